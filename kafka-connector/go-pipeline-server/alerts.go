@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 告警规则（后台定时评估，firing/resolved 状态跟踪） **************/
+
+const (
+	ruleESCount        = "es_count"        // 时间窗口内匹配查询的日志条数
+	ruleConsumerLag    = "consumer_lag"    // sink consumer group 的总延迟
+	ruleILMError       = "ilm_error"       // ILM/ISM 处于 ERROR 步骤的索引数
+	ruleConnectorState = "connector_state" // connector 或 task 非 RUNNING 的数量
+	ruleDiskWatermark  = "disk_watermark"  // 磁盘超过 high 水位的节点数
+	ruleShardHeadroom  = "shard_headroom"  // 下一次 rollover 后的分片余量（配合 op: "<"）
+	ruleCCRLag         = "ccr_lag"         // CCR follower index 落后 leader 的最大操作数
+
+	alertOK     = "ok"
+	alertFiring = "firing"
+)
+
+type AlertRule struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`      // es_count | consumer_lag | ilm_error | connector_state | disk_watermark | shard_headroom | ccr_lag
+	Query     string   `yaml:"query"`     // es_count：query_string，如 log.level:ERROR
+	Index     string   `yaml:"index"`     // es_count：默认 es.names.data_stream
+	Window    string   `yaml:"window"`    // es_count：统计窗口，默认 1m
+	Op        string   `yaml:"op"`        // > | >= | < | <= | ==，默认 >
+	Threshold float64  `yaml:"threshold"` // 阈值
+	Severity  string   `yaml:"severity"`  // info | warning | critical，默认 warning
+	Targets   []string `yaml:"targets"`   // 通知目标名；空则按事件订阅路由
+}
+
+type AlertsConfig struct {
+	Interval string      `yaml:"interval"` // 评估间隔，默认 1m
+	Rules    []AlertRule `yaml:"rules"`
+}
+
+type alertState struct {
+	Rule      string     `json:"rule"`
+	Type      string     `json:"type"`
+	State     string     `json:"state"` // ok | firing
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold"`
+	Op        string     `json:"op"`
+	Since     *time.Time `json:"since,omitempty"` // 进入当前状态的时间
+	LastEval  time.Time  `json:"last_eval"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type alertManager struct {
+	mu     sync.Mutex
+	states map[string]*alertState
+}
+
+func newAlertManager() *alertManager { return &alertManager{states: map[string]*alertState{}} }
+
+func compareThreshold(op string, v, threshold float64) (bool, error) {
+	switch op {
+	case ">", "":
+		return v > threshold, nil
+	case ">=":
+		return v >= threshold, nil
+	case "<":
+		return v < threshold, nil
+	case "<=":
+		return v <= threshold, nil
+	case "==":
+		return v == threshold, nil
+	default:
+		return false, fmt.Errorf("unknown op %q", op)
+	}
+}
+
+func (s *Server) evalESCount(ctx context.Context, r AlertRule) (float64, error) {
+	index := r.Index
+	if index == "" {
+		index = s.cfg.ES.Names.DataStream
+	}
+	window := r.Window
+	if window == "" {
+		window = "1m"
+	}
+	filter := []any{map[string]any{"range": map[string]any{"@timestamp": map[string]any{"gte": "now-" + window}}}}
+	if r.Query != "" {
+		filter = append(filter, map[string]any{"query_string": map[string]any{"query": r.Query}})
+	}
+	body, _ := json.Marshal(map[string]any{"query": map[string]any{"bool": map[string]any{"filter": filter}}})
+	resp, respBody, err := s.doPOST(ctx, fmt.Sprintf("%s/%s/_count", s.cfg.ES.Host, index), body, "es")
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("_count returned %s", resp.Status)
+	}
+	var out struct {
+		Count float64 `json:"count"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+func (s *Server) evalILMError(ctx context.Context) (float64, error) {
+	resp, body, err := s.doGET(ctx, s.lifecycleExplainURL(), "es")
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("lifecycle explain returned %s", resp.Status)
+	}
+	// ILM：indices.<idx>.step；ISM：<idx>.step.name / failed
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return 0, err
+	}
+	n := 0
+	if raw, ok := doc["indices"]; ok {
+		var indices map[string]struct {
+			Step string `json:"step"`
+		}
+		if err := json.Unmarshal(raw, &indices); err != nil {
+			return 0, err
+		}
+		for _, idx := range indices {
+			if idx.Step == "ERROR" {
+				n++
+			}
+		}
+		return float64(n), nil
+	}
+	for k, raw := range doc {
+		if k == "total_managed_indices" {
+			continue
+		}
+		var idx struct {
+			Failed bool `json:"failed"`
+			Step   struct {
+				StepStatus string `json:"step_status"`
+			} `json:"step"`
+		}
+		if json.Unmarshal(raw, &idx) == nil && (idx.Failed || idx.Step.StepStatus == "failed") {
+			n++
+		}
+	}
+	return float64(n), nil
+}
+
+func (s *Server) evalAlertRule(ctx context.Context, r AlertRule) (float64, error) {
+	switch r.Type {
+	case ruleESCount:
+		return s.evalESCount(ctx, r)
+	case ruleConsumerLag:
+		st := s.collectSinkState(ctx)
+		lag := s.collectConsumerLag(ctx, st.Name)
+		if lag == nil {
+			return 0, fmt.Errorf("kafka is not configured")
+		}
+		if lag.Error != "" {
+			return 0, fmt.Errorf("%s", lag.Error)
+		}
+		return float64(lag.Total), nil
+	case ruleILMError:
+		return s.evalILMError(ctx)
+	case ruleConnectorState:
+		st := s.collectSinkState(ctx)
+		if st.Error != "" {
+			return 0, fmt.Errorf("%s", st.Error)
+		}
+		n := 0
+		if st.State != "RUNNING" {
+			n++
+		}
+		for _, t := range st.Tasks {
+			if t.State != "RUNNING" {
+				n++
+			}
+		}
+		return float64(n), nil
+	case ruleDiskWatermark, ruleShardHeadroom:
+		return s.evalCapacity(ctx, r.Type)
+	case ruleCCRLag:
+		return s.evalCCRLag(ctx)
+	default:
+		return 0, fmt.Errorf("unknown rule type %q", r.Type)
+	}
+}
+
+// 评估一条规则并更新状态；状态发生迁移时发送通知
+func (s *Server) evaluateAlert(ctx context.Context, r AlertRule) {
+	v, err := s.evalAlertRule(ctx, r)
+	now := time.Now()
+	firing := false
+	if err == nil {
+		firing, err = compareThreshold(r.Op, v, r.Threshold)
+	}
+
+	s.alerts.mu.Lock()
+	st, ok := s.alerts.states[r.Name]
+	if !ok {
+		st = &alertState{Rule: r.Name, Type: r.Type, State: alertOK}
+		s.alerts.states[r.Name] = st
+	}
+	st.LastEval, st.Threshold, st.Op = now, r.Threshold, r.Op
+	if st.Op == "" {
+		st.Op = ">"
+	}
+	if err != nil {
+		// 评估失败不改变 firing/ok 状态，避免下游抖动误报恢复
+		st.Error = err.Error()
+		s.alerts.mu.Unlock()
+		s.logger.Printf("alert rule=%s eval_err=%v", r.Name, err)
+		return
+	}
+	st.Error, st.Value = "", v
+	prev := st.State
+	next := alertOK
+	if firing {
+		next = alertFiring
+	}
+	if prev != next {
+		st.State, st.Since = next, &now
+	}
+	s.alerts.mu.Unlock()
+	if prev == next {
+		return
+	}
+
+	s.logger.Printf("alert rule=%s state=%s value=%v threshold=%s%v", r.Name, next, v, st.Op, r.Threshold)
+	severity := r.Severity
+	if severity == "" {
+		severity = "warning"
+	}
+	n := notification{Severity: severity, At: now, targets: r.Targets,
+		Fields: map[string]any{"rule": r.Name, "type": r.Type, "value": v, "threshold": st.Op + fmt.Sprint(r.Threshold)}}
+	if next == alertFiring {
+		n.Event, n.Title = eventAlertFiring, "Alert firing: "+r.Name
+		n.Text = fmt.Sprintf("%s = %v (threshold %s %v)", r.Type, v, st.Op, r.Threshold)
+	} else {
+		n.Event, n.Severity, n.Title = eventAlertResolved, "info", "Alert resolved: "+r.Name
+		n.Text = fmt.Sprintf("%s = %v", r.Type, v)
+	}
+	if q := strings.TrimSpace(r.Query); q != "" {
+		n.Fields["query"] = q
+	}
+	s.notify(n)
+}
+
+func (s *Server) runAlertRules(ctx context.Context) {
+	rules := s.cfg.Alerts.Rules
+	if len(rules) == 0 {
+		return
+	}
+	interval := mustParseDuration("alerts.interval", s.cfg.Alerts.Interval)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	s.logger.Printf("alerts rules=%d interval=%s", len(rules), interval)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if !s.inMaintenance("alerts") {
+			var wg sync.WaitGroup
+			for _, r := range rules {
+				wg.Add(1)
+				go func(r AlertRule) {
+					defer wg.Done()
+					rctx, cancel := context.WithTimeout(ctx, interval)
+					defer cancel()
+					s.evaluateAlert(rctx, r)
+				}(r)
+			}
+			wg.Wait()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Server) handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	s.alerts.mu.Lock()
+	out := make([]alertState, 0, len(s.cfg.Alerts.Rules))
+	for _, rule := range s.cfg.Alerts.Rules {
+		if st, ok := s.alerts.states[rule.Name]; ok {
+			out = append(out, *st)
+		} else {
+			out = append(out, alertState{Rule: rule.Name, Type: rule.Type, State: "pending", Threshold: rule.Threshold, Op: rule.Op})
+		}
+	}
+	s.alerts.mu.Unlock()
+	sort.SliceStable(out, func(i, k int) bool { return out[i].State == alertFiring && out[k].State != alertFiring })
+	writeJSON(w, http.StatusOK, out)
+}
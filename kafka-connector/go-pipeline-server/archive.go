@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 归档层：快照仓库 / searchable snapshot / S3 sink / 按时间回灌 **************/
+
+type ArchiveConfig struct {
+	Repository          string     `yaml:"repository"` // ES 快照仓库名
+	Bucket              string     `yaml:"bucket"`
+	BasePath            string     `yaml:"base_path"`
+	Client              string     `yaml:"client"` // s3.client.<name>，凭据配置在 ES keystore
+	SearchableSnapshots bool       `yaml:"searchable_snapshots"`
+	SnapshotPhase       string     `yaml:"snapshot_phase"` // cold | frozen
+	SnapshotAfter       string     `yaml:"snapshot_after"` // 进入该 phase 的 min_age，如 3d
+	Sink                SinkConfig `yaml:"sink"`           // 同 topic 的 S3 sink connector
+	RestorePrefix       string     `yaml:"restore_prefix"` // 回灌临时索引前缀
+}
+
+func (s *Server) archiveRepoURL() string {
+	return fmt.Sprintf("%s/_snapshot/%s", s.cfg.ES.Host, s.cfg.Archive.Repository)
+}
+
+// 解析 ES 时间单位（d/h/m/s/ms）
+func parseESDuration(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{{"ms", time.Millisecond}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}}
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(v, u.suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Duration(f * float64(u.unit)), true
+		}
+	}
+	return 0, false
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// 在 ILM policy 上叠加 searchable_snapshot phase（仅 ES；OpenSearch 无对应能力）
+func (s *Server) applyArchiveOverlay(b []byte) ([]byte, []string, error) {
+	a := s.cfg.Archive
+	if !a.SearchableSnapshots {
+		return b, nil, nil
+	}
+	if s.isOpenSearch() {
+		return b, []string{"archive.searchable_snapshots ignored: not supported on opensearch"}, nil
+	}
+	if a.Repository == "" {
+		return nil, nil, fmt.Errorf("archive.repository is required for searchable snapshots")
+	}
+	phase := a.SnapshotPhase
+	if phase != "cold" {
+		phase = "frozen"
+	}
+	minAge := a.SnapshotAfter
+	if minAge == "" {
+		minAge = "3d"
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse ilm policy: %w", err)
+	}
+	policy, _ := doc["policy"].(map[string]any)
+	if policy == nil {
+		return nil, nil, fmt.Errorf("ilm policy has no \"policy\" object")
+	}
+	phases, _ := policy["phases"].(map[string]any)
+	if phases == nil {
+		phases = map[string]any{}
+		policy["phases"] = phases
+	}
+	ph, _ := phases[phase].(map[string]any)
+	if ph == nil {
+		ph = map[string]any{"min_age": minAge}
+		phases[phase] = ph
+	}
+	actions, _ := ph["actions"].(map[string]any)
+	if actions == nil {
+		actions = map[string]any{}
+		ph["actions"] = actions
+	}
+	actions["searchable_snapshot"] = map[string]any{"snapshot_repository": a.Repository}
+
+	var warnings []string
+	if del, ok := phases["delete"].(map[string]any); ok {
+		delAge, _ := del["min_age"].(string)
+		phAge, _ := ph["min_age"].(string)
+		d1, ok1 := parseESDuration(delAge)
+		d2, ok2 := parseESDuration(phAge)
+		if ok1 && ok2 && d1 <= d2 {
+			warnings = append(warnings, fmt.Sprintf("delete phase min_age=%s is not later than %s phase min_age=%s; data is deleted before archiving", delAge, phase, phAge))
+		}
+	}
+	out, err := json.Marshal(doc)
+	return out, warnings, err
+}
+
+func (s *Server) handlePutArchiveRepository(w http.ResponseWriter, r *http.Request) {
+	a := s.cfg.Archive
+	if a.Repository == "" || a.Bucket == "" {
+		writeJSON(w, 400, map[string]string{"error": "archive.repository and archive.bucket are required"})
+		return
+	}
+	settings := map[string]any{"bucket": a.Bucket}
+	if a.BasePath != "" {
+		settings["base_path"] = a.BasePath
+	}
+	if a.Client != "" {
+		settings["client"] = a.Client
+	}
+	b, _ := json.Marshal(map[string]any{"type": "s3", "settings": settings})
+	url := s.archiveRepoURL()
+	s.logger.Printf("step=archive-repo put url=%s", url)
+	resp, respBody, err := s.doPUT(r.Context(), url, b, "es")
+	if err != nil {
+		writeJSON(w, 500, errorBody("archive-repo", err))
+		return
+	}
+	writeJSON(w, resp.StatusCode, map[string]any{"step": "archive-repo", "status": resp.Status, "body": string(respBody)})
+}
+
+// 重新下发 ILM（叠加 searchable snapshot phase），返回生效的策略
+func (s *Server) handlePutSearchableSnapshots(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Archive.SearchableSnapshots {
+		writeJSON(w, 400, map[string]string{"error": "archive.searchable_snapshots is disabled"})
+		return
+	}
+	s.handlePutILM(w, r)
+}
+
+// 归档 sink 的 connector 名，默认 <主 sink 名>-s3-archive
+func (s *Server) archiveSinkName() string {
+	if n := s.cfg.Archive.Sink.Name; n != "" {
+		return n
+	}
+	return s.cfg.Connect.Names.Sink + "-s3-archive"
+}
+
+func (s *Server) handleRegisterArchiveSink(w http.ResponseWriter, r *http.Request) {
+	sc := s.cfg.Archive.Sink
+	sc.Type = sinkTypeS3
+	sc.Name = s.archiveSinkName()
+	if len(sc.Topics) == 0 {
+		sc.Topics = s.primarySinkTopics()
+	}
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	res, err := p.Register(optionsContext(r))
+	s.recordSinkRegister(r, p, res, err, 0)
+	s.writeSinkRegister(w, p, res, err)
+}
+
+// 主 sink 文件中的 topics，供归档 sink 复用同一 topic
+func (s *Server) primarySinkTopics() []string {
+	b, err := s.readConfiguredAsset(assetSink, s.primarySinkConfig().File)
+	if err != nil {
+		return nil
+	}
+	var sink struct {
+		Config map[string]any `json:"config"`
+	}
+	if json.Unmarshal(b, &sink) != nil {
+		return nil
+	}
+	t, _ := sink.Config["topics"].(string)
+	var out []string
+	for _, p := range strings.Split(t, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+/************** 按时间范围回灌 **************/
+
+type restoreRequest struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Target string    `json:"target,omitempty"`
+}
+
+type snapshotInfo struct {
+	Snapshot   string   `json:"snapshot"`
+	State      string   `json:"state"`
+	Indices    []string `json:"indices"`
+	StartMilli int64    `json:"start_time_in_millis"`
+}
+
+func (s *Server) handleArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Archive.Repository == "" {
+		writeJSON(w, 400, map[string]string{"error": "archive.repository is not configured"})
+		return
+	}
+	var req restoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if req.From.IsZero() || req.To.IsZero() || !req.From.Before(req.To) {
+		writeJSON(w, 400, map[string]string{"error": "from/to are required (RFC3339) and from must be before to"})
+		return
+	}
+	j := s.startJob("archive-restore", req, func(ctx context.Context, j *Job) (any, error) {
+		return s.runArchiveRestore(ctx, j, req)
+	})
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// 选取快照：[from,to] 内的快照 + to 之后的第一个；同一索引取最新快照中的版本
+func pickSnapshots(snaps []snapshotInfo, ds string, from, to time.Time) map[string][]string {
+	sort.Slice(snaps, func(i, k int) bool { return snaps[i].StartMilli < snaps[k].StartMilli })
+	var chosen []snapshotInfo
+	for _, sn := range snaps {
+		if sn.State != "SUCCESS" {
+			continue
+		}
+		start := time.UnixMilli(sn.StartMilli)
+		if start.Before(from) {
+			continue
+		}
+		chosen = append(chosen, sn)
+		if start.After(to) {
+			break
+		}
+	}
+	prefix := ".ds-" + ds + "-"
+	latest := map[string]string{}
+	for _, sn := range chosen {
+		for _, idx := range sn.Indices {
+			if strings.HasPrefix(idx, prefix) {
+				latest[idx] = sn.Snapshot
+			}
+		}
+	}
+	out := map[string][]string{}
+	for idx, sn := range latest {
+		out[sn] = append(out[sn], idx)
+	}
+	for sn := range out {
+		sort.Strings(out[sn])
+	}
+	return out
+}
+
+func (s *Server) runArchiveRestore(ctx context.Context, j *Job, req restoreRequest) (any, error) {
+	ds := s.cfg.ES.Names.DataStream
+	prefix := s.cfg.Archive.RestorePrefix
+	if prefix == "" {
+		prefix = "restore"
+	}
+	target := req.Target
+	if target == "" {
+		target = fmt.Sprintf("%s-%s-%s", prefix, ds, strings.ToLower(j.ID))
+	}
+	tmpPrefix := fmt.Sprintf("%s-tmp-%s-", prefix, strings.ToLower(j.ID))
+
+	// 1) 列出快照
+	resp, body, err := s.doGET(ctx, s.archiveRepoURL()+"/_all", "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("list snapshots: %s %s", resp.Status, body)
+	}
+	var list struct {
+		Snapshots []snapshotInfo `json:"snapshots"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("decode snapshots: %w", err)
+	}
+	plan := pickSnapshots(list.Snapshots, ds, req.From, req.To)
+	if len(plan) == 0 {
+		j.Step("select-snapshots", "failed", "no snapshot covers the requested range")
+		return nil, fmt.Errorf("no snapshot of %s covers %s..%s", ds, req.From.Format(time.RFC3339), req.To.Format(time.RFC3339))
+	}
+	j.Step("select-snapshots", "ok", fmt.Sprintf("%d snapshots", len(plan)))
+
+	// 2) 恢复到临时索引（去掉 ILM/默认 pipeline，避免被生命周期管理或二次处理）
+	var restored []string
+	for snap, indices := range plan {
+		rb, _ := json.Marshal(map[string]any{
+			"indices":               strings.Join(indices, ","),
+			"include_global_state":  false,
+			"include_aliases":       false,
+			"rename_pattern":        "(.+)",
+			"rename_replacement":    tmpPrefix + "$1",
+			"index_settings":        map[string]any{"index.number_of_replicas": 0},
+			"ignore_index_settings": []string{"index.lifecycle.name", "index.default_pipeline"},
+		})
+		url := fmt.Sprintf("%s/%s/_restore", s.archiveRepoURL(), snap)
+		resp, body, err := s.doPOST(ctx, url, rb, "es")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			j.Step("restore", "failed", snap)
+			return nil, fmt.Errorf("restore %s: %s %s", snap, resp.Status, body)
+		}
+		for _, idx := range indices {
+			restored = append(restored, tmpPrefix+idx)
+		}
+		j.Step("restore", "ok", fmt.Sprintf("%s: %d indices", snap, len(indices)))
+	}
+	defer s.cleanupRestored(j, restored)
+
+	healthURL := fmt.Sprintf("%s/_cluster/health/%s?wait_for_status=green&timeout=20s", s.cfg.ES.Host, strings.Join(restored, ","))
+	for {
+		resp, body, err := s.doGET(ctx, healthURL, "es")
+		if err != nil {
+			return nil, err
+		}
+		var h struct {
+			TimedOut bool `json:"timed_out"`
+		}
+		_ = json.Unmarshal(body, &h)
+		if resp.StatusCode == http.StatusOK && !h.TimedOut {
+			break
+		}
+		j.SetProgress("restore", "recovering")
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return nil, err
+		}
+	}
+	j.Step("recover", "ok", "")
+
+	// 3) 按时间范围 reindex 到目标索引
+	rb, _ := json.Marshal(map[string]any{
+		"source": map[string]any{
+			"index": strings.Join(restored, ","),
+			"query": map[string]any{"range": map[string]any{"@timestamp": map[string]any{
+				"gte": req.From.Format(time.RFC3339Nano), "lte": req.To.Format(time.RFC3339Nano),
+			}}},
+		},
+		"dest": map[string]any{"index": target},
+	})
+	resp, body, err = s.doPOST(ctx, s.cfg.ES.Host+"/_reindex?wait_for_completion=false", rb, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("reindex: %s %s", resp.Status, body)
+	}
+	var task struct {
+		Task string `json:"task"`
+	}
+	_ = json.Unmarshal(body, &task)
+	status, err := s.waitESTask(ctx, j, task.Task)
+	if err != nil {
+		return nil, err
+	}
+	j.Step("reindex", "ok", target)
+
+	return map[string]any{"target": target, "snapshots": plan, "reindex": status}, nil
+}
+
+// 轮询 _tasks/{id} 直到完成，并把 status 写进 job 进度
+func (s *Server) waitESTask(ctx context.Context, j *Job, taskID string) (any, error) {
+	if taskID == "" {
+		return nil, fmt.Errorf("es did not return a task id")
+	}
+	u := fmt.Sprintf("%s/_tasks/%s", s.cfg.ES.Host, url.PathEscape(taskID))
+	for {
+		resp, body, err := s.doGET(ctx, u, "es")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("task %s: %s %s", taskID, resp.Status, body)
+		}
+		var t struct {
+			Completed bool `json:"completed"`
+			Task      struct {
+				Status any `json:"status"`
+			} `json:"task"`
+			Error    any `json:"error"`
+			Response any `json:"response"`
+		}
+		if err := json.Unmarshal(body, &t); err != nil {
+			return nil, fmt.Errorf("decode task: %w", err)
+		}
+		j.SetProgress("task", t.Task.Status)
+		if t.Completed {
+			if t.Error != nil {
+				return nil, fmt.Errorf("task %s failed: %v", taskID, t.Error)
+			}
+			return t.Response, nil
+		}
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 删除临时恢复的索引（逐个指名，兼容 action.destructive_requires_name）
+func (s *Server) cleanupRestored(j *Job, indices []string) {
+	if len(indices) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	url := fmt.Sprintf("%s/%s", s.cfg.ES.Host, strings.Join(indices, ","))
+	resp, _, err := s.doDELETE(ctx, url, "es")
+	if err != nil {
+		j.Step("cleanup", "failed", err.Error())
+		return
+	}
+	j.Step("cleanup", "ok", resp.Status)
+}
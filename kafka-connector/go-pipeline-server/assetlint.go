@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+/************** 资产文件交叉检查 **************/
+
+// 不访问 ES / Connect，只对照配置检查 ILM / 模板 / pipeline / 主 sink 文件之间引用的名字：
+//   - 模板 index_patterns 匹配 es.names.data_stream，带 data_stream 对象
+//   - 模板 index.lifecycle.name = es.names.ilm_policy，index.default_pipeline = es.names.pipeline
+//   - sink 的 name = connect.names.sink，topics 含 kafka.topic，写入的 data stream 与 ingest pipeline 与配置一致
+// 另外按 guardrails 检查分片数、保留期、connector 任务数与 topic 前缀（guardrails.go），按 ecs.mode 检查模板 mappings（ecs.go）。
+// 名字对不上是部署时最常见的问题（数据进了没有模板的索引、pipeline 没生效等），下发前先查出来。
+// GET /admin/assets/lint[?ref=]；check-config 也会执行。
+
+func (s *Server) lintAssets(ctx context.Context) []configIssue {
+	var issues []configIssue
+	add := func(level, p, format string, args ...any) {
+		issues = append(issues, configIssue{Level: level, Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+	names := s.cfg.ES.Names
+
+	load := func(kind, file string) map[string]any {
+		b, _, err := s.readAssetOrDefault(ctx, kind, file)
+		if err != nil {
+			add(issueError, kind, "%v", err)
+			return nil
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			add(issueError, kind, "parse %s: %v", file, err)
+			return nil
+		}
+		return doc
+	}
+
+	guard := func(found []guardrailViolation) {
+		for _, v := range found {
+			add(issueError, "guardrails."+v.Rule, "%s", v.Message)
+		}
+	}
+
+	if doc := load(assetILM, s.cfg.ES.Files.ILM); doc != nil {
+		guard(s.cfg.Guardrails.checkILM(doc))
+		policy, _ := doc["policy"].(map[string]any)
+		if phases, _ := policy["phases"].(map[string]any); len(phases) == 0 {
+			add(issueError, "ilm.policy.phases", "ILM policy has no phases")
+		}
+	}
+
+	if doc := load(assetPipeline, s.cfg.ES.Files.Pipeline); doc != nil {
+		if procs, _ := doc["processors"].([]any); len(procs) == 0 {
+			add(issueWarning, "pipeline.processors", "ingest pipeline has no processors")
+		}
+	}
+
+	if doc := load(assetTemplate, s.cfg.ES.Files.Template); doc != nil {
+		guard(s.cfg.Guardrails.checkTemplate(doc))
+		level := issueWarning
+		if s.cfg.ECS.mode() == ecsModeBlock {
+			level = issueError
+		}
+		for _, is := range s.cfg.ECS.checkTemplate(doc) {
+			add(level, "template.mappings.properties."+is.Field, "%s", is.Message)
+		}
+		if _, ok := doc["data_stream"].(map[string]any); !ok {
+			add(issueError, "template.data_stream", "index template has no data_stream object; %s cannot be created as a data stream", names.DataStream)
+		}
+		// data_stream 为名字模板时 index_patterns 在下发时改写，不检查
+		if !names.templated && names.DataStream != "" {
+			pats, _ := doc["index_patterns"].([]any)
+			if !slices.ContainsFunc(pats, func(p any) bool { ok, _ := path.Match(fmt.Sprint(p), names.DataStream); return ok }) {
+				add(issueError, "template.index_patterns", "%v does not match data stream %s (es.names.data_stream)", pats, names.DataStream)
+			}
+		}
+		tpl, _ := doc["template"].(map[string]any)
+		settings, _ := tpl["settings"].(map[string]any)
+		if !s.isOpenSearch() {
+			switch v, ok := lookupSetting(settings, "index.lifecycle.name"); {
+			case !ok && names.ILMPolicy != "":
+				add(issueWarning, "template.settings.index.lifecycle.name", "not set; backing indices of %s get no ILM policy (es.names.ilm_policy=%s)", names.DataStream, names.ILMPolicy)
+			case ok && fmt.Sprint(v) != names.ILMPolicy:
+				add(issueError, "template.settings.index.lifecycle.name", "%v does not match es.names.ilm_policy=%s", v, names.ILMPolicy)
+			}
+		}
+		if v, ok := lookupSetting(settings, "index.default_pipeline"); ok && fmt.Sprint(v) != names.Pipeline && fmt.Sprint(v) != "_none" {
+			add(issueError, "template.settings.index.default_pipeline", "%v does not match es.names.pipeline=%s", v, names.Pipeline)
+		}
+	}
+
+	if sc := s.primarySinkConfig(); sc.Type == sinkTypeConnect {
+		b, err := s.readSinkAsset(ctx, sc)
+		if err != nil {
+			add(issueError, assetSink, "%v", err)
+			return issues
+		}
+		var doc struct {
+			Name   string            `json:"name"`
+			Config map[string]string `json:"config"`
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			add(issueError, assetSink, "parse %s: %v", sc.File, err)
+			return issues
+		}
+		if doc.Name != sc.Name {
+			add(issueError, "sink.name", "%q does not match connect.names.sink=%q", doc.Name, sc.Name)
+		}
+		c := doc.Config
+		guard(s.cfg.Guardrails.checkConnector(c))
+		var topics []string
+		for _, t := range strings.Split(c["topics"], ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				topics = append(topics, t)
+			}
+		}
+		if s.cfg.Kafka.Topic != "" && c["topics.regex"] == "" && !slices.Contains(topics, s.cfg.Kafka.Topic) {
+			add(issueWarning, "sink.config.topics", "%v does not include kafka.topic=%s", topics, s.cfg.Kafka.Topic)
+		}
+		if m := c["topic.to.external.resource.mapping"]; m != "" {
+			for _, pair := range strings.Split(m, ",") {
+				topic, target, _ := strings.Cut(strings.TrimSpace(pair), ":")
+				if target != names.DataStream {
+					add(issueError, "sink.config.topic.to.external.resource.mapping", "topic %s is written to %q, not es.names.data_stream=%s", topic, target, names.DataStream)
+				}
+			}
+		} else if strings.EqualFold(c["external.resource.usage"], "DATASTREAM") {
+			add(issueError, "sink.config.topic.to.external.resource.mapping", "external.resource.usage=DATASTREAM but no topic mapping to %s", names.DataStream)
+		}
+		if p := c["ingest.pipeline.name"]; p != "" && c["use.ingest.pipeline"] != "false" && p != names.Pipeline {
+			add(issueError, "sink.config.ingest.pipeline.name", "%s does not match es.names.pipeline=%s", p, names.Pipeline)
+		}
+	}
+	return issues
+}
+
+// 模板 settings 取值：兼容 "index.lifecycle.name" 扁平写法、嵌套写法与省略 index. 前缀
+func lookupSetting(m map[string]any, key string) (any, bool) {
+	if m == nil {
+		return nil, false
+	}
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	parts := strings.Split(key, ".")
+	for i := 1; i < len(parts); i++ {
+		if sub, ok := m[strings.Join(parts[:i], ".")].(map[string]any); ok {
+			if v, ok := lookupSetting(sub, strings.Join(parts[i:], ".")); ok {
+				return v, true
+			}
+		}
+	}
+	if rest, ok := strings.CutPrefix(key, "index."); ok {
+		if v, ok := m[rest]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// GET /admin/assets/lint[?ref=]
+func (s *Server) handleLintAssets(w http.ResponseWriter, r *http.Request) {
+	issues := s.lintAssets(optionsContext(r))
+	if issues == nil {
+		issues = []configIssue{}
+	}
+	errs := configIssueErrors(issues)
+	if len(errs) > 0 {
+		s.logger.Printf("step=assets-lint errors=%d first=%q", len(errs), errs[0].Path+": "+errs[0].Message)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": len(errs) == 0, "issues": issues})
+}
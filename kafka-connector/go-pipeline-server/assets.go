@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 资产版本历史（ILM / 模板 / pipeline / sink）与回滚 **************/
+
+// 每次成功下发都记录一版：内容按 sha256 存到 <dir>/objects/<hash>.json，
+// 版本列表存在 <dir>/index.json。ES 资产记录的是叠加（ISM/降采样等）之前的原始文档，
+// 回滚时重新走同一套下发流程。
+
+const (
+	assetILM      = "ilm"
+	assetTemplate = "template"
+	assetPipeline = "pipeline"
+	assetSink     = "sink"
+
+	defaultAssetsDir  = "asset-versions"
+	defaultAssetsKeep = 100
+)
+
+var assetKinds = []string{assetILM, assetTemplate, assetPipeline, assetSink}
+
+type AssetsConfig struct {
+	Dir  string `yaml:"dir"`  // 版本存储目录，默认 asset-versions
+	Keep int    `yaml:"keep"` // 每类资产保留的版本数，默认 100
+}
+
+type assetVersion struct {
+	Version    int       `json:"version"`
+	Hash       string    `json:"hash"`
+	Size       int       `json:"size"`
+	AppliedAt  time.Time `json:"applied_at"`
+	AppliedBy  string    `json:"applied_by,omitempty"` // 操作人（X-Operator 或客户端 IP）与 User-Agent
+	Source     string    `json:"source"`               // 资产文件路径，或 rollback
+	RollbackOf int       `json:"rollback_of,omitempty"`
+	// 下发时的 ownership.labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// 经变更计划下发时的计划 ID 与批准人（plans.go）
+	Plan       string   `json:"plan,omitempty"`
+	ApprovedBy []string `json:"approved_by,omitempty"`
+}
+
+type assetStore struct {
+	mu    sync.Mutex
+	dir   string
+	keep  int
+	index map[string][]assetVersion // key: kind 或 sink/<name>
+}
+
+func newAssetStore(cfg AssetsConfig) *assetStore {
+	st := &assetStore{dir: cfg.Dir, keep: cfg.Keep, index: map[string][]assetVersion{}}
+	if st.dir == "" {
+		st.dir = defaultAssetsDir
+	}
+	if st.keep <= 0 {
+		st.keep = defaultAssetsKeep
+	}
+	return st
+}
+
+func assetKey(kind, name string) string {
+	if kind == assetSink {
+		return assetSink + "/" + name
+	}
+	return kind
+}
+
+func (st *assetStore) objectPath(hash string) string {
+	return filepath.Join(st.dir, "objects", hash+".json")
+}
+
+// 读取版本索引；目录不存在不算错误
+func (st *assetStore) load() error {
+	b, err := os.ReadFile(filepath.Join(st.dir, "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := json.Unmarshal(b, &st.index); err != nil {
+		return fmt.Errorf("decode %s/index.json: %w", st.dir, err)
+	}
+	return nil
+}
+
+func (st *assetStore) saveLocked() error {
+	b, err := json.MarshalIndent(st.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(st.dir, "index.json")
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// 记录一次成功下发；内容与最新版本相同时只更新时间，不新增版本
+func (st *assetStore) record(key string, b []byte, v assetVersion) (assetVersion, error) {
+	sum := sha256.Sum256(b)
+	v.Hash, v.Size = hex.EncodeToString(sum[:]), len(b)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := os.MkdirAll(filepath.Join(st.dir, "objects"), 0o755); err != nil {
+		return v, err
+	}
+	if _, err := os.Stat(st.objectPath(v.Hash)); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(st.objectPath(v.Hash), b, 0o644); err != nil {
+			return v, err
+		}
+	}
+	list := st.index[key]
+	if n := len(list); n > 0 && list[n-1].Hash == v.Hash && v.RollbackOf == 0 {
+		list[n-1].AppliedAt, list[n-1].AppliedBy, list[n-1].Labels = v.AppliedAt, v.AppliedBy, v.Labels
+		list[n-1].Plan, list[n-1].ApprovedBy = v.Plan, v.ApprovedBy
+		return list[n-1], st.saveLocked()
+	}
+	v.Version = 1
+	if n := len(list); n > 0 {
+		v.Version = list[n-1].Version + 1
+	}
+	list = append(list, v)
+	if len(list) > st.keep {
+		list = list[len(list)-st.keep:]
+	}
+	st.index[key] = list
+	if err := st.saveLocked(); err != nil {
+		return v, err
+	}
+	st.pruneLocked()
+	return v, nil
+}
+
+// 删除不再被任何版本引用的内容
+func (st *assetStore) pruneLocked() {
+	used := map[string]bool{}
+	for _, list := range st.index {
+		for _, v := range list {
+			used[v.Hash] = true
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(st.dir, "objects", "*.json"))
+	for _, f := range files {
+		if h := filepath.Base(f); !used[h[:len(h)-len(".json")]] {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+func (st *assetStore) versions(key string) []assetVersion {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := st.index[key]
+	out := make([]assetVersion, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		out = append(out, list[i])
+	}
+	return out
+}
+
+func (st *assetStore) get(key string, version int) (assetVersion, []byte, error) {
+	st.mu.Lock()
+	var v assetVersion
+	found := false
+	for _, x := range st.index[key] {
+		if x.Version == version {
+			v, found = x, true
+		}
+	}
+	st.mu.Unlock()
+	if !found {
+		return v, nil, os.ErrNotExist
+	}
+	b, err := os.ReadFile(st.objectPath(v.Hash))
+	return v, b, err
+}
+
+// 下发成功后调用；记录失败只打日志，不影响下发结果
+func (s *Server) recordAsset(r *http.Request, kind, name, source string, rollbackOf int, b []byte) {
+	v := assetVersion{AppliedAt: time.Now().UTC(), AppliedBy: strings.TrimSpace(operatorIdentity(r) + " " + r.UserAgent()), Source: source, RollbackOf: rollbackOf, Labels: s.cfg.Ownership.Labels}
+	if p, ok := r.Context().Value(planKey{}).(*changePlan); ok {
+		v.Plan, v.ApprovedBy = p.ID, p.approvers()
+	}
+	v, err := s.assets.record(assetKey(kind, name), b, v)
+	if err != nil {
+		s.logger.Printf("step=asset-version kind=%s name=%s record_err=%v", kind, name, err)
+		return
+	}
+	s.logger.Printf("step=asset-version kind=%s name=%s version=%d hash=%s", kind, name, v.Version, v.Hash[:12])
+}
+
+// connect / s3 sink 注册成功后记录实际提交的 connector 文档
+func (s *Server) recordSinkRegister(r *http.Request, p SinkProvider, res *sinkResponse, err error, rollbackOf int) {
+	c, ok := p.(*connectSink)
+	if err != nil || !ok || res == nil || res.Code >= 300 || res.Applied == nil {
+		return
+	}
+	source := assetSource(optionsContext(r), c.file)
+	if rollbackOf > 0 {
+		source = "rollback"
+	} else if source == "" {
+		source = "rendered from sinks config"
+	}
+	s.recordAsset(r, assetSink, c.name, source, rollbackOf, res.Applied)
+}
+
+/************** 乐观并发：If-Match 修订号 **************/
+
+// 接受文档的编辑接口（PATCH /admin/es/{kind}、PUT /admin/es/pipeline/processors）要求带 If-Match，
+// 值为读取时响应头 ETag 给出的修订号；与当前修订号不一致说明期间有人改过，返回 409，
+// 避免两人同时编辑时后写的静默覆盖前者。不带 If-Match 返回 428；If-Match: * 显式跳过检查（脚本用）。
+// 修订号取自版本历史：最新版本为 "v<N>"，尚无历史为 "v0"。
+// 可视化 pipeline 编辑改的是资产文件（不下发就不产生新版本），修订号再带上文件内容哈希。
+
+func (s *Server) assetRevision(kind, name string) string {
+	n := 0
+	if vs := s.assets.versions(assetKey(kind, name)); len(vs) > 0 {
+		n = vs[0].Version
+	}
+	return "v" + strconv.Itoa(n)
+}
+
+func fileRevision(rev string, b []byte) string {
+	sum := sha256.Sum256(b)
+	return rev + "-" + hex.EncodeToString(sum[:6])
+}
+
+func setETag(w http.ResponseWriter, rev string) {
+	w.Header().Set("ETag", strconv.Quote(rev))
+}
+
+// 校验 If-Match；不通过时已写好 428/409 并返回 false
+func checkIfMatch(w http.ResponseWriter, r *http.Request, step, current string) bool {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "*" {
+		return true
+	}
+	if h == "" {
+		writeJSON(w, http.StatusPreconditionRequired, map[string]any{"step": step, "error": "If-Match header with the asset revision is required (use the ETag from the last read, or * to skip the check)", "revision": current})
+		return false
+	}
+	for _, tag := range strings.Split(h, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if unq, err := strconv.Unquote(tag); err == nil {
+			tag = unq
+		}
+		if tag == current {
+			return true
+		}
+	}
+	writeJSON(w, http.StatusConflict, map[string]any{"step": step, "error": fmt.Sprintf("asset was modified concurrently: If-Match %s does not match current revision %q; reload and retry", h, current), "revision": current})
+	return false
+}
+
+// 解析 {kind} 与 ?name=（sink 默认主 sink）；不合法时直接写 400/404
+func (s *Server) assetTarget(w http.ResponseWriter, r *http.Request) (kind, name string, ok bool) {
+	kind = r.PathValue("kind")
+	switch kind {
+	case assetILM, assetTemplate, assetPipeline:
+		return kind, "", true
+	case assetSink:
+		name = r.URL.Query().Get("name")
+		if name == "" {
+			name = s.primarySinkConfig().Name
+		}
+		if _, found := s.findSinkConfig(name); !found {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("sink %q not configured", name)})
+			return "", "", false
+		}
+		return kind, name, true
+	}
+	writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown asset kind %q", kind), "kinds": assetKinds})
+	return "", "", false
+}
+
+func (s *Server) assetVersionParam(w http.ResponseWriter, r *http.Request, key string) (assetVersion, []byte, bool) {
+	n, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": "version must be an integer"})
+		return assetVersion{}, nil, false
+	}
+	v, b, err := s.assets.get(key, n)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("version %d not found", n)})
+		return v, nil, false
+	}
+	if err != nil {
+		writeJSON(w, 500, map[string]string{"error": err.Error()})
+		return v, nil, false
+	}
+	return v, b, true
+}
+
+func (s *Server) handleListAssetVersions(w http.ResponseWriter, r *http.Request) {
+	kind, name, ok := s.assetTarget(w, r)
+	if !ok {
+		return
+	}
+	sel, err := parseLabelSelector(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	versions := s.assets.versions(assetKey(kind, name))
+	if len(sel) > 0 {
+		versions = slices.DeleteFunc(versions, func(v assetVersion) bool { return !sel.matches(v.Labels) })
+	}
+	out := map[string]any{"kind": kind, "versions": versions, "revision": s.assetRevision(kind, name)}
+	setETag(w, s.assetRevision(kind, name))
+	if name != "" {
+		out["name"] = name
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetAssetVersion(w http.ResponseWriter, r *http.Request) {
+	kind, name, ok := s.assetTarget(w, r)
+	if !ok {
+		return
+	}
+	v, b, ok := s.assetVersionParam(w, r, assetKey(kind, name))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"version": v, "document": json.RawMessage(b)})
+}
+
+// 重新下发某个历史版本；成功后该内容作为新版本（rollback_of 指向原版本）记录
+func (s *Server) handleRollbackAsset(w http.ResponseWriter, r *http.Request) {
+	kind, name, ok := s.assetTarget(w, r)
+	if !ok {
+		return
+	}
+	v, b, ok := s.assetVersionParam(w, r, assetKey(kind, name))
+	if !ok {
+		return
+	}
+	s.logger.Printf("step=asset-rollback kind=%s name=%s version=%d hash=%s", kind, name, v.Version, v.Hash[:12])
+	var applied bool
+	switch kind {
+	case assetILM:
+		applied = s.applyILM(w, r, "rollback", b)
+	case assetTemplate:
+		applied = s.applyTemplate(w, r, "rollback", b)
+	case assetPipeline:
+		applied = s.applyPipeline(w, r, "rollback", b)
+	case assetSink:
+		sc, _ := s.findSinkConfig(name)
+		if sc.Type != sinkTypeConnect && sc.Type != sinkTypeS3 && sc.Type != sinkTypeMirrorMaker {
+			writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("sink %q (type %s) has no connector document to roll back", name, sc.Type)})
+			return
+		}
+		p := &connectSink{s: s, typ: sc.Type, name: sc.Name,
+			load: func(context.Context) ([]byte, error) { return b, nil }}
+		res, err := p.Register(optionsContext(r))
+		s.recordSinkRegister(r, p, res, err, v.Version)
+		s.writeSinkRegister(w, p, res, err)
+		return
+	}
+	if applied {
+		s.recordAsset(r, kind, name, "rollback", v.Version, b)
+	}
+}
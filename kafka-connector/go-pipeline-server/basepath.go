@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/************** URL 基础路径（-base-path） **************/
+
+// 挂在共享反向代理的子路径下（如 https://ops.example.com/log-pipeline/）时设置 -base-path=/log-pipeline/（或 BASE_PATH）：
+//   - UI 端口上的静态资源、SPA 回退与 /admin/* 都挂在该路径下，去掉前缀后按原路径路由；不带前缀的请求返回 404，
+//     /log-pipeline 重定向到 /log-pipeline/；代理转发时应保留前缀
+//   - /healthz、/readyz 仍在根路径（探针直连 Pod）；-admin-listen 的独立端口不受影响
+//   - 返回 index.html 时把 <base href> 改写为基础路径（没有 base 标签时插入到 <head> 之后），
+//     前端产物需使用相对路径（CRA 的 homepage: "."、Vite 的 base: "./"）；此时 index.html 不使用预压缩版本
+//   - 响应中以 / 开头的 Location（如 ServeMux 补斜杠的重定向）补上前缀
+
+var basePathRe = regexp.MustCompile(`^/[A-Za-z0-9._~/-]*$`)
+
+// 规范化为 /a/b/ 形式；空为 /
+func normalizeBasePath(p string) (string, error) {
+	if p == "" || p == "/" {
+		return "/", nil
+	}
+	if !basePathRe.MatchString(p) {
+		return "", fmt.Errorf("base path %q must start with / and contain only letters, digits and . _ ~ - /", p)
+	}
+	clean := path.Clean(p)
+	if clean == "/" {
+		return "/", nil
+	}
+	if strings.Contains(clean, "/..") || strings.Contains(clean, "/./") {
+		return "", fmt.Errorf("base path %q must not contain . or .. segments", p)
+	}
+	return clean + "/", nil
+}
+
+// base 为 / 时原样返回 app
+func (s *Server) mountBasePath(base string, app http.Handler) http.Handler {
+	if base == "/" {
+		return app
+	}
+	prefix := strings.TrimSuffix(base, "/")
+	m := http.NewServeMux()
+	m.HandleFunc("GET /healthz", s.handleHealthz)
+	m.HandleFunc("GET /readyz", s.handleReadyz)
+	m.Handle(base, http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.ServeHTTP(&basePathWriter{ResponseWriter: w, prefix: prefix}, r)
+	})))
+	return m
+}
+
+// 给以 / 开头的 Location 补上前缀
+type basePathWriter struct {
+	http.ResponseWriter
+	prefix string
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	if loc := w.Header().Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") && !strings.HasPrefix(loc, w.prefix+"/") {
+		w.Header().Set("Location", w.prefix+loc)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// 供 http.ResponseController 取得底层连接（SSE flush、WebSocket hijack）
+func (w *basePathWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+var (
+	baseTagRe = regexp.MustCompile(`(?i)<base\s[^>]*>`)
+	headTagRe = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+)
+
+// <base href> 改写为 base；没有 base 标签时插入到 <head> 之后，也没有 <head> 时放在最前面
+func rewriteBaseHref(html []byte, base string) []byte {
+	tag := []byte(`<base href="` + base + `">`)
+	if loc := baseTagRe.FindIndex(html); loc != nil {
+		return append(append(append([]byte{}, html[:loc[0]]...), tag...), html[loc[1]:]...)
+	}
+	if loc := headTagRe.FindIndex(html); loc != nil {
+		return append(append(append([]byte{}, html[:loc[1]]...), tag...), html[loc[1]:]...)
+	}
+	return append(tag, html...)
+}
+
+func (h *spaHandler) serveIndex(w http.ResponseWriter, r *http.Request, name string) bool {
+	fi, err := os.Stat(name)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		http.Error(w, "read index: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, name, fi.ModTime(), bytes.NewReader(rewriteBaseHref(b, h.basePath)))
+	return true
+}
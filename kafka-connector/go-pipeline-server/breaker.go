@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/************** 下游熔断 **************/
+
+// 按下游 host 计数连续失败（连接错误、超时、502/503/504），达到 breaker.failures 后熔断：
+// cooldown 内直接返回 "ES circuit open, retry after Xs"，不再让每次点击都等满超时。
+// cooldown 过后放行一个探测请求（half-open），成功则恢复，失败则重新计时。
+
+const defaultBreakerCooldown = 30 * time.Second
+
+type BreakerConfig struct {
+	Failures int    `yaml:"failures"` // 连续失败多少次后熔断；0 或不配置为不启用
+	Cooldown string `yaml:"cooldown"` // 熔断持续时间，默认 30s
+}
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+type hostBreaker struct {
+	kind      string
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool // half-open 时已有探测请求在途
+	lastError string
+	trips     int
+}
+
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostBreaker
+}
+
+func newBreakers(cfg BreakerConfig) *breakers {
+	b := &breakers{threshold: cfg.Failures, cooldown: mustParseDuration("breaker.cooldown", cfg.Cooldown), hosts: map[string]*hostBreaker{}}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultBreakerCooldown
+	}
+	return b
+}
+
+type circuitOpenError struct {
+	Kind       string
+	Host       string
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e *circuitOpenError) Error() string {
+	msg := fmt.Sprintf("%s circuit open, retry after %ds (%s)", downstreamLabel(e.Kind), e.retryAfterSeconds(), e.Host)
+	if e.LastError != "" {
+		msg += ": last error: " + e.LastError
+	}
+	return msg
+}
+
+func (e *circuitOpenError) retryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+func (e *circuitOpenError) fields() map[string]any {
+	return map[string]any{"circuit_open": true, "downstream": e.Kind, "retry_after_seconds": e.retryAfterSeconds()}
+}
+
+func downstreamLabel(kind string) string {
+	switch kind {
+	case "es":
+		return "ES"
+	case "connect":
+		return "Connect"
+	}
+	return kind
+}
+
+// 请求前调用；熔断中返回 *circuitOpenError
+func (b *breakers) allow(kind, host string) error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hb := b.hosts[host]
+	if hb == nil || hb.state == breakerClosed {
+		return nil
+	}
+	wait := b.cooldown - time.Since(hb.openedAt)
+	if hb.state == breakerOpen && wait <= 0 {
+		hb.state = breakerHalfOpen
+	}
+	if hb.state == breakerHalfOpen && !hb.probing {
+		hb.probing = true
+		return nil
+	}
+	return &circuitOpenError{Kind: kind, Host: host, RetryAfter: max(wait, time.Second), LastError: hb.lastError}
+}
+
+// 请求结束后调用；failure 为空表示成功
+func (b *breakers) record(kind, host, failure string, logf func(string, ...any)) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hb := b.hosts[host]
+	if hb == nil {
+		hb = &hostBreaker{kind: kind, state: breakerClosed}
+		b.hosts[host] = hb
+	}
+	hb.probing = false
+	if failure == "" {
+		if hb.state != breakerClosed {
+			logf("step=breaker kind=%s host=%s state=closed", kind, host)
+		}
+		hb.state, hb.failures, hb.lastError = breakerClosed, 0, ""
+		return
+	}
+	hb.failures++
+	hb.lastError = failure
+	if hb.state == breakerHalfOpen || (hb.state == breakerClosed && hb.failures >= b.threshold) {
+		hb.state, hb.openedAt = breakerOpen, time.Now()
+		hb.trips++
+		logf("step=breaker kind=%s host=%s state=open failures=%d cooldown=%s err=%q", kind, host, hb.failures, b.cooldown, failure)
+	}
+}
+
+// 连接错误、超时与网关类状态码计为失败；调用方主动取消不计
+func breakerFailure(req *http.Request, resp *http.Response, err error) string {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return ""
+		}
+		return err.Error()
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return resp.Status
+	}
+	return ""
+}
+
+func (b *breakers) stats() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hosts := map[string]any{}
+	for h, hb := range b.hosts {
+		st := map[string]any{"kind": hb.kind, "state": hb.state, "consecutive_failures": hb.failures, "trips": hb.trips}
+		if hb.state != breakerClosed {
+			st["opened_at"] = hb.openedAt.UTC().Format(time.RFC3339)
+			st["last_error"] = hb.lastError
+			if wait := b.cooldown - time.Since(hb.openedAt); wait > 0 {
+				st["retry_after_seconds"] = int(math.Ceil(wait.Seconds()))
+			}
+		}
+		hosts[h] = st
+	}
+	return map[string]any{"enabled": b.threshold > 0, "failures": b.threshold, "cooldown": b.cooldown.String(), "hosts": hosts}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/************** 批量 connector 操作 **************/
+
+// POST /admin/connect/bulk：维护窗口内对一批 sink 统一 pause / resume / restart / delete，
+// 逐个返回结果，单个失败不影响其他。names 为空且 all=true 时作用于全部已配置的 sink。
+// restart 只适用于 Connect 类 sink（connect / s3），其他类型返回 unsupported；按 restart_guard 限速（restartguard.go）。
+
+const bulkWorkers = 4 // 另受 limits.concurrency.connect 约束
+
+var bulkActions = []string{"pause", "resume", "restart", "delete"}
+
+type bulkRequest struct {
+	Action     string   `json:"action"`
+	Names      []string `json:"names"`
+	All        bool     `json:"all"`
+	OnlyFailed bool     `json:"only_failed"` // restart：只重启 FAILED 的 connector / task
+}
+
+type bulkItem struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	OK    bool   `json:"ok"`
+	Code  int    `json:"code,omitempty"`
+	Body  any    `json:"body,omitempty"`
+	Error string `json:"error,omitempty"`
+	DurMs int64  `json:"dur_ms"`
+}
+
+// Connect REST：POST /connectors/{name}/restart，includeTasks 一并重启 task
+func (c *connectSink) Restart(ctx context.Context, onlyFailed bool) (*sinkResponse, error) {
+	if err := c.checkOwner(ctx, nil); err != nil {
+		return nil, err
+	}
+	url := c.connectorURL("/restart?includeTasks=true&onlyFailed=" + strconv.FormatBool(onlyFailed))
+	c.s.logger.Printf("connect action=restart name=%s url=%s", c.name, url)
+	resp, body, err := c.s.doPOST(ctx, url, nil, "connect")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (s *Server) bulkOne(ctx context.Context, r *http.Request, req bulkRequest, name string) bulkItem {
+	it := bulkItem{Name: name}
+	sc, ok := s.findSinkConfig(name)
+	if !ok {
+		it.Error = fmt.Sprintf("sink %q not configured", name)
+		return it
+	}
+	it.Type = sc.Type
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		it.Error = err.Error()
+		return it
+	}
+	var res *sinkResponse
+	switch req.Action {
+	case "pause":
+		res, err = p.Pause(ctx)
+		s.trackDesired(r, p, desiredPaused, res, err)
+	case "resume":
+		res, err = p.Resume(ctx)
+		s.trackDesired(r, p, desiredRunning, res, err)
+	case "delete":
+		res, err = p.Delete(ctx)
+		s.trackDesired(r, p, desiredAbsent, res, err)
+	case "restart":
+		c, ok := p.(*connectSink)
+		if !ok {
+			err = errSinkUnsupported
+			break
+		}
+		if !s.guardRestart(ctx, c, &it) {
+			return it
+		}
+		res, err = c.Restart(ctx, req.OnlyFailed)
+	}
+	if err != nil {
+		it.Error = err.Error()
+		return it
+	}
+	it.Code = res.Code
+	if len(res.Body) > 0 {
+		it.Body = jsonRaw(res.Body)
+	}
+	it.OK = res.Code < 300
+	if !it.OK {
+		it.Error = res.Status
+	}
+	return it
+}
+
+func (s *Server) handleBulkConnectors(w http.ResponseWriter, r *http.Request) {
+	var req bulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if !slices.Contains(bulkActions, req.Action) {
+		writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown action %q", req.Action), "actions": bulkActions})
+		return
+	}
+	names := req.Names
+	if req.All {
+		if len(names) > 0 {
+			writeJSON(w, 400, map[string]string{"error": "names and all are mutually exclusive"})
+			return
+		}
+		for _, sc := range s.sinkConfigs() {
+			names = append(names, sc.Name)
+		}
+	}
+	if len(names) == 0 {
+		writeJSON(w, 400, map[string]string{"error": "names is required (or set all=true)"})
+		return
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	start := time.Now()
+	ctx := optionsContext(r)
+	results := make([]bulkItem, len(names))
+	var wg sync.WaitGroup
+	queue := make(chan int)
+	for i := 0; i < min(bulkWorkers, len(names)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				t := time.Now()
+				results[idx] = s.bulkOne(ctx, r, req, names[idx])
+				results[idx].DurMs = time.Since(t).Milliseconds()
+			}
+		}()
+	}
+	for i := range names {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	failed := []string{}
+	for _, it := range results {
+		if !it.OK {
+			failed = append(failed, it.Name)
+		}
+	}
+	s.logger.Printf("connect action=bulk-%s operator=%s total=%d failed=%v dur_ms=%d",
+		req.Action, operatorIdentity(r), len(names), failed, time.Since(start).Milliseconds())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"action":    req.Action,
+		"ok":        len(failed) == 0,
+		"total":     len(names),
+		"succeeded": len(names) - len(failed),
+		"failed":    failed,
+		"results":   results,
+	})
+}
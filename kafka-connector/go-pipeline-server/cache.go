@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+/************** 查询类 GET 响应的短 TTL 缓存 **************/
+
+type cachedResponse struct {
+	status      int
+	contentType string
+	body        string
+	expires     time.Time
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: map[string]cachedResponse{}}
+}
+
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return cachedResponse{}, false
+	}
+	return e, true
+}
+
+func (c *responseCache) put(key string, e cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.expires = time.Now().Add(c.ttl)
+	c.entries[key] = e
+}
+
+func (c *responseCache) bust() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// 缓存 name 对应检查的 2xx 响应，按路径参数与查询参数区分；Cache-Control: no-cache 可绕过
+func (s *Server) cacheGET(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cache.ttl <= 0 || r.Header.Get("Cache-Control") == "no-cache" {
+			h(w, r)
+			return
+		}
+		// 查询参数规范化（按 key 排序）后计入 key，不同参数的响应不会串用
+		key := name + "|" + r.PathValue("name") + "|" + r.URL.Query().Encode()
+		if e, ok := s.cache.get(key); ok {
+			w.Header().Set("Content-Type", e.contentType)
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(e.status)
+			_, _ = w.Write([]byte(e.body))
+			return
+		}
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		h(cw, r)
+		if cw.status >= 200 && cw.status < 300 {
+			s.cache.put(key, cachedResponse{status: cw.status, contentType: cw.hdr.Get("Content-Type"), body: cw.body})
+		}
+		for k, v := range cw.hdr {
+			w.Header()[k] = v
+		}
+		w.Header().Set("X-Cache", "MISS")
+		w.WriteHeader(cw.status)
+		_, _ = w.Write([]byte(cw.body))
+	}
+}
+
+// 任何写操作完成后清空缓存，保证随后的验证能看到新状态
+func (s *Server) bustCacheOnWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			s.cache.bust()
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/************** 磁盘水位与分片余量检查 **************/
+
+// 读取 _cat/allocation 与集群设置：节点磁盘接近 high / flood_stage 水位时提前告警（到 flood_stage 后索引被置为只读，
+// 写入全部失败）；按 cluster.max_shards_per_node 与写索引的分片数估算下一次 rollover 后的分片余量，
+// 以及 index.routing.allocation.total_shards_per_node 是否导致新索引无法完全分配。
+// 结果见 GET /admin/verify/capacity（也包含在 /admin/verify/all 中），告警规则类型 disk_watermark / shard_headroom。
+
+const (
+	diskOK         = "ok"
+	diskLow        = "low"
+	diskHigh       = "high"
+	diskFloodStage = "flood_stage"
+
+	defaultMaxShardsPerNode = 1000
+)
+
+// 水位可以是使用率（"85%" / "0.85"）或剩余空间（"50gb"）
+type watermark struct {
+	Raw       string  `json:"raw"`
+	Percent   float64 `json:"percent,omitempty"`
+	FreeBytes int64   `json:"free_bytes,omitempty"`
+}
+
+func parseWatermark(v string) (watermark, error) {
+	w := watermark{Raw: v}
+	v = strings.TrimSpace(strings.ToLower(v))
+	if p, ok := strings.CutSuffix(v, "%"); ok {
+		f, err := strconv.ParseFloat(p, 64)
+		w.Percent = f
+		return w, err
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		w.Percent = f * 100
+		return w, nil
+	}
+	b, err := parseESBytes(v)
+	w.FreeBytes = b
+	return w, err
+}
+
+// ES 的字节单位（1024 进制）：b / kb / mb / gb / tb / pb
+func parseESBytes(v string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   float64
+	}{{"pb", 1 << 50}, {"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}}
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(v, u.suffix); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid byte size %q", v)
+			}
+			return int64(f * u.mult), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid byte size %q", v)
+}
+
+func (w watermark) exceeded(percent float64, avail int64) bool {
+	if w.FreeBytes > 0 {
+		return avail <= w.FreeBytes
+	}
+	return w.Percent > 0 && percent >= w.Percent
+}
+
+type nodeDisk struct {
+	Node    string  `json:"node"`
+	Shards  int     `json:"shards"`
+	Percent float64 `json:"disk_percent"`
+	Avail   int64   `json:"disk_avail_bytes"`
+	Total   int64   `json:"disk_total_bytes"`
+	Level   string  `json:"level"` // ok | low | high | flood_stage
+	ToFlood float64 `json:"percent_to_flood_stage,omitempty"`
+}
+
+type shardHeadroom struct {
+	MaxPerNode         int `json:"max_shards_per_node"`
+	DataNodes          int `json:"data_nodes"`
+	Capacity           int `json:"capacity"`
+	Current            int `json:"current"`
+	PerRollover        int `json:"per_rollover"` // 写索引的 primary × (1 + replicas)
+	Headroom           int `json:"headroom"`     // 下一次 rollover 之后还剩的分片数
+	TotalShardsPerNode int `json:"total_shards_per_node,omitempty"`
+}
+
+type capacityReport struct {
+	OK         bool                 `json:"ok"`
+	Warnings   []string             `json:"warnings"`
+	DiskCheck  bool                 `json:"disk_threshold_enabled"`
+	Watermarks map[string]watermark `json:"watermarks"`
+	Nodes      []nodeDisk           `json:"nodes"`
+	Shards     shardHeadroom        `json:"shards"`
+	WriteIndex string               `json:"write_index,omitempty"`
+}
+
+// 集群设置按 transient > persistent > defaults 取值
+func (s *Server) clusterSettings(ctx context.Context) (map[string]string, error) {
+	u := s.cfg.ES.Host + "/_cluster/settings?include_defaults=true&flat_settings=true"
+	resp, body, err := s.doGET(ctx, u, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster settings returned %s", resp.Status)
+	}
+	var doc map[string]map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode cluster settings: %w", err)
+	}
+	out := map[string]string{}
+	for _, scope := range []string{"defaults", "persistent", "transient"} {
+		for k, v := range doc[scope] {
+			if str, ok := v.(string); ok {
+				out[k] = str
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) capacityReport(ctx context.Context) (*capacityReport, error) {
+	settings, err := s.clusterSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rep := &capacityReport{Warnings: []string{}, Watermarks: map[string]watermark{}, DiskCheck: settings["cluster.routing.allocation.disk.threshold_enabled"] != "false"}
+	for _, lvl := range []string{diskLow, diskHigh, diskFloodStage} {
+		raw := settings["cluster.routing.allocation.disk.watermark."+lvl]
+		if raw == "" {
+			continue
+		}
+		w, err := parseWatermark(raw)
+		if err != nil {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("cannot parse %s watermark %q: %v", lvl, raw, err))
+			continue
+		}
+		rep.Watermarks[lvl] = w
+	}
+
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_cat/allocation?format=json&bytes=b&h=node,shards,disk.percent,disk.avail,disk.total", "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cat allocation returned %s", resp.Status)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("decode cat allocation: %w", err)
+	}
+	num := func(v any) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+	for _, row := range rows {
+		shards := atoiLoose(num(row["shards"]))
+		rep.Shards.Current += shards
+		node := num(row["node"])
+		if node == "UNASSIGNED" {
+			continue
+		}
+		pct, _ := strconv.ParseFloat(num(row["disk.percent"]), 64)
+		avail, _ := strconv.ParseInt(num(row["disk.avail"]), 10, 64)
+		total, _ := strconv.ParseInt(num(row["disk.total"]), 10, 64)
+		nd := nodeDisk{Node: node, Shards: shards, Percent: pct, Avail: avail, Total: total, Level: diskOK}
+		for _, lvl := range []string{diskLow, diskHigh, diskFloodStage} {
+			if w, ok := rep.Watermarks[lvl]; ok && w.exceeded(pct, avail) {
+				nd.Level = lvl
+			}
+		}
+		if w, ok := rep.Watermarks[diskFloodStage]; ok && w.Percent > 0 {
+			nd.ToFlood = max(w.Percent-pct, 0)
+		}
+		if rep.DiskCheck && (nd.Level == diskHigh || nd.Level == diskFloodStage) {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("node %s disk at %.0f%% is over the %s watermark (%s)", node, pct, nd.Level, rep.Watermarks[nd.Level].Raw))
+		}
+		rep.Nodes = append(rep.Nodes, nd)
+		rep.Shards.DataNodes++
+	}
+
+	h := &rep.Shards
+	h.MaxPerNode = atoiLoose(settings["cluster.max_shards_per_node"])
+	if h.MaxPerNode <= 0 {
+		h.MaxPerNode = defaultMaxShardsPerNode
+	}
+	h.Capacity = h.MaxPerNode * h.DataNodes
+
+	if indices, err := s.dataStreamIndices(ctx); err == nil && len(indices) > 0 {
+		rep.WriteIndex = indices[len(indices)-1]
+		idx, err := s.indexSettings(ctx, rep.WriteIndex)
+		if err != nil {
+			rep.Warnings = append(rep.Warnings, err.Error())
+		} else {
+			pri, rpl := atoiLoose(idx["index.number_of_shards"]), atoiLoose(idx["index.number_of_replicas"])
+			h.PerRollover = pri * (1 + rpl)
+			h.TotalShardsPerNode = atoiLoose(idx["index.routing.allocation.total_shards_per_node"])
+			if h.TotalShardsPerNode > 0 && h.TotalShardsPerNode*h.DataNodes < h.PerRollover {
+				rep.Warnings = append(rep.Warnings, fmt.Sprintf("next backing index needs %d shards but total_shards_per_node=%d over %d data node(s) allows only %d",
+					h.PerRollover, h.TotalShardsPerNode, h.DataNodes, h.TotalShardsPerNode*h.DataNodes))
+			}
+			if rpl+1 > h.DataNodes {
+				rep.Warnings = append(rep.Warnings, fmt.Sprintf("number_of_replicas=%d needs %d data nodes, cluster has %d; replicas stay unassigned", rpl, rpl+1, h.DataNodes))
+			}
+		}
+	} else if err != nil {
+		rep.Warnings = append(rep.Warnings, err.Error())
+	}
+	h.Headroom = h.Capacity - h.Current - h.PerRollover
+	if h.Headroom < 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("next rollover needs %d shards but only %d of %d (max_shards_per_node=%d × %d data node(s)) are free",
+			h.PerRollover, h.Capacity-h.Current, h.Capacity, h.MaxPerNode, h.DataNodes))
+	}
+	rep.OK = len(rep.Warnings) == 0
+	return rep, nil
+}
+
+func (s *Server) indexSettings(ctx context.Context, index string) (map[string]string, error) {
+	resp, body, err := s.doGET(ctx, fmt.Sprintf("%s/%s/_settings?flat_settings=true", s.cfg.ES.Host, index), "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s settings returned %s", index, resp.Status)
+	}
+	var doc map[string]struct {
+		Settings map[string]any `json:"settings"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode %s settings: %w", index, err)
+	}
+	out := map[string]string{}
+	for k, v := range doc[index].Settings {
+		if str, ok := v.(string); ok {
+			out[k] = str
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) handleVerifyCapacity(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.capacityReport(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("verify-capacity", err))
+		return
+	}
+	code := http.StatusOK
+	if !rep.OK {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, rep)
+}
+
+// 告警规则取值：disk_watermark 为超过 high 水位的节点数，shard_headroom 为下一次 rollover 后的分片余量
+func (s *Server) evalCapacity(ctx context.Context, typ string) (float64, error) {
+	rep, err := s.capacityReport(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if typ == ruleShardHeadroom {
+		return float64(rep.Shards.Headroom), nil
+	}
+	n := 0
+	for _, nd := range rep.Nodes {
+		if nd.Level == diskHigh || nd.Level == diskFloodStage {
+			n++
+		}
+	}
+	return float64(n), nil
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/************** 跨集群复制（CCR，容灾） **************/
+
+// ccr.follower 为容灾集群。PUT /admin/es/ccr 在 follower 上：
+//  1. seeds 非空时写入 cluster.remote.<remote_cluster>.seeds，指向本集群的 transport 地址
+//  2. 创建 auto-follow pattern，匹配本数据流的 backing index（含 shrink- / reindex- 前缀），
+//     follow index 与 leader 同名，follower 上自动生成同名的 follower data stream
+//  3. auto-follow 只对之后新建的索引生效，已有的 backing index 逐个 _ccr/follow（?existing=false 跳过）
+// GET /admin/es/ccr/status 汇总 remote 连接、auto-follow 状态与错误、各 follower index 落后的操作数；
+// 告警规则类型 ccr_lag。需要两个集群都有 CCR 许可（platinum / enterprise），仅支持 ES。
+
+const ccrDownstream = "es-follower"
+
+type CCRConfig struct {
+	Follower struct {
+		Host     string `yaml:"host"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"follower"`
+	RemoteCluster     string   `yaml:"remote_cluster"`      // follower 上指向本集群的 remote cluster 别名，默认 leader
+	Seeds             []string `yaml:"seeds"`               // 本集群 transport 地址（host:9300）；为空则认为 remote cluster 已手工配置
+	AutoFollowPattern string   `yaml:"auto_follow_pattern"` // 默认 <data_stream>-ccr
+}
+
+func (c CCRConfig) enabled() bool { return c.Follower.Host != "" }
+
+func (c CCRConfig) remote() string {
+	if c.RemoteCluster != "" {
+		return c.RemoteCluster
+	}
+	return "leader"
+}
+
+func (s *Server) ccrPatternName() string {
+	if p := s.cfg.CCR.AutoFollowPattern; p != "" {
+		return p
+	}
+	return s.cfg.ES.Names.DataStream + "-ccr"
+}
+
+func (s *Server) ccrLeaderPatterns() []string {
+	return s.backingIndexPatterns()
+}
+
+// 本数据流的 backing index，包括 shrink / reindex 产生的索引
+func (s *Server) backingIndexPatterns() []string {
+	ds := s.cfg.ES.Names.DataStream
+	return []string{".ds-" + ds + "-*", "shrink-*.ds-" + ds + "-*", reindexPrefix + ".ds-" + ds + "-*"}
+}
+
+func (s *Server) withFollowerAuth(req *http.Request) {
+	if f := s.cfg.CCR.Follower; f.Username != "" {
+		req.SetBasicAuth(f.Username, f.Password)
+	}
+}
+
+func (s *Server) followerDo(ctx context.Context, method, path string, body []byte) (*http.Response, []byte, error) {
+	u := strings.TrimRight(s.cfg.CCR.Follower.Host, "/") + path
+	return s.doRequest(ctx, method, u, body, ccrDownstream, "application/json", s.withFollowerAuth)
+}
+
+func (s *Server) ccrUnavailable(w http.ResponseWriter) bool {
+	switch {
+	case !s.cfg.CCR.enabled():
+		writeJSON(w, 400, map[string]string{"error": "ccr.follower.host is not configured"})
+		return true
+	case s.isOpenSearch():
+		writeJSON(w, 400, map[string]string{"error": "cross-cluster replication setup is only supported on elasticsearch"})
+		return true
+	}
+	return false
+}
+
+// follower 上已存在的索引（GET /_cat/indices）
+func (s *Server) followerIndices(ctx context.Context) (map[string]bool, error) {
+	// *.ds-<ds>-* 同时匹配 shrink- / reindex- 前缀
+	resp, body, err := s.followerDo(ctx, http.MethodGet, "/_cat/indices/*.ds-"+url.PathEscape(s.cfg.ES.Names.DataStream)+"-*?format=json&h=index&expand_wildcards=all", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("follower cat indices returned %s", resp.Status)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("decode follower indices: %w", err)
+	}
+	out := map[string]bool{}
+	for _, row := range rows {
+		out[row["index"]] = true
+	}
+	return out, nil
+}
+
+type ccrFollowResult struct {
+	Index  string `json:"index"`
+	Status string `json:"status"` // followed | exists | failed
+	Error  string `json:"error,omitempty"`
+}
+
+// PUT /admin/es/ccr[?existing=false]
+func (s *Server) handleSetupCCR(w http.ResponseWriter, r *http.Request) {
+	if s.ccrUnavailable(w) {
+		return
+	}
+	ctx := r.Context()
+	cfg := s.cfg.CCR
+	out := map[string]any{"step": "ccr", "remote_cluster": cfg.remote(), "auto_follow_pattern": s.ccrPatternName()}
+	fail := func(step string, code int, body any) {
+		out[step] = body
+		writeJSON(w, code, out)
+	}
+
+	// 1) remote cluster
+	if len(cfg.Seeds) > 0 {
+		b, _ := json.Marshal(map[string]any{"persistent": map[string]any{
+			"cluster.remote." + cfg.remote() + ".mode":  "sniff",
+			"cluster.remote." + cfg.remote() + ".seeds": cfg.Seeds,
+		}})
+		s.logger.Printf("step=ccr remote=%s seeds=%s operator=%s", cfg.remote(), strings.Join(cfg.Seeds, ","), operatorIdentity(r))
+		resp, body, err := s.followerDo(ctx, http.MethodPut, "/_cluster/settings", b)
+		if err != nil {
+			fail("remote", http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		if resp.StatusCode >= 300 {
+			fail("remote", resp.StatusCode, jsonRaw(body))
+			return
+		}
+		out["remote"] = jsonRaw(body)
+	}
+
+	// 2) auto-follow pattern
+	b, _ := json.Marshal(map[string]any{
+		"remote_cluster":        cfg.remote(),
+		"leader_index_patterns": s.ccrLeaderPatterns(),
+		"follow_index_pattern":  "{{leader_index}}",
+	})
+	s.logger.Printf("step=ccr auto_follow=%s patterns=%s operator=%s", s.ccrPatternName(), strings.Join(s.ccrLeaderPatterns(), ","), operatorIdentity(r))
+	resp, body, err := s.followerDo(ctx, http.MethodPut, "/_ccr/auto_follow/"+url.PathEscape(s.ccrPatternName()), b)
+	if err != nil {
+		fail("auto_follow", http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	if resp.StatusCode >= 300 {
+		fail("auto_follow", resp.StatusCode, jsonRaw(body))
+		return
+	}
+	out["auto_follow"] = jsonRaw(body)
+
+	// 3) 已有的 backing index
+	if r.URL.Query().Get("existing") == "false" {
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	leader, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		fail("follow", http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	existing, err := s.followerIndices(ctx)
+	if err != nil {
+		fail("follow", http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	results := make([]ccrFollowResult, 0, len(leader))
+	code := http.StatusOK
+	for _, idx := range leader {
+		if existing[idx] {
+			results = append(results, ccrFollowResult{Index: idx, Status: "exists"})
+			continue
+		}
+		b, _ := json.Marshal(map[string]any{"remote_cluster": cfg.remote(), "leader_index": idx, "data_stream_name": s.cfg.ES.Names.DataStream})
+		resp, body, err := s.followerDo(ctx, http.MethodPut, "/"+url.PathEscape(idx)+"/_ccr/follow?wait_for_active_shards=1", b)
+		res := ccrFollowResult{Index: idx, Status: "followed"}
+		switch {
+		case err != nil:
+			res.Status, res.Error = "failed", err.Error()
+		case resp.StatusCode >= 300:
+			res.Status, res.Error = "failed", fmt.Sprintf("%s %s", resp.Status, firstLine(string(body)))
+		}
+		if res.Status == "failed" {
+			code = http.StatusMultiStatus
+		}
+		results = append(results, res)
+	}
+	out["follow"] = results
+	writeJSON(w, code, out)
+}
+
+type ccrIndexStatus struct {
+	Index          string `json:"index"`
+	Shards         int    `json:"shards"`
+	OpsBehind      int64  `json:"operations_behind"`
+	SinceLastRead  int64  `json:"time_since_last_read_ms"`
+	FatalException string `json:"fatal_exception,omitempty"`
+}
+
+type ccrStatus struct {
+	OK                bool             `json:"ok"`
+	Problems          []string         `json:"problems"`
+	RemoteCluster     string           `json:"remote_cluster"`
+	Connected         bool             `json:"connected"`
+	AutoFollowPattern string           `json:"auto_follow_pattern"`
+	AutoFollowActive  bool             `json:"auto_follow_active"`
+	AutoFollowErrors  []string         `json:"auto_follow_errors,omitempty"`
+	Followers         []ccrIndexStatus `json:"followers"`
+	Missing           []string         `json:"missing"` // leader 上有、follower 上没有的 backing index
+	MaxOpsBehind      int64            `json:"max_operations_behind"`
+}
+
+func (s *Server) ccrStatus(ctx context.Context) (*ccrStatus, error) {
+	st := &ccrStatus{Problems: []string{}, RemoteCluster: s.cfg.CCR.remote(), AutoFollowPattern: s.ccrPatternName(), Followers: []ccrIndexStatus{}, Missing: []string{}}
+
+	resp, body, err := s.followerDo(ctx, http.MethodGet, "/_remote/info", nil)
+	if err != nil {
+		return nil, err
+	}
+	var remotes map[string]struct {
+		Connected bool `json:"connected"`
+	}
+	if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &remotes) == nil {
+		st.Connected = remotes[st.RemoteCluster].Connected
+	}
+	if !st.Connected {
+		st.Problems = append(st.Problems, fmt.Sprintf("remote cluster %q is not connected on the follower", st.RemoteCluster))
+	}
+
+	resp, body, err = s.followerDo(ctx, http.MethodGet, "/_ccr/auto_follow/"+url.PathEscape(st.AutoFollowPattern), nil)
+	if err != nil {
+		return nil, err
+	}
+	var patterns struct {
+		Patterns []struct {
+			Pattern struct {
+				Active *bool `json:"active"`
+			} `json:"pattern"`
+		} `json:"patterns"`
+	}
+	if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &patterns) == nil && len(patterns.Patterns) > 0 {
+		// active 字段 7.11 起才有，缺省视为启用
+		st.AutoFollowActive = patterns.Patterns[0].Pattern.Active == nil || *patterns.Patterns[0].Pattern.Active
+	}
+	if !st.AutoFollowActive {
+		st.Problems = append(st.Problems, fmt.Sprintf("auto-follow pattern %s is missing or paused", st.AutoFollowPattern))
+	}
+
+	resp, body, err = s.followerDo(ctx, http.MethodGet, "/_ccr/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("follower ccr stats returned %s", resp.Status)
+	}
+	var stats struct {
+		AutoFollowStats struct {
+			RecentAutoFollowErrors []struct {
+				LeaderIndex         string `json:"leader_index"`
+				AutoFollowException struct {
+					Reason string `json:"reason"`
+				} `json:"auto_follow_exception"`
+			} `json:"recent_auto_follow_errors"`
+		} `json:"auto_follow_stats"`
+		FollowStats struct {
+			Indices []struct {
+				Index  string `json:"index"`
+				Shards []struct {
+					LeaderGlobalCheckpoint   int64 `json:"leader_global_checkpoint"`
+					FollowerGlobalCheckpoint int64 `json:"follower_global_checkpoint"`
+					TimeSinceLastReadMillis  int64 `json:"time_since_last_read_millis"`
+					FatalException           *struct {
+						Reason string `json:"reason"`
+					} `json:"fatal_exception"`
+				} `json:"shards"`
+			} `json:"indices"`
+		} `json:"follow_stats"`
+	}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return nil, fmt.Errorf("decode ccr stats: %w", err)
+	}
+	for _, e := range stats.AutoFollowStats.RecentAutoFollowErrors {
+		st.AutoFollowErrors = append(st.AutoFollowErrors, e.LeaderIndex+": "+e.AutoFollowException.Reason)
+	}
+	followed := map[string]bool{}
+	for _, idx := range stats.FollowStats.Indices {
+		fs := ccrIndexStatus{Index: idx.Index, Shards: len(idx.Shards)}
+		for _, sh := range idx.Shards {
+			fs.OpsBehind += max(sh.LeaderGlobalCheckpoint-sh.FollowerGlobalCheckpoint, 0)
+			fs.SinceLastRead = max(fs.SinceLastRead, sh.TimeSinceLastReadMillis)
+			if sh.FatalException != nil && fs.FatalException == "" {
+				fs.FatalException = sh.FatalException.Reason
+			}
+		}
+		if fs.FatalException != "" {
+			st.Problems = append(st.Problems, fmt.Sprintf("follower %s stopped: %s", fs.Index, fs.FatalException))
+		}
+		st.MaxOpsBehind = max(st.MaxOpsBehind, fs.OpsBehind)
+		followed[idx.Index] = true
+		st.Followers = append(st.Followers, fs)
+	}
+	sort.Slice(st.Followers, func(i, j int) bool { return st.Followers[i].Index < st.Followers[j].Index })
+
+	leader, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range leader {
+		if !followed[idx] {
+			st.Missing = append(st.Missing, idx)
+		}
+	}
+	if len(st.Missing) > 0 {
+		st.Problems = append(st.Problems, fmt.Sprintf("%d backing index(es) not followed: %s", len(st.Missing), strings.Join(st.Missing, ", ")))
+	}
+	// 写索引缺失时 DR 集群收不到新数据
+	if len(leader) > 0 && slices.Contains(st.Missing, leader[len(leader)-1]) {
+		st.Problems = append(st.Problems, "the write index is not replicated")
+	}
+	st.OK = len(st.Problems) == 0
+	return st, nil
+}
+
+func (s *Server) handleCCRStatus(w http.ResponseWriter, r *http.Request) {
+	if s.ccrUnavailable(w) {
+		return
+	}
+	st, err := s.ccrStatus(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("ccr-status", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// 告警规则取值：各 follower index 落后 leader 的最大操作数
+func (s *Server) evalCCRLag(ctx context.Context) (float64, error) {
+	if !s.cfg.CCR.enabled() {
+		return 0, fmt.Errorf("ccr.follower.host is not configured")
+	}
+	st, err := s.ccrStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return float64(st.MaxOpsBehind), nil
+}
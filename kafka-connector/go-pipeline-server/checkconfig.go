@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/************** 配置检查：check-config 子命令 / -strict **************/
+
+// go-pipeline-server check-config [-config ...] [-set k=v] [-json]：不启动服务，只检查配置
+//   - yaml 按 Config 的 JSON Schema（与 GET /admin/config/schema 同源）校验：未知 key、类型、枚举
+//   - 与启动相同的解析与校验（含 LOGPIPE_ 环境变量与 -set 覆盖）
+//   - 引用的文件存在且可解析（.json / .yaml）；es.files.* / connect.files.sink 缺失只是警告（回退内嵌默认）
+//   - URL 形如 http(s)://host[:port]
+//   - 资产文件之间及与配置的名字一致（见 assetlint.go）
+// 有 error 退出码 1，只有 warning 为 0。
+// 服务启动加 -strict 时执行同样的检查，有 error 拒绝启动；KV 配置源热加载的新配置同样按此检查，不通过即拒绝。
+
+const (
+	issueError   = "error"
+	issueWarning = "warning"
+)
+
+type configIssue struct {
+	Level   string `json:"level"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// main 中 flag.Parse 后赋值
+var strictConfig bool
+
+// 运行时写出的文件（状态文件、生成的 promtail 配置），不要求事先存在
+var configOutputFileKeys = map[string]bool{"state_file": true, "promtail_config_file": true}
+
+// 内嵌默认文档兜底的文件项（defaults.go）
+var configDefaultedFiles = map[string]bool{"es.files.ilm": true, "es.files.template": true, "es.files.pipeline": true, "connect.files.sink": true}
+
+// 作为 URL 检查的非 url 命名字段
+var configURLPaths = map[string]bool{"es.host": true, "connect.host": true, "ccr.follower.host": true}
+
+func checkConfig(raw []byte, environ, sets []string) []configIssue {
+	issues := checkConfigSchema(raw)
+	cfg, err := parseConfigEnv(raw, environ, sets)
+	if err != nil {
+		return append(issues, configIssue{Level: issueError, Path: "(config)", Message: err.Error()})
+	}
+	checkConfigRefs(reflect.ValueOf(cfg), "", "", &issues)
+	// 资产文件之间的名字交叉检查（assetlint.go）；文件缺失已在上面报告
+	lint := (&Server{cfg: cfg, logger: log.New(io.Discard, "", 0)}).lintAssets(context.Background())
+	for _, is := range lint {
+		if is.Path != assetILM && is.Path != assetTemplate && is.Path != assetPipeline && is.Path != assetSink {
+			issues = append(issues, is)
+		}
+	}
+	return issues
+}
+
+// 原始 yaml 按 Config 的 JSON Schema 校验；不用 configSchema() 的缓存，redact 规则不影响校验
+func checkConfigSchema(raw []byte) []configIssue {
+	doc := (&Server{redact: newRedactor(nil)}).typeSchema(reflect.TypeOf(Config{}), "")
+	b, _ := json.Marshal(doc)
+	var sc jsonSchema
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return []configIssue{{Level: issueError, Path: "(schema)", Message: err.Error()}}
+	}
+	if err := sc.compile(); err != nil {
+		return []configIssue{{Level: issueError, Path: "(schema)", Message: err.Error()}}
+	}
+	var y any
+	if err := yaml.Unmarshal(raw, &y); err != nil {
+		return []configIssue{{Level: issueError, Path: "(yaml)", Message: err.Error()}}
+	}
+	if y == nil {
+		return nil
+	}
+	// 经 JSON 往返统一数字 / map 类型；yaml 中留空（null）的 key 等同于零值，不参与校验
+	jb, err := json.Marshal(y)
+	if err != nil {
+		return []configIssue{{Level: issueError, Path: "(yaml)", Message: "keys must be strings: " + err.Error()}}
+	}
+	var v any
+	_ = json.Unmarshal(jb, &v)
+	var found []schemaIssue
+	sc.validate(&sc, dropNulls(v), "", &found)
+	out := make([]configIssue, 0, len(found))
+	for _, is := range found {
+		out = append(out, configIssue{Level: issueError, Path: is.Path, Message: is.Message})
+	}
+	return out
+}
+
+func dropNulls(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			if e == nil {
+				delete(x, k)
+				continue
+			}
+			x[k] = dropNulls(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = dropNulls(e)
+		}
+	}
+	return v
+}
+
+// 遍历生效配置，检查文件与 URL；path 带下标（sinks[1].file），key 为字段的 yaml 名
+func checkConfigRefs(v reflect.Value, path, key string, issues *[]configIssue) {
+	add := func(level, format string, args ...any) {
+		*issues = append(*issues, configIssue{Level: level, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			checkConfigRefs(v.Elem(), path, key, issues)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			checkConfigRefs(v.Field(i), joinSchemaPath(path, name), name, issues)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			checkConfigRefs(v.Index(i), fmt.Sprintf("%s[%d]", path, i), key, issues)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			checkConfigRefs(iter.Value(), joinSchemaPath(path, fmt.Sprint(iter.Key().Interface())), key, issues)
+		}
+	case reflect.String:
+		val := strings.TrimSpace(v.String())
+		if val == "" {
+			return
+		}
+		switch {
+		case isConfigURLField(path, key):
+			if err := checkConfigURL(val); err != nil {
+				add(issueError, "%v", err)
+			}
+		case isConfigFileField(path, key):
+			level := issueError
+			if configDefaultedFiles[path] {
+				level = issueWarning
+			}
+			if err := checkConfigFile(val); err != nil {
+				if level == issueWarning && errors.Is(err, fs.ErrNotExist) {
+					add(level, "%v (the embedded default will be used)", err)
+					return
+				}
+				add(issueError, "%v", err)
+			}
+		}
+	}
+}
+
+func isConfigURLField(path, key string) bool {
+	return key == "url" || key == "store_url" || key == "es_hosts" || configURLPaths[path]
+}
+
+func isConfigFileField(path, key string) bool {
+	if configOutputFileKeys[key] {
+		return false
+	}
+	return key == "file" || key == "ddl_files" || strings.HasSuffix(key, "_file") ||
+		strings.HasPrefix(path, "es.files.") || strings.HasPrefix(path, "connect.files.")
+}
+
+func checkConfigURL(raw string) error {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	case u.Host == "":
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// 文件存在；.json / .yaml / .yml 还要能解析
+func checkConfigFile(file string) error {
+	b, err := readJSONFile(file)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+	case ".yaml", ".yml":
+		var v any
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func configIssueErrors(issues []configIssue) []configIssue {
+	var out []configIssue
+	for _, is := range issues {
+		if is.Level == issueError {
+			out = append(out, is)
+		}
+	}
+	return out
+}
+
+// -strict：有 error 时返回汇总错误
+func strictConfigError(raw []byte) error {
+	errs := configIssueErrors(checkConfig(raw, os.Environ(), configSetOverrides))
+	if len(errs) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(errs))
+	for _, is := range errs {
+		lines = append(lines, is.Path+": "+is.Message)
+	}
+	return fmt.Errorf("strict config check failed (%d error(s)): %s", len(errs), strings.Join(lines, "; "))
+}
+
+// check-config 子命令入口，返回进程退出码
+func runCheckConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	spec := fs.String("config", "config.yaml", "Config source: YAML file path, etcd://host:2379/key or consul://host:8500/key")
+	asJSON := fs.Bool("json", false, "Print issues as JSON")
+	fs.Var(&configSetOverrides, "set", "Override a config key, e.g. -set es.host=https://es:9200 (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: go-pipeline-server check-config [flags]\n\nValidates the config (unknown keys, types, referenced files, URLs) without starting the server.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	withEnv(spec, "CONFIG")
+	src, err := newConfigSource(*spec)
+	if err != nil {
+		fmt.Fprintf(stderr, "check-config: %v\n", err)
+		return 1
+	}
+	cur, err := src.load(context.Background())
+	if err != nil {
+		fmt.Fprintf(stderr, "check-config: load config from %s: %v\n", src, err)
+		return 1
+	}
+	issues := checkConfig(cur.Raw, os.Environ(), configSetOverrides)
+	errs := configIssueErrors(issues)
+	if *asJSON {
+		if issues == nil {
+			issues = []configIssue{}
+		}
+		b, _ := json.MarshalIndent(map[string]any{"source": src.String(), "ok": len(errs) == 0, "issues": issues}, "", "  ")
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		for _, is := range issues {
+			fmt.Fprintf(stdout, "%-7s %s: %s\n", is.Level, is.Path, is.Message)
+		}
+		if len(errs) == 0 {
+			fmt.Fprintf(stdout, "%s: OK (%d warning(s))\n", src, len(issues))
+		} else {
+			fmt.Fprintf(stdout, "%s: %d error(s), %d warning(s)\n", src, len(errs), len(issues)-len(errs))
+		}
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
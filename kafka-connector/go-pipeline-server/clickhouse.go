@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+/************** ClickHouse sink（Kafka 引擎表 + 物化视图） **************/
+
+type ClickHouseConfig struct {
+	URL        string   `yaml:"url"` // HTTP 接口，e.g. http://clickhouse:8123
+	Username   string   `yaml:"username"`
+	Password   string   `yaml:"password"`
+	Database   string   `yaml:"database"`
+	DDLFiles   []string `yaml:"ddl_files"`   // 按顺序执行，如 clickhouse/init/*.sql
+	KafkaTable string   `yaml:"kafka_table"` // Kafka 引擎源表，暂停/恢复即 DETACH/ATTACH
+	View       string   `yaml:"view"`        // 物化视图，删除时一并 DROP
+}
+
+type clickhouseSink struct {
+	s  *Server
+	sc SinkConfig
+}
+
+func (c *clickhouseSink) Type() string { return sinkTypeClickHouse }
+func (c *clickhouseSink) Name() string { return c.sc.Name }
+
+func (c *clickhouseSink) auth(req *http.Request) {
+	if c.sc.ClickHouse.Username != "" {
+		req.SetBasicAuth(c.sc.ClickHouse.Username, c.sc.ClickHouse.Password)
+	}
+}
+
+func (c *clickhouseSink) exec(ctx context.Context, query string) (*http.Response, []byte, error) {
+	u := strings.TrimRight(c.sc.ClickHouse.URL, "/") + "/"
+	if db := c.sc.ClickHouse.Database; db != "" {
+		u += "?database=" + url.QueryEscape(db)
+	}
+	return c.s.doRequest(ctx, http.MethodPost, u, []byte(query), "clickhouse", "text/plain", c.auth)
+}
+
+// 去掉 -- 注释后按 ; 切分语句
+func splitSQL(src string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	var out []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+func (c *clickhouseSink) Register(ctx context.Context) (*sinkResponse, error) {
+	if c.sc.ClickHouse.URL == "" || len(c.sc.ClickHouse.DDLFiles) == 0 {
+		return nil, &sinkInputError{fmt.Errorf("sink %s: clickhouse.url and clickhouse.ddl_files are required", c.sc.Name)}
+	}
+	var stmts []string
+	for _, f := range c.sc.ClickHouse.DDLFiles {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			c.s.logger.Printf("step=sink type=clickhouse read_file_err file=%s err=%v", f, err)
+			return nil, &sinkInputError{err}
+		}
+		stmts = append(stmts, splitSQL(string(b))...)
+	}
+	type result struct {
+		Statement string `json:"statement"`
+		Status    int    `json:"status"`
+		Body      string `json:"body,omitempty"`
+	}
+	results := []result{}
+	code := http.StatusOK
+	for _, q := range stmts {
+		c.s.logger.Printf("step=sink type=clickhouse exec size=%d", len(q))
+		resp, body, err := c.exec(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result{Statement: q, Status: resp.StatusCode, Body: strings.TrimSpace(string(body))})
+		if resp.StatusCode >= 400 {
+			code = resp.StatusCode
+			break
+		}
+	}
+	out, _ := json.Marshal(map[string]any{"statements": results})
+	return &sinkResponse{Code: code, Status: fmt.Sprintf("%d %s", code, http.StatusText(code)), Body: out}, nil
+}
+
+func (c *clickhouseSink) Status(ctx context.Context) (*sinkResponse, error) {
+	db := c.sc.ClickHouse.Database
+	if db == "" {
+		db = "default"
+	}
+	q := fmt.Sprintf("SELECT name, engine, total_rows FROM system.tables WHERE database = '%s' FORMAT JSON",
+		strings.ReplaceAll(db, "'", "''"))
+	resp, body, err := c.exec(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (c *clickhouseSink) Config(ctx context.Context) (*sinkResponse, error) {
+	if c.sc.ClickHouse.KafkaTable == "" {
+		return nil, &sinkInputError{fmt.Errorf("sink %s: clickhouse.kafka_table is not set", c.sc.Name)}
+	}
+	resp, body, err := c.exec(ctx, "SHOW CREATE TABLE "+c.sc.ClickHouse.KafkaTable)
+	if err != nil {
+		return nil, err
+	}
+	out, _ := json.Marshal(map[string]any{"ddl": strings.TrimSpace(string(body))})
+	return &sinkResponse{Code: resp.StatusCode, Status: resp.Status, Body: out}, nil
+}
+
+func (c *clickhouseSink) tableStmt(ctx context.Context, verb string) (*sinkResponse, error) {
+	if c.sc.ClickHouse.KafkaTable == "" {
+		return nil, &sinkInputError{fmt.Errorf("sink %s: clickhouse.kafka_table is not set", c.sc.Name)}
+	}
+	c.s.logger.Printf("clickhouse action=%s table=%s", strings.ToLower(verb), c.sc.ClickHouse.KafkaTable)
+	resp, body, err := c.exec(ctx, verb+" TABLE "+c.sc.ClickHouse.KafkaTable)
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+// DETACH 停止消费但保留表定义与 consumer group 位点
+func (c *clickhouseSink) Pause(ctx context.Context) (*sinkResponse, error) {
+	return c.tableStmt(ctx, "DETACH")
+}
+func (c *clickhouseSink) Resume(ctx context.Context) (*sinkResponse, error) {
+	return c.tableStmt(ctx, "ATTACH")
+}
+
+// 只删除消费链路（物化视图 + Kafka 源表），事实表数据保留
+func (c *clickhouseSink) Delete(ctx context.Context) (*sinkResponse, error) {
+	var last *sinkResponse
+	if v := c.sc.ClickHouse.View; v != "" {
+		resp, body, err := c.exec(ctx, "DROP VIEW IF EXISTS "+v)
+		if err != nil {
+			return nil, err
+		}
+		if last = toSinkResponse(resp, body); resp.StatusCode >= 400 {
+			return last, nil
+		}
+	}
+	if t := c.sc.ClickHouse.KafkaTable; t != "" {
+		resp, body, err := c.exec(ctx, "DROP TABLE IF EXISTS "+t)
+		if err != nil {
+			return nil, err
+		}
+		last = toSinkResponse(resp, body)
+	}
+	if last == nil {
+		return nil, &sinkInputError{fmt.Errorf("sink %s: neither clickhouse.view nor clickhouse.kafka_table is set", c.sc.Name)}
+	}
+	return last, nil
+}
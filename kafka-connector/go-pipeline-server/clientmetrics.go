@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/************** 下游 HTTP 客户端指标与慢调用日志 **************/
+
+// 每个下游 Transport 外包一层 RoundTripper：按 host 统计延迟直方图、连接新建/复用次数，
+// 以及建连（DNS/TCP/TLS）与服务端处理（请求写完到首字节）的累计耗时——
+// 压测/批量下发时据此区分瓶颈在网络还是 ES 本身。超过 timeouts.slow_call 的调用单独打日志。
+// 指标见 /admin/debug/runtime 的 http_client 与 /admin/debug/vars。
+
+// 直方图上界（毫秒），最后一档为 +Inf
+var latencyBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+type hostMetrics struct {
+	mu         sync.Mutex
+	buckets    []int64 // len(latencyBucketsMs)+1
+	count      int64
+	errors     int64
+	sumMs      float64
+	maxMs      float64
+	connNew    int64
+	connReused int64
+	connIdle   int64 // 复用的连接中来自空闲池的次数
+	dnsMs      float64
+	connectMs  float64
+	tlsMs      float64
+	serverMs   float64 // 请求写完 -> 响应首字节
+}
+
+type clientMetrics struct {
+	mu       sync.Mutex
+	hosts    map[string]*hostMetrics
+	slowCall time.Duration
+	logger   atomic.Pointer[log.Logger]
+}
+
+func newClientMetrics(slowCall time.Duration) *clientMetrics {
+	return &clientMetrics{hosts: map[string]*hostMetrics{}, slowCall: slowCall}
+}
+
+func (m *clientMetrics) host(h string) *hostMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hm, ok := m.hosts[h]
+	if !ok {
+		hm = &hostMetrics{buckets: make([]int64, len(latencyBucketsMs)+1)}
+		m.hosts[h] = hm
+	}
+	return hm
+}
+
+// 单次调用的各阶段时间点
+type callTrace struct {
+	start                    time.Time
+	dnsStart, dnsDone        time.Time
+	connectStart, connectEnd time.Time
+	tlsStart, tlsEnd         time.Time
+	wroteRequest, firstByte  time.Time
+	gotConn, reused, wasIdle bool
+}
+
+func ms(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000.0
+}
+
+func (m *clientMetrics) observe(kind string, req *http.Request, ct *callTrace, status int, err error) {
+	total := ms(ct.start, time.Now())
+	hm := m.host(req.URL.Host)
+	hm.mu.Lock()
+	i := sort.SearchFloat64s(latencyBucketsMs, total)
+	hm.buckets[i]++
+	hm.count++
+	hm.sumMs += total
+	hm.maxMs = max(hm.maxMs, total)
+	if err != nil {
+		hm.errors++
+	}
+	if ct.gotConn {
+		if ct.reused {
+			hm.connReused++
+			if ct.wasIdle {
+				hm.connIdle++
+			}
+		} else {
+			hm.connNew++
+		}
+	}
+	hm.dnsMs += ms(ct.dnsStart, ct.dnsDone)
+	hm.connectMs += ms(ct.connectStart, ct.connectEnd)
+	hm.tlsMs += ms(ct.tlsStart, ct.tlsEnd)
+	hm.serverMs += ms(ct.wroteRequest, ct.firstByte)
+	hm.mu.Unlock()
+
+	if m.slowCall > 0 && total >= float64(m.slowCall)/float64(time.Millisecond) {
+		if l := m.logger.Load(); l != nil {
+			l.Printf("downstream slow kind=%s host=%s method=%s path=%s status=%d dur_ms=%.3f dns_ms=%.3f connect_ms=%.3f tls_ms=%.3f server_ms=%.3f conn_reused=%v err=%v",
+				kind, req.URL.Host, req.Method, req.URL.Path, status, total,
+				ms(ct.dnsStart, ct.dnsDone), ms(ct.connectStart, ct.connectEnd), ms(ct.tlsStart, ct.tlsEnd),
+				ms(ct.wroteRequest, ct.firstByte), ct.reused, err)
+		}
+	}
+}
+
+func (m *clientMetrics) stats() map[string]any {
+	m.mu.Lock()
+	hosts := make(map[string]*hostMetrics, len(m.hosts))
+	for h, hm := range m.hosts {
+		hosts[h] = hm
+	}
+	m.mu.Unlock()
+
+	out := map[string]any{}
+	for h, hm := range hosts {
+		hm.mu.Lock()
+		buckets := make([]map[string]any, 0, len(hm.buckets))
+		var cum int64
+		for i, n := range hm.buckets {
+			cum += n
+			var le any = "+Inf"
+			if i < len(latencyBucketsMs) {
+				le = latencyBucketsMs[i]
+			}
+			buckets = append(buckets, map[string]any{"le_ms": le, "count": cum})
+		}
+		avg := func(sum float64) float64 {
+			if hm.count == 0 {
+				return 0
+			}
+			return sum / float64(hm.count)
+		}
+		out[h] = map[string]any{
+			"requests":         hm.count,
+			"errors":           hm.errors,
+			"latency_avg_ms":   avg(hm.sumMs),
+			"latency_max_ms":   hm.maxMs,
+			"latency_buckets":  buckets,
+			"conn_new":         hm.connNew,
+			"conn_reused":      hm.connReused,
+			"conn_reused_idle": hm.connIdle,
+			"avg_dns_ms":       avg(hm.dnsMs),
+			"avg_connect_ms":   avg(hm.connectMs),
+			"avg_tls_ms":       avg(hm.tlsMs),
+			"avg_server_ms":    avg(hm.serverMs),
+		}
+		hm.mu.Unlock()
+	}
+	return map[string]any{"slow_call_threshold": m.slowCall.String(), "hosts": out}
+}
+
+// 包在每个下游 Transport 外层
+type instrumentedTransport struct {
+	base    http.RoundTripper
+	kind    string
+	metrics *clientMetrics
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct := &callTrace{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { ct.dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { ct.dnsDone = time.Now() },
+		ConnectStart:      func(string, string) { ct.connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { ct.connectEnd = time.Now() },
+		TLSHandshakeStart: func() { ct.tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { ct.tlsEnd = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.gotConn, ct.reused, ct.wasIdle = true, info.Reused, info.WasIdle
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { ct.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { ct.firstByte = time.Now() },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.metrics.observe(t.kind, req, ct, 0, err)
+		return nil, err
+	}
+	// 读完（或关闭）响应体时才算一次完整调用
+	resp.Body = &observedBody{ReadCloser: resp.Body, done: func(err error) {
+		t.metrics.observe(t.kind, req, ct, resp.StatusCode, err)
+	}}
+	return resp, nil
+}
+
+type observedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func(error)
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		var e error
+		if err != io.EOF {
+			e = err
+		}
+		b.once.Do(func() { b.done(e) })
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(nil) })
+	return err
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+/************** ES 集群健康与分片分配诊断 **************/
+
+// 写入停滞时 connector 往往只报超时或 bulk 失败；这里直接给出集群与数据流 backing index 的健康度，
+// 以及 red / yellow 索引中未分配分片的 allocation explain（哪个 decider 拒绝、为什么），
+// UI 可以据此说明"磁盘水位超限"或"副本数大于节点数"等根因。
+
+const maxExplainShards = 20 // 单次最多解释的未分配分片数
+
+type indexHealth struct {
+	Status              string `json:"status"`
+	NumberOfShards      int    `json:"number_of_shards"`
+	NumberOfReplicas    int    `json:"number_of_replicas"`
+	ActiveShards        int    `json:"active_shards"`
+	RelocatingShards    int    `json:"relocating_shards"`
+	InitializingShards  int    `json:"initializing_shards"`
+	UnassignedShards    int    `json:"unassigned_shards"`
+	ActivePrimaryShards int    `json:"active_primary_shards"`
+}
+
+// 集群整体健康度 + 数据流各 backing index 的健康度（最后一个为写索引）
+func (s *Server) dataStreamHealth(ctx context.Context) (map[string]any, map[string]indexHealth, []string, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_cluster/health", "es")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, fmt.Errorf("cluster health returned %s", resp.Status)
+	}
+	var cluster map[string]any
+	if err := json.Unmarshal(body, &cluster); err != nil {
+		return nil, nil, nil, fmt.Errorf("decode cluster health: %w", err)
+	}
+	indices, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		return cluster, nil, nil, err
+	}
+	u := fmt.Sprintf("%s/_cluster/health/%s?level=indices", s.cfg.ES.Host, strings.Join(indices, ","))
+	resp, body, err = s.doGET(ctx, u, "es")
+	if err != nil {
+		return cluster, nil, indices, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cluster, nil, indices, fmt.Errorf("index health returned %s", resp.Status)
+	}
+	var doc struct {
+		Indices map[string]indexHealth `json:"indices"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return cluster, nil, indices, fmt.Errorf("decode index health: %w", err)
+	}
+	return cluster, doc.Indices, indices, nil
+}
+
+func (s *Server) handleClusterHealth(w http.ResponseWriter, r *http.Request) {
+	cluster, health, indices, err := s.dataStreamHealth(r.Context())
+	if cluster == nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("cluster-health", err))
+		return
+	}
+	out := map[string]any{"cluster": cluster, "data_stream": s.cfg.ES.Names.DataStream}
+	if err != nil {
+		out["error"] = err.Error()
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	type item struct {
+		Index string `json:"index"`
+		Write bool   `json:"write_index"`
+		indexHealth
+	}
+	items := make([]item, 0, len(indices))
+	unhealthy := []string{}
+	for i, name := range indices {
+		h := health[name]
+		items = append(items, item{Index: name, Write: i == len(indices)-1, indexHealth: h})
+		if h.Status != "green" {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	out["indices"] = items
+	out["unhealthy"] = unhealthy
+	writeJSON(w, http.StatusOK, out)
+}
+
+type shardExplain struct {
+	Index            string         `json:"index"`
+	Shard            int            `json:"shard"`
+	Primary          bool           `json:"primary"`
+	UnassignedReason string         `json:"unassigned_reason,omitempty"`
+	CanAllocate      string         `json:"can_allocate,omitempty"`
+	Explanation      string         `json:"explanation,omitempty"`
+	Nodes            []nodeDecision `json:"nodes,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+type nodeDecision struct {
+	Node     string   `json:"node"`
+	Decision string   `json:"decision"`
+	Deciders []string `json:"deciders,omitempty"` // "decider: explanation"，只列出 NO / THROTTLE
+}
+
+// 列出未分配分片
+func (s *Server) unassignedShards(ctx context.Context, indices []string) ([]shardExplain, error) {
+	u := fmt.Sprintf("%s/_cat/shards/%s?format=json&h=index,shard,prirep,state,unassigned.reason", s.cfg.ES.Host, strings.Join(indices, ","))
+	resp, body, err := s.doGET(ctx, u, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cat shards returned %s", resp.Status)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("decode cat shards: %w", err)
+	}
+	var out []shardExplain
+	for _, row := range rows {
+		if row["state"] != "UNASSIGNED" {
+			continue
+		}
+		out = append(out, shardExplain{Index: row["index"], Shard: atoiLoose(row["shard"]), Primary: row["prirep"] == "p", UnassignedReason: row["unassigned.reason"]})
+	}
+	// 主分片优先：主分片未分配才是 red
+	slices.SortStableFunc(out, func(a, b shardExplain) int {
+		if a.Primary != b.Primary {
+			if a.Primary {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Index, b.Index)
+	})
+	return out, nil
+}
+
+func (s *Server) explainShard(ctx context.Context, sh *shardExplain) {
+	b, _ := json.Marshal(map[string]any{"index": sh.Index, "shard": sh.Shard, "primary": sh.Primary})
+	resp, body, err := s.doPOST(ctx, s.cfg.ES.Host+"/_cluster/allocation/explain", b, "es")
+	if err != nil {
+		sh.Error = err.Error()
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		sh.Error = fmt.Sprintf("allocation explain returned %s", resp.Status)
+		return
+	}
+	var doc struct {
+		CanAllocate             string `json:"can_allocate"`
+		AllocateExplanation     string `json:"allocate_explanation"`
+		NodeAllocationDecisions []struct {
+			NodeName     string `json:"node_name"`
+			NodeDecision string `json:"node_decision"`
+			Deciders     []struct {
+				Decider     string `json:"decider"`
+				Decision    string `json:"decision"`
+				Explanation string `json:"explanation"`
+			} `json:"deciders"`
+		} `json:"node_allocation_decisions"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		sh.Error = fmt.Sprintf("decode allocation explain: %v", err)
+		return
+	}
+	sh.CanAllocate, sh.Explanation = doc.CanAllocate, doc.AllocateExplanation
+	for _, n := range doc.NodeAllocationDecisions {
+		nd := nodeDecision{Node: n.NodeName, Decision: n.NodeDecision}
+		for _, d := range n.Deciders {
+			if d.Decision != "YES" {
+				nd.Deciders = append(nd.Deciders, d.Decider+": "+d.Explanation)
+			}
+		}
+		sh.Nodes = append(sh.Nodes, nd)
+	}
+}
+
+// GET /admin/es/allocation/explain[?index=a,b]：默认取数据流中非 green 的 backing index
+func (s *Server) handleAllocationExplain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var targets []string
+	if q := r.URL.Query().Get("index"); q != "" {
+		targets = strings.Split(q, ",")
+	} else {
+		_, health, indices, err := s.dataStreamHealth(ctx)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("allocation-explain", err))
+			return
+		}
+		for _, name := range indices {
+			if health[name].Status != "green" {
+				targets = append(targets, name)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"indices": []string{}, "shards": []shardExplain{}, "message": "all backing indices are green"})
+		return
+	}
+	shards, err := s.unassignedShards(ctx, targets)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("allocation-explain", err))
+		return
+	}
+	total := len(shards)
+	if total > maxExplainShards {
+		shards = shards[:maxExplainShards]
+	}
+	for i := range shards {
+		s.explainShard(ctx, &shards[i])
+	}
+	if shards == nil {
+		shards = []shardExplain{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"indices": targets, "unassigned": total, "shards": shards})
+}
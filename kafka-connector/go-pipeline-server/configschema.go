@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/************** Config 的 JSON Schema（前端动态表单） **************/
+
+// GET /admin/config/schema：按 Config 结构体的 yaml tag 反射生成 JSON Schema（draft 2020-12），
+// 前端据此渲染配置表单与校验，新增配置项无需改前端字段列表。
+// 敏感字段（按 redact 规则匹配 key）标 writeOnly + format: password；取值固定的字段见 configSchemaEnums；
+// x-env 为覆盖该字段的环境变量名（envconfig.go）。
+
+// 取值固定的字段，key 为 yaml 路径，数组元素用 []；"" 表示留空取默认值
+var configSchemaEnums = map[string][]string{
+	"es.flavor":                     {"", "elasticsearch", "opensearch"},
+	"sink.type":                     {"", sinkTypeConnect, sinkTypeLogstash, sinkTypeLoki, sinkTypeClickHouse, sinkTypeS3, sinkTypeMirrorMaker},
+	"sinks[].type":                  {"", sinkTypeConnect, sinkTypeLogstash, sinkTypeLoki, sinkTypeClickHouse, sinkTypeS3, sinkTypeMirrorMaker},
+	"sinks[].mirrormaker.connector": {"", "source", "checkpoint", "heartbeat"},
+	"kafka.sasl.mechanism":          {"", "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512", "AWS_MSK_IAM"},
+	"downsample.phase":              {"", "warm", "cold"},
+	"ecs.mode":                      {"", ecsModeOff, ecsModeWarn, ecsModeBlock},
+	"archive.snapshot_phase":        {"", "cold", "frozen"},
+	"lock.backend":                  {"", lockBackendLocal, lockBackendES},
+	"notifications.targets[].type":  {"", notifySlack, notifyDingTalk, notifyWebhook, notifyEmail},
+	"alerts.rules[].type": {ruleESCount, ruleConsumerLag, ruleILMError, ruleConnectorState,
+		ruleDiskWatermark, ruleShardHeadroom, ruleCCRLag},
+}
+
+var (
+	configSchemaOnce sync.Once
+	configSchemaDoc  map[string]any
+)
+
+func (s *Server) configSchema() map[string]any {
+	configSchemaOnce.Do(func() {
+		doc := s.typeSchema(reflect.TypeOf(Config{}), "")
+		doc["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		doc["title"] = "go-pipeline-server config"
+		configSchemaDoc = doc
+	})
+	return configSchemaDoc
+}
+
+func (s *Server) typeSchema(t reflect.Type, path string) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		var order []string
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			p := name
+			if path != "" {
+				p = path + "." + name
+			}
+			fs := s.typeSchema(f.Type, p)
+			if f.Type.Kind() == reflect.String && s.redact.matchKey(name) {
+				fs["writeOnly"] = true
+				fs["format"] = "password"
+			}
+			// 可用环境变量覆盖的字段（不在数组 / map 元素内）标出变量名
+			if !strings.ContainsAny(p, "[*") {
+				fs["x-env"] = envConfigName(p)
+			}
+			props[name] = fs
+			order = append(order, name)
+		}
+		// JSON 对象无序，x-order 保留结构体中的字段顺序，表单按此排列
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false, "x-order": order}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.typeSchema(t.Elem(), path+".*")}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.typeSchema(t.Elem(), path+"[]")}
+	case reflect.String:
+		sc := map[string]any{"type": "string"}
+		if enum, ok := configSchemaEnums[path]; ok {
+			sc["enum"] = enum
+		}
+		return sc
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	// any 等任意类型：不限制
+	return map[string]any{}
+}
+
+func (s *Server) handleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.configSchema())
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/************** 配置来源：本地文件 / etcd / Consul **************/
+
+// -config（或 ENV CONFIG）指定配置来源：
+//   config.yaml                              本地文件（默认，不监听变更）
+//   etcd://host:2379/log-pipeline/config      etcd v3 的一个 key（JSON gateway），支持 user:pass@host
+//   consul://host:8500/log-pipeline/config    Consul KV 的一个 key，token 取 CONSUL_HTTP_TOKEN，?dc= 等参数透传
+// scheme 写成 etcd+https / consul+https 时走 TLS。
+//
+// KV 来源启动后持续 watch。新内容先解析、校验，不合法直接拒绝、继续用旧配置；合法则等去抖 + 随机抖动
+// （错开整个集群同时重启），再等进行中的 job 与本进程持有的下发锁结束（最长 reload.idle_timeout），
+// 重启前再读一次 KV 复核，然后优雅关机并 re-exec 自身，新进程从 KV 读取新配置启动。
+// 运行中的 Server 不原地改配置：各处直接读 s.cfg，整进程重启才不会出现半新半旧。
+
+const (
+	defaultReloadDebounce    = 5 * time.Second
+	defaultReloadJitter      = 30 * time.Second
+	defaultReloadIdleTimeout = 5 * time.Minute
+
+	configLoadTimeout = 10 * time.Second
+	consulWaitTime    = 5 * time.Minute
+	etcdWatchMaxAge   = 10 * time.Minute // 定期重建 watch 流，防止中间代理静默断开
+)
+
+type ReloadConfig struct {
+	Debounce    string `yaml:"debounce"`     // KV 变更后静默多久才处理（合并连续写入），默认 5s
+	Jitter      string `yaml:"jitter"`       // 额外随机等待上限，错开多副本同时重启，默认 30s
+	IdleTimeout string `yaml:"idle_timeout"` // 等待 job / 下发锁结束的最长时间，超时仍重启，默认 5m
+}
+
+// KV 中某个版本的配置内容
+type configRevision struct {
+	Raw     []byte
+	Rev     string
+	Deleted bool // key 被删除：保留当前配置，只记日志
+}
+
+type configSource interface {
+	String() string // 展示用，已去掉密码
+	load(ctx context.Context) (*configRevision, error)
+	// 阻塞直到 key 在 rev 之后变化；本轮长轮询超时无变化返回 nil, nil
+	watch(ctx context.Context, rev string) (*configRevision, error)
+}
+
+func newConfigSource(spec string) (configSource, error) {
+	if !strings.Contains(spec, "://") {
+		return &fileConfigSource{path: spec}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("config source %q: %w", spec, err)
+	}
+	kind, scheme, _ := strings.Cut(u.Scheme, "+")
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("config source %q: unsupported transport %q (http | https)", spec, scheme)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("config source %q: host and key are required, e.g. %s://host/log-pipeline/config", spec, kind)
+	}
+	base := &url.URL{Scheme: scheme, Host: u.Host}
+	display := (&url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: u.Path, RawQuery: u.RawQuery}).Redacted()
+	switch kind {
+	case "etcd":
+		src := &etcdConfigSource{base: base, key: key, display: display, client: newKVClient()}
+		if u.User != nil {
+			src.user = u.User.Username()
+			src.pass, _ = u.User.Password()
+		}
+		return src, nil
+	case "consul":
+		return &consulConfigSource{base: base, key: key, query: u.Query(), display: display,
+			token: os.Getenv("CONSUL_HTTP_TOKEN"), client: newKVClient()}, nil
+	}
+	return nil, fmt.Errorf("config source %q: unknown scheme %q (etcd | consul)", spec, kind)
+}
+
+// 长轮询 / watch 流不能套 s.client 的 30s 总超时，超时由各调用的 ctx 控制
+func newKVClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+		DialContext:     (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		IdleConnTimeout: 90 * time.Second,
+	}}
+}
+
+// 解析并校验：与启动时会 panic 的构造函数走同一套检查，拒绝的配置不会触发重启
+func parseConfig(raw []byte) (Config, error) {
+	return parseConfigEnv(raw, os.Environ(), configSetOverrides)
+}
+
+// environ 中的 LOGPIPE_ 变量与命令行 -set 覆盖 yaml 中的值（见 envconfig.go）；init 生成配置时均传 nil
+func parseConfigEnv(raw []byte, environ, sets []string) (cfg Config, err error) {
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse yaml: %w", err)
+	}
+	if _, err := applyEnvOverrides(&cfg, environ); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err := applySetOverrides(&cfg, sets); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := validateLabels(cfg.Ownership.Labels); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.renderNames(time.Now().UTC()); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Tenancy.validate(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Guardrails.validate(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Routing.validate(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.ECS.validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Approvals.validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Freeze.validate(cfg.Approvals); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid config: %v", r)
+		}
+	}()
+	mustParseDuration("cache.ttl", cfg.Cache.TTL)
+	mustParseDuration("live.interval", cfg.Live.Interval)
+	mustParseDuration("reload.debounce", cfg.Reload.Debounce)
+	mustParseDuration("reload.jitter", cfg.Reload.Jitter)
+	mustParseDuration("reload.idle_timeout", cfg.Reload.IdleTimeout)
+	cfg.Shutdown.timeouts()
+	newProbeState(cfg.Probes)
+	newLockManager(cfg.Lock)
+	newScheduler(cfg.Schedules)
+	newFreezeCalendar(cfg.Freeze)
+	newGitStore(cfg.Git)
+	newDownstreamClients(cfg.Timeouts, cfg.Proxy, false)
+	newBreakers(cfg.Breaker)
+	newRequestLogRules(cfg.RequestLog)
+	newIdempotencyStore(cfg.Idempotency)
+	mustParseDuration("connect_state.interval", cfg.ConnectState.Interval)
+	mustParseDuration("approvals.plan_ttl", cfg.Approvals.PlanTTL)
+	newTaskRestarter(cfg.TaskRestarter)
+	newRestartGuard(cfg.RestartGuard)
+	if cfg.ES.Host == "" {
+		return cfg, fmt.Errorf("invalid config: es.host is required")
+	}
+	if cfg.Debug.Enabled && cfg.Debug.Token == "" {
+		return cfg, fmt.Errorf("invalid config: debug.token is required when debug.enabled is true")
+	}
+	return cfg, nil
+}
+
+/************** 本地文件 **************/
+
+type fileConfigSource struct{ path string }
+
+func (f *fileConfigSource) String() string { return f.path }
+
+func (f *fileConfigSource) load(ctx context.Context) (*configRevision, error) {
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) && envConfigPresent() {
+		// 容器部署：不挂载配置文件，全部配置来自 LOGPIPE_ 环境变量
+		return &configRevision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &configRevision{Raw: b}, nil
+}
+
+func (f *fileConfigSource) watch(ctx context.Context, rev string) (*configRevision, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+/************** etcd v3（JSON gateway） **************/
+
+type etcdConfigSource struct {
+	base       *url.URL
+	key        string
+	user, pass string
+	display    string
+	client     *http.Client
+}
+
+func (e *etcdConfigSource) String() string { return e.display }
+
+type etcdKV struct {
+	Value       string `json:"value"` // base64
+	ModRevision string `json:"mod_revision"`
+}
+
+func (kv etcdKV) revision() (*configRevision, error) {
+	b, err := base64.StdEncoding.DecodeString(kv.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decode etcd value: %w", err)
+	}
+	return &configRevision{Raw: b, Rev: kv.ModRevision}, nil
+}
+
+// 开启认证时先换 token（etcd 的 token 有时效，每次调用重新取，load/watch 频率很低）
+func (e *etcdConfigSource) request(ctx context.Context, path string, body any) (*http.Response, error) {
+	token := ""
+	if e.user != "" {
+		var out struct {
+			Token string `json:"token"`
+		}
+		resp, err := e.post(ctx, "/v3/auth/authenticate", "", map[string]string{"name": e.user, "password": e.pass})
+		if err != nil {
+			return nil, fmt.Errorf("etcd authenticate: %w", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("etcd authenticate: %w", err)
+		}
+		token = out.Token
+	}
+	return e.post(ctx, path, token, body)
+}
+
+func (e *etcdConfigSource) post(ctx context.Context, path, token string, body any) (*http.Response, error) {
+	b, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.base.String()+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("etcd %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (e *etcdConfigSource) load(ctx context.Context) (*configRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, configLoadTimeout)
+	defer cancel()
+	resp, err := e.request(ctx, "/v3/kv/range", map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.key))})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Kvs []etcdKV `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode etcd range: %w", err)
+	}
+	if len(out.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd key %q not found", e.key)
+	}
+	return out.Kvs[0].revision()
+}
+
+// watch 流逐条返回 JSON；只取第一个事件，调用方处理完再从新版本继续 watch
+func (e *etcdConfigSource) watch(ctx context.Context, rev string) (*configRevision, error) {
+	n, err := strconv.ParseInt(rev, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("etcd revision %q: %w", rev, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, etcdWatchMaxAge)
+	defer cancel()
+	resp, err := e.request(ctx, "/v3/watch", map[string]any{"create_request": map[string]any{
+		"key": base64.StdEncoding.EncodeToString([]byte(e.key)), "start_revision": strconv.FormatInt(n+1, 10),
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Canceled        bool   `json:"canceled"`
+				CancelReason    string `json:"cancel_reason"`
+				CompactRevision string `json:"compact_revision"`
+				Events          []struct {
+					Type string `json:"type"` // PUT 为默认值不输出，删除为 DELETE
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("etcd watch stream: %w", err)
+		}
+		if msg.Error != nil {
+			return nil, fmt.Errorf("etcd watch: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			// 起始版本已被 compact 等：返回错误，调用方重新 load 对齐
+			return nil, fmt.Errorf("etcd watch canceled: %s (compact_revision=%s)", msg.Result.CancelReason, msg.Result.CompactRevision)
+		}
+		if len(msg.Result.Events) == 0 {
+			continue
+		}
+		ev := msg.Result.Events[len(msg.Result.Events)-1]
+		if ev.Type == "DELETE" {
+			return &configRevision{Rev: ev.KV.ModRevision, Deleted: true}, nil
+		}
+		return ev.KV.revision()
+	}
+}
+
+/************** Consul KV **************/
+
+type consulConfigSource struct {
+	base    *url.URL
+	key     string
+	query   url.Values
+	token   string
+	display string
+	client  *http.Client
+}
+
+func (c *consulConfigSource) String() string { return c.display }
+
+// index 为空时普通读取；否则为阻塞查询，直到 ModifyIndex 超过 index 或 wait 超时
+func (c *consulConfigSource) get(ctx context.Context, index string) (*configRevision, error) {
+	q := url.Values{}
+	for k, v := range c.query {
+		q[k] = v
+	}
+	if index != "" {
+		q.Set("index", index)
+		q.Set("wait", consulWaitTime.String())
+	}
+	u := *c.base
+	u.Path = "/v1/kv/" + c.key
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	newIndex := resp.Header.Get("X-Consul-Index")
+	if resp.StatusCode == http.StatusNotFound {
+		return &configRevision{Rev: newIndex, Deleted: true}, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("consul kv %s returned %s: %s", c.key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var entries []struct {
+		Value       string `json:"Value"` // base64
+		ModifyIndex uint64 `json:"ModifyIndex"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil || len(entries) == 0 {
+		return nil, fmt.Errorf("decode consul kv %s: %v", c.key, err)
+	}
+	b, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("decode consul value: %w", err)
+	}
+	return &configRevision{Raw: b, Rev: strconv.FormatUint(entries[0].ModifyIndex, 10)}, nil
+}
+
+func (c *consulConfigSource) load(ctx context.Context) (*configRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, configLoadTimeout)
+	defer cancel()
+	r, err := c.get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if r.Deleted {
+		return nil, fmt.Errorf("consul key %q not found", c.key)
+	}
+	return r, nil
+}
+
+func (c *consulConfigSource) watch(ctx context.Context, rev string) (*configRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, consulWaitTime+30*time.Second)
+	defer cancel()
+	r, err := c.get(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+	// 阻塞查询超时返回同一 index 即无变化；index 回退（Consul 重建）时按变化处理，交给内容比较
+	if r.Rev == rev {
+		return nil, nil
+	}
+	return r, nil
+}
+
+/************** watch 与安全重启 **************/
+
+type configChange struct {
+	Rev   string    `json:"revision"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+type configWatcher struct {
+	src configSource
+
+	mu       sync.Mutex
+	raw      []byte // 当前进程使用的配置内容
+	rev      string
+	loadedAt time.Time
+	env      []string      // 被 LOGPIPE_ 环境变量覆盖的配置项（yaml 路径）
+	sets     []string      // 被 -set 覆盖的配置项
+	pending  *configChange // 已通过校验、等待重启生效
+	rejected *configChange // 最近一次被拒绝的变更
+	timer    *time.Timer
+
+	restart     chan struct{} // 关闭即请求重启（main 中优雅关机后 re-exec）
+	restartOnce sync.Once
+}
+
+// 启动时读取配置；KV 不可达直接退出，由编排系统重试
+func loadConfig(spec string) (Config, *configWatcher, error) {
+	src, err := newConfigSource(spec)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	cur, err := src.load(context.Background())
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("load config from %s: %w", src, err)
+	}
+	cfg, err := parseConfig(cur.Raw)
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("config from %s: %w", src, err)
+	}
+	env, _ := applyEnvOverrides(&Config{}, os.Environ())
+	if len(env) > 0 {
+		log.Printf("config: overridden from environment: %s", strings.Join(env, ", "))
+	}
+	sets, _ := applySetOverrides(&Config{}, configSetOverrides)
+	if len(sets) > 0 {
+		log.Printf("config: overridden by -set: %s", strings.Join(sets, ", "))
+	}
+	return cfg, &configWatcher{src: src, raw: cur.Raw, rev: cur.Rev, loadedAt: time.Now(), env: env, sets: sets, restart: make(chan struct{})}, nil
+}
+
+func (c *configWatcher) watchable() bool {
+	_, isFile := c.src.(*fileConfigSource)
+	return !isFile
+}
+
+func (s *Server) runConfigWatch(ctx context.Context) {
+	c := s.conf
+	if !c.watchable() {
+		return
+	}
+	s.logger.Printf("step=config watch source=%s revision=%s", c.src, c.rev)
+	rev, resync := c.rev, false
+	for ctx.Err() == nil {
+		var next *configRevision
+		var err error
+		if resync {
+			next, err = c.src.load(ctx)
+		} else {
+			next, err = c.src.watch(ctx, rev)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Printf("step=config watch err=%v (retry in 5s)", err)
+			resync = true
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		resync = false
+		if next == nil {
+			continue
+		}
+		rev = next.Rev
+		s.onConfigChange(ctx, next)
+	}
+}
+
+func (s *Server) onConfigChange(ctx context.Context, next *configRevision) {
+	c := s.conf
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if next.Deleted {
+		s.logger.Printf("step=config key deleted revision=%s (keeping current config)", next.Rev)
+		return
+	}
+	if bytes.Equal(next.Raw, c.raw) {
+		// 改回当前内容：取消尚未执行的重启
+		if c.pending != nil {
+			c.timer.Stop()
+			c.pending = nil
+			s.logger.Printf("step=config revision=%s matches running config, reload canceled", next.Rev)
+		}
+		return
+	}
+	_, err := parseConfig(next.Raw)
+	if err == nil && strictConfig {
+		err = strictConfigError(next.Raw)
+	}
+	if err != nil {
+		// 新内容不合法：不重启，也取消之前排队的重启（新进程会读到这份坏配置）
+		c.rejected = &configChange{Rev: next.Rev, At: time.Now(), Error: err.Error()}
+		if c.pending != nil {
+			c.timer.Stop()
+			c.pending = nil
+		}
+		s.logger.Printf("step=config rejected revision=%s err=%v", next.Rev, err)
+		return
+	}
+	debounce := mustParseDuration("reload.debounce", s.cfg.Reload.Debounce)
+	if debounce <= 0 {
+		debounce = defaultReloadDebounce
+	}
+	jitter := mustParseDuration("reload.jitter", s.cfg.Reload.Jitter)
+	if jitter <= 0 {
+		jitter = defaultReloadJitter
+	}
+	delay := debounce + rand.N(jitter)
+	c.pending = &configChange{Rev: next.Rev, At: time.Now()}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.timer = time.AfterFunc(delay, func() { s.reloadConfig(ctx) })
+	s.logger.Printf("step=config change revision=%s validated, reload in %s", next.Rev, delay.Round(time.Millisecond))
+}
+
+// 等空闲后复核 KV 最新内容，合法才触发重启
+func (s *Server) reloadConfig(ctx context.Context) {
+	idle := mustParseDuration("reload.idle_timeout", s.cfg.Reload.IdleTimeout)
+	if idle <= 0 {
+		idle = defaultReloadIdleTimeout
+	}
+	if jobs, locks := s.waitIdle(ctx, idle); ctx.Err() != nil {
+		return
+	} else if jobs > 0 || locks > 0 {
+		s.logger.Printf("step=config idle_timeout=%s exceeded, restarting with running_jobs=%d held_locks=%d", idle, jobs, locks)
+	}
+
+	c := s.conf
+	latest, err := c.src.load(ctx)
+	if err == nil {
+		_, err = parseConfig(latest.Raw)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		return // 期间已被取消
+	}
+	if err != nil {
+		c.pending = nil
+		s.logger.Printf("step=config reload aborted: latest config not usable: %v", err)
+		return
+	}
+	s.logger.Printf("step=config reload revision=%s, restarting", latest.Rev)
+	c.restartOnce.Do(func() { close(c.restart) })
+}
+
+// 用同样的参数与环境替换当前进程，新进程重新从配置来源加载；fds 为交接的监听 socket
+func (s *Server) reexec(fds string) {
+	exe, err := os.Executable()
+	if err != nil {
+		s.logger.Fatalf("re-exec: %v", err)
+	}
+	env := os.Environ()
+	if fds != "" {
+		env = append(env, inheritFDsEnv+"="+fds)
+	}
+	s.logger.Printf("step=restart re-exec %s listen_fds=%q", exe, fds)
+	if err := syscall.Exec(exe, os.Args, env); err != nil {
+		s.logger.Fatalf("re-exec: %v", err)
+	}
+}
+
+func (s *Server) handleConfigSource(w http.ResponseWriter, r *http.Request) {
+	c := s.conf
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"source": c.src.String(), "watch": c.watchable(), "revision": c.rev, "loaded_at": c.loadedAt,
+		"pending": c.pending, "rejected": c.rejected, "env_overrides": c.env, "set_overrides": c.sets,
+	})
+}
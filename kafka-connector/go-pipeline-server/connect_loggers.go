@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** Connect worker 日志级别 **************/
+
+// 包装 Connect 的 /admin/loggers：排查 sink 报错时临时把 io.confluent.connect.elasticsearch 调到 DEBUG，
+// 结束后 DELETE 恢复为修改前的级别。默认带 scope=cluster 作用于全部 worker（Kafka 3.7+；
+// 更早的版本忽略该参数，只作用于接到请求的 worker）。修改前的级别只记在内存中，重启后无法恢复。
+
+var connectLogLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "OFF"}
+
+type loggerOverride struct {
+	Logger   string    `json:"logger"`
+	Level    string    `json:"level"`
+	Original string    `json:"original,omitempty"` // 为空表示修改前未单独配置，恢复时用 root 级别
+	Scope    string    `json:"scope"`
+	SetAt    time.Time `json:"set_at"`
+	SetBy    string    `json:"set_by,omitempty"`
+}
+
+type loggerOverrides struct {
+	mu sync.Mutex
+	m  map[string]loggerOverride
+}
+
+func newLoggerOverrides() *loggerOverrides {
+	return &loggerOverrides{m: map[string]loggerOverride{}}
+}
+
+func (s *Server) connectLoggerURL(name, scope string) string {
+	u := s.cfg.Connect.Host + "/admin/loggers/"
+	if name != "" {
+		u += url.PathEscape(name)
+	}
+	if scope != "" {
+		u += "?scope=" + url.QueryEscape(scope)
+	}
+	return u
+}
+
+// GET /admin/connect/loggers：worker 当前的 logger 级别，以及通过本服务做过的修改
+func (s *Server) handleConnectLoggers(w http.ResponseWriter, r *http.Request) {
+	resp, body, err := s.doGET(r.Context(), s.connectLoggerURL("", ""), "connect")
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("connect-loggers", err))
+		return
+	}
+	if resp.StatusCode >= 300 {
+		writeJSON(w, resp.StatusCode, jsonRaw(body))
+		return
+	}
+	var loggers map[string]struct {
+		Level        string `json:"level"`
+		LastModified *int64 `json:"last_modified,omitempty"`
+	}
+	if err := json.Unmarshal(body, &loggers); err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("connect-loggers", fmt.Errorf("decode loggers: %w", err)))
+		return
+	}
+	s.loggers.mu.Lock()
+	overrides := make([]loggerOverride, 0, len(s.loggers.m))
+	for _, o := range s.loggers.m {
+		overrides = append(overrides, o)
+	}
+	s.loggers.mu.Unlock()
+	slices.SortFunc(overrides, func(a, b loggerOverride) int { return strings.Compare(a.Logger, b.Logger) })
+	writeJSON(w, http.StatusOK, map[string]any{"loggers": loggers, "overrides": overrides, "levels": connectLogLevels})
+}
+
+// 读取单个 logger 的当前级别；未单独配置时 Connect 返回 404
+func (s *Server) connectLoggerLevel(r *http.Request, name string) (string, error) {
+	resp, body, err := s.doGET(r.Context(), s.connectLoggerURL(name, ""), "connect")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("get logger %s: %s", name, resp.Status)
+	}
+	var doc struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("decode logger %s: %w", name, err)
+	}
+	return doc.Level, nil
+}
+
+func (s *Server) putConnectLogger(r *http.Request, name, level, scope string) (*http.Response, []byte, error) {
+	b, _ := json.Marshal(map[string]string{"level": level})
+	u := s.connectLoggerURL(name, scope)
+	s.logger.Printf("connect action=set-logger logger=%s level=%s scope=%s url=%s operator=%s", name, level, scope, u, operatorIdentity(r))
+	return s.doPUT(r.Context(), u, b, "connect")
+}
+
+// PUT /admin/connect/loggers {"logger":"io.confluent.connect.elasticsearch","level":"DEBUG","scope":"cluster"}
+func (s *Server) handleSetConnectLogger(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Logger string `json:"logger"`
+		Level  string `json:"level"`
+		Scope  string `json:"scope"` // cluster（默认）| worker
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	req.Level = strings.ToUpper(strings.TrimSpace(req.Level))
+	if req.Scope == "" {
+		req.Scope = "cluster"
+	}
+	switch {
+	case strings.TrimSpace(req.Logger) == "":
+		writeJSON(w, 400, map[string]string{"error": "logger is required"})
+		return
+	case !slices.Contains(connectLogLevels, req.Level):
+		writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown level %q", req.Level), "levels": connectLogLevels})
+		return
+	case req.Scope != "cluster" && req.Scope != "worker":
+		writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("unknown scope %q (cluster | worker)", req.Scope)})
+		return
+	}
+
+	// 第一次修改时记下原级别，重复修改不覆盖
+	s.loggers.mu.Lock()
+	prev, seen := s.loggers.m[req.Logger]
+	s.loggers.mu.Unlock()
+	original := prev.Original
+	if !seen {
+		lvl, err := s.connectLoggerLevel(r, req.Logger)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("connect-logger", err))
+			return
+		}
+		original = lvl
+	}
+
+	resp, body, err := s.putConnectLogger(r, req.Logger, req.Level, req.Scope)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("connect-logger", err))
+		return
+	}
+	if resp.StatusCode >= 300 {
+		writeJSON(w, resp.StatusCode, jsonRaw(body))
+		return
+	}
+	o := loggerOverride{Logger: req.Logger, Level: req.Level, Original: original, Scope: req.Scope, SetAt: time.Now().UTC(), SetBy: operatorIdentity(r)}
+	s.loggers.mu.Lock()
+	s.loggers.m[req.Logger] = o
+	s.loggers.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"override": o, "response": jsonRaw(body)})
+}
+
+// DELETE /admin/connect/loggers/{logger}：恢复为修改前的级别（之前未单独配置时恢复为 root 级别）
+func (s *Server) handleResetConnectLogger(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("logger")
+	s.loggers.mu.Lock()
+	o, ok := s.loggers.m[name]
+	s.loggers.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("logger %q was not changed through this server", name)})
+		return
+	}
+	level := o.Original
+	if level == "" {
+		root, err := s.connectLoggerLevel(r, "root")
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("connect-logger-reset", err))
+			return
+		}
+		level = root
+	}
+	if level == "" {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"step": "connect-logger-reset", "error": "cannot determine root logger level"})
+		return
+	}
+	resp, body, err := s.putConnectLogger(r, name, level, o.Scope)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("connect-logger-reset", err))
+		return
+	}
+	if resp.StatusCode >= 300 {
+		writeJSON(w, resp.StatusCode, jsonRaw(body))
+		return
+	}
+	s.loggers.mu.Lock()
+	delete(s.loggers.m, name)
+	s.loggers.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"logger": name, "level": level, "response": jsonRaw(body)})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+/************** Connect config providers（外部化密钥） **************/
+
+// ${provider:path:key} 或 ${provider:key}，与 Kafka ConfigTransformer 的语法一致
+var configPlaceholderRe = regexp.MustCompile(`\$\{([A-Za-z0-9_.\-]+):(?:([^}:]*):)?([^}]*)\}`)
+
+type configPlaceholder struct {
+	Field    string `json:"field"`
+	Provider string `json:"provider"`
+	Path     string `json:"path,omitempty"`
+	Key      string `json:"key"`
+}
+
+type providerCheck struct {
+	Configured   []string            `json:"configured"` // connect.config_providers
+	Placeholders []configPlaceholder `json:"placeholders"`
+	Missing      []string            `json:"missing"`
+	Validation   []string            `json:"validation_errors,omitempty"` // Connect validate 接口对引用字段报告的错误
+}
+
+func (p *providerCheck) ok() bool { return len(p.Missing) == 0 && len(p.Validation) == 0 }
+
+func findConfigPlaceholders(conf map[string]string) []configPlaceholder {
+	out := []configPlaceholder{}
+	for k, v := range conf {
+		for _, m := range configPlaceholderRe.FindAllStringSubmatch(v, -1) {
+			out = append(out, configPlaceholder{Field: k, Provider: m[1], Path: m[2], Key: m[3]})
+		}
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Field < out[k].Field })
+	return out
+}
+
+// 校验 connector JSON 引用的 provider 均已在 worker 上配置（config.providers）。
+// Connect REST 不暴露 worker 配置，因此以 connect.config_providers 声明为准，
+// 再用 validate 接口确认引用字段能被 worker 解析。
+func (s *Server) checkConfigProviders(ctx context.Context, connectorJSON []byte) (*providerCheck, error) {
+	var doc struct {
+		Config map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(connectorJSON, &doc); err != nil {
+		return nil, fmt.Errorf("decode connector json: %w", err)
+	}
+	chk := &providerCheck{
+		Configured:   append([]string{}, s.cfg.Connect.ConfigProviders...),
+		Placeholders: findConfigPlaceholders(doc.Config),
+		Missing:      []string{},
+	}
+	fields := map[string]bool{}
+	for _, p := range chk.Placeholders {
+		fields[p.Field] = true
+		if !slices.Contains(chk.Configured, p.Provider) && !slices.Contains(chk.Missing, p.Provider) {
+			chk.Missing = append(chk.Missing, p.Provider)
+		}
+	}
+	class := doc.Config["connector.class"]
+	if len(fields) == 0 || len(chk.Missing) > 0 || class == "" {
+		return chk, nil
+	}
+
+	b, _ := json.Marshal(doc.Config)
+	u := fmt.Sprintf("%s/connector-plugins/%s/config/validate", s.cfg.Connect.Host, url.PathEscape(class))
+	resp, body, err := s.doRequest(ctx, http.MethodPut, u, b, "connect", "application/json", s.withConnectAuth)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("connect validate returned %s", resp.Status)
+	}
+	var res struct {
+		Configs []struct {
+			Value struct {
+				Name   string   `json:"name"`
+				Errors []string `json:"errors"`
+			} `json:"value"`
+		} `json:"configs"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("decode validate response: %w", err)
+	}
+	for _, c := range res.Configs {
+		if fields[c.Value.Name] {
+			for _, e := range c.Value.Errors {
+				chk.Validation = append(chk.Validation, c.Value.Name+": "+e)
+			}
+		}
+	}
+	return chk, nil
+}
+
+func (s *Server) handleConnectConfigProviders(w http.ResponseWriter, r *http.Request) {
+	sc := s.primarySinkConfig()
+	if name := r.URL.Query().Get("sink"); name != "" {
+		var ok bool
+		if sc, ok = s.findSinkConfig(name); !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sink not found"})
+			return
+		}
+	}
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		writeSinkError(w, "config-providers", err)
+		return
+	}
+	cs, ok := p.(*connectSink)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sink " + sc.Name + " is not a Kafka Connect sink"})
+		return
+	}
+	b, err := cs.load(optionsContext(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	chk, err := s.checkConfigProviders(ctx, b)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sink": sc.Name, "ok": chk.ok(), "check": chk})
+}
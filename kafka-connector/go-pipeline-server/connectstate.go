@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** Connector 期望状态（running / paused / absent）与 reconciler **************/
+
+// 通过本服务注册 / 暂停 / 恢复 / 删除 connector 时记录期望状态，持久化到 <assets.dir>/connector-state.json；
+// 配置了 connect_state.interval 时 reconciler 每隔该间隔比对 Connect 实际状态并纠正（默认关闭，只记录）——
+// 例如 Connect worker 重启后被自动恢复运行的 connector 会重新暂停，被手工删除的会重新注册。
+// FAILED 的 task 不在这里处理。未记录期望状态的 connector 不做干预。
+
+const (
+	desiredRunning = "running"
+	desiredPaused  = "paused"
+	desiredAbsent  = "absent"
+
+	connectorStateFile = "connector-state.json"
+)
+
+var desiredStates = []string{desiredRunning, desiredPaused, desiredAbsent}
+
+type ConnectStateConfig struct {
+	Interval string `yaml:"interval"` // 比对间隔，如 1m；为空或 "0" 只记录不纠正
+}
+
+type desiredEntry struct {
+	State string    `json:"state"`
+	SetAt time.Time `json:"set_at"`
+	SetBy string    `json:"set_by,omitempty"`
+
+	LastAction   string     `json:"last_action,omitempty"` // reconciler 最近一次纠正
+	LastActionAt *time.Time `json:"last_action_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+type desiredStore struct {
+	mu      sync.Mutex
+	file    string
+	entries map[string]*desiredEntry
+	lastRun time.Time
+}
+
+func newDesiredStore(cfg AssetsConfig) *desiredStore {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultAssetsDir
+	}
+	return &desiredStore{file: filepath.Join(dir, connectorStateFile), entries: map[string]*desiredEntry{}}
+}
+
+func (st *desiredStore) load() error {
+	b, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := json.Unmarshal(b, &st.entries); err != nil {
+		return fmt.Errorf("decode %s: %w", st.file, err)
+	}
+	return nil
+}
+
+func (st *desiredStore) saveLocked() error {
+	b, err := json.MarshalIndent(st.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), 0o755); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+func (st *desiredStore) set(name, state, by string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.entries[name] = &desiredEntry{State: state, SetAt: time.Now().UTC(), SetBy: by}
+	return st.saveLocked()
+}
+
+func (st *desiredStore) get(name string) (desiredEntry, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e, ok := st.entries[name]
+	if !ok {
+		return desiredEntry{}, false
+	}
+	return *e, true
+}
+
+func (st *desiredStore) names() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := make([]string, 0, len(st.entries))
+	for n := range st.entries {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (st *desiredStore) recordAction(name, action string, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e, ok := st.entries[name]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	e.LastAction, e.LastActionAt, e.LastError = action, &now, ""
+	if err != nil {
+		e.LastError = err.Error()
+	}
+	_ = st.saveLocked()
+}
+
+// 下发接口成功后调用；只跟踪 Connect 类 sink，失败的操作不改变期望状态
+func (s *Server) trackDesired(r *http.Request, p SinkProvider, state string, res *sinkResponse, err error) {
+	if _, ok := p.(*connectSink); !ok || err != nil || res == nil {
+		return
+	}
+	if res.Code >= 300 && !(state == desiredAbsent && res.Code == http.StatusNotFound) {
+		return
+	}
+	if err := s.desired.set(p.Name(), state, operatorIdentity(r)); err != nil {
+		s.logger.Printf("step=connect-state name=%s set_err=%v", p.Name(), err)
+		return
+	}
+	s.logger.Printf("step=connect-state name=%s desired=%s", p.Name(), state)
+}
+
+type connectorActual struct {
+	State string          `json:"state"` // RUNNING / PAUSED / FAILED / UNASSIGNED / ABSENT / UNKNOWN
+	Tasks []connectorTask `json:"tasks,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+func (c *connectSink) actual(ctx context.Context) connectorActual {
+	res, err := c.Status(ctx)
+	switch {
+	case err != nil:
+		return connectorActual{State: "UNKNOWN", Error: err.Error()}
+	case res.Code == http.StatusNotFound:
+		return connectorActual{State: "ABSENT"}
+	case res.Code >= 300:
+		return connectorActual{State: "UNKNOWN", Error: res.Status}
+	}
+	state, tasks, err := parseConnectStatus(res.Body)
+	if err != nil {
+		return connectorActual{State: "UNKNOWN", Error: err.Error()}
+	}
+	return connectorActual{State: state, Tasks: tasks}
+}
+
+// 期望状态下需要执行的纠正动作；空表示一致（或无法判断）
+func desiredAction(desired string, actual connectorActual) string {
+	switch {
+	case actual.State == "UNKNOWN":
+		return ""
+	case desired == desiredAbsent:
+		if actual.State != "ABSENT" {
+			return "delete"
+		}
+	case actual.State == "ABSENT":
+		return "register"
+	case desired == desiredPaused && actual.State != "PAUSED":
+		return "pause"
+	case desired == desiredRunning && actual.State == "PAUSED":
+		return "resume"
+	}
+	return ""
+}
+
+func (c *connectSink) apply(ctx context.Context, action string) error {
+	var res *sinkResponse
+	var err error
+	switch action {
+	case "delete":
+		res, err = c.Delete(ctx)
+	case "register":
+		// 恢复的是已下发过的 connector，不需要再走审批（plans.go）
+		res, err = c.Register(context.WithValue(ctx, approvalExemptKey{}, true))
+	case "pause":
+		res, err = c.Pause(ctx)
+	case "resume":
+		res, err = c.Resume(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if res.Code >= 300 {
+		return fmt.Errorf("%s %s: %s", action, c.name, res.Status)
+	}
+	return nil
+}
+
+// 纠正单个 connector；返回执行的动作
+func (s *Server) reconcileConnector(ctx context.Context, operator, name string) (string, error) {
+	want, ok := s.desired.get(name)
+	if !ok {
+		return "", nil
+	}
+	sc, ok := s.findSinkConfig(name)
+	if !ok {
+		return "", fmt.Errorf("sink %q not configured", name)
+	}
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		return "", err
+	}
+	c, ok := p.(*connectSink)
+	if !ok {
+		return "", nil
+	}
+	action := desiredAction(want.State, c.actual(ctx))
+	if action == "" {
+		return "", nil
+	}
+	release, err := s.acquireLock(ctx, operator, "connect-state")
+	if err != nil {
+		return action, err
+	}
+	defer release()
+	err = c.apply(ctx, action)
+	s.desired.recordAction(name, action, err)
+	s.logger.Printf("step=connect-state reconcile name=%s desired=%s action=%s err=%v", name, want.State, action, err)
+	return action, err
+}
+
+func (s *Server) runConnectStateReconciler(ctx context.Context) {
+	interval := mustParseDuration("connect_state.interval", s.cfg.ConnectState.Interval)
+	if interval <= 0 {
+		s.logger.Printf("connect-state reconciler disabled")
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if p, frozen := s.freeze.active(time.Now()); frozen {
+			s.logger.Printf("connect-state reconcile paused: %s", p.message())
+			continue
+		}
+		if s.inMaintenance("connect-state reconcile") {
+			continue
+		}
+		for _, name := range s.desired.names() {
+			if ctx.Err() != nil {
+				return
+			}
+			cctx, cancel := context.WithTimeout(ctx, interval)
+			_, _ = s.reconcileConnector(cctx, "connect-state-reconciler", name)
+			cancel()
+		}
+		s.desired.mu.Lock()
+		s.desired.lastRun = time.Now()
+		s.desired.mu.Unlock()
+	}
+}
+
+type connectStateItem struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Desired *desiredEntry   `json:"desired"` // null：未跟踪
+	Actual  connectorActual `json:"actual"`
+	InSync  bool            `json:"in_sync"`
+	Pending string          `json:"pending_action,omitempty"`
+}
+
+func (s *Server) connectStateItem(ctx context.Context, sc SinkConfig) (connectStateItem, bool) {
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		return connectStateItem{}, false
+	}
+	c, ok := p.(*connectSink)
+	if !ok {
+		return connectStateItem{}, false
+	}
+	it := connectStateItem{Name: sc.Name, Type: sc.Type, Actual: c.actual(ctx), InSync: true}
+	if want, ok := s.desired.get(sc.Name); ok {
+		it.Desired = &want
+		it.Pending = desiredAction(want.State, it.Actual)
+		it.InSync = it.Pending == "" && it.Actual.State != "UNKNOWN"
+	}
+	return it, true
+}
+
+func (s *Server) handleConnectState(w http.ResponseWriter, r *http.Request) {
+	items := []connectStateItem{}
+	for _, sc := range s.sinkConfigs() {
+		if it, ok := s.connectStateItem(r.Context(), sc); ok {
+			items = append(items, it)
+		}
+	}
+	s.desired.mu.Lock()
+	lastRun := s.desired.lastRun
+	s.desired.mu.Unlock()
+	out := map[string]any{"connectors": items, "interval": s.cfg.ConnectState.Interval}
+	if !lastRun.IsZero() {
+		out["last_reconcile"] = lastRun.UTC().Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// PUT /admin/connect/state/{name} {"state":"paused"}：直接设置期望状态并立即纠正一次
+func (s *Server) handleSetConnectState(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	req.State = strings.ToLower(strings.TrimSpace(req.State))
+	if !slices.Contains(desiredStates, req.State) {
+		writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown state %q", req.State), "states": desiredStates})
+		return
+	}
+	sc, ok := s.findSinkConfig(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("sink %q not configured", name)})
+		return
+	}
+	if p, err := s.sinkProvider(sc); err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	} else if _, ok := p.(*connectSink); !ok {
+		writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("sink %q is not a Kafka Connect connector", name)})
+		return
+	}
+	if err := s.desired.set(name, req.State, operatorIdentity(r)); err != nil {
+		writeJSON(w, 500, errorBody("connect-state", err))
+		return
+	}
+	s.logger.Printf("step=connect-state name=%s desired=%s operator=%s", name, req.State, operatorIdentity(r))
+	action, err := s.reconcileConnector(optionsContext(r), operatorIdentity(r), name)
+	it, _ := s.connectStateItem(r.Context(), sc)
+	out := map[string]any{"connector": it, "action": action}
+	if err != nil {
+		if e, ok := isNotManaged(err); ok {
+			writeNotManaged(w, "connect-state", e)
+			return
+		}
+		out["error"] = err.Error()
+		writeJSON(w, http.StatusBadGateway, out)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
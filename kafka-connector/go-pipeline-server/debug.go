@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+/************** 调试：pprof / expvar / runtime **************/
+
+var startedAt = time.Now()
+
+// 调试端点默认关闭；开启时必须配置 token（parseConfig 校验），请求需带 Authorization: Bearer <token>
+func (s *Server) debugAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg.Debug.Enabled {
+			http.NotFound(w, r)
+			return
+		}
+		tok := s.cfg.Debug.Token
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tok == "" || subtle.ConstantTimeCompare([]byte(got), []byte(tok)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) registerDebug(mux *http.ServeMux) {
+	// pprof 的 Index 依赖 /debug/pprof/ 前缀，这里先剥掉 /admin
+	pp := http.NewServeMux()
+	pp.HandleFunc("/debug/pprof/", pprof.Index)
+	pp.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	pp.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	pp.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	pp.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.Handle("/admin/debug/pprof/", s.debugAuth(http.StripPrefix("/admin", pp)))
+	expvar.Publish("http_client", expvar.Func(func() any { return s.clients.metrics.stats() }))
+	mux.Handle("GET /admin/debug/vars", s.debugAuth(expvar.Handler()))
+	mux.Handle("GET /admin/debug/runtime", s.debugAuth(http.HandlerFunc(s.handleRuntimeStats)))
+}
+
+func (s *Server) handleRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"go_version":     runtime.Version(),
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"memory": map[string]any{
+			"alloc_bytes":       ms.Alloc,
+			"total_alloc_bytes": ms.TotalAlloc,
+			"sys_bytes":         ms.Sys,
+			"heap_alloc_bytes":  ms.HeapAlloc,
+			"heap_inuse_bytes":  ms.HeapInuse,
+			"heap_objects":      ms.HeapObjects,
+			"stack_inuse_bytes": ms.StackInuse,
+		},
+		"gc": map[string]any{
+			"num_gc":          ms.NumGC,
+			"pause_total_ns":  ms.PauseTotalNs,
+			"last_gc":         time.Unix(0, int64(ms.LastGC)).Format(time.RFC3339Nano),
+			"gc_cpu_fraction": ms.GCCPUFraction,
+		},
+		"downstream_concurrency": s.limits.stats(),
+		"http_client":            s.clients.metrics.stats(),
+		"circuit_breakers":       s.breakers.stats(),
+		"proxies":                s.clients.proxyInfo(),
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+/************** 内嵌默认资产 **************/
+
+// es.files.ilm / template / pipeline 与主 sink 的 connect.files.sink 为空或文件不存在时，
+// 使用编译进二进制的默认文档（defaults/*.json），只配置 es / connect / kafka 地址与 names 即可跑通。
+// 默认文档不含环境相关的名字，下发前按配置填入：模板的 index_patterns / lifecycle / default_pipeline，
+// sink 的 name / topics / connection.url / ingest pipeline / data stream 映射 / DLQ topic。
+// 带 ?ref= 从 git 读取时不回退；资产版本历史中 source 记为 embedded:<kind>。
+
+//go:embed defaults/*.json
+var defaultAssetsFS embed.FS
+
+const embeddedAssetPrefix = "embedded:"
+
+// 读取资产：file 为空或不存在时回退到内嵌默认文档，返回内容与来源（用于日志与版本历史）
+func (s *Server) readAssetOrDefault(ctx context.Context, kind, file string) ([]byte, string, error) {
+	ref, _ := ctx.Value(assetRefKey{}).(string)
+	if file != "" || ref != "" {
+		b, err := s.readAsset(ctx, file)
+		if err == nil || ref != "" || !errors.Is(err, fs.ErrNotExist) {
+			return b, assetSource(ctx, file), err
+		}
+	}
+	b, err := s.defaultAsset(kind)
+	if err != nil {
+		return nil, "", err
+	}
+	if file != "" {
+		s.logger.Printf("step=%s file=%s missing, using embedded default", kind, file)
+	}
+	return b, embeddedAssetPrefix + kind, nil
+}
+
+// 不带 ctx 的场景（operator、probe 等）
+func (s *Server) readConfiguredAsset(kind, file string) ([]byte, error) {
+	b, _, err := s.readAssetOrDefault(context.Background(), kind, file)
+	return b, err
+}
+
+// 按当前配置填好名字的默认文档
+func (s *Server) defaultAsset(kind string) ([]byte, error) {
+	b, err := defaultAssetsFS.ReadFile("defaults/" + kind + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no embedded default for %s", kind)
+	}
+	switch kind {
+	case assetILM, assetPipeline:
+		return b, nil
+	case assetSink:
+		// 未配置 sink.elasticsearch 时即全部取默认值
+		return s.renderESSinkConnector(s.primarySinkConfig())
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("embedded default %s: %w", kind, err)
+	}
+	// 模板
+	names := s.cfg.ES.Names
+	if names.DataStream == "" {
+		return nil, fmt.Errorf("es.names.data_stream is required for the embedded index template")
+	}
+	doc["index_patterns"] = []string{s.dataStreamIndexPattern()}
+	tpl, _ := doc["template"].(map[string]any)
+	settings, _ := tpl["settings"].(map[string]any)
+	if names.ILMPolicy != "" {
+		settings["index.lifecycle.name"] = names.ILMPolicy
+	}
+	if names.Pipeline != "" {
+		settings["index.default_pipeline"] = names.Pipeline
+	}
+	return json.Marshal(doc)
+}
+
+// connect sink 的 connector 文档：配了 elasticsearch 段时按配置生成（essink.go），否则读文件；只有主 sink 回退到内嵌默认
+func (s *Server) readSinkAsset(ctx context.Context, sc SinkConfig) ([]byte, error) {
+	if sc.Elasticsearch.configured() {
+		return s.renderESSinkConnector(sc)
+	}
+	if sc.Name == s.primarySinkConfig().Name {
+		b, _, err := s.readAssetOrDefault(ctx, assetSink, sc.File)
+		if err != nil {
+			return nil, err
+		}
+		return s.alignSinkNames(b)
+	}
+	return s.readAsset(ctx, sc.File)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+/************** 降采样（downsampling）：TSDS 模板 + ILM downsample 动作 **************/
+
+// 只有 time series data stream（index.mode=time_series）的 backing index 才能被降采样，
+// 因此开启后同时改写模板（维度/指标字段）与 ILM 策略；均在下发时叠加，资产文件本身不变。
+type DownsampleConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Phase         string            `yaml:"phase"`          // warm | cold，默认 warm
+	After         string            `yaml:"after"`          // 进入该 phase 的 min_age，默认 7d
+	FixedInterval string            `yaml:"fixed_interval"` // 聚合粒度，默认 1h
+	Dimensions    []string          `yaml:"dimensions"`     // 维度字段（keyword），即 index.routing_path
+	Metrics       map[string]string `yaml:"metrics"`        // 指标字段 -> gauge | counter
+}
+
+func (d DownsampleConfig) phase() string {
+	if d.Phase == "cold" {
+		return "cold"
+	}
+	return "warm"
+}
+
+func (d DownsampleConfig) after() string {
+	if d.After == "" {
+		return "7d"
+	}
+	return d.After
+}
+
+func (d DownsampleConfig) fixedInterval() string {
+	if d.FixedInterval == "" {
+		return "1h"
+	}
+	return d.FixedInterval
+}
+
+// 在 ILM policy 上叠加 downsample 动作（仅 ES；OpenSearch 的 rollup 机制不同）
+func (s *Server) applyDownsampleOverlay(b []byte) ([]byte, []string, error) {
+	d := s.cfg.Downsample
+	if !d.Enabled {
+		return b, nil, nil
+	}
+	if s.isOpenSearch() {
+		return b, []string{"downsample ignored: not supported on opensearch"}, nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse ilm policy: %w", err)
+	}
+	policy, _ := doc["policy"].(map[string]any)
+	if policy == nil {
+		return nil, nil, fmt.Errorf("ilm policy has no \"policy\" object")
+	}
+	phases, _ := policy["phases"].(map[string]any)
+	if phases == nil {
+		phases = map[string]any{}
+		policy["phases"] = phases
+	}
+	phase := d.phase()
+	ph, _ := phases[phase].(map[string]any)
+	if ph == nil {
+		ph = map[string]any{"min_age": d.after()}
+		phases[phase] = ph
+	}
+	actions, _ := ph["actions"].(map[string]any)
+	if actions == nil {
+		actions = map[string]any{}
+		ph["actions"] = actions
+	}
+	actions["downsample"] = map[string]any{"fixed_interval": d.fixedInterval()}
+
+	var warnings []string
+	if del, ok := phases["delete"].(map[string]any); ok {
+		delAge, _ := del["min_age"].(string)
+		phAge, _ := ph["min_age"].(string)
+		d1, ok1 := parseESDuration(delAge)
+		d2, ok2 := parseESDuration(phAge)
+		if ok1 && ok2 && d1 <= d2 {
+			warnings = append(warnings, fmt.Sprintf("delete phase min_age=%s is not later than %s phase min_age=%s; data is deleted before downsampling", delAge, phase, phAge))
+		}
+	}
+	out, err := json.Marshal(doc)
+	return out, warnings, err
+}
+
+// 把模板改为 TSDS：index.mode=time_series、routing_path，并标注维度/指标字段
+func (s *Server) applyDownsampleTemplate(b []byte) ([]byte, error) {
+	d := s.cfg.Downsample
+	if !d.Enabled || s.isOpenSearch() {
+		return b, nil
+	}
+	if len(d.Dimensions) == 0 {
+		return nil, fmt.Errorf("downsample.dimensions is required: a time series data stream needs at least one dimension field")
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse index template: %w", err)
+	}
+	tpl, _ := doc["template"].(map[string]any)
+	if tpl == nil {
+		tpl = map[string]any{}
+		doc["template"] = tpl
+	}
+	settings, _ := tpl["settings"].(map[string]any)
+	if settings == nil {
+		settings = map[string]any{}
+		tpl["settings"] = settings
+	}
+	settings["index.mode"] = "time_series"
+	settings["index.routing_path"] = d.Dimensions
+
+	mappings, _ := tpl["mappings"].(map[string]any)
+	if mappings == nil {
+		mappings = map[string]any{}
+		tpl["mappings"] = mappings
+	}
+	props, _ := mappings["properties"].(map[string]any)
+	if props == nil {
+		props = map[string]any{}
+		mappings["properties"] = props
+	}
+	for _, f := range d.Dimensions {
+		m, _ := props[f].(map[string]any)
+		if m == nil {
+			m = map[string]any{"type": "keyword"}
+			props[f] = m
+		}
+		if t, _ := m["type"].(string); t != "keyword" {
+			return nil, fmt.Errorf("downsample dimension %q must be a keyword field, got %q", f, t)
+		}
+		m["time_series_dimension"] = true
+	}
+	for f, kind := range d.Metrics {
+		if kind != "gauge" && kind != "counter" {
+			return nil, fmt.Errorf("downsample metric %q: type must be gauge or counter, got %q", f, kind)
+		}
+		m, _ := props[f].(map[string]any)
+		if m == nil {
+			return nil, fmt.Errorf("downsample metric %q is not mapped in the index template", f)
+		}
+		switch t, _ := m["type"].(string); t {
+		case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		default:
+			return nil, fmt.Errorf("downsample metric %q must be numeric, got %q", f, t)
+		}
+		m["time_series_metric"] = kind
+	}
+	return json.Marshal(doc)
+}
+
+// 重新下发模板与 ILM（叠加降采样配置）；?rollover=true 时立即滚动，使新的写索引成为 TSDS
+func (s *Server) handlePutDownsample(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Downsample.Enabled {
+		writeJSON(w, 400, map[string]string{"error": "downsample.enabled is false"})
+		return
+	}
+	if s.isOpenSearch() {
+		writeJSON(w, 400, map[string]string{"error": "downsampling is only supported on elasticsearch"})
+		return
+	}
+	out := map[string]any{"step": "downsample"}
+	code := http.StatusOK
+	for _, step := range []struct {
+		name string
+		h    http.HandlerFunc
+	}{{"template", s.handlePutTemplate}, {"ilm", s.handlePutILM}} {
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		step.h(cw, r)
+		out[step.name] = jsonRaw([]byte(cw.body))
+		if cw.status >= 400 {
+			code = cw.status
+			writeJSON(w, code, out)
+			return
+		}
+	}
+	if r.URL.Query().Get("rollover") == "true" {
+		url := fmt.Sprintf("%s/%s/_rollover", s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+		resp, body, err := s.doPOST(r.Context(), url, nil, "es")
+		if err != nil {
+			out["rollover"] = map[string]string{"error": err.Error()}
+			writeJSON(w, http.StatusBadGateway, out)
+			return
+		}
+		out["rollover"] = jsonRaw(body)
+		code = resp.StatusCode
+	} else {
+		out["note"] = "the data stream becomes a time series data stream at its next rollover"
+	}
+	writeJSON(w, code, out)
+}
+
+type downsampleCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// 检查线上策略含 downsample 动作、模板为 TSDS、当前写索引为 time_series，并列出已降采样的 backing index
+func (s *Server) handleVerifyDownsample(w http.ResponseWriter, r *http.Request) {
+	d := s.cfg.Downsample
+	if !d.Enabled {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "downsample.enabled is false"})
+		return
+	}
+	if s.isOpenSearch() {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "downsampling is only supported on elasticsearch"})
+		return
+	}
+	ctx := r.Context()
+	checks := map[string]downsampleCheck{}
+
+	// 1) ILM 策略
+	resp, body, err := s.doGET(ctx, s.lifecyclePolicyURL(), "es")
+	if err != nil {
+		writeJSON(w, 500, errorBody("verify-downsample", err))
+		return
+	}
+	var policies map[string]struct {
+		Policy struct {
+			Phases map[string]struct {
+				MinAge  string                    `json:"min_age"`
+				Actions map[string]map[string]any `json:"actions"`
+			} `json:"phases"`
+		} `json:"policy"`
+	}
+	c := downsampleCheck{Detail: "policy not found"}
+	if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &policies) == nil {
+		c.Detail = fmt.Sprintf("no downsample action in %s phase", d.phase())
+		if p, ok := policies[s.cfg.ES.Names.ILMPolicy]; ok {
+			if ds, ok := p.Policy.Phases[d.phase()].Actions["downsample"]; ok {
+				got := fmt.Sprint(ds["fixed_interval"])
+				c.OK = got == d.fixedInterval()
+				c.Detail = fmt.Sprintf("phase=%s min_age=%s fixed_interval=%s", d.phase(), p.Policy.Phases[d.phase()].MinAge, got)
+			}
+		}
+	}
+	checks["ilm"] = c
+
+	// 2) 索引模板
+	resp, body, err = s.doGET(ctx, fmt.Sprintf("%s/_index_template/%s", s.cfg.ES.Host, s.cfg.ES.Names.IndexTemplate), "es")
+	if err != nil {
+		writeJSON(w, 500, errorBody("verify-downsample", err))
+		return
+	}
+	var tpls struct {
+		IndexTemplates []struct {
+			IndexTemplate struct {
+				Template struct {
+					Settings struct {
+						Index struct {
+							Mode        string   `json:"mode"`
+							RoutingPath []string `json:"routing_path"`
+						} `json:"index"`
+					} `json:"settings"`
+				} `json:"template"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	c = downsampleCheck{Detail: "template not found"}
+	if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &tpls) == nil && len(tpls.IndexTemplates) > 0 {
+		idx := tpls.IndexTemplates[0].IndexTemplate.Template.Settings.Index
+		c.OK = idx.Mode == "time_series" && len(idx.RoutingPath) > 0
+		c.Detail = fmt.Sprintf("index.mode=%q routing_path=%v", idx.Mode, idx.RoutingPath)
+	}
+	checks["template"] = c
+
+	// 3) 当前写索引与已降采样的 backing index
+	backing, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		checks["write_index"] = downsampleCheck{Detail: err.Error()}
+	} else {
+		write := backing[len(backing)-1]
+		c = downsampleCheck{Detail: write}
+		resp, body, err = s.doGET(ctx, fmt.Sprintf("%s/%s/_settings/index.mode", s.cfg.ES.Host, write), "es")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			var st map[string]struct {
+				Settings struct {
+					Index struct {
+						Mode string `json:"mode"`
+					} `json:"index"`
+				} `json:"settings"`
+			}
+			if json.Unmarshal(body, &st) == nil {
+				mode := st[write].Settings.Index.Mode
+				c.OK = mode == "time_series"
+				c.Detail = fmt.Sprintf("%s index.mode=%q", write, mode)
+			}
+		}
+		checks["write_index"] = c
+	}
+	downsampled := []string{}
+	for _, idx := range backing {
+		if strings.HasPrefix(idx, "downsample-") {
+			downsampled = append(downsampled, idx)
+		}
+	}
+
+	failed := []string{}
+	for name, c := range checks {
+		if !c.OK {
+			failed = append(failed, name)
+		}
+	}
+	slices.Sort(failed)
+	code := http.StatusOK
+	if len(failed) > 0 {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, map[string]any{
+		"ok":             len(failed) == 0,
+		"failed":         failed,
+		"checks":         checks,
+		"phase":          d.phase(),
+		"after":          d.after(),
+		"fixed_interval": d.fixedInterval(),
+		"downsampled":    downsampled,
+	})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/************** ECS 字段映射检查 **************/
+
+// Kibana 的 Logs / Observability / SIEM 等应用按 ECS（Elastic Common Schema）字段名与类型查询，
+// 模板 mappings 与 ECS 不一致时数据写得进去但在这些应用里看不到或无法聚合。
+// 对照内嵌的 ECS 字段参考（ecs/fields.json，常用字段集的子集）检查模板 mappings.properties：
+//   - ECS 字段的类型不一致（按类型族比较，keyword / constant_keyword / wildcard 视为一致，text / match_only_text 同理）
+//   - ECS 对象（host、log 等）被映射为叶子字段，如 host: keyword
+//   - ECS 字段集下不在参考中的字段（可能拼错，或属于参考未收录的 ECS 字段，可加入 ecs.ignore）
+//   - 自定义顶层字段使用了常见的非 ECS 名字（timestamp、level、hostname 等），给出对应的 ECS 字段
+// ecs.mode=warn（默认）时模板下发响应与 assets/lint 中给出警告；block 时不通过返回 422，lint 中为 error；off 关闭。
+// 只检查模板自身的 mappings，不展开 composed_of 组件模板与 dynamic_templates。
+// GET /admin/assets/ecs[?ref=] 返回检查结果。
+
+const (
+	ecsModeOff   = "off"
+	ecsModeWarn  = "warn"
+	ecsModeBlock = "block"
+)
+
+type ECSConfig struct {
+	Mode   string   `yaml:"mode"`   // off | warn | block，默认 warn
+	Ignore []string `yaml:"ignore"` // 不检查的字段，如 ["app", "kubernetes.labels"]（含其子字段）
+}
+
+func (c ECSConfig) mode() string {
+	if c.Mode == "" {
+		return ecsModeWarn
+	}
+	return c.Mode
+}
+
+func (c ECSConfig) validate() error {
+	switch c.mode() {
+	case ecsModeOff, ecsModeWarn, ecsModeBlock:
+		return nil
+	}
+	return fmt.Errorf("ecs.mode must be off, warn or block, got %q", c.Mode)
+}
+
+func (c ECSConfig) ignored(field string) bool {
+	for _, p := range c.Ignore {
+		if field == p || strings.HasPrefix(field, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed ecs/fields.json
+var ecsFieldsJSON []byte
+
+type ecsReference struct {
+	Version string            `json:"version"`
+	Fields  map[string]string `json:"fields"`  // 字段路径 -> ECS 类型
+	Aliases map[string]string `json:"aliases"` // 常见的非 ECS 名字 -> ECS 字段
+
+	objects map[string]bool // ECS 对象路径，如 host、host.os
+}
+
+var loadECSReference = sync.OnceValue(func() *ecsReference {
+	ref := &ecsReference{}
+	if err := json.Unmarshal(ecsFieldsJSON, ref); err != nil {
+		panic(fmt.Sprintf("ecs/fields.json: %v", err))
+	}
+	ref.objects = map[string]bool{}
+	for f := range ref.Fields {
+		for i := range len(f) {
+			if f[i] == '.' {
+				ref.objects[f[:i]] = true
+			}
+		}
+	}
+	return ref
+})
+
+// 类型族：同族内视为与 ECS 一致
+func ecsTypeFamily(t string) string {
+	switch t {
+	case "keyword", "constant_keyword", "wildcard":
+		return "keyword"
+	case "text", "match_only_text":
+		return "text"
+	case "long", "integer", "short", "byte", "unsigned_long":
+		return "integer"
+	case "float", "double", "half_float", "scaled_float":
+		return "float"
+	case "", "object":
+		return "object"
+	}
+	return t
+}
+
+type ecsIssue struct {
+	Field    string `json:"field"`
+	Type     string `json:"type"`
+	Expected string `json:"expected,omitempty"` // ECS 类型
+	Suggest  string `json:"suggest,omitempty"`  // 建议使用的 ECS 字段
+	Message  string `json:"message"`
+}
+
+// 展开 mappings.properties 为 字段路径 -> 类型（对象为 object）；属性名可带点，多字段（fields）与 alias 不检查
+func flattenMappingTypes(prefix string, props map[string]any, out map[string]string) {
+	for name, v := range props {
+		m, _ := v.(map[string]any)
+		p := name
+		if prefix != "" {
+			p = prefix + "." + name
+		}
+		typ, _ := m["type"].(string)
+		if typ == "alias" {
+			continue
+		}
+		if typ == "" {
+			typ = "object"
+		}
+		out[p] = typ
+		// 带点的属性名隐含父对象
+		for i := strings.LastIndexByte(p, '.'); i > len(prefix); i = strings.LastIndexByte(p[:i], '.') {
+			if _, ok := out[p[:i]]; !ok {
+				out[p[:i]] = "object"
+			}
+		}
+		if sub, ok := m["properties"].(map[string]any); ok && (typ == "object" || typ == "nested") {
+			flattenMappingTypes(p, sub, out)
+		}
+	}
+}
+
+// 检查模板文档的 mappings；解析失败交给后续步骤报告
+func (c ECSConfig) checkTemplate(doc map[string]any) []ecsIssue {
+	if c.mode() == ecsModeOff {
+		return nil
+	}
+	tpl, _ := doc["template"].(map[string]any)
+	mappings, _ := tpl["mappings"].(map[string]any)
+	props, _ := mappings["properties"].(map[string]any)
+	fields := map[string]string{}
+	flattenMappingTypes("", props, fields)
+
+	ref := loadECSReference()
+	var issues []ecsIssue
+	for f, typ := range fields {
+		if c.ignored(f) {
+			continue
+		}
+		top, _, _ := strings.Cut(f, ".")
+		want, isField := ref.Fields[f]
+		switch {
+		case isField:
+			if ecsTypeFamily(typ) != ecsTypeFamily(want) {
+				issues = append(issues, ecsIssue{Field: f, Type: typ, Expected: want,
+					Message: fmt.Sprintf("%s is mapped as %s; ECS %s defines it as %s", f, typ, ref.Version, want)})
+			}
+		case ref.objects[f]:
+			if ecsTypeFamily(typ) != "object" {
+				is := ecsIssue{Field: f, Type: typ, Expected: "object", Suggest: ref.Aliases[f],
+					Message: fmt.Sprintf("%s is mapped as %s but is an ECS object field set", f, typ)}
+				if is.Suggest != "" {
+					is.Message += "; use " + is.Suggest
+				}
+				issues = append(issues, is)
+			}
+		case ref.objects[top]:
+			issues = append(issues, ecsIssue{Field: f, Type: typ,
+				Message: fmt.Sprintf("%s is under the ECS field set %s but is not in the ECS %s reference; check the spelling or add it to ecs.ignore", f, top, ref.Version)})
+		default:
+			if alt := ref.Aliases[f]; alt != "" {
+				issues = append(issues, ecsIssue{Field: f, Type: typ, Expected: ref.Fields[alt], Suggest: alt,
+					Message: fmt.Sprintf("%s is not an ECS field name; use %s", f, alt)})
+			}
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Field < issues[j].Field })
+	return issues
+}
+
+func (s *Server) checkECS(b []byte) []ecsIssue {
+	var doc map[string]any
+	if json.Unmarshal(b, &doc) != nil {
+		return nil
+	}
+	return s.cfg.ECS.checkTemplate(doc)
+}
+
+func ecsMessages(issues []ecsIssue) []string {
+	out := make([]string, 0, len(issues))
+	for _, is := range issues {
+		out = append(out, is.Message)
+	}
+	return out
+}
+
+func writeECSError(w http.ResponseWriter, step string, issues []ecsIssue) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+		"step":   step,
+		"error":  fmt.Sprintf("index template mappings are not ECS compliant (%d issue(s), ecs.mode=block)", len(issues)),
+		"issues": issues,
+	})
+}
+
+// GET /admin/assets/ecs[?ref=]
+func (s *Server) handleECSReport(w http.ResponseWriter, r *http.Request) {
+	b, source, err := s.readAssetOrDefault(optionsContext(r), assetTemplate, s.cfg.ES.Files.Template)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		writeJSON(w, 400, map[string]string{"error": "parse template: " + err.Error()})
+		return
+	}
+	issues := s.cfg.ECS.checkTemplate(doc)
+	if issues == nil {
+		issues = []ecsIssue{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":          len(issues) == 0,
+		"mode":        s.cfg.ECS.mode(),
+		"ecs_version": loadECSReference().Version,
+		"source":      source,
+		"issues":      issues,
+	})
+}
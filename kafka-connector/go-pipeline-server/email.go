@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/************** 邮件通知（SMTP） **************/
+
+const notifyEmail = "email"
+
+const (
+	defaultEmailSubject = `[{{.Severity}}] {{.Title}}`
+	defaultEmailBody    = `{{.Title}}
+
+{{.Text}}
+{{range $k, $v := .Fields}}
+- {{$k}}: {{$v}}{{end}}
+
+event: {{.Event}}
+time:  {{.At.Format "2006-01-02 15:04:05 MST"}}
+`
+)
+
+type SMTPConfig struct {
+	Host            string   `yaml:"host"`
+	Port            int      `yaml:"port"` // 默认 tls=465，其余 587
+	Username        string   `yaml:"username"`
+	Password        string   `yaml:"password"`
+	From            string   `yaml:"from"`
+	To              []string `yaml:"to"`
+	TLS             string   `yaml:"tls"` // starttls（默认）| tls（隐式 TLS）| none
+	InsecureSkip    bool     `yaml:"insecure_skip_verify"`
+	SubjectTemplate string   `yaml:"subject_template"` // text/template，字段同通知：.Event .Severity .Title .Text .Fields .At
+	BodyTemplate    string   `yaml:"body_template"`
+}
+
+func renderNotificationTemplate(name, tpl, def string, n notification) (string, error) {
+	if tpl == "" {
+		tpl = def
+	}
+	t, err := template.New(name).Parse(tpl)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, n); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+func buildEmail(c SMTPConfig, subject, body string, attachments []notificationAttachment) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if len(attachments) == 0 {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+		b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+		return b.Bytes()
+	}
+	// 带附件：multipart/mixed，正文 8bit，附件 base64
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	pw, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	_, _ = pw.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	for _, a := range attachments {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		enc := base64.StdEncoding.EncodeToString([]byte(a.Content))
+		for len(enc) > 76 {
+			_, _ = pw.Write([]byte(enc[:76] + "\r\n"))
+			enc = enc[76:]
+		}
+		_, _ = pw.Write([]byte(enc + "\r\n"))
+	}
+	_ = mw.Close()
+	return b.Bytes()
+}
+
+func (s *Server) sendEmail(ctx context.Context, t NotificationTarget, n notification) error {
+	c := t.SMTP
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return fmt.Errorf("%s: smtp.host, smtp.from and smtp.to are required", t.Name)
+	}
+	subject, err := renderNotificationTemplate("subject_template", c.SubjectTemplate, defaultEmailSubject, n)
+	if err != nil {
+		return err
+	}
+	body, err := renderNotificationTemplate("body_template", c.BodyTemplate, defaultEmailBody, n)
+	if err != nil {
+		return err
+	}
+
+	mode := strings.ToLower(c.TLS)
+	port := c.Port
+	if port == 0 {
+		port = 587
+		if mode == "tls" {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+	tlsCfg := &tls.Config{ServerName: c.Host, InsecureSkipVerify: c.InsecureSkip, MinVersion: tls.VersionTLS12} //nolint:gosec
+
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if mode == "tls" {
+		conn, err = tls.DialWithDialer(d, "tcp", addr, tlsCfg)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	cl, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer cl.Close()
+
+	if mode == "" || mode == "starttls" {
+		if ok, _ := cl.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s: server does not support STARTTLS (set smtp.tls: none to send in plaintext)", t.Name)
+		}
+		if err := cl.StartTLS(tlsCfg); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		if err := cl.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return fmt.Errorf("%s: smtp.from: %w", t.Name, err)
+	}
+	if err := cl.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range c.To {
+		if err := cl.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	wc, err := cl.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(buildEmail(c, subject, body, n.Attachments)); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	s.logger.Printf("notify target=%s smtp=%s rcpt=%d", t.Name, addr, len(c.To))
+	return cl.Quit()
+}
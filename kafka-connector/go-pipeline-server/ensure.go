@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/************** ensure 语义：重复执行创建类接口时返回 created/updated/unchanged **************/
+
+const (
+	ensureCreated   = "created"
+	ensureUpdated   = "updated"
+	ensureUnchanged = "unchanged"
+	ensureConflict  = "conflict" // 已存在但与期望不一致且无法原地更新
+)
+
+// ES 的 resource_already_exists_exception
+func esAlreadyExists(body []byte) bool {
+	var e struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	return json.Unmarshal(body, &e) == nil && e.Error.Type == "resource_already_exists_exception"
+}
+
+// Connect 的 409 也可能是 rebalance 进行中，需按消息区分
+func connectAlreadyExists(code int, body []byte) bool {
+	return code == http.StatusConflict && bytes.Contains(bytes.ToLower(body), []byte("already exists"))
+}
+
+type ensureDiff struct {
+	Field    string `json:"field"`
+	Expected any    `json:"expected"`
+	Actual   any    `json:"actual"`
+}
+
+// data stream 已存在时，核对其匹配的 index template 与 ILM policy
+func (s *Server) verifyExistingDataStream(ctx context.Context) ([]ensureDiff, error) {
+	url := fmt.Sprintf("%s/_data_stream/%s", s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+	resp, body, err := s.doGET(ctx, url, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get data stream returned %s", resp.Status)
+	}
+	var doc struct {
+		DataStreams []struct {
+			Template  string `json:"template"`
+			ILMPolicy string `json:"ilm_policy"`
+		} `json:"data_streams"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || len(doc.DataStreams) == 0 {
+		return nil, fmt.Errorf("unexpected data stream response: %s", strings.TrimSpace(string(body)))
+	}
+	ds := doc.DataStreams[0]
+	diffs := []ensureDiff{}
+	if want := s.cfg.ES.Names.IndexTemplate; want != "" && ds.Template != want {
+		diffs = append(diffs, ensureDiff{Field: "template", Expected: want, Actual: ds.Template})
+	}
+	// OpenSearch 的 ISM 不体现在 data stream 上
+	if want := s.cfg.ES.Names.ILMPolicy; want != "" && !s.isOpenSearch() && ds.ILMPolicy != want {
+		diffs = append(diffs, ensureDiff{Field: "ilm_policy", Expected: want, Actual: ds.ILMPolicy})
+	}
+	return diffs, nil
+}
+
+// 比较期望的 connector config 与 Connect 上的现有配置。
+// 现有配置经过脱敏，期望值也按同样规则脱敏后再比较，因此密钥变更无法在此识别。
+func (s *Server) connectorConfigDiff(want, have map[string]string) []ensureDiff {
+	wb, _ := json.Marshal(want)
+	var redacted map[string]string
+	if err := json.Unmarshal(s.redact.JSON(wb), &redacted); err != nil {
+		redacted = want
+	}
+	keys := map[string]bool{}
+	for k := range redacted {
+		keys[k] = true
+	}
+	for k := range have {
+		keys[k] = true
+	}
+	diffs := []ensureDiff{}
+	for k := range keys {
+		if k == "name" {
+			continue
+		}
+		w, wok := redacted[k]
+		h, hok := have[k]
+		if wok != hok || w != h {
+			d := ensureDiff{Field: k}
+			if wok {
+				d.Expected = w
+			}
+			if hok {
+				d.Actual = h
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	sort.Slice(diffs, func(i, k int) bool { return diffs[i].Field < diffs[k].Field })
+	return diffs
+}
+
+// connector 已存在：一致则 unchanged，不一致则 PUT /config 更新
+func (c *connectSink) ensureExisting(ctx context.Context, desired []byte) (*sinkResponse, error) {
+	var doc struct {
+		Config map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(desired, &doc); err != nil {
+		return nil, &sinkInputError{err}
+	}
+	resp, body, err := c.s.doGET(ctx, c.connectorURL("/config"), "connect")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return toSinkResponse(resp, body), nil
+	}
+	var have map[string]string
+	if err := json.Unmarshal(body, &have); err != nil {
+		return nil, fmt.Errorf("decode connector config: %w", err)
+	}
+	diffs := c.s.connectorConfigDiff(doc.Config, have)
+	if len(diffs) == 0 {
+		c.s.logger.Printf("step=sink ensure=unchanged name=%s", c.name)
+		out, _ := json.Marshal(map[string]any{"name": c.name, "config": have})
+		return &sinkResponse{Code: http.StatusOK, Status: "200 OK", Body: out, Result: ensureUnchanged}, nil
+	}
+
+	if err := c.checkOwner(ctx, have); err != nil {
+		return nil, err
+	}
+	cfg, _ := json.Marshal(doc.Config)
+	c.s.logger.Printf("step=sink ensure=update name=%s diffs=%d", c.name, len(diffs))
+	resp, body, err = c.s.doPUT(ctx, c.connectorURL("/config"), cfg, "connect")
+	if err != nil {
+		return nil, err
+	}
+	res := toSinkResponse(resp, body)
+	if resp.StatusCode < 300 {
+		res.Result = ensureUpdated
+	}
+	res.Diffs = diffs
+	return res, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/************** 环境变量 / 命令行覆盖配置 **************/
+
+// 每个配置项都可以用环境变量覆盖，变量名由 yaml 路径生成：LOGPIPE_ + 路径大写、"." 换成 "_"，
+// 如 es.host -> LOGPIPE_ES_HOST，es.names.data_stream -> LOGPIPE_ES_NAMES_DATA_STREAM。
+// 取值：字符串原样使用；字符串列表可写逗号分隔（a,b,c）；其余类型（bool / 数字 / map / 结构体列表）按 YAML 解析，
+// 因此也可直接写 JSON，如 LOGPIPE_SINKS='[{"name":"s1","type":"loki"}]'。整段结构体也可用一个变量给出（LOGPIPE_ES='{...}'），
+// 此时不再看其下的子项变量。
+// 覆盖在 yaml 解析之后、校验之前进行，KV 配置源热加载时同样生效。未知的 LOGPIPE_ 变量视为拼写错误，拒绝启动。
+// 配置文件不存在但设置了 LOGPIPE_ 变量（或 -set）时按空配置处理，容器部署可以完全不挂载配置文件。
+
+const envConfigPrefix = "LOGPIPE_"
+
+// yaml 路径 -> 环境变量名
+func envConfigName(path string) string {
+	return envConfigPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// 当前进程中的 LOGPIPE_ 变量
+func envConfigVars(environ []string) map[string]string {
+	out := map[string]string{}
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(k, envConfigPrefix) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// 把 environ 中的 LOGPIPE_ 变量写入 cfg，返回生效的 yaml 路径（已排序）
+func applyEnvOverrides(cfg *Config, environ []string) ([]string, error) {
+	vars := envConfigVars(environ)
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	used := map[string]bool{}
+	var applied []string
+	err := overrideConfig(reflect.ValueOf(cfg).Elem(), "", func(p string) (string, string, bool) {
+		key := envConfigName(p)
+		val, ok := vars[key]
+		if ok {
+			used[key] = true
+		}
+		return key, val, ok
+	}, &applied)
+	if err != nil {
+		return nil, err
+	}
+	if unknown := unusedKeys(vars, used); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown config environment variable(s): %s", strings.Join(unknown, ", "))
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
+
+func unusedKeys(m map[string]string, used map[string]bool) []string {
+	var out []string
+	for k := range m {
+		if !used[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// 按 yaml 路径遍历 Config，lookup 返回 (用于报错的名字, 值, 是否设置)
+func overrideConfig(v reflect.Value, path string, lookup func(p string) (string, string, bool), applied *[]string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		p := name
+		if path != "" {
+			p = path + "." + name
+		}
+		if key, val, ok := lookup(p); ok {
+			if err := setConfigValue(v.Field(i), val); err != nil {
+				if key != p {
+					key += " (" + p + ")"
+				}
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*applied = append(*applied, p)
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			if err := overrideConfig(v.Field(i), p, lookup, applied); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setConfigValue(field reflect.Value, val string) error {
+	val = strings.TrimSpace(val)
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(val)
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(val, "["):
+		items := []string{}
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+		return nil
+	}
+	// 解到新值再整体替换：列表 / map 是覆盖而不是与配置文件中的值合并
+	nv := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(val), nv.Interface()); err != nil {
+		return fmt.Errorf("expected %s: %w", field.Type(), err)
+	}
+	field.Set(nv.Elem())
+	return nil
+}
+
+// 设置了任何 LOGPIPE_ 变量或 -set（无配置文件启动）
+func envConfigPresent() bool {
+	return len(envConfigVars(os.Environ())) > 0 || len(configSetOverrides) > 0
+}
+
+/************** 命令行 -set 覆盖 **************/
+
+// -set es.host=https://... -set connect.names.sink=foo：可重复，key 为 yaml 路径，取值规则同环境变量；
+// 优先级：配置文件 < LOGPIPE_ 环境变量 < -set。用于临时运行与 CI，热加载时同样生效。
+
+type setFlags []string
+
+func (f *setFlags) String() string { return strings.Join(*f, " ") }
+
+func (f *setFlags) Set(v string) error {
+	if k, _, ok := strings.Cut(v, "="); !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// main 中 flag.Parse 后赋值
+var configSetOverrides setFlags
+
+func applySetOverrides(cfg *Config, sets []string) ([]string, error) {
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	vals := map[string]string{}
+	for _, kv := range sets {
+		k, v, _ := strings.Cut(kv, "=")
+		vals[strings.TrimSpace(k)] = v // 同一 key 后者生效
+	}
+	used := map[string]bool{}
+	var applied []string
+	err := overrideConfig(reflect.ValueOf(cfg).Elem(), "", func(p string) (string, string, bool) {
+		val, ok := vals[p]
+		if ok {
+			used[p] = true
+		}
+		return p, val, ok
+	}, &applied)
+	if err != nil {
+		return nil, fmt.Errorf("-set %w", err)
+	}
+	if unknown := unusedKeys(vals, used); len(unknown) > 0 {
+		return nil, fmt.Errorf("-set: unknown config key(s): %s", strings.Join(unknown, ", "))
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/************** 由配置生成 ES sink connector **************/
+
+// connect 类型的 sink 配了 elasticsearch 段时，不再读 sink JSON 文件（主 sink 的 connect.files.sink 忽略），
+// 以内嵌的 defaults/sink.json 为底按下面的字段生成 ElasticsearchSinkConnector 配置；未列出的属性写在 extra 中原样透传。
+// 主 sink 未配置该段且文件缺失时，也是用这里以默认值生成（见 defaults.go）。
+
+type ESSinkSettings struct {
+	URL            string `yaml:"url"`              // 默认 es.host
+	Username       string `yaml:"username"`         // 默认 es.username
+	Password       string `yaml:"password"`         // 默认 es.password
+	DataStream     string `yaml:"data_stream"`      // 写入的 data stream；主 sink 默认 es.names.data_stream
+	Pipeline       string `yaml:"pipeline"`         // ingest pipeline，默认 es.names.pipeline；"_none" 不使用
+	TasksMax       int    `yaml:"tasks_max"`        // 默认 2
+	BatchSize      int    `yaml:"batch_size"`       // 默认 2000
+	FlushTimeoutMs int64  `yaml:"flush_timeout_ms"` // connector 默认 180000
+	LingerMs       int64  `yaml:"linger_ms"`
+	MaxRetries     int    `yaml:"max_retries"`      // 默认 10
+	RetryBackoffMs int64  `yaml:"retry_backoff_ms"` // 默认 5000
+	DLQ            struct {
+		Topic             string `yaml:"topic"` // 默认 dlq.<第一个 topic>
+		Disabled          bool   `yaml:"disabled"`
+		ReplicationFactor int    `yaml:"replication_factor"` // 默认 1
+		Partitions        int    `yaml:"partitions"`         // 默认 1
+	} `yaml:"dlq"`
+	Extra map[string]string `yaml:"extra"` // 原样透传的额外属性，覆盖生成的同名属性
+}
+
+func (c ESSinkSettings) configured() bool { return !reflect.ValueOf(c).IsZero() }
+
+func (s *Server) renderESSinkConnector(sc SinkConfig) ([]byte, error) {
+	b, err := defaultAssetsFS.ReadFile("defaults/sink.json")
+	if err != nil {
+		return nil, fmt.Errorf("no embedded default for %s", assetSink)
+	}
+	var doc struct {
+		Name   string            `json:"name"`
+		Config map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("embedded default %s: %w", assetSink, err)
+	}
+	c := sc.Elasticsearch
+	primary := sc.Name == s.primarySinkConfig().Name
+	orStr := func(v, def string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	}
+	topics := sc.Topics
+	ds := c.DataStream
+	if primary {
+		if len(topics) == 0 && s.cfg.Kafka.Topic != "" {
+			topics = []string{s.cfg.Kafka.Topic}
+		}
+		ds = orStr(ds, s.cfg.ES.Names.DataStream)
+	}
+	switch {
+	case sc.Name == "":
+		return nil, fmt.Errorf("connect.names.sink is required for the generated sink connector")
+	case len(topics) == 0:
+		return nil, fmt.Errorf("sink %s: topics (or kafka.topic for the primary sink) are required", sc.Name)
+	case ds == "":
+		return nil, fmt.Errorf("sink %s: elasticsearch.data_stream (or es.names.data_stream for the primary sink) is required", sc.Name)
+	}
+
+	doc.Name = sc.Name
+	cfg := doc.Config
+	cfg["topics"] = strings.Join(topics, ",")
+	cfg["connection.url"] = orStr(c.URL, s.cfg.ES.Host)
+	if user := orStr(c.Username, s.cfg.ES.Username); user != "" {
+		cfg["connection.username"] = user
+		cfg["connection.password"] = orStr(c.Password, s.cfg.ES.Password)
+	}
+	if pipeline := orStr(c.Pipeline, s.cfg.ES.Names.Pipeline); pipeline != "" && pipeline != "_none" {
+		cfg["ingest.pipeline.name"] = pipeline
+	} else {
+		cfg["use.ingest.pipeline"] = "false"
+	}
+	mapping := make([]string, 0, len(topics))
+	for _, t := range topics {
+		mapping = append(mapping, t+":"+ds)
+	}
+	cfg["topic.to.external.resource.mapping"] = strings.Join(mapping, ",")
+
+	setInt := func(key string, v int64) {
+		if v > 0 {
+			cfg[key] = strconv.FormatInt(v, 10)
+		}
+	}
+	setInt("tasks.max", int64(c.TasksMax))
+	setInt("batch.size", int64(c.BatchSize))
+	setInt("flush.timeout.ms", c.FlushTimeoutMs)
+	setInt("linger.ms", c.LingerMs)
+	setInt("max.retries", int64(c.MaxRetries))
+	setInt("retry.backoff.ms", c.RetryBackoffMs)
+
+	if c.DLQ.Disabled {
+		for k := range cfg {
+			if strings.HasPrefix(k, "errors.deadletterqueue.") {
+				delete(cfg, k)
+			}
+		}
+	} else {
+		cfg["errors.deadletterqueue.topic.name"] = orStr(c.DLQ.Topic, "dlq."+topics[0])
+		setInt("errors.deadletterqueue.topic.replication.factor", int64(c.DLQ.ReplicationFactor))
+		setInt("errors.deadletterqueue.topic.partitions", int64(c.DLQ.Partitions))
+	}
+	for k, v := range c.Extra {
+		cfg[k] = v
+	}
+	return json.Marshal(doc)
+}
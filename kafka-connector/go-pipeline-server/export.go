@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/************** 导出部署清单（docker-compose / Kubernetes） **************/
+
+// GET /admin/export/manifests?format=compose|k8s 按当前配置渲染整条流水线：
+//   topic-init  创建日志 topic 与 DLQ topic（已存在则跳过）
+//   connect     Kafka Connect worker，按 sink 的 connector.class 安装插件
+//   admin       本服务，config.yaml 与资产文件（ILM / 模板 / pipeline / sink JSON）随清单下发
+//   provision   admin 就绪后依次调用下发接口（ILM → 模板 → pipeline → data stream → sink）
+// 可选参数：image（本服务镜像）、namespace（k8s）、partitions、replication_factor、?ref（资产取自 git ref）。
+// 导出内容中的密码 / token 等按 redact.keys 替换为 ********，部署前需填写。
+
+const (
+	exportName         = "log-pipeline"
+	exportAdminImage   = "log-pipeline-admin:latest"
+	exportConnectImage = "confluentinc/cp-kafka-connect:7.6.1"
+	exportKafkaImage   = "confluentinc/cp-kafka:7.6.1"
+	exportCurlImage    = "curlimages/curl:8.10.1"
+
+	exportAdminPort   = 8801
+	exportConnectPort = 8083
+	exportPluginPath  = "/usr/share/java,/usr/share/confluent-hub-components"
+)
+
+// connector.class → confluent-hub 组件；未收录的类只在清单头部提示手工安装
+var connectorHubComponents = map[string]string{
+	"io.confluent.connect.elasticsearch.ElasticsearchSinkConnector": "confluentinc/kafka-connect-elasticsearch:15.0.1",
+	s3ConnectorClass: "confluentinc/kafka-connect-s3:10.5.13",
+}
+
+// connect.config_providers → worker 上的 provider 实现类
+var configProviderClasses = map[string]string{
+	"file":      "org.apache.kafka.common.config.provider.FileConfigProvider",
+	"directory": "org.apache.kafka.common.config.provider.DirectoryConfigProvider",
+	"env":       "org.apache.kafka.common.config.provider.EnvVarConfigProvider",
+}
+
+var configMapKeyRe = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+type exportAsset struct {
+	Key     string // ConfigMap key / compose config 名
+	Path    string // admin 容器内路径（与 config.yaml 中的引用一致）
+	Content []byte
+}
+
+type manifestPlan struct {
+	format      string
+	adminImage  string
+	namespace   string
+	partitions  int
+	replication int
+
+	brokers     string
+	topics      []string
+	plugins     []string
+	warnings    []string
+	assets      []exportAsset
+	adminConfig []byte            // connect.host 已指向导出的 worker
+	clientProps string            // Kafka 安全配置（topic-init 的 --command-config），未启用为空
+	connectEnv  map[string]string // worker 环境变量（不含 advertised host）
+	provision   []string          // 依次 POST 的 admin 接口
+}
+
+func (s *Server) handleExportManifests(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "compose" && format != "k8s" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"step": "export", "error": "format must be compose or k8s"})
+		return
+	}
+	plan, err := s.manifestPlan(optionsContext(r), format, q)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if _, ok := err.(*sinkInputError); ok {
+			code = http.StatusBadRequest
+		}
+		writeJSON(w, code, errorBody("export", err))
+		return
+	}
+	var out []byte
+	filename := "docker-compose.yaml"
+	if format == "compose" {
+		out, err = plan.compose()
+	} else {
+		out, err = plan.k8s()
+		filename = exportName + ".k8s.yaml"
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorBody("export", err))
+		return
+	}
+	s.logger.Printf("step=export format=%s topics=%v plugins=%v assets=%d", format, plan.topics, plan.plugins, len(plan.assets))
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+func (s *Server) manifestPlan(ctx context.Context, format string, q url.Values) (*manifestPlan, error) {
+	p := &manifestPlan{format: format, adminImage: q.Get("image"), namespace: q.Get("namespace"), partitions: 3, replication: 1}
+	if p.adminImage == "" {
+		p.adminImage = exportAdminImage
+	}
+	for name, dst := range map[string]*int{"partitions": &p.partitions, "replication_factor": &p.replication} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, &sinkInputError{fmt.Errorf("%s must be a positive integer", name)}
+			}
+			*dst = n
+		}
+	}
+	if len(s.cfg.Kafka.Brokers) == 0 {
+		return nil, &sinkInputError{errKafkaNotConfigured}
+	}
+	p.brokers = strings.Join(s.cfg.Kafka.Brokers, ",")
+
+	// ES 资产
+	for _, f := range []struct{ file, path string }{
+		{s.cfg.ES.Files.ILM, "/admin/es/ilm"},
+		{s.cfg.ES.Files.Template, "/admin/es/template"},
+		{s.cfg.ES.Files.Pipeline, "/admin/es/pipeline"},
+	} {
+		if f.file == "" {
+			continue
+		}
+		if _, err := p.addAsset(ctx, s, f.file); err != nil {
+			return nil, err
+		}
+		p.provision = append(p.provision, f.path)
+	}
+	p.provision = append(p.provision, "/admin/es/data-stream")
+
+	// sink：connector JSON 决定 topic、DLQ 与需要的插件
+	topics := map[string]bool{}
+	if s.cfg.Kafka.Topic != "" {
+		topics[s.cfg.Kafka.Topic] = true
+	}
+	plugins := map[string]bool{}
+	for _, class := range s.cfg.Connect.RequiredPlugins {
+		p.addPlugin(plugins, class)
+	}
+	for i, sc := range s.sinkConfigs() {
+		for _, t := range sc.Topics {
+			topics[t] = true
+		}
+		var doc []byte
+		switch sc.Type {
+		case sinkTypeConnect:
+			if sc.File == "" {
+				continue
+			}
+			b, err := p.addAsset(ctx, s, sc.File)
+			if err != nil {
+				return nil, err
+			}
+			doc = b
+		case sinkTypeS3:
+			b, err := s.renderS3Connector(sc)
+			if err != nil {
+				return nil, &sinkInputError{err}
+			}
+			doc = b
+		case sinkTypeMirrorMaker:
+			b, err := s.renderMirrorMakerConnector(sc)
+			if err != nil {
+				return nil, &sinkInputError{err}
+			}
+			doc = b
+		default:
+			p.warnings = append(p.warnings, fmt.Sprintf("sink %q (type %s) runs outside Kafka Connect and is not included", sc.Name, sc.Type))
+			continue
+		}
+		var c struct {
+			Config map[string]string `json:"config"`
+		}
+		if err := json.Unmarshal(doc, &c); err != nil {
+			return nil, &sinkInputError{fmt.Errorf("sink %q: %w", sc.Name, err)}
+		}
+		for _, t := range strings.Split(c.Config["topics"], ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				topics[t] = true
+			}
+		}
+		if dlq := c.Config["errors.deadletterqueue.topic.name"]; dlq != "" {
+			topics[dlq] = true
+		}
+		p.addPlugin(plugins, c.Config["connector.class"])
+		if i == 0 {
+			p.provision = append(p.provision, "/admin/connect/sink")
+		} else {
+			p.provision = append(p.provision, "/admin/sinks/"+url.PathEscape(sc.Name))
+		}
+	}
+	for t := range topics {
+		p.topics = append(p.topics, t)
+	}
+	slices.Sort(p.topics)
+	for c := range plugins {
+		p.plugins = append(p.plugins, c)
+	}
+	slices.Sort(p.plugins)
+
+	props := s.exportKafkaClientProps()
+	p.clientProps = strings.Join(props, "\n")
+	p.connectEnv = p.workerEnv(s, props)
+
+	cfg, err := s.exportAdminConfig(p.connectHost())
+	if err != nil {
+		return nil, err
+	}
+	p.adminConfig = cfg
+	return p, nil
+}
+
+func (p *manifestPlan) addAsset(ctx context.Context, s *Server, file string) ([]byte, error) {
+	b, err := s.readAsset(ctx, file)
+	if err != nil {
+		return nil, &sinkInputError{err}
+	}
+	dst := file
+	if !filepath.IsAbs(dst) {
+		dst = path.Join("/app", filepath.ToSlash(dst))
+	}
+	for _, a := range p.assets {
+		if a.Path == dst {
+			return a.Content, nil
+		}
+	}
+	key := configMapKeyRe.ReplaceAllString(filepath.Base(file), "_")
+	for _, a := range p.assets {
+		if a.Key == key {
+			key = fmt.Sprintf("%d-%s", len(p.assets), key)
+			break
+		}
+	}
+	a := exportAsset{Key: key, Path: dst, Content: s.redact.JSON(b)}
+	p.assets = append(p.assets, a)
+	return a.Content, nil
+}
+
+func (p *manifestPlan) addPlugin(seen map[string]bool, class string) {
+	if class == "" {
+		return
+	}
+	comp, ok := connectorHubComponents[class]
+	if !ok {
+		p.warnings = append(p.warnings, fmt.Sprintf("connector class %s has no known confluent-hub component, install it on the worker manually", class))
+		return
+	}
+	seen[comp] = true
+}
+
+func (p *manifestPlan) connectHost() string {
+	if p.format == "compose" {
+		return fmt.Sprintf("http://connect:%d", exportConnectPort)
+	}
+	return fmt.Sprintf("http://%s-connect:%d", exportName, exportConnectPort)
+}
+
+func (p *manifestPlan) adminURL() string {
+	if p.format == "compose" {
+		return fmt.Sprintf("http://admin:%d", exportAdminPort)
+	}
+	return fmt.Sprintf("http://%s-admin:%d", exportName, exportAdminPort)
+}
+
+// 当前 config.yaml 原文改写 connect.host 并脱敏（注释不保留）
+func (s *Server) exportAdminConfig(connectHost string) ([]byte, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(s.conf.raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse running config: %w", err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	connect, _ := doc["connect"].(map[string]any)
+	if connect == nil {
+		connect = map[string]any{}
+		doc["connect"] = connect
+	}
+	connect["host"] = connectHost
+	s.redact.walk(doc)
+	return marshalYAML(doc)
+}
+
+// 两空格缩进，与手写的 compose / k8s 清单一致
+func marshalYAML(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// 与 kafkaSecurityOpts 对应的 Java 客户端配置；密码一律留空待填
+func (s *Server) exportKafkaClientProps() []string {
+	sasl, tlsOn := s.cfg.Kafka.SASL, s.cfg.Kafka.TLS.Enabled
+	var props []string
+	switch {
+	case sasl.Mechanism != "" && tlsOn:
+		props = append(props, "security.protocol=SASL_SSL")
+	case sasl.Mechanism != "":
+		props = append(props, "security.protocol=SASL_PLAINTEXT")
+	case tlsOn:
+		props = append(props, "security.protocol=SSL")
+	}
+	switch strings.ToUpper(sasl.Mechanism) {
+	case "":
+	case "PLAIN":
+		props = append(props, "sasl.mechanism=PLAIN", fmt.Sprintf(
+			`sasl.jaas.config=org.apache.kafka.common.security.plain.PlainLoginModule required username="%s" password="%s";`, sasl.Username, redactedValue))
+	case "SCRAM-SHA-256", "SCRAM-SHA-512":
+		props = append(props, "sasl.mechanism="+strings.ToUpper(sasl.Mechanism), fmt.Sprintf(
+			`sasl.jaas.config=org.apache.kafka.common.security.scram.ScramLoginModule required username="%s" password="%s";`, sasl.Username, redactedValue))
+	case "AWS_MSK_IAM":
+		props = append(props, "sasl.mechanism=AWS_MSK_IAM",
+			"sasl.jaas.config=software.amazon.msk.auth.iam.IAMLoginModule required;",
+			"sasl.client.callback.handler.class=software.amazon.msk.auth.iam.IAMClientCallbackHandler")
+	}
+	return props
+}
+
+func (p *manifestPlan) workerEnv(s *Server, props []string) map[string]string {
+	rf := strconv.Itoa(p.replication)
+	env := map[string]string{
+		"CONNECT_BOOTSTRAP_SERVERS":                 p.brokers,
+		"CONNECT_REST_PORT":                         strconv.Itoa(exportConnectPort),
+		"CONNECT_GROUP_ID":                          exportName + "-connect",
+		"CONNECT_CONFIG_STORAGE_TOPIC":              "_" + exportName + "-connect-configs",
+		"CONNECT_OFFSET_STORAGE_TOPIC":              "_" + exportName + "-connect-offsets",
+		"CONNECT_STATUS_STORAGE_TOPIC":              "_" + exportName + "-connect-status",
+		"CONNECT_CONFIG_STORAGE_REPLICATION_FACTOR": rf,
+		"CONNECT_OFFSET_STORAGE_REPLICATION_FACTOR": rf,
+		"CONNECT_STATUS_STORAGE_REPLICATION_FACTOR": rf,
+		"CONNECT_KEY_CONVERTER":                     "org.apache.kafka.connect.storage.StringConverter",
+		"CONNECT_VALUE_CONVERTER":                   "org.apache.kafka.connect.json.JsonConverter",
+		"CONNECT_VALUE_CONVERTER_SCHEMAS_ENABLE":    "false",
+		"CONNECT_PLUGIN_PATH":                       exportPluginPath,
+	}
+	var providers []string
+	for _, name := range s.cfg.Connect.ConfigProviders {
+		class, ok := configProviderClasses[name]
+		if !ok {
+			p.warnings = append(p.warnings, fmt.Sprintf("config provider %q: set CONNECT_CONFIG_PROVIDERS_%s_CLASS manually", name, strings.ToUpper(name)))
+		} else {
+			env["CONNECT_CONFIG_PROVIDERS_"+strings.ToUpper(name)+"_CLASS"] = class
+		}
+		providers = append(providers, name)
+	}
+	if len(providers) > 0 {
+		env["CONNECT_CONFIG_PROVIDERS"] = strings.Join(providers, ",")
+	}
+	// worker 自身、sink 的 consumer 与 DLQ 的 producer 都要带安全配置
+	for _, prop := range props {
+		k, v, _ := strings.Cut(prop, "=")
+		name := strings.ToUpper(strings.ReplaceAll(k, ".", "_"))
+		for _, prefix := range []string{"CONNECT_", "CONNECT_CONSUMER_", "CONNECT_PRODUCER_"} {
+			env[prefix+name] = v
+		}
+	}
+	return env
+}
+
+func (p *manifestPlan) topicInitScript(clientConfig string) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, t := range p.topics {
+		fmt.Fprintf(&b, "kafka-topics --bootstrap-server %s%s --create --if-not-exists --topic %s --partitions %d --replication-factor %d\n",
+			p.brokers, clientConfig, t, p.partitions, p.replication)
+	}
+	return b.String()
+}
+
+func (p *manifestPlan) connectScript() string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, c := range p.plugins {
+		fmt.Fprintf(&b, "confluent-hub install --no-prompt %s\n", c)
+	}
+	b.WriteString("exec /etc/confluent/docker/run\n")
+	return b.String()
+}
+
+// curl 自带重试：admin 或下游尚未就绪时等待，任一步最终失败即退出非 0
+func (p *manifestPlan) provisionScript() string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, path := range p.provision {
+		fmt.Fprintf(&b, "curl -fsS --retry 30 --retry-delay 5 --retry-all-errors -X POST -H 'X-Operator: %s-provision' %s%s\necho\n",
+			exportName, p.adminURL(), path)
+	}
+	return b.String()
+}
+
+func (p *manifestPlan) header() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# generated by go-pipeline-server GET /admin/export/manifests?format=%s\n", p.format)
+	fmt.Fprintf(&b, "# topics: %s (partitions=%d, replication_factor=%d)\n", strings.Join(p.topics, ", "), p.partitions, p.replication)
+	b.WriteString("# secrets are replaced with " + redactedValue + ", fill them in before deploying\n")
+	for _, w := range p.warnings {
+		b.WriteString("# WARNING: " + w + "\n")
+	}
+	return b.Bytes()
+}
+
+/************** docker-compose **************/
+
+func (p *manifestPlan) compose() ([]byte, error) {
+	configs := map[string]any{"config.yaml": map[string]any{"content": string(p.adminConfig)}}
+	adminConfigs := []any{map[string]any{"source": "config.yaml", "target": "/app/config.yaml"}}
+	for _, a := range p.assets {
+		configs[a.Key] = map[string]any{"content": string(a.Content)}
+		adminConfigs = append(adminConfigs, map[string]any{"source": a.Key, "target": a.Path})
+	}
+	topicInit := map[string]any{
+		"image":   exportKafkaImage,
+		"command": []string{"bash", "-c", p.topicInitScript("")},
+		"restart": "no",
+	}
+	if p.clientProps != "" {
+		configs["kafka-client.properties"] = map[string]any{"content": p.clientProps + "\n"}
+		topicInit["configs"] = []any{map[string]any{"source": "kafka-client.properties", "target": "/etc/kafka/client.properties"}}
+		topicInit["command"] = []string{"bash", "-c", p.topicInitScript(" --command-config /etc/kafka/client.properties")}
+	}
+	env := map[string]string{"CONNECT_REST_ADVERTISED_HOST_NAME": "connect"}
+	for k, v := range p.connectEnv {
+		env[k] = v
+	}
+	doc := map[string]any{
+		"services": map[string]any{
+			"topic-init": topicInit,
+			"connect": map[string]any{
+				"image":       exportConnectImage,
+				"command":     []string{"bash", "-c", p.connectScript()},
+				"environment": env,
+				"ports":       []string{fmt.Sprintf("%d:%d", exportConnectPort, exportConnectPort)},
+				"depends_on":  map[string]any{"topic-init": map[string]any{"condition": "service_completed_successfully"}},
+				"healthcheck": map[string]any{
+					"test":     []string{"CMD", "curl", "-fs", fmt.Sprintf("http://127.0.0.1:%d/connectors", exportConnectPort)},
+					"interval": "10s", "retries": 30, "start_period": "60s",
+				},
+			},
+			"admin": map[string]any{
+				"image":       p.adminImage,
+				"environment": map[string]string{"LISTEN": fmt.Sprintf(":%d", exportAdminPort), "CONFIG": "/app/config.yaml"},
+				"ports":       []string{fmt.Sprintf("%d:%d", exportAdminPort, exportAdminPort)},
+				"configs":     adminConfigs,
+				"depends_on":  map[string]any{"connect": map[string]any{"condition": "service_healthy"}},
+				"healthcheck": map[string]any{
+					"test":     []string{"CMD", "wget", "-qO-", fmt.Sprintf("http://127.0.0.1:%d/readyz", exportAdminPort)},
+					"interval": "10s", "retries": 10,
+				},
+			},
+			"provision": map[string]any{
+				"image":      exportCurlImage,
+				"entrypoint": []string{"sh", "-c", p.provisionScript()},
+				"depends_on": map[string]any{"admin": map[string]any{"condition": "service_healthy"}},
+				"restart":    "no",
+			},
+		},
+		"configs": configs,
+	}
+	b, err := marshalYAML(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append(p.header(), b...), nil
+}
+
+/************** Kubernetes **************/
+
+func (p *manifestPlan) meta(name string) map[string]any {
+	m := map[string]any{"name": name, "labels": map[string]string{
+		"app.kubernetes.io/name": name, "app.kubernetes.io/part-of": exportName,
+	}}
+	if p.namespace != "" {
+		m["namespace"] = p.namespace
+	}
+	return m
+}
+
+func (p *manifestPlan) k8s() ([]byte, error) {
+	var objs []any
+	name := func(s string) string { return exportName + "-" + s }
+	selector := func(n string) map[string]any {
+		return map[string]any{"matchLabels": map[string]string{"app.kubernetes.io/name": n}}
+	}
+	podMeta := func(n string) map[string]any {
+		return map[string]any{"labels": map[string]string{"app.kubernetes.io/name": n, "app.kubernetes.io/part-of": exportName}}
+	}
+	service := func(n string, port int) map[string]any {
+		return map[string]any{"apiVersion": "v1", "kind": "Service", "metadata": p.meta(n), "spec": map[string]any{
+			"selector": map[string]string{"app.kubernetes.io/name": n},
+			"ports":    []any{map[string]any{"name": "http", "port": port, "targetPort": port}},
+		}}
+	}
+
+	// 配置与资产
+	assetData := map[string]string{}
+	for _, a := range p.assets {
+		assetData[a.Key] = string(a.Content)
+	}
+	objs = append(objs,
+		map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "metadata": p.meta(name("config")),
+			"data": map[string]string{"config.yaml": string(p.adminConfig)}},
+		map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "metadata": p.meta(name("assets")), "data": assetData},
+	)
+
+	// topic 初始化
+	topicContainer := map[string]any{"name": "topic-init", "image": exportKafkaImage,
+		"command": []string{"bash", "-c", p.topicInitScript("")}}
+	topicPod := map[string]any{"restartPolicy": "OnFailure", "containers": []any{topicContainer}}
+	if p.clientProps != "" {
+		objs = append(objs, map[string]any{"apiVersion": "v1", "kind": "Secret", "metadata": p.meta(name("kafka-client")),
+			"stringData": map[string]string{"client.properties": p.clientProps + "\n"}})
+		topicContainer["command"] = []string{"bash", "-c", p.topicInitScript(" --command-config /etc/kafka/client/client.properties")}
+		topicContainer["volumeMounts"] = []any{map[string]any{"name": "kafka-client", "mountPath": "/etc/kafka/client", "readOnly": true}}
+		topicPod["volumes"] = []any{map[string]any{"name": "kafka-client", "secret": map[string]any{"secretName": name("kafka-client")}}}
+	}
+	objs = append(objs, map[string]any{"apiVersion": "batch/v1", "kind": "Job", "metadata": p.meta(name("topic-init")),
+		"spec": map[string]any{"backoffLimit": 6, "template": map[string]any{"metadata": podMeta(name("topic-init")), "spec": topicPod}}})
+
+	// Connect worker
+	env := []any{map[string]any{"name": "CONNECT_REST_ADVERTISED_HOST_NAME",
+		"valueFrom": map[string]any{"fieldRef": map[string]string{"fieldPath": "status.podIP"}}}}
+	keys := make([]string, 0, len(p.connectEnv))
+	for k := range p.connectEnv {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		env = append(env, map[string]any{"name": k, "value": p.connectEnv[k]})
+	}
+	connect := name("connect")
+	objs = append(objs, map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": p.meta(connect),
+		"spec": map[string]any{"replicas": 1, "selector": selector(connect), "template": map[string]any{
+			"metadata": podMeta(connect),
+			"spec": map[string]any{"containers": []any{map[string]any{
+				"name": "connect", "image": exportConnectImage,
+				"command": []string{"bash", "-c", p.connectScript()},
+				"env":     env,
+				"ports":   []any{map[string]any{"name": "http", "containerPort": exportConnectPort}},
+				"readinessProbe": map[string]any{"httpGet": map[string]any{"path": "/connectors", "port": exportConnectPort},
+					"initialDelaySeconds": 30, "periodSeconds": 10},
+			}}},
+		}}}, service(connect, exportConnectPort))
+
+	// admin
+	admin := name("admin")
+	mounts := []any{map[string]any{"name": "config", "mountPath": "/app/config.yaml", "subPath": "config.yaml", "readOnly": true}}
+	for _, a := range p.assets {
+		mounts = append(mounts, map[string]any{"name": "assets", "mountPath": a.Path, "subPath": a.Key, "readOnly": true})
+	}
+	probe := func(path string) map[string]any {
+		return map[string]any{"httpGet": map[string]any{"path": path, "port": exportAdminPort}, "periodSeconds": 10}
+	}
+	objs = append(objs, map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": p.meta(admin),
+		"spec": map[string]any{"replicas": 1, "selector": selector(admin), "template": map[string]any{
+			"metadata": podMeta(admin),
+			"spec": map[string]any{
+				"containers": []any{map[string]any{
+					"name": "admin", "image": p.adminImage,
+					"env": []any{
+						map[string]any{"name": "LISTEN", "value": fmt.Sprintf(":%d", exportAdminPort)},
+						map[string]any{"name": "CONFIG", "value": "/app/config.yaml"},
+					},
+					"ports":          []any{map[string]any{"name": "http", "containerPort": exportAdminPort}},
+					"livenessProbe":  probe("/healthz"),
+					"readinessProbe": probe("/readyz"),
+					"volumeMounts":   mounts,
+				}},
+				"volumes": []any{
+					map[string]any{"name": "config", "configMap": map[string]any{"name": name("config")}},
+					map[string]any{"name": "assets", "configMap": map[string]any{"name": name("assets")}},
+				},
+			},
+		}}}, service(admin, exportAdminPort))
+
+	// 下发
+	objs = append(objs, map[string]any{"apiVersion": "batch/v1", "kind": "Job", "metadata": p.meta(name("provision")),
+		"spec": map[string]any{"backoffLimit": 6, "template": map[string]any{"metadata": podMeta(name("provision")),
+			"spec": map[string]any{"restartPolicy": "OnFailure", "containers": []any{map[string]any{
+				"name": "provision", "image": exportCurlImage, "command": []string{"sh", "-c", p.provisionScript()},
+			}}}}}})
+
+	out := p.header()
+	for _, o := range objs {
+		b, err := marshalYAML(o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, "---\n"...)
+		out = append(out, b...)
+	}
+	return out, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/************** 采样与降噪过滤（PUT /admin/es/pipeline/filters） **************/
+
+// 以命名开关管理 pipeline 中的丢弃 / 采样处理器，不必手写 drop 处理器与 painless 条件：
+//   {"name": "debug-sampling", "enabled": true, "action": "sample", "rate": 0.1, "field": "log.level", "equals": ["debug"], "ignore_case": true}
+//   {"name": "health-checks", "enabled": true, "action": "drop", "field": "url.path", "prefix": ["/healthz", "/ready"]}
+// action=drop 丢弃匹配的文档（必须有条件）；action=sample 匹配的文档只保留 rate 比例（无条件时对全部文档采样）。
+// 条件：field 的值等于 equals 之一或以 prefix 之一开头；field 可为点分路径，同时匹配嵌套对象与带点的平铺字段名。
+// 定义保存在 pipeline 文档的 _meta.filters，启用的过滤器生成 tag 为 filter:<name> 的 drop 处理器，
+// position=last（默认，grok 等解析之后才有 log.level 之类字段）放在处理器末尾，first 放在最前面以省去后续处理。
+// 写回 es.files.pipeline（同 PUT /admin/es/pipeline/processors：需 If-Match，支持 ?dry_run=true 与 ?deploy=true）。
+
+const (
+	filterActionDrop   = "drop"
+	filterActionSample = "sample"
+	filterTagPrefix    = "filter:"
+)
+
+type pipelineFilter struct {
+	Name       string   `json:"name"`
+	Enabled    bool     `json:"enabled"`
+	Action     string   `json:"action"`         // drop | sample
+	Rate       float64  `json:"rate,omitempty"` // sample：保留比例，(0, 1)
+	Field      string   `json:"field,omitempty"`
+	Equals     []string `json:"equals,omitempty"`
+	Prefix     []string `json:"prefix,omitempty"`
+	IgnoreCase bool     `json:"ignore_case,omitempty"`
+}
+
+type pipelineFilters struct {
+	Position string           `json:"position,omitempty"` // first | last，默认 last
+	Filters  []pipelineFilter `json:"filters"`
+}
+
+func (pf pipelineFilters) validate() error {
+	switch pf.Position {
+	case "", "first", "last":
+	default:
+		return fmt.Errorf("position must be first or last")
+	}
+	seen := map[string]bool{}
+	for _, f := range pf.Filters {
+		if f.Name == "" {
+			return fmt.Errorf("filter name is required")
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate filter name %q", f.Name)
+		}
+		seen[f.Name] = true
+		hasCond := len(f.Equals) > 0 || len(f.Prefix) > 0
+		if hasCond && f.Field == "" {
+			return fmt.Errorf("filter %s: field is required with equals / prefix", f.Name)
+		}
+		if f.Field != "" && !hasCond {
+			return fmt.Errorf("filter %s: equals or prefix is required with field", f.Name)
+		}
+		switch f.Action {
+		case filterActionDrop:
+			if !hasCond {
+				return fmt.Errorf("filter %s: drop requires a condition (field with equals / prefix)", f.Name)
+			}
+		case filterActionSample:
+			if f.Rate <= 0 || f.Rate >= 1 {
+				return fmt.Errorf("filter %s: rate must be between 0 and 1 (exclusive)", f.Name)
+			}
+		default:
+			return fmt.Errorf("filter %s: action must be drop or sample", f.Name)
+		}
+	}
+	return nil
+}
+
+// painless 字符串字面量
+func painlessString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// field 的取值：平铺的带点字段名优先，否则按嵌套对象逐级取（null 安全）
+func painlessFieldValue(field string) string {
+	expr := "ctx"
+	for _, seg := range strings.Split(field, ".") {
+		expr += "?.get(" + painlessString(seg) + ")"
+	}
+	if !strings.Contains(field, ".") {
+		return expr
+	}
+	return "(ctx.get(" + painlessString(field) + ") ?: " + expr + ")"
+}
+
+// drop 处理器的 if 条件；sample 在匹配条件上再加随机数，文档以 rate 的概率保留
+func (f pipelineFilter) condition() string {
+	var conds []string
+	if f.Field != "" {
+		v := painlessFieldValue(f.Field) + ".toString()"
+		if f.IgnoreCase {
+			v += ".toLowerCase()"
+		}
+		norm := func(s string) string {
+			if f.IgnoreCase {
+				s = strings.ToLower(s)
+			}
+			return painlessString(s)
+		}
+		var match []string
+		if len(f.Equals) > 0 {
+			lits := make([]string, 0, len(f.Equals))
+			for _, e := range f.Equals {
+				lits = append(lits, norm(e))
+			}
+			match = append(match, "["+strings.Join(lits, ", ")+"].contains("+v+")")
+		}
+		for _, p := range f.Prefix {
+			match = append(match, v+".startsWith("+norm(p)+")")
+		}
+		conds = append(conds, painlessFieldValue(f.Field)+" != null", "("+strings.Join(match, " || ")+")")
+	}
+	if f.Action == filterActionSample {
+		conds = append(conds, "Math.random() >= "+strconv.FormatFloat(f.Rate, 'f', -1, 64))
+	}
+	return strings.Join(conds, " && ")
+}
+
+func (f pipelineFilter) processor() builderProcessor {
+	desc := "drop matching documents"
+	if f.Action == filterActionSample {
+		desc = fmt.Sprintf("keep %s%% of matching documents", strconv.FormatFloat(f.Rate*100, 'f', -1, 64))
+	}
+	return builderProcessor{Type: "drop", Config: map[string]any{
+		"tag":         filterTagPrefix + f.Name,
+		"description": "managed by /admin/es/pipeline/filters: " + desc,
+		"if":          f.condition(),
+	}}
+}
+
+func isFilterProcessor(p builderProcessor) bool {
+	tag, _ := p.Config["tag"].(string)
+	return strings.HasPrefix(tag, filterTagPrefix)
+}
+
+// 去掉旧的过滤器处理器，按 position 插入启用的过滤器
+func (pf pipelineFilters) applyTo(bp *builderPipeline) {
+	procs := slices.DeleteFunc(slices.Clone(bp.Processors), isFilterProcessor)
+	var gen []builderProcessor
+	for _, f := range pf.Filters {
+		if f.Enabled {
+			gen = append(gen, f.processor())
+		}
+	}
+	if pf.Position == "first" {
+		bp.Processors = append(gen, procs...)
+	} else {
+		bp.Processors = append(procs, gen...)
+	}
+	if bp.Meta == nil {
+		bp.Meta = map[string]any{}
+	}
+	bp.Meta["filters"] = pf
+}
+
+func filtersFromMeta(meta map[string]any) pipelineFilters {
+	pf := pipelineFilters{Filters: []pipelineFilter{}}
+	if v, ok := meta["filters"]; ok {
+		b, _ := json.Marshal(v)
+		_ = json.Unmarshal(b, &pf)
+	}
+	if pf.Filters == nil {
+		pf.Filters = []pipelineFilter{}
+	}
+	return pf
+}
+
+func (s *Server) readPipelineFile() ([]byte, builderPipeline, error) {
+	b, err := s.readConfiguredAsset(assetPipeline, s.cfg.ES.Files.Pipeline)
+	if err != nil {
+		return nil, builderPipeline{}, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, builderPipeline{}, fmt.Errorf("parse pipeline file: %w", err)
+	}
+	bp, err := pipelineFromRaw(doc)
+	return b, bp, err
+}
+
+func (s *Server) handleGetPipelineFilters(w http.ResponseWriter, r *http.Request) {
+	b, bp, err := s.readPipelineFile()
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	setETag(w, fileRevision(s.assetRevision(assetPipeline, ""), b))
+	writeJSON(w, http.StatusOK, filtersFromMeta(bp.Meta))
+}
+
+// 整体替换过滤器列表；enabled=false 的保留定义但不生成处理器
+func (s *Server) handlePutPipelineFilters(w http.ResponseWriter, r *http.Request) {
+	cur, bp, err := s.readPipelineFile()
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun && !checkIfMatch(w, r, "pipeline-filters", fileRevision(s.assetRevision(assetPipeline, ""), cur)) {
+		return
+	}
+	var pf pipelineFilters
+	if err := json.NewDecoder(r.Body).Decode(&pf); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if err := pf.validate(); err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	pf.applyTo(&bp)
+	raw := bp.raw()
+	warns := []builderIssue{}
+	if code, detail, err := s.compilePipeline(r.Context(), raw); err != nil {
+		warns = append(warns, builderIssue{"", "could not compile on elasticsearch: " + err.Error()})
+	} else if detail != nil {
+		writeJSON(w, code, map[string]any{"error": "elasticsearch rejected the pipeline", "detail": detail, "warnings": warns})
+		return
+	}
+
+	out := map[string]any{"filters": pf, "pipeline": raw, "warnings": warns}
+	if dryRun {
+		out["dry_run"] = true
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	var enabled []string
+	for _, f := range pf.Filters {
+		if f.Enabled {
+			enabled = append(enabled, f.Name)
+		}
+	}
+	msg := fmt.Sprintf("Update ingest pipeline %s filters (enabled: %s)", s.cfg.ES.Names.Pipeline, strings.Join(enabled, ", "))
+	if len(enabled) == 0 {
+		msg = fmt.Sprintf("Update ingest pipeline %s filters (none enabled)", s.cfg.ES.Names.Pipeline)
+	}
+	s.savePipelineFile(w, r, "pipeline-filters", raw, msg, out)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/************** ES / OpenSearch 兼容 **************/
+
+const (
+	flavorElasticsearch = "elasticsearch"
+	flavorOpenSearch    = "opensearch"
+)
+
+func (s *Server) esFlavor() string {
+	if strings.EqualFold(s.cfg.ES.Flavor, flavorOpenSearch) {
+		return flavorOpenSearch
+	}
+	return flavorElasticsearch
+}
+
+func (s *Server) isOpenSearch() bool { return s.esFlavor() == flavorOpenSearch }
+
+// 生命周期策略：ES 为 ILM，OpenSearch 为 ISM
+func (s *Server) lifecyclePolicyURL() string {
+	if s.isOpenSearch() {
+		return fmt.Sprintf("%s/_plugins/_ism/policies/%s", s.cfg.ES.Host, s.cfg.ES.Names.ILMPolicy)
+	}
+	return fmt.Sprintf("%s/_ilm/policy/%s", s.cfg.ES.Host, s.cfg.ES.Names.ILMPolicy)
+}
+
+func (s *Server) lifecycleExplainURL() string {
+	if s.isOpenSearch() {
+		return fmt.Sprintf("%s/_plugins/_ism/explain/%s", s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+	}
+	return fmt.Sprintf("%s/%s/_ilm/explain", s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+}
+
+// 按 flavor 生成最终要 PUT 的策略 URL 与 body。
+// 先叠加归档层（searchable snapshot）与降采样配置；OpenSearch 下 ILM 格式文件再自动转换为 ISM；已存在的策略需带 seq_no/primary_term 才能更新。
+func (s *Server) prepareLifecyclePolicy(ctx context.Context, b []byte) (string, []byte, []string, error) {
+	url := s.lifecyclePolicyURL()
+	b, warnings, err := s.applyArchiveOverlay(b)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	b, dsWarnings, err := s.applyDownsampleOverlay(b)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	warnings = append(warnings, dsWarnings...)
+	if !s.isOpenSearch() {
+		if b, err = s.stampMeta(b, "policy"); err != nil {
+			return "", nil, nil, fmt.Errorf("parse ilm policy: %w", err)
+		}
+		return url, b, warnings, nil
+	}
+	patterns := []string{s.cfg.ES.Names.DataStream + "*", ".ds-" + s.cfg.ES.Names.DataStream + "-*"}
+	out, convWarnings, err := ilmToISM(b, patterns)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	warnings = append(warnings, convWarnings...)
+	resp, body, err := s.doGET(ctx, url, "es")
+	if err == nil && resp.StatusCode == http.StatusOK {
+		var cur struct {
+			SeqNo       *int64 `json:"_seq_no"`
+			PrimaryTerm *int64 `json:"_primary_term"`
+		}
+		if json.Unmarshal(body, &cur) == nil && cur.SeqNo != nil && cur.PrimaryTerm != nil {
+			url = fmt.Sprintf("%s?if_seq_no=%d&if_primary_term=%d", url, *cur.SeqNo, *cur.PrimaryTerm)
+		}
+	}
+	return url, out, warnings, nil
+}
+
+// ILM phase 顺序
+var ilmPhaseOrder = []string{"hot", "warm", "cold", "frozen", "delete"}
+
+// 将 ILM policy 转换为 ISM policy；若已是 ISM 格式（含 states）则原样返回。
+// 注意 ISM 的 min_index_age 从索引创建起算，而 ILM 的 min_age 从 rollover 起算，二者近似处理。
+func ilmToISM(b []byte, indexPatterns []string) ([]byte, []string, error) {
+	var doc struct {
+		Policy map[string]json.RawMessage `json:"policy"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse lifecycle policy: %w", err)
+	}
+	if _, ok := doc.Policy["states"]; ok {
+		return b, nil, nil
+	}
+	var phases map[string]struct {
+		MinAge  string                    `json:"min_age"`
+		Actions map[string]map[string]any `json:"actions"`
+	}
+	if raw, ok := doc.Policy["phases"]; ok {
+		if err := json.Unmarshal(raw, &phases); err != nil {
+			return nil, nil, fmt.Errorf("parse ilm phases: %w", err)
+		}
+	}
+
+	var warnings []string
+	var order []string
+	for _, p := range ilmPhaseOrder {
+		if _, ok := phases[p]; ok {
+			order = append(order, p)
+		}
+	}
+	if len(order) == 0 {
+		return nil, nil, fmt.Errorf("ilm policy has no phases")
+	}
+
+	states := make([]map[string]any, 0, len(order))
+	for i, name := range order {
+		ph := phases[name]
+		actions := []map[string]any{}
+		names := make([]string, 0, len(ph.Actions))
+		for act := range ph.Actions {
+			names = append(names, act)
+		}
+		sort.Strings(names)
+		for _, act := range names {
+			ism, ok := ilmActionToISM(act, ph.Actions[act])
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("phase %s: action %s has no ISM equivalent, skipped", name, act))
+				continue
+			}
+			actions = append(actions, ism)
+		}
+		transitions := []map[string]any{}
+		if i+1 < len(order) {
+			next := order[i+1]
+			t := map[string]any{"state_name": next}
+			if age := phases[next].MinAge; age != "" {
+				t["conditions"] = map[string]any{"min_index_age": age}
+			}
+			transitions = append(transitions, t)
+		}
+		states = append(states, map[string]any{"name": name, "actions": actions, "transitions": transitions})
+	}
+
+	out := map[string]any{"policy": map[string]any{
+		"description":   "converted from ILM policy",
+		"default_state": order[0],
+		"states":        states,
+		"ism_template":  []map[string]any{{"index_patterns": indexPatterns, "priority": 100}},
+	}}
+	nb, err := json.Marshal(out)
+	return nb, warnings, err
+}
+
+func ilmActionToISM(action string, p map[string]any) (map[string]any, bool) {
+	switch action {
+	case "rollover":
+		m := map[string]any{}
+		rename := map[string]string{
+			"max_age":                "min_index_age",
+			"max_primary_shard_size": "min_primary_shard_size",
+			"max_size":               "min_size",
+			"max_docs":               "min_doc_count",
+		}
+		for k, v := range p {
+			if nk, ok := rename[k]; ok {
+				m[nk] = v
+			}
+		}
+		return map[string]any{"rollover": m}, true
+	case "delete":
+		return map[string]any{"delete": map[string]any{}}, true
+	case "forcemerge":
+		return map[string]any{"force_merge": map[string]any{"max_num_segments": p["max_num_segments"]}}, true
+	case "readonly":
+		return map[string]any{"read_only": map[string]any{}}, true
+	case "set_priority":
+		return map[string]any{"index_priority": map[string]any{"priority": p["priority"]}}, true
+	case "allocate":
+		if n, ok := p["number_of_replicas"]; ok {
+			return map[string]any{"replica_count": map[string]any{"number_of_replicas": n}}, true
+		}
+	case "shrink":
+		if n, ok := p["number_of_shards"]; ok {
+			return map[string]any{"shrink": map[string]any{"num_new_shards": n}}, true
+		}
+	}
+	return nil, false
+}
+
+// 开启降采样时改为 TSDS 模板；OpenSearch 不认识 index.lifecycle.*（由 ISM 的 ism_template 绑定），下发模板前去掉
+func (s *Server) prepareIndexTemplate(b []byte) ([]byte, error) {
+	b, err := s.applyDownsampleTemplate(b)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = s.stampMeta(b); err != nil {
+		return nil, fmt.Errorf("parse index template: %w", err)
+	}
+	if !s.isOpenSearch() {
+		return b, nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse index template: %w", err)
+	}
+	if tpl, ok := doc["template"].(map[string]any); ok {
+		if settings, ok := tpl["settings"].(map[string]any); ok {
+			for k := range settings {
+				if strings.HasPrefix(k, "index.lifecycle.") {
+					delete(settings, k)
+				}
+			}
+			if idx, ok := settings["index"].(map[string]any); ok {
+				delete(idx, "lifecycle")
+			}
+		}
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 老化索引的 force-merge / shrink **************/
+
+var defaultMergePhases = []string{"warm", "cold"}
+
+type forcemergeRequest struct {
+	Indices        []string `json:"indices,omitempty"`          // 指定 backing index；为空则按 phases 自动挑选
+	Phases         []string `json:"phases,omitempty"`           // 默认 warm, cold
+	MaxNumSegments int      `json:"max_num_segments,omitempty"` // 默认 1
+	ShrinkShards   int      `json:"shrink_shards,omitempty"`    // >0 时先 shrink 到该主分片数（须整除原分片数）
+	DryRun         bool     `json:"dry_run,omitempty"`
+}
+
+func (req *forcemergeRequest) normalize() error {
+	if len(req.Phases) == 0 {
+		req.Phases = defaultMergePhases
+	}
+	if req.MaxNumSegments == 0 {
+		req.MaxNumSegments = 1
+	}
+	if req.MaxNumSegments < 0 || req.ShrinkShards < 0 {
+		return fmt.Errorf("max_num_segments and shrink_shards must be positive")
+	}
+	return nil
+}
+
+type mergeCandidate struct {
+	Index      string `json:"index"`
+	Phase      string `json:"phase"`
+	Shards     int    `json:"primary_shards"`
+	Replicas   int    `json:"replicas"`
+	Segments   int    `json:"segments"` // 含副本
+	Docs       int64  `json:"docs"`
+	StoreBytes int64  `json:"primary_store_bytes"`
+	Merge      bool   `json:"merge"`
+	Shrink     bool   `json:"shrink"`
+	Reason     string `json:"reason,omitempty"` // 不处理的原因
+}
+
+type lifecycleInfo struct {
+	phase      string
+	inProgress string // 非空表示 ILM/ISM 正在执行的 action/step
+}
+
+// ILM：indices.<idx>.{phase,action,step}；ISM：<idx>.state.name / action.name / step.step_status
+func (s *Server) lifecyclePhases(ctx context.Context) (map[string]lifecycleInfo, error) {
+	resp, body, err := s.doGET(ctx, s.lifecycleExplainURL(), "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lifecycle explain returned %s", resp.Status)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	out := map[string]lifecycleInfo{}
+	if raw, ok := doc["indices"]; ok {
+		var indices map[string]struct {
+			Phase  string `json:"phase"`
+			Action string `json:"action"`
+			Step   string `json:"step"`
+		}
+		if err := json.Unmarshal(raw, &indices); err != nil {
+			return nil, err
+		}
+		for name, idx := range indices {
+			li := lifecycleInfo{phase: idx.Phase}
+			if idx.Action != "" && idx.Action != "complete" && idx.Step != "complete" {
+				li.inProgress = idx.Action + "/" + idx.Step
+			}
+			out[name] = li
+		}
+		return out, nil
+	}
+	for name, raw := range doc {
+		var idx struct {
+			State struct {
+				Name string `json:"name"`
+			} `json:"state"`
+			Action struct {
+				Name string `json:"name"`
+			} `json:"action"`
+			Step struct {
+				Name       string `json:"name"`
+				StepStatus string `json:"step_status"`
+			} `json:"step"`
+		}
+		if json.Unmarshal(raw, &idx) != nil || idx.State.Name == "" {
+			continue
+		}
+		li := lifecycleInfo{phase: idx.State.Name}
+		if idx.Action.Name != "" && idx.Step.StepStatus != "" && idx.Step.StepStatus != "completed" {
+			li.inProgress = idx.Action.Name + "/" + idx.Step.Name
+		}
+		out[name] = li
+	}
+	return out, nil
+}
+
+// 数据流的 backing index，最后一个为当前写索引
+func (s *Server) dataStreamIndices(ctx context.Context) ([]string, error) {
+	resp, body, err := s.doGET(ctx, fmt.Sprintf("%s/_data_stream/%s", s.cfg.ES.Host, s.cfg.ES.Names.DataStream), "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get data stream returned %s", resp.Status)
+	}
+	var doc struct {
+		DataStreams []struct {
+			Indices []struct {
+				IndexName string `json:"index_name"`
+			} `json:"indices"`
+		} `json:"data_streams"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.DataStreams) == 0 {
+		return nil, fmt.Errorf("data stream %s not found", s.cfg.ES.Names.DataStream)
+	}
+	var out []string
+	for _, idx := range doc.DataStreams[0].Indices {
+		out = append(out, idx.IndexName)
+	}
+	return out, nil
+}
+
+func atoiLoose(v string) int {
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+// 按请求挑选需要处理的 backing index；写索引与 ILM 正在执行 action 的索引一律跳过
+func (s *Server) mergeCandidates(ctx context.Context, req forcemergeRequest) ([]mergeCandidate, error) {
+	backing, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phases, err := s.lifecyclePhases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/_cat/indices/%s?format=json&bytes=b&expand_wildcards=all&h=index,pri,rep,sc,docs.count,pri.store.size",
+		s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+	resp, body, err := s.doGET(ctx, u, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("_cat/indices returned %s", resp.Status)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, err
+	}
+	stats := map[string]map[string]string{}
+	for _, row := range rows {
+		stats[row["index"]] = row
+	}
+
+	out := []mergeCandidate{}
+	for i, idx := range backing {
+		if len(req.Indices) > 0 && !slices.Contains(req.Indices, idx) {
+			continue
+		}
+		st := stats[idx]
+		c := mergeCandidate{Index: idx, Phase: phases[idx].phase,
+			Shards: atoiLoose(st["pri"]), Replicas: atoiLoose(st["rep"]), Segments: atoiLoose(st["sc"])}
+		c.Docs, _ = strconv.ParseInt(st["docs.count"], 10, 64)
+		c.StoreBytes, _ = strconv.ParseInt(st["pri.store.size"], 10, 64)
+		switch {
+		case i == len(backing)-1:
+			c.Reason = "write index"
+		case len(req.Indices) == 0 && !slices.Contains(req.Phases, c.Phase):
+			c.Reason = fmt.Sprintf("phase %q not selected", c.Phase)
+		case phases[idx].inProgress != "":
+			c.Reason = "lifecycle action in progress: " + phases[idx].inProgress
+		default:
+			c.Shrink = req.ShrinkShards > 0 && c.Shards > req.ShrinkShards
+			if c.Shrink && c.Shards%req.ShrinkShards != 0 {
+				c.Shrink = false
+				c.Reason = fmt.Sprintf("shrink_shards %d is not a factor of %d", req.ShrinkShards, c.Shards)
+			}
+			copies := max(c.Shards, 1) * (1 + c.Replicas)
+			c.Merge = c.Shrink || c.Segments > copies*req.MaxNumSegments
+			if !c.Merge && c.Reason == "" {
+				c.Reason = "already merged"
+			}
+		}
+		out = append(out, c)
+	}
+	for _, name := range req.Indices {
+		if !slices.Contains(backing, name) {
+			return nil, fmt.Errorf("%s is not a backing index of %s", name, s.cfg.ES.Names.DataStream)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) putIndexSettings(ctx context.Context, index string, settings map[string]any) error {
+	b, _ := json.Marshal(settings)
+	resp, body, err := s.doPUT(ctx, fmt.Sprintf("%s/%s/_settings", s.cfg.ES.Host, index), b, "es")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("update settings of %s: %s %s", index, resp.Status, body)
+	}
+	return nil
+}
+
+// 先加写保护，再提交 force-merge 任务并等待完成
+func (s *Server) forcemergeIndex(ctx context.Context, j *Job, index string, segments int) error {
+	if err := s.putIndexSettings(ctx, index, map[string]any{"index.blocks.write": true}); err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/%s/_forcemerge?max_num_segments=%d&wait_for_completion=false", s.cfg.ES.Host, url.PathEscape(index), segments)
+	resp, body, err := s.doPOST(ctx, u, nil, "es")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("forcemerge %s: %s %s", index, resp.Status, body)
+	}
+	var task struct {
+		Task string `json:"task"`
+	}
+	_ = json.Unmarshal(body, &task)
+	// 不支持 wait_for_completion=false 的版本会同步返回结果
+	if task.Task != "" {
+		if _, err := s.waitESTask(ctx, j, task.Task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 轮询 _cluster/health 直到索引达到指定状态
+func (s *Server) waitIndexHealth(ctx context.Context, index, status string) error {
+	u := fmt.Sprintf("%s/_cluster/health/%s?wait_for_status=%s&timeout=20s", s.cfg.ES.Host, url.PathEscape(index), status)
+	for {
+		resp, body, err := s.doGET(ctx, u, "es")
+		if err != nil {
+			return err
+		}
+		var h struct {
+			TimedOut bool `json:"timed_out"`
+		}
+		if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &h) == nil && !h.TimedOut {
+			return nil
+		}
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return fmt.Errorf("wait for %s to become %s: %w", index, status, err)
+		}
+	}
+}
+
+// 每个分片都有一个 STARTED 副本位于 node 上时才能 shrink
+func (s *Server) waitShardsOnNode(ctx context.Context, index, node string) error {
+	u := fmt.Sprintf("%s/_cat/shards/%s?format=json&h=shard,state,node", s.cfg.ES.Host, url.PathEscape(index))
+	for {
+		resp, body, err := s.doGET(ctx, u, "es")
+		if err != nil {
+			return err
+		}
+		var rows []map[string]string
+		if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &rows) == nil {
+			shards, ready := map[string]bool{}, map[string]bool{}
+			for _, row := range rows {
+				shards[row["shard"]] = true
+				if row["node"] == node && row["state"] == "STARTED" {
+					ready[row["shard"]] = true
+				}
+			}
+			if len(shards) > 0 && len(ready) == len(shards) {
+				return nil
+			}
+		}
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return fmt.Errorf("wait for shards of %s to relocate to %s: %w", index, node, err)
+		}
+	}
+}
+
+func (s *Server) primaryNode(ctx context.Context, index string) (string, error) {
+	u := fmt.Sprintf("%s/_cat/shards/%s?format=json&h=prirep,state,node", s.cfg.ES.Host, url.PathEscape(index))
+	resp, body, err := s.doGET(ctx, u, "es")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("_cat/shards returned %s", resp.Status)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		if row["prirep"] == "p" && row["state"] == "STARTED" && row["node"] != "" {
+			return row["node"], nil
+		}
+	}
+	return "", fmt.Errorf("no started primary shard for %s", index)
+}
+
+// shrink 一个 backing index 并在数据流中替换它，返回新索引名。流程与 ILM shrink 一致：
+// 写保护 -> 分片集中到一个节点 -> _shrink -> 等待 green -> _data_stream/_modify 替换 -> 删除原索引
+func (s *Server) shrinkBackingIndex(ctx context.Context, j *Job, index string, shards int) (target string, err error) {
+	if s.isOpenSearch() {
+		return "", fmt.Errorf("shrinking data stream backing indices is not supported on OpenSearch")
+	}
+	target = "shrink-" + index
+	node, err := s.primaryNode(ctx, index)
+	if err != nil {
+		return "", err
+	}
+	if err := s.putIndexSettings(ctx, index, map[string]any{
+		"index.blocks.write":                     true,
+		"index.routing.allocation.require._name": node,
+	}); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			// 失败时撤销分片集中，避免原索引一直被钉在一个节点上
+			_ = s.putIndexSettings(context.Background(), index, map[string]any{"index.routing.allocation.require._name": nil})
+		}
+	}()
+	j.Step("shrink-allocate", "running", fmt.Sprintf("%s -> %s", index, node))
+	if err := s.waitShardsOnNode(ctx, index, node); err != nil {
+		return "", err
+	}
+
+	b, _ := json.Marshal(map[string]any{"settings": map[string]any{
+		"index.number_of_shards":                 shards,
+		"index.routing.allocation.require._name": nil,
+	}})
+	resp, body, err := s.doPOST(ctx, fmt.Sprintf("%s/%s/_shrink/%s", s.cfg.ES.Host, url.PathEscape(index), url.PathEscape(target)), b, "es")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("shrink %s: %s %s", index, resp.Status, body)
+	}
+	if err := s.waitIndexHealth(ctx, target, "green"); err != nil {
+		return "", err
+	}
+
+	b, _ = json.Marshal(map[string]any{"actions": []any{
+		map[string]any{"remove_backing_index": map[string]string{"data_stream": s.cfg.ES.Names.DataStream, "index": index}},
+		map[string]any{"add_backing_index": map[string]string{"data_stream": s.cfg.ES.Names.DataStream, "index": target}},
+	}})
+	resp, body, err = s.doPOST(ctx, s.cfg.ES.Host+"/_data_stream/_modify", b, "es")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("swap %s -> %s in data stream: %s %s", index, target, resp.Status, body)
+	}
+	resp, body, err = s.doDELETE(ctx, fmt.Sprintf("%s/%s", s.cfg.ES.Host, url.PathEscape(index)), "es")
+	if err != nil || resp.StatusCode >= 400 {
+		// 数据已在新索引中，删除失败只记录，不算失败
+		j.Step("shrink-cleanup", "failed", fmt.Sprintf("delete %s: %v %s", index, err, body))
+	}
+	j.Step("shrink", "ok", fmt.Sprintf("%s -> %s (%d shards)", index, target, shards))
+	return target, nil
+}
+
+func (s *Server) runForcemerge(ctx context.Context, j *Job, req forcemergeRequest) (any, error) {
+	candidates, err := s.mergeCandidates(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var todo []mergeCandidate
+	for _, c := range candidates {
+		if c.Merge {
+			todo = append(todo, c)
+		}
+	}
+	j.Step("select", "ok", fmt.Sprintf("phases=%s candidates=%d selected=%d", strings.Join(req.Phases, ","), len(candidates), len(todo)))
+	merged, shrunk := []string{}, map[string]string{}
+	partial := func() map[string]any { return map[string]any{"merged": merged, "shrunk": shrunk} }
+	for i, c := range todo {
+		j.SetProgress("index", c.Index)
+		j.SetProgress("done", i)
+		j.SetProgress("total", len(todo))
+		index := c.Index
+		if c.Shrink {
+			if index, err = s.shrinkBackingIndex(ctx, j, c.Index, req.ShrinkShards); err != nil {
+				return partial(), err
+			}
+			shrunk[c.Index] = index
+		}
+		if err := s.forcemergeIndex(ctx, j, index, req.MaxNumSegments); err != nil {
+			return partial(), err
+		}
+		merged = append(merged, index)
+		j.Step("forcemerge", "ok", index)
+	}
+	j.SetProgress("done", len(todo))
+	res := partial()
+	res["max_num_segments"] = req.MaxNumSegments
+	res["skipped"] = len(candidates) - len(todo)
+	return res, nil
+}
+
+// 计划任务：params.phases / max_num_segments / shrink_shards 与 POST /admin/es/forcemerge 相同
+func (s *Server) taskForcemerge(ctx context.Context, j *Job, params map[string]any) (any, error) {
+	req := forcemergeRequest{Phases: paramStrings(params, "phases")}
+	if p := paramString(params, "phase", ""); p != "" {
+		req.Phases = append(req.Phases, p)
+	}
+	var err error
+	if req.MaxNumSegments, err = paramInt(params, "max_num_segments", 1); err != nil {
+		return nil, err
+	}
+	if req.ShrinkShards, err = paramInt(params, "shrink_shards", 0); err != nil {
+		return nil, err
+	}
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	return s.runForcemerge(ctx, j, req)
+}
+
+func (s *Server) handleForcemergeCandidates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := forcemergeRequest{MaxNumSegments: atoiLoose(q.Get("max_num_segments")), ShrinkShards: atoiLoose(q.Get("shrink_shards"))}
+	if v := q.Get("phases"); v != "" {
+		req.Phases = strings.Split(v, ",")
+	}
+	if err := req.normalize(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	out, err := s.mergeCandidates(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("forcemerge-candidates", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleForcemerge(w http.ResponseWriter, r *http.Request) {
+	var req forcemergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if err := req.normalize(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.ShrinkShards > 0 && s.isOpenSearch() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "shrink_shards is not supported on OpenSearch"})
+		return
+	}
+	if len(req.Indices) > 0 {
+		backing, err := s.dataStreamIndices(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("forcemerge", err))
+			return
+		}
+		for _, name := range req.Indices {
+			if !slices.Contains(backing, name) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s is not a backing index of %s", name, s.cfg.ES.Names.DataStream)})
+				return
+			}
+		}
+	}
+	if req.DryRun {
+		out, err := s.mergeCandidates(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("forcemerge-plan", err))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dry_run": true, "candidates": out})
+		return
+	}
+	j := s.startJob("forcemerge", req, func(ctx context.Context, j *Job) (any, error) {
+		return s.runForcemerge(ctx, j, req)
+	})
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 变更日历与冻结窗口 **************/
+
+// freeze.windows 定义冻结窗口（如季度结算、节假日），窗口内拒绝 /admin 下的写请求（非 GET），返回 423：
+//   - cron + duration：每次 cron 触发起冻结 duration，如 "0 0 25 3,6,9,12 *" + "168h"
+//   - start / end：日期区间，"2026-12-24"（end 为日期时包含当天）、"2026-12-24 18:00" 或 RFC3339
+// 时间按 freeze.timezone 解释（默认本地时区）。冻结期间：
+//   - 管理员可覆盖：Authorization: Bearer <tenancy.admin_tokens 之一>，或 X-User-Token 为 freeze.override_users 中的用户，
+//     且带 X-Freeze-Override: <原因>；覆盖会记日志
+//   - 定时任务中会改动集群的（forcemerge）跳过本次运行，只读任务照常；connector 期望状态的自动恢复暂停
+//   - gRPC 的下发接口同样拒绝
+// freeze.allow 列出冻结期间仍放行的路径前缀（默认放行计划的创建与审批、grok 测试、手动触发定时任务；
+// 计划的下发与会改动集群的定时任务在处理函数中另行检查）。
+// GET /admin/calendar?days=30：冻结窗口与定时任务的排期（冻结中会被跳过的标出）。
+
+type FreezeConfig struct {
+	Timezone      string         `yaml:"timezone"`
+	Windows       []FreezeWindow `yaml:"windows"`
+	Allow         []string       `yaml:"allow"`          // 冻结期间放行的写接口路径前缀
+	OverrideUsers []string       `yaml:"override_users"` // 可覆盖冻结的 approvals.users
+}
+
+type FreezeWindow struct {
+	Name     string `yaml:"name"`
+	Reason   string `yaml:"reason"`
+	Cron     string `yaml:"cron"`
+	Duration string `yaml:"duration"`
+	Start    string `yaml:"start"`
+	End      string `yaml:"end"`
+}
+
+// 冻结期间默认放行的写接口：不改动集群
+var defaultFreezeAllow = []string{"/admin/plans", grokTestPath, "/admin/schedules/", "/admin/maintenance", "/admin/es/template/simulate"}
+
+// 冻结期间会被跳过的定时任务
+var freezeMutatingTasks = map[string]bool{taskForcemerge: true}
+
+type freezePeriod struct {
+	Name   string    `json:"name"`
+	Reason string    `json:"reason,omitempty"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+type freezeWindow struct {
+	cfg        FreezeWindow
+	spec       *cronSpec
+	dur        time.Duration
+	start, end time.Time
+}
+
+type freezeCalendar struct {
+	loc     *time.Location
+	windows []freezeWindow
+	allow   []string
+	users   []string
+}
+
+// 配置错误直接 panic，与 newScheduler 一致
+func newFreezeCalendar(cfg FreezeConfig) *freezeCalendar {
+	fc := &freezeCalendar{loc: time.Local, allow: defaultFreezeAllow, users: cfg.OverrideUsers}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			panic(fmt.Errorf("freeze.timezone: %w", err))
+		}
+		fc.loc = loc
+	}
+	if len(cfg.Allow) > 0 {
+		fc.allow = cfg.Allow
+	}
+	for i, c := range cfg.Windows {
+		field := fmt.Sprintf("freeze.windows[%d]", i)
+		if c.Name == "" {
+			c.Name = strconv.Itoa(i)
+		}
+		w := freezeWindow{cfg: c}
+		switch {
+		case c.Cron != "" && (c.Start != "" || c.End != ""):
+			panic(fmt.Errorf("%s: use either cron+duration or start/end", field))
+		case c.Cron != "":
+			spec, err := parseCron(c.Cron)
+			if err != nil {
+				panic(fmt.Errorf("%s.cron: %w", field, err))
+			}
+			if spec.every > 0 {
+				panic(fmt.Errorf("%s.cron: @every is not supported for freeze windows", field))
+			}
+			w.spec = spec
+			if w.dur = mustParseDuration(field+".duration", c.Duration); w.dur <= 0 {
+				panic(fmt.Errorf("%s.duration is required with cron", field))
+			}
+		default:
+			var err error
+			if w.start, _, err = parseFreezeTime(c.Start, fc.loc); err != nil {
+				panic(fmt.Errorf("%s.start: %w", field, err))
+			}
+			end, dateOnly, err := parseFreezeTime(c.End, fc.loc)
+			if err != nil {
+				panic(fmt.Errorf("%s.end: %w", field, err))
+			}
+			if dateOnly {
+				end = end.AddDate(0, 0, 1)
+			}
+			if !end.After(w.start) {
+				panic(fmt.Errorf("%s: end must be after start", field))
+			}
+			w.end = end
+		}
+		fc.windows = append(fc.windows, w)
+	}
+	return fc
+}
+
+// override_users 须是 approvals.users 中的用户
+func (c FreezeConfig) validate(a ApprovalsConfig) error {
+	for _, name := range c.OverrideUsers {
+		if !slices.ContainsFunc(a.Users, func(u ApprovalUser) bool { return u.Name == name }) {
+			return fmt.Errorf("freeze.override_users: %q is not in approvals.users", name)
+		}
+	}
+	return nil
+}
+
+// 返回时间与是否只有日期
+func parseFreezeTime(v string, loc *time.Location) (time.Time, bool, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, false, fmt.Errorf("is required (or use cron+duration)")
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", v, loc); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, false, fmt.Errorf("%q is not YYYY-MM-DD, YYYY-MM-DD HH:MM or RFC3339", v)
+	}
+	return t, false, nil
+}
+
+// now 所在的冻结期；有多个时取结束最晚的
+func (fc *freezeCalendar) active(now time.Time) (freezePeriod, bool) {
+	var out freezePeriod
+	found := false
+	for _, p := range fc.periods(now, now.Add(time.Nanosecond)) {
+		if !found || p.End.After(out.End) {
+			out, found = p, true
+		}
+	}
+	return out, found
+}
+
+// 与 [from, to) 有交集的冻结期，按开始时间排序
+func (fc *freezeCalendar) periods(from, to time.Time) []freezePeriod {
+	if fc == nil {
+		return nil
+	}
+	from, to = from.In(fc.loc), to.In(fc.loc)
+	var out []freezePeriod
+	for _, w := range fc.windows {
+		if w.spec == nil {
+			if w.start.Before(to) && w.end.After(from) {
+				out = append(out, freezePeriod{Name: w.cfg.Name, Reason: w.cfg.Reason, Start: w.start.In(fc.loc), End: w.end.In(fc.loc)})
+			}
+			continue
+		}
+		// cron.next 严格晚于参数，往前退一分钟以包含恰好在 from-dur 开始的一次
+		t := from.Add(-w.dur - time.Minute)
+		for range 1000 {
+			start := w.spec.next(t)
+			if start.IsZero() || !start.Before(to) {
+				break
+			}
+			if end := start.Add(w.dur); end.After(from) {
+				out = append(out, freezePeriod{Name: w.cfg.Name, Reason: w.cfg.Reason, Start: start, End: end})
+			}
+			t = start
+		}
+	}
+	slices.SortFunc(out, func(a, b freezePeriod) int { return a.Start.Compare(b.Start) })
+	return out
+}
+
+func (p freezePeriod) message() string {
+	msg := fmt.Sprintf("change freeze %q is in effect until %s", p.Name, p.End.Format(time.RFC3339))
+	if p.Reason != "" {
+		msg += " (" + p.Reason + ")"
+	}
+	return msg
+}
+
+// 管理员覆盖：admin token 或 override_users 中的用户，且带 X-Freeze-Override 原因
+func (s *Server) freezeOverride(r *http.Request) (who, reason string, ok bool) {
+	reason = strings.TrimSpace(r.Header.Get("X-Freeze-Override"))
+	if reason == "" {
+		return "", "", false
+	}
+	if tokenIn(bearerToken(r), s.cfg.Tenancy.AdminTokens) {
+		return "admin:" + operatorIdentity(r), reason, true
+	}
+	if user, ok := s.approvalUser(r); ok && slices.Contains(s.freeze.users, user) {
+		return user, reason, true
+	}
+	return "", "", false
+}
+
+// 冻结中返回 false 并写好 423；admin 覆盖时放行
+func (s *Server) checkFreeze(w http.ResponseWriter, r *http.Request) bool {
+	p, frozen := s.freeze.active(time.Now())
+	if !frozen {
+		return true
+	}
+	if who, reason, ok := s.freezeOverride(r); ok {
+		s.logger.Printf("step=freeze override window=%s by=%q reason=%q %s %s", p.Name, who, reason, r.Method, r.URL.Path)
+		return true
+	}
+	s.logger.Printf("step=freeze rejected window=%s %s %s from=%s", p.Name, r.Method, r.URL.Path, clientIP(r))
+	writeJSON(w, http.StatusLocked, map[string]any{"step": "freeze", "error": p.message(), "freeze": p,
+		"hint": "admins may override with X-Freeze-Override: <reason>"})
+	return false
+}
+
+// 包在 /admin 路由外层：冻结期间拒绝写请求（freeze.allow 中的路径除外）
+func (s *Server) freezeGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		// 租户接口按去掉 /t/{tenant} 后的路径匹配
+		path := r.URL.Path
+		if rest, ok := strings.CutPrefix(path, "/admin/t/"); ok {
+			if _, sub, found := strings.Cut(rest, "/"); found {
+				path = "/admin/" + sub
+			}
+		}
+		if len(s.freeze.windows) == 0 || slices.ContainsFunc(s.freeze.allow, func(p string) bool { return strings.HasPrefix(path, p) }) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.checkFreeze(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// 进程内调用的下发（gRPC）用
+func (s *Server) withFreeze(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.checkFreeze(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// 定时任务是否因冻结跳过；返回冻结期
+func (s *Server) freezeSkips(task string, at time.Time) (freezePeriod, bool) {
+	if !freezeMutatingTasks[task] {
+		return freezePeriod{}, false
+	}
+	return s.freeze.active(at)
+}
+
+type calendarRun struct {
+	Schedule string        `json:"schedule"`
+	Task     string        `json:"task"`
+	At       time.Time     `json:"at"`
+	Skipped  bool          `json:"skipped,omitempty"` // 落在冻结期内，届时跳过
+	Freeze   *freezePeriod `json:"freeze,omitempty"`
+}
+
+// GET /admin/calendar[?days=30]
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 366 {
+			writeJSON(w, 400, map[string]string{"error": "days must be 1-366"})
+			return
+		}
+		days = n
+	}
+	now := time.Now().In(s.freeze.loc)
+	to := now.AddDate(0, 0, days)
+	periods := s.freeze.periods(now, to)
+	runs := []calendarRun{}
+	for _, e := range s.sched.entries {
+		if e.cfg.Disabled || e.spec.every > 0 {
+			continue
+		}
+		for t := e.spec.next(now); !t.IsZero() && t.Before(to) && len(runs) < 1000; t = e.spec.next(t) {
+			run := calendarRun{Schedule: e.cfg.Name, Task: e.cfg.Task, At: t}
+			if p, ok := s.freezeSkips(e.cfg.Task, t); ok {
+				run.Skipped, run.Freeze = true, &p
+			}
+			runs = append(runs, run)
+		}
+	}
+	slices.SortFunc(runs, func(a, b calendarRun) int { return a.At.Compare(b.At) })
+	out := map[string]any{"timezone": s.freeze.loc.String(), "from": now, "to": to, "freezes": periods, "schedules": runs}
+	if p, ok := s.freeze.active(now); ok {
+		out["active"] = p
+	}
+	if periods == nil {
+		out["freezes"] = []freezePeriod{}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
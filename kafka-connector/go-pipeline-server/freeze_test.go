@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 冻结期间 grok 测试应放行；请求发往实际注册的路由
+func TestFreezeGateAllowsGrokTest(t *testing.T) {
+	s := &Server{
+		logger: log.New(io.Discard, "", 0),
+		freeze: newFreezeCalendar(FreezeConfig{Windows: []FreezeWindow{{Name: "always", Start: "2000-01-01", End: "2999-12-31"}}}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+grokTestPath, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("POST /admin/es/ilm", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := s.freezeGate(mux)
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{grokTestPath, http.StatusOK},
+		{"/admin/es/ilm", http.StatusLocked},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Errorf("POST %s: got %d, want %d", tc.path, rec.Code, tc.want)
+		}
+	}
+}
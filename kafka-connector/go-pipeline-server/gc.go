@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+)
+
+/************** 孤儿资源回收（GC） **************/
+
+// 带本服务归属标记（见 ownership.go）但已不在当前配置中的资源：改名后遗留的 pipeline / 模板 / 策略、
+// 从 sinks 中删除的 connector 等。只看标记值等于 ownership.managed_by 的资源，其他环境或手工维护的不动。
+// 删除顺序 connector → 模板 → pipeline → 策略，避免先删被引用的资源。
+
+const gcConnector = "connector"
+
+var gcKindOrder = []string{gcConnector, assetTemplate, assetPipeline, assetILM}
+
+type gcOrphan struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type gcRequest struct {
+	DryRun bool     `json:"dry_run"`
+	Kinds  []string `json:"kinds,omitempty"` // 只处理这些类型，默认全部
+	Names  []string `json:"names,omitempty"` // 只处理这些名字（须在 preview 结果中）
+}
+
+// 当前配置中的资源名
+func (s *Server) gcExpected() map[string][]string {
+	exp := map[string][]string{
+		assetILM:      {s.cfg.ES.Names.ILMPolicy},
+		assetTemplate: {s.cfg.ES.Names.IndexTemplate},
+		assetPipeline: {s.cfg.ES.Names.Pipeline},
+		gcConnector:   {s.archiveSinkName()},
+	}
+	for _, sc := range s.sinkConfigs() {
+		exp[gcConnector] = append(exp[gcConnector], sc.Name)
+	}
+	// operator 模式下各 LogPipeline CR 的资源同样在管
+	if s.operator != nil {
+		names, _ := s.operator.managedNames()
+		for k, v := range names {
+			exp[k] = append(exp[k], v...)
+		}
+	}
+	return exp
+}
+
+// 列出某类资源中带本服务标记的名字
+func (s *Server) gcManaged(ctx context.Context, kind string) ([]string, error) {
+	all, err := s.listMarked(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	mine := s.managedBy()
+	var out []string
+	for _, m := range all {
+		if m.Owner == mine {
+			out = append(out, m.Name)
+		}
+	}
+	return out, nil
+}
+
+// 带归属标记的资源（任意标记值）
+type markedResource struct {
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Owner  string            `json:"managed_by"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// 列出某类资源中带归属标记的资源，按名字排序
+func (s *Server) listMarked(ctx context.Context, kind string) ([]markedResource, error) {
+	u, dk := "", "es"
+	switch kind {
+	case assetILM:
+		u = s.cfg.ES.Host + "/_ilm/policy"
+	case assetTemplate:
+		u = s.cfg.ES.Host + "/_index_template"
+	case assetPipeline:
+		u = s.cfg.ES.Host + "/_ingest/pipeline"
+	case gcConnector:
+		u, dk = s.cfg.Connect.Host+"/connectors?expand=info", "connect"
+	}
+	resp, body, err := s.doGET(ctx, u, dk)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list %s returned %s", kind, resp.Status)
+	}
+	type meta struct {
+		Meta struct {
+			ManagedBy string            `json:"managed_by"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"_meta"`
+	}
+	var out []markedResource
+	add := func(name string, m meta) {
+		if m.Meta.ManagedBy != "" {
+			out = append(out, markedResource{Kind: kind, Name: name, Owner: m.Meta.ManagedBy, Labels: m.Meta.Labels})
+		}
+	}
+	switch kind {
+	case assetILM:
+		var doc map[string]struct {
+			Policy meta `json:"policy"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for name, p := range doc {
+			add(name, p.Policy)
+		}
+	case assetTemplate:
+		var doc struct {
+			IndexTemplates []struct {
+				Name          string `json:"name"`
+				IndexTemplate meta   `json:"index_template"`
+			} `json:"index_templates"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for _, t := range doc.IndexTemplates {
+			add(t.Name, t.IndexTemplate)
+		}
+	case assetPipeline:
+		var doc map[string]meta
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for name, p := range doc {
+			add(name, p)
+		}
+	case gcConnector:
+		var doc map[string]struct {
+			Info struct {
+				Config map[string]string `json:"config"`
+			} `json:"info"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		for name, c := range doc {
+			if owner := c.Info.Config[connectorManagedKey]; owner != "" {
+				out = append(out, markedResource{Kind: kind, Name: name, Owner: owner, Labels: parseLabelList(c.Info.Config[connectorLabelsKey])})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// 找出带标记但不在配置中的资源；OpenSearch 的 ISM 策略与 pipeline 没有标记，跳过
+func (s *Server) gcOrphans(ctx context.Context, kinds []string) ([]gcOrphan, error) {
+	if s.operator != nil {
+		if _, synced := s.operator.managedNames(); !synced {
+			return nil, fmt.Errorf("operator has not listed LogPipeline resources yet")
+		}
+	}
+	exp := s.gcExpected()
+	orphans := []gcOrphan{}
+	for _, kind := range gcKindOrder {
+		if len(kinds) > 0 && !slices.Contains(kinds, kind) {
+			continue
+		}
+		if kind != gcConnector && !s.ownershipTracked(kind) {
+			continue
+		}
+		names, err := s.gcManaged(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if !slices.Contains(exp[kind], n) {
+				orphans = append(orphans, gcOrphan{Kind: kind, Name: n})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+func (s *Server) gcDelete(ctx context.Context, o gcOrphan) error {
+	u, dk := "", "es"
+	name := url.PathEscape(o.Name)
+	switch o.Kind {
+	case assetILM:
+		u = s.cfg.ES.Host + "/_ilm/policy/" + name
+	case assetTemplate:
+		u = s.cfg.ES.Host + "/_index_template/" + name
+	case assetPipeline:
+		u = s.cfg.ES.Host + "/_ingest/pipeline/" + name
+	case gcConnector:
+		u, dk = s.cfg.Connect.Host+"/connectors/"+name, "connect"
+	}
+	resp, body, err := s.doDELETE(ctx, u, dk)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("%s: %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+func (s *Server) handleGCPreview(w http.ResponseWriter, r *http.Request) {
+	orphans, err := s.gcOrphans(r.Context(), nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("gc-preview", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"managed_by": s.managedBy(), "expected": s.gcExpected(), "orphans": orphans})
+}
+
+func (s *Server) handleGCRun(w http.ResponseWriter, r *http.Request) {
+	var req gcRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeInvalidBody(w, err)
+			return
+		}
+	}
+	for _, k := range req.Kinds {
+		if !slices.Contains(gcKindOrder, k) {
+			writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown kind %q", k), "kinds": gcKindOrder})
+			return
+		}
+	}
+	operator := operatorIdentity(r)
+	j := s.startJob("gc", req, func(ctx context.Context, j *Job) (any, error) {
+		orphans, err := s.gcOrphans(ctx, req.Kinds)
+		if err != nil {
+			return nil, err
+		}
+		var todo []gcOrphan
+		for _, o := range orphans {
+			if len(req.Names) == 0 || slices.Contains(req.Names, o.Name) {
+				todo = append(todo, o)
+			}
+		}
+		j.Step("scan", "ok", fmt.Sprintf("orphans=%d selected=%d", len(orphans), len(todo)))
+		if req.DryRun {
+			return map[string]any{"dry_run": true, "would_delete": todo}, nil
+		}
+		release, err := s.acquireLock(ctx, operator, "gc")
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		deleted, failed, skipped := []gcOrphan{}, map[string]string{}, []gcOrphan{}
+		for i, o := range todo {
+			if ctx.Err() != nil {
+				// job 被取消：剩余的不再删除
+				skipped = todo[i:]
+				j.Step("delete", "skipped", fmt.Sprintf("%d remaining: %v", len(skipped), context.Cause(ctx)))
+				break
+			}
+			j.SetProgress("done", i)
+			j.SetProgress("total", len(todo))
+			if err := s.gcDelete(ctx, o); err != nil {
+				failed[o.Kind+"/"+o.Name] = err.Error()
+				j.Step("delete", "failed", fmt.Sprintf("%s/%s: %v", o.Kind, o.Name, err))
+				continue
+			}
+			deleted = append(deleted, o)
+			j.Step("delete", "ok", o.Kind+"/"+o.Name)
+			s.logger.Printf("step=gc deleted kind=%s name=%s", o.Kind, o.Name)
+		}
+		res := map[string]any{"deleted": deleted, "failed": failed}
+		if len(skipped) > 0 {
+			res["skipped"] = skipped
+			return res, context.Cause(ctx)
+		}
+		if len(failed) > 0 {
+			return res, fmt.Errorf("%d of %d deletions failed", len(failed), len(todo))
+		}
+		return res, nil
+	})
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": j.ID, "dry_run": req.DryRun})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/************** GeoIP 数据库状态与 pipeline geoip 处理器校验 **************/
+
+// geoip 处理器找不到数据库或属性不匹配时不会报错，只是静默缺字段，因此在这里提前校验
+
+const defaultGeoIPDatabase = "GeoLite2-City.mmdb"
+
+// 各类数据库支持的 properties（自定义 mmdb 不校验）
+var geoipProperties = map[string][]string{
+	"city": {"ip", "country_iso_code", "country_name", "country_in_european_union", "continent_code", "continent_name",
+		"region_iso_code", "region_name", "city_name", "timezone", "location", "postal_code", "accuracy_radius",
+		"registered_country_iso_code", "registered_country_name"},
+	"country": {"ip", "country_iso_code", "country_name", "country_in_european_union", "continent_code", "continent_name",
+		"registered_country_iso_code", "registered_country_name"},
+	"asn": {"ip", "asn", "organization_name", "network"},
+}
+
+func geoipDatabaseKind(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.Contains(n, "city"):
+		return "city"
+	case strings.Contains(n, "country"):
+		return "country"
+	case strings.Contains(n, "asn"):
+		return "asn"
+	}
+	return ""
+}
+
+type pipelineProcessor struct {
+	Path   string         `json:"path"` // 如 processors[2].foreach.processor
+	Type   string         `json:"type"`
+	Config map[string]any `json:"config"`
+}
+
+// 递归展开 processors，包括 on_failure 与 foreach 内嵌的处理器
+func walkProcessors(procs []any, path string, fn func(p pipelineProcessor)) {
+	for i, raw := range procs {
+		m, _ := raw.(map[string]any)
+		for typ, c := range m {
+			cfg, _ := c.(map[string]any)
+			p := fmt.Sprintf("%s[%d].%s", path, i, typ)
+			fn(pipelineProcessor{Path: p, Type: typ, Config: cfg})
+			if onFailure, ok := cfg["on_failure"].([]any); ok {
+				walkProcessors(onFailure, p+".on_failure", fn)
+			}
+			if inner, ok := cfg["processor"].(map[string]any); ok && typ == "foreach" {
+				walkProcessors([]any{inner}, p+".processor", fn)
+			}
+		}
+	}
+}
+
+// 优先使用已部署的 pipeline；尚未部署时读取 es.files.pipeline
+func (s *Server) loadPipelineDoc(ctx context.Context) (map[string]any, string, error) {
+	url := fmt.Sprintf("%s/_ingest/pipeline/%s", s.cfg.ES.Host, s.cfg.ES.Names.Pipeline)
+	resp, body, err := s.doGET(ctx, url, "es")
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusOK {
+		var doc map[string]map[string]any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, "", fmt.Errorf("decode pipeline: %w", err)
+		}
+		if p, ok := doc[s.cfg.ES.Names.Pipeline]; ok {
+			return p, "live", nil
+		}
+	} else if resp.StatusCode != http.StatusNotFound {
+		return nil, "", fmt.Errorf("get pipeline returned %s", resp.Status)
+	}
+	b, err := s.readConfiguredAsset(assetPipeline, s.cfg.ES.Files.Pipeline)
+	if err != nil {
+		return nil, "", err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, "", fmt.Errorf("parse pipeline file: %w", err)
+	}
+	return doc, "file", nil
+}
+
+type geoipProcessorCheck struct {
+	Path     string   `json:"path"`
+	Field    string   `json:"field"`
+	Database string   `json:"database_file"`
+	OK       bool     `json:"ok"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type geoipReport struct {
+	Stats          any                   `json:"stats"`
+	Nodes          map[string][]string   `json:"nodes"` // node -> 可用数据库
+	Databases      []string              `json:"databases"`
+	PipelineSource string                `json:"pipeline_source"` // live | file
+	Processors     []geoipProcessorCheck `json:"processors"`
+	Warnings       []string              `json:"warnings,omitempty"`
+	OK             bool                  `json:"ok"`
+}
+
+func (s *Server) geoipReport(ctx context.Context) (*geoipReport, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_ingest/geoip/stats", "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("_ingest/geoip/stats returned %s", resp.Status)
+	}
+	var st struct {
+		Stats struct {
+			FailedDownloads  int `json:"failed_downloads"`
+			ExpiredDatabases int `json:"expired_databases"`
+		} `json:"stats"`
+		Nodes map[string]struct {
+			Databases []struct {
+				Name string `json:"name"`
+			} `json:"databases"`
+			ConfigDatabases []string `json:"config_databases"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		return nil, fmt.Errorf("decode geoip stats: %w", err)
+	}
+	var raw map[string]any
+	_ = json.Unmarshal(body, &raw)
+
+	rep := &geoipReport{Stats: raw["stats"], Nodes: map[string][]string{}, Processors: []geoipProcessorCheck{}}
+	union := map[string]bool{}
+	for node, n := range st.Nodes {
+		dbs := slices.Clone(n.ConfigDatabases)
+		for _, d := range n.Databases {
+			dbs = append(dbs, d.Name)
+		}
+		sort.Strings(dbs)
+		rep.Nodes[node] = slices.Compact(dbs)
+		for _, d := range dbs {
+			union[d] = true
+		}
+	}
+	for d := range union {
+		rep.Databases = append(rep.Databases, d)
+	}
+	sort.Strings(rep.Databases)
+	if st.Stats.FailedDownloads > 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("%d database downloads failed; check ingest.geoip.downloader settings and outbound access", st.Stats.FailedDownloads))
+	}
+	if st.Stats.ExpiredDatabases > 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("%d databases expired and are no longer used for lookups", st.Stats.ExpiredDatabases))
+	}
+
+	doc, source, err := s.loadPipelineDoc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rep.PipelineSource = source
+	var procs []pipelineProcessor
+	collect := func(p pipelineProcessor) {
+		if p.Type == "geoip" {
+			procs = append(procs, p)
+		}
+	}
+	top, _ := doc["processors"].([]any)
+	walkProcessors(top, "processors", collect)
+	onFailure, _ := doc["on_failure"].([]any)
+	walkProcessors(onFailure, "on_failure", collect)
+
+	rep.OK = st.Stats.ExpiredDatabases == 0
+	for _, p := range procs {
+		c := geoipProcessorCheck{Path: p.Path, Database: defaultGeoIPDatabase}
+		c.Field, _ = p.Config["field"].(string)
+		if db, _ := p.Config["database_file"].(string); db != "" {
+			c.Database = db
+		}
+		var missing []string
+		for node, dbs := range rep.Nodes {
+			if !slices.Contains(dbs, c.Database) {
+				missing = append(missing, node)
+			}
+		}
+		sort.Strings(missing)
+		switch {
+		case len(rep.Nodes) > 0 && len(missing) == len(rep.Nodes):
+			c.Errors = append(c.Errors, fmt.Sprintf("database %s is not available on any node", c.Database))
+		case len(missing) > 0:
+			c.Errors = append(c.Errors, fmt.Sprintf("database %s is missing on nodes %s", c.Database, strings.Join(missing, ",")))
+		}
+		if allowed, ok := geoipProperties[geoipDatabaseKind(c.Database)]; ok {
+			props, _ := p.Config["properties"].([]any)
+			for _, v := range props {
+				if name := fmt.Sprint(v); !slices.Contains(allowed, name) {
+					c.Errors = append(c.Errors, fmt.Sprintf("property %q is not provided by %s", name, c.Database))
+				}
+			}
+		}
+		if c.Field == "" {
+			c.Errors = append(c.Errors, "field is required")
+		}
+		if im, _ := p.Config["ignore_missing"].(bool); !im {
+			c.Warnings = append(c.Warnings, "ignore_missing is false: documents without the field fail the pipeline")
+		}
+		c.OK = len(c.Errors) == 0
+		rep.OK = rep.OK && c.OK
+		rep.Processors = append(rep.Processors, c)
+	}
+	return rep, nil
+}
+
+func (s *Server) handleGeoIPStatus(w http.ResponseWriter, r *http.Request) {
+	if s.isOpenSearch() {
+		writeJSON(w, 400, map[string]string{"error": "geoip stats are only available on elasticsearch"})
+		return
+	}
+	rep, err := s.geoipReport(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("geoip-status", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// verify 版本：pipeline 没有 geoip 处理器时跳过，校验失败返回 409
+func (s *Server) handleVerifyGeoIP(w http.ResponseWriter, r *http.Request) {
+	if s.isOpenSearch() {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "geoip stats are only available on elasticsearch"})
+		return
+	}
+	rep, err := s.geoipReport(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("verify-geoip", err))
+		return
+	}
+	if len(rep.Processors) == 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "pipeline has no geoip processor"})
+		return
+	}
+	code := http.StatusOK
+	if !rep.OK {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, rep)
+}
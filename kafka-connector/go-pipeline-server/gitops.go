@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+/************** GitOps：git push webhook 触发下发 **************/
+
+// GitHub（X-Hub-Signature-256）或 GitLab（X-Gitlab-Token）的 push 事件：校验签名 → 拉取资产仓库 →
+// 以 job 形式按 ilm → template → pipeline → sink 的顺序下发本次 push 改动过的资产（读取的是 push 的 commit）
+
+const maxWebhookBody = 5 << 20
+
+type gitPushEvent struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"` // GitLab
+	Deleted     bool   `json:"deleted"`      // GitHub：删除分支
+	Pusher      struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"pusher"` // GitHub
+	UserName  string `json:"user_name"`  // GitLab
+	UserEmail string `json:"user_email"` // GitLab
+	Commits   []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+}
+
+func (e gitPushEvent) commit() string {
+	if e.CheckoutSHA != "" {
+		return e.CheckoutSHA
+	}
+	return e.After
+}
+
+func (e gitPushEvent) operator() string {
+	name, email := e.Pusher.Name, e.Pusher.Email
+	if e.UserName != "" {
+		name, email = e.UserName, e.UserEmail
+	}
+	if email != "" {
+		return fmt.Sprintf("%s <%s>", name, email)
+	}
+	return name
+}
+
+// 本次 push 改动的文件；payload 不含 commits 时返回 nil（全部下发）
+func (e gitPushEvent) changed() map[string]bool {
+	if len(e.Commits) == 0 {
+		return nil
+	}
+	out := map[string]bool{}
+	for _, c := range e.Commits {
+		for _, f := range append(c.Added, c.Modified...) {
+			out[f] = true
+		}
+	}
+	return out
+}
+
+// 校验 webhook 来源，返回平台名
+func verifyWebhook(r *http.Request, body []byte, secret string) (string, error) {
+	if sig := r.Header.Get("X-Hub-Signature-256"); sig != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(sig), []byte(want)) {
+			return "", fmt.Errorf("invalid X-Hub-Signature-256")
+		}
+		return "github", nil
+	}
+	if tok := r.Header.Get("X-Gitlab-Token"); tok != "" {
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(secret)) != 1 {
+			return "", fmt.Errorf("invalid X-Gitlab-Token")
+		}
+		return "gitlab", nil
+	}
+	return "", fmt.Errorf("missing X-Hub-Signature-256 or X-Gitlab-Token")
+}
+
+type gitApplyTarget struct {
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"` // sink 名
+	File string `json:"file"`
+}
+
+// 按下发顺序列出 webhook_apply 中的资产；changed 非 nil 时只保留改动过的文件
+func (s *Server) gitApplyTargets(changed map[string]bool) []gitApplyTarget {
+	kinds := s.cfg.Git.WebhookApply
+	if len(kinds) == 0 {
+		kinds = assetKinds
+	}
+	var all []gitApplyTarget
+	for _, t := range []gitApplyTarget{
+		{Kind: assetILM, File: s.cfg.ES.Files.ILM},
+		{Kind: assetTemplate, File: s.cfg.ES.Files.Template},
+		{Kind: assetPipeline, File: s.cfg.ES.Files.Pipeline},
+	} {
+		if slices.Contains(kinds, t.Kind) {
+			all = append(all, t)
+		}
+	}
+	if slices.Contains(kinds, assetSink) {
+		for _, sc := range s.sinkConfigs() {
+			if sc.Type == sinkTypeConnect && sc.File != "" {
+				all = append(all, gitApplyTarget{Kind: assetSink, Name: sc.Name, File: sc.File})
+			}
+		}
+	}
+	var out []gitApplyTarget
+	for _, t := range all {
+		rel, err := s.git.rel(t.File)
+		if err != nil {
+			continue
+		}
+		if changed == nil || changed[rel] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// 复用各下发 handler；请求带 ?ref=<commit> 与 X-Operator，版本历史记录为 push 的作者
+func (s *Server) applyGitTarget(ctx context.Context, t gitApplyTarget, commit, operator string) (int, any) {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/admin/hooks/git?ref="+commit, nil)
+	r.RemoteAddr = "git-webhook:0"
+	r.Header.Set("X-Operator", operator)
+	r.Header.Set("User-Agent", "git-webhook")
+	cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+	switch t.Kind {
+	case assetILM:
+		s.handlePutILM(cw, r)
+	case assetTemplate:
+		s.handlePutTemplate(cw, r)
+	case assetPipeline:
+		s.handlePutPipeline(cw, r)
+	case assetSink:
+		r.SetPathValue("name", t.Name)
+		s.handleNamedSinkRegister(cw, r)
+	}
+	return cw.status, jsonRaw([]byte(cw.body))
+}
+
+func (s *Server) handleGitWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.git == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "git.enabled is false"})
+		return
+	}
+	if s.cfg.Git.WebhookSecret == "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "git.webhook_secret is not configured"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		return
+	}
+	platform, err := verifyWebhook(r, body, s.cfg.Git.WebhookSecret)
+	if err != nil {
+		s.logger.Printf("step=git-webhook rejected ip=%s err=%v", clientIP(r), err)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+	event := r.Header.Get("X-GitHub-Event")
+	if platform == "gitlab" {
+		event = r.Header.Get("X-Gitlab-Event")
+	}
+	switch event {
+	case "ping":
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "platform": platform})
+		return
+	case "push", "Push Hook", "Tag Push Hook":
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"ignored": true, "reason": fmt.Sprintf("event %q is not a push", event)})
+		return
+	}
+	var ev gitPushEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		writeJSON(w, 400, map[string]string{"error": "invalid payload: " + err.Error()})
+		return
+	}
+	branch := strings.TrimPrefix(ev.Ref, "refs/heads/")
+	if branch != s.git.cfg.Branch || ev.Deleted || strings.Trim(ev.commit(), "0") == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"ignored": true, "reason": fmt.Sprintf("push to %s, watching %s", ev.Ref, s.git.cfg.Branch)})
+		return
+	}
+
+	commit, operator := ev.commit(), ev.operator()
+	params := map[string]any{"platform": platform, "ref": ev.Ref, "commit": commit, "operator": operator}
+	s.logger.Printf("step=git-webhook platform=%s ref=%s commit=%s operator=%q", platform, ev.Ref, commit, operator)
+	j := s.startJob("git-apply", params, func(ctx context.Context, j *Job) (any, error) {
+		head, err := s.git.pull(ctx)
+		if err != nil {
+			return nil, err
+		}
+		j.Step("pull", "ok", "HEAD="+head)
+		targets := s.gitApplyTargets(ev.changed())
+		if len(targets) == 0 {
+			j.Step("apply", "skipped", "no asset files changed in this push")
+			return map[string]any{"applied": []gitApplyTarget{}}, nil
+		}
+		// 受保护环境不直接下发，改为生成待批准的计划（plans.go）
+		if s.cfg.Approvals.Protected {
+			req := planRequest{Note: fmt.Sprintf("git push %s by %s", commit, operator)}
+			for _, t := range targets {
+				if !slices.Contains(req.Kinds, t.Kind) {
+					req.Kinds = append(req.Kinds, t.Kind)
+				}
+				if t.Kind == assetSink {
+					req.Sinks = append(req.Sinks, t.Name)
+				}
+			}
+			p, err := s.createPlan(context.WithValue(ctx, assetRefKey{}, commit), req, "git:"+operator)
+			if err != nil {
+				return nil, err
+			}
+			j.Step("plan", "ok", fmt.Sprintf("plan %s awaits approval", p.ID))
+			return map[string]any{"targets": targets, "plan": p.summary()}, nil
+		}
+		// 整批下发期间持有锁（各 handler 为进程内直接调用，不经过 withLock）
+		release, err := s.acquireLock(ctx, operator, "git-apply "+commit)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		j.Step("lock", "ok", s.lockKey())
+		j.SetProgress("total", len(targets))
+		results := map[string]any{}
+		for i, t := range targets {
+			key := t.Kind
+			if t.Name != "" {
+				key += "/" + t.Name
+			}
+			code, res := s.applyGitTarget(ctx, t, commit, operator)
+			results[key] = res
+			j.SetProgress("done", i+1)
+			if code >= 300 {
+				j.Step("apply", "failed", fmt.Sprintf("%s: HTTP %d", key, code))
+				return map[string]any{"targets": targets, "results": results}, fmt.Errorf("apply %s at %s failed with HTTP %d", key, commit, code)
+			}
+			j.Step("apply", "ok", key)
+		}
+		return map[string]any{"targets": targets, "results": results}, nil
+	})
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": j.ID, "commit": commit, "platform": platform})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 资产文件存放在 git 仓库（可选） **************/
+
+// 开启后 es.files.* / sink 文件须位于 git.dir 之下：服务端每次改写资产文件都会以操作人身份提交
+// （配置了 remote 时随后 push）；下发接口带 ?ref=<分支|tag|commit> 时从该 ref 读取资产内容，
+// 工作区不受影响。依赖容器内的 git 命令。
+
+type GitConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Dir         string `yaml:"dir"`          // 仓库工作目录（资产文件所在的根目录）
+	Remote      string `yaml:"remote"`       // 可选：远端地址；目录不存在时 clone，提交后 push
+	Branch      string `yaml:"branch"`       // 默认 main
+	AuthorName  string `yaml:"author_name"`  // 请求未带 X-Operator 时的提交身份
+	AuthorEmail string `yaml:"author_email"` // 同上
+
+	// POST /admin/hooks/git：GitHub 用 HMAC 签名校验，GitLab 比对 X-Gitlab-Token
+	WebhookSecret string   `yaml:"webhook_secret"`
+	WebhookApply  []string `yaml:"webhook_apply"` // push 后下发的资产：ilm / template / pipeline / sink，默认全部
+}
+
+type gitStore struct {
+	mu  sync.Mutex
+	cfg GitConfig
+}
+
+func newGitStore(cfg GitConfig) *gitStore {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Dir == "" {
+		panic(fmt.Errorf("git.dir is required when git.enabled is true"))
+	}
+	if cfg.Branch == "" {
+		cfg.Branch = "main"
+	}
+	if cfg.AuthorName == "" {
+		cfg.AuthorName = "go-pipeline-server"
+	}
+	if cfg.AuthorEmail == "" {
+		cfg.AuthorEmail = "go-pipeline-server@localhost"
+	}
+	return &gitStore{cfg: cfg}
+}
+
+func (g *gitStore) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.cfg.Dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0",
+		"GIT_COMMITTER_NAME="+g.cfg.AuthorName, "GIT_COMMITTER_EMAIL="+g.cfg.AuthorEmail)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// 启动时准备仓库：有 remote 且目录不存在则 clone，否则确保已 init
+func (g *gitStore) init(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := os.Stat(filepath.Join(g.cfg.Dir, ".git")); err == nil {
+		if g.cfg.Remote != "" {
+			_, err := g.run(ctx, "pull", "--ff-only", "origin", g.cfg.Branch)
+			return err
+		}
+		return nil
+	}
+	if g.cfg.Remote != "" {
+		cmd := exec.CommandContext(ctx, "git", "clone", "--branch", g.cfg.Branch, g.cfg.Remote, g.cfg.Dir)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git clone %s: %w: %s", g.cfg.Remote, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if err := os.MkdirAll(g.cfg.Dir, 0o755); err != nil {
+		return err
+	}
+	_, err := g.run(ctx, "init", "-b", g.cfg.Branch)
+	return err
+}
+
+// 拉取远端分支（fast-forward）；没有 remote 时什么也不做
+func (g *gitStore) pull(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cfg.Remote != "" {
+		if _, err := g.run(ctx, "pull", "--ff-only", "origin", g.cfg.Branch); err != nil {
+			return "", err
+		}
+	}
+	out, err := g.run(ctx, "rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// 资产文件在仓库内的相对路径
+func (g *gitStore) rel(file string) (string, error) {
+	root, err := filepath.Abs(g.cfg.Dir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside git.dir %s", file, g.cfg.Dir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// 提交单个资产文件，返回 commit id；内容无变化时返回空
+func (g *gitStore) commit(ctx context.Context, file, message, author string) (string, error) {
+	rel, err := g.rel(file)
+	if err != nil {
+		return "", err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := g.run(ctx, "add", "--", rel); err != nil {
+		return "", err
+	}
+	if _, err := g.run(ctx, "diff", "--cached", "--quiet", "--", rel); err == nil {
+		return "", nil
+	}
+	if _, err := g.run(ctx, "commit", "--author", author, "-m", message, "--", rel); err != nil {
+		return "", err
+	}
+	out, err := g.run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(out)
+	if g.cfg.Remote != "" {
+		if _, err := g.run(ctx, "push", "origin", "HEAD:"+g.cfg.Branch); err != nil {
+			return id, fmt.Errorf("committed %s but push failed: %w", id[:12], err)
+		}
+	}
+	return id, nil
+}
+
+var gitRefRe = regexp.MustCompile(`^[A-Za-z0-9._/@^~-]+$`)
+
+// 读取某个 ref 下的资产文件；配置了 remote 时先 fetch，使远端分支/tag 可用
+func (g *gitStore) show(ctx context.Context, ref, file string) ([]byte, error) {
+	if !gitRefRe.MatchString(ref) || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+	rel, err := g.rel(file)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cfg.Remote != "" {
+		if _, err := g.run(ctx, "fetch", "--tags", "origin"); err != nil {
+			return nil, err
+		}
+	}
+	commit, err := g.run(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil && g.cfg.Remote != "" {
+		commit, err = g.run(ctx, "rev-parse", "--verify", "--quiet", "origin/"+ref+"^{commit}")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+	out, err := g.run(ctx, "show", strings.TrimSpace(commit)+":"+rel)
+	if err != nil {
+		return nil, fmt.Errorf("read %s at %s: %w", rel, ref, err)
+	}
+	return []byte(out), nil
+}
+
+type assetRefKey struct{}
+
+// 请求的 ?ref= 与 ?force=true 放进 ctx：ref 由 readAsset 使用，force 跳过归属校验（sink 方法只拿得到 ctx）
+func optionsContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if ref := r.URL.Query().Get("ref"); ref != "" {
+		ctx = context.WithValue(ctx, assetRefKey{}, ref)
+	}
+	if r.URL.Query().Get("force") == "true" {
+		ctx = context.WithValue(ctx, forceKey{}, true)
+	}
+	return ctx
+}
+
+// 读取资产文件；ctx 带 ref 时从 git 读取该版本
+func (s *Server) readAsset(ctx context.Context, file string) ([]byte, error) {
+	ref, _ := ctx.Value(assetRefKey{}).(string)
+	if ref == "" {
+		return readJSONFile(file)
+	}
+	if s.git == nil {
+		return nil, fmt.Errorf("?ref=%s requires git.enabled", ref)
+	}
+	return s.git.show(ctx, ref, file)
+}
+
+// 资产来源描述（写入版本历史）
+func assetSource(ctx context.Context, file string) string {
+	if ref, _ := ctx.Value(assetRefKey{}).(string); ref != "" {
+		return file + "@" + ref
+	}
+	return file
+}
+
+// 操作人身份：X-Operator 头（"Name <email>" 或名字），否则使用客户端 IP
+func operatorIdentity(r *http.Request) string {
+	if op := strings.TrimSpace(r.Header.Get("X-Operator")); op != "" {
+		return op
+	}
+	return clientIP(r)
+}
+
+// 服务端改写资产文件后调用；未开启 git 时返回空
+func (s *Server) commitAsset(r *http.Request, file, message string) (string, error) {
+	if s.git == nil {
+		return "", nil
+	}
+	op := operatorIdentity(r)
+	author := op
+	if !strings.Contains(op, "<") {
+		author = fmt.Sprintf("%s <%s>", op, s.git.cfg.AuthorEmail)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	id, err := s.git.commit(ctx, file, message+"\n\nOperator: "+op, author)
+	if err != nil {
+		s.logger.Printf("step=git-commit file=%s err=%v", file, err)
+		return id, err
+	}
+	if id != "" {
+		s.logger.Printf("step=git-commit file=%s commit=%s operator=%q", file, id[:12], op)
+	}
+	return id, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/************** Grok 调试：_ingest/pipeline/_simulate + 临时 grok 处理器 **************/
+
+const (
+	maxGrokSamples = 200
+	maxGrokTokens  = 64 // 定位失败位置时最多拆成的 pattern 片段数
+	grokPrefixKey  = "_grok_prefix"
+	grokTestPath   = "/admin/es/grok/test" // 路由与冻结放行（defaultFreezeAllow）共用
+)
+
+type grokTestRequest struct {
+	Patterns           []string          `json:"patterns"`                      // 依次尝试，第一个匹配的生效
+	Pattern            string            `json:"pattern,omitempty"`             // 单个 pattern 的简写
+	PatternDefinitions map[string]string `json:"pattern_definitions,omitempty"` // 自定义子 pattern
+	Field              string            `json:"field,omitempty"`               // 样本写入的字段，默认 message
+	Lines              []string          `json:"lines"`
+	ECSCompatibility   string            `json:"ecs_compatibility,omitempty"` // disabled | v1
+}
+
+type grokLineResult struct {
+	Line    string         `json:"line"`
+	Matched bool           `json:"matched"`
+	Pattern *int           `json:"pattern,omitempty"` // 命中的 pattern 下标
+	Fields  map[string]any `json:"fields,omitempty"`  // 提取出的字段（不含原始字段）
+	Error   string         `json:"error,omitempty"`
+	Failure *grokFailure   `json:"failure,omitempty"`
+}
+
+// 未匹配时第一个 pattern 能匹配到的位置
+type grokFailure struct {
+	Position      int    `json:"position"`       // 行内字符偏移，此前的内容已匹配
+	MatchedPrefix string `json:"matched_prefix"` // 已匹配的行前缀
+	FailedToken   string `json:"failed_token"`   // 第一个无法匹配的 pattern 片段
+	PatternOffset int    `json:"pattern_offset"` // 该片段在 pattern 中的偏移
+	Remaining     string `json:"remaining"`      // 未匹配的行内容
+}
+
+func (req *grokTestRequest) validate() error {
+	if req.Pattern != "" {
+		req.Patterns = append([]string{req.Pattern}, req.Patterns...)
+	}
+	if len(req.Patterns) == 0 {
+		return fmt.Errorf("pattern or patterns is required")
+	}
+	if len(req.Lines) == 0 {
+		return fmt.Errorf("lines is required")
+	}
+	if len(req.Lines) > maxGrokSamples {
+		return fmt.Errorf("at most %d lines per request", maxGrokSamples)
+	}
+	if req.Field == "" {
+		req.Field = "message"
+	}
+	return nil
+}
+
+// 每行一个 doc；trace_match 让 ES 在 _ingest._grok_match_index 中返回命中的 pattern 下标
+func (req grokTestRequest) simulateBody() []byte {
+	grok := map[string]any{"field": req.Field, "patterns": req.Patterns, "trace_match": true}
+	if len(req.PatternDefinitions) > 0 {
+		grok["pattern_definitions"] = req.PatternDefinitions
+	}
+	if req.ECSCompatibility != "" {
+		grok["ecs_compatibility"] = req.ECSCompatibility
+	}
+	docs := make([]any, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		docs = append(docs, map[string]any{"_source": map[string]any{req.Field: l}})
+	}
+	b, _ := json.Marshal(map[string]any{
+		"pipeline": map[string]any{"processors": []any{map[string]any{"grok": grok}}},
+		"docs":     docs,
+	})
+	return b
+}
+
+func (s *Server) handleGrokTest(w http.ResponseWriter, r *http.Request) {
+	var req grokTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	url := s.cfg.ES.Host + "/_ingest/pipeline/_simulate"
+	resp, body, err := s.doPOST(r.Context(), url, req.simulateBody(), "es")
+	if err != nil {
+		writeJSON(w, 500, errorBody("grok-test", err))
+		return
+	}
+	// pattern 本身无法编译时 ES 直接返回 400，原样透出便于定位
+	if resp.StatusCode != http.StatusOK {
+		writeJSON(w, resp.StatusCode, map[string]any{"step": "grok-test", "status": resp.Status, "body": jsonRaw(body)})
+		return
+	}
+	var sim struct {
+		Docs []struct {
+			Doc *struct {
+				Source map[string]any `json:"_source"`
+				Ingest struct {
+					MatchIndex *string `json:"_grok_match_index"`
+				} `json:"_ingest"`
+			} `json:"doc"`
+			Error *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"docs"`
+	}
+	if err := json.Unmarshal(body, &sim); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"step": "grok-test", "error": "decode simulate response: " + err.Error()})
+		return
+	}
+
+	results := make([]grokLineResult, 0, len(req.Lines))
+	matched := 0
+	for i, line := range req.Lines {
+		res := grokLineResult{Line: line}
+		if i < len(sim.Docs) {
+			d := sim.Docs[i]
+			switch {
+			case d.Error != nil:
+				res.Error = d.Error.Reason
+				if res.Error == "" {
+					res.Error = d.Error.Type
+				}
+			case d.Doc != nil:
+				res.Matched = true
+				res.Fields = map[string]any{}
+				for k, v := range d.Doc.Source {
+					if k != req.Field {
+						res.Fields[k] = v
+					}
+				}
+				// 若 pattern 把结果写回原字段，则保留
+				if v, ok := d.Doc.Source[req.Field]; ok && v != line {
+					res.Fields[req.Field] = v
+				}
+				if mi := d.Doc.Ingest.MatchIndex; mi != nil {
+					if n, err := strconv.Atoi(*mi); err == nil {
+						res.Pattern = &n
+					}
+				}
+			}
+		}
+		if res.Matched {
+			matched++
+		}
+		results = append(results, res)
+	}
+	if failed := len(req.Lines) - matched; failed > 0 {
+		if err := s.locateGrokFailures(r.Context(), req, results); err != nil {
+			s.logger.Printf("grok test locate_failures_err=%v", err)
+		}
+	}
+	s.logger.Printf("grok test patterns=%d lines=%d matched=%d", len(req.Patterns), len(req.Lines), matched)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"patterns": req.Patterns,
+		"matched":  matched,
+		"total":    len(req.Lines),
+		"results":  results,
+	})
+}
+
+type grokToken struct {
+	text   string
+	offset int
+}
+
+// 把 pattern 拆成可逐步拼接的片段：%{...}、括号分组、字符类、转义与普通字符（含其后的量词）；
+// 相邻的普通字符合并为一个片段。每个前缀都是合法的正则。
+func splitGrokPattern(p string) []grokToken {
+	var atoms []grokToken
+	for i := 0; i < len(p); {
+		start := i
+		switch {
+		case strings.HasPrefix(p[i:], "%{"):
+			if j := strings.IndexByte(p[i:], '}'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(p)
+			}
+		case p[i] == '\\':
+			i += 2
+		case p[i] == '(':
+			depth := 0
+			for ; i < len(p); i++ {
+				if p[i] == '\\' {
+					i++
+					continue
+				}
+				if p[i] == '(' {
+					depth++
+				} else if p[i] == ')' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case p[i] == '[':
+			for i++; i < len(p) && p[i] != ']'; i++ {
+				if p[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(p[i:])
+			i += size
+		}
+		i = min(i, len(p))
+		// 量词跟随前一个原子
+		for i < len(p) && strings.IndexByte("*+?", p[i]) >= 0 {
+			i++
+		}
+		if i < len(p) && p[i] == '{' && !strings.HasPrefix(p[i:], "%{") {
+			if j := strings.IndexByte(p[i:], '}'); j >= 0 {
+				i += j + 1
+			}
+		}
+		atoms = append(atoms, grokToken{text: p[start:i], offset: start})
+	}
+	var out []grokToken
+	for _, a := range atoms {
+		if n := len(out); n > 0 && isLiteralRun(a.text) && out[n-1].offset+len(out[n-1].text) == a.offset && isLiteralRun(out[n-1].text) {
+			out[n-1].text += a.text
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func isLiteralRun(s string) bool {
+	return !strings.ContainsAny(s, "%\\()[]{}*+?^$.|")
+}
+
+// 用 verbose simulate 一次性跑完所有未匹配行：第 k 个处理器匹配 "^(?<_grok_prefix>前 k 个片段)"，
+// 最后一个成功的处理器即已匹配的前缀，其后一个片段就是失败位置
+func (s *Server) locateGrokFailures(ctx context.Context, req grokTestRequest, results []grokLineResult) error {
+	tokens := splitGrokPattern(strings.TrimPrefix(req.Patterns[0], "^"))
+	if len(tokens) > maxGrokTokens {
+		tokens = tokens[:maxGrokTokens]
+	}
+	var procs []any
+	prefix := ""
+	for k, t := range tokens {
+		prefix += t.text
+		grok := map[string]any{
+			"field":          req.Field,
+			"patterns":       []string{"^(?<" + grokPrefixKey + ">" + prefix + ")"},
+			"tag":            strconv.Itoa(k),
+			"ignore_failure": true,
+		}
+		if len(req.PatternDefinitions) > 0 {
+			grok["pattern_definitions"] = req.PatternDefinitions
+		}
+		if req.ECSCompatibility != "" {
+			grok["ecs_compatibility"] = req.ECSCompatibility
+		}
+		procs = append(procs, map[string]any{"grok": grok})
+	}
+	var idx []int
+	var docs []any
+	for i, res := range results {
+		if !res.Matched && res.Error != "" {
+			idx = append(idx, i)
+			docs = append(docs, map[string]any{"_source": map[string]any{req.Field: res.Line}})
+		}
+	}
+	if len(docs) == 0 || len(procs) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(map[string]any{"pipeline": map[string]any{"processors": procs}, "docs": docs})
+	resp, body, err := s.doPOST(ctx, s.cfg.ES.Host+"/_ingest/pipeline/_simulate?verbose=true", b, "es")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("simulate returned %s", resp.Status)
+	}
+	var sim struct {
+		Docs []struct {
+			ProcessorResults []struct {
+				Tag    string `json:"tag"`
+				Status string `json:"status"`
+				Doc    *struct {
+					Source map[string]any `json:"_source"`
+				} `json:"doc"`
+			} `json:"processor_results"`
+		} `json:"docs"`
+	}
+	if err := json.Unmarshal(body, &sim); err != nil {
+		return err
+	}
+	for n, d := range sim.Docs {
+		if n >= len(idx) {
+			break
+		}
+		res := &results[idx[n]]
+		last, matchedPrefix := -1, ""
+		for _, pr := range d.ProcessorResults {
+			k, err := strconv.Atoi(pr.Tag)
+			if err != nil || pr.Status != "success" || pr.Doc == nil || k <= last {
+				continue
+			}
+			if v, ok := pr.Doc.Source[grokPrefixKey].(string); ok {
+				last, matchedPrefix = k, v
+			}
+		}
+		f := &grokFailure{MatchedPrefix: matchedPrefix, Position: utf8.RuneCountInString(matchedPrefix)}
+		if last+1 < len(tokens) {
+			f.FailedToken, f.PatternOffset = tokens[last+1].text, tokens[last+1].offset
+		}
+		f.Remaining = strings.TrimPrefix(res.Line, matchedPrefix)
+		res.Failure = f
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-pipeline-server/pipelinepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/************** gRPC 管理接口 **************/
+
+// 与 REST 并行的 gRPC 服务（定义见 pipelinepb/pipeline.proto），供平台控制器调用；开启 reflection，
+// 可直接用 grpcurl 调试。下发类方法在进程内调用对应的 REST handler，归属校验、版本历史、git 提交等行为一致。
+// 鉴权同全局 /admin 接口：配置了 tenancy.tenants 时需 metadata authorization: Bearer <admin token>，租户 token 拒绝。
+
+type GRPCConfig struct {
+	Listen string `yaml:"listen"` // 如 ":9090"；留空不启用
+}
+
+type grpcAdmin struct {
+	pipelinepb.UnimplementedPipelineAdminServer
+	s *Server
+}
+
+func (s *Server) newGRPCServer() *grpc.Server {
+	gs := grpc.NewServer(grpc.ChainUnaryInterceptor(s.grpcLogger, s.grpcAuth), grpc.ChainStreamInterceptor(s.grpcStreamAuth))
+	pipelinepb.RegisterPipelineAdminServer(gs, &grpcAdmin{s: s})
+	reflection.Register(gs)
+	return gs
+}
+
+// 启动 gRPC 监听；grpc.listen 为空时返回 nil
+func (s *Server) serveGRPC() (*grpc.Server, error) {
+	if s.cfg.GRPC.Listen == "" {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", s.cfg.GRPC.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("grpc listen %s: %w", s.cfg.GRPC.Listen, err)
+	}
+	gs := s.newGRPCServer()
+	go func() {
+		if err := gs.Serve(lis); err != nil {
+			s.logger.Printf("grpc server error: %v", err)
+		}
+	}()
+	s.logger.Printf("grpc server listening on %s", s.cfg.GRPC.Listen)
+	return gs, nil
+}
+
+func (s *Server) grpcLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+	}
+	s.logger.Printf("grpc req method=%s ip=%s code=%s dur_ms=%.3f operator=%q",
+		info.FullMethod, ip, status.Code(err), float64(time.Since(start).Microseconds())/1000.0, grpcOperator(ctx))
+	return resp, err
+}
+
+// 与 REST 全局接口相同的 token 校验（tenantGate）：metadata authorization: Bearer <token>
+func (s *Server) grpcCheckToken(ctx context.Context) error {
+	tok := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			if t, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+				tok = strings.TrimSpace(t)
+			}
+		}
+	}
+	code, err := s.checkGlobalToken(tok)
+	switch {
+	case err == nil:
+		return nil
+	case code == http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+}
+
+func (s *Server) grpcAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.grpcCheckToken(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.grpcCheckToken(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func grpcOperator(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-operator"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// 构造等价的 HTTP 请求并调用 handler；写操作后与 REST 一样清空 GET 缓存
+func (g *grpcAdmin) call(ctx context.Context, method, path string, q url.Values, pathValues map[string]string, h http.HandlerFunc) *captureWriter {
+	target := path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	r, _ := http.NewRequestWithContext(ctx, method, target, nil)
+	r.RemoteAddr = "grpc:0"
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	r.Header.Set("User-Agent", "grpc")
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("user-agent"); len(v) > 0 {
+			r.Header.Set("User-Agent", v[0])
+		}
+	}
+	if op := grpcOperator(ctx); op != "" {
+		r.Header.Set("X-Operator", op)
+	}
+	for k, v := range pathValues {
+		r.SetPathValue(k, v)
+	}
+	cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+	h(cw, r)
+	if method != http.MethodGet {
+		g.s.cache.bust()
+	}
+	return cw
+}
+
+func applyQuery(ref string, force bool) url.Values {
+	q := url.Values{}
+	if ref != "" {
+		q.Set("ref", ref)
+	}
+	if force {
+		q.Set("force", "true")
+	}
+	return q
+}
+
+// REST 状态码映射为 gRPC 错误；2xx 返回 nil
+func grpcError(cw *captureWriter) error {
+	if cw.status < 300 {
+		return nil
+	}
+	msg := cw.body
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(cw.body), &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	code := codes.Internal
+	switch cw.status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		code = codes.FailedPrecondition
+	case http.StatusLocked:
+		code = codes.Aborted
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		code = codes.Unavailable
+	}
+	return status.Errorf(code, "HTTP %d: %s", cw.status, msg)
+}
+
+// JSON 响应体转 Struct；非对象时与 jsonRaw 一样包一层
+func bodyStruct(body string) *structpb.Struct {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		m = jsonRaw([]byte(body))
+	}
+	st, err := structpb.NewStruct(m)
+	if err != nil {
+		st, _ = structpb.NewStruct(map[string]any{"raw": body})
+	}
+	return st
+}
+
+// 任意值经 JSON 往返后转 Value（job 参数/结果中可能有结构体）
+func jsonValue(v any) *structpb.Value {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return structpb.NewStringValue(fmt.Sprint(v))
+	}
+	var x any
+	if err := json.Unmarshal(b, &x); err != nil {
+		return structpb.NewStringValue(string(b))
+	}
+	out, err := structpb.NewValue(x)
+	if err != nil {
+		return structpb.NewStringValue(string(b))
+	}
+	return out
+}
+
+func (g *grpcAdmin) apply(ctx context.Context, path string, q url.Values, pathValues map[string]string, h http.HandlerFunc) (*pipelinepb.ApplyResponse, error) {
+	cw := g.call(ctx, http.MethodPost, path, q, pathValues, g.s.withFreeze(g.s.withLock(h)))
+	if err := grpcError(cw); err != nil {
+		return nil, err
+	}
+	detail := bodyStruct(cw.body)
+	return &pipelinepb.ApplyResponse{
+		HttpStatus: int32(cw.status),
+		Result:     detail.GetFields()["result"].GetStringValue(),
+		Detail:     detail,
+	}, nil
+}
+
+func (g *grpcAdmin) sinkName(name string) string {
+	if name == "" {
+		return g.s.sinkConfigs()[0].Name
+	}
+	return name
+}
+
+func (g *grpcAdmin) CreateDataStream(ctx context.Context, req *pipelinepb.ApplyRequest) (*pipelinepb.ApplyResponse, error) {
+	return g.apply(ctx, "/admin/es/data-stream", applyQuery(req.GetRef(), req.GetForce()), nil, g.s.handleCreateDataStream)
+}
+
+func (g *grpcAdmin) ApplyILM(ctx context.Context, req *pipelinepb.ApplyRequest) (*pipelinepb.ApplyResponse, error) {
+	return g.apply(ctx, "/admin/es/ilm", applyQuery(req.GetRef(), req.GetForce()), nil, g.s.handlePutILM)
+}
+
+func (g *grpcAdmin) ApplyTemplate(ctx context.Context, req *pipelinepb.ApplyRequest) (*pipelinepb.ApplyResponse, error) {
+	return g.apply(ctx, "/admin/es/template", applyQuery(req.GetRef(), req.GetForce()), nil, g.s.handlePutTemplate)
+}
+
+func (g *grpcAdmin) ApplyPipeline(ctx context.Context, req *pipelinepb.ApplyRequest) (*pipelinepb.ApplyResponse, error) {
+	return g.apply(ctx, "/admin/es/pipeline", applyQuery(req.GetRef(), req.GetForce()), nil, g.s.handlePutPipeline)
+}
+
+func (g *grpcAdmin) RegisterSink(ctx context.Context, req *pipelinepb.SinkRequest) (*pipelinepb.ApplyResponse, error) {
+	name := g.sinkName(req.GetName())
+	return g.apply(ctx, "/admin/sinks/"+url.PathEscape(name), applyQuery(req.GetRef(), req.GetForce()),
+		map[string]string{"name": name}, g.s.handleNamedSinkRegister)
+}
+
+func (g *grpcAdmin) PauseSink(ctx context.Context, req *pipelinepb.SinkRequest) (*pipelinepb.ApplyResponse, error) {
+	name := g.sinkName(req.GetName())
+	return g.apply(ctx, "/admin/sinks/"+url.PathEscape(name)+"/pause", applyQuery("", req.GetForce()),
+		map[string]string{"name": name}, g.s.handleNamedSinkPause)
+}
+
+func (g *grpcAdmin) ResumeSink(ctx context.Context, req *pipelinepb.SinkRequest) (*pipelinepb.ApplyResponse, error) {
+	name := g.sinkName(req.GetName())
+	return g.apply(ctx, "/admin/sinks/"+url.PathEscape(name)+"/resume", applyQuery("", req.GetForce()),
+		map[string]string{"name": name}, g.s.handleNamedSinkResume)
+}
+
+// 启动中 / degraded 时 REST 返回 503，这里作为正常响应返回，由调用方看 status
+func (g *grpcAdmin) GetHealth(ctx context.Context, _ *pipelinepb.GetHealthRequest) (*pipelinepb.GetHealthResponse, error) {
+	cw := g.call(ctx, http.MethodGet, "/admin/health", nil, nil, g.s.handleHealth)
+	detail := bodyStruct(cw.body)
+	return &pipelinepb.GetHealthResponse{Status: detail.GetFields()["status"].GetStringValue(), Detail: detail}, nil
+}
+
+func (g *grpcAdmin) GetStatus(ctx context.Context, _ *pipelinepb.GetStatusRequest) (*pipelinepb.GetStatusResponse, error) {
+	cw := g.call(ctx, http.MethodGet, "/admin/status", nil, nil, g.s.handleLiveStatus)
+	if err := grpcError(cw); err != nil {
+		return nil, err
+	}
+	return &pipelinepb.GetStatusResponse{Status: bodyStruct(cw.body)}, nil
+}
+
+func (g *grpcAdmin) GetSinkStatus(ctx context.Context, req *pipelinepb.SinkRequest) (*pipelinepb.GetSinkStatusResponse, error) {
+	name := g.sinkName(req.GetName())
+	cw := g.call(ctx, http.MethodGet, "/admin/sinks/"+url.PathEscape(name)+"/status", nil,
+		map[string]string{"name": name}, g.s.handleNamedSinkStatus)
+	if err := grpcError(cw); err != nil {
+		return nil, err
+	}
+	out := &pipelinepb.GetSinkStatusResponse{Detail: bodyStruct(cw.body)}
+	// Connect 的 /status：{"connector":{"state":...},"tasks":[{"state":...}]}
+	var st struct {
+		Connector struct {
+			State string `json:"state"`
+		} `json:"connector"`
+		Tasks []struct {
+			State string `json:"state"`
+		} `json:"tasks"`
+	}
+	if json.Unmarshal([]byte(cw.body), &st) == nil {
+		out.ConnectorState = st.Connector.State
+		for _, t := range st.Tasks {
+			out.TaskStates = append(out.TaskStates, t.State)
+		}
+	}
+	return out, nil
+}
+
+func (g *grpcAdmin) VerifyAll(ctx context.Context, _ *pipelinepb.VerifyAllRequest) (*pipelinepb.VerifyAllResponse, error) {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/admin/verify/all", nil)
+	ok, failed, results := g.s.verifyAll(ctx, r)
+	out := &pipelinepb.VerifyAllResponse{Ok: ok, Failed: failed, Checks: map[string]*pipelinepb.VerifyCheck{}}
+	for name, res := range results {
+		out.Checks[name] = &pipelinepb.VerifyCheck{
+			Ok:         res.OK,
+			HttpStatus: int32(res.Status),
+			DurationMs: res.DurationMS,
+			Error:      res.Error,
+			Body:       jsonValue(res.Body),
+		}
+	}
+	return out, nil
+}
+
+func jobProto(j *Job) *pipelinepb.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := &pipelinepb.Job{
+		Id:        j.ID,
+		Kind:      j.Kind,
+		Status:    j.Status,
+		CreatedAt: timestamppb.New(j.CreatedAt),
+		Params:    jsonValue(j.Params),
+		Result:    jsonValue(j.Result),
+		Error:     j.Error,
+	}
+	if j.FinishedAt != nil {
+		out.FinishedAt = timestamppb.New(*j.FinishedAt)
+	}
+	for _, st := range j.Steps {
+		out.Steps = append(out.Steps, &pipelinepb.JobStep{Name: st.Name, Status: st.Status, Detail: st.Detail, At: timestamppb.New(st.At)})
+	}
+	if len(j.Progress) > 0 {
+		out.Progress = jsonValue(j.Progress).GetStructValue()
+	}
+	return out
+}
+
+func (g *grpcAdmin) ListJobs(ctx context.Context, req *pipelinepb.ListJobsRequest) (*pipelinepb.ListJobsResponse, error) {
+	out := &pipelinepb.ListJobsResponse{}
+	for _, j := range g.s.jobs.list() {
+		if req.GetKind() != "" && j.Kind != req.GetKind() {
+			continue
+		}
+		out.Jobs = append(out.Jobs, jobProto(j))
+	}
+	return out, nil
+}
+
+func (g *grpcAdmin) GetJob(ctx context.Context, req *pipelinepb.GetJobRequest) (*pipelinepb.Job, error) {
+	j, ok := g.s.jobs.get(req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetId())
+	}
+	return jobProto(j), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 下发护栏 **************/
+
+// guardrails 限制下发内容的规模，防止误配出 50 个分片的热索引、永不删除的数据或占满 worker 的 connector：
+//   - max_shards：模板 index.number_of_shards 上限
+//   - max_retention_days：ILM delete phase 的 min_age 上限；设置后 policy 必须有 delete phase
+//   - max_connector_tasks：connector tasks.max 上限
+//   - allowed_topic_prefixes：connector 消费的 topic（topics / topics.regex）与 kafka.topic 必须以其一开头
+// 在服务端下发前检查（ILM / 模板 / connector 的下发、回滚、git 下发、operator），不通过返回 422；
+// GET /admin/assets/lint 与 check-config 同样报告。租户的 tenancy.tenants[].guardrails 覆盖全局中非零的项。
+// 0 / 空表示不限。
+
+type GuardrailsConfig struct {
+	MaxShards            int      `yaml:"max_shards"`
+	MaxRetentionDays     int      `yaml:"max_retention_days"`
+	MaxConnectorTasks    int      `yaml:"max_connector_tasks"`
+	AllowedTopicPrefixes []string `yaml:"allowed_topic_prefixes"`
+}
+
+// o 中非零的项覆盖 g
+func (g GuardrailsConfig) merge(o GuardrailsConfig) GuardrailsConfig {
+	if o.MaxShards > 0 {
+		g.MaxShards = o.MaxShards
+	}
+	if o.MaxRetentionDays > 0 {
+		g.MaxRetentionDays = o.MaxRetentionDays
+	}
+	if o.MaxConnectorTasks > 0 {
+		g.MaxConnectorTasks = o.MaxConnectorTasks
+	}
+	if len(o.AllowedTopicPrefixes) > 0 {
+		g.AllowedTopicPrefixes = o.AllowedTopicPrefixes
+	}
+	return g
+}
+
+func (g GuardrailsConfig) topicAllowed(topic string) bool {
+	if len(g.AllowedTopicPrefixes) == 0 {
+		return true
+	}
+	for _, p := range g.AllowedTopicPrefixes {
+		if strings.HasPrefix(topic, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// topics.regex 须以允许的前缀（按字面量转义）开头
+func (g GuardrailsConfig) topicRegexAllowed(re string) bool {
+	if len(g.AllowedTopicPrefixes) == 0 {
+		return true
+	}
+	re = strings.TrimPrefix(re, "^")
+	for _, p := range g.AllowedTopicPrefixes {
+		if strings.HasPrefix(re, regexp.QuoteMeta(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析配置时调用：数值不能为负，kafka.topic 与租户 topic 符合前缀
+func (g GuardrailsConfig) validate(cfg *Config) error {
+	check := func(field string, g GuardrailsConfig) error {
+		if g.MaxShards < 0 || g.MaxRetentionDays < 0 || g.MaxConnectorTasks < 0 {
+			return fmt.Errorf("%s: limits must not be negative", field)
+		}
+		return nil
+	}
+	if err := check("guardrails", g); err != nil {
+		return err
+	}
+	if cfg.Kafka.Topic != "" && !g.topicAllowed(cfg.Kafka.Topic) {
+		return fmt.Errorf("kafka.topic %q does not start with any of guardrails.allowed_topic_prefixes %v", cfg.Kafka.Topic, g.AllowedTopicPrefixes)
+	}
+	for _, t := range cfg.Tenancy.Tenants {
+		tg := g.merge(t.Guardrails)
+		if err := check("tenancy.tenants["+t.Name+"].guardrails", t.Guardrails); err != nil {
+			return err
+		}
+		if t.Topic != "" && !tg.topicAllowed(t.Topic) {
+			return fmt.Errorf("tenancy.tenants[%s]: topic %q does not start with any of %v", t.Name, t.Topic, tg.AllowedTopicPrefixes)
+		}
+	}
+	return nil
+}
+
+type guardrailViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type guardrailError struct {
+	Kind       string
+	Violations []guardrailViolation
+}
+
+func (e *guardrailError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+	return fmt.Sprintf("%s rejected by guardrails: %s", e.Kind, strings.Join(msgs, "; "))
+}
+
+func (g GuardrailsConfig) checkILM(doc map[string]any) []guardrailViolation {
+	if g.MaxRetentionDays <= 0 {
+		return nil
+	}
+	policy, _ := doc["policy"].(map[string]any)
+	phases, _ := policy["phases"].(map[string]any)
+	del, ok := phases["delete"].(map[string]any)
+	if !ok {
+		return []guardrailViolation{{"max_retention_days", fmt.Sprintf("ILM policy has no delete phase; retention must be at most %dd", g.MaxRetentionDays)}}
+	}
+	age, _ := del["min_age"].(string)
+	if age == "" {
+		age = "0ms"
+	}
+	d, ok := parseESDuration(age)
+	if !ok {
+		return []guardrailViolation{{"max_retention_days", fmt.Sprintf("delete phase min_age %q is not a valid duration", age)}}
+	}
+	if d > time.Duration(g.MaxRetentionDays)*24*time.Hour {
+		return []guardrailViolation{{"max_retention_days", fmt.Sprintf("delete phase min_age=%s exceeds %dd", age, g.MaxRetentionDays)}}
+	}
+	return nil
+}
+
+func (g GuardrailsConfig) checkTemplate(doc map[string]any) []guardrailViolation {
+	if g.MaxShards <= 0 {
+		return nil
+	}
+	tpl, _ := doc["template"].(map[string]any)
+	settings, _ := tpl["settings"].(map[string]any)
+	v, ok := lookupSetting(settings, "index.number_of_shards")
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(fmt.Sprint(v))
+	if err != nil {
+		return []guardrailViolation{{"max_shards", fmt.Sprintf("index.number_of_shards %v is not a number", v)}}
+	}
+	if n > g.MaxShards {
+		return []guardrailViolation{{"max_shards", fmt.Sprintf("index.number_of_shards=%d exceeds %d", n, g.MaxShards)}}
+	}
+	return nil
+}
+
+func (g GuardrailsConfig) checkConnector(cfg map[string]string) []guardrailViolation {
+	var out []guardrailViolation
+	if g.MaxConnectorTasks > 0 {
+		if v := cfg["tasks.max"]; v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n > g.MaxConnectorTasks {
+				out = append(out, guardrailViolation{"max_connector_tasks", fmt.Sprintf("tasks.max=%s exceeds %d", v, g.MaxConnectorTasks)})
+			}
+		}
+	}
+	for _, t := range strings.Split(cfg["topics"], ",") {
+		if t = strings.TrimSpace(t); t != "" && !g.topicAllowed(t) {
+			out = append(out, guardrailViolation{"allowed_topic_prefixes", fmt.Sprintf("topic %s does not start with any of %v", t, g.AllowedTopicPrefixes)})
+		}
+	}
+	if re := cfg["topics.regex"]; re != "" && !g.topicRegexAllowed(re) {
+		out = append(out, guardrailViolation{"allowed_topic_prefixes", fmt.Sprintf("topics.regex %q is not anchored to any of %v", re, g.AllowedTopicPrefixes)})
+	}
+	return out
+}
+
+// 下发前检查；kind 为 ilm / template / sink，其他资产不受限制。解析失败交给后续步骤报告
+func (s *Server) checkGuardrails(kind string, b []byte) error {
+	g := s.cfg.Guardrails
+	var found []guardrailViolation
+	switch kind {
+	case assetILM, assetTemplate:
+		var doc map[string]any
+		if json.Unmarshal(b, &doc) != nil {
+			return nil
+		}
+		if kind == assetILM {
+			found = g.checkILM(doc)
+		} else {
+			found = g.checkTemplate(doc)
+		}
+	case assetSink:
+		var doc struct {
+			Config map[string]string `json:"config"`
+		}
+		if json.Unmarshal(b, &doc) != nil {
+			return nil
+		}
+		found = g.checkConnector(doc.Config)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	s.logger.Printf("step=%s guardrails rejected violations=%d first=%q", kind, len(found), found[0].Message)
+	return &guardrailError{Kind: kind, Violations: found}
+}
+
+func writeGuardrailError(w http.ResponseWriter, step string, e *guardrailError) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"step": step, "error": e.Error(), "violations": e.Violations})
+}
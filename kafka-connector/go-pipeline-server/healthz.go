@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/************** Kubernetes 探针 **************/
+
+// /healthz：进程存活即 200（liveness，不访问任何下游，避免 ES 抖动导致容器被重启）。
+// /readyz：配置已加载、未在关机，且 probes.require 中的下游可达才 200（readiness）；
+// 必需下游连续失败 failure_threshold 次才摘流量，短暂抖动期间仍返回 200（status=degraded）。
+// 与 /admin/health（兼容性明细）互不影响；两个路径不记请求日志。
+
+const (
+	defaultProbeTimeout   = 2 * time.Second
+	defaultProbeThreshold = 3
+)
+
+type ProbesConfig struct {
+	Require          []string `yaml:"require"`           // 必须可达的下游：es / connect；为空时下游异常只算 degraded
+	Timeout          string   `yaml:"timeout"`           // 单个下游检查超时，默认 2s
+	FailureThreshold int      `yaml:"failure_threshold"` // 连续失败多少次才判定不就绪，默认 3
+}
+
+type probeState struct {
+	require   []string
+	timeout   time.Duration
+	threshold int
+
+	mu       sync.Mutex
+	failures map[string]int // 下游连续失败次数
+	ready    bool           // 上一次 /readyz 结果，用于只在变化时记日志
+
+	draining atomic.Bool // 收到退出信号后置位，/readyz 立即返回 503
+}
+
+func newProbeState(cfg ProbesConfig) *probeState {
+	for _, k := range cfg.Require {
+		if k != "es" && k != "connect" {
+			panic(fmt.Errorf("probes.require: unknown downstream %q (es | connect)", k))
+		}
+	}
+	st := &probeState{
+		require:   cfg.Require,
+		timeout:   mustParseDuration("probes.timeout", cfg.Timeout),
+		threshold: cfg.FailureThreshold,
+		failures:  map[string]int{},
+	}
+	if st.timeout <= 0 {
+		st.timeout = defaultProbeTimeout
+	}
+	if st.threshold <= 0 {
+		st.threshold = defaultProbeThreshold
+	}
+	return st
+}
+
+type probeCheck struct {
+	OK       bool    `json:"ok"`
+	Required bool    `json:"required"`
+	Failures int     `json:"consecutive_failures"`
+	DurMS    float64 `json:"dur_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// 只看能否连通：有响应即可达，但 401/403（凭据问题）与 5xx 算失败；不走并发限制与下游日志
+func (s *Server) probeDownstream(ctx context.Context, kind string) error {
+	url := s.cfg.ES.Host + "/"
+	if kind == "connect" {
+		url = s.cfg.Connect.Host + "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if kind == "es" {
+		s.withESAuth(req)
+	} else {
+		s.withConnectAuth(req)
+	}
+	resp, err := s.send(req, kind)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= 500 {
+		return fmt.Errorf("%s returned %s", kind, resp.Status)
+	}
+	return nil
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.probes.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "shutting_down"})
+		return
+	}
+	st := s.probes
+	kinds := []string{"es", "connect"}
+	errs := make([]error, len(kinds))
+	durs := make([]time.Duration, len(kinds))
+	var wg sync.WaitGroup
+	for i, kind := range kinds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), st.timeout)
+			defer cancel()
+			start := time.Now()
+			errs[i] = s.probeDownstream(ctx, kind)
+			durs[i] = time.Since(start)
+		}()
+	}
+	wg.Wait()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	checks := map[string]probeCheck{}
+	ready, degraded := true, false
+	for i, kind := range kinds {
+		c := probeCheck{OK: errs[i] == nil, Required: slices.Contains(st.require, kind),
+			DurMS: float64(durs[i].Microseconds()) / 1000.0}
+		if errs[i] != nil {
+			st.failures[kind]++
+			c.Error = errs[i].Error()
+			degraded = true
+		} else {
+			st.failures[kind] = 0
+		}
+		c.Failures = st.failures[kind]
+		if c.Required && c.Failures >= st.threshold {
+			ready = false
+		}
+		checks[kind] = c
+	}
+	if ready != st.ready {
+		s.logger.Printf("step=readyz ready=%v checks=%+v", ready, checks)
+		st.ready = ready
+	}
+
+	status, code := "ready", http.StatusOK
+	switch {
+	case !ready:
+		status, code = "not_ready", http.StatusServiceUnavailable
+	case degraded:
+		status = "degraded"
+	}
+	writeJSON(w, code, map[string]any{"status": status, "config": "loaded", "checks": checks, "failure_threshold": st.threshold})
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 请求日志实时流（SSE，/admin/logs/http） **************/
+
+// requestLogger 记录的 /admin/* 请求与 logDownstream 记录的下游调用，除写入进程日志外同时发布到这里，
+// 浏览器用 EventSource 订阅即可在排障时实时查看，无需登录主机。
+// 参数：
+//   - path=<前缀>        只看匹配前缀的请求（下游调用不受影响）
+//   - min_status=<code>  只看状态码 >= code 的请求与下游调用（下游出错的 status 为 0，总是保留）
+//   - downstream=false   不推送下游调用
+//   - backlog=<n>        连接时先补发最近 n 条（默认 50，最多 httpLogRing）
+// 断线重连时浏览器带 Last-Event-ID，从环形缓冲中补发之后的记录。
+// 慢客户端的发送队列满时丢弃记录，并以 event: dropped 告知丢了多少条。
+
+const (
+	httpLogPath    = "/admin/logs/http"
+	httpLogRing    = 500
+	httpLogBacklog = 50
+	httpLogQueue   = 256
+)
+
+type httpLogEntry struct {
+	Seq  uint64    `json:"seq"`
+	At   time.Time `json:"at"`
+	Kind string    `json:"kind"` // request | downstream
+
+	// request
+	Method   string  `json:"method"`
+	Path     string  `json:"path,omitempty"`
+	Query    string  `json:"query,omitempty"`
+	Origin   string  `json:"origin,omitempty"`
+	IP       string  `json:"ip,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+	Status   int     `json:"status"`
+	Bytes    int     `json:"bytes,omitempty"`
+	DurMS    float64 `json:"dur_ms,omitempty"`
+	ReqBytes string  `json:"req_bytes,omitempty"`
+	UA       string  `json:"ua,omitempty"`
+	// 内层中间件与 handler 附加的字段，如 tenant、idempotent_replay（middleware.go logField）
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// downstream
+	Target string `json:"target,omitempty"` // es|put、connect|get 等，同 logDownstream 的 kind
+	URL    string `json:"url,omitempty"`
+	File   string `json:"file,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Body   string `json:"body,omitempty"` // 仅失败时带响应片段（已脱敏）
+}
+
+type httpLogSub struct {
+	ch      chan httpLogEntry
+	dropped int
+}
+
+type httpLogFeed struct {
+	mu     sync.Mutex
+	redact *redactor
+	seq    uint64
+	ring   []httpLogEntry
+	subs   map[*httpLogSub]struct{}
+	closed bool
+}
+
+func newHTTPLogFeed(redact *redactor) *httpLogFeed {
+	return &httpLogFeed{redact: redact, subs: map[*httpLogSub]struct{}{}}
+}
+
+func (f *httpLogFeed) publish(e httpLogEntry) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	e.Seq = f.seq
+	if len(f.ring) == httpLogRing {
+		copy(f.ring, f.ring[1:])
+		f.ring = f.ring[:httpLogRing-1]
+	}
+	f.ring = append(f.ring, e)
+	for sub := range f.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// 订阅并取出要补发的记录：after > 0 时取 seq 之后的全部，否则取最近 backlog 条
+func (f *httpLogFeed) subscribe(after uint64, backlog int) (*httpLogSub, []httpLogEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub := &httpLogSub{ch: make(chan httpLogEntry, httpLogQueue)}
+	if f.closed {
+		close(sub.ch)
+		return sub, nil
+	}
+	f.subs[sub] = struct{}{}
+	var replay []httpLogEntry
+	if after > 0 {
+		for _, e := range f.ring {
+			if e.Seq > after {
+				replay = append(replay, e)
+			}
+		}
+	} else if backlog > 0 {
+		replay = f.ring[max(0, len(f.ring)-backlog):]
+	}
+	return sub, append([]httpLogEntry(nil), replay...)
+}
+
+func (f *httpLogFeed) unsubscribe(sub *httpLogSub) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// 取出并清零丢弃计数
+func (f *httpLogFeed) takeDropped(sub *httpLogSub) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := sub.dropped
+	sub.dropped = 0
+	return n
+}
+
+func (f *httpLogFeed) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+// 关闭所有订阅，SSE handler 随之返回（http.Server.Shutdown 会等待进行中的请求）
+func (f *httpLogFeed) closeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subs {
+		delete(f.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// 查询参数中 key 命中 redact.keys 的值替换为 ***
+func (f *httpLogFeed) redactQuery(raw string) string {
+	if raw == "" || f.redact == nil {
+		return raw
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	changed := false
+	for k, vs := range q {
+		if f.redact.matchKey(k) {
+			for i := range vs {
+				vs[i] = "***"
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return q.Encode()
+}
+
+// 去掉 URL 中的 user:password@
+func stripURLUserinfo(u string) string {
+	p, err := url.Parse(u)
+	if err != nil || p.User == nil {
+		return u
+	}
+	p.User = nil
+	return p.String()
+}
+
+func (f *httpLogFeed) publishRequest(r *http.Request, status, bytes int, dur time.Duration, reqBytes string, fields map[string]string) {
+	if f == nil || !strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == httpLogPath {
+		return
+	}
+	f.publish(httpLogEntry{
+		At: time.Now(), Kind: "request",
+		Method: r.Method, Path: r.URL.Path, Query: f.redactQuery(r.URL.RawQuery), Origin: r.Header.Get("Origin"),
+		IP: clientIP(r), Operator: strings.TrimSpace(r.Header.Get("X-Operator")),
+		Status: status, Bytes: bytes, DurMS: float64(dur.Microseconds()) / 1000.0, ReqBytes: reqBytes, UA: r.UserAgent(),
+		Fields: fields,
+	})
+}
+
+func (f *httpLogFeed) publishDownstream(kind, method, u, file string, status int, snippet []byte, err error) {
+	if f == nil {
+		return
+	}
+	e := httpLogEntry{At: time.Now(), Kind: "downstream", Target: kind, Method: method, URL: stripURLUserinfo(u), File: file, Status: status}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err != nil || status >= 400 {
+		if f.redact != nil {
+			snippet = f.redact.JSON(snippet)
+		}
+		e.Body = string(snippet)
+	}
+	f.publish(e)
+}
+
+type httpLogFilter struct {
+	pathPrefix string
+	minStatus  int
+	downstream bool
+}
+
+func (flt httpLogFilter) match(e httpLogEntry) bool {
+	if e.Kind == "downstream" {
+		if !flt.downstream {
+			return false
+		}
+		return e.Status == 0 || e.Status >= flt.minStatus
+	}
+	return strings.HasPrefix(e.Path, flt.pathPrefix) && e.Status >= flt.minStatus
+}
+
+func writeSSE(w http.ResponseWriter, event string, id uint64, v any) error {
+	b, _ := json.Marshal(v)
+	if id > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
+func (s *Server) handleHTTPLogStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	flt := httpLogFilter{pathPrefix: q.Get("path"), downstream: q.Get("downstream") != "false"}
+	if v := q.Get("min_status"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "min_status must be a non-negative integer"})
+			return
+		}
+		flt.minStatus = n
+	}
+	backlog := httpLogBacklog
+	if v := q.Get("backlog"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "backlog must be a non-negative integer"})
+			return
+		}
+		backlog = min(n, httpLogRing)
+	}
+	var after uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		after, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	rc := http.NewResponseController(w)
+	// 长连接：清除 http.Server 的写超时，由心跳维持
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sub, replay := s.httplog.subscribe(after, backlog)
+	defer s.httplog.unsubscribe(sub)
+	s.logger.Printf("logs-http connected ip=%s clients=%d path=%q min_status=%d", clientIP(r), s.httplog.count(), flt.pathPrefix, flt.minStatus)
+	defer s.logger.Printf("logs-http disconnected ip=%s", clientIP(r))
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, e := range replay {
+		if flt.match(e) {
+			if writeSSE(w, e.Kind, e.Seq, e) != nil {
+				return
+			}
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			if n := s.httplog.takeDropped(sub); n > 0 {
+				if writeSSE(w, "dropped", 0, map[string]int{"dropped": n}) != nil {
+					return
+				}
+			}
+			if !flt.match(e) {
+				continue
+			}
+			if writeSSE(w, e.Kind, e.Seq, e) != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
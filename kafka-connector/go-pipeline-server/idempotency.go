@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/************** 写操作的幂等键 **************/
+
+// POST / PUT / PATCH / DELETE 携带 Idempotency-Key 时，窗口期内同一个 key 只执行一次：
+//   - 首次请求照常执行，记录状态码与响应体（5xx 不记录，重试会重新执行）；
+//   - 重试直接返回原结果，并带 Idempotent-Replayed: true；
+//   - 原请求仍在执行时返回 409；同一 key 用于不同的方法/路径/请求体返回 422；
+//   - 处理中 panic 时释放 key；执行中的登记超过窗口期视为失效，重试可重新执行。
+// 前端网络抖动后的重试因此不会重复注册 connector。
+
+const (
+	defaultIdempotencyWindow = 10 * time.Minute
+	maxIdempotentBody        = 1 << 20 // 超过的响应不记录
+)
+
+type IdempotencyConfig struct {
+	Window string `yaml:"window"` // 记录保留时长，默认 10m
+}
+
+type idempotentEntry struct {
+	fingerprint string // method + path + query + 请求体 sha256
+	done        bool
+	status      int
+	contentType string
+	body        string
+	expires     time.Time // 执行中时为登记失效时间
+}
+
+type idempotencyStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*idempotentEntry
+}
+
+func newIdempotencyStore(cfg IdempotencyConfig) *idempotencyStore {
+	w := mustParseDuration("idempotency.window", cfg.Window)
+	if w <= 0 {
+		w = defaultIdempotencyWindow
+	}
+	return &idempotencyStore{window: w, entries: map[string]*idempotentEntry{}}
+}
+
+// 返回已有记录；不存在时登记为执行中并返回 nil
+func (st *idempotencyStore) begin(key, fingerprint string) *idempotentEntry {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	for k, e := range st.entries {
+		if now.After(e.expires) {
+			delete(st.entries, k)
+		}
+	}
+	if e, ok := st.entries[key]; ok {
+		cp := *e
+		return &cp
+	}
+	st.entries[key] = &idempotentEntry{fingerprint: fingerprint, expires: now.Add(st.window)}
+	return nil
+}
+
+// 未 finish 就结束（handler panic）时删除执行中的登记，避免重试一直 409
+func (st *idempotencyStore) release(key string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if e := st.entries[key]; e != nil && !e.done {
+		delete(st.entries, key)
+	}
+}
+
+func (st *idempotencyStore) finish(key string, cw *captureWriter) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e := st.entries[key]
+	if e == nil {
+		return
+	}
+	if cw.status >= 500 || len(cw.body) > maxIdempotentBody {
+		delete(st.entries, key)
+		return
+	}
+	e.done, e.status, e.contentType, e.body = true, cw.status, cw.hdr.Get("Content-Type"), cw.body
+	e.expires = time.Now().Add(st.window)
+}
+
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			key = ""
+		}
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeInvalidBody(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		fingerprint := r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + " " + hex.EncodeToString(sum[:])
+
+		if e := s.idem.begin(key, fingerprint); e != nil {
+			switch {
+			case e.fingerprint != fingerprint:
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used for a different request", "idempotency_key": key})
+			case !e.done:
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is still in progress", "idempotency_key": key})
+			default:
+				logField(r, "idempotent_replay", key)
+				w.Header().Set("Content-Type", e.contentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(e.status)
+				_, _ = w.Write([]byte(e.body))
+			}
+			return
+		}
+
+		defer s.idem.release(key)
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		s.idem.finish(key, cw)
+		for k, v := range cw.hdr {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(cw.status)
+		_, _ = w.Write([]byte(cw.body))
+	})
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+/************** 接管现有资源（brownfield 导入） **************/
+
+// POST /admin/import 从 ES / Connect 读取当前部署的 ILM 策略、索引模板、ingest pipeline、connector，
+// 整理成资产文件格式（去掉 ES 返回的只读字段与归属标记）后：
+//   1. 写入配置的资产文件（es.files.* / sink 文件，开启 git 时随后提交）；?target=versions 时只记入版本历史；
+//   2. 记一版资产版本（source=import），作为后续回滚的基线；
+//   3. 在集群中的资源上打归属标记（_meta.managed_by / managed.by），之后的下发与 GC 才会处理它。
+// 参数：kinds=ilm,template,pipeline,sink（默认全部）、sink=<name>（默认主 sink）、dry_run=true、force=true。
+// 已被其他实例标记的资源默认拒绝（409），force=true 时接管。connector 打标记会更新其配置，Connect 会重启任务。
+// connector 配置中有明文密钥（匹配 redact.keys 且不是 ${provider:...} 占位符）时拒绝导入（422）：
+// 读到的是脱敏后的值，且明文不应进入资产文件与版本历史，需先改用 Connect 的 config provider。
+
+type importResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Result    string `json:"result"` // imported | unchanged | not_found | would_import | failed
+	File      string `json:"file,omitempty"`
+	Version   int    `json:"version,omitempty"`
+	Owner     string `json:"previous_owner,omitempty"`
+	Marked    bool   `json:"marked"`
+	GitCommit string `json:"git_commit,omitempty"`
+	Note      string `json:"note,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	code int
+}
+
+// 集群中读到的资源：doc 为资产文件格式，mark 在资源上写入归属标记
+type importedDoc struct {
+	doc   []byte
+	owner string
+	mark  func(ctx context.Context) error // nil 表示该类资源不跟踪归属
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	ctx := optionsContext(r)
+	q := r.URL.Query()
+	kinds := assetKinds
+	if v := q.Get("kinds"); v != "" {
+		kinds = strings.Split(v, ",")
+		for _, k := range kinds {
+			if !slices.Contains(assetKinds, k) {
+				writeJSON(w, 400, map[string]any{"step": "import", "error": fmt.Sprintf("unknown asset kind %q", k), "kinds": assetKinds})
+				return
+			}
+		}
+	}
+	toFile := q.Get("target") != "versions"
+	dryRun := q.Get("dry_run") == "true"
+
+	results := []importResult{}
+	code := http.StatusOK
+	for _, kind := range kinds {
+		res := s.importAsset(ctx, r, kind, q.Get("sink"), toFile, dryRun)
+		s.logger.Printf("step=import kind=%s name=%s result=%s owner=%q marked=%v err=%s", kind, res.Name, res.Result, res.Owner, res.Marked, res.Error)
+		if res.code >= 300 && code == http.StatusOK {
+			code = res.code
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, code, map[string]any{"step": "import", "dry_run": dryRun, "results": results})
+}
+
+func (s *Server) importAsset(ctx context.Context, r *http.Request, kind, sinkName string, toFile, dryRun bool) importResult {
+	res := importResult{Kind: kind}
+	fail := func(code int, err error) importResult {
+		res.Result, res.Error, res.code = "failed", err.Error(), code
+		return res
+	}
+	var (
+		got *importedDoc
+		err error
+	)
+	switch kind {
+	case assetILM:
+		res.Name, res.File = s.cfg.ES.Names.ILMPolicy, s.cfg.ES.Files.ILM
+		got, err = s.importILM(ctx)
+	case assetTemplate:
+		res.Name, res.File = s.cfg.ES.Names.IndexTemplate, s.cfg.ES.Files.Template
+		got, err = s.importTemplate(ctx)
+	case assetPipeline:
+		res.Name, res.File = s.cfg.ES.Names.Pipeline, s.cfg.ES.Files.Pipeline
+		got, err = s.importPipeline(ctx)
+	case assetSink:
+		if sinkName == "" {
+			sinkName = s.primarySinkConfig().Name
+		}
+		sc, ok := s.findSinkConfig(sinkName)
+		if !ok {
+			return fail(http.StatusNotFound, fmt.Errorf("sink %q not configured", sinkName))
+		}
+		res.Name = sc.Name
+		switch sc.Type {
+		case sinkTypeConnect:
+			res.File = sc.File
+		case sinkTypeS3, sinkTypeMirrorMaker:
+			res.Note = sc.Type + " sink is rendered from config, recorded in version history only"
+		default:
+			return fail(http.StatusBadRequest, fmt.Errorf("sink %q (type %s) has no connector to import", sc.Name, sc.Type))
+		}
+		got, err = s.importConnector(ctx, sc.Name)
+	}
+	var redactedErr *redactedImportError
+	if errors.As(err, &redactedErr) {
+		return fail(http.StatusUnprocessableEntity, err)
+	}
+	if err != nil {
+		return fail(http.StatusBadGateway, err)
+	}
+	if got == nil {
+		res.Result, res.code = "not_found", http.StatusNotFound
+		return res
+	}
+	res.Owner = got.owner
+	if got.owner != "" && got.owner != s.managedBy() && !forced(ctx) {
+		return fail(http.StatusConflict, fmt.Errorf("%s %q is managed by %q (use force=true to take over)", kind, res.Name, got.owner))
+	}
+	if !toFile {
+		res.File = ""
+	}
+
+	unchanged := res.File != "" && sameJSONFile(res.File, got.doc)
+	if dryRun {
+		res.Result = "would_import"
+		if unchanged {
+			res.Result = "unchanged"
+		}
+		return res
+	}
+
+	if res.File != "" && !unchanged {
+		if err := writeJSONAsset(res.File, got.doc); err != nil {
+			return fail(http.StatusInternalServerError, err)
+		}
+		if id, err := s.commitAsset(r, res.File, fmt.Sprintf("Import %s %s from cluster", kind, res.Name)); err != nil {
+			res.Note = "git commit failed: " + err.Error()
+		} else {
+			res.GitCommit = id
+		}
+	}
+	res.Result = "imported"
+	if unchanged {
+		res.Result = "unchanged"
+	}
+
+	if got.mark != nil && got.owner != s.managedBy() {
+		if err := got.mark(ctx); err != nil {
+			return fail(http.StatusBadGateway, fmt.Errorf("mark managed: %w", err))
+		}
+		res.Marked = true
+	}
+	name := ""
+	if kind == assetSink {
+		name = res.Name
+	}
+	v := assetVersion{AppliedAt: time.Now().UTC(), AppliedBy: strings.TrimSpace(operatorIdentity(r) + " " + r.UserAgent()), Source: "import"}
+	if v, err := s.assets.record(assetKey(kind, name), got.doc, v); err != nil {
+		res.Note = strings.TrimSpace(res.Note + " version record failed: " + err.Error())
+	} else {
+		res.Version = v.Version
+	}
+	return res
+}
+
+// 文件存在且与 doc 语义相同
+func sameJSONFile(file string, doc []byte) bool {
+	b, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return false
+	}
+	var a, c any
+	if json.Unmarshal(b, &a) != nil || json.Unmarshal(doc, &c) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, c)
+}
+
+func writeJSONAsset(file string, doc []byte) error {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return err
+	}
+	b, _ := json.MarshalIndent(v, "", "  ")
+	file = filepath.Clean(file)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// 去掉 _meta.managed_by / labels（下发时会重新打上），_meta 为空则整个删掉
+func stripManagedMeta(obj map[string]any) string {
+	meta, _ := obj["_meta"].(map[string]any)
+	owner, _ := meta["managed_by"].(string)
+	delete(meta, "managed_by")
+	delete(meta, "labels")
+	if meta != nil && len(meta) == 0 {
+		delete(obj, "_meta")
+	}
+	return owner
+}
+
+func (s *Server) importGET(ctx context.Context, url, kind string) (map[string]any, error) {
+	resp, body, err := s.doGET(ctx, url, kind)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s: %s", url, resp.Status, string(body))
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", url, err)
+	}
+	return doc, nil
+}
+
+// 打标记：原样 PUT 回去（不经过归档/降采样叠加，避免改变现有行为）
+func (s *Server) importMarkES(url string, doc map[string]any, metaPath ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		b, _ := json.Marshal(doc)
+		b, err := s.stampMeta(b, metaPath...)
+		if err != nil {
+			return err
+		}
+		resp, body, err := s.doPUT(ctx, url, b, "es")
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: %s", resp.Status, string(body))
+		}
+		return nil
+	}
+}
+
+func (s *Server) importILM(ctx context.Context) (*importedDoc, error) {
+	url := s.lifecyclePolicyURL()
+	doc, err := s.importGET(ctx, url, "es")
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	if s.isOpenSearch() {
+		// ISM：文件中保留 ISM 格式，下发时原样使用；OpenSearch 下 ILM 不跟踪归属
+		policy, _ := doc["policy"].(map[string]any)
+		for _, k := range []string{"policy_id", "last_updated_time", "schema_version", "seq_no", "primary_term"} {
+			delete(policy, k)
+		}
+		b, _ := json.Marshal(map[string]any{"policy": policy})
+		return &importedDoc{doc: b}, nil
+	}
+	entry, _ := doc[s.cfg.ES.Names.ILMPolicy].(map[string]any)
+	policy, _ := entry["policy"].(map[string]any)
+	if policy == nil {
+		return nil, nil
+	}
+	owner := stripManagedMeta(policy)
+	b, _ := json.Marshal(map[string]any{"policy": policy})
+	var live map[string]any
+	_ = json.Unmarshal(b, &live)
+	return &importedDoc{doc: b, owner: owner, mark: s.importMarkES(url, live, "policy")}, nil
+}
+
+func (s *Server) importTemplate(ctx context.Context) (*importedDoc, error) {
+	url := fmt.Sprintf("%s/_index_template/%s", s.cfg.ES.Host, s.cfg.ES.Names.IndexTemplate)
+	doc, err := s.importGET(ctx, url, "es")
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	list, _ := doc["index_templates"].([]any)
+	if len(list) == 0 {
+		return nil, nil
+	}
+	entry, _ := list[0].(map[string]any)
+	tpl, _ := entry["index_template"].(map[string]any)
+	if tpl == nil {
+		return nil, nil
+	}
+	owner := stripManagedMeta(tpl)
+	b, _ := json.Marshal(tpl)
+	var live map[string]any
+	_ = json.Unmarshal(b, &live)
+	return &importedDoc{doc: b, owner: owner, mark: s.importMarkES(url, live)}, nil
+}
+
+func (s *Server) importPipeline(ctx context.Context) (*importedDoc, error) {
+	url := fmt.Sprintf("%s/_ingest/pipeline/%s", s.cfg.ES.Host, s.cfg.ES.Names.Pipeline)
+	doc, err := s.importGET(ctx, url, "es")
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	p, _ := doc[s.cfg.ES.Names.Pipeline].(map[string]any)
+	if p == nil {
+		return nil, nil
+	}
+	owner := stripManagedMeta(p)
+	b, _ := json.Marshal(p)
+	got := &importedDoc{doc: b, owner: owner}
+	if s.ownershipTracked(assetPipeline) {
+		var live map[string]any
+		_ = json.Unmarshal(b, &live)
+		got.mark = s.importMarkES(url, live)
+	}
+	return got, nil
+}
+
+func (s *Server) importConnector(ctx context.Context, name string) (*importedDoc, error) {
+	url := fmt.Sprintf("%s/connectors/%s/config", s.cfg.Connect.Host, name)
+	doc, err := s.importGET(ctx, url, "connect")
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	// doGET 返回的是脱敏后的配置：明文密钥已被替换为 ********，写回 Connect 或资产文件都会破坏 connector
+	if fields := redactedFields(doc); len(fields) > 0 {
+		return nil, &redactedImportError{name: name, fields: fields}
+	}
+	owner, _ := doc[connectorManagedKey].(string)
+	delete(doc, connectorManagedKey)
+	delete(doc, connectorLabelsKey)
+	delete(doc, "name") // 文件中 name 在顶层，config 里不重复
+	b, _ := json.Marshal(map[string]any{"name": name, "config": doc})
+	mark := func(ctx context.Context) error {
+		cfg := map[string]any{connectorManagedKey: s.managedBy(), "name": name}
+		for k, v := range doc {
+			cfg[k] = v
+		}
+		if labels := s.cfg.Ownership.Labels; len(labels) > 0 {
+			cfg[connectorLabelsKey] = formatLabelList(labels)
+		}
+		body, _ := json.Marshal(cfg)
+		resp, respBody, err := s.doPUT(ctx, url, body, "connect")
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: %s", resp.Status, string(respBody))
+		}
+		return nil
+	}
+	return &importedDoc{doc: b, owner: owner, mark: mark}, nil
+}
+
+type redactedImportError struct {
+	name   string
+	fields []string
+}
+
+func (e *redactedImportError) Error() string {
+	return fmt.Sprintf("connector %s has plaintext secrets in %s; move them to a Connect config provider (e.g. ${file:/path:key}) before importing",
+		e.name, strings.Join(e.fields, ", "))
+}
+
+// 值为脱敏占位的字段
+func redactedFields(doc map[string]any) []string {
+	var out []string
+	for k, v := range doc {
+		if v == redactedValue {
+			out = append(out, k)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/************** ingest 节点角色检查 **************/
+
+// ingest pipeline 在接收请求的 ingest 节点上执行（非 ingest 节点会转发给 ingest 节点）。
+// 没有专用 ingest 节点时 grok / geoip 等处理器与 data 节点的索引、查询争抢 CPU，写入与查询延迟一起变差。
+// 读取 _nodes/stats/ingest 的节点角色与本 pipeline 的执行统计：
+//   - 没有任何节点带 ingest 角色（node.ingest: false / node.roles 不含 ingest）：pipeline 无法执行，检查失败（409）
+//   - ingest 节点全部兼任 data 角色、本 pipeline 实际在 data 节点上执行过、只有一个 ingest 节点：给出警告
+// 结果见 GET /admin/verify/ingest-nodes（也包含在 /admin/verify/all 中）。未配置 es.names.pipeline 时跳过。
+
+type ingestNode struct {
+	Name      string   `json:"name"`
+	Roles     []string `json:"roles"`
+	Ingest    bool     `json:"ingest"`
+	Data      bool     `json:"data"`
+	Dedicated bool     `json:"dedicated_ingest"` // ingest 且不带任何 data 角色
+	// 本 pipeline 在该节点上的累计执行次数与耗时
+	PipelineCount  int64 `json:"pipeline_count"`
+	PipelineTimeMS int64 `json:"pipeline_time_ms"`
+}
+
+type ingestNodesReport struct {
+	OK              bool         `json:"ok"`
+	Pipeline        string       `json:"pipeline"`
+	Warnings        []string     `json:"warnings"`
+	IngestNodes     int          `json:"ingest_nodes"`
+	DedicatedIngest int          `json:"dedicated_ingest_nodes"`
+	Nodes           []ingestNode `json:"nodes"`
+}
+
+func isDataRole(role string) bool {
+	return role == "data" || strings.HasPrefix(role, "data_")
+}
+
+type ingestProcessorStats struct {
+	Count       int64 `json:"count"`
+	TimeInMilli int64 `json:"time_in_millis"`
+	Current     int64 `json:"current"`
+	Failed      int64 `json:"failed"`
+}
+
+type nodeIngestStats struct {
+	Name   string   `json:"name"`
+	Roles  []string `json:"roles"`
+	Ingest struct {
+		Pipelines map[string]struct {
+			ingestProcessorStats
+			// 每个元素为 {"<type>[:<tag>]": {"type": ..., "stats": {...}}}，顺序同 pipeline 定义
+			Processors []map[string]struct {
+				Type  string               `json:"type"`
+				Stats ingestProcessorStats `json:"stats"`
+			} `json:"processors"`
+		} `json:"pipelines"`
+	} `json:"ingest"`
+}
+
+// GET _nodes/stats/ingest，key 为节点 ID
+func (s *Server) nodesIngestStats(ctx context.Context) (map[string]nodeIngestStats, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_nodes/stats/ingest", "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nodes stats returned %s", resp.Status)
+	}
+	var doc struct {
+		Nodes map[string]nodeIngestStats `json:"nodes"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode nodes stats: %w", err)
+	}
+	return doc.Nodes, nil
+}
+
+func (s *Server) ingestNodesReport(ctx context.Context) (*ingestNodesReport, error) {
+	name := s.cfg.ES.Names.Pipeline
+	nodes, err := s.nodesIngestStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &ingestNodesReport{OK: true, Pipeline: name, Warnings: []string{}, Nodes: []ingestNode{}}
+	var busyData []string
+	for _, n := range nodes {
+		nd := ingestNode{Name: n.Name, Roles: n.Roles, Ingest: slices.Contains(n.Roles, "ingest"), Data: slices.ContainsFunc(n.Roles, isDataRole)}
+		nd.Dedicated = nd.Ingest && !nd.Data
+		if p, ok := n.Ingest.Pipelines[name]; ok {
+			nd.PipelineCount, nd.PipelineTimeMS = p.Count, p.TimeInMilli
+		}
+		if nd.Ingest {
+			rep.IngestNodes++
+		}
+		if nd.Dedicated {
+			rep.DedicatedIngest++
+		}
+		if nd.Ingest && nd.Data && nd.PipelineCount > 0 {
+			busyData = append(busyData, nd.Name)
+		}
+		rep.Nodes = append(rep.Nodes, nd)
+	}
+	sort.Slice(rep.Nodes, func(i, j int) bool { return rep.Nodes[i].Name < rep.Nodes[j].Name })
+	sort.Strings(busyData)
+
+	switch {
+	case rep.IngestNodes == 0:
+		rep.OK = false
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("no node has the ingest role: documents sent through pipeline %s will be rejected; enable node.roles: [ingest] on at least one node", name))
+	case rep.DedicatedIngest == 0:
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("no dedicated ingest nodes: pipeline %s runs on data nodes and competes with indexing and search for CPU", name))
+	}
+	if len(busyData) > 0 && rep.DedicatedIngest > 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("pipeline %s has executed on data nodes %s; point the sink's connection.url at the dedicated ingest nodes", name, strings.Join(busyData, ", ")))
+	}
+	if rep.IngestNodes == 1 {
+		rep.Warnings = append(rep.Warnings, "only one ingest node: it is a single point of failure for ingestion")
+	}
+	return rep, nil
+}
+
+func (s *Server) handleVerifyIngestNodes(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.ES.Names.Pipeline == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "es.names.pipeline is not configured"})
+		return
+	}
+	rep, err := s.ingestNodesReport(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("verify-ingest-nodes", err))
+		return
+	}
+	for _, warn := range rep.Warnings {
+		s.logger.Printf("verify=ingest-nodes warning=%q", warn)
+	}
+	code := http.StatusOK
+	if !rep.OK {
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, rep)
+}
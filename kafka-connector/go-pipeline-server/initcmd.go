@@ -0,0 +1,179 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/************** go-pipeline-server init：生成配置与资产文件 **************/
+
+// 以仓库自带的 config.yaml 与 elasticsearch/ connect/ 下的文件为模板（编译时内嵌，注释随之保留），
+// 按 -data-stream / -topic 替换名字后写入目标目录：
+//
+//	<dir>/config.yaml
+//	<dir>/elasticsearch/<ds>-ilm.json, <ds>-template.json, <ds>-pipeline.json
+//	<dir>/connect/sink-es-<ds>.json
+//
+// 已存在的文件不覆盖（-force 覆盖）。生成的配置先经 parseConfig 校验再落盘。
+
+var (
+	//go:embed config.yaml
+	initConfigYAML string
+	//go:embed elasticsearch/logs-ds-daily.json
+	initILMJSON string
+	//go:embed elasticsearch/logs-ds-template.json
+	initTemplateJSON string
+	//go:embed elasticsearch/pipeline.json
+	initPipelineJSON string
+	//go:embed connect/sink-es-app-logs.json
+	initSinkJSON string
+)
+
+var (
+	initDataStreamRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	initTopicRe      = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+)
+
+type initFile struct {
+	path    string
+	content string
+}
+
+// init 子命令入口，返回进程退出码
+func runInit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", ".", "Target directory")
+	ds := fs.String("data-stream", "logs-app-ds", "Data stream name")
+	topic := fs.String("topic", "app_logs.prod", "Kafka topic carrying the logs")
+	esHost := fs.String("es", "http://localhost:9200", "Elasticsearch URL")
+	connectHost := fs.String("connect", "http://localhost:8083", "Kafka Connect REST URL")
+	brokers := fs.String("brokers", "localhost:9092", "Kafka bootstrap servers, comma separated")
+	assetPath := fs.String("asset-path", "", "Directory the server reads the asset files from at runtime (default: absolute path of -dir)")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: go-pipeline-server init [flags]\n\nWrites a commented config.yaml plus ILM / index template / ingest pipeline / sink connector files.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	switch {
+	case !initDataStreamRe.MatchString(*ds):
+		fmt.Fprintf(stderr, "init: invalid -data-stream %q: lowercase letters, digits, '.', '_' and '-' only\n", *ds)
+		return 2
+	case !initTopicRe.MatchString(*topic):
+		fmt.Fprintf(stderr, "init: invalid -topic %q\n", *topic)
+		return 2
+	}
+	if *assetPath == "" {
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			fmt.Fprintf(stderr, "init: %v\n", err)
+			return 1
+		}
+		*assetPath = abs
+	}
+
+	files, err := renderInitFiles(initParams{
+		DataStream: *ds, Topic: *topic, ESHost: *esHost, ConnectHost: *connectHost,
+		Brokers: strings.Split(*brokers, ","), AssetPath: filepath.ToSlash(*assetPath),
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "init: %v\n", err)
+		return 1
+	}
+	if !*force {
+		var exist []string
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(*dir, f.path)); err == nil {
+				exist = append(exist, f.path)
+			}
+		}
+		if len(exist) > 0 {
+			fmt.Fprintf(stderr, "init: refusing to overwrite existing files in %s: %s (pass -force)\n", *dir, strings.Join(exist, ", "))
+			return 1
+		}
+	}
+	for _, f := range files {
+		dst := filepath.Join(*dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			fmt.Fprintf(stderr, "init: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(dst, []byte(f.content), 0o644); err != nil {
+			fmt.Fprintf(stderr, "init: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", dst)
+	}
+	fmt.Fprintf(stdout, "\nnext: review %s, then start the server with -config %s\n",
+		filepath.Join(*dir, "config.yaml"), filepath.Join(*dir, "config.yaml"))
+	return 0
+}
+
+type initParams struct {
+	DataStream  string
+	Topic       string
+	ESHost      string
+	ConnectHost string
+	Brokers     []string
+	AssetPath   string
+}
+
+func renderInitFiles(p initParams) ([]initFile, error) {
+	ds := p.DataStream
+	ilmName, tplName, pipeName, sinkName := ds+"-ilm", ds+"-template", ds+"-pipeline", "sink-es-"+ds
+	ilmFile, tplFile, pipeFile, sinkFile := "elasticsearch/"+ilmName+".json", "elasticsearch/"+tplName+".json",
+		"elasticsearch/"+pipeName+".json", "connect/"+sinkName+".json"
+
+	// 模板中的名字 -> 新名字；同一位置按参数顺序匹配，完整路径放在 /app/static/ 前面
+	quoted := make([]string, 0, len(p.Brokers))
+	for _, b := range p.Brokers {
+		if b = strings.TrimSpace(b); b != "" {
+			quoted = append(quoted, fmt.Sprintf("%q", b))
+		}
+	}
+	names := strings.NewReplacer(
+		"/app/static/elasticsearch/logs-ds-daily.json", p.AssetPath+"/"+ilmFile,
+		"/app/static/elasticsearch/logs-ds-template.json", p.AssetPath+"/"+tplFile,
+		"/app/static/elasticsearch/pipeline.json", p.AssetPath+"/"+pipeFile,
+		"/app/static/connect/sink-es-app-logs.json", p.AssetPath+"/"+sinkFile,
+		"/app/static/", p.AssetPath+"/",
+		`http://172.31.11.228:9200`, p.ESHost,
+		`http://172.31.11.228:8083`, p.ConnectHost,
+		`["172.31.11.228:19092"]`, "["+strings.Join(quoted, ", ")+"]",
+		`"http://elasticsearch:9200"`, fmt.Sprintf("%q", p.ESHost),
+		"sink-es-app-logs", sinkName,
+		"logs-ds-template", tplName,
+		"logs-ds-daily", ilmName,
+		"kafka-to-es", pipeName,
+		"logs-app-ds", ds,
+		"app_logs.prod", p.Topic,
+	)
+	files := []initFile{
+		{"config.yaml", names.Replace(initConfigYAML)},
+		{ilmFile, names.Replace(initILMJSON)},
+		{tplFile, names.Replace(initTemplateJSON)},
+		{pipeFile, names.Replace(initPipelineJSON)},
+		{sinkFile, names.Replace(initSinkJSON)},
+	}
+	cfg, err := parseConfigEnv([]byte(files[0].content), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("generated config does not parse: %w", err)
+	}
+	if cfg.ES.Names.DataStream != ds || cfg.Kafka.Topic != p.Topic {
+		return nil, fmt.Errorf("generated config has data_stream=%q topic=%q; the embedded config.yaml no longer matches the init template", cfg.ES.Names.DataStream, cfg.Kafka.Topic)
+	}
+	return files, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/************** 后台任务（job） **************/
+
+const maxJobsKept = 200
+
+const (
+	jobRunning     = "running"
+	jobSucceeded   = "succeeded"
+	jobFailed      = "failed"
+	jobInterrupted = "interrupted" // 关机时被取消
+	jobCancelled   = "cancelled"   // DELETE /admin/jobs/{id}
+)
+
+var errJobCancelled = errors.New("job cancelled")
+
+type JobStep struct {
+	Name   string    `json:"name"`
+	Status string    `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+type Job struct {
+	mu sync.Mutex
+
+	ID         string
+	Kind       string
+	Status     string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+	Params     any
+	Steps      []JobStep
+	Progress   map[string]any
+	Result     any
+	Error      string
+
+	cancel context.CancelCauseFunc
+}
+
+func (j *Job) Step(name, status, detail string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Steps = append(j.Steps, JobStep{Name: name, Status: status, Detail: detail, At: time.Now()})
+}
+
+func (j *Job) SetProgress(k string, v any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Progress == nil {
+		j.Progress = map[string]any{}
+	}
+	j.Progress[k] = v
+}
+
+// 并发安全的快照，供 JSON 输出
+func (j *Job) snapshot() map[string]any {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	steps := make([]JobStep, len(j.Steps))
+	copy(steps, j.Steps)
+	progress := make(map[string]any, len(j.Progress))
+	for k, v := range j.Progress {
+		progress[k] = v
+	}
+	out := map[string]any{
+		"id": j.ID, "kind": j.Kind, "status": j.Status, "created_at": j.CreatedAt,
+		"steps": steps,
+	}
+	if j.FinishedAt != nil {
+		out["finished_at"] = j.FinishedAt
+	}
+	if j.Params != nil {
+		out["params"] = j.Params
+	}
+	if len(progress) > 0 {
+		out["progress"] = progress
+	}
+	if j.Result != nil {
+		out["result"] = j.Result
+	}
+	if j.Error != "" {
+		out["error"] = j.Error
+	}
+	return out
+}
+
+type jobManager struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+}
+
+func newJobManager() *jobManager { return &jobManager{jobs: map[string]*Job{}} }
+
+func newJobID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(b))
+}
+
+func (m *jobManager) add(j *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[j.ID] = j
+	m.order = append(m.order, j.ID)
+	for len(m.order) > maxJobsKept {
+		delete(m.jobs, m.order[0])
+		m.order = m.order[1:]
+	}
+}
+
+func (m *jobManager) get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+func (m *jobManager) list() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		out = append(out, m.jobs[m.order[i]])
+	}
+	return out
+}
+
+// 仍在运行的 job 数（配置变更重启前等待其结束）
+func (m *jobManager) running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		if j.Status == jobRunning {
+			n++
+		}
+		j.mu.Unlock()
+	}
+	return n
+}
+
+// 启动后台 job；fn 返回的结果/错误写回 job
+func (s *Server) startJob(kind string, params any, fn func(ctx context.Context, j *Job) (any, error)) *Job {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	j := &Job{ID: newJobID(), Kind: kind, Status: jobRunning, CreatedAt: time.Now(), Params: params, Steps: []JobStep{}, cancel: cancel}
+	s.jobs.add(j)
+	s.logger.Printf("job id=%s kind=%s started", j.ID, kind)
+
+	go func() {
+		defer cancel(nil)
+		res, err := fn(ctx, j)
+		now := time.Now()
+		j.mu.Lock()
+		j.FinishedAt = &now
+		j.Result = res
+		switch {
+		case err != nil && errors.Is(context.Cause(ctx), errShutdown):
+			j.Status = jobInterrupted
+			j.Error = err.Error()
+		case ctx.Err() != nil && errors.Is(context.Cause(ctx), errJobCancelled):
+			// 已完成的步骤与 fn 返回的部分结果保留
+			j.Status = jobCancelled
+			if err != nil {
+				j.Error = err.Error()
+			}
+		case err != nil:
+			j.Status = jobFailed
+			j.Error = err.Error()
+		default:
+			j.Status = jobSucceeded
+		}
+		status := j.Status
+		j.mu.Unlock()
+		s.logger.Printf("job id=%s kind=%s status=%s dur_ms=%d err=%v", j.ID, kind, status, now.Sub(j.CreatedAt).Milliseconds(), err)
+		if err != nil && status == jobFailed {
+			s.notifyJobFailed(j, err)
+		}
+	}()
+	return j
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	out := []map[string]any{}
+	for _, j := range s.jobs.list() {
+		if kind != "" && j.Kind != kind {
+			continue
+		}
+		out = append(out, j.snapshot())
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
+
+// 取消运行中的 job：context 以 errJobCancelled 取消，后续步骤不再执行，已完成步骤与部分结果保留
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	j.mu.Lock()
+	if j.Status != jobRunning {
+		status := j.Status
+		j.mu.Unlock()
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "job is not running", "status": status})
+		return
+	}
+	by := operatorIdentity(r)
+	j.Steps = append(j.Steps, JobStep{Name: "cancel", Status: "requested", Detail: "by " + by, At: time.Now()})
+	j.cancel(errJobCancelled)
+	j.mu.Unlock()
+	s.logger.Printf("job id=%s kind=%s cancel requested by=%q", j.ID, j.Kind, by)
+
+	// 等 job 退出，最多几秒；仍未退出时返回 202，状态可继续轮询
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		j.mu.Lock()
+		done := j.Status != jobRunning
+		j.mu.Unlock()
+		if done {
+			writeJSON(w, http.StatusOK, j.snapshot())
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
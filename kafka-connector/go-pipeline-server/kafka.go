@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/************** Kafka（admin client） **************/
+
+type KafkaConfig struct {
+	Brokers  []string `yaml:"brokers"`
+	Topic    string   `yaml:"topic"` // 日志 topic
+	ClientID string   `yaml:"client_id"`
+	// sink 的 consumer group，用于计算消费延迟；默认 connect-<sink 名>
+	ConsumerGroup string `yaml:"consumer_group"`
+
+	SASL KafkaSASLConfig `yaml:"sasl"`
+	TLS  KafkaTLSConfig  `yaml:"tls"`
+
+	SchemaRegistry SchemaRegistryConfig `yaml:"schema_registry"` // Avro 解码用
+}
+
+var errKafkaNotConfigured = errors.New("kafka.brokers is not configured")
+
+func (s *Server) kafkaOpts(extra ...kgo.Opt) ([]kgo.Opt, error) {
+	clientID := s.cfg.Kafka.ClientID
+	if clientID == "" {
+		clientID = "log-pipeline-admin"
+	}
+	sec, err := s.kafkaSecurityOpts()
+	if err != nil {
+		return nil, err
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(s.cfg.Kafka.Brokers...),
+		kgo.ClientID(clientID),
+		kgo.DialTimeout(5 * time.Second),
+	}
+	opts = append(opts, sec...)
+	return append(opts, extra...), nil
+}
+
+// 共享的 admin 用 client，首次使用时创建
+func (s *Server) kafkaAdmin() (*kadm.Client, error) {
+	if len(s.cfg.Kafka.Brokers) == 0 {
+		return nil, errKafkaNotConfigured
+	}
+	s.kafkaMu.Lock()
+	defer s.kafkaMu.Unlock()
+	if s.kafka == nil {
+		opts, err := s.kafkaOpts()
+		if err != nil {
+			return nil, err
+		}
+		cl, err := kgo.NewClient(opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.kafka = cl
+	}
+	return kadm.NewClient(s.kafka), nil
+}
+
+func (s *Server) closeKafka() {
+	s.kafkaMu.Lock()
+	defer s.kafkaMu.Unlock()
+	if s.kafka != nil {
+		s.kafka.Close()
+		s.kafka = nil
+	}
+}
+
+func kafkaErrStatus(err error) int {
+	if errors.Is(err, errKafkaNotConfigured) {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+type kafkaBroker struct {
+	NodeID int32   `json:"node_id"`
+	Host   string  `json:"host"`
+	Port   int32   `json:"port"`
+	Rack   *string `json:"rack,omitempty"`
+}
+
+type kafkaPartitionIssue struct {
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	Leader    int32   `json:"leader"`
+	Replicas  []int32 `json:"replicas"`
+	ISR       []int32 `json:"isr"`
+}
+
+type kafkaClusterInfo struct {
+	ClusterID       string                `json:"cluster_id"`
+	Controller      int32                 `json:"controller"`
+	Brokers         []kafkaBroker         `json:"brokers"`
+	Topics          int                   `json:"topics"`
+	InternalTopics  int                   `json:"internal_topics"`
+	Partitions      int                   `json:"partitions"`
+	UnderReplicated []kafkaPartitionIssue `json:"under_replicated"`
+	Offline         []kafkaPartitionIssue `json:"offline"`
+	LogTopic        map[string]any        `json:"log_topic,omitempty"`
+}
+
+func (s *Server) kafkaClusterInfo(ctx context.Context) (*kafkaClusterInfo, error) {
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		return nil, err
+	}
+	md, err := adm.Metadata(ctx)
+	release()
+	if err != nil {
+		return nil, err
+	}
+	info := &kafkaClusterInfo{
+		ClusterID:       md.Cluster,
+		Controller:      md.Controller,
+		Brokers:         []kafkaBroker{},
+		UnderReplicated: []kafkaPartitionIssue{},
+		Offline:         []kafkaPartitionIssue{},
+	}
+	for _, b := range md.Brokers {
+		info.Brokers = append(info.Brokers, kafkaBroker{NodeID: b.NodeID, Host: b.Host, Port: b.Port, Rack: b.Rack})
+	}
+	for _, t := range md.Topics.Sorted() {
+		if t.IsInternal {
+			info.InternalTopics++
+		} else {
+			info.Topics++
+		}
+		for _, p := range t.Partitions.Sorted() {
+			info.Partitions++
+			issue := kafkaPartitionIssue{Topic: t.Topic, Partition: p.Partition, Leader: p.Leader, Replicas: p.Replicas, ISR: p.ISR}
+			if p.Leader < 0 {
+				info.Offline = append(info.Offline, issue)
+			} else if len(p.ISR) < len(p.Replicas) {
+				info.UnderReplicated = append(info.UnderReplicated, issue)
+			}
+		}
+	}
+	if name := s.cfg.Kafka.Topic; name != "" {
+		t, ok := md.Topics[name]
+		lt := map[string]any{"name": name, "exists": ok && t.Err == nil}
+		if ok && t.Err == nil {
+			lt["partitions"] = len(t.Partitions)
+			ids := t.Partitions.Numbers()
+			sort.Slice(ids, func(i, k int) bool { return ids[i] < ids[k] })
+			if len(ids) > 0 {
+				lt["replication_factor"] = len(t.Partitions[ids[0]].Replicas)
+			}
+		}
+		info.LogTopic = lt
+	}
+	return info, nil
+}
+
+func (s *Server) handleKafkaCluster(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	info, err := s.kafkaClusterInfo(ctx)
+	if err != nil {
+		s.logger.Printf("kafka action=cluster err=%v", err)
+		writeJSON(w, kafkaErrStatus(err), errorBody("kafka-cluster", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
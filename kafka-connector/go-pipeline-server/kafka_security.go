@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/aws"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+/************** Kafka SASL / TLS **************/
+
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // PLAIN | SCRAM-SHA-256 | SCRAM-SHA-512 | AWS_MSK_IAM，空则不启用
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+	// AWS_MSK_IAM：留空时读取 AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN
+	AWS struct {
+		AccessKey    string `yaml:"access_key"`
+		SecretKey    string `yaml:"secret_key"`
+		SessionToken string `yaml:"session_token"`
+	} `yaml:"aws"`
+}
+
+type KafkaTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`   // 为空则使用系统根证书
+	CertFile           string `yaml:"cert_file"` // mTLS 客户端证书
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func (c KafkaTLSConfig) build() (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.tls.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka.tls.ca_file: no certificates found in %s", c.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.tls.cert_file/key_file: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func (c KafkaSASLConfig) mechanism() (sasl.Mechanism, error) {
+	switch strings.ToUpper(c.Mechanism) {
+	case "PLAIN":
+		return plain.Auth{User: c.Username, Pass: c.Password}.AsMechanism(), nil
+	case "SCRAM-SHA-256":
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha256Mechanism(), nil
+	case "SCRAM-SHA-512":
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha512Mechanism(), nil
+	case "AWS_MSK_IAM":
+		// 每次认证时取凭证，便于环境变量中的临时凭证轮换
+		return aws.ManagedStreamingIAM(func(context.Context) (aws.Auth, error) {
+			a := aws.Auth{AccessKey: c.AWS.AccessKey, SecretKey: c.AWS.SecretKey, SessionToken: c.AWS.SessionToken}
+			if a.AccessKey == "" {
+				a.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+				a.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+				a.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+			}
+			if a.AccessKey == "" || a.SecretKey == "" {
+				return a, fmt.Errorf("AWS_MSK_IAM: no AWS credentials configured")
+			}
+			return a, nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("kafka.sasl.mechanism %q is not supported", c.Mechanism)
+	}
+}
+
+func (s *Server) kafkaSecurityOpts() ([]kgo.Opt, error) {
+	var opts []kgo.Opt
+	if t := s.cfg.Kafka.TLS; t.Enabled {
+		tc, err := t.build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(tc))
+	}
+	if s.cfg.Kafka.SASL.Mechanism != "" {
+		m, err := s.cfg.Kafka.SASL.mechanism()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(m))
+	}
+	return opts, nil
+}
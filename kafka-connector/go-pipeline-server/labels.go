@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/************** 资源标签 **************/
+
+// ownership.labels 中的标签（如 team: search, env: prod）随归属标记一起写入下发的资源：
+// ILM / 模板 / pipeline / transform 的 _meta.labels，watch 的 metadata.labels，
+// connector 的 config["managed.labels"]（"k=v,k2=v2"，connector 配置只能是字符串）；
+// 同时记入资产版本历史。多个团队共用集群时按标签筛选：
+//   GET /admin/managed?label=team=search               集群中带归属标记的资源（默认只看本实例，?owner=all 全部）
+//   GET /admin/assets/{kind}/versions?label=env=prod   版本历史
+// label 可重复，全部满足才匹配；写法 k=v、k!=v、k（存在）。
+
+const connectorLabelsKey = "managed.labels"
+
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		switch {
+		case k == "" || strings.ContainsAny(k, "=,! "):
+			return fmt.Errorf("ownership.labels: invalid key %q", k)
+		case strings.ContainsAny(v, "=,"):
+			return fmt.Errorf("ownership.labels.%s: value must not contain '=' or ','", k)
+		}
+	}
+	return nil
+}
+
+// 写入 labels；配置中没有标签时去掉旧的
+func (s *Server) stampLabels(meta map[string]any) {
+	if len(s.cfg.Ownership.Labels) > 0 {
+		meta["labels"] = s.cfg.Ownership.Labels
+	} else {
+		delete(meta, "labels")
+	}
+}
+
+func formatLabelList(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func parseLabelList(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	out := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		if k != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type labelRequirement struct {
+	Key, Value string
+	Op         string // = | != | exists
+}
+
+type labelSelector []labelRequirement
+
+// ?label=k=v&label=k!=v&label=k
+func parseLabelSelector(r *http.Request) (labelSelector, error) {
+	var sel labelSelector
+	for _, raw := range r.URL.Query()["label"] {
+		raw = strings.TrimSpace(raw)
+		switch {
+		case raw == "":
+			continue
+		case strings.Contains(raw, "!="):
+			k, v, _ := strings.Cut(raw, "!=")
+			sel = append(sel, labelRequirement{Key: k, Value: v, Op: "!="})
+		case strings.Contains(raw, "="):
+			k, v, _ := strings.Cut(raw, "=")
+			sel = append(sel, labelRequirement{Key: k, Value: v, Op: "="})
+		default:
+			sel = append(sel, labelRequirement{Key: raw, Op: "exists"})
+		}
+		if last := sel[len(sel)-1]; last.Key == "" {
+			return nil, fmt.Errorf("invalid label selector %q (k=v, k!=v or k)", raw)
+		}
+	}
+	return sel, nil
+}
+
+func (sel labelSelector) matches(labels map[string]string) bool {
+	for _, req := range sel {
+		v, ok := labels[req.Key]
+		switch req.Op {
+		case "=":
+			if !ok || v != req.Value {
+				return false
+			}
+		case "!=":
+			if ok && v == req.Value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GET /admin/managed[?kinds=ilm,template,pipeline,connector&owner=all|<managed_by>&label=k=v]
+func (s *Server) handleListManaged(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseLabelSelector(r)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	q := r.URL.Query()
+	kinds := gcKindOrder
+	if v := q.Get("kinds"); v != "" {
+		kinds = strings.Split(v, ",")
+		for _, k := range kinds {
+			if !slices.Contains(gcKindOrder, k) {
+				writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown kind %q", k), "kinds": gcKindOrder})
+				return
+			}
+		}
+	}
+	owner := q.Get("owner")
+	if owner == "" {
+		owner = s.managedBy()
+	}
+	out := []markedResource{}
+	errs := map[string]string{}
+	for _, kind := range kinds {
+		list, err := s.listMarked(r.Context(), kind)
+		if err != nil {
+			errs[kind] = err.Error()
+			continue
+		}
+		for _, m := range list {
+			if (owner == "all" || m.Owner == owner) && sel.matches(m.Labels) {
+				out = append(out, m)
+			}
+		}
+	}
+	resp := map[string]any{"owner": owner, "resources": out}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
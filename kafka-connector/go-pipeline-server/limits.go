@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+/************** 下游并发限制（按目标划分的信号量） **************/
+
+// sems 只在构造时写入，之后只读
+type concurrencyLimiter struct {
+	sems map[string]chan struct{}
+}
+
+// limits: es/connect/kafka/... -> 最大并发；未配置或 <=0 表示不限
+func newConcurrencyLimiter(limits map[string]int) *concurrencyLimiter {
+	l := &concurrencyLimiter{sems: map[string]chan struct{}{}}
+	for k, n := range limits {
+		if n > 0 {
+			l.sems[k] = make(chan struct{}, n)
+		}
+	}
+	return l
+}
+
+// 阻塞直到取得名额或 ctx 结束；返回的 release 必须调用
+func (l *concurrencyLimiter) acquire(ctx context.Context, target string) (func(), error) {
+	sem := l.sems[target]
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for %s concurrency slot: %w", target, ctx.Err())
+	}
+}
+
+// 当前占用情况，供 /admin/debug/runtime 输出
+func (l *concurrencyLimiter) stats() map[string]map[string]int {
+	out := make(map[string]map[string]int, len(l.sems))
+	for k, sem := range l.sems {
+		out[k] = map[string]int{"in_use": len(sem), "limit": cap(sem)}
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/************** 压测：向日志 topic 写入合成日志 **************/
+
+const (
+	maxLoadRate     = 200000
+	maxLoadDuration = time.Hour
+	maxPayloadSize  = 1 << 20
+)
+
+type loadtestRequest struct {
+	Topic       string `json:"topic,omitempty"`
+	Rate        int    `json:"rate"`         // 条/秒
+	Duration    string `json:"duration"`     // e.g. "5m"
+	PayloadSize int    `json:"payload_size"` // 每条 message 字段的字节数
+	Cardinality int    `json:"cardinality"`  // service/host 的不同取值个数
+
+	duration time.Duration
+}
+
+func (req *loadtestRequest) validate(defaultTopic string) error {
+	if req.Topic == "" {
+		req.Topic = defaultTopic
+	}
+	if req.Topic == "" {
+		return fmt.Errorf("topic is required (or set kafka.topic)")
+	}
+	if req.Rate <= 0 || req.Rate > maxLoadRate {
+		return fmt.Errorf("rate must be in (0, %d]", maxLoadRate)
+	}
+	if req.Duration == "" {
+		req.Duration = "1m"
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 || d > maxLoadDuration {
+		return fmt.Errorf("duration must be a Go duration in (0, %s]", maxLoadDuration)
+	}
+	req.duration = d
+	if req.PayloadSize <= 0 {
+		req.PayloadSize = 256
+	}
+	if req.PayloadSize > maxPayloadSize {
+		return fmt.Errorf("payload_size must be <= %d", maxPayloadSize)
+	}
+	if req.Cardinality <= 0 {
+		req.Cardinality = 10
+	}
+	return nil
+}
+
+var loadLevels = []string{"DEBUG", "INFO", "INFO", "INFO", "WARN", "ERROR"}
+
+func syntheticLog(rng *rand.Rand, seq int64, req loadtestRequest) []byte {
+	n := rng.IntN(req.Cardinality)
+	doc := map[string]any{
+		"@timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+		"log.level":  loadLevels[rng.IntN(len(loadLevels))],
+		"service":    map[string]string{"name": fmt.Sprintf("loadtest-svc-%d", n)},
+		"host":       map[string]string{"name": fmt.Sprintf("loadtest-host-%d", n)},
+		"labels":     map[string]string{"loadtest": "true"},
+		"seq":        seq,
+		"message":    strings.Repeat("x", req.PayloadSize),
+	}
+	b, _ := json.Marshal(doc)
+	return b
+}
+
+func (s *Server) handleLoadtest(w http.ResponseWriter, r *http.Request) {
+	if len(s.cfg.Kafka.Brokers) == 0 {
+		writeJSON(w, 400, map[string]string{"error": errKafkaNotConfigured.Error()})
+		return
+	}
+	var req loadtestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if err := req.validate(s.cfg.Kafka.Topic); err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	j := s.startJob("loadtest", req, func(ctx context.Context, j *Job) (any, error) {
+		return s.runLoadtest(ctx, j, req)
+	})
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+func (s *Server) runLoadtest(ctx context.Context, j *Job, req loadtestRequest) (any, error) {
+	opts, err := s.kafkaOpts(kgo.DefaultProduceTopic(req.Topic), kgo.ProducerLinger(5*time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cl.Close()
+
+	var sent, acked, failed, bytes atomic.Int64
+	var lastErr atomic.Value
+	rng := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+
+	start := time.Now()
+	deadline := start.Add(req.duration)
+	j.Step("produce", "running", fmt.Sprintf("topic=%s rate=%d/s duration=%s", req.Topic, req.Rate, req.duration))
+
+	// 每 10ms 按累计应发送量补齐，避免 ticker 抖动导致速率偏低
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	report := time.NewTicker(time.Second)
+	defer report.Stop()
+	var lastAcked int64
+	lastReport := start
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case now := <-report.C:
+			a := acked.Load()
+			j.SetProgress("sent", sent.Load())
+			j.SetProgress("acked", a)
+			j.SetProgress("failed", failed.Load())
+			j.SetProgress("bytes", bytes.Load())
+			j.SetProgress("msgs_per_sec", float64(a-lastAcked)/now.Sub(lastReport).Seconds())
+			j.SetProgress("elapsed_s", int(now.Sub(start).Seconds()))
+			lastAcked, lastReport = a, now
+		case now := <-tick.C:
+			if !now.Before(deadline) {
+				break loop
+			}
+			due := int64(now.Sub(start).Seconds() * float64(req.Rate))
+			for seq := sent.Load(); seq < due; seq++ {
+				v := syntheticLog(rng, seq, req)
+				sent.Add(1)
+				bytes.Add(int64(len(v)))
+				cl.Produce(ctx, &kgo.Record{Value: v}, func(_ *kgo.Record, err error) {
+					if err != nil {
+						failed.Add(1)
+						lastErr.Store(err.Error())
+						return
+					}
+					acked.Add(1)
+				})
+			}
+		}
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cl.Flush(flushCtx); err != nil {
+		j.Step("flush", "failed", err.Error())
+	} else {
+		j.Step("flush", "ok", "")
+	}
+	elapsed := time.Since(start)
+	res := map[string]any{
+		"topic":        req.Topic,
+		"sent":         sent.Load(),
+		"acked":        acked.Load(),
+		"failed":       failed.Load(),
+		"bytes":        bytes.Load(),
+		"elapsed_s":    elapsed.Seconds(),
+		"msgs_per_sec": float64(acked.Load()) / elapsed.Seconds(),
+	}
+	if e, ok := lastErr.Load().(string); ok {
+		res["last_error"] = e
+	}
+	j.Step("produce", "done", "")
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	if failed.Load() > 0 && acked.Load() == 0 {
+		return res, fmt.Errorf("all %d messages failed", failed.Load())
+	}
+	return res, nil
+}
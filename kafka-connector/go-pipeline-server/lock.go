@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+/************** 下发操作互斥锁 **************/
+
+// 同一条 pipeline（以 es.names.data_stream 为 key）的下发操作（ILM / 模板 / pipeline / sink 写入、回滚、
+// git 下发、GC 等）串行执行，避免两个操作人同时 apply 时交错写入留下半新半旧的状态。
+// backend=es 时锁是 ES 索引中的一篇文档（_create 抢占，_seq_no 条件更新续租/释放），多副本之间互斥；
+// 持有者崩溃后租约（ttl）到期即可被接管。backend=local 只在本进程内互斥（单副本部署）。
+
+const (
+	lockBackendLocal = "local"
+	lockBackendES    = "es"
+
+	defaultLockIndex = "go-pipeline-locks"
+	defaultLockTTL   = 2 * time.Minute
+	defaultLockWait  = 10 * time.Second
+)
+
+type LockConfig struct {
+	Backend string `yaml:"backend"` // local（默认）| es
+	Index   string `yaml:"index"`   // backend=es 时存放锁文档的索引，默认 go-pipeline-locks
+	TTL     string `yaml:"ttl"`     // 租约时长，持有期间自动续租，默认 2m
+	Wait    string `yaml:"wait"`    // 锁被占用时的最长等待，超时返回 423，默认 10s
+}
+
+type lockInfo struct {
+	Key        string    `json:"key"`
+	Holder     string    `json:"holder"` // 副本标识：hostname/pid/随机串
+	Operator   string    `json:"operator"`
+	Op         string    `json:"op"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ES 锁文档的版本，用于条件续租/释放
+type lockVersion struct {
+	seqNo, primaryTerm int64
+}
+
+type lockManager struct {
+	backend string
+	index   string
+	ttl     time.Duration
+	wait    time.Duration
+	holder  string
+
+	mu    sync.Mutex
+	local map[string]chan struct{} // 本进程内先排队，避免多个请求同时去 ES 抢
+	held  map[string]lockInfo
+}
+
+func newLockManager(cfg LockConfig) *lockManager {
+	m := &lockManager{
+		backend: cfg.Backend,
+		index:   cfg.Index,
+		ttl:     mustParseDuration("lock.ttl", cfg.TTL),
+		wait:    mustParseDuration("lock.wait", cfg.Wait),
+		local:   map[string]chan struct{}{},
+		held:    map[string]lockInfo{},
+	}
+	switch m.backend {
+	case "":
+		m.backend = lockBackendLocal
+	case lockBackendLocal, lockBackendES:
+	default:
+		panic(fmt.Errorf("lock.backend: unknown backend %q (local | es)", cfg.Backend))
+	}
+	if m.index == "" {
+		m.index = defaultLockIndex
+	}
+	if m.ttl <= 0 {
+		m.ttl = defaultLockTTL
+	}
+	if m.wait <= 0 {
+		m.wait = defaultLockWait
+	}
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	m.holder = fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+	return m
+}
+
+// 锁被其他操作占用（等待超时）
+type lockHeldError struct {
+	Key  string
+	Info *lockInfo // 持有者信息，可能为空
+}
+
+func (e *lockHeldError) Error() string {
+	if e.Info == nil {
+		return fmt.Sprintf("pipeline %q is locked by another operation", e.Key)
+	}
+	return fmt.Sprintf("pipeline %q is locked by %s (%s, operator %q, since %s)", e.Key, e.Info.Holder, e.Info.Op,
+		e.Info.Operator, e.Info.AcquiredAt.Format(time.RFC3339))
+}
+
+func (s *Server) lockKey() string {
+	return s.cfg.ES.Names.DataStream
+}
+
+// 获取下发锁；返回的 release 必须调用
+func (s *Server) acquireLock(ctx context.Context, operator, op string) (func(), error) {
+	m, key := s.locks, s.lockKey()
+	ctx, cancel := context.WithTimeout(ctx, m.wait)
+	defer cancel()
+
+	m.mu.Lock()
+	ch := m.local[key]
+	if ch == nil {
+		ch = make(chan struct{}, 1)
+		m.local[key] = ch
+	}
+	m.mu.Unlock()
+	select {
+	case ch <- struct{}{}:
+	case <-ctx.Done():
+		m.mu.Lock()
+		info, ok := m.held[key]
+		m.mu.Unlock()
+		if ok {
+			return nil, &lockHeldError{Key: key, Info: &info}
+		}
+		return nil, &lockHeldError{Key: key}
+	}
+
+	now := time.Now()
+	info := lockInfo{Key: key, Holder: m.holder, Operator: operator, Op: op, AcquiredAt: now, ExpiresAt: now.Add(m.ttl)}
+	var lease *esLease
+	if m.backend == lockBackendES {
+		l, err := s.esLockAcquire(ctx, info)
+		if err != nil {
+			<-ch
+			if _, held := err.(*lockHeldError); !held && ctx.Err() != nil {
+				err = &lockHeldError{Key: key} // 等待期间 ES 请求超时
+			}
+			return nil, err
+		}
+		lease = l
+		go s.esLockRenew(lease)
+	}
+	m.mu.Lock()
+	m.held[key] = info
+	m.mu.Unlock()
+	s.logger.Printf("step=lock acquired key=%s op=%q operator=%q backend=%s", key, op, operator, m.backend)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if lease != nil {
+				close(lease.stop)
+				<-lease.done
+				rctx, rcancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if err := s.esLockRelease(rctx, lease); err != nil {
+					s.logger.Printf("step=lock release key=%s err=%v (lease expires within %s)", key, err, m.ttl)
+				}
+				rcancel()
+			}
+			m.mu.Lock()
+			delete(m.held, key)
+			m.mu.Unlock()
+			<-ch
+			s.logger.Printf("step=lock released key=%s op=%q", key, op)
+		})
+	}, nil
+}
+
+// HTTP 入口：获取锁失败时写好响应（占用 423，ES 出错 502）并返回 false
+func (s *Server) lockRequest(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	release, err := s.acquireLock(r.Context(), operatorIdentity(r), r.Method+" "+r.URL.Path)
+	if err != nil {
+		writeLockError(w, err)
+		return nil, false
+	}
+	return release, true
+}
+
+func writeLockError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*lockHeldError); ok {
+		writeJSON(w, http.StatusLocked, map[string]any{"step": "lock", "error": e.Error(), "holder": e.Info})
+		return
+	}
+	writeJSON(w, http.StatusBadGateway, errorBody("lock", err))
+}
+
+// 包装下发类 handler：整个请求期间持有锁
+func (s *Server) withLock(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, ok := s.lockRequest(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+		h(w, r)
+	}
+}
+
+/************** backend=es **************/
+
+type esLease struct {
+	info lockInfo
+	ver  lockVersion // 仅续租 goroutine 修改；stop 之后由 release 读取
+	stop chan struct{}
+	done chan struct{}
+}
+
+type esLockDoc struct {
+	SeqNo       int64    `json:"_seq_no"`
+	PrimaryTerm int64    `json:"_primary_term"`
+	Found       bool     `json:"found"`
+	Source      lockInfo `json:"_source"`
+}
+
+func (s *Server) lockDocURL(op, key string, ver *lockVersion) string {
+	u := fmt.Sprintf("%s/%s/%s/%s", s.cfg.ES.Host, s.locks.index, op, url.PathEscape(key))
+	if ver != nil {
+		u += fmt.Sprintf("?if_seq_no=%d&if_primary_term=%d", ver.seqNo, ver.primaryTerm)
+	}
+	return u
+}
+
+// 抢占锁文档：不存在则 _create；已存在但租约过期则按 _seq_no 条件覆盖；否则轮询直到 ctx 超时
+func (s *Server) esLockAcquire(ctx context.Context, info lockInfo) (*esLease, error) {
+	for {
+		doc, _ := json.Marshal(info)
+		resp, body, err := s.doPUT(ctx, s.lockDocURL("_create", info.Key, nil), doc, "es")
+		if err != nil {
+			return nil, fmt.Errorf("acquire lock: %w", err)
+		}
+		switch {
+		case resp.StatusCode < 300:
+			return newESLease(info, body)
+		case resp.StatusCode != http.StatusConflict:
+			return nil, fmt.Errorf("acquire lock: %s: %s", resp.Status, string(body))
+		}
+
+		cur, err := s.esLockGet(ctx, info.Key)
+		if err != nil {
+			return nil, err
+		}
+		if cur.Found && time.Now().After(cur.Source.ExpiresAt) {
+			s.logger.Printf("step=lock takeover key=%s expired_holder=%s expired_at=%s", info.Key, cur.Source.Holder,
+				cur.Source.ExpiresAt.Format(time.RFC3339))
+			ver := lockVersion{seqNo: cur.SeqNo, primaryTerm: cur.PrimaryTerm}
+			resp, body, err := s.doPUT(ctx, s.lockDocURL("_doc", info.Key, &ver), doc, "es")
+			if err != nil {
+				return nil, fmt.Errorf("acquire lock: %w", err)
+			}
+			if resp.StatusCode < 300 {
+				return newESLease(info, body)
+			}
+			if resp.StatusCode != http.StatusConflict {
+				return nil, fmt.Errorf("acquire lock: %s: %s", resp.Status, string(body))
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			if cur.Found {
+				return nil, &lockHeldError{Key: info.Key, Info: &cur.Source}
+			}
+			return nil, &lockHeldError{Key: info.Key}
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func newESLease(info lockInfo, body []byte) (*esLease, error) {
+	var res esLockDoc
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("decode lock response: %w", err)
+	}
+	return &esLease{
+		info: info,
+		ver:  lockVersion{seqNo: res.SeqNo, primaryTerm: res.PrimaryTerm},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (s *Server) esLockGet(ctx context.Context, key string) (*esLockDoc, error) {
+	resp, body, err := s.doGET(ctx, s.lockDocURL("_doc", key, nil), "es")
+	if err != nil {
+		return nil, fmt.Errorf("read lock: %w", err)
+	}
+	var doc esLockDoc
+	if resp.StatusCode == http.StatusNotFound {
+		return &doc, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read lock: %s: %s", resp.Status, string(body))
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode lock: %w", err)
+	}
+	return &doc, nil
+}
+
+// 每 ttl/3 续租一次；续租失败（锁已被接管）只记日志，当前操作继续执行完
+func (s *Server) esLockRenew(l *esLease) {
+	defer close(l.done)
+	t := time.NewTicker(s.locks.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+		l.info.ExpiresAt = time.Now().Add(s.locks.ttl)
+		doc, _ := json.Marshal(l.info)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		resp, body, err := s.doPUT(ctx, s.lockDocURL("_doc", l.info.Key, &l.ver), doc, "es")
+		cancel()
+		switch {
+		case err != nil:
+			s.logger.Printf("step=lock renew key=%s err=%v", l.info.Key, err)
+		case resp.StatusCode >= 300:
+			s.logger.Printf("step=lock renew key=%s status=%s body=%s", l.info.Key, resp.Status, string(body))
+		default:
+			var res esLockDoc
+			if json.Unmarshal(body, &res) == nil {
+				l.ver = lockVersion{seqNo: res.SeqNo, primaryTerm: res.PrimaryTerm}
+			}
+		}
+	}
+}
+
+// 条件删除：锁已被他人接管时（409）不删
+func (s *Server) esLockRelease(ctx context.Context, l *esLease) error {
+	resp, body, err := s.doDELETE(ctx, s.lockDocURL("_doc", l.info.Key, &l.ver), "es")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("%s: %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+/************** 查看 **************/
+
+// 本进程当前持有的锁数量
+func (m *lockManager) heldCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.held)
+}
+
+func (s *Server) handleListLocks(w http.ResponseWriter, r *http.Request) {
+	m := s.locks
+	m.mu.Lock()
+	local := make([]lockInfo, 0, len(m.held))
+	for _, info := range m.held {
+		local = append(local, info)
+	}
+	m.mu.Unlock()
+	sort.Slice(local, func(i, j int) bool { return local[i].Key < local[j].Key })
+	out := map[string]any{"backend": m.backend, "holder": m.holder, "ttl": m.ttl.String(), "wait": m.wait.String(), "held": local}
+	if m.backend == lockBackendES {
+		doc, err := s.esLockGet(r.Context(), s.lockKey())
+		switch {
+		case err != nil:
+			out["cluster_error"] = err.Error()
+		case doc.Found:
+			out["cluster"] = map[string]any{"lock": doc.Source, "expired": time.Now().After(doc.Source.ExpiresAt)}
+		default:
+			out["cluster"] = nil
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** Logstash 输出（替代 Kafka Connect） **************/
+
+type LogstashConfig struct {
+	PipelineID       string   `yaml:"pipeline_id"`
+	BootstrapServers string   `yaml:"bootstrap_servers"`
+	Topics           []string `yaml:"topics"`
+	GroupID          string   `yaml:"group_id"`
+	ConsumerThreads  int      `yaml:"consumer_threads"`
+	ESHosts          []string `yaml:"es_hosts"` // Logstash 视角的 ES 地址，缺省用 es.host
+	Workers          int      `yaml:"workers"`
+	BatchSize        int      `yaml:"batch_size"`
+}
+
+type logstashSink struct {
+	s  *Server
+	sc SinkConfig
+}
+
+func (l *logstashSink) Type() string { return sinkTypeLogstash }
+func (l *logstashSink) Name() string { return l.pipelineID() }
+
+func (l *logstashSink) topics() []string {
+	if len(l.sc.Logstash.Topics) > 0 {
+		return l.sc.Logstash.Topics
+	}
+	return l.sc.Topics
+}
+
+func (l *logstashSink) pipelineID() string {
+	if id := l.sc.Logstash.PipelineID; id != "" {
+		return id
+	}
+	return "kafka-to-" + l.s.cfg.ES.Names.DataStream
+}
+
+func (l *logstashSink) pipelineURL() string {
+	return fmt.Sprintf("%s/_logstash/pipeline/%s", l.s.cfg.ES.Host, l.pipelineID())
+}
+
+func lsQuote(v string) string { return strconv.Quote(v) }
+
+func lsList(vs []string) string {
+	q := make([]string, len(vs))
+	for i, v := range vs {
+		q[i] = lsQuote(v)
+	}
+	return "[" + strings.Join(q, ", ") + "]"
+}
+
+// 生成 Logstash pipeline 配置：Kafka -> (kafka_partition/kafka_offset) -> ES data stream（走同一 ingest pipeline）
+func (l *logstashSink) renderConfig() string {
+	s, ls := l.s, l.sc.Logstash
+	threads := ls.ConsumerThreads
+	if threads <= 0 {
+		threads = 1
+	}
+	groupID := ls.GroupID
+	if groupID == "" {
+		groupID = l.pipelineID()
+	}
+	hosts := ls.ESHosts
+	if len(hosts) == 0 {
+		hosts = []string{s.cfg.ES.Host}
+	}
+
+	var b strings.Builder
+	b.WriteString("input {\n  kafka {\n")
+	fmt.Fprintf(&b, "    bootstrap_servers => %s\n", lsQuote(ls.BootstrapServers))
+	fmt.Fprintf(&b, "    topics => %s\n", lsList(l.topics()))
+	fmt.Fprintf(&b, "    group_id => %s\n", lsQuote(groupID))
+	fmt.Fprintf(&b, "    consumer_threads => %d\n", threads)
+	b.WriteString("    codec => json\n    decorate_events => \"basic\"\n  }\n}\n")
+
+	b.WriteString("filter {\n  mutate {\n    copy => {\n")
+	b.WriteString("      \"[@metadata][kafka][partition]\" => \"kafka_partition\"\n")
+	b.WriteString("      \"[@metadata][kafka][offset]\" => \"kafka_offset\"\n")
+	b.WriteString("    }\n  }\n}\n")
+
+	b.WriteString("output {\n  elasticsearch {\n")
+	fmt.Fprintf(&b, "    hosts => %s\n", lsList(hosts))
+	fmt.Fprintf(&b, "    index => %s\n", lsQuote(s.cfg.ES.Names.DataStream))
+	b.WriteString("    action => \"create\"\n    data_stream => false\n")
+	fmt.Fprintf(&b, "    pipeline => %s\n", lsQuote(s.cfg.ES.Names.Pipeline))
+	if s.cfg.ES.Username != "" {
+		fmt.Fprintf(&b, "    user => %s\n", lsQuote(s.cfg.ES.Username))
+		fmt.Fprintf(&b, "    password => %s\n", lsQuote(s.cfg.ES.Password))
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+func (l *logstashSink) pipelineDoc() ([]byte, error) {
+	s, ls := l.s, l.sc.Logstash
+	settings := map[string]any{}
+	if ls.Workers > 0 {
+		settings["pipeline.workers"] = ls.Workers
+	}
+	if ls.BatchSize > 0 {
+		settings["pipeline.batch.size"] = ls.BatchSize
+	}
+	user := s.cfg.ES.Username
+	if user == "" {
+		user = "log-pipeline"
+	}
+	return json.Marshal(map[string]any{
+		"description":       fmt.Sprintf("Kafka %s -> %s (managed by log-pipeline)", strings.Join(l.topics(), ","), s.cfg.ES.Names.DataStream),
+		"last_modified":     time.Now().UTC().Format(time.RFC3339Nano),
+		"pipeline_metadata": map[string]any{"type": "logstash_pipeline", "version": 1},
+		"username":          user,
+		"pipeline":          l.renderConfig(),
+		"pipeline_settings": settings,
+	})
+}
+
+func (l *logstashSink) Register(ctx context.Context) (*sinkResponse, error) {
+	if l.sc.Logstash.BootstrapServers == "" || len(l.topics()) == 0 {
+		return nil, &sinkInputError{fmt.Errorf("logstash.bootstrap_servers and logstash.topics are required")}
+	}
+	b, err := l.pipelineDoc()
+	if err != nil {
+		return nil, err
+	}
+	url := l.pipelineURL()
+	l.s.logger.Printf("step=sink type=logstash put url=%s size=%d", url, len(b))
+	resp, respBody, err := l.s.doPUT(ctx, url, b, "es")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, respBody), nil
+}
+
+func (l *logstashSink) Status(ctx context.Context) (*sinkResponse, error) {
+	url := l.pipelineURL()
+	l.s.logger.Printf("verify=sink-status type=logstash url=%s", url)
+	resp, body, err := l.s.doGET(ctx, url, "es")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (l *logstashSink) Config(ctx context.Context) (*sinkResponse, error) { return l.Status(ctx) }
+
+// Logstash 集中管理的 pipeline 没有暂停/恢复语义
+func (l *logstashSink) Pause(ctx context.Context) (*sinkResponse, error) {
+	return nil, errSinkUnsupported
+}
+func (l *logstashSink) Resume(ctx context.Context) (*sinkResponse, error) {
+	return nil, errSinkUnsupported
+}
+
+func (l *logstashSink) Delete(ctx context.Context) (*sinkResponse, error) {
+	url := l.pipelineURL()
+	l.s.logger.Printf("logstash action=delete id=%s url=%s", l.pipelineID(), url)
+	resp, body, err := l.s.doDELETE(ctx, url, "es")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
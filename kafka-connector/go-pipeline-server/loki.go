@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/************** Grafana Loki sink（promtail kafka 抓取） **************/
+
+// promtail 不提供远程管理 API：注册即生成其 kafka scrape 配置文件，由 promtail 热加载
+type LokiConfig struct {
+	URL                string            `yaml:"url"` // e.g. http://loki:3100
+	PromtailConfigFile string            `yaml:"promtail_config_file"`
+	Brokers            []string          `yaml:"brokers"`
+	GroupID            string            `yaml:"group_id"`
+	Labels             map[string]string `yaml:"labels"`
+}
+
+type lokiSink struct {
+	s  *Server
+	sc SinkConfig
+}
+
+func (l *lokiSink) Type() string { return sinkTypeLoki }
+func (l *lokiSink) Name() string { return l.sc.Name }
+
+func (l *lokiSink) renderPromtail() ([]byte, error) {
+	c := l.sc.Loki
+	if c.URL == "" || c.PromtailConfigFile == "" || len(c.Brokers) == 0 || len(l.sc.Topics) == 0 {
+		return nil, fmt.Errorf("sink %s: loki.url, loki.promtail_config_file, loki.brokers and topics are required", l.sc.Name)
+	}
+	groupID := c.GroupID
+	if groupID == "" {
+		groupID = "promtail-" + l.sc.Name
+	}
+	labels := map[string]string{"job": l.sc.Name}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	doc := map[string]any{
+		"clients": []map[string]any{{"url": strings.TrimRight(c.URL, "/") + "/loki/api/v1/push"}},
+		"scrape_configs": []map[string]any{{
+			"job_name": l.sc.Name,
+			"kafka": map[string]any{
+				"brokers":                c.Brokers,
+				"topics":                 l.sc.Topics,
+				"group_id":               groupID,
+				"use_incoming_timestamp": true,
+				"labels":                 labels,
+			},
+			"relabel_configs": []map[string]any{
+				{"action": "replace", "source_labels": []string{"__meta_kafka_topic"}, "target_label": "topic"},
+				{"action": "replace", "source_labels": []string{"__meta_kafka_partition"}, "target_label": "partition"},
+			},
+		}},
+	}
+	return yaml.Marshal(doc)
+}
+
+func (l *lokiSink) Register(ctx context.Context) (*sinkResponse, error) {
+	b, err := l.renderPromtail()
+	if err != nil {
+		return nil, &sinkInputError{err}
+	}
+	file := filepath.Clean(l.sc.Loki.PromtailConfigFile)
+	l.s.logger.Printf("step=sink type=loki write file=%s size=%d", file, len(b))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, b, 0o644); err != nil {
+		return nil, err
+	}
+	out, _ := json.Marshal(map[string]any{"file": file, "promtail_config": string(b)})
+	return &sinkResponse{Code: http.StatusOK, Status: "200 OK", Body: out}, nil
+}
+
+func (l *lokiSink) Status(ctx context.Context) (*sinkResponse, error) {
+	url := strings.TrimRight(l.sc.Loki.URL, "/") + "/ready"
+	l.s.logger.Printf("verify=sink-status type=loki url=%s", url)
+	resp, body, err := l.s.doRequest(ctx, http.MethodGet, url, nil, "loki", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(l.sc.Loki.PromtailConfigFile)
+	out, _ := json.Marshal(map[string]any{
+		"loki_ready":       resp.StatusCode == http.StatusOK,
+		"loki_response":    strings.TrimSpace(string(body)),
+		"promtail_config":  l.sc.Loki.PromtailConfigFile,
+		"config_installed": statErr == nil,
+	})
+	return &sinkResponse{Code: http.StatusOK, Status: "200 OK", Body: out}, nil
+}
+
+func (l *lokiSink) Config(ctx context.Context) (*sinkResponse, error) {
+	b, err := os.ReadFile(l.sc.Loki.PromtailConfigFile)
+	if err != nil {
+		return nil, &sinkInputError{err}
+	}
+	out, _ := json.Marshal(map[string]any{"file": l.sc.Loki.PromtailConfigFile, "promtail_config": string(b)})
+	return &sinkResponse{Code: http.StatusOK, Status: "200 OK", Body: out}, nil
+}
+
+func (l *lokiSink) Pause(ctx context.Context) (*sinkResponse, error)  { return nil, errSinkUnsupported }
+func (l *lokiSink) Resume(ctx context.Context) (*sinkResponse, error) { return nil, errSinkUnsupported }
+
+func (l *lokiSink) Delete(ctx context.Context) (*sinkResponse, error) {
+	file := l.sc.Loki.PromtailConfigFile
+	l.s.logger.Printf("loki action=delete file=%s", file)
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	out, _ := json.Marshal(map[string]any{"deleted": file})
+	return &sinkResponse{Code: http.StatusOK, Status: "200 OK", Body: out}, nil
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -15,11 +14,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/twmb/franz-go/pkg/kgo"
 )
 
 func init() {
@@ -46,11 +47,17 @@ type Config struct {
 		Username  string `yaml:"username"`
 		Password  string `yaml:"password"`
 		VerifyTLS bool   `yaml:"verify_tls"`
+		Flavor    string `yaml:"flavor"` // elasticsearch | opensearch
 		Names     struct {
 			DataStream    string `yaml:"data_stream"`
 			ILMPolicy     string `yaml:"ilm_policy"`
 			IndexTemplate string `yaml:"index_template"`
 			Pipeline      string `yaml:"pipeline"`
+			// 名字中 {name} 占位符的取值（见 nametemplate.go）
+			Vars map[string]string `yaml:"vars"`
+
+			templated    bool   // data_stream 含占位符
+			indexPattern string // data_stream 的日期部分换成 * 后的 index pattern
 		} `yaml:"names"`
 		Files struct {
 			ILM      string `yaml:"ilm"`
@@ -68,26 +75,138 @@ type Config struct {
 		Files struct {
 			Sink string `yaml:"sink"`
 		} `yaml:"files"`
+		RequiredPlugins []string `yaml:"required_plugins"`
+		ConfigProviders []string `yaml:"config_providers"` // worker 上 config.providers 已配置的 provider 名
 	} `yaml:"connect"`
 
+	// 主 sink（兼容旧的 connect.* 配置）与按日志流划分的额外 sink
+	Sink     SinkConfig     `yaml:"sink"`
+	Sinks    []SinkConfig   `yaml:"sinks"`
+	Logstash LogstashConfig `yaml:"logstash"`
+	Archive  ArchiveConfig  `yaml:"archive"`
+
+	Downsample DownsampleConfig  `yaml:"downsample"`
+	Transforms []TransformConfig `yaml:"transforms"`
+	Watches    []WatchConfig     `yaml:"watches"`
+
+	Kafka KafkaConfig `yaml:"kafka"`
+
 	Frontend struct {
 		AllowedOrigins []string `yaml:"allowed_origins"`
 	} `yaml:"frontend"`
+
+	// 每个下游的最大并发请求数（es / connect / kafka / clickhouse ...），0 或缺省为不限
+	Limits struct {
+		Concurrency      map[string]int `yaml:"concurrency"`
+		MaxResponseBytes int64          `yaml:"max_response_bytes"` // 单个下游响应体上限，默认 32MiB
+		MaxRequestBytes  int64          `yaml:"max_request_bytes"`  // /admin 请求体上限，默认 8MiB
+		LogSnippetBytes  int            `yaml:"log_snippet_bytes"`  // 下游日志中的响应体片段长度，默认 2048
+	} `yaml:"limits"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Alerts        AlertsConfig        `yaml:"alerts"`
+	Schedules     SchedulesConfig     `yaml:"schedules"`
+	Assets        AssetsConfig        `yaml:"assets"`
+	Git           GitConfig           `yaml:"git"`
+	Ownership     OwnershipConfig     `yaml:"ownership"`
+	GRPC          GRPCConfig          `yaml:"grpc"`
+	Lock          LockConfig          `yaml:"lock"`
+	Probes        ProbesConfig        `yaml:"probes"`
+	Operator      OperatorConfig      `yaml:"operator"`
+	Reload        ReloadConfig        `yaml:"reload"`
+	Timeouts      TimeoutsConfig      `yaml:"timeouts"`
+	Breaker       BreakerConfig       `yaml:"breaker"`
+	Proxy         ProxyConfig         `yaml:"proxy"`
+	Shutdown      ShutdownConfig      `yaml:"shutdown"`
+	Idempotency   IdempotencyConfig   `yaml:"idempotency"`
+	ConnectState  ConnectStateConfig  `yaml:"connect_state"`
+	TaskRestarter TaskRestarterConfig `yaml:"task_restarter"`
+	RestartGuard  RestartGuardConfig  `yaml:"restart_guard"`
+	CCR           CCRConfig           `yaml:"ccr"`
+	Tenancy       TenancyConfig       `yaml:"tenancy"`
+	Guardrails    GuardrailsConfig    `yaml:"guardrails"`
+	ECS           ECSConfig           `yaml:"ecs"`
+	Routing       RoutingConfig       `yaml:"routing"`
+	Approvals     ApprovalsConfig     `yaml:"approvals"`
+	Freeze        FreezeConfig        `yaml:"freeze"`
+	Slowlog       SlowlogConfig       `yaml:"slowlog"`
+
+	Live struct {
+		Interval string `yaml:"interval"` // /admin/ws 与 /admin/topology/stream 推送间隔，默认 5s
+	} `yaml:"live"`
+
+	// 验证/查询类 GET 响应缓存时长（如 "2s"），任何写操作后清空；留空不缓存
+	Cache struct {
+		TTL string `yaml:"ttl"`
+	} `yaml:"cache"`
+
+	RequestLog RequestLogConfig `yaml:"request_log"` // 请求日志的排除与采样（requestlog.go）
+
+	Redact struct {
+		Keys []string `yaml:"keys"` // 支持 * 通配，匹配 JSON key
+	} `yaml:"redact"`
+
+	Debug struct {
+		Enabled bool   `yaml:"enabled"`
+		Token   string `yaml:"token"`
+	} `yaml:"debug"`
 }
 
 /************** 服务器对象 **************/
 
 type Server struct {
-	cfg    Config
-	client *http.Client
-	logger *log.Logger
+	cfg          Config
+	clients      *downstreamClients // 按下游区分的 HTTP 客户端与超时
+	breakers     *breakers          // 按下游 host 熔断
+	idem         *idempotencyStore  // Idempotency-Key 去重
+	logger       *log.Logger
+	redact       *redactor
+	limits       *concurrencyLimiter
+	cache        *responseCache
+	ws           *wsHub
+	httplog      *httpLogFeed // /admin/logs/http 请求日志流
+	reqlog       *requestLogRules
+	alerts       *alertManager
+	sched        *scheduler
+	freeze       *freezeCalendar // 冻结窗口
+	assets       *assetStore
+	desired      *desiredStore     // connector 期望状态
+	setup        *setupStore       // 部署向导进度
+	plans        *planStore        // 变更计划与审批
+	restarts     *taskRestarter    // FAILED task 自动重启
+	restartGuard *restartGuard     // 手动重启限速
+	maintenance  *maintenanceStore // 维护模式
+	loggers      *loggerOverrides  // 通过本服务修改过的 Connect logger 级别
+	pressure     *pressureSampler  // /admin/es/pressure 上一次采样
+	topoSampler  *topologySampler  // 拓扑运行状态的上一次采样
+	git          *gitStore         // 未开启 git 存储时为 nil
+	locks        *lockManager
+	probes       *probeState
+	conf         *configWatcher // 配置来源（文件 / etcd / Consul）及变更状态
+
+	operator *operator // 未开启 operator 模式时为 nil
+
+	tenants map[string]*tenant // tenancy.tenants，按名字
+
+	compatMu sync.RWMutex
+	compat   *compatReport
+
+	jobs *jobManager
+
+	kafkaMu sync.Mutex
+	kafka   *kgo.Client
+	avro    avroCodecs
 }
 
 /************** 启动参数（支持 ENV 覆盖） **************/
 
 var (
-	flagListen = flag.String("listen", ":8801", "HTTP listen address, e.g. :80")
-	flagStatic = flag.String("static-dir", "./static", "Directory of built frontend (must contain index.html)")
+	flagListen      = flag.String("listen", ":8801", "HTTP listen address, e.g. :80")
+	flagAdminListen = flag.String("admin-listen", "", "Separate listen address for /admin/* (empty: served on -listen)")
+	flagUIAdmin     = flag.String("ui-admin", "readonly", "With -admin-listen: /admin/* on the UI listener is readonly (GET only) or none")
+	flagStatic      = flag.String("static-dir", "./static", "Directory of built frontend (must contain index.html)")
+	flagConfig      = flag.String("config", "config.yaml", "Config source: YAML file path, etcd://host:2379/key or consul://host:8500/key")
+	flagBasePath    = flag.String("base-path", "/", "URL base path for the UI listener when served under a reverse proxy sub-path, e.g. /log-pipeline/")
 )
 
 func withEnv(v *string, envKey string) {
@@ -98,27 +217,16 @@ func withEnv(v *string, envKey string) {
 
 /************** 工具函数 **************/
 
-func mustReadYAML(path string, out any) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		panic(err)
+// 可选的时长配置，空串为 0
+func mustParseDuration(field, v string) time.Duration {
+	if v == "" {
+		return 0
 	}
-	if err := yaml.Unmarshal(b, out); err != nil {
-		panic(err)
-	}
-}
-
-func newHTTPClient(skipVerify bool) *http.Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify}, //nolint:gosec
-		DialContext: (&net.Dialer{
-			Timeout: 5 * time.Second,
-		}).DialContext,
-		ResponseHeaderTimeout: 15 * time.Second,
-		IdleConnTimeout:       30 * time.Second,
-		MaxIdleConnsPerHost:   8,
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", field, err))
 	}
-	return &http.Client{Transport: tr, Timeout: 30 * time.Second}
+	return d
 }
 
 func (s *Server) withESAuth(req *http.Request) {
@@ -195,6 +303,10 @@ func (w *statusRecorder) WriteHeader(code int) {
 	w.status = code
 	w.ResponseWriter.WriteHeader(code)
 }
+
+// 供 http.ResponseController 取得底层连接（WebSocket hijack）
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 func (w *statusRecorder) Write(b []byte) (int, error) {
 	if w.status == 0 {
 		w.status = http.StatusOK
@@ -204,73 +316,95 @@ func (w *statusRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
-func requestLogger(l *log.Logger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		sr := &statusRecorder{ResponseWriter: w}
-		next.ServeHTTP(sr, r)
-		dur := time.Since(start)
-
-		origin := r.Header.Get("Origin")
-		clen := r.Header.Get("Content-Length")
-		if clen == "" {
-			clen = "0"
-		}
-
-		l.Printf(
-			"http req method=%s path=%s query=%q origin=%q ip=%s status=%d bytes=%d dur_ms=%.3f req_bytes=%s ua=%q",
-			r.Method, r.URL.Path, r.URL.RawQuery, origin, clientIP(r), sr.status, sr.bytes,
-			float64(dur.Microseconds())/1000.0, clen, r.UserAgent(),
-		)
-	})
+// feed 非 nil 时同时发布到 /admin/logs/http（httplog.go）；rules 为排除与采样规则（requestlog.go）；
+// 内层通过 logField 附加的字段追加在行末（middleware.go）
+func requestLogger(l *log.Logger, feed *httpLogFeed, rules *requestLogRules) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sr := &statusRecorder{ResponseWriter: w}
+			r, fields := withLogFields(r)
+			next.ServeHTTP(sr, r)
+			dur := time.Since(start)
+			keep, rate := rules.keep(r.URL.Path, sr.status)
+			if !keep {
+				return
+			}
+			if rate < 1 {
+				logField(r, "sample_rate", strconv.FormatFloat(rate, 'g', -1, 64))
+			}
+
+			origin := r.Header.Get("Origin")
+			clen := r.Header.Get("Content-Length")
+			if clen == "" {
+				clen = "0"
+			}
+
+			extra := fields.snapshot()
+			line := formatLogFields(extra)
+			if op := strings.TrimSpace(r.Header.Get("X-Operator")); op != "" {
+				line += " operator=" + quoteIfNeeded(op) // feed 中为单独的 operator 字段
+			}
+			l.Printf(
+				"http req method=%s path=%s query=%q origin=%q ip=%s status=%d bytes=%d dur_ms=%.3f req_bytes=%s ua=%q%s",
+				r.Method, r.URL.Path, r.URL.RawQuery, origin, clientIP(r), sr.status, sr.bytes,
+				float64(dur.Microseconds())/1000.0, clen, r.UserAgent(), line,
+			)
+			feed.publishRequest(r, sr.status, sr.bytes, dur, clen, extra)
+		})
+	}
 }
 
 /************** CORS 中间件（多域白名单） **************/
 
-func cors(allowed []string, next http.Handler) http.Handler {
+func cors(allowed []string) middleware {
 	allowSet := map[string]struct{}{}
 	for _, o := range allowed {
 		allowSet[o] = struct{}{}
 	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 如需严格白名单，请恢复下方逻辑并去掉通配：
-		// origin := r.Header.Get("Origin")
-		// if origin != "" {
-		// 	if _, ok := allowSet[origin]; ok {
-		// 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		// 		// w.Header().Set("Access-Control-Allow-Credentials", "true")
-		// 	}
-		// }
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "600")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 如需严格白名单，请恢复下方逻辑并去掉通配：
+			// origin := r.Header.Get("Origin")
+			// if origin != "" {
+			// 	if _, ok := allowSet[origin]; ok {
+			// 		w.Header().Set("Access-Control-Allow-Origin", origin)
+			// 		// w.Header().Set("Access-Control-Allow-Credentials", "true")
+			// 	}
+			// }
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, If-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed, ETag, X-Maintenance")
+			w.Header().Set("Access-Control-Max-Age", "600")
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 /************** 下游调用日志 **************/
 
 func (s *Server) logDownstream(kind, method, url, file string, status int, body []byte, err error) {
-	const maxDump = 2048
-	snippet := body
-	if len(snippet) > maxDump {
-		snippet = body[:maxDump]
+	snippet, total, truncated := s.logSnippet(body)
+	s.httplog.publishDownstream(kind, method, url, file, status, snippet, err)
+	size := ""
+	if truncated {
+		size = fmt.Sprintf(" body_bytes=%d body_truncated=true", total)
 	}
 	if err != nil {
-		s.logger.Printf("downstream kind=%s method=%s url=%s file=%s status=%d err=%v body=%q",
-			kind, method, url, file, status, err, string(snippet))
+		s.logger.Printf("downstream kind=%s method=%s url=%s file=%s status=%d err=%v body=%q%s",
+			kind, method, url, file, status, err, string(snippet), size)
 		return
 	}
 	if status >= 400 {
-		s.logger.Printf("downstream kind=%s method=%s url=%s file=%s status=%d body=%q",
-			kind, method, url, file, status, string(snippet))
+		s.logger.Printf("downstream kind=%s method=%s url=%s file=%s status=%d body=%q%s",
+			kind, method, url, file, status, string(snippet), size)
 	} else {
 		s.logger.Printf("downstream kind=%s method=%s url=%s file=%s status=%d",
 			kind, method, url, file, status)
@@ -291,13 +425,24 @@ func (s *Server) doPUT(ctx context.Context, url string, body []byte, esOrConnect
 	} else {
 		s.withConnectAuth(req)
 	}
-	resp, err := s.client.Do(req)
+	release, err := s.limits.acquire(ctx, esOrConnect)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|put", "PUT", url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer release()
+	resp, err := s.send(req, esOrConnect)
 	if err != nil {
 		s.logDownstream(esOrConnect+"|put", "PUT", url, "", 0, nil, err)
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := s.readResponse(resp)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|put", "PUT", url, "", resp.StatusCode, nil, err)
+		return nil, nil, err
+	}
+	respBody = s.redact.JSON(respBody)
 	s.logDownstream(esOrConnect+"|put", "PUT", url, "", resp.StatusCode, respBody, nil)
 	return resp, respBody, nil
 }
@@ -317,13 +462,24 @@ func (s *Server) doGET(ctx context.Context, url string, esOrConnect string) (*ht
 	} else {
 		s.withConnectAuth(req)
 	}
-	resp, err := s.client.Do(req)
+	release, err := s.limits.acquire(ctx, esOrConnect)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|get", "GET", url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer release()
+	resp, err := s.send(req, esOrConnect)
 	if err != nil {
 		s.logDownstream(esOrConnect+"|get", "GET", url, "", 0, nil, err)
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := s.readResponse(resp)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|get", "GET", url, "", resp.StatusCode, nil, err)
+		return nil, nil, err
+	}
+	respBody = s.redact.JSON(respBody)
 	s.logDownstream(esOrConnect+"|get", "GET", url, "", resp.StatusCode, respBody, nil)
 	return resp, respBody, nil
 }
@@ -340,13 +496,24 @@ func (s *Server) doPOST(ctx context.Context, url string, body []byte, esOrConnec
 	} else {
 		s.withConnectAuth(req)
 	}
-	resp, err := s.client.Do(req)
+	release, err := s.limits.acquire(ctx, esOrConnect)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|post", "POST", url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer release()
+	resp, err := s.send(req, esOrConnect)
 	if err != nil {
 		s.logDownstream(esOrConnect+"|post", "POST", url, "", 0, nil, err)
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := s.readResponse(resp)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|post", "POST", url, "", resp.StatusCode, nil, err)
+		return nil, nil, err
+	}
+	respBody = s.redact.JSON(respBody)
 	s.logDownstream(esOrConnect+"|post", "POST", url, "", resp.StatusCode, respBody, nil)
 	return resp, respBody, nil
 }
@@ -362,17 +529,68 @@ func (s *Server) doDELETE(ctx context.Context, url string, esOrConnect string) (
 	} else {
 		s.withConnectAuth(req)
 	}
-	resp, err := s.client.Do(req)
+	release, err := s.limits.acquire(ctx, esOrConnect)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|delete", "DELETE", url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer release()
+	resp, err := s.send(req, esOrConnect)
 	if err != nil {
 		s.logDownstream(esOrConnect+"|delete", "DELETE", url, "", 0, nil, err)
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := s.readResponse(resp)
+	if err != nil {
+		s.logDownstream(esOrConnect+"|delete", "DELETE", url, "", resp.StatusCode, nil, err)
+		return nil, nil, err
+	}
+	respBody = s.redact.JSON(respBody)
 	s.logDownstream(esOrConnect+"|delete", "DELETE", url, "", resp.StatusCode, respBody, nil)
 	return resp, respBody, nil
 }
 
+// 非 ES/Connect 的下游（ClickHouse、Loki 等），鉴权由调用方提供
+func (s *Server) doRequest(ctx context.Context, method, url string, body []byte, kind, contentType string, auth func(*http.Request)) (*http.Response, []byte, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytesReader(body)
+	}
+	op := kind + "|" + strings.ToLower(method)
+	req, err := http.NewRequestWithContext(ctx, method, url, rd)
+	if err != nil {
+		s.logDownstream(op, method, url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if auth != nil {
+		auth(req)
+	}
+	release, err := s.limits.acquire(ctx, kind)
+	if err != nil {
+		s.logDownstream(op, method, url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer release()
+	resp, err := s.send(req, kind)
+	if err != nil {
+		s.logDownstream(op, method, url, "", 0, nil, err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := s.readResponse(resp)
+	if err != nil {
+		s.logDownstream(op, method, url, "", resp.StatusCode, nil, err)
+		return nil, nil, err
+	}
+	respBody = s.redact.JSON(respBody)
+	s.logDownstream(op, method, url, "", resp.StatusCode, respBody, nil)
+	return resp, respBody, nil
+}
+
 /************** 业务处理：创建/更新 **************/
 
 func (s *Server) handleClientConfig(w http.ResponseWriter, r *http.Request) {
@@ -381,10 +599,20 @@ func (s *Server) handleClientConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
 
-	var cfg Config
-	mustReadYAML("config.yaml", &cfg)
+	// 本地文件按当前内容返回；读取或解析失败（如写了一半）返回 500，不 panic
+	cfg := s.cfg
+	if f, ok := s.conf.src.(*fileConfigSource); ok {
+		rev, err := f.load(r.Context())
+		if err == nil {
+			cfg, err = parseConfig(rev.Raw)
+		}
+		if err != nil {
+			writeJSON(w, 500, errorBody("client-config", err))
+			return
+		}
+	}
 
-	writeJSON(w, http.StatusOK, cfg)
+	writeJSON(w, http.StatusOK, s.redact.Value(cfg))
 }
 
 func (s *Server) handleCreateDataStream(w http.ResponseWriter, r *http.Request) {
@@ -393,94 +621,223 @@ func (s *Server) handleCreateDataStream(w http.ResponseWriter, r *http.Request)
 	s.logger.Printf("step=data-stream put url=%s", url)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, url, nil)
 	s.withESAuth(req)
-	resp, err := s.client.Do(req)
+	release, err := s.limits.acquire(ctx, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "data-stream", "error": err.Error()})
+		writeJSON(w, 503, errorBody("data-stream", err))
+		return
+	}
+	resp, err := s.send(req, "es")
+	release()
+	if err != nil {
+		writeJSON(w, 500, errorBody("data-stream", err))
 		return
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	writeJSON(w, resp.StatusCode, map[string]any{
+	body, err := s.readResponse(resp)
+	if err != nil {
+		writeJSON(w, 502, errorBody("data-stream", err))
+		return
+	}
+	body = s.redact.JSON(body)
+	out := map[string]any{
 		"step":   "data-stream",
 		"status": resp.Status,
 		"body":   string(body),
-	})
+	}
+	code := resp.StatusCode
+	switch {
+	case code < 300:
+		out["result"] = ensureCreated
+	case esAlreadyExists(body):
+		// 已存在：核对模板与策略，一致即视为成功
+		diffs, err := s.verifyExistingDataStream(ctx)
+		if err != nil {
+			writeJSON(w, 502, errorBody("data-stream", err))
+			return
+		}
+		if len(diffs) == 0 {
+			code, out["result"], out["status"] = http.StatusOK, ensureUnchanged, "200 OK"
+		} else {
+			code, out["result"], out["status"] = http.StatusConflict, ensureConflict, "409 Conflict"
+			out["diffs"] = diffs
+		}
+	}
+	s.logger.Printf("step=data-stream result=%v code=%d", out["result"], code)
+	writeJSON(w, code, out)
 }
 
 func (s *Server) handlePutILM(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := optionsContext(r)
 	file := s.cfg.ES.Files.ILM
-	b, err := readJSONFile(file)
+	b, source, err := s.readAssetOrDefault(ctx, assetILM, file)
 	if err != nil {
 		s.logger.Printf("step=ilm read_file_err file=%s err=%v", file, err)
 		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	url := fmt.Sprintf("%s/_ilm/policy/%s", s.cfg.ES.Host, s.cfg.ES.Names.ILMPolicy)
-	s.logger.Printf("step=ilm put url=%s file=%s size=%d", url, file, len(b))
+	if s.applyILM(w, r, source, b) {
+		s.recordAsset(r, assetILM, "", source, 0, b)
+	}
+}
+
+// 下发 ILM 文档（file 仅用于日志），返回是否成功；版本历史回滚也走这里
+func (s *Server) applyILM(w http.ResponseWriter, r *http.Request, file string, raw []byte) bool {
+	ctx := r.Context()
+	deployed, ok := s.guardESOwnership(w, r, assetILM)
+	if !ok {
+		return false
+	}
+	var ar *approvalRequiredError
+	if errors.As(s.requireApproval(ctx), &ar) {
+		writeApprovalRequired(w, "ilm", ar)
+		return false
+	}
+	var ge *guardrailError
+	if errors.As(s.checkGuardrails(assetILM, raw), &ge) {
+		writeGuardrailError(w, "ilm", ge)
+		return false
+	}
+	url, b, warnings, err := s.prepareLifecyclePolicy(ctx, raw)
+	if err != nil {
+		s.logger.Printf("step=ilm convert_err file=%s err=%v", file, err)
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return false
+	}
+	if s.skipUnchanged(w, assetILM, deployed, b, "policy") {
+		return false
+	}
+	s.logger.Printf("step=ilm put url=%s file=%s size=%d flavor=%s", url, file, len(b), s.esFlavor())
 	resp, respBody, err := s.doPUT(ctx, url, b, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]string{"error": err.Error()})
-		return
+		writeJSON(w, 500, errorBody("ilm", err))
+		return false
+	}
+	out := map[string]any{"step": "ilm", "status": resp.Status, "body": string(respBody)}
+	if len(warnings) > 0 {
+		out["warnings"] = warnings
 	}
-	writeJSON(w, resp.StatusCode, map[string]any{"step": "ilm", "status": resp.Status, "body": string(respBody)})
+	writeJSON(w, resp.StatusCode, out)
+	return resp.StatusCode < 300
 }
 
 func (s *Server) handlePutTemplate(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := optionsContext(r)
 	file := s.cfg.ES.Files.Template
-	b, err := readJSONFile(file)
+	b, source, err := s.readAssetOrDefault(ctx, assetTemplate, file)
 	if err != nil {
 		s.logger.Printf("step=template read_file_err file=%s err=%v", file, err)
 		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
+	if s.applyTemplate(w, r, source, b) {
+		s.recordAsset(r, assetTemplate, "", source, 0, b)
+	}
+}
+
+func (s *Server) applyTemplate(w http.ResponseWriter, r *http.Request, file string, raw []byte) bool {
+	var ar *approvalRequiredError
+	if errors.As(s.requireApproval(r.Context()), &ar) {
+		writeApprovalRequired(w, "template", ar)
+		return false
+	}
+	deployed, ok := s.guardESOwnership(w, r, assetTemplate)
+	if !ok {
+		return false
+	}
+	b, err := s.alignTemplatePatterns(raw)
+	var ge *guardrailError
+	if err == nil && errors.As(s.checkGuardrails(assetTemplate, b), &ge) {
+		writeGuardrailError(w, "template", ge)
+		return false
+	}
+	var ecsIssues []ecsIssue
+	if err == nil {
+		if ecsIssues = s.checkECS(b); len(ecsIssues) > 0 && s.cfg.ECS.mode() == ecsModeBlock {
+			writeECSError(w, "template", ecsIssues)
+			return false
+		}
+		b, err = s.prepareIndexTemplate(b)
+	}
+	if err != nil {
+		s.logger.Printf("step=template convert_err file=%s err=%v", file, err)
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return false
+	}
+	if s.skipUnchanged(w, assetTemplate, deployed, b) {
+		return false
+	}
 	url := fmt.Sprintf("%s/_index_template/%s", s.cfg.ES.Host, s.cfg.ES.Names.IndexTemplate)
 	s.logger.Printf("step=template put url=%s file=%s size=%d", url, file, len(b))
-	resp, respBody, err := s.doPUT(ctx, url, b, "es")
+	resp, respBody, err := s.doPUT(r.Context(), url, b, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]string{"error": err.Error()})
-		return
+		writeJSON(w, 500, errorBody("template", err))
+		return false
+	}
+	out := map[string]any{"step": "template", "status": resp.Status, "body": string(respBody)}
+	if len(ecsIssues) > 0 {
+		out["warnings"] = ecsMessages(ecsIssues)
 	}
-	writeJSON(w, resp.StatusCode, map[string]any{"step": "template", "status": resp.Status, "body": string(respBody)})
+	writeJSON(w, resp.StatusCode, out)
+	return resp.StatusCode < 300
 }
 
 func (s *Server) handlePutPipeline(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := optionsContext(r)
 	file := s.cfg.ES.Files.Pipeline
-	b, err := readJSONFile(file)
+	b, source, err := s.readAssetOrDefault(ctx, assetPipeline, file)
 	if err != nil {
 		s.logger.Printf("step=pipeline read_file_err file=%s err=%v", file, err)
 		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
+	if s.applyPipeline(w, r, source, b) {
+		s.recordAsset(r, assetPipeline, "", source, 0, b)
+	}
+}
+
+func (s *Server) applyPipeline(w http.ResponseWriter, r *http.Request, file string, raw []byte) bool {
+	var ar *approvalRequiredError
+	if errors.As(s.requireApproval(r.Context()), &ar) {
+		writeApprovalRequired(w, "pipeline", ar)
+		return false
+	}
+	deployed, ok := s.guardESOwnership(w, r, assetPipeline)
+	if !ok {
+		return false
+	}
+	b, err := s.applyRouting(raw)
+	if err == nil && s.ownershipTracked(assetPipeline) {
+		b, err = s.stampMeta(b)
+	}
+	if err != nil {
+		s.logger.Printf("step=pipeline convert_err file=%s err=%v", file, err)
+		writeJSON(w, 400, map[string]string{"error": "parse pipeline: " + err.Error()})
+		return false
+	}
+	if s.skipUnchanged(w, assetPipeline, deployed, b) {
+		return false
+	}
 	url := fmt.Sprintf("%s/_ingest/pipeline/%s", s.cfg.ES.Host, s.cfg.ES.Names.Pipeline)
 	s.logger.Printf("step=pipeline put url=%s file=%s size=%d", url, file, len(b))
-	resp, respBody, err := s.doPUT(ctx, url, b, "es")
+	resp, respBody, err := s.doPUT(r.Context(), url, b, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]string{"error": err.Error()})
-		return
+		writeJSON(w, 500, errorBody("pipeline", err))
+		return false
 	}
 	writeJSON(w, resp.StatusCode, map[string]any{"step": "pipeline", "status": resp.Status, "body": string(respBody)})
+	return resp.StatusCode < 300
 }
 
 func (s *Server) handleRegisterSink(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	file := s.cfg.Connect.Files.Sink
-	b, err := readJSONFile(file)
+	p, err := s.primarySink()
 	if err != nil {
-		s.logger.Printf("step=sink read_file_err file=%s err=%v", file, err)
 		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	url := fmt.Sprintf("%s/connectors", s.cfg.Connect.Host)
-	s.logger.Printf("step=sink post url=%s file=%s size=%d", url, file, len(b))
-	resp, respBody, err := s.doPOST(ctx, url, b, "connect")
-	if err != nil {
-		writeJSON(w, 500, map[string]string{"error": err.Error()})
-		return
-	}
-	writeJSON(w, resp.StatusCode, map[string]any{"step": "sink", "status": resp.Status, "body": string(respBody)})
+	res, err := p.Register(optionsContext(r))
+	s.recordSinkRegister(r, p, res, err, 0)
+	s.trackDesired(r, p, desiredRunning, res, err)
+	s.writeSinkRegister(w, p, res, err)
 }
 
 type captureWriter struct {
@@ -502,11 +859,11 @@ func (c *captureWriter) Write(b []byte) (int, error) { c.body += string(b); retu
 
 func (s *Server) handleVerifyILMExplain(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	url := fmt.Sprintf("%s/%s/_ilm/explain", s.cfg.ES.Host, s.cfg.ES.Names.DataStream)
+	url := s.lifecycleExplainURL()
 	s.logger.Printf("verify=ilm-explain url=%s", url)
 	resp, body, err := s.doGET(ctx, url, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "verify-ilm", "error": err.Error()})
+		writeJSON(w, 500, errorBody("verify-ilm", err))
 		return
 	}
 	writeJSON(w, resp.StatusCode, jsonRaw(body))
@@ -518,7 +875,7 @@ func (s *Server) handleVerifyTemplate(w http.ResponseWriter, r *http.Request) {
 	s.logger.Printf("verify=index-template url=%s", url)
 	resp, body, err := s.doGET(ctx, url, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "verify-template", "error": err.Error()})
+		writeJSON(w, 500, errorBody("verify-template", err))
 		return
 	}
 	writeJSON(w, resp.StatusCode, jsonRaw(body))
@@ -530,22 +887,20 @@ func (s *Server) handleVerifyPipeline(w http.ResponseWriter, r *http.Request) {
 	s.logger.Printf("verify=pipeline url=%s", url)
 	resp, body, err := s.doGET(ctx, url, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "verify-pipeline", "error": err.Error()})
+		writeJSON(w, 500, errorBody("verify-pipeline", err))
 		return
 	}
 	writeJSON(w, resp.StatusCode, jsonRaw(body))
 }
 
 func (s *Server) handleVerifySinkStatus(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	url := fmt.Sprintf("%s/connectors/%s/status", s.cfg.Connect.Host, s.cfg.Connect.Names.Sink)
-	s.logger.Printf("verify=sink-status url=%s", url)
-	resp, body, err := s.doGET(ctx, url, "connect")
+	p, err := s.primarySink()
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "verify-sink-status", "error": err.Error()})
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, resp.StatusCode, jsonRaw(body))
+	res, err := p.Status(r.Context())
+	s.writeSinkResult(w, "verify-sink-status", res, err)
 }
 
 func (s *Server) handleQueryDataStream(w http.ResponseWriter, r *http.Request) {
@@ -554,7 +909,7 @@ func (s *Server) handleQueryDataStream(w http.ResponseWriter, r *http.Request) {
 	s.logger.Printf("_data_stream url=%s", url)
 	resp, body, err := s.doGET(ctx, url, "es")
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "query _data_stream", "error": err.Error()})
+		writeJSON(w, 500, errorBody("query _data_stream", err))
 		return
 	}
 	writeJSON(w, resp.StatusCode, jsonRaw(body))
@@ -563,186 +918,639 @@ func (s *Server) handleQueryDataStream(w http.ResponseWriter, r *http.Request) {
 /************** 业务处理：维护（Kafka Connect） **************/
 
 func (s *Server) handleGetSinkConfig(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	url := fmt.Sprintf("%s/connectors/%s/config", s.cfg.Connect.Host, s.cfg.Connect.Names.Sink)
-	s.logger.Printf("connect action=get-config name=%s url=%s", s.cfg.Connect.Names.Sink, url)
-	resp, body, err := s.doGET(ctx, url, "connect")
+	p, err := s.primarySink()
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "connect-config", "error": err.Error()})
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, resp.StatusCode, jsonRaw(body))
+	res, err := p.Config(r.Context())
+	s.writeSinkResult(w, "connect-config", res, err)
 }
 
 func (s *Server) handlePauseSink(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	url := fmt.Sprintf("%s/connectors/%s/pause", s.cfg.Connect.Host, s.cfg.Connect.Names.Sink)
-	s.logger.Printf("connect action=pause name=%s url=%s", s.cfg.Connect.Names.Sink, url)
-	resp, body, err := s.doPUTNoBody(ctx, url, "connect")
+	p, err := s.primarySink()
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "connect-pause", "error": err.Error()})
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, resp.StatusCode, jsonRaw(body))
+	res, err := p.Pause(optionsContext(r))
+	s.trackDesired(r, p, desiredPaused, res, err)
+	s.writeSinkResult(w, "connect-pause", res, err)
 }
 
 func (s *Server) handleResumeSink(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	url := fmt.Sprintf("%s/connectors/%s/resume", s.cfg.Connect.Host, s.cfg.Connect.Names.Sink)
-	s.logger.Printf("connect action=resume name=%s url=%s", s.cfg.Connect.Names.Sink, url)
-	resp, body, err := s.doPUTNoBody(ctx, url, "connect")
+	p, err := s.primarySink()
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "connect-resume", "error": err.Error()})
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, resp.StatusCode, jsonRaw(body))
+	res, err := p.Resume(optionsContext(r))
+	s.trackDesired(r, p, desiredRunning, res, err)
+	s.writeSinkResult(w, "connect-resume", res, err)
 }
 
 func (s *Server) handleDeleteSink(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	url := fmt.Sprintf("%s/connectors/%s", s.cfg.Connect.Host, s.cfg.Connect.Names.Sink)
-	s.logger.Printf("connect action=delete name=%s url=%s", s.cfg.Connect.Names.Sink, url)
-	resp, body, err := s.doDELETE(ctx, url, "connect")
+	p, err := s.primarySink()
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	res, err := p.Delete(optionsContext(r))
+	s.trackDesired(r, p, desiredAbsent, res, err)
+	s.writeSinkResult(w, "connect-delete", res, err)
+}
+
+// 列出 Connect 已安装插件，并标记 sink 文件引用的 connector.class 是否存在
+func (s *Server) handleConnectPlugins(w http.ResponseWriter, r *http.Request) {
+	s.logger.Printf("connect action=list-plugins url=%s/connector-plugins", s.cfg.Connect.Host)
+	plugins, err := s.listConnectPlugins(r.Context())
 	if err != nil {
-		writeJSON(w, 500, map[string]any{"step": "connect-delete", "error": err.Error()})
+		writeJSON(w, http.StatusBadGateway, errorBody("connect-plugins", err))
 		return
 	}
-	writeJSON(w, resp.StatusCode, jsonRaw(body))
+
+	installed := map[string]bool{}
+	for _, p := range plugins {
+		installed[p.Class] = true
+	}
+	required := map[string]bool{}
+	missing := []string{}
+	for _, c := range s.requiredPlugins() {
+		required[c] = installed[c]
+		if !installed[c] {
+			missing = append(missing, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"plugins":  plugins,
+		"required": required,
+		"missing":  missing,
+	})
 }
 
 /************** 静态文件 + SPA 回退 **************/
 
+// /admin/* 由顶层 mux 单独路由，这里只处理静态文件与 SPA 回退
 type spaHandler struct {
-	staticDir    string
-	indexFile    string
-	adminHandler http.Handler
+	staticDir string
+	indexFile string
+	basePath  string // 非 / 时改写 index.html 的 <base href>（basepath.go）
 }
 
 func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 1) /admin/* -> 交给 API
-	if strings.HasPrefix(r.URL.Path, "/admin/") {
-		if h.adminHandler != nil {
-			h.adminHandler.ServeHTTP(w, r)
-			return
-		}
-		http.NotFound(w, r)
-		return
-	}
-
-	// 2) 静态文件或 SPA 回退
+	// 静态文件或 SPA 回退
 	// 根路径直接返回 index.html
 	if r.URL.Path == "/" || r.URL.Path == "" {
-		http.ServeFile(w, r, filepath.Join(h.staticDir, h.indexFile))
+		h.serveFile(w, r, filepath.Join(h.staticDir, h.indexFile))
 		return
 	}
 
 	clean := filepath.Clean(r.URL.Path)
 	try := filepath.Join(h.staticDir, clean)
 
-	if fi, err := os.Stat(try); err == nil && !fi.IsDir() {
-		http.ServeFile(w, r, try)
+	if h.serveFile(w, r, try) {
 		return
 	}
 
 	// 未命中文件 -> SPA 回退到 index.html
-	http.ServeFile(w, r, filepath.Join(h.staticDir, h.indexFile))
+	h.serveFile(w, r, filepath.Join(h.staticDir, h.indexFile))
+}
+
+// 只直出文件、不回退 index.html：/static/ 下缺失的 chunk 应返回 404，而不是 200 的 HTML
+func (h *spaHandler) serveAsset(w http.ResponseWriter, r *http.Request) {
+	if !h.serveFile(w, r, filepath.Join(h.staticDir, filepath.Clean("/"+r.URL.Path))) {
+		http.NotFound(w, r)
+	}
+}
+
+// 构建产物中与原文件并列的预压缩版本，按顺序优先
+var precompressedExts = []struct{ ext, encoding string }{{".br", "br"}, {".gz", "gzip"}}
+
+// 客户端接受 br / gzip 且存在 <file>.br / <file>.gz 时直接返回压缩文件（Content-Type 仍按原文件名）；
+// 存在压缩版本时都带 Vary: Accept-Encoding，避免缓存把压缩内容返回给不支持的客户端。文件不存在或为目录时返回 false
+func (h *spaHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	if h.basePath != "/" && name == filepath.Join(h.staticDir, h.indexFile) {
+		return h.serveIndex(w, r, name)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+		return false
+	}
+	varied := false
+	for _, pc := range precompressedExts {
+		fi, err := os.Stat(name + pc.ext)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		if !varied {
+			w.Header().Add("Vary", "Accept-Encoding")
+			varied = true
+		}
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), pc.encoding) {
+			continue
+		}
+		f, err := os.Open(name + pc.ext)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		w.Header().Set("Content-Encoding", pc.encoding)
+		http.ServeContent(w, r, name, fi.ModTime(), f)
+		return true
+	}
+	http.ServeFile(w, r, name)
+	return true
+}
+
+// Accept-Encoding 是否接受 enc：显式列出的优先于 *，q=0 表示拒绝
+func acceptsEncoding(header, enc string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		accepted := true
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q == 0 {
+				accepted = false
+			}
+		}
+		switch {
+		case strings.EqualFold(name, enc):
+			return accepted
+		case name == "*":
+			wildcard = accepted
+		}
+	}
+	return wildcard
+}
+
+// 分端口部署时 UI 端口上的 /admin：只放行只读请求，写操作须走 admin 端口
+func readOnlyAdmin(adminAddr string) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+			default:
+				logField(r, "rejected", "readonly_listener")
+				writeJSON(w, http.StatusForbidden, map[string]string{
+					"error":        "mutating admin API is not served on this listener",
+					"admin_listen": adminAddr,
+				})
+			}
+		})
+	}
 }
 
 /************** main **************/
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		os.Exit(runInit(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		os.Exit(runCheckConfig(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	flag.BoolVar(&strictConfig, "strict", false, "Refuse to start (or reload) when check-config reports errors")
+	flag.Var(&configSetOverrides, "set", "Override a config key, e.g. -set es.host=https://es:9200 (repeatable)")
 	flag.Parse()
 	withEnv(flagListen, "LISTEN")
+	withEnv(flagAdminListen, "ADMIN_LISTEN")
+	withEnv(flagUIAdmin, "UI_ADMIN")
 	withEnv(flagStatic, "STATIC_DIR")
+	withEnv(flagConfig, "CONFIG")
+	withEnv(flagBasePath, "BASE_PATH")
+	basePath, err := normalizeBasePath(*flagBasePath)
+	if err != nil {
+		log.Fatalf("-base-path: %v", err)
+	}
 
-	var cfg Config
-	mustReadYAML("config.yaml", &cfg)
+	cfg, conf, err := loadConfig(*flagConfig)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if strictConfig {
+		if err := strictConfigError(conf.raw); err != nil {
+			log.Fatalf("config from %s: %v", conf.src, err)
+		}
+	}
 
 	s := &Server{
 		cfg: cfg,
 		// 注意：VerifyTLS=true 表示“校验证书”，我们创建 client 时需要传入“是否跳过校验”
-		// 所以这里用 newHTTPClient(!cfg.ES.VerifyTLS)
-		client: newHTTPClient(!cfg.ES.VerifyTLS),
-		logger: log.New(os.Stdout, "", log.LstdFlags|log.Lmicroseconds),
+		// 所以这里用 !cfg.ES.VerifyTLS
+		clients:      newDownstreamClients(cfg.Timeouts, cfg.Proxy, !cfg.ES.VerifyTLS),
+		breakers:     newBreakers(cfg.Breaker),
+		idem:         newIdempotencyStore(cfg.Idempotency),
+		logger:       log.New(os.Stdout, "", log.LstdFlags|log.Lmicroseconds),
+		redact:       newRedactor(cfg.Redact.Keys),
+		limits:       newConcurrencyLimiter(cfg.Limits.Concurrency),
+		cache:        newResponseCache(mustParseDuration("cache.ttl", cfg.Cache.TTL)),
+		jobs:         newJobManager(),
+		ws:           newWSHub(),
+		httplog:      newHTTPLogFeed(newRedactor(cfg.Redact.Keys)),
+		reqlog:       newRequestLogRules(cfg.RequestLog),
+		alerts:       newAlertManager(),
+		sched:        newScheduler(cfg.Schedules),
+		freeze:       newFreezeCalendar(cfg.Freeze),
+		assets:       newAssetStore(cfg.Assets),
+		desired:      newDesiredStore(cfg.Assets),
+		setup:        newSetupStore(cfg.Assets),
+		plans:        newPlanStore(cfg),
+		restarts:     newTaskRestarter(cfg.TaskRestarter),
+		restartGuard: newRestartGuard(cfg.RestartGuard),
+		maintenance:  newMaintenanceStore(cfg.Assets),
+		loggers:      newLoggerOverrides(),
+		pressure:     &pressureSampler{},
+		topoSampler:  &topologySampler{},
+		git:          newGitStore(cfg.Git),
+		locks:        newLockManager(cfg.Lock),
+		probes:       newProbeState(cfg.Probes),
+		conf:         conf,
+	}
+	s.clients.metrics.logger.Store(s.logger)
+	if err := s.sched.load(); err != nil {
+		s.logger.Printf("warning: load schedule state: %v", err)
+	}
+	if err := s.assets.load(); err != nil {
+		s.logger.Printf("warning: load asset versions: %v", err)
+	}
+	if err := s.desired.load(); err != nil {
+		s.logger.Printf("warning: load connector desired state: %v", err)
+	}
+	if err := s.setup.load(); err != nil {
+		s.logger.Printf("warning: load setup state: %v", err)
+	}
+	if err := s.plans.load(); err != nil {
+		s.logger.Printf("warning: load plans: %v", err)
+	}
+	if err := s.maintenance.load(); err != nil {
+		s.logger.Printf("warning: load maintenance state: %v", err)
+	}
+	if cfg.Operator.Enabled {
+		op, err := newOperator(s, cfg.Operator)
+		if err != nil {
+			s.logger.Fatalf("operator: %v", err)
+		}
+		s.operator = op
+	}
+	if err := s.initTenants(); err != nil {
+		s.logger.Fatalf("tenancy: %v", err)
+	}
+	if s.git != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := s.git.init(ctx); err != nil {
+			s.logger.Printf("warning: prepare git asset repo %s: %v", cfg.Git.Dir, err)
+		}
+		cancel()
 	}
 
 	// --- 构建 /admin/* 的路由（沿用你现有的全部业务处理） ---
 	adminMux := http.NewServeMux()
 
 	adminMux.HandleFunc("GET /admin/client-config", s.handleClientConfig)
-
-	// 创建/更新
-	adminMux.HandleFunc("POST /admin/es/data-stream", s.handleCreateDataStream)
-	adminMux.HandleFunc("POST /admin/es/ilm", s.handlePutILM)
-	adminMux.HandleFunc("POST /admin/es/template", s.handlePutTemplate)
-	adminMux.HandleFunc("POST /admin/es/pipeline", s.handlePutPipeline)
-	adminMux.HandleFunc("POST /admin/connect/sink", s.handleRegisterSink)
+	adminMux.HandleFunc("GET /admin/config/source", s.handleConfigSource)
+	adminMux.HandleFunc("GET /admin/config/schema", s.handleConfigSchema)
+	adminMux.HandleFunc("GET /admin/setup/state", s.handleGetSetupState)
+	adminMux.HandleFunc("PUT /admin/setup/state", s.withSchema("setup-state", s.handlePutSetupState))
+
+	// 健康检查 / 兼容性探测
+	adminMux.HandleFunc("GET /admin/health", s.handleHealth)
+	adminMux.HandleFunc("POST /admin/probe", s.handleProbe)
+
+	// 创建/更新（下发类接口经 withLock 串行，见 lock.go）
+	adminMux.HandleFunc("POST /admin/es/data-stream", s.trackSetupStep("data_stream", s.withLock(s.handleCreateDataStream)))
+	adminMux.HandleFunc("POST /admin/es/ilm", s.trackSetupStep("ilm", s.withLock(s.handlePutILM)))
+	adminMux.HandleFunc("POST /admin/es/template", s.trackSetupStep("template", s.withLock(s.handlePutTemplate)))
+	adminMux.HandleFunc("POST /admin/es/template/simulate", s.handleSimulateTemplate)
+	adminMux.HandleFunc("POST /admin/es/pipeline", s.trackSetupStep("pipeline", s.withLock(s.handlePutPipeline)))
+	adminMux.HandleFunc("GET /admin/topology", s.topologyRoute())
+	adminMux.HandleFunc("GET /admin/es/routes", s.handleListRoutes)
+	adminMux.HandleFunc("POST /admin/es/routes", s.withLock(s.handleApplyRoutes))
+	adminMux.HandleFunc("PATCH /admin/es/{kind}", s.withLock(s.handlePatchAsset))
+	adminMux.HandleFunc("POST /admin/connect/sink", s.trackSetupStep("sink", s.withLock(s.handleRegisterSink)))
 
 	// 验证查看
-	adminMux.HandleFunc("GET /admin/verify/ilm-explain", s.handleVerifyILMExplain)
-	adminMux.HandleFunc("GET /admin/verify/template", s.handleVerifyTemplate)
-	adminMux.HandleFunc("GET /admin/verify/pipeline", s.handleVerifyPipeline)
-	adminMux.HandleFunc("GET /admin/query/data-streams", s.handleQueryDataStream)
-	adminMux.HandleFunc("GET /admin/verify/sink-status", s.handleVerifySinkStatus)
+	adminMux.HandleFunc("GET /admin/verify/ilm-explain", s.cacheGET("ilm-explain", s.handleVerifyILMExplain))
+	adminMux.HandleFunc("GET /admin/verify/template", s.cacheGET("template", s.handleVerifyTemplate))
+	adminMux.HandleFunc("GET /admin/verify/pipeline", s.cacheGET("pipeline", s.handleVerifyPipeline))
+	adminMux.HandleFunc("GET /admin/query/data-streams", s.cacheGET("data-streams", s.handleQueryDataStream))
+	adminMux.HandleFunc("GET /admin/verify/sink-status", s.cacheGET("sink-status", s.handleVerifySinkStatus))
+	adminMux.HandleFunc("GET /admin/verify/data-stream", s.cacheGET("data-stream", s.handleVerifyDataStream))
+	adminMux.HandleFunc("GET /admin/verify/kafka-topic", s.handleVerifyKafkaTopic)
+	adminMux.HandleFunc("GET /admin/verify/all", s.handleVerifyAll)
 
 	// 维护（Connect）
 	adminMux.HandleFunc("GET /admin/connect/config", s.handleGetSinkConfig)
-	adminMux.HandleFunc("PUT /admin/connect/pause", s.handlePauseSink)
-	adminMux.HandleFunc("PUT /admin/connect/resume", s.handleResumeSink)
-	adminMux.HandleFunc("DELETE /admin/connect/delete", s.handleDeleteSink)
-
-	// 给 /admin/* 包上 CORS 和请求日志
-	adminHandler := requestLogger(s.logger, cors(cfg.Frontend.AllowedOrigins, adminMux))
+	adminMux.HandleFunc("PUT /admin/connect/pause", s.withLock(s.handlePauseSink))
+	adminMux.HandleFunc("PUT /admin/connect/resume", s.withLock(s.handleResumeSink))
+	adminMux.HandleFunc("DELETE /admin/connect/delete", s.withLock(s.handleDeleteSink))
+	adminMux.HandleFunc("POST /admin/connect/bulk", s.withSchema("connect-bulk", s.withLock(s.handleBulkConnectors)))
+	adminMux.HandleFunc("GET /admin/connect/state", s.handleConnectState)
+	adminMux.HandleFunc("PUT /admin/connect/state/{name}", s.withSchema("connect-state", s.handleSetConnectState)) // 纠正时自行取锁
+	adminMux.HandleFunc("GET /admin/connect/restarts", s.handleTaskRestarts)
+	adminMux.HandleFunc("GET /admin/connect/loggers", s.handleConnectLoggers)
+	adminMux.HandleFunc("PUT /admin/connect/loggers", s.withSchema("connect-logger", s.handleSetConnectLogger))
+	adminMux.HandleFunc("DELETE /admin/connect/loggers/{logger}", s.handleResetConnectLogger)
+	adminMux.HandleFunc("GET /admin/connect/plugins", s.handleConnectPlugins)
+	adminMux.HandleFunc("GET /admin/connect/config-providers", s.handleConnectConfigProviders)
+
+	// 多 sink（Connect / Logstash / Loki / ClickHouse / S3）
+	adminMux.HandleFunc("GET /admin/sinks", s.handleListSinks)
+	adminMux.HandleFunc("POST /admin/sinks/{name}", s.withLock(s.handleNamedSinkRegister))
+	adminMux.HandleFunc("GET /admin/sinks/{name}/status", s.cacheGET("sink-status", s.handleNamedSinkStatus))
+	adminMux.HandleFunc("GET /admin/sinks/{name}/config", s.handleNamedSinkConfig)
+	adminMux.HandleFunc("PUT /admin/sinks/{name}/pause", s.withLock(s.handleNamedSinkPause))
+	adminMux.HandleFunc("PUT /admin/sinks/{name}/resume", s.withLock(s.handleNamedSinkResume))
+	adminMux.HandleFunc("DELETE /admin/sinks/{name}", s.withLock(s.handleNamedSinkDelete))
+	adminMux.HandleFunc("GET /admin/sinks/{name}/replication", s.handleSinkReplication)
+
+	// 归档层（S3 快照仓库 / searchable snapshot / S3 sink / 回灌）
+	adminMux.HandleFunc("PUT /admin/archive/repository", s.withLock(s.handlePutArchiveRepository))
+	adminMux.HandleFunc("PUT /admin/archive/searchable-snapshots", s.withLock(s.handlePutSearchableSnapshots))
+	adminMux.HandleFunc("POST /admin/archive/sink", s.withLock(s.handleRegisterArchiveSink))
+	adminMux.HandleFunc("POST /admin/archive/restore", s.withSchema("archive-restore", s.handleArchiveRestore))
+
+	// Kafka
+	adminMux.HandleFunc("GET /admin/kafka/cluster", s.handleKafkaCluster)
+	adminMux.HandleFunc("GET /admin/kafka/tail", s.handleKafkaTail)
+	adminMux.HandleFunc("POST /admin/loadtest", s.withSchema("loadtest", s.handleLoadtest))
+
+	// 通知
+	adminMux.HandleFunc("POST /admin/notifications/test", s.handleTestNotification)
+	adminMux.HandleFunc("GET /admin/alerts", s.handleListAlerts)
+
+	// 实时状态
+	adminMux.HandleFunc("GET /admin/status", s.handleLiveStatus)
+	adminMux.HandleFunc("GET /admin/ws", s.handleWS)
+	adminMux.HandleFunc("GET "+httpLogPath, s.handleHTTPLogStream)
+	adminMux.HandleFunc("GET /admin/topology/stream", s.handleTopologyStream)
+
+	// 后台任务
+	adminMux.HandleFunc("GET /admin/jobs", s.handleListJobs)
+	adminMux.HandleFunc("GET /admin/jobs/{id}", s.handleGetJob)
+	adminMux.HandleFunc("DELETE /admin/jobs/{id}", s.handleCancelJob)
+
+	// 集群健康与分片分配诊断
+	adminMux.HandleFunc("GET /admin/es/cluster/health", s.handleClusterHealth)
+	adminMux.HandleFunc("GET /admin/es/allocation/explain", s.handleAllocationExplain)
+	adminMux.HandleFunc("GET /admin/es/pressure", s.handleESPressure)
+	adminMux.HandleFunc("GET /admin/es/retention/estimate", s.handleRetentionEstimate)
+	adminMux.HandleFunc("GET /admin/es/slowlog", s.handleSlowlog)
+	adminMux.HandleFunc("PUT /admin/es/ccr", s.withLock(s.handleSetupCCR))
+	adminMux.HandleFunc("GET /admin/es/ccr/status", s.handleCCRStatus)
+
+	// 索引维护（force-merge / shrink）
+	adminMux.HandleFunc("GET /admin/es/forcemerge/candidates", s.handleForcemergeCandidates)
+	adminMux.HandleFunc("POST /admin/es/forcemerge", s.withSchema("forcemerge", s.handleForcemerge))
+	adminMux.HandleFunc("POST /admin/es/reindex", s.withSchema("reindex", s.withLock(s.handleReindex)))
+	adminMux.HandleFunc("PUT /admin/es/downsample", s.withLock(s.handlePutDownsample))
+	adminMux.HandleFunc("GET /admin/es/transforms", s.handleListTransforms)
+	adminMux.HandleFunc("PUT /admin/es/transforms", s.withLock(s.handlePutTransforms))
+	adminMux.HandleFunc("PUT /admin/es/transforms/{name}/start", s.withLock(s.handleStartTransform))
+	adminMux.HandleFunc("PUT /admin/es/transforms/{name}/stop", s.withLock(s.handleStopTransform))
+	adminMux.HandleFunc("GET /admin/es/watches", s.handleListWatches)
+	adminMux.HandleFunc("PUT /admin/es/watches", s.withLock(s.handlePutWatches))
+	adminMux.HandleFunc("POST /admin/es/watches/{name}/execute", s.handleExecuteWatch)
+	adminMux.HandleFunc("POST "+grokTestPath, s.withSchema("grok-test", s.handleGrokTest))
+	adminMux.HandleFunc("GET /admin/es/pipeline/processors", s.handleGetPipelineProcessors)
+	adminMux.HandleFunc("PUT /admin/es/pipeline/processors", s.withSchema("pipeline-processors", s.withLock(s.handlePutPipelineProcessors)))
+	adminMux.HandleFunc("GET /admin/es/pipeline/stats", s.cacheGET("pipeline-stats", s.handlePipelineStats))
+	adminMux.HandleFunc("GET /admin/es/pipeline/filters", s.handleGetPipelineFilters)
+	adminMux.HandleFunc("PUT /admin/es/pipeline/filters", s.withSchema("pipeline-filters", s.withLock(s.handlePutPipelineFilters)))
+	adminMux.HandleFunc("GET /admin/es/geoip/status", s.cacheGET("geoip-status", s.handleGeoIPStatus))
+	adminMux.HandleFunc("GET /admin/verify/geoip", s.cacheGET("geoip", s.handleVerifyGeoIP))
+	adminMux.HandleFunc("GET /admin/verify/downsample", s.cacheGET("downsample", s.handleVerifyDownsample))
+	adminMux.HandleFunc("GET /admin/verify/capacity", s.cacheGET("capacity", s.handleVerifyCapacity))
+	adminMux.HandleFunc("GET /admin/verify/ingest-nodes", s.cacheGET("ingest-nodes", s.handleVerifyIngestNodes))
+	adminMux.HandleFunc("GET /admin/report", s.handleReport)
+
+	// 定时维护任务
+	adminMux.HandleFunc("GET /admin/schedules", s.handleListSchedules)
+	adminMux.HandleFunc("GET /admin/calendar", s.handleCalendar)
+	adminMux.HandleFunc("GET /admin/maintenance", s.handleGetMaintenance)
+	adminMux.HandleFunc("PUT /admin/maintenance", s.withSchema("maintenance", s.handlePutMaintenance))
+	adminMux.HandleFunc("POST /admin/schedules/{name}/run", s.handleRunSchedule)
+
+	// 变更计划与审批（受保护环境的双人规则）
+	adminMux.HandleFunc("GET /admin/plans", s.handleListPlans)
+	adminMux.HandleFunc("POST /admin/plans", s.withSchema("plan", s.handleCreatePlan))
+	adminMux.HandleFunc("GET /admin/plans/{id}", s.handleGetPlan)
+	adminMux.HandleFunc("POST /admin/plans/{id}/approve", s.withSchema("plan-approve", s.handleApprovePlan))
+	adminMux.HandleFunc("POST /admin/plans/{id}/apply", s.withLock(s.handleApplyPlan))
+
+	// 资产版本历史与回滚
+	adminMux.HandleFunc("GET /admin/assets/lint", s.handleLintAssets)
+	adminMux.HandleFunc("GET /admin/assets/ecs", s.handleECSReport)
+	adminMux.HandleFunc("GET /admin/assets/{kind}/versions", s.handleListAssetVersions)
+	adminMux.HandleFunc("GET /admin/assets/{kind}/versions/{version}", s.handleGetAssetVersion)
+	adminMux.HandleFunc("POST /admin/assets/{kind}/rollback/{version}", s.withLock(s.handleRollbackAsset))
+	adminMux.HandleFunc("POST /admin/hooks/git", s.handleGitWebhook)
+
+	// 接管集群中已存在的资源（写回资产文件并打归属标记）
+	adminMux.HandleFunc("POST /admin/import", s.withLock(s.handleImport))
+
+	// 孤儿资源回收（带归属标记但已不在配置中）
+	adminMux.HandleFunc("GET /admin/gc/preview", s.handleGCPreview)
+	adminMux.HandleFunc("GET /admin/managed", s.handleListManaged)
+	adminMux.HandleFunc("POST /admin/gc/run", s.withSchema("gc-run", s.handleGCRun))
+	adminMux.HandleFunc("GET /admin/locks", s.handleListLocks)
+
+	// 导出部署清单（docker-compose / Kubernetes）与 Terraform
+	adminMux.HandleFunc("GET /admin/export/manifests", s.handleExportManifests)
+	adminMux.HandleFunc("GET /admin/export/terraform", s.handleExportTerraform)
+
+	// Kubernetes operator 模式（LogPipeline CR）
+	adminMux.HandleFunc("GET /admin/operator", s.handleOperatorStatus)
+
+	// 多租户：/admin/t/{tenant}/...（tenant.go）
+	adminMux.HandleFunc("/admin/t/{tenant}/", s.handleTenant)
+
+	// 调试（pprof / expvar / runtime）
+	s.registerDebug(adminMux)
+
+	// 中间件按路由组声明，排在前面的在外层；每个请求只经过一条链，只记一次日志（middleware.go）
+	adminStack := func(extra ...middleware) http.Handler {
+		mws := []middleware{requestLogger(s.logger, s.httplog, s.reqlog), cors(cfg.Frontend.AllowedOrigins)}
+		mws = append(mws, extra...)
+		mws = append(mws, s.maintenanceHeader, s.tenantGate, s.freezeGate, s.bustCacheOnWrite, s.limitRequestBody, s.idempotent)
+		return chain(adminMux, mws...)
+	}
+	staticStack := []middleware{requestLogger(s.logger, nil, s.reqlog)}
+	adminHandler := adminStack()
+
+	// 开启 -admin-listen 时 /admin/* 单独监听，UI 端口上按 -ui-admin 只读或不提供
+	uiAdmin := adminHandler
+	if *flagAdminListen != "" {
+		switch *flagUIAdmin {
+		case "readonly":
+			uiAdmin = adminStack(readOnlyAdmin(*flagAdminListen))
+		case "none":
+			uiAdmin = chain(http.NotFoundHandler(), staticStack...)
+		default:
+			s.logger.Fatalf("invalid -ui-admin %q (want readonly or none)", *flagUIAdmin)
+		}
+	}
 
-	// --- 顶层：静态 + SPA 回退 + /admin 代理 ---
+	// --- 顶层：静态 + SPA 回退 + /admin ---
 	root := http.NewServeMux()
-	root.Handle("/", &spaHandler{
-		staticDir:    *flagStatic,
-		indexFile:    "index.html",
-		adminHandler: adminHandler,
-	})
+	root.Handle("/admin/", uiAdmin)
+	spa := &spaHandler{
+		staticDir: *flagStatic,
+		indexFile: "index.html",
+		basePath:  basePath,
+	}
+	root.Handle("/", chain(spa, staticStack...))
+
+	// Kubernetes 探针（不经过 CORS，不记请求日志）
+	root.HandleFunc("GET /healthz", s.handleHealthz)
+	root.HandleFunc("GET /readyz", s.handleReadyz)
 
 	// 额外：如果你的前端产物使用 /static 前缀，也可直出（非必需）
 	if _, err := os.Stat(*flagStatic); err == nil {
-		root.Handle("/static/", http.FileServer(http.Dir(*flagStatic)))
-		root.Handle("/asset-manifest.json", http.FileServer(http.Dir(*flagStatic)))
-		root.Handle("/favicon.ico", http.FileServer(http.Dir(*flagStatic)))
+		files := chain(http.HandlerFunc(spa.serveAsset), staticStack...)
+		root.Handle("/static/", files)
+		root.Handle("/asset-manifest.json", files)
+		root.Handle("/favicon.ico", files)
 	}
 
 	srv := &http.Server{
 		Addr:              *flagListen,
-		Handler:           requestLogger(s.logger, root), // 顶层也记一次日志（包含静态）
+		Handler:           s.mountBasePath(basePath, root),
 		ReadTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 10 * time.Second,
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       120 * time.Second,
 	}
+	servers := []*http.Server{srv}
+	if *flagAdminListen != "" {
+		adminRoot := http.NewServeMux()
+		adminRoot.Handle("/admin/", adminHandler)
+		adminRoot.HandleFunc("GET /healthz", s.handleHealthz)
+		adminRoot.HandleFunc("GET /readyz", s.handleReadyz)
+		servers = append(servers, &http.Server{
+			Addr:              *flagAdminListen,
+			Handler:           adminRoot,
+			ReadTimeout:       srv.ReadTimeout,
+			ReadHeaderTimeout: srv.ReadHeaderTimeout,
+			WriteTimeout:      srv.WriteTimeout,
+			IdleTimeout:       srv.IdleTimeout,
+		})
+	}
 
 	// 校验静态目录（不存在也不退出，让 API 可用）
 	if _, err := os.Stat(filepath.Join(*flagStatic, "index.html")); err != nil {
 		s.logger.Printf("warning: index.html not found in static dir: %s (err=%v)", *flagStatic, err)
 	}
 
-	// 优雅关机
+	// 启动时异步探测 ES / Connect 版本与插件，结果见 /admin/health
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		s.runCompatProbe(ctx)
+	}()
+
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	bg := newBackgroundSet()
+	bg.start("status-monitor", func() { s.runStatusMonitor(bgCtx, mustParseDuration("live.interval", cfg.Live.Interval)) })
+	bg.start("alerts", func() { s.runAlertRules(bgCtx) })
+	bg.start("scheduler", func() { s.runScheduler(bgCtx) })
+	bg.start("connect-state", func() { s.runConnectStateReconciler(bgCtx) })
+	bg.start("task-restarter", func() { s.runTaskRestarter(bgCtx) })
+	if s.operator != nil {
+		bg.start("operator", func() { s.operator.run(bgCtx) })
+	}
+	bg.start("config-watch", func() { s.runConfigWatch(bgCtx) })
+
+	grpcSrv, err := s.serveGRPC()
+	if err != nil {
+		s.logger.Fatalf("%v", err)
+	}
+
+	// 监听 socket：平滑重启时从上一个进程继承
+	inherited := inheritedFDs()
+	listeners := map[string]net.Listener{}
+	for _, hs := range servers {
+		ln, reused, err := listenInherited(hs.Addr, inherited)
+		if err != nil {
+			s.logger.Fatalf("listen %s: %v", hs.Addr, err)
+		}
+		if reused {
+			s.logger.Printf("listen %s: inherited socket from previous process", hs.Addr)
+		}
+		listeners[hs.Addr] = ln
+	}
+
+	// 优雅关机；SIGHUP 或配置来源有合法变更时同样走关机流程，结束后带着监听 socket re-exec
+	drainTimeout, jobTimeout := cfg.Shutdown.timeouts()
 	idleConnsClosed := make(chan struct{})
+	restart, handoff := false, ""
 	go func() {
 		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-		sig := <-ch
-		s.logger.Printf("signal=%s shutting down...", sig)
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		sum := &shutdownSummary{start: time.Now()}
+		select {
+		case sig := <-ch:
+			restart = sig == syscall.SIGHUP
+			sum.reason = "signal " + sig.String()
+			s.logger.Printf("signal=%s restart=%v shutting down...", sig, restart)
+		case <-s.conf.restart:
+			sum.reason = "config changed"
+			s.logger.Printf("config changed, restarting...")
+			restart = true
+		}
+		// readyz 先转 503，继续服务直到进行中的 job / 下发锁结束
+		s.probes.draining.Store(true)
+		sum.waitedJobs, sum.waitedLocks = s.waitIdle(context.Background(), jobTimeout)
+		if sum.waitedJobs > 0 || sum.waitedLocks > 0 {
+			s.logger.Printf("shutdown: job_timeout=%s exceeded, running_jobs=%d held_locks=%d", jobTimeout, sum.waitedJobs, sum.waitedLocks)
+		}
+		// 先停后台子系统与 job，HTTP 仍可查询状态
+		stopBackground()
+		sum.bgTimedOut = bg.wait(drainTimeout)
+		sum.jobsCancelled = s.interruptJobs(drainTimeout)
+		if restart {
+			fds, err := handoffListeners(listeners)
+			if err != nil {
+				s.logger.Printf("shutdown: socket handoff failed, new process will re-listen: %v", err)
+			}
+			handoff = fds
+		}
+		// SSE 请求日志流是普通 HTTP 请求，先断开，否则 Shutdown 会一直等待
+		s.httplog.closeAll()
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 		defer cancel()
-		if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.logger.Printf("graceful shutdown error: %v", err)
+		for _, hs := range servers {
+			if err := hs.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Printf("graceful shutdown error: addr=%s err=%v", hs.Addr, err)
+				sum.httpErrors = append(sum.httpErrors, hs.Addr+": "+err.Error())
+			}
 		}
+		if grpcSrv != nil {
+			grpcSrv.GracefulStop()
+		}
+		s.ws.closeAll()
+		s.closeKafka()
+		sum.locksHeld = s.locks.heldCount()
+		s.logShutdownSummary(sum)
 		close(idleConnsClosed)
 	}()
 
-	s.logger.Printf("admin server listening on %s (static=%s)", *flagListen, *flagStatic)
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if len(servers) > 1 {
+		go func() {
+			s.logger.Printf("admin API listening on %s (ui listener admin=%s)", *flagAdminListen, *flagUIAdmin)
+			if err := servers[1].Serve(listeners[servers[1].Addr]); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Fatalf("admin server error: %v", err)
+			}
+		}()
+	}
+	s.logger.Printf("admin server listening on %s (static=%s base_path=%s)", *flagListen, *flagStatic, basePath)
+	if err := srv.Serve(listeners[srv.Addr]); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.logger.Fatalf("server error: %v", err)
 	}
 
 	<-idleConnsClosed
 	s.logger.Printf("server stopped")
+	if restart {
+		s.reexec(handoff)
+	}
 }
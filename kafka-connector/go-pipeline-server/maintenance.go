@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 维护模式 **************/
+
+// PUT /admin/maintenance {"enabled": true, "reason": "ES 8.15 升级", "duration": "2h"} 开启维护模式，期间暂停后台自动化：
+//   - connector 期望状态的自动纠正（connectstate.go）与 LogPipeline operator 的 reconcile
+//   - FAILED task 自动重启（restarter.go）
+//   - 定时任务（跳过本次运行，calendar 与 schedules 中标出原因）
+//   - 告警规则评估与异步通知（drift、connector_failed 等）
+// 手动调用的 /admin 接口不受影响。duration 到期自动结束，也可 PUT {"enabled": false} 提前结束。
+// 状态存于 assets.dir/maintenance.json，重启后保持；/admin/status 与 /admin/ws 的状态推送带 maintenance 字段，
+// /admin 响应带 X-Maintenance: on 头，前端据此显示维护横幅（原因等详情见 GET /admin/maintenance）。
+
+const maintenanceFile = "maintenance.json"
+
+type maintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	By      string     `json:"by,omitempty"`
+	Since   time.Time  `json:"since,omitzero"`
+	Until   *time.Time `json:"until,omitempty"` // 为空时需手动结束
+}
+
+func (m maintenanceState) message() string {
+	msg := fmt.Sprintf("maintenance mode since %s (%s)", m.Since.Format(time.RFC3339), m.Reason)
+	if m.Until != nil {
+		msg += " until " + m.Until.Format(time.RFC3339)
+	}
+	return msg
+}
+
+type maintenanceStore struct {
+	mu    sync.Mutex
+	file  string
+	state maintenanceState
+}
+
+func newMaintenanceStore(cfg AssetsConfig) *maintenanceStore {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultAssetsDir
+	}
+	return &maintenanceStore{file: filepath.Join(dir, maintenanceFile)}
+}
+
+func (st *maintenanceStore) load() error {
+	b, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := json.Unmarshal(b, &st.state); err != nil {
+		return fmt.Errorf("decode %s: %w", st.file, err)
+	}
+	return nil
+}
+
+func (st *maintenanceStore) saveLocked() error {
+	b, err := json.MarshalIndent(st.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), 0o755); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+// 当前是否处于维护模式；到期的在这里结束
+func (st *maintenanceStore) active(now time.Time) (maintenanceState, bool) {
+	if st == nil {
+		return maintenanceState{}, false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.state.Enabled && st.state.Until != nil && !now.Before(*st.state.Until) {
+		st.state = maintenanceState{}
+		_ = st.saveLocked()
+	}
+	return st.state, st.state.Enabled
+}
+
+func (st *maintenanceStore) set(m maintenanceState) (maintenanceState, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	prev := st.state
+	st.state = m
+	if err := st.saveLocked(); err != nil {
+		st.state = prev
+		return prev, err
+	}
+	return prev, nil
+}
+
+// liveStatus 用：不在维护中为 nil
+func (s *Server) maintenanceStatus() *maintenanceState {
+	m, on := s.maintenance.active(time.Now())
+	if !on {
+		return nil
+	}
+	return &m
+}
+
+// 后台任务在每轮开始前调用；维护中返回 true 并记日志
+func (s *Server) inMaintenance(what string) bool {
+	m, on := s.maintenance.active(time.Now())
+	if on {
+		s.logger.Printf("%s paused: %s", what, m.message())
+	}
+	return on
+}
+
+// 给 /admin 响应加 X-Maintenance 头，前端据此显示横幅
+func (s *Server) maintenanceHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, on := s.maintenance.active(time.Now()); on {
+			w.Header().Set("X-Maintenance", "on")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type maintenanceRequest struct {
+	Enabled  bool   `json:"enabled"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"` // 如 2h；为空时需手动结束
+}
+
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	m, _ := s.maintenance.active(time.Now())
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) handlePutMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	now := time.Now().UTC()
+	m := maintenanceState{}
+	if req.Enabled {
+		if strings.TrimSpace(req.Reason) == "" {
+			writeJSON(w, 400, map[string]string{"error": "reason is required when enabling maintenance mode"})
+			return
+		}
+		m = maintenanceState{Enabled: true, Reason: strings.TrimSpace(req.Reason), By: operatorIdentity(r), Since: now}
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d <= 0 {
+				writeJSON(w, 400, map[string]string{"error": "duration must be a positive duration such as 2h"})
+				return
+			}
+			until := now.Add(d)
+			m.Until = &until
+		}
+	}
+	prev, err := s.maintenance.set(m)
+	if err != nil {
+		writeJSON(w, 500, errorBody("maintenance", err))
+		return
+	}
+	if m.Enabled {
+		s.logger.Printf("step=maintenance enabled by=%s %s", m.By, m.message())
+	} else if prev.Enabled {
+		s.logger.Printf("step=maintenance disabled by=%s (was: %s)", operatorIdentity(r), prev.message())
+	}
+	b, _ := json.Marshal(wsMessage{Type: "maintenance", Data: m})
+	s.ws.broadcast(b, false)
+	writeJSON(w, http.StatusOK, m)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/************** 中间件链 **************/
+
+// 中间件按路由组声明（见 main 中的 adminStack / staticStack），每个请求只经过一条链、只记一次日志：
+//   - /admin/*：请求日志（发布到 /admin/logs/http）→ CORS → [UI 端口只读] → 维护模式头 → 鉴权（tenantGate）
+//     → 冻结窗口 → 写后清缓存 → 请求体大小 → 幂等
+//   - 静态资源与 SPA 回退：请求日志（按 request_log 排除 / 采样）
+//   - /healthz、/readyz：无中间件
+// 请求日志在最外层；内层可用 logField 给该请求的日志行附加字段（如 tenant、idempotent_replay），
+// 这样拒绝、重放等在内层决定的信息也记在同一行里。
+
+type middleware func(http.Handler) http.Handler
+
+// chain(h, a, b, c) = a(b(c(h)))：排在前面的在外层；nil 跳过
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] != nil {
+			h = mws[i](h)
+		}
+	}
+	return h
+}
+
+type logFieldsKey struct{}
+
+// 请求日志行的附加字段，由 requestLogger 放入 context
+type logFields struct {
+	mu sync.Mutex
+	kv map[string]string
+}
+
+// 给当前请求的日志附加字段；不在 requestLogger 之内时忽略
+func logField(r *http.Request, k, v string) {
+	f, _ := r.Context().Value(logFieldsKey{}).(*logFields)
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.kv == nil {
+		f.kv = map[string]string{}
+	}
+	f.kv[k] = v
+}
+
+func withLogFields(r *http.Request) (*http.Request, *logFields) {
+	f := &logFields{}
+	return r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, f)), f
+}
+
+func (f *logFields) snapshot() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.kv) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(f.kv))
+	for k, v := range f.kv {
+		out[k] = v
+	}
+	return out
+}
+
+// 按 key 排序的 " k=v" 串，追加在日志行末尾
+func formatLogFields(kv map[string]string) string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(" " + k + "=" + quoteIfNeeded(kv[k]))
+	}
+	return b.String()
+}
+
+func quoteIfNeeded(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"=") {
+		return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+	}
+	return v
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** MirrorMaker 2 topic 复制（Connect 上的 Mirror*Connector） **************/
+
+// type: mirrormaker2 的 sink 在 Connect 集群上注册 MirrorMaker 2 connector，把日志 topic 复制到远端 Kafka。
+// 与 s3 sink 一样由配置渲染 connector 文档，注册/状态/暂停/恢复/删除走同一套 /admin/sinks/{name} 接口；
+// 一条 sink 对应一个 connector，需要 offset 同步或心跳时另配 connector: checkpoint / heartbeat 的 sink。
+// 记录经 producer.override.bootstrap.servers 写到远端集群，worker 需配置 connector.client.config.override.policy=All。
+// GET /admin/sinks/{name}/replication：source connector 已复制到的源 offset（Connect 3.5+ 的 /offsets）
+// 与源 topic 末尾 offset 之差，即复制延迟；源集群为 kafka.brokers 时才能计算。
+
+const (
+	sinkTypeMirrorMaker = "mirrormaker2"
+
+	mm2SourceClass     = "org.apache.kafka.connect.mirror.MirrorSourceConnector"
+	mm2CheckpointClass = "org.apache.kafka.connect.mirror.MirrorCheckpointConnector"
+	mm2HeartbeatClass  = "org.apache.kafka.connect.mirror.MirrorHeartbeatConnector"
+	mm2IdentityPolicy  = "org.apache.kafka.connect.mirror.IdentityReplicationPolicy"
+)
+
+type MirrorMakerConfig struct {
+	Connector         string            `yaml:"connector"`                // source（默认）| checkpoint | heartbeat
+	SourceAlias       string            `yaml:"source_alias"`             // 默认 source；远端 topic 名为 <source_alias>.<topic>
+	TargetAlias       string            `yaml:"target_alias"`             // 默认 target
+	SourceBootstrap   string            `yaml:"source_bootstrap_servers"` // 默认 kafka.brokers
+	TargetBootstrap   string            `yaml:"target_bootstrap_servers"` // 远端 Kafka，必填
+	KeepTopicName     bool              `yaml:"keep_topic_name"`          // IdentityReplicationPolicy：远端 topic 不加前缀（Kafka 3.0+）
+	ReplicationFactor int               `yaml:"replication_factor"`       // 远端 topic 的副本数，0 用 MM2 默认
+	SyncGroupOffsets  bool              `yaml:"sync_group_offsets"`       // checkpoint：把消费组 offset 同步到远端
+	TasksMax          int               `yaml:"tasks_max"`
+	Extra             map[string]string `yaml:"extra"` // 原样透传，如 target.cluster.security.protocol / producer.override.sasl.*
+}
+
+func (c MirrorMakerConfig) class() (string, error) {
+	switch c.Connector {
+	case "", "source":
+		return mm2SourceClass, nil
+	case "checkpoint":
+		return mm2CheckpointClass, nil
+	case "heartbeat":
+		return mm2HeartbeatClass, nil
+	}
+	return "", fmt.Errorf("unknown mirrormaker.connector %q (source | checkpoint | heartbeat)", c.Connector)
+}
+
+func (c MirrorMakerConfig) sourceAlias() string {
+	if c.SourceAlias != "" {
+		return c.SourceAlias
+	}
+	return "source"
+}
+
+func (s *Server) mm2SourceBootstrap(sc SinkConfig) string {
+	if b := sc.MirrorMaker.SourceBootstrap; b != "" {
+		return b
+	}
+	return strings.Join(s.cfg.Kafka.Brokers, ",")
+}
+
+func (s *Server) mm2Topics(sc SinkConfig) []string {
+	if len(sc.Topics) > 0 {
+		return sc.Topics
+	}
+	if s.cfg.Kafka.Topic != "" {
+		return []string{s.cfg.Kafka.Topic}
+	}
+	return nil
+}
+
+func (s *Server) renderMirrorMakerConnector(sc SinkConfig) ([]byte, error) {
+	c := sc.MirrorMaker
+	class, err := c.class()
+	if err != nil {
+		return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+	}
+	source := s.mm2SourceBootstrap(sc)
+	topics := s.mm2Topics(sc)
+	switch {
+	case c.TargetBootstrap == "":
+		return nil, fmt.Errorf("sink %s: mirrormaker.target_bootstrap_servers is required", sc.Name)
+	case source == "":
+		return nil, fmt.Errorf("sink %s: mirrormaker.source_bootstrap_servers or kafka.brokers is required", sc.Name)
+	case class == mm2SourceClass && len(topics) == 0:
+		return nil, fmt.Errorf("sink %s: topics or kafka.topic is required", sc.Name)
+	}
+	target := c.TargetAlias
+	if target == "" {
+		target = "target"
+	}
+	tasks := c.TasksMax
+	if tasks <= 0 {
+		tasks = 1
+	}
+	cfg := map[string]string{
+		"connector.class":                     class,
+		"tasks.max":                           strconv.Itoa(tasks),
+		"source.cluster.alias":                c.sourceAlias(),
+		"target.cluster.alias":                target,
+		"source.cluster.bootstrap.servers":    source,
+		"target.cluster.bootstrap.servers":    c.TargetBootstrap,
+		"producer.override.bootstrap.servers": c.TargetBootstrap,
+		"key.converter":                       "org.apache.kafka.connect.converters.ByteArrayConverter",
+		"value.converter":                     "org.apache.kafka.connect.converters.ByteArrayConverter",
+	}
+	switch class {
+	case mm2SourceClass:
+		cfg["topics"] = strings.Join(topics, ",")
+		cfg["sync.topic.acls.enabled"] = "false"
+		if c.ReplicationFactor > 0 {
+			cfg["replication.factor"] = strconv.Itoa(c.ReplicationFactor)
+		}
+	case mm2CheckpointClass:
+		cfg["groups"] = ".*"
+		cfg["sync.group.offsets.enabled"] = strconv.FormatBool(c.SyncGroupOffsets)
+	}
+	if c.KeepTopicName {
+		cfg["replication.policy.class"] = mm2IdentityPolicy
+	}
+	for k, v := range c.Extra {
+		cfg[k] = v
+	}
+	return json.Marshal(map[string]any{"name": sc.Name, "config": cfg})
+}
+
+type mm2PartitionLag struct {
+	Topic      string `json:"topic"`
+	Partition  int32  `json:"partition"`
+	Replicated int64  `json:"replicated_offset"` // 已复制的最后一条记录的源 offset，-1 表示尚未复制
+	End        int64  `json:"end_offset,omitempty"`
+	Lag        int64  `json:"lag,omitempty"`
+}
+
+// source connector 保存的源 offset：partition {cluster, topic, partition} -> offset {offset}
+func (c *connectSink) sourceOffsets(ctx context.Context) (map[string]map[int32]int64, error) {
+	res, err := c.get(ctx, "get-offsets", "/offsets")
+	if err != nil {
+		return nil, err
+	}
+	if res.Code >= 300 {
+		return nil, fmt.Errorf("get offsets of %s: %s (needs Kafka Connect 3.5+)", c.name, res.Status)
+	}
+	var doc struct {
+		Offsets []struct {
+			Partition struct {
+				Topic     string `json:"topic"`
+				Partition int32  `json:"partition"`
+			} `json:"partition"`
+			Offset struct {
+				Offset int64 `json:"offset"`
+			} `json:"offset"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(res.Body, &doc); err != nil {
+		return nil, fmt.Errorf("decode offsets of %s: %w", c.name, err)
+	}
+	out := map[string]map[int32]int64{}
+	for _, o := range doc.Offsets {
+		if out[o.Partition.Topic] == nil {
+			out[o.Partition.Topic] = map[int32]int64{}
+		}
+		out[o.Partition.Topic][o.Partition.Partition] = o.Offset.Offset
+	}
+	return out, nil
+}
+
+func (s *Server) handleSinkReplication(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	sc, ok := s.findSinkConfig(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("sink %q not configured", name)})
+		return
+	}
+	if sc.Type != sinkTypeMirrorMaker {
+		writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("sink %q (type %s) is not a mirrormaker2 sink", name, sc.Type)})
+		return
+	}
+	if class, _ := sc.MirrorMaker.class(); class != mm2SourceClass {
+		writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("sink %q is a %s connector; replication lag is tracked on the source connector", name, sc.MirrorMaker.Connector)})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	c := p.(*connectSink)
+	out := map[string]any{"name": name, "source_alias": sc.MirrorMaker.sourceAlias(), "target": sc.MirrorMaker.TargetBootstrap}
+	act := c.actual(ctx)
+	out["state"], out["tasks"] = act.State, act.Tasks
+
+	offsets, err := c.sourceOffsets(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("sink-replication", err))
+		return
+	}
+	topics := s.mm2Topics(sc)
+	var parts []mm2PartitionLag
+	for _, t := range topics {
+		for p, off := range offsets[t] {
+			parts = append(parts, mm2PartitionLag{Topic: t, Partition: p, Replicated: off})
+		}
+	}
+
+	// 源集群就是 kafka.brokers 时，用末尾 offset 计算延迟
+	local := len(s.cfg.Kafka.Brokers) > 0 && s.mm2SourceBootstrap(sc) == strings.Join(s.cfg.Kafka.Brokers, ",")
+	if !local {
+		out["note"] = "source cluster is not kafka.brokers; lag is not computed"
+	} else if adm, err := s.kafkaAdmin(); err != nil {
+		out["lag_error"] = err.Error()
+	} else if release, err := s.limits.acquire(ctx, "kafka"); err != nil {
+		out["lag_error"] = err.Error()
+	} else {
+		ends, err := adm.ListEndOffsets(ctx, topics...)
+		release()
+		if err == nil {
+			err = ends.Error()
+		}
+		if err != nil {
+			out["lag_error"] = err.Error()
+		} else {
+			var total int64
+			parts = parts[:0]
+			for _, t := range topics {
+				for p, eo := range ends[t] {
+					pl := mm2PartitionLag{Topic: t, Partition: p, Replicated: -1, End: eo.Offset}
+					if off, ok := offsets[t][p]; ok {
+						pl.Replicated = off
+					}
+					pl.Lag = max(pl.End-(pl.Replicated+1), 0)
+					total += pl.Lag
+					parts = append(parts, pl)
+				}
+			}
+			out["total_lag"] = total
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].Topic != parts[j].Topic {
+			return parts[i].Topic < parts[j].Topic
+		}
+		return parts[i].Partition < parts[j].Partition
+	})
+	if parts == nil {
+		parts = []mm2PartitionLag{}
+	}
+	out["partitions"] = parts
+	if missing := slices.DeleteFunc(slices.Clone(topics), func(t string) bool { return len(offsets[t]) > 0 }); len(missing) > 0 {
+		out["not_started"] = missing
+	}
+	writeJSON(w, http.StatusOK, out)
+}
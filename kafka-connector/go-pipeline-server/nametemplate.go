@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/************** 名字模板：es.names.* / connect.names.sink **************/
+
+// 名字中可以写占位符，解析配置时统一展开，模板 index_patterns、创建 data stream 与 sink 配置用的是同一个结果：
+//
+//	{service}             es.names.vars.service，未定义则取同名环境变量（原样或大写，如 SERVICE）
+//	{now/d}               UTC 日期，按单位截断：y -> yyyy，M -> yyyy.MM，d -> yyyy.MM.dd，H -> yyyy.MM.dd.HH
+//	{now/M{yyyy-MM}}      指定格式（yyyy / yy / MM / dd / HH）
+//
+// 如 data_stream: "logs-{service}-{env}-{now/M}" -> logs-app-prod-2026.10。
+// 日期在进程启动（或配置热加载重启）时取值，滚动到新的 data stream 需要重启。
+// data_stream 含占位符时：模板的 index_patterns 改为把日期部分换成 * 的模式（logs-app-prod-*），
+// 主 sink connector 的 topic.to.external.resource.mapping / ingest.pipeline.name 改为展开后的名字，
+// 避免文件里写死的名字与配置不一致。
+
+var (
+	namePlaceholderRe = regexp.MustCompile(`\{(now(?:/([yMdH]))?(?:\{([^{}]*)\})?|[A-Za-z_][A-Za-z0-9_]*)\}`)
+	nameDateTokens    = strings.NewReplacer("yyyy", "2006", "yy", "06", "MM", "01", "dd", "02", "HH", "15")
+	nameDateDefaults  = map[string]string{"y": "yyyy", "M": "yyyy.MM", "d": "yyyy.MM.dd", "H": "yyyy.MM.dd.HH", "": "yyyy.MM.dd"}
+)
+
+// 展开后的名字；datePattern 为 true 时日期部分替换为 *（用于 index_patterns）
+func renderNameTemplate(tpl string, vars map[string]string, now time.Time, datePattern bool) (string, error) {
+	var firstErr error
+	out := namePlaceholderRe.ReplaceAllStringFunc(tpl, func(m string) string {
+		sub := namePlaceholderRe.FindStringSubmatch(m)
+		if strings.HasPrefix(sub[1], "now") {
+			if datePattern {
+				return "*"
+			}
+			format := sub[3]
+			if format == "" {
+				format = nameDateDefaults[sub[2]]
+			}
+			return now.Format(nameDateTokens.Replace(format))
+		}
+		if v, ok := vars[sub[1]]; ok {
+			return v
+		}
+		for _, k := range []string{sub[1], strings.ToUpper(sub[1])} {
+			if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+				return v
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: {%s} is not defined in es.names.vars or the environment", tpl, sub[1])
+		}
+		return m
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if strings.ContainsAny(out, "{}") {
+		return "", fmt.Errorf("%s: unbalanced or unsupported placeholder", tpl)
+	}
+	return out, nil
+}
+
+func isNameTemplate(s string) bool { return namePlaceholderRe.MatchString(s) }
+
+// ES 索引 / data stream 名字规则
+func validESName(name string) error {
+	switch {
+	case name == "":
+		return nil
+	case strings.ToLower(name) != name:
+		return fmt.Errorf("%q must be lowercase", name)
+	case strings.ContainsAny(name, `\/*?"<>| ,#:`):
+		return fmt.Errorf(`%q must not contain \ / * ? " < > | space , # :`, name)
+	case strings.HasPrefix(name, "-") || strings.HasPrefix(name, "_") || strings.HasPrefix(name, "+"):
+		return fmt.Errorf("%q must not start with - _ +", name)
+	}
+	return nil
+}
+
+// 解析配置时调用：展开 es.names.* 与 connect.names.sink，记下 data stream 的 index pattern
+func (cfg *Config) renderNames(now time.Time) error {
+	n := &cfg.ES.Names
+	raw := n.DataStream
+	fields := []struct {
+		key   string
+		v     *string
+		index bool // 需要符合 ES 索引名规则
+	}{
+		{"es.names.data_stream", &n.DataStream, true},
+		{"es.names.ilm_policy", &n.ILMPolicy, false},
+		{"es.names.index_template", &n.IndexTemplate, false},
+		{"es.names.pipeline", &n.Pipeline, false},
+		{"connect.names.sink", &cfg.Connect.Names.Sink, false},
+	}
+	for _, f := range fields {
+		if !isNameTemplate(*f.v) {
+			continue
+		}
+		out, err := renderNameTemplate(*f.v, n.Vars, now, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		if f.index {
+			if err := validESName(out); err != nil {
+				return fmt.Errorf("%s renders to %w", f.key, err)
+			}
+		}
+		*f.v = out
+	}
+	if isNameTemplate(raw) {
+		n.templated = true
+		n.indexPattern, _ = renderNameTemplate(raw, n.Vars, now, true)
+		if !strings.HasSuffix(n.indexPattern, "*") {
+			n.indexPattern += "*"
+		}
+	}
+	return nil
+}
+
+// 模板 index_patterns 应覆盖的模式
+func (s *Server) dataStreamIndexPattern() string {
+	if n := s.cfg.ES.Names; n.indexPattern != "" {
+		return n.indexPattern
+	}
+	return s.cfg.ES.Names.DataStream + "*"
+}
+
+// 下发索引模板前：data_stream 为模板时改写 index_patterns，否则要求现有 patterns 能匹配 data stream
+func (s *Server) alignTemplatePatterns(b []byte) ([]byte, error) {
+	ds := s.cfg.ES.Names.DataStream
+	if ds == "" {
+		return b, nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse index template: %w", err)
+	}
+	if s.cfg.ES.Names.templated {
+		doc["index_patterns"] = []string{s.dataStreamIndexPattern()}
+		return json.Marshal(doc)
+	}
+	pats, _ := doc["index_patterns"].([]any)
+	if len(pats) == 0 {
+		return b, nil
+	}
+	for _, p := range pats {
+		if ok, _ := path.Match(fmt.Sprint(p), ds); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("index template index_patterns %v do not match data stream %s (es.names.data_stream)", pats, ds)
+}
+
+// 主 sink connector：data_stream 为模板时把映射与 ingest pipeline 改成展开后的名字
+func (s *Server) alignSinkNames(b []byte) ([]byte, error) {
+	names := s.cfg.ES.Names
+	if !names.templated {
+		return b, nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse connector: %w", err)
+	}
+	cfg, _ := doc["config"].(map[string]any)
+	if cfg == nil {
+		return b, nil
+	}
+	if m, ok := cfg["topic.to.external.resource.mapping"].(string); ok && m != "" {
+		var out []string
+		for _, pair := range strings.Split(m, ",") {
+			topic, _, _ := strings.Cut(strings.TrimSpace(pair), ":")
+			out = append(out, topic+":"+names.DataStream)
+		}
+		cfg["topic.to.external.resource.mapping"] = strings.Join(out, ",")
+	}
+	if _, ok := cfg["ingest.pipeline.name"]; ok && names.Pipeline != "" {
+		cfg["ingest.pipeline.name"] = names.Pipeline
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+/************** 通知：Slack / 钉钉 / 通用 webhook / 邮件 **************/
+
+const (
+	notifySlack    = "slack"
+	notifyDingTalk = "dingtalk"
+	notifyWebhook  = "webhook"
+)
+
+// 事件类型
+const (
+	eventDrift           = "drift"            // 实际配置与期望不一致
+	eventConnectorFailed = "connector_failed" // connector 进入 FAILED
+	eventTaskFailed      = "task_failed"      // connector task 进入 FAILED
+	eventRestartGaveUp   = "restart_gave_up"  // 自动重启次数用尽仍 FAILED
+	eventJobFailed       = "job_failed"       // 后台任务失败
+	eventAlertFiring     = "alert_firing"     // 告警规则触发
+	eventAlertResolved   = "alert_resolved"   // 告警规则恢复
+	eventReport          = "report"           // 定时状态报告（只发往显式订阅或任务指定的目标）
+	eventTest            = "test"
+)
+
+type NotificationTarget struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"` // slack | dingtalk | webhook | email
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"` // 钉钉加签密钥
+	Events []string `yaml:"events"` // 订阅的事件，空为全部
+
+	SMTP SMTPConfig `yaml:"smtp"` // type=email
+}
+
+type NotificationsConfig struct {
+	Targets []NotificationTarget `yaml:"targets"`
+}
+
+type notification struct {
+	Event    string         `json:"event"`
+	Severity string         `json:"severity"` // info | warning | critical
+	Title    string         `json:"title"`
+	Text     string         `json:"text"`
+	Fields   map[string]any `json:"fields,omitempty"`
+	At       time.Time      `json:"at"`
+	// 附件：webhook 随 JSON 发送，邮件作为 MIME 附件；Slack / 钉钉只发正文
+	Attachments []notificationAttachment `json:"attachments,omitempty"`
+
+	targets []string // 非空时只发往这些目标（按 name），忽略事件订阅
+}
+
+type notificationAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+func (n notification) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**[%s] %s**\n\n%s", strings.ToUpper(n.Severity), n.Title, n.Text)
+	keys := make([]string, 0, len(n.Fields))
+	for k := range n.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n- %s: %v", k, n.Fields[k])
+	}
+	return b.String()
+}
+
+func (t NotificationTarget) wants(event string) bool {
+	return event == eventTest || len(t.Events) == 0 || slices.Contains(t.Events, event)
+}
+
+// 按目标类型渲染请求地址与 body
+func (t NotificationTarget) render(n notification) (string, []byte, error) {
+	switch strings.ToLower(t.Type) {
+	case notifySlack:
+		b, err := json.Marshal(map[string]any{"text": strings.ReplaceAll(n.markdown(), "**", "*")})
+		return t.URL, b, err
+	case notifyDingTalk:
+		u := t.URL
+		if t.Secret != "" {
+			// 加签：timestamp + "\n" + secret 做 HmacSHA256 后 base64
+			ts := fmt.Sprint(time.Now().UnixMilli())
+			mac := hmac.New(sha256.New, []byte(t.Secret))
+			mac.Write([]byte(ts + "\n" + t.Secret))
+			sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			u += fmt.Sprintf("&timestamp=%s&sign=%s", ts, sign)
+		}
+		b, err := json.Marshal(map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"title": n.Title, "text": n.markdown()},
+		})
+		return u, b, err
+	case notifyWebhook, "":
+		b, err := json.Marshal(n)
+		return t.URL, b, err
+	default:
+		return "", nil, fmt.Errorf("unknown notification target type %q", t.Type)
+	}
+}
+
+func (s *Server) sendNotification(ctx context.Context, t NotificationTarget, n notification) error {
+	if strings.EqualFold(t.Type, notifyEmail) {
+		return s.sendEmail(ctx, t, n)
+	}
+	u, body, err := t.render(n)
+	if err != nil {
+		return err
+	}
+	resp, respBody, err := s.doRequest(ctx, http.MethodPost, u, body, "notify", "application/json", nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", t.Name, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// 异步投递到所有订阅了该事件的目标
+func (s *Server) notify(n notification) {
+	if n.At.IsZero() {
+		n.At = time.Now()
+	}
+	if m, on := s.maintenance.active(n.At); on {
+		s.logger.Printf("notify event=%s suppressed: %s", n.Event, m.message())
+		return
+	}
+	for _, t := range s.cfg.Notifications.Targets {
+		if len(n.targets) > 0 && !slices.Contains(n.targets, t.Name) {
+			continue
+		}
+		if len(n.targets) == 0 && !t.wants(n.Event) {
+			continue
+		}
+		go func(t NotificationTarget) {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := s.sendNotification(ctx, t, n); err != nil {
+				s.logger.Printf("notify target=%s event=%s err=%v", t.Name, n.Event, err)
+				return
+			}
+			s.logger.Printf("notify target=%s event=%s sent", t.Name, n.Event)
+		}(t)
+	}
+}
+
+func (s *Server) notificationsEnabled() bool { return len(s.cfg.Notifications.Targets) > 0 }
+
+// 状态迁移事件中需要通知的部分
+func notificationForEvent(ev statusEvent) (notification, bool) {
+	switch {
+	case ev.Kind == "sink_state" && ev.To == "FAILED":
+		return notification{Event: eventConnectorFailed, Severity: "critical", At: ev.At,
+			Title: "Connector " + ev.Target + " FAILED", Text: ev.Detail,
+			Fields: map[string]any{"connector": ev.Target, "from": ev.From}}, true
+	case ev.Kind == "task_state" && ev.To == "FAILED":
+		return notification{Event: eventTaskFailed, Severity: "critical", At: ev.At,
+			Title: "Connector task " + ev.Target + " FAILED", Text: ev.Detail,
+			Fields: map[string]any{"task": ev.Target, "from": ev.From}}, true
+	}
+	return notification{}, false
+}
+
+func (s *Server) notifyJobFailed(j *Job, err error) {
+	s.notify(notification{Event: eventJobFailed, Severity: "warning",
+		Title: "Job " + j.Kind + " failed", Text: err.Error(),
+		Fields: map[string]any{"job_id": j.ID, "kind": j.Kind}})
+}
+
+// 供期望状态比对（reconcile）发现差异时调用
+func (s *Server) notifyDrift(resource string, diffs []ensureDiff) {
+	fields := map[string]any{"resource": resource}
+	for _, d := range diffs {
+		fields[d.Field] = fmt.Sprintf("expected=%v actual=%v", d.Expected, d.Actual)
+	}
+	s.notify(notification{Event: eventDrift, Severity: "warning",
+		Title: "Configuration drift on " + resource, Text: fmt.Sprintf("%d field(s) differ from the desired state", len(diffs)),
+		Fields: fields})
+}
+
+func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request) {
+	if !s.notificationsEnabled() {
+		writeJSON(w, 400, map[string]string{"error": "notifications.targets is empty"})
+		return
+	}
+	n := notification{Event: eventTest, Severity: "info", At: time.Now(),
+		Title: "log-pipeline test notification", Text: "If you can read this, the target is configured correctly."}
+	results := map[string]string{}
+	for _, t := range s.cfg.Notifications.Targets {
+		if err := s.sendNotification(r.Context(), t, n); err != nil {
+			results[t.Name] = err.Error()
+		} else {
+			results[t.Name] = "ok"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
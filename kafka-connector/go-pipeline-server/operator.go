@@ -0,0 +1,814 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** Kubernetes operator 模式：LogPipeline CR 对账 **************/
+
+// operator.enabled 时 list/watch LogPipeline 自定义资源（CRD 见 deploy/logpipeline-crd.yaml）。每个 CR 是一条独立的
+// pipeline：以 es.files.ilm / es.files.template 与主 sink 文件为模板，按 spec 生成 ILM 策略（保留期）、索引模板、
+// data stream 与 sink connector 并下发，结果写回 CR 的 status。ingest pipeline 沿用 es.names.pipeline（全局共享）。
+// 删除 CR 时由 finalizer 先删 connector；deletionPolicy=Delete 时连同 data stream、模板、策略一起删除。
+
+const (
+	lpGroup     = "log-pipeline.io"
+	lpVersion   = "v1alpha1"
+	lpPlural    = "logpipelines"
+	lpFinalizer = "log-pipeline.io/cleanup"
+
+	lpPhaseReady    = "Ready"
+	lpPhaseError    = "Error"
+	lpPhaseDeleting = "Deleting"
+
+	serviceAccountDir    = "/var/run/secrets/kubernetes.io/serviceaccount"
+	defaultOperatorSync  = 5 * time.Minute
+	operatorRetryBackoff = 30 * time.Second
+)
+
+type OperatorConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Namespace string `yaml:"namespace"`  // 只处理该 namespace 的 CR，空为全部（需 ClusterRole）
+	Resync    string `yaml:"resync"`     // 定期全量对账、纠正 ES/Connect 侧漂移，默认 5m
+	APIServer string `yaml:"api_server"` // 默认 in-cluster；本地调试可填 kubectl proxy 的 http://127.0.0.1:8001
+	TokenFile string `yaml:"token_file"` // 默认 service account token
+	CAFile    string `yaml:"ca_file"`    // 默认 service account ca.crt
+}
+
+type logPipelineSpec struct {
+	DataStream string `json:"dataStream"`
+	Retention  string `json:"retention,omitempty"` // ILM delete 阶段的 min_age，如 14d；空则沿用模板文件
+	Topic      string `json:"topic"`
+	Sink       struct {
+		TasksMax int               `json:"tasksMax,omitempty"`
+		Config   map[string]string `json:"config,omitempty"` // 覆盖/追加 connector config
+	} `json:"sink"`
+	DeletionPolicy string `json:"deletionPolicy,omitempty"` // Retain（默认）| Delete
+}
+
+type lpCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+type lpResources struct {
+	ILMPolicy     string `json:"ilmPolicy"`
+	IndexTemplate string `json:"indexTemplate"`
+	DataStream    string `json:"dataStream"`
+	Connector     string `json:"connector"`
+}
+
+type logPipelineStatus struct {
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+	Phase              string            `json:"phase,omitempty"`
+	Resources          *lpResources      `json:"resources,omitempty"`
+	Results            map[string]string `json:"results,omitempty"` // 各资源本次对账结果：created / updated / unchanged
+	Conditions         []lpCondition     `json:"conditions,omitempty"`
+	LastReconciled     string            `json:"lastReconciled,omitempty"`
+}
+
+type logPipeline struct {
+	Metadata struct {
+		Name              string   `json:"name"`
+		Namespace         string   `json:"namespace"`
+		Generation        int64    `json:"generation"`
+		ResourceVersion   string   `json:"resourceVersion"`
+		DeletionTimestamp *string  `json:"deletionTimestamp,omitempty"`
+		Finalizers        []string `json:"finalizers,omitempty"`
+	} `json:"metadata"`
+	Spec   logPipelineSpec   `json:"spec"`
+	Status logPipelineStatus `json:"status"`
+}
+
+func (lp *logPipeline) key() string { return lp.Metadata.Namespace + "/" + lp.Metadata.Name }
+
+// CR 对应的资源名
+func (lp *logPipeline) resources() lpResources {
+	ds := lp.Spec.DataStream
+	return lpResources{
+		ILMPolicy:     ds + "-ilm",
+		IndexTemplate: ds + "-template",
+		DataStream:    ds,
+		Connector:     "lp-" + lp.Metadata.Namespace + "-" + lp.Metadata.Name,
+	}
+}
+
+var (
+	lpDataStreamRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	lpTopicRe      = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	lpRetentionRe  = regexp.MustCompile(`^[0-9]+(d|h|m|s)$`)
+)
+
+func validateLogPipeline(spec *logPipelineSpec) error {
+	switch {
+	case !lpDataStreamRe.MatchString(spec.DataStream) || strings.HasPrefix(spec.DataStream, ".ds-"):
+		return fmt.Errorf("spec.dataStream %q is not a valid data stream name", spec.DataStream)
+	case !lpTopicRe.MatchString(spec.Topic):
+		return fmt.Errorf("spec.topic %q is not a valid Kafka topic name", spec.Topic)
+	case spec.Retention != "" && !lpRetentionRe.MatchString(spec.Retention):
+		return fmt.Errorf("spec.retention %q must look like 14d / 36h", spec.Retention)
+	case spec.DeletionPolicy != "" && spec.DeletionPolicy != "Retain" && spec.DeletionPolicy != "Delete":
+		return fmt.Errorf("spec.deletionPolicy must be Retain or Delete")
+	}
+	return nil
+}
+
+/************** Kubernetes API（不引入 client-go，直接走 REST） **************/
+
+type kubeClient struct {
+	host      string
+	tokenFile string
+	client    *http.Client
+}
+
+type kubeError struct {
+	Code int
+	Body string
+}
+
+func (e *kubeError) Error() string {
+	return fmt.Sprintf("kubernetes API returned %d: %s", e.Code, e.Body)
+}
+
+func newKubeClient(cfg OperatorConfig) (*kubeClient, error) {
+	host, tokenFile, caFile := cfg.APIServer, cfg.TokenFile, cfg.CAFile
+	if host == "" {
+		h, p := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if h == "" || p == "" {
+			return nil, fmt.Errorf("operator.api_server is empty and KUBERNETES_SERVICE_HOST is not set")
+		}
+		host = "https://" + net.JoinHostPort(h, p)
+		if tokenFile == "" {
+			tokenFile = serviceAccountDir + "/token"
+		}
+		if caFile == "" {
+			caFile = serviceAccountDir + "/ca.crt"
+		}
+	}
+	tlsCfg := &tls.Config{}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read operator.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("operator.ca_file %s contains no certificates", caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return &kubeClient{
+		host:      strings.TrimRight(host, "/"),
+		tokenFile: tokenFile,
+		client:    &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment}},
+	}, nil
+}
+
+func (k *kubeClient) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.host+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// 绑定的 SA token 会轮换，每次读取
+	if k.tokenFile != "" {
+		tok, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(tok)))
+	}
+	return k.client.Do(req)
+}
+
+func (k *kubeClient) call(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	resp, err := k.do(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return &kubeError{Code: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	}
+	if out != nil {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
+
+func lpCollectionPath(ns string) string {
+	p := "/apis/" + lpGroup + "/" + lpVersion
+	if ns != "" {
+		p += "/namespaces/" + url.PathEscape(ns)
+	}
+	return p + "/" + lpPlural
+}
+
+func lpItemPath(lp *logPipeline) string {
+	return lpCollectionPath(lp.Metadata.Namespace) + "/" + url.PathEscape(lp.Metadata.Name)
+}
+
+/************** 控制循环 **************/
+
+type operator struct {
+	s      *Server
+	kube   *kubeClient
+	ns     string
+	resync time.Duration
+
+	mu      sync.Mutex
+	items   map[string]*logPipeline // 最近一次看到的 CR
+	pending map[string]bool         // 待处理的 key → 是否强制对账（全量同步/重试）
+	wake    chan struct{}
+	synced  bool // 至少完成过一次 list
+}
+
+func newOperator(s *Server, cfg OperatorConfig) (*operator, error) {
+	kube, err := newKubeClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	o := &operator{
+		s:       s,
+		kube:    kube,
+		ns:      cfg.Namespace,
+		resync:  mustParseDuration("operator.resync", cfg.Resync),
+		items:   map[string]*logPipeline{},
+		pending: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+	}
+	if o.resync <= 0 {
+		o.resync = defaultOperatorSync
+	}
+	return o, nil
+}
+
+func (o *operator) enqueue(key string, force bool) {
+	o.mu.Lock()
+	o.pending[key] = o.pending[key] || force
+	o.mu.Unlock()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *operator) enqueueAll() {
+	o.mu.Lock()
+	keys := make([]string, 0, len(o.items))
+	for k := range o.items {
+		keys = append(keys, k)
+	}
+	o.mu.Unlock()
+	for _, k := range keys {
+		o.enqueue(k, true)
+	}
+}
+
+func (o *operator) run(ctx context.Context) {
+	o.s.logger.Printf("step=operator start api=%s namespace=%q resync=%s", o.kube.host, o.ns, o.resync)
+	// 退出前等在途的 reconcile 完成
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.worker(ctx)
+	}()
+	go func() {
+		t := time.NewTicker(o.resync)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				o.enqueueAll()
+			}
+		}
+	}()
+	for ctx.Err() == nil {
+		rv, err := o.list(ctx)
+		for err == nil && ctx.Err() == nil {
+			rv, err = o.watch(ctx, rv)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		o.s.logger.Printf("step=operator watch err=%v (relisting)", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// 全量 list：替换本地缓存并全部入队，返回 resourceVersion 供 watch 使用
+func (o *operator) list(ctx context.Context) (string, error) {
+	var out struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []*logPipeline `json:"items"`
+	}
+	if err := o.kube.call(ctx, http.MethodGet, lpCollectionPath(o.ns), "", nil, &out); err != nil {
+		return "", err
+	}
+	items := map[string]*logPipeline{}
+	for _, lp := range out.Items {
+		items[lp.key()] = lp
+	}
+	o.mu.Lock()
+	o.items, o.synced = items, true
+	o.mu.Unlock()
+	o.enqueueAll()
+	return out.Metadata.ResourceVersion, nil
+}
+
+// 单次 watch，服务端超时正常结束时返回最新 resourceVersion 与 nil；410 Gone 等返回错误（调用方重新 list）
+func (o *operator) watch(ctx context.Context, rv string) (string, error) {
+	path := lpCollectionPath(o.ns) + "?watch=1&allowWatchBookmarks=true&timeoutSeconds=300&resourceVersion=" + url.QueryEscape(rv)
+	resp, err := o.kube.do(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return rv, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return rv, &kubeError{Code: resp.StatusCode, Body: string(b)}
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return rv, nil
+			}
+			return rv, err
+		}
+		if ev.Type == "ERROR" {
+			return rv, fmt.Errorf("watch error: %s", string(ev.Object))
+		}
+		var lp logPipeline
+		if err := json.Unmarshal(ev.Object, &lp); err != nil {
+			return rv, fmt.Errorf("decode watch event: %w", err)
+		}
+		rv = lp.Metadata.ResourceVersion
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			o.mu.Lock()
+			o.items[lp.key()] = &lp
+			o.mu.Unlock()
+			o.enqueue(lp.key(), false)
+		case "DELETED":
+			o.mu.Lock()
+			delete(o.items, lp.key())
+			o.mu.Unlock()
+		}
+	}
+}
+
+func (o *operator) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		}
+		for {
+			o.mu.Lock()
+			var key string
+			var force bool
+			for k, f := range o.pending {
+				key, force = k, f
+				break
+			}
+			if key == "" {
+				o.mu.Unlock()
+				break
+			}
+			delete(o.pending, key)
+			lp := o.items[key]
+			o.mu.Unlock()
+			if lp == nil || ctx.Err() != nil {
+				continue
+			}
+			// watch 事件（含自己写 status 引起的 MODIFIED）只在 spec 变化或删除时处理；失败的由定时重试强制处理
+			if !force && lp.Metadata.DeletionTimestamp == nil && lp.Status.ObservedGeneration == lp.Metadata.Generation {
+				continue
+			}
+			if _, on := o.s.maintenance.active(time.Now()); on {
+				o.s.logger.Printf("step=operator reconcile key=%s deferred: maintenance mode (retry in %s)", key, operatorRetryBackoff)
+				time.AfterFunc(operatorRetryBackoff, func() { o.enqueue(key, true) })
+				continue
+			}
+			rctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			err := o.reconcile(rctx, lp)
+			cancel()
+			if err != nil {
+				o.s.logger.Printf("step=operator reconcile key=%s err=%v (retry in %s)", key, err, operatorRetryBackoff)
+				time.AfterFunc(operatorRetryBackoff, func() { o.enqueue(key, true) })
+			}
+		}
+	}
+}
+
+// CR 对应的配置：共用全局配置，只替换资源名
+func (o *operator) configFor(lp *logPipeline) Config {
+	cfg := o.s.cfg
+	res := lp.resources()
+	cfg.ES.Names.DataStream = res.DataStream
+	cfg.ES.Names.ILMPolicy = res.ILMPolicy
+	cfg.ES.Names.IndexTemplate = res.IndexTemplate
+	cfg.Connect.Names.Sink = res.Connector
+	cfg.ES.Names.templated, cfg.ES.Names.indexPattern = false, ""
+	cfg.Approvals.Protected = false // LogPipeline 的变更经 Kubernetes 评审，不走本服务的审批（plans.go）
+	return cfg
+}
+
+// 与主 Server 共享连接、限流、锁等，仅配置不同；用于复用各下发函数
+func (s *Server) pipelineServer(cfg Config) *Server {
+	return &Server{
+		cfg: cfg, clients: s.clients, breakers: s.breakers, logger: s.logger, redact: s.redact, limits: s.limits, cache: s.cache,
+		ws: s.ws, httplog: s.httplog, alerts: s.alerts, sched: s.sched, assets: s.assets, git: s.git, locks: s.locks, probes: s.probes,
+		jobs: s.jobs, compat: s.lastCompat(), restartGuard: s.restartGuard, maintenance: s.maintenance,
+	}
+}
+
+func (o *operator) reconcile(ctx context.Context, lp *logPipeline) error {
+	if lp.Metadata.DeletionTimestamp != nil {
+		return o.finalize(ctx, lp)
+	}
+	if err := validateLogPipeline(&lp.Spec); err != nil {
+		// spec 不合法，等用户修改后再处理（不重试）
+		return o.patchStatus(ctx, lp, lpPhaseError, "InvalidSpec", err.Error(), nil)
+	}
+	if !slices.Contains(lp.Metadata.Finalizers, lpFinalizer) {
+		if err := o.patchFinalizers(ctx, lp, append(slices.Clone(lp.Metadata.Finalizers), lpFinalizer)); err != nil {
+			return err
+		}
+	}
+
+	ps := o.s.pipelineServer(o.configFor(lp))
+	release, err := ps.acquireLock(ctx, "operator:"+lp.key(), "reconcile")
+	if err != nil {
+		_ = o.patchStatus(ctx, lp, lpPhaseError, "Locked", err.Error(), nil)
+		return err
+	}
+	defer release()
+
+	results := map[string]string{}
+	fail := func(step string, err error) error {
+		_ = o.patchStatus(ctx, lp, lpPhaseError, "ApplyFailed", fmt.Sprintf("%s: %v", step, err), results)
+		return fmt.Errorf("%s: %w", step, err)
+	}
+	source := "LogPipeline " + lp.key()
+
+	ilm, err := o.renderILM(lp)
+	if err != nil {
+		return fail(assetILM, err)
+	}
+	if results[assetILM], err = o.applyStep(ctx, lp, func(w http.ResponseWriter, r *http.Request) { ps.applyILM(w, r, source, ilm) }); err != nil {
+		return fail(assetILM, err)
+	}
+	tpl, err := o.renderTemplate(lp)
+	if err != nil {
+		return fail(assetTemplate, err)
+	}
+	if results[assetTemplate], err = o.applyStep(ctx, lp, func(w http.ResponseWriter, r *http.Request) { ps.applyTemplate(w, r, source, tpl) }); err != nil {
+		return fail(assetTemplate, err)
+	}
+	if results["data-stream"], err = o.applyStep(ctx, lp, ps.handleCreateDataStream); err != nil {
+		return fail("data-stream", err)
+	}
+	doc, err := o.renderConnector(lp)
+	if err != nil {
+		return fail(gcConnector, err)
+	}
+	c := &connectSink{s: ps, typ: sinkTypeConnect, name: lp.resources().Connector, file: source,
+		load: func(context.Context) ([]byte, error) { return doc, nil }}
+	res, err := c.Register(ctx)
+	if err == nil && res.Code >= 300 {
+		err = fmt.Errorf("HTTP %d: %s", res.Code, string(res.Body))
+	}
+	if err != nil {
+		return fail(gcConnector, err)
+	}
+	results[gcConnector] = res.Result
+
+	o.s.logger.Printf("step=operator reconciled key=%s results=%v", lp.key(), results)
+	return o.patchStatus(ctx, lp, lpPhaseReady, "Reconciled", "all resources applied", results)
+}
+
+// 以进程内请求调用下发函数，返回 result（无则为 applied）
+func (o *operator) applyStep(ctx context.Context, lp *logPipeline, h http.HandlerFunc) (string, error) {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/operator/"+lp.key(), nil)
+	r.RemoteAddr = "k8s-operator:0"
+	r.Header.Set("X-Operator", "operator:"+lp.key())
+	r.Header.Set("User-Agent", "k8s-operator")
+	cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+	h(cw, r)
+	var out struct {
+		Result string `json:"result"`
+		Error  string `json:"error"`
+		Body   string `json:"body"`
+	}
+	_ = json.Unmarshal([]byte(cw.body), &out)
+	if cw.status >= 300 {
+		msg := out.Error
+		if msg == "" {
+			msg = out.Body
+		}
+		if msg == "" {
+			msg = cw.body
+		}
+		return "", fmt.Errorf("HTTP %d: %s", cw.status, msg)
+	}
+	if out.Result == "" {
+		return "applied", nil
+	}
+	return out.Result, nil
+}
+
+// es.files.ilm 为模板，spec.retention 覆盖 delete 阶段的 min_age
+func (o *operator) renderILM(lp *logPipeline) ([]byte, error) {
+	b, err := o.s.readConfiguredAsset(assetILM, o.s.cfg.ES.Files.ILM)
+	if err != nil || lp.Spec.Retention == "" {
+		return b, err
+	}
+	return ilmWithRetention(b, o.s.cfg.ES.Files.ILM, lp.Spec.Retention)
+}
+
+// ILM 文档的 delete phase min_age 改为 retention，没有 delete phase 时补上（routing.go 也用）
+func ilmWithRetention(b []byte, file, retention string) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	policy, _ := doc["policy"].(map[string]any)
+	if policy == nil {
+		return nil, fmt.Errorf("%s has no \"policy\" object", file)
+	}
+	phases, _ := policy["phases"].(map[string]any)
+	if phases == nil {
+		phases = map[string]any{}
+		policy["phases"] = phases
+	}
+	del, _ := phases["delete"].(map[string]any)
+	if del == nil {
+		del = map[string]any{"actions": map[string]any{"delete": map[string]any{}}}
+		phases["delete"] = del
+	}
+	del["min_age"] = retention
+	return json.Marshal(doc)
+}
+
+// es.files.template 为模板：index_patterns 指向 CR 的 data stream，生命周期策略指向 CR 的策略
+func (o *operator) renderTemplate(lp *logPipeline) ([]byte, error) {
+	b, err := o.s.readConfiguredAsset(assetTemplate, o.s.cfg.ES.Files.Template)
+	if err != nil {
+		return nil, err
+	}
+	res := lp.resources()
+	doc, err := retargetTemplate(b, o.s.cfg.ES.Files.Template, res.DataStream, res.ILMPolicy)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// 模板改为匹配 dataStream 并使用 policy 生命周期策略（routing.go 也用）
+func retargetTemplate(b []byte, file, dataStream, policy string) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	doc["index_patterns"] = []string{dataStream + "*"}
+	if _, ok := doc["data_stream"]; !ok {
+		doc["data_stream"] = map[string]any{}
+	}
+	tpl, _ := doc["template"].(map[string]any)
+	if tpl == nil {
+		tpl = map[string]any{}
+		doc["template"] = tpl
+	}
+	settings, _ := tpl["settings"].(map[string]any)
+	if settings == nil {
+		settings = map[string]any{}
+		tpl["settings"] = settings
+	}
+	if idx, ok := settings["index"].(map[string]any); ok {
+		delete(idx, "lifecycle")
+	}
+	settings["index.lifecycle.name"] = policy
+	return doc, nil
+}
+
+// 主 sink 文件为模板：改名、topic 与写入的 data stream，最后叠加 spec.sink
+func (o *operator) renderConnector(lp *logPipeline) ([]byte, error) {
+	sc := o.s.primarySinkConfig()
+	if sc.Type != sinkTypeConnect {
+		return nil, fmt.Errorf("operator mode needs a Kafka Connect primary sink (sink.type=%s)", sc.Type)
+	}
+	b, err := o.s.readSinkAsset(context.Background(), sc)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Name   string            `json:"name"`
+		Config map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", sc.File, err)
+	}
+	if doc.Config == nil {
+		return nil, fmt.Errorf("%s has no \"config\" object", sc.File)
+	}
+	res := lp.resources()
+	doc.Name = res.Connector
+	cfg := doc.Config
+	if _, ok := cfg["name"]; ok {
+		cfg["name"] = res.Connector
+	}
+	cfg["topics"] = lp.Spec.Topic
+	delete(cfg, "topics.regex")
+	if _, ok := cfg["topic.to.external.resource.mapping"]; ok || cfg["external.resource.usage"] == "DATASTREAM" {
+		cfg["topic.to.external.resource.mapping"] = lp.Spec.Topic + ":" + res.DataStream
+	}
+	if _, ok := cfg["errors.deadletterqueue.topic.name"]; ok {
+		cfg["errors.deadletterqueue.topic.name"] = "dlq." + lp.Spec.Topic
+	}
+	if lp.Spec.Sink.TasksMax > 0 {
+		cfg["tasks.max"] = fmt.Sprint(lp.Spec.Sink.TasksMax)
+	}
+	for k, v := range lp.Spec.Sink.Config {
+		cfg[k] = v
+	}
+	return json.Marshal(doc)
+}
+
+// 删除 CR：先删 connector，deletionPolicy=Delete 时再删 data stream、模板、策略，最后去掉 finalizer
+func (o *operator) finalize(ctx context.Context, lp *logPipeline) error {
+	if !slices.Contains(lp.Metadata.Finalizers, lpFinalizer) {
+		return nil
+	}
+	if lp.Status.Phase != lpPhaseDeleting {
+		_ = o.patchStatus(ctx, lp, lpPhaseDeleting, "Finalizing", "deleting managed resources", nil)
+	}
+	if lp.Spec.DataStream != "" {
+		ps := o.s.pipelineServer(o.configFor(lp))
+		release, err := ps.acquireLock(ctx, "operator:"+lp.key(), "finalize")
+		if err != nil {
+			return err
+		}
+		defer release()
+		res := lp.resources()
+		c := &connectSink{s: ps, typ: sinkTypeConnect, name: res.Connector}
+		r, err := c.Delete(ctx)
+		if err == nil && r.Code >= 300 && r.Code != http.StatusNotFound {
+			err = fmt.Errorf("HTTP %d: %s", r.Code, string(r.Body))
+		}
+		if err != nil {
+			return fmt.Errorf("delete connector %s: %w", res.Connector, err)
+		}
+		if lp.Spec.DeletionPolicy == "Delete" {
+			for _, u := range []string{
+				fmt.Sprintf("%s/_data_stream/%s", ps.cfg.ES.Host, url.PathEscape(res.DataStream)),
+				fmt.Sprintf("%s/_index_template/%s", ps.cfg.ES.Host, url.PathEscape(res.IndexTemplate)),
+				ps.lifecyclePolicyURL(),
+			} {
+				resp, body, err := ps.doDELETE(ctx, u, "es")
+				if err == nil && resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+					err = fmt.Errorf("%s: %s", resp.Status, string(body))
+				}
+				if err != nil {
+					return fmt.Errorf("delete %s: %w", u, err)
+				}
+			}
+		}
+		o.s.logger.Printf("step=operator finalized key=%s policy=%s", lp.key(), lp.Spec.DeletionPolicy)
+	}
+	rest := slices.DeleteFunc(slices.Clone(lp.Metadata.Finalizers), func(f string) bool { return f == lpFinalizer })
+	return o.patchFinalizers(ctx, lp, rest)
+}
+
+// merge patch 带 resourceVersion，CR 已被修改时返回 409，下次重试
+func (o *operator) patchFinalizers(ctx context.Context, lp *logPipeline, finalizers []string) error {
+	patch, _ := json.Marshal(map[string]any{"metadata": map[string]any{
+		"finalizers": finalizers, "resourceVersion": lp.Metadata.ResourceVersion,
+	}})
+	var out logPipeline
+	if err := o.kube.call(ctx, http.MethodPatch, lpItemPath(lp), "application/merge-patch+json", patch, &out); err != nil {
+		return fmt.Errorf("patch finalizers: %w", err)
+	}
+	lp.Metadata.Finalizers, lp.Metadata.ResourceVersion = out.Metadata.Finalizers, out.Metadata.ResourceVersion
+	return nil
+}
+
+func (o *operator) patchStatus(ctx context.Context, lp *logPipeline, phase, reason, msg string, results map[string]string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	cond := lpCondition{Type: "Ready", Status: "False", Reason: reason, Message: msg, LastTransitionTime: now}
+	if phase == lpPhaseReady {
+		cond.Status = "True"
+	}
+	for _, c := range lp.Status.Conditions {
+		if c.Type == "Ready" && c.Status == cond.Status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+	res := lp.resources()
+	st := logPipelineStatus{
+		ObservedGeneration: lp.Metadata.Generation,
+		Phase:              phase,
+		Resources:          &res,
+		Results:            results,
+		Conditions:         []lpCondition{cond},
+		LastReconciled:     now,
+	}
+	patch, _ := json.Marshal(map[string]any{"status": st})
+	var out logPipeline
+	if err := o.kube.call(ctx, http.MethodPatch, lpItemPath(lp)+"/status", "application/merge-patch+json", patch, &out); err != nil {
+		var ke *kubeError
+		if errors.As(err, &ke) && ke.Code == http.StatusNotFound {
+			return nil // CR 已删除
+		}
+		return fmt.Errorf("patch status: %w", err)
+	}
+	// status 子资源同样推进 resourceVersion，后续 finalizer patch 需用新值
+	lp.Status, lp.Metadata.ResourceVersion = st, out.Metadata.ResourceVersion
+	return nil
+}
+
+// 当前由 operator 管理的资源名（供 GC 排除）；尚未完成首次 list 时返回 false
+func (o *operator) managedNames() (map[string][]string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := map[string][]string{}
+	for _, lp := range o.items {
+		if lp.Spec.DataStream == "" {
+			continue
+		}
+		res := lp.resources()
+		out[assetILM] = append(out[assetILM], res.ILMPolicy)
+		out[assetTemplate] = append(out[assetTemplate], res.IndexTemplate)
+		out[gcConnector] = append(out[gcConnector], res.Connector)
+	}
+	return out, o.synced
+}
+
+func (s *Server) handleOperatorStatus(w http.ResponseWriter, r *http.Request) {
+	if s.operator == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "operator.enabled is false"})
+		return
+	}
+	o := s.operator
+	type item struct {
+		Key       string            `json:"key"`
+		Spec      logPipelineSpec   `json:"spec"`
+		Status    logPipelineStatus `json:"status"`
+		Deleting  bool              `json:"deleting,omitempty"`
+		Resources lpResources       `json:"resources"`
+	}
+	o.mu.Lock()
+	items := make([]item, 0, len(o.items))
+	for _, lp := range o.items {
+		items = append(items, item{Key: lp.key(), Spec: lp.Spec, Status: lp.Status,
+			Deleting: lp.Metadata.DeletionTimestamp != nil, Resources: lp.resources()})
+	}
+	synced := o.synced
+	o.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	writeJSON(w, http.StatusOK, map[string]any{"api_server": o.kube.host, "namespace": o.ns, "synced": synced, "items": items})
+}
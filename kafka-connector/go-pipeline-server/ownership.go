@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+/************** 资源归属标记与保护 **************/
+
+// 服务端创建/更新的资源都带上归属标记：ILM policy / 索引模板 / ingest pipeline 写 _meta.managed_by，
+// connector 在 config 中写 managed.by；配置了 ownership.labels 时一并写入标签（labels.go）。修改或删除已存在但标记不一致（或没有标记）的资源时返回 409，
+// 需显式带 ?force=true（接管手工维护的同名资源，或升级前创建、尚无标记的资源）。
+// OpenSearch 的 ISM 策略与 ingest pipeline 不支持 _meta，不做标记与校验。
+//
+// _meta 中同时写入 content_hash：最终下发文档（叠加、标记之后，不含 content_hash 本身）的 sha256。
+// 下发前读取已部署资源的 content_hash，相同则跳过 PUT 并返回 "skipped (unchanged)"，
+// 避免重复下发抬高模板版本、刷 ES 审计日志；跳过时不记录新的资产版本。
+// 只比较标记而不比较实际内容，资源被手工改过（保留了 _meta）时需带 ?force=true 强制下发。
+
+const (
+	defaultManagedBy    = "go-pipeline-server"
+	connectorManagedKey = "managed.by"
+)
+
+type OwnershipConfig struct {
+	ManagedBy string            `yaml:"managed_by"` // 标记值，默认 go-pipeline-server；多个环境共用集群时可填环境名区分
+	Labels    map[string]string `yaml:"labels"`     // 随标记写入资源与版本历史的标签，见 labels.go
+}
+
+func (s *Server) managedBy() string {
+	if s.cfg.Ownership.ManagedBy != "" {
+		return s.cfg.Ownership.ManagedBy
+	}
+	return defaultManagedBy
+}
+
+// 资源存在但不归本服务管理
+type notManagedError struct {
+	Kind  string
+	Name  string
+	Owner string // 现有标记，空表示没有标记
+}
+
+func (e *notManagedError) Error() string {
+	owner := "has no managed_by marker"
+	if e.Owner != "" {
+		owner = fmt.Sprintf("is managed by %q", e.Owner)
+	}
+	return fmt.Sprintf("%s %q already exists and %s; pass force=true to take it over", e.Kind, e.Name, owner)
+}
+
+func writeNotManaged(w http.ResponseWriter, step string, e *notManagedError) {
+	writeJSON(w, http.StatusConflict, map[string]any{"step": step, "error": e.Error(), "owner": e.Owner, "hint": "force=true"})
+}
+
+type forceKey struct{}
+
+func forced(ctx context.Context) bool {
+	v, _ := ctx.Value(forceKey{}).(bool)
+	return v
+}
+
+func (s *Server) ownershipTracked(kind string) bool {
+	return kind == assetTemplate || !s.isOpenSearch()
+}
+
+// 在文档的 _meta 中写入 managed_by；path 为 _meta 所在对象的路径（ILM 为 policy）
+func (s *Server) stampMeta(b []byte, path ...string) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	obj := doc
+	for _, p := range path {
+		next, _ := obj[p].(map[string]any)
+		if next == nil {
+			return nil, fmt.Errorf("document has no %q object", p)
+		}
+		obj = next
+	}
+	meta, _ := obj["_meta"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		obj["_meta"] = meta
+	}
+	meta["managed_by"] = s.managedBy()
+	s.stampLabels(meta)
+	delete(meta, "content_hash")
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	meta["content_hash"] = hex.EncodeToString(sum[:])
+	return json.Marshal(doc)
+}
+
+// 读取 stampMeta 写入的 content_hash
+func contentHash(b []byte, path ...string) string {
+	var doc map[string]any
+	if json.Unmarshal(b, &doc) != nil {
+		return ""
+	}
+	obj := doc
+	for _, p := range path {
+		if obj, _ = obj[p].(map[string]any); obj == nil {
+			return ""
+		}
+	}
+	meta, _ := obj["_meta"].(map[string]any)
+	h, _ := meta["content_hash"].(string)
+	return h
+}
+
+// 已部署的 content_hash 与待下发文档相同时写好 200 响应并返回 true
+func (s *Server) skipUnchanged(w http.ResponseWriter, kind, deployed string, b []byte, path ...string) bool {
+	h := contentHash(b, path...)
+	if deployed == "" || h != deployed {
+		return false
+	}
+	s.logger.Printf("step=%s skipped_unchanged content_hash=%s", kind, h[:12])
+	writeJSON(w, http.StatusOK, map[string]any{"step": kind, "status": "skipped (unchanged)", "content_hash": h})
+	return true
+}
+
+type esResourceMeta struct {
+	ManagedBy   string `json:"managed_by"`
+	ContentHash string `json:"content_hash"`
+}
+
+// 读取 ES 资源的 _meta；资源不存在时 exists=false
+func (s *Server) esMeta(ctx context.Context, kind string) (exists bool, m esResourceMeta, err error) {
+	var url string
+	switch kind {
+	case assetILM:
+		url = s.lifecyclePolicyURL()
+	case assetTemplate:
+		url = fmt.Sprintf("%s/_index_template/%s", s.cfg.ES.Host, s.cfg.ES.Names.IndexTemplate)
+	case assetPipeline:
+		url = fmt.Sprintf("%s/_ingest/pipeline/%s", s.cfg.ES.Host, s.cfg.ES.Names.Pipeline)
+	}
+	resp, body, err := s.doGET(ctx, url, "es")
+	if err != nil {
+		return false, m, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, m, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, m, fmt.Errorf("get %s returned %s", kind, resp.Status)
+	}
+	type meta struct {
+		Meta esResourceMeta `json:"_meta"`
+	}
+	switch kind {
+	case assetILM:
+		var doc map[string]struct {
+			Policy meta `json:"policy"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return false, m, err
+		}
+		p, ok := doc[s.cfg.ES.Names.ILMPolicy]
+		return ok, p.Policy.Meta, nil
+	case assetTemplate:
+		var doc struct {
+			IndexTemplates []struct {
+				IndexTemplate meta `json:"index_template"`
+			} `json:"index_templates"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return false, m, err
+		}
+		if len(doc.IndexTemplates) == 0 {
+			return false, m, nil
+		}
+		return true, doc.IndexTemplates[0].IndexTemplate.Meta, nil
+	default:
+		var doc map[string]meta
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return false, m, err
+		}
+		p, ok := doc[s.cfg.ES.Names.Pipeline]
+		return ok, p.Meta, nil
+	}
+}
+
+// 下发 ES 资产前调用；不允许时已写好响应并返回 ok=false。
+// deployed 为已部署资源的 content_hash（force 或未标记时为空），供 skipUnchanged 比较
+func (s *Server) guardESOwnership(w http.ResponseWriter, r *http.Request, kind string) (deployed string, ok bool) {
+	if r.URL.Query().Get("force") == "true" || !s.ownershipTracked(kind) {
+		return "", true
+	}
+	exists, m, err := s.esMeta(r.Context(), kind)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"step": kind, "error": "check ownership: " + err.Error()})
+		return "", false
+	}
+	if exists && m.ManagedBy != s.managedBy() {
+		name := map[string]string{assetILM: s.cfg.ES.Names.ILMPolicy, assetTemplate: s.cfg.ES.Names.IndexTemplate,
+			assetPipeline: s.cfg.ES.Names.Pipeline}[kind]
+		e := &notManagedError{Kind: kind, Name: name, Owner: m.ManagedBy}
+		s.logger.Printf("step=%s ownership_refused name=%s owner=%q", kind, name, m.ManagedBy)
+		writeNotManaged(w, kind, e)
+		return "", false
+	}
+	return m.ContentHash, true
+}
+
+// connector 文档的 config 中写入 managed.by
+func (s *Server) stampConnector(b []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	cfg, _ := doc["config"].(map[string]any)
+	if cfg == nil {
+		return nil, fmt.Errorf("connector document has no \"config\" object")
+	}
+	cfg[connectorManagedKey] = s.managedBy()
+	if labels := s.cfg.Ownership.Labels; len(labels) > 0 {
+		cfg[connectorLabelsKey] = formatLabelList(labels)
+	} else {
+		delete(cfg, connectorLabelsKey)
+	}
+	return json.Marshal(doc)
+}
+
+// 修改/删除已存在的 connector 前校验标记；connector 不存在时放行（由后续请求返回 404）
+func (c *connectSink) checkOwner(ctx context.Context, have map[string]string) error {
+	if forced(ctx) {
+		return nil
+	}
+	if have == nil {
+		resp, body, err := c.s.doGET(ctx, c.connectorURL("/config"), "connect")
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		if err := json.Unmarshal(body, &have); err != nil {
+			return fmt.Errorf("decode connector config: %w", err)
+		}
+	}
+	if owner := have[connectorManagedKey]; owner != c.s.managedBy() {
+		c.s.logger.Printf("step=sink ownership_refused name=%s owner=%q", c.name, owner)
+		return &notManagedError{Kind: "connector", Name: c.name, Owner: owner}
+	}
+	return nil
+}
+
+func isNotManaged(err error) (*notManagedError, bool) {
+	var e *notManagedError
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/************** 资产局部更新（PATCH /admin/es/{ilm|template|pipeline}） **************/
+
+// 在已存储的资产上打补丁后重新下发，改个 rollover 大小不必重新提交整份文档。
+// 补丁格式按 Content-Type：
+//   - application/json-patch+json   RFC 6902 JSON Patch（操作数组）
+//   - application/merge-patch+json  RFC 7396 JSON Merge Patch（null 表示删除）
+//   - 其他：body 为数组按 JSON Patch，对象按 Merge Patch
+// 基准文档默认为版本历史中的最新一版（/admin/assets/{kind}/versions），
+// 没有历史或 ?base=file 时为配置的资产文件（同 POST，支持 ?ref=）。
+// ?dry_run=true 只返回打补丁后的文档，不下发。
+// 需带 If-Match（见 assets.go），修订号可从 GET /admin/assets/{kind}/versions 或 dry_run 响应的 ETag 取得。
+// 下发成功后补丁结果记为新版本，source 为 patch:v<N> 或 patch:<file>。
+// 注意补丁不会写回资产文件，之后再 POST /admin/es/{kind} 会以文件内容为准。
+
+const (
+	contentTypeJSONPatch  = "application/json-patch+json"
+	contentTypeMergePatch = "application/merge-patch+json"
+)
+
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value"` // "value": null 解码为 null 字面量，缺省时为空
+}
+
+// 解析 JSON Pointer（RFC 6901），"" 为整个文档
+func parseJSONPointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("json pointer %q must start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(tok string, n int, allowEnd bool) (int, error) {
+	if allowEnd && tok == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (tok != "0" && strings.HasPrefix(tok, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	limit := n - 1
+	if allowEnd {
+		limit = n
+	}
+	if i > limit {
+		return 0, fmt.Errorf("array index %d out of range (len %d)", i, n)
+	}
+	return i, nil
+}
+
+// 取 tokens 指向的值
+func jsonPointerGet(doc any, tokens []string) (any, error) {
+	cur := doc
+	for _, t := range tokens {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			cur = v
+		case []any:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into scalar at %q", t)
+		}
+	}
+	return cur, nil
+}
+
+// 对 tokens 的父容器执行 fn，返回替换后的文档（数组增删会产生新切片，需要回写到上一层）
+func jsonPointerUpdate(doc any, tokens []string, fn func(parent any, last string) (any, error)) (any, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("path must not be empty")
+	}
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch c := doc.(type) {
+	case map[string]any:
+		child, ok := c[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", tokens[0])
+		}
+		v, err := jsonPointerUpdate(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[tokens[0]] = v
+		return c, nil
+	case []any:
+		i, err := arrayIndex(tokens[0], len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		v, err := jsonPointerUpdate(c[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+		return c, nil
+	}
+	return nil, fmt.Errorf("cannot descend into scalar at %q", tokens[0])
+}
+
+func jsonPatchAdd(doc any, tokens []string, v any) (any, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return jsonPointerUpdate(doc, tokens, func(parent any, last string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			c[last] = v
+			return c, nil
+		case []any:
+			i, err := arrayIndex(last, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+		}
+		return nil, fmt.Errorf("cannot add to scalar at %q", last)
+	})
+}
+
+func jsonPatchRemove(doc any, tokens []string) (any, error) {
+	return jsonPointerUpdate(doc, tokens, func(parent any, last string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			if _, ok := c[last]; !ok {
+				return nil, fmt.Errorf("member %q not found", last)
+			}
+			delete(c, last)
+			return c, nil
+		case []any:
+			i, err := arrayIndex(last, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove from scalar at %q", last)
+	})
+}
+
+func decodeJSONValue(b []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// 深拷贝（copy 操作与 test 比较用）
+func cloneJSONValue(v any) any {
+	b, _ := json.Marshal(v)
+	out, _ := decodeJSONValue(b)
+	return out
+}
+
+func applyJSONPatch(doc any, ops []jsonPatchOp) (any, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyJSONPatchOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyJSONPatchOp(doc any, op jsonPatchOp) (any, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	value := func() (any, error) {
+		if len(op.Value) == 0 {
+			return nil, errors.New("value is required")
+		}
+		return decodeJSONValue(op.Value)
+	}
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, v)
+	case "remove":
+		return jsonPatchRemove(doc, path)
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := jsonPointerGet(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return v, nil
+		}
+		if doc, err = jsonPatchRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, v)
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := jsonPointerGet(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		if op.Op == "copy" {
+			return jsonPatchAdd(doc, path, cloneJSONValue(v))
+		}
+		if strings.HasPrefix(op.Path+"/", op.From+"/") {
+			if op.Path == op.From {
+				return doc, nil
+			}
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		if doc, err = jsonPatchRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, v)
+	case "test":
+		want, err := value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := jsonPointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(got, want) {
+			return nil, errors.New("test failed: value differs")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// 数字按数值比较（1 与 1.0 相等），其余结构比较
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, ex := x.Float64()
+		fy, ey := y.Float64()
+		if ex != nil || ey != nil {
+			return x == y
+		}
+		return fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// RFC 7396
+func applyMergePatch(doc, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]any)
+	if !ok {
+		d = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = applyMergePatch(d[k], v)
+	}
+	return d
+}
+
+// 按 Content-Type（或 body 首字符）选择补丁格式并应用
+func applyAssetPatch(base []byte, contentType string, body []byte) ([]byte, string, error) {
+	doc, err := decodeJSONValue(base)
+	if err != nil {
+		return nil, "", fmt.Errorf("stored asset is not valid JSON: %w", err)
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	if mediaType != contentTypeJSONPatch && mediaType != contentTypeMergePatch {
+		mediaType = contentTypeMergePatch
+		if t := bytes.TrimSpace(body); len(t) > 0 && t[0] == '[' {
+			mediaType = contentTypeJSONPatch
+		}
+	}
+	switch mediaType {
+	case contentTypeJSONPatch:
+		var ops []jsonPatchOp
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, mediaType, fmt.Errorf("invalid JSON Patch: %w", err)
+		}
+		if len(ops) == 0 {
+			return nil, mediaType, errors.New("JSON Patch has no operations")
+		}
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return nil, mediaType, err
+		}
+	default:
+		patch, err := decodeJSONValue(body)
+		if err != nil {
+			return nil, mediaType, fmt.Errorf("invalid merge patch: %w", err)
+		}
+		doc = applyMergePatch(doc, patch)
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return nil, mediaType, errors.New("patched asset must be a JSON object")
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	return out, mediaType, err
+}
+
+// 取补丁基准：最新的已下发版本，或配置的资产文件
+func (s *Server) patchBase(r *http.Request, kind string) ([]byte, string, error) {
+	if r.URL.Query().Get("base") != "file" && r.URL.Query().Get("ref") == "" {
+		if vs := s.assets.versions(assetKey(kind, "")); len(vs) > 0 {
+			_, b, err := s.assets.get(assetKey(kind, ""), vs[0].Version)
+			if err == nil {
+				return b, fmt.Sprintf("v%d", vs[0].Version), nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, "", err
+			}
+		}
+	}
+	var file string
+	switch kind {
+	case assetILM:
+		file = s.cfg.ES.Files.ILM
+	case assetTemplate:
+		file = s.cfg.ES.Files.Template
+	case assetPipeline:
+		file = s.cfg.ES.Files.Pipeline
+	}
+	b, source, err := s.readAssetOrDefault(optionsContext(r), kind, file)
+	return b, source, err
+}
+
+func (s *Server) handlePatchAsset(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	switch kind {
+	case assetILM, assetTemplate, assetPipeline:
+	default:
+		writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown asset kind %q", kind), "kinds": []string{assetILM, assetTemplate, assetPipeline}})
+		return
+	}
+	rev := s.assetRevision(kind, "")
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun && !checkIfMatch(w, r, kind, rev) {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	base, baseSource, err := s.patchBase(r, kind)
+	if err != nil {
+		s.logger.Printf("step=%s patch read_base_err err=%v", kind, err)
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	patched, format, err := applyAssetPatch(base, r.Header.Get("Content-Type"), body)
+	if err != nil {
+		s.logger.Printf("step=%s patch base=%s format=%s err=%v", kind, baseSource, format, err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error(), "base": baseSource, "format": format})
+		return
+	}
+	s.logger.Printf("step=%s patch base=%s format=%s size=%d->%d operator=%s", kind, baseSource, format, len(base), len(patched), operatorIdentity(r))
+	if dryRun {
+		setETag(w, rev)
+		writeJSON(w, http.StatusOK, map[string]any{"step": kind, "dry_run": true, "base": baseSource, "revision": rev, "format": format, "document": json.RawMessage(patched)})
+		return
+	}
+	source := "patch:" + baseSource
+	var applied bool
+	switch kind {
+	case assetILM:
+		applied = s.applyILM(w, r, source, patched)
+	case assetTemplate:
+		applied = s.applyTemplate(w, r, source, patched)
+	case assetPipeline:
+		applied = s.applyPipeline(w, r, source, patched)
+	}
+	if applied {
+		s.recordAsset(r, kind, "", source, 0, patched)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// "value": null 是合法的 add / replace / test 值，不能当作缺省
+func TestApplyAssetPatchNullValue(t *testing.T) {
+	base := []byte(`{"a":1,"b":{"c":"x"}}`)
+	cases := []struct {
+		name, patch, want string
+	}{
+		{"replace", `[{"op":"replace","path":"/a","value":null}]`, `{"a":null,"b":{"c":"x"}}`},
+		{"add", `[{"op":"add","path":"/b/d","value":null}]`, `{"a":1,"b":{"c":"x","d":null}}`},
+		{"test", `[{"op":"replace","path":"/a","value":null},{"op":"test","path":"/a","value":null}]`, `{"a":null,"b":{"c":"x"}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, _, err := applyAssetPatch(base, contentTypeJSONPatch, []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("applyAssetPatch: %v", err)
+			}
+			var got, want any
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			_ = json.Unmarshal([]byte(tc.want), &want)
+			g, _ := json.Marshal(got)
+			w, _ := json.Marshal(want)
+			if string(g) != string(w) {
+				t.Errorf("got %s, want %s", g, w)
+			}
+		})
+	}
+}
+
+func TestApplyAssetPatchMissingValue(t *testing.T) {
+	_, _, err := applyAssetPatch([]byte(`{"a":1}`), contentTypeJSONPatch, []byte(`[{"op":"add","path":"/b"}]`))
+	if err == nil || !strings.Contains(err.Error(), "value is required") {
+		t.Fatalf("want value is required, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/************** 可视化 pipeline 编辑：处理器结构化 CRUD **************/
+
+// 前端以 {type, config} 列表编辑处理器（拖拽排序即数组顺序），服务端校验后转换为 ES 原生
+// pipeline JSON，写回 es.files.pipeline（即 POST /admin/es/pipeline 下发的资产）
+
+type builderProcessor struct {
+	Type      string             `json:"type"`
+	Config    map[string]any     `json:"config"`               // 不含 on_failure 与 foreach.processor
+	OnFailure []builderProcessor `json:"on_failure,omitempty"` // 处理器级失败分支
+	Processor *builderProcessor  `json:"processor,omitempty"`  // foreach 的内层处理器
+}
+
+type builderPipeline struct {
+	Description string             `json:"description,omitempty"`
+	Version     *int               `json:"version,omitempty"`
+	Meta        map[string]any     `json:"_meta,omitempty"`
+	Processors  []builderProcessor `json:"processors"`
+	OnFailure   []builderProcessor `json:"on_failure,omitempty"` // pipeline 级失败分支
+}
+
+type builderIssue struct {
+	Path    string `json:"path"` // 如 processors[2].on_failure[0]
+	Message string `json:"message"`
+}
+
+// 常用处理器的必填字段；每组内任填其一即可。未收录的类型（插件等）只给警告
+var processorCatalog = map[string][][]string{
+	"append":            {{"field"}, {"value", "copy_from"}},
+	"bytes":             {{"field"}},
+	"community_id":      {},
+	"convert":           {{"field"}, {"type"}},
+	"csv":               {{"field"}, {"target_fields"}},
+	"date":              {{"field"}, {"formats"}},
+	"date_index_name":   {{"field"}, {"date_rounding"}},
+	"dissect":           {{"field"}, {"pattern"}},
+	"dot_expander":      {{"field"}},
+	"drop":              {},
+	"enrich":            {{"policy_name"}, {"field"}, {"target_field"}},
+	"fail":              {{"message"}},
+	"fingerprint":       {{"fields"}},
+	"foreach":           {{"field"}},
+	"geoip":             {{"field"}},
+	"grok":              {{"field"}, {"patterns"}},
+	"gsub":              {{"field"}, {"pattern"}, {"replacement"}},
+	"html_strip":        {{"field"}},
+	"join":              {{"field"}, {"separator"}},
+	"json":              {{"field"}},
+	"kv":                {{"field"}, {"field_split"}, {"value_split"}},
+	"lowercase":         {{"field"}},
+	"network_direction": {},
+	"pipeline":          {{"name"}},
+	"redact":            {{"field"}, {"patterns"}},
+	"registered_domain": {{"field"}, {"target_field"}},
+	"remove":            {{"field", "keep"}},
+	"rename":            {{"field"}, {"target_field"}},
+	"reroute":           {},
+	"script":            {{"source", "id"}},
+	"set":               {{"field"}, {"value", "copy_from"}},
+	"sort":              {{"field"}},
+	"split":             {{"field"}, {"separator"}},
+	"trim":              {{"field"}},
+	"uppercase":         {{"field"}},
+	"uri_parts":         {{"field"}},
+	"urldecode":         {{"field"}},
+	"user_agent":        {{"field"}},
+}
+
+// 所有处理器都可用的通用选项
+var processorCommonOptions = []string{"if", "tag", "description", "ignore_failure", "on_failure"}
+
+func builderFromRaw(raw []any) ([]builderProcessor, error) {
+	out := make([]builderProcessor, 0, len(raw))
+	for i, r := range raw {
+		m, ok := r.(map[string]any)
+		if !ok || len(m) != 1 {
+			return nil, fmt.Errorf("processor %d must be an object with exactly one processor type", i)
+		}
+		for typ, c := range m {
+			p, err := builderProcessorFromRaw(typ, c)
+			if err != nil {
+				return nil, fmt.Errorf("processor %d (%s): %w", i, typ, err)
+			}
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func builderProcessorFromRaw(typ string, c any) (builderProcessor, error) {
+	cfg, _ := c.(map[string]any)
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+	p := builderProcessor{Type: typ, Config: map[string]any{}}
+	for k, v := range cfg {
+		p.Config[k] = v
+	}
+	if of, ok := cfg["on_failure"].([]any); ok {
+		sub, err := builderFromRaw(of)
+		if err != nil {
+			return p, err
+		}
+		p.OnFailure = sub
+		delete(p.Config, "on_failure")
+	}
+	if typ == "foreach" {
+		if inner, ok := cfg["processor"].(map[string]any); ok {
+			sub, err := builderFromRaw([]any{inner})
+			if err != nil {
+				return p, err
+			}
+			p.Processor = &sub[0]
+			delete(p.Config, "processor")
+		}
+	}
+	return p, nil
+}
+
+func (p builderProcessor) raw() map[string]any {
+	cfg := make(map[string]any, len(p.Config)+2)
+	for k, v := range p.Config {
+		cfg[k] = v
+	}
+	if len(p.OnFailure) > 0 {
+		cfg["on_failure"] = builderToRaw(p.OnFailure)
+	}
+	if p.Processor != nil {
+		cfg["processor"] = p.Processor.raw()
+	}
+	return map[string]any{p.Type: cfg}
+}
+
+func builderToRaw(ps []builderProcessor) []any {
+	out := make([]any, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, p.raw())
+	}
+	return out
+}
+
+// 转换为 ES 原生 pipeline JSON
+func (bp builderPipeline) raw() map[string]any {
+	doc := map[string]any{"processors": builderToRaw(bp.Processors)}
+	if bp.Description != "" {
+		doc["description"] = bp.Description
+	}
+	if bp.Version != nil {
+		doc["version"] = *bp.Version
+	}
+	if len(bp.Meta) > 0 {
+		doc["_meta"] = bp.Meta
+	}
+	if len(bp.OnFailure) > 0 {
+		doc["on_failure"] = builderToRaw(bp.OnFailure)
+	}
+	return doc
+}
+
+func pipelineFromRaw(doc map[string]any) (builderPipeline, error) {
+	var bp builderPipeline
+	bp.Description, _ = doc["description"].(string)
+	if v, ok := doc["version"].(float64); ok {
+		n := int(v)
+		bp.Version = &n
+	}
+	bp.Meta, _ = doc["_meta"].(map[string]any)
+	procs, _ := doc["processors"].([]any)
+	var err error
+	if bp.Processors, err = builderFromRaw(procs); err != nil {
+		return bp, err
+	}
+	if of, ok := doc["on_failure"].([]any); ok {
+		if bp.OnFailure, err = builderFromRaw(of); err != nil {
+			return bp, err
+		}
+	}
+	return bp, nil
+}
+
+// 本地校验：类型、必填字段、通用选项类型、foreach/on_failure 递归
+func validateBuilderProcessors(ps []builderProcessor, path string, errs, warns *[]builderIssue) {
+	for i, p := range ps {
+		at := fmt.Sprintf("%s[%d]", path, i)
+		if p.Type == "" {
+			*errs = append(*errs, builderIssue{at, "type is required"})
+			continue
+		}
+		required, known := processorCatalog[p.Type]
+		if !known {
+			*warns = append(*warns, builderIssue{at, fmt.Sprintf("processor type %q is not in the catalog; it is only validated by elasticsearch", p.Type)})
+		}
+		for _, group := range required {
+			found := false
+			for _, k := range group {
+				if v, ok := p.Config[k]; ok && v != nil && v != "" {
+					found = true
+				}
+			}
+			if !found {
+				msg := fmt.Sprintf("%s is required", group[0])
+				if len(group) > 1 {
+					msg = fmt.Sprintf("one of %v is required", group)
+				}
+				*errs = append(*errs, builderIssue{at + "." + p.Type, msg})
+			}
+		}
+		if v, ok := p.Config["if"]; ok {
+			if _, isStr := v.(string); !isStr {
+				*errs = append(*errs, builderIssue{at + "." + p.Type, "if must be a painless condition string"})
+			}
+		}
+		if v, ok := p.Config["ignore_failure"]; ok {
+			if _, isBool := v.(bool); !isBool {
+				*errs = append(*errs, builderIssue{at + "." + p.Type, "ignore_failure must be a boolean"})
+			}
+		}
+		if p.Type == "foreach" && p.Processor == nil {
+			*errs = append(*errs, builderIssue{at + ".foreach", "processor is required"})
+		}
+		if p.Processor != nil {
+			if p.Type != "foreach" {
+				*errs = append(*errs, builderIssue{at, "only foreach has an inner processor"})
+			} else {
+				validateBuilderProcessors([]builderProcessor{*p.Processor}, at+".foreach.processor", errs, warns)
+			}
+		}
+		validateBuilderProcessors(p.OnFailure, at+".on_failure", errs, warns)
+	}
+}
+
+// 用一条空文档跑 _simulate，让 ES 编译整个 pipeline（grok/script/date 等配置错误在这里暴露）
+func (s *Server) compilePipeline(ctx context.Context, raw map[string]any) (int, any, error) {
+	b, _ := json.Marshal(map[string]any{"pipeline": raw, "docs": []any{map[string]any{"_source": map[string]any{}}}})
+	resp, body, err := s.doPOST(ctx, s.cfg.ES.Host+"/_ingest/pipeline/_simulate", b, "es")
+	if err != nil {
+		return 0, nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, nil, nil
+	}
+	return resp.StatusCode, jsonRaw(body), nil
+}
+
+func (s *Server) handleGetPipelineProcessors(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	var doc map[string]any
+	switch source {
+	case "", "file":
+		source = "file"
+		b, err := s.readConfiguredAsset(assetPipeline, s.cfg.ES.Files.Pipeline)
+		if err != nil {
+			writeJSON(w, 400, map[string]string{"error": err.Error()})
+			return
+		}
+		setETag(w, fileRevision(s.assetRevision(assetPipeline, ""), b))
+		if err := json.Unmarshal(b, &doc); err != nil {
+			writeJSON(w, 400, map[string]string{"error": "parse pipeline file: " + err.Error()})
+			return
+		}
+	case "live":
+		url := fmt.Sprintf("%s/_ingest/pipeline/%s", s.cfg.ES.Host, s.cfg.ES.Names.Pipeline)
+		resp, body, err := s.doGET(r.Context(), url, "es")
+		if err != nil {
+			writeJSON(w, 500, errorBody("pipeline-processors", err))
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			writeJSON(w, resp.StatusCode, jsonRaw(body))
+			return
+		}
+		var all map[string]map[string]any
+		if err := json.Unmarshal(body, &all); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": "decode pipeline: " + err.Error()})
+			return
+		}
+		doc = all[s.cfg.ES.Names.Pipeline]
+	default:
+		writeJSON(w, 400, map[string]string{"error": "source must be file or live"})
+		return
+	}
+	bp, err := pipelineFromRaw(doc)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	types := make([]string, 0, len(processorCatalog))
+	for t := range processorCatalog {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	catalog := make([]map[string]any, 0, len(types))
+	for _, t := range types {
+		catalog = append(catalog, map[string]any{"type": t, "required": processorCatalog[t]})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":           s.cfg.ES.Names.Pipeline,
+		"source":         source,
+		"pipeline":       bp,
+		"catalog":        catalog,
+		"common_options": processorCommonOptions,
+	})
+}
+
+// 校验并写回 es.files.pipeline；?dry_run=true 只返回转换结果，?deploy=true 同时下发到 ES。
+// 写回需带 If-Match：GET ?source=file 响应的 ETag（见 assets.go）
+func (s *Server) handlePutPipelineProcessors(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun {
+		cur, err := s.readConfiguredAsset(assetPipeline, s.cfg.ES.Files.Pipeline)
+		if err != nil {
+			writeJSON(w, 400, map[string]string{"error": err.Error()})
+			return
+		}
+		if !checkIfMatch(w, r, "pipeline-processors", fileRevision(s.assetRevision(assetPipeline, ""), cur)) {
+			return
+		}
+	}
+	var bp builderPipeline
+	if err := json.NewDecoder(r.Body).Decode(&bp); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	errs, warns := []builderIssue{}, []builderIssue{}
+	if len(bp.Processors) == 0 {
+		errs = append(errs, builderIssue{"processors", "at least one processor is required"})
+	}
+	validateBuilderProcessors(bp.Processors, "processors", &errs, &warns)
+	validateBuilderProcessors(bp.OnFailure, "on_failure", &errs, &warns)
+	raw := bp.raw()
+	if len(errs) > 0 {
+		writeJSON(w, 400, map[string]any{"error": "pipeline validation failed", "errors": errs, "warnings": warns})
+		return
+	}
+	if code, detail, err := s.compilePipeline(r.Context(), raw); err != nil {
+		warns = append(warns, builderIssue{"", "could not compile on elasticsearch: " + err.Error()})
+	} else if detail != nil {
+		writeJSON(w, code, map[string]any{"error": "elasticsearch rejected the pipeline", "detail": detail, "warnings": warns})
+		return
+	}
+
+	out := map[string]any{"pipeline": raw, "warnings": warns}
+	if dryRun {
+		out["dry_run"] = true
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	msg := fmt.Sprintf("Update ingest pipeline %s (%d processors)", s.cfg.ES.Names.Pipeline, len(bp.Processors))
+	s.savePipelineFile(w, r, "pipeline-processors", raw, msg, out)
+}
+
+// 写回 es.files.pipeline 并提交到 git（若启用），?deploy=true 时同时下发；响应带新的 ETag。
+// out 为已填好的响应体，这里补上 file / git_commit / deploy
+func (s *Server) savePipelineFile(w http.ResponseWriter, r *http.Request, step string, raw map[string]any, msg string, out map[string]any) {
+	b, _ := json.MarshalIndent(raw, "", "  ")
+	file := filepath.Clean(s.cfg.ES.Files.Pipeline)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		writeJSON(w, 500, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		writeJSON(w, 500, map[string]string{"error": err.Error()})
+		return
+	}
+	procs, _ := raw["processors"].([]any)
+	s.logger.Printf("step=%s saved file=%s processors=%d", step, file, len(procs))
+	out["file"] = file
+	if id, err := s.commitAsset(r, file, msg); err != nil {
+		out["git_error"] = err.Error()
+	} else if id != "" {
+		out["git_commit"] = id
+	}
+
+	if r.URL.Query().Get("deploy") == "true" {
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		s.handlePutPipeline(cw, r)
+		out["deploy"] = jsonRaw([]byte(cw.body))
+		setETag(w, fileRevision(s.assetRevision(assetPipeline, ""), append(b, '\n')))
+		writeJSON(w, cw.status, out)
+		return
+	}
+	setETag(w, fileRevision(s.assetRevision(assetPipeline, ""), append(b, '\n')))
+	writeJSON(w, http.StatusOK, out)
+}
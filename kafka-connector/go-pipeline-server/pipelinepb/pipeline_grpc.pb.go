@@ -0,0 +1,595 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pipeline.proto
+
+// 供平台控制器调用的管理接口，与 REST /admin/* 的下发与状态接口一一对应。
+// 生成代码：protoc -I pipelinepb --go_out=pipelinepb --go_opt=paths=source_relative \
+//   --go-grpc_out=pipelinepb --go-grpc_opt=paths=source_relative pipelinepb/pipeline.proto
+
+package pipelinepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PipelineAdmin_CreateDataStream_FullMethodName = "/logpipeline.v1.PipelineAdmin/CreateDataStream"
+	PipelineAdmin_ApplyILM_FullMethodName         = "/logpipeline.v1.PipelineAdmin/ApplyILM"
+	PipelineAdmin_ApplyTemplate_FullMethodName    = "/logpipeline.v1.PipelineAdmin/ApplyTemplate"
+	PipelineAdmin_ApplyPipeline_FullMethodName    = "/logpipeline.v1.PipelineAdmin/ApplyPipeline"
+	PipelineAdmin_RegisterSink_FullMethodName     = "/logpipeline.v1.PipelineAdmin/RegisterSink"
+	PipelineAdmin_PauseSink_FullMethodName        = "/logpipeline.v1.PipelineAdmin/PauseSink"
+	PipelineAdmin_ResumeSink_FullMethodName       = "/logpipeline.v1.PipelineAdmin/ResumeSink"
+	PipelineAdmin_GetHealth_FullMethodName        = "/logpipeline.v1.PipelineAdmin/GetHealth"
+	PipelineAdmin_GetStatus_FullMethodName        = "/logpipeline.v1.PipelineAdmin/GetStatus"
+	PipelineAdmin_GetSinkStatus_FullMethodName    = "/logpipeline.v1.PipelineAdmin/GetSinkStatus"
+	PipelineAdmin_VerifyAll_FullMethodName        = "/logpipeline.v1.PipelineAdmin/VerifyAll"
+	PipelineAdmin_ListJobs_FullMethodName         = "/logpipeline.v1.PipelineAdmin/ListJobs"
+	PipelineAdmin_GetJob_FullMethodName           = "/logpipeline.v1.PipelineAdmin/GetJob"
+)
+
+// PipelineAdminClient is the client API for PipelineAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// 操作人身份通过 metadata x-operator 传递（等同 REST 的 X-Operator 头），写入版本历史与 git 提交。
+// 下发失败时返回 gRPC 错误，状态码由 REST 状态码映射：400→InvalidArgument，404→NotFound，
+// 409→FailedPrecondition（归属校验未通过等），423→Aborted（其他操作持有下发锁），502/503→Unavailable，其他→Internal。
+type PipelineAdminClient interface {
+	// 下发（POST /admin/es/*、/admin/sinks/{name}）
+	CreateDataStream(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	ApplyILM(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	ApplyTemplate(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	ApplyPipeline(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	RegisterSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	PauseSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	ResumeSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	// 状态（/admin/health、/admin/status、/admin/verify/all、/admin/sinks/{name}/status）
+	GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*GetHealthResponse, error)
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	GetSinkStatus(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*GetSinkStatusResponse, error)
+	VerifyAll(ctx context.Context, in *VerifyAllRequest, opts ...grpc.CallOption) (*VerifyAllResponse, error)
+	// 异步任务（/admin/jobs）
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type pipelineAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPipelineAdminClient(cc grpc.ClientConnInterface) PipelineAdminClient {
+	return &pipelineAdminClient{cc}
+}
+
+func (c *pipelineAdminClient) CreateDataStream(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_CreateDataStream_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) ApplyILM(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_ApplyILM_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) ApplyTemplate(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_ApplyTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) ApplyPipeline(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_ApplyPipeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) RegisterSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_RegisterSink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) PauseSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_PauseSink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) ResumeSink(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_ResumeSink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*GetHealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetHealthResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_GetHealth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) GetSinkStatus(ctx context.Context, in *SinkRequest, opts ...grpc.CallOption) (*GetSinkStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSinkStatusResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_GetSinkStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) VerifyAll(ctx context.Context, in *VerifyAllRequest, opts ...grpc.CallOption) (*VerifyAllResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyAllResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_VerifyAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, PipelineAdmin_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineAdminClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PipelineAdmin_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PipelineAdminServer is the server API for PipelineAdmin service.
+// All implementations must embed UnimplementedPipelineAdminServer
+// for forward compatibility.
+//
+// 操作人身份通过 metadata x-operator 传递（等同 REST 的 X-Operator 头），写入版本历史与 git 提交。
+// 下发失败时返回 gRPC 错误，状态码由 REST 状态码映射：400→InvalidArgument，404→NotFound，
+// 409→FailedPrecondition（归属校验未通过等），423→Aborted（其他操作持有下发锁），502/503→Unavailable，其他→Internal。
+type PipelineAdminServer interface {
+	// 下发（POST /admin/es/*、/admin/sinks/{name}）
+	CreateDataStream(context.Context, *ApplyRequest) (*ApplyResponse, error)
+	ApplyILM(context.Context, *ApplyRequest) (*ApplyResponse, error)
+	ApplyTemplate(context.Context, *ApplyRequest) (*ApplyResponse, error)
+	ApplyPipeline(context.Context, *ApplyRequest) (*ApplyResponse, error)
+	RegisterSink(context.Context, *SinkRequest) (*ApplyResponse, error)
+	PauseSink(context.Context, *SinkRequest) (*ApplyResponse, error)
+	ResumeSink(context.Context, *SinkRequest) (*ApplyResponse, error)
+	// 状态（/admin/health、/admin/status、/admin/verify/all、/admin/sinks/{name}/status）
+	GetHealth(context.Context, *GetHealthRequest) (*GetHealthResponse, error)
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	GetSinkStatus(context.Context, *SinkRequest) (*GetSinkStatusResponse, error)
+	VerifyAll(context.Context, *VerifyAllRequest) (*VerifyAllResponse, error)
+	// 异步任务（/admin/jobs）
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	mustEmbedUnimplementedPipelineAdminServer()
+}
+
+// UnimplementedPipelineAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPipelineAdminServer struct{}
+
+func (UnimplementedPipelineAdminServer) CreateDataStream(context.Context, *ApplyRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDataStream not implemented")
+}
+func (UnimplementedPipelineAdminServer) ApplyILM(context.Context, *ApplyRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyILM not implemented")
+}
+func (UnimplementedPipelineAdminServer) ApplyTemplate(context.Context, *ApplyRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyTemplate not implemented")
+}
+func (UnimplementedPipelineAdminServer) ApplyPipeline(context.Context, *ApplyRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyPipeline not implemented")
+}
+func (UnimplementedPipelineAdminServer) RegisterSink(context.Context, *SinkRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSink not implemented")
+}
+func (UnimplementedPipelineAdminServer) PauseSink(context.Context, *SinkRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseSink not implemented")
+}
+func (UnimplementedPipelineAdminServer) ResumeSink(context.Context, *SinkRequest) (*ApplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeSink not implemented")
+}
+func (UnimplementedPipelineAdminServer) GetHealth(context.Context, *GetHealthRequest) (*GetHealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHealth not implemented")
+}
+func (UnimplementedPipelineAdminServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedPipelineAdminServer) GetSinkStatus(context.Context, *SinkRequest) (*GetSinkStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSinkStatus not implemented")
+}
+func (UnimplementedPipelineAdminServer) VerifyAll(context.Context, *VerifyAllRequest) (*VerifyAllResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyAll not implemented")
+}
+func (UnimplementedPipelineAdminServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedPipelineAdminServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedPipelineAdminServer) mustEmbedUnimplementedPipelineAdminServer() {}
+func (UnimplementedPipelineAdminServer) testEmbeddedByValue()                       {}
+
+// UnsafePipelineAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PipelineAdminServer will
+// result in compilation errors.
+type UnsafePipelineAdminServer interface {
+	mustEmbedUnimplementedPipelineAdminServer()
+}
+
+func RegisterPipelineAdminServer(s grpc.ServiceRegistrar, srv PipelineAdminServer) {
+	// If the following call pancis, it indicates UnimplementedPipelineAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PipelineAdmin_ServiceDesc, srv)
+}
+
+func _PipelineAdmin_CreateDataStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).CreateDataStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_CreateDataStream_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).CreateDataStream(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_ApplyILM_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).ApplyILM(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_ApplyILM_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).ApplyILM(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_ApplyTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).ApplyTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_ApplyTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).ApplyTemplate(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_ApplyPipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).ApplyPipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_ApplyPipeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).ApplyPipeline(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_RegisterSink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).RegisterSink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_RegisterSink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).RegisterSink(ctx, req.(*SinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_PauseSink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).PauseSink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_PauseSink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).PauseSink(ctx, req.(*SinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_ResumeSink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).ResumeSink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_ResumeSink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).ResumeSink(ctx, req.(*SinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_GetHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).GetHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_GetHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).GetHealth(ctx, req.(*GetHealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_GetSinkStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).GetSinkStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_GetSinkStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).GetSinkStatus(ctx, req.(*SinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_VerifyAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).VerifyAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_VerifyAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).VerifyAll(ctx, req.(*VerifyAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PipelineAdmin_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineAdminServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PipelineAdmin_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineAdminServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PipelineAdmin_ServiceDesc is the grpc.ServiceDesc for PipelineAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PipelineAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "logpipeline.v1.PipelineAdmin",
+	HandlerType: (*PipelineAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateDataStream",
+			Handler:    _PipelineAdmin_CreateDataStream_Handler,
+		},
+		{
+			MethodName: "ApplyILM",
+			Handler:    _PipelineAdmin_ApplyILM_Handler,
+		},
+		{
+			MethodName: "ApplyTemplate",
+			Handler:    _PipelineAdmin_ApplyTemplate_Handler,
+		},
+		{
+			MethodName: "ApplyPipeline",
+			Handler:    _PipelineAdmin_ApplyPipeline_Handler,
+		},
+		{
+			MethodName: "RegisterSink",
+			Handler:    _PipelineAdmin_RegisterSink_Handler,
+		},
+		{
+			MethodName: "PauseSink",
+			Handler:    _PipelineAdmin_PauseSink_Handler,
+		},
+		{
+			MethodName: "ResumeSink",
+			Handler:    _PipelineAdmin_ResumeSink_Handler,
+		},
+		{
+			MethodName: "GetHealth",
+			Handler:    _PipelineAdmin_GetHealth_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _PipelineAdmin_GetStatus_Handler,
+		},
+		{
+			MethodName: "GetSinkStatus",
+			Handler:    _PipelineAdmin_GetSinkStatus_Handler,
+		},
+		{
+			MethodName: "VerifyAll",
+			Handler:    _PipelineAdmin_VerifyAll_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _PipelineAdmin_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _PipelineAdmin_GetJob_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pipeline.proto",
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/************** pipeline 处理器耗时统计（GET /admin/es/pipeline/stats） **************/
+
+// 汇总 _nodes/stats/ingest 中本 pipeline（es.names.pipeline）各处理器在所有节点上的累计执行次数、耗时与失败数，
+// 按耗时从高到低排列，用于找出昂贵的 grok 等处理器。index 为处理器在 pipeline 中的位置（同 GET /admin/es/pipeline/processors），
+// 给处理器设置 tag 后 name 为 <type>:<tag>，便于区分同类型的多个处理器。
+// 统计自节点启动起累计，pipeline 更新后处理器顺序变化的节点按 index + name 分别计数。
+
+type pipelineProcessorStat struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"` // <type> 或 <type>:<tag>
+	Type  string `json:"type"`
+	ingestProcessorStats
+	AvgMicros  float64 `json:"avg_micros"`  // 每条文档平均耗时
+	TimeShare  float64 `json:"time_share"`  // 占全部处理器耗时的比例（0-1）
+	FailedRate float64 `json:"failed_rate"` // failed / count
+}
+
+type pipelineStatsReport struct {
+	Pipeline   string                  `json:"pipeline"`
+	Nodes      int                     `json:"nodes"` // 执行过本 pipeline 的节点数
+	Total      ingestProcessorStats    `json:"total"`
+	AvgMicros  float64                 `json:"avg_micros"`
+	Processors []pipelineProcessorStat `json:"processors"`
+}
+
+func ratio(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func (s *Server) handlePipelineStats(w http.ResponseWriter, r *http.Request) {
+	name := s.cfg.ES.Names.Pipeline
+	if name == "" {
+		writeJSON(w, 400, map[string]string{"error": "es.names.pipeline is not configured"})
+		return
+	}
+	nodes, err := s.nodesIngestStats(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("pipeline-stats", err))
+		return
+	}
+
+	rep := pipelineStatsReport{Pipeline: name, Processors: []pipelineProcessorStat{}}
+	type procKey struct {
+		index int
+		name  string
+	}
+	byKey := map[procKey]*pipelineProcessorStat{}
+	for _, n := range nodes {
+		p, ok := n.Ingest.Pipelines[name]
+		if !ok {
+			continue
+		}
+		rep.Nodes++
+		rep.Total.Count += p.Count
+		rep.Total.TimeInMilli += p.TimeInMilli
+		rep.Total.Current += p.Current
+		rep.Total.Failed += p.Failed
+		for i, entry := range p.Processors {
+			for key, proc := range entry {
+				st := byKey[procKey{i, key}]
+				if st == nil {
+					typ := proc.Type
+					if typ == "" {
+						typ, _, _ = strings.Cut(key, ":")
+					}
+					st = &pipelineProcessorStat{Index: i, Name: key, Type: typ}
+					byKey[procKey{i, key}] = st
+				}
+				st.Count += proc.Stats.Count
+				st.TimeInMilli += proc.Stats.TimeInMilli
+				st.Current += proc.Stats.Current
+				st.Failed += proc.Stats.Failed
+			}
+		}
+	}
+	if rep.Nodes == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "pipeline " + name + " has no ingest stats on any node (not deployed or never executed since node start)"})
+		return
+	}
+
+	var procTime int64
+	for _, st := range byKey {
+		procTime += st.TimeInMilli
+	}
+	for _, st := range byKey {
+		st.AvgMicros = ratio(st.TimeInMilli*1000, st.Count)
+		st.TimeShare = ratio(st.TimeInMilli, procTime)
+		st.FailedRate = ratio(st.Failed, st.Count)
+		rep.Processors = append(rep.Processors, *st)
+	}
+	sort.Slice(rep.Processors, func(i, j int) bool {
+		a, b := rep.Processors[i], rep.Processors[j]
+		if a.TimeInMilli != b.TimeInMilli {
+			return a.TimeInMilli > b.TimeInMilli
+		}
+		return a.Index < b.Index
+	})
+	rep.AvgMicros = ratio(rep.Total.TimeInMilli*1000, rep.Total.Count)
+	writeJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 变更计划与审批（双人规则） **************/
+
+// approvals.protected=true 的环境（如 prod）中 ILM / 模板 / pipeline / connector 不能直接下发：
+//   1. POST /admin/plans 生成计划：按当前资产文件（?ref= 可指定 git 版本）读出要下发的文档并做 guardrails 检查，内容就此固定；
+//   2. 另一位认证用户 POST /admin/plans/{id}/approve 批准（计划作者不能批准自己的计划）；
+//   3. POST /admin/plans/{id}/apply 下发的正是被批准的文档，产生的资产版本记录 plan 与 approved_by。
+// 用户身份来自 approvals.users 的 token（X-User-Token 头，或 Authorization: Bearer）；受保护环境中创建、批准、下发都要求认证。
+// 计划及审批记录保存在 <assets.dir>/plans.json（GET /admin/plans）。过期（plan_ttl）后不能再批准或下发。
+// 受保护环境中直接下发的接口（含回滚、git 下发、gRPC）返回 403，git webhook 改为生成待批准的计划；
+// connector 期望状态的自动恢复（connectstate.go）与 operator 模式的 LogPipeline 不经过审批。
+// 未受保护的环境也可以用计划，此时不需要批准。
+
+type ApprovalsConfig struct {
+	Environment string         `yaml:"environment"` // 环境名，写入计划，如 prod
+	Protected   bool           `yaml:"protected"`
+	Users       []ApprovalUser `yaml:"users"`
+	PlanTTL     string         `yaml:"plan_ttl"` // 计划有效期，默认 24h
+	Keep        int            `yaml:"keep"`     // 保留的计划数，默认 200
+}
+
+type ApprovalUser struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+}
+
+const (
+	plansFile        = "plans.json"
+	defaultPlansKeep = 200
+	defaultPlanTTL   = 24 * time.Hour
+
+	planPending  = "pending"
+	planApproved = "approved"
+	planApplied  = "applied"
+	planFailed   = "failed"
+	planExpired  = "expired"
+)
+
+// 解析配置时调用
+func (c ApprovalsConfig) validate() error {
+	seen := map[string]bool{}
+	for _, u := range c.Users {
+		switch {
+		case u.Name == "" || u.Token == "":
+			return fmt.Errorf("approvals.users: name and token are required")
+		case seen["name/"+u.Name]:
+			return fmt.Errorf("approvals.users: duplicate user %q", u.Name)
+		case seen["token/"+u.Token]:
+			return fmt.Errorf("approvals.users: user %q reuses another user's token", u.Name)
+		}
+		seen["name/"+u.Name], seen["token/"+u.Token] = true, true
+	}
+	if c.Protected && len(c.Users) < 2 {
+		return fmt.Errorf("approvals.protected needs at least two approvals.users (the author cannot approve their own plan)")
+	}
+	return nil
+}
+
+func (c ApprovalsConfig) ttl() time.Duration {
+	if d := mustParseDuration("approvals.plan_ttl", c.PlanTTL); d > 0 {
+		return d
+	}
+	return defaultPlanTTL
+}
+
+type planItem struct {
+	Kind     string          `json:"kind"`
+	Name     string          `json:"name,omitempty"` // sink 名
+	Source   string          `json:"source"`
+	Hash     string          `json:"hash"`
+	Document json.RawMessage `json:"document,omitempty"`
+}
+
+func (it planItem) key() string {
+	if it.Name != "" {
+		return it.Kind + "/" + it.Name
+	}
+	return it.Kind
+}
+
+type planApproval struct {
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+type changePlan struct {
+	ID          string         `json:"id"`
+	Environment string         `json:"environment,omitempty"`
+	Protected   bool           `json:"protected"`
+	Status      string         `json:"status"`
+	Note        string         `json:"note,omitempty"`
+	Ref         string         `json:"ref,omitempty"`
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	Items       []planItem     `json:"items"`
+	Approvals   []planApproval `json:"approvals,omitempty"`
+	AppliedBy   string         `json:"applied_by,omitempty"`
+	AppliedAt   *time.Time     `json:"applied_at,omitempty"`
+	Results     map[string]any `json:"results,omitempty"`
+}
+
+func (p *changePlan) approvers() []string {
+	out := make([]string, 0, len(p.Approvals))
+	for _, a := range p.Approvals {
+		out = append(out, a.By)
+	}
+	return out
+}
+
+// 待处理的计划过期后标记为 expired；返回是否有改动
+func (p *changePlan) expire(now time.Time) bool {
+	if (p.Status == planPending || p.Status == planApproved) && now.After(p.ExpiresAt) {
+		p.Status = planExpired
+		return true
+	}
+	return false
+}
+
+// 列表中不带文档
+func (p changePlan) summary() changePlan {
+	items := make([]planItem, len(p.Items))
+	for i, it := range p.Items {
+		it.Document = nil
+		items[i] = it
+	}
+	p.Items = items
+	return p
+}
+
+type planStore struct {
+	mu    sync.Mutex
+	file  string
+	keep  int
+	plans []*changePlan // 按创建时间排序
+}
+
+func newPlanStore(cfg Config) *planStore {
+	dir := cfg.Assets.Dir
+	if dir == "" {
+		dir = defaultAssetsDir
+	}
+	keep := cfg.Approvals.Keep
+	if keep <= 0 {
+		keep = defaultPlansKeep
+	}
+	return &planStore{file: filepath.Join(dir, plansFile), keep: keep}
+}
+
+func (st *planStore) load() error {
+	b, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := json.Unmarshal(b, &st.plans); err != nil {
+		return fmt.Errorf("decode %s: %w", st.file, err)
+	}
+	return nil
+}
+
+func (st *planStore) saveLocked() error {
+	if n := len(st.plans) - st.keep; n > 0 {
+		st.plans = slices.Delete(st.plans, 0, n)
+	}
+	b, err := json.MarshalIndent(st.plans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), 0o755); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+func (st *planStore) add(p *changePlan) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.plans = append(st.plans, p)
+	return st.saveLocked()
+}
+
+func (st *planStore) list(status string) []changePlan {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now().UTC()
+	out := []changePlan{}
+	for i := len(st.plans) - 1; i >= 0; i-- {
+		p := st.plans[i]
+		p.expire(now)
+		if status == "" || p.Status == status {
+			out = append(out, p.summary())
+		}
+	}
+	return out
+}
+
+// 在锁内修改计划并保存；fn 返回错误时不保存。返回修改后的副本
+func (st *planStore) update(id string, fn func(p *changePlan) error) (changePlan, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := slices.IndexFunc(st.plans, func(p *changePlan) bool { return p.ID == id })
+	if i < 0 {
+		return changePlan{}, os.ErrNotExist
+	}
+	p := st.plans[i]
+	expired := p.expire(time.Now().UTC())
+	if err := fn(p); err != nil {
+		if expired {
+			_ = st.saveLocked()
+		}
+		return *p, err
+	}
+	return *p, st.saveLocked()
+}
+
+func (st *planStore) get(id string) (changePlan, error) {
+	p, err := st.update(id, func(*changePlan) error { return errPlanUnchanged })
+	if errors.Is(err, errPlanUnchanged) {
+		err = nil
+	}
+	return p, err
+}
+
+var errPlanUnchanged = errors.New("unchanged")
+
+/************** 身份与拦截 **************/
+
+// approvals.users 中的用户；X-User-Token 优先，其次 Bearer token
+func (s *Server) approvalUser(r *http.Request) (string, bool) {
+	tok := strings.TrimSpace(r.Header.Get("X-User-Token"))
+	if tok == "" {
+		tok = bearerToken(r)
+	}
+	if tok == "" {
+		return "", false
+	}
+	for _, u := range s.cfg.Approvals.Users {
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(u.Token)) == 1 {
+			return u.Name, true
+		}
+	}
+	return "", false
+}
+
+type approvalRequiredError struct{ env string }
+
+func (e *approvalRequiredError) Error() string {
+	return fmt.Sprintf("environment %s is protected: changes must go through an approved plan (POST /admin/plans)", e.env)
+}
+
+type (
+	planKey           struct{}
+	approvalExemptKey struct{}
+)
+
+// 受保护环境中，不是由计划发起（也未豁免）的下发返回 *approvalRequiredError
+func (s *Server) requireApproval(ctx context.Context) error {
+	if !s.cfg.Approvals.Protected {
+		return nil
+	}
+	if _, ok := ctx.Value(planKey{}).(*changePlan); ok {
+		return nil
+	}
+	if ok, _ := ctx.Value(approvalExemptKey{}).(bool); ok {
+		return nil
+	}
+	env := s.cfg.Approvals.Environment
+	if env == "" {
+		env = "(approvals.environment)"
+	}
+	return &approvalRequiredError{env: env}
+}
+
+func writeApprovalRequired(w http.ResponseWriter, step string, e *approvalRequiredError) {
+	writeJSON(w, http.StatusForbidden, map[string]any{"step": step, "error": e.Error(), "hint": "POST /admin/plans"})
+}
+
+/************** 生成计划 **************/
+
+type planRequest struct {
+	Kinds []string `json:"kinds,omitempty"` // 默认 ilm / pipeline / template / sink
+	Sinks []string `json:"sinks,omitempty"` // kind=sink 时的 sink 名，默认主 sink
+	Note  string   `json:"note,omitempty"`
+}
+
+// 按当前资产（ctx 带 ref 时为该 git 版本）读出文档；下发顺序同部署向导：ILM、pipeline、模板、sink
+func (s *Server) planItems(ctx context.Context, req planRequest) ([]planItem, error) {
+	kinds := req.Kinds
+	if len(kinds) == 0 {
+		kinds = assetKinds
+	}
+	var items []planItem
+	add := func(kind, name, source string, b []byte) {
+		sum := sha256.Sum256(b)
+		items = append(items, planItem{Kind: kind, Name: name, Source: source, Hash: hex.EncodeToString(sum[:]), Document: json.RawMessage(b)})
+	}
+	for _, kind := range []string{assetILM, assetPipeline, assetTemplate} {
+		if !slices.Contains(kinds, kind) {
+			continue
+		}
+		file := map[string]string{assetILM: s.cfg.ES.Files.ILM, assetPipeline: s.cfg.ES.Files.Pipeline, assetTemplate: s.cfg.ES.Files.Template}[kind]
+		b, source, err := s.readAssetOrDefault(ctx, kind, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kind, err)
+		}
+		add(kind, "", assetSource(ctx, source), b)
+	}
+	if slices.Contains(kinds, assetSink) {
+		names := req.Sinks
+		if len(names) == 0 {
+			names = []string{s.primarySinkConfig().Name}
+		}
+		for _, name := range names {
+			sc, ok := s.findSinkConfig(name)
+			if !ok {
+				return nil, fmt.Errorf("sink %q not configured", name)
+			}
+			p, err := s.sinkProvider(sc)
+			if err != nil {
+				return nil, err
+			}
+			c, ok := p.(*connectSink)
+			if !ok {
+				return nil, fmt.Errorf("sink %s (type %s) has no connector document to plan", name, sc.Type)
+			}
+			b, err := c.load(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("sink %s: %w", name, err)
+			}
+			source := assetSource(ctx, c.file)
+			if source == "" {
+				source = "rendered from sinks config"
+			}
+			add(assetSink, name, source, b)
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no assets selected; kinds must be among %v", assetKinds)
+	}
+	return items, nil
+}
+
+func (s *Server) createPlan(ctx context.Context, req planRequest, by string) (*changePlan, error) {
+	items, err := s.planItems(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// guardrails 在生成计划时检查，不合规的计划不会进入审批
+	var violations []guardrailViolation
+	for _, it := range items {
+		var ge *guardrailError
+		if errors.As(s.checkGuardrails(it.Kind, it.Document), &ge) {
+			violations = append(violations, ge.Violations...)
+		}
+	}
+	if len(violations) > 0 {
+		return nil, &guardrailError{Kind: "plan", Violations: violations}
+	}
+	now := time.Now().UTC()
+	ref, _ := ctx.Value(assetRefKey{}).(string)
+	p := &changePlan{
+		ID: newJobID(), Environment: s.cfg.Approvals.Environment, Protected: s.cfg.Approvals.Protected, Status: planPending,
+		Note: req.Note, Ref: ref, CreatedBy: by, CreatedAt: now, ExpiresAt: now.Add(s.cfg.Approvals.ttl()), Items: items,
+	}
+	if err := s.plans.add(p); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	for _, it := range items {
+		keys = append(keys, it.key())
+	}
+	s.logger.Printf("step=plan create id=%s env=%s protected=%t items=%s by=%q", p.ID, p.Environment, p.Protected, strings.Join(keys, ","), by)
+	return p, nil
+}
+
+// POST /admin/plans[?ref=]
+func (s *Server) handleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req planRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeInvalidBody(w, err)
+			return
+		}
+	}
+	by, ok := s.approvalUser(r)
+	if !ok {
+		if s.cfg.Approvals.Protected {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "a user token (approvals.users) is required to create plans in a protected environment"})
+			return
+		}
+		by = operatorIdentity(r)
+	}
+	p, err := s.createPlan(optionsContext(r), req, by)
+	var ge *guardrailError
+	switch {
+	case errors.As(err, &ge):
+		writeGuardrailError(w, "plan", ge)
+	case err != nil:
+		writeJSON(w, 400, map[string]string{"step": "plan", "error": err.Error()})
+	default:
+		writeJSON(w, http.StatusCreated, p)
+	}
+}
+
+// GET /admin/plans[?status=pending]
+func (s *Server) handleListPlans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"environment": s.cfg.Approvals.Environment, "protected": s.cfg.Approvals.Protected,
+		"plans": s.plans.list(r.URL.Query().Get("status")),
+	})
+}
+
+func (s *Server) handleGetPlan(w http.ResponseWriter, r *http.Request) {
+	p, err := s.plans.get(r.PathValue("id"))
+	if err != nil {
+		writePlanError(w, r.PathValue("id"), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+type planConflict struct{ msg string }
+
+func (e *planConflict) Error() string { return e.msg }
+
+func writePlanError(w http.ResponseWriter, id string, err error) {
+	var pc *planConflict
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("plan %q not found", id)})
+	case errors.As(err, &pc):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorBody("plan", err))
+	}
+}
+
+// POST /admin/plans/{id}/approve  {"comment": "..."}
+func (s *Server) handleApprovePlan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeInvalidBody(w, err)
+			return
+		}
+	}
+	by, ok := s.approvalUser(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "a user token (approvals.users) is required to approve plans"})
+		return
+	}
+	p, err := s.plans.update(id, func(p *changePlan) error {
+		switch {
+		case p.Status != planPending:
+			return &planConflict{fmt.Sprintf("plan %s is %s", p.ID, p.Status)}
+		case p.CreatedBy == by:
+			return &planConflict{fmt.Sprintf("%s created plan %s and cannot approve it; a second user must approve", by, p.ID)}
+		}
+		p.Approvals = append(p.Approvals, planApproval{By: by, At: time.Now().UTC(), Comment: req.Comment})
+		p.Status = planApproved
+		return nil
+	})
+	if err != nil {
+		writePlanError(w, id, err)
+		return
+	}
+	s.logger.Printf("step=plan approve id=%s by=%q", id, by)
+	writeJSON(w, http.StatusOK, p.summary())
+}
+
+// POST /admin/plans/{id}/apply：按计划中的文档依次下发，遇到失败即停止
+func (s *Server) handleApplyPlan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	// 冻结窗口放行 /admin/plans 的创建与审批，下发在这里检查（freeze.go）
+	if !s.checkFreeze(w, r) {
+		return
+	}
+	by, authed := s.approvalUser(r)
+	if !authed {
+		if s.cfg.Approvals.Protected {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "a user token (approvals.users) is required to apply plans in a protected environment"})
+			return
+		}
+		by = operatorIdentity(r)
+	}
+	// 先占住计划（状态改为 applied），避免同一计划被并发下发两次；失败时再改为 failed
+	now := time.Now().UTC()
+	p, err := s.plans.update(id, func(p *changePlan) error {
+		switch {
+		case p.Status == planPending && (p.Protected || s.cfg.Approvals.Protected):
+			return &planConflict{fmt.Sprintf("plan %s targets protected environment %s and has not been approved", p.ID, p.Environment)}
+		case p.Status != planPending && p.Status != planApproved:
+			return &planConflict{fmt.Sprintf("plan %s is %s", p.ID, p.Status)}
+		}
+		p.Status, p.AppliedBy, p.AppliedAt = planApplied, by, &now
+		return nil
+	})
+	if err != nil {
+		writePlanError(w, id, err)
+		return
+	}
+	s.logger.Printf("step=plan apply id=%s by=%q approved_by=%v", id, by, p.approvers())
+
+	r = r.WithContext(context.WithValue(r.Context(), planKey{}, &p))
+	r.Header.Set("X-Operator", by)
+	source := "plan " + p.ID
+	results := map[string]any{}
+	code := http.StatusOK
+	for _, it := range p.Items {
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		doc := []byte(it.Document)
+		switch it.Kind {
+		case assetILM:
+			if s.applyILM(cw, r, source, doc) {
+				s.recordAsset(r, it.Kind, "", source, 0, doc)
+			}
+		case assetTemplate:
+			if s.applyTemplate(cw, r, source, doc) {
+				s.recordAsset(r, it.Kind, "", source, 0, doc)
+			}
+		case assetPipeline:
+			if s.applyPipeline(cw, r, source, doc) {
+				s.recordAsset(r, it.Kind, "", source, 0, doc)
+			}
+		case assetSink:
+			sc, _ := s.findSinkConfig(it.Name)
+			c := &connectSink{s: s, typ: sc.Type, name: it.Name, file: source,
+				load: func(context.Context) ([]byte, error) { return doc, nil }}
+			res, err := c.Register(optionsContext(r))
+			s.recordSinkRegister(r, c, res, err, 0)
+			s.writeSinkRegister(cw, c, res, err)
+		}
+		results[it.key()] = map[string]any{"status": cw.status, "body": jsonRaw([]byte(cw.body))}
+		if cw.status >= 300 {
+			code = cw.status
+			break
+		}
+	}
+	p, err = s.plans.update(id, func(p *changePlan) error {
+		p.Results = results
+		if code >= 300 {
+			p.Status = planFailed
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Printf("step=plan id=%s save_err=%v", id, err)
+	}
+	s.logger.Printf("step=plan applied id=%s status=%s", id, p.Status)
+	writeJSON(w, code, p.summary())
+}
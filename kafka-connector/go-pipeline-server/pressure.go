@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/************** ES 写入背压（bulk 拒绝 / indexing pressure / 熔断）与消费延迟 **************/
+
+// GET /admin/es/pressure：从 _nodes/stats 取 write 线程池、indexing pressure 与 circuit breaker，
+// 和 sink 的 consumer lag 放在一起返回。ES 的计数都是启动以来的累计值，
+// 这里记住上一次采样，给出距上次调用的增量——"lag 在涨、同时 write 拒绝在涨"即是背压。
+
+type nodePressure struct {
+	Node string `json:"node"`
+
+	WriteActive        int   `json:"write_active"`
+	WriteQueue         int   `json:"write_queue"`
+	WriteRejected      int64 `json:"write_rejected"`
+	WriteRejectedDelta int64 `json:"write_rejected_delta"`
+
+	// indexing_pressure.memory（ES 7.9+）
+	IndexingBytes         int64 `json:"indexing_current_bytes"`
+	IndexingLimit         int64 `json:"indexing_limit_bytes,omitempty"`
+	IndexingRejected      int64 `json:"indexing_rejections"` // coordinating + primary + replica
+	IndexingRejectedDelta int64 `json:"indexing_rejections_delta"`
+
+	BreakerTrips      map[string]int64 `json:"breaker_trips,omitempty"` // 只列出 tripped > 0 的
+	BreakerTripsDelta int64            `json:"breaker_trips_delta"`
+}
+
+type pressureSample struct {
+	at    time.Time
+	nodes map[string]nodePressure
+	lag   *int64
+}
+
+type pressureSampler struct {
+	mu   sync.Mutex
+	last *pressureSample
+}
+
+func (s *Server) collectNodePressure(ctx context.Context) (map[string]nodePressure, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_nodes/stats/thread_pool,indexing_pressure,breaker", "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nodes stats returned %s", resp.Status)
+	}
+	var doc struct {
+		Nodes map[string]struct {
+			Name       string `json:"name"`
+			ThreadPool map[string]struct {
+				Active   int   `json:"active"`
+				Queue    int   `json:"queue"`
+				Rejected int64 `json:"rejected"`
+			} `json:"thread_pool"`
+			IndexingPressure struct {
+				Memory struct {
+					Current struct {
+						AllInBytes int64 `json:"all_in_bytes"`
+					} `json:"current"`
+					Total struct {
+						CoordinatingRejections int64 `json:"coordinating_rejections"`
+						PrimaryRejections      int64 `json:"primary_rejections"`
+						ReplicaRejections      int64 `json:"replica_rejections"`
+					} `json:"total"`
+					LimitInBytes int64 `json:"limit_in_bytes"`
+				} `json:"memory"`
+			} `json:"indexing_pressure"`
+			Breakers map[string]struct {
+				Tripped int64 `json:"tripped"`
+			} `json:"breakers"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode nodes stats: %w", err)
+	}
+	out := map[string]nodePressure{}
+	for id, n := range doc.Nodes {
+		name := n.Name
+		if name == "" {
+			name = id
+		}
+		wp := n.ThreadPool["write"]
+		mem := n.IndexingPressure.Memory
+		np := nodePressure{
+			Node:             name,
+			WriteActive:      wp.Active,
+			WriteQueue:       wp.Queue,
+			WriteRejected:    wp.Rejected,
+			IndexingBytes:    mem.Current.AllInBytes,
+			IndexingLimit:    mem.LimitInBytes,
+			IndexingRejected: mem.Total.CoordinatingRejections + mem.Total.PrimaryRejections + mem.Total.ReplicaRejections,
+		}
+		for b, st := range n.Breakers {
+			if st.Tripped > 0 {
+				if np.BreakerTrips == nil {
+					np.BreakerTrips = map[string]int64{}
+				}
+				np.BreakerTrips[b] = st.Tripped
+			}
+		}
+		out[name] = np
+	}
+	return out, nil
+}
+
+func sumTrips(m map[string]int64) int64 {
+	var n int64
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
+
+// 累计计数的增量；节点重启后计数归零，此时按当前值计
+func counterDelta(cur, prev int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+func (s *Server) handleESPressure(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	nodes, err := s.collectNodePressure(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("es-pressure", err))
+		return
+	}
+	var lag *consumerLag
+	if p, err := s.primarySink(); err == nil {
+		lag = s.collectConsumerLag(ctx, p.Name())
+	}
+	cur := &pressureSample{at: time.Now(), nodes: nodes}
+	if lag != nil && lag.Error == "" {
+		cur.lag = &lag.Total
+	}
+
+	s.pressure.mu.Lock()
+	prev := s.pressure.last
+	s.pressure.last = cur
+	s.pressure.mu.Unlock()
+
+	out := map[string]any{"at": cur.at.UTC().Format(time.RFC3339)}
+	var totalRejected, totalIdxRejected, totalTrips int64
+	list := make([]nodePressure, 0, len(nodes))
+	for name, np := range nodes {
+		if prev != nil {
+			if p, ok := prev.nodes[name]; ok {
+				np.WriteRejectedDelta = counterDelta(np.WriteRejected, p.WriteRejected)
+				np.IndexingRejectedDelta = counterDelta(np.IndexingRejected, p.IndexingRejected)
+				np.BreakerTripsDelta = counterDelta(sumTrips(np.BreakerTrips), sumTrips(p.BreakerTrips))
+			}
+		}
+		totalRejected += np.WriteRejectedDelta
+		totalIdxRejected += np.IndexingRejectedDelta
+		totalTrips += np.BreakerTripsDelta
+		list = append(list, np)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Node < list[j].Node })
+	out["nodes"] = list
+	if lag != nil {
+		out["lag"] = lag
+	}
+	if prev != nil {
+		since := cur.at.Sub(prev.at)
+		window := map[string]any{
+			"since_seconds":       int(since.Seconds()),
+			"write_rejected":      totalRejected,
+			"indexing_rejections": totalIdxRejected,
+			"breaker_trips":       totalTrips,
+		}
+		if cur.lag != nil && prev.lag != nil {
+			window["lag_delta"] = *cur.lag - *prev.lag
+		}
+		out["since_last"] = window
+		// 背压判断：拒绝/熔断在增加，且 lag 没有下降
+		rising := cur.lag == nil || prev.lag == nil || *cur.lag >= *prev.lag
+		out["backpressure"] = (totalRejected > 0 || totalIdxRejected > 0 || totalTrips > 0) && rising
+	}
+	writeJSON(w, http.StatusOK, out)
+}
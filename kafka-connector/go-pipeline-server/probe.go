@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 启动兼容性探测（ES / Connect） **************/
+
+// ES 需 >= 7.9 才同时支持 data stream 与 ILM 的组合用法（OpenSearch 另行判断）
+const (
+	minESMajor = 7
+	minESMinor = 9
+)
+
+type probeTarget struct {
+	Reachable bool   `json:"reachable"`
+	Version   string `json:"version,omitempty"`
+	Flavor    string `json:"flavor,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type compatReport struct {
+	CheckedAt time.Time       `json:"checked_at"`
+	ES        probeTarget     `json:"es"`
+	Connect   probeTarget     `json:"connect"`
+	Plugins   map[string]bool `json:"plugins,omitempty"`
+	Issues    []string        `json:"issues"`
+}
+
+func (c *compatReport) ok() bool { return len(c.Issues) == 0 }
+
+// 解析 "8.12.2" / "7.17.0-SNAPSHOT" 这类版本号的主次版本
+func parseMajorMinor(v string) (int, int, bool) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+func versionAtLeast(v string, major, minor int) bool {
+	ma, mi, ok := parseMajorMinor(v)
+	if !ok {
+		return false
+	}
+	return ma > major || (ma == major && mi >= minor)
+}
+
+// 需要在 Connect 上存在的插件：sink 文件里的 connector.class（仅 connect 模式）+ 配置里的附加项
+func (s *Server) requiredPlugins() []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(c string) {
+		if c != "" && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	if b, err := s.readConfiguredAsset(assetSink, s.cfg.Connect.Files.Sink); err == nil && s.sinkType() == sinkTypeConnect {
+		var sink struct {
+			Config map[string]any `json:"config"`
+		}
+		if json.Unmarshal(b, &sink) == nil {
+			if c, ok := sink.Config["connector.class"].(string); ok {
+				add(c)
+			}
+		}
+	}
+	for _, sc := range s.cfg.Sinks {
+		switch normalizeSinkType(sc.Type) {
+		case sinkTypeS3:
+			add(s3ConnectorClass)
+		case sinkTypeMirrorMaker:
+			if class, err := sc.MirrorMaker.class(); err == nil {
+				add(class)
+			}
+		}
+	}
+	for _, c := range s.cfg.Connect.RequiredPlugins {
+		add(c)
+	}
+	return out
+}
+
+func (s *Server) probeES(ctx context.Context, rep *compatReport) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/", "es")
+	if err != nil {
+		rep.ES.Error = err.Error()
+		rep.Issues = append(rep.Issues, fmt.Sprintf("es unreachable: %v", err))
+		return
+	}
+	if resp.StatusCode >= 400 {
+		rep.ES.Error = resp.Status
+		rep.Issues = append(rep.Issues, fmt.Sprintf("es root returned %s", resp.Status))
+		return
+	}
+	rep.ES.Reachable = true
+	var info struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	_ = json.Unmarshal(body, &info)
+	rep.ES.Version = info.Version.Number
+	rep.ES.Flavor = flavorElasticsearch
+	if info.Version.Distribution != "" {
+		rep.ES.Flavor = info.Version.Distribution
+	}
+	if rep.ES.Flavor != s.esFlavor() {
+		rep.Issues = append(rep.Issues, fmt.Sprintf("es.flavor is %q but cluster reports %q", s.esFlavor(), rep.ES.Flavor))
+	}
+	switch rep.ES.Flavor {
+	case flavorElasticsearch:
+		if !versionAtLeast(rep.ES.Version, minESMajor, minESMinor) {
+			rep.Issues = append(rep.Issues, fmt.Sprintf("es version %q does not support data streams with ILM (need >= %d.%d)",
+				rep.ES.Version, minESMajor, minESMinor))
+		}
+	case flavorOpenSearch:
+		// OpenSearch 1.0 起支持 data stream 与 ISM
+		if !versionAtLeast(rep.ES.Version, 1, 0) {
+			rep.Issues = append(rep.Issues, fmt.Sprintf("opensearch version %q does not support data streams", rep.ES.Version))
+		}
+	}
+}
+
+func (s *Server) probeConnect(ctx context.Context, rep *compatReport) {
+	resp, body, err := s.doGET(ctx, s.cfg.Connect.Host+"/", "connect")
+	if err != nil {
+		rep.Connect.Error = err.Error()
+		rep.Issues = append(rep.Issues, fmt.Sprintf("connect unreachable: %v", err))
+		return
+	}
+	if resp.StatusCode >= 400 {
+		rep.Connect.Error = resp.Status
+		rep.Issues = append(rep.Issues, fmt.Sprintf("connect root returned %s", resp.Status))
+		return
+	}
+	rep.Connect.Reachable = true
+	var info struct {
+		Version string `json:"version"`
+	}
+	_ = json.Unmarshal(body, &info)
+	rep.Connect.Version = info.Version
+
+	required := s.requiredPlugins()
+	if len(required) == 0 {
+		return
+	}
+	plugins, err := s.listConnectPlugins(ctx)
+	if err != nil {
+		rep.Issues = append(rep.Issues, fmt.Sprintf("connect plugin list failed: %v", err))
+		return
+	}
+	installed := map[string]bool{}
+	for _, p := range plugins {
+		installed[p.Class] = true
+	}
+	rep.Plugins = map[string]bool{}
+	for _, c := range required {
+		ok := installed[c]
+		rep.Plugins[c] = ok
+		if !ok {
+			rep.Issues = append(rep.Issues, fmt.Sprintf("connect plugin %s is not installed", c))
+		}
+	}
+}
+
+type connectPlugin struct {
+	Class   string `json:"class"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+// GET /connector-plugins
+func (s *Server) listConnectPlugins(ctx context.Context) ([]connectPlugin, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.Connect.Host+"/connector-plugins", "connect")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("connector-plugins returned %s", resp.Status)
+	}
+	var plugins []connectPlugin
+	if err := json.Unmarshal(body, &plugins); err != nil {
+		return nil, fmt.Errorf("decode connector-plugins: %w", err)
+	}
+	return plugins, nil
+}
+
+func (s *Server) runCompatProbe(ctx context.Context) *compatReport {
+	rep := &compatReport{CheckedAt: time.Now(), Issues: []string{}}
+	s.probeES(ctx, rep)
+	s.probeConnect(ctx, rep)
+
+	s.compatMu.Lock()
+	s.compat = rep
+	s.compatMu.Unlock()
+
+	if rep.ok() {
+		s.logger.Printf("probe ok es=%s connect=%s", rep.ES.Version, rep.Connect.Version)
+	} else {
+		s.logger.Printf("probe issues=%q", rep.Issues)
+	}
+	return rep
+}
+
+func (s *Server) lastCompat() *compatReport {
+	s.compatMu.RLock()
+	defer s.compatMu.RUnlock()
+	return s.compat
+}
+
+/************** 健康检查 **************/
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	rep := s.lastCompat()
+	if rep == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "starting"})
+		return
+	}
+	status, code := "ok", http.StatusOK
+	if !rep.ok() {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "compat": rep})
+}
+
+func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, s.runCompatProbe(ctx))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+/************** 敏感字段脱敏 **************/
+
+const redactedValue = "********"
+
+// 未配置 redact.keys 时的默认规则（按 key 名匹配，不区分大小写）
+var defaultRedactKeys = []string{"*password*", "*secret*", "*token*", "*credentials*", "*.key", "*api*key*", "*access*key*"}
+
+type redactor struct {
+	patterns  []string
+	fragments []string // 各规则中最长的字面片段，用于快速跳过不含敏感 key 的响应
+}
+
+func newRedactor(keys []string) *redactor {
+	if len(keys) == 0 {
+		keys = defaultRedactKeys
+	}
+	r := &redactor{}
+	for _, k := range keys {
+		k = strings.ToLower(k)
+		r.patterns = append(r.patterns, k)
+		longest := ""
+		for _, f := range strings.Split(k, "*") {
+			if len(f) > len(longest) {
+				longest = f
+			}
+		}
+		r.fragments = append(r.fragments, longest)
+	}
+	return r
+}
+
+func (r *redactor) matchKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, k); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) mayContain(b []byte) bool {
+	lower := bytes.ToLower(b)
+	for _, f := range r.fragments {
+		if bytes.Contains(lower, []byte(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回值是否被改写；${provider:...} 占位符不是明文，保留以便排查
+func (r *redactor) walk(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			switch vv := val.(type) {
+			case []any:
+				// 字符串数组（如 tenancy.tenants[].tokens）逐个脱敏
+				if r.matchKey(k) {
+					for i, e := range vv {
+						if s, ok := e.(string); ok && s != "" && s != redactedValue && !configPlaceholderRe.MatchString(s) {
+							vv[i] = redactedValue
+							changed = true
+						}
+					}
+				}
+				changed = r.walk(vv) || changed
+			case map[string]any:
+				changed = r.walk(vv) || changed
+			case nil:
+			case string:
+				if r.matchKey(k) && vv != "" && vv != redactedValue && !configPlaceholderRe.MatchString(vv) {
+					t[k] = redactedValue
+					changed = true
+				}
+			default:
+				if r.matchKey(k) {
+					t[k] = redactedValue
+					changed = true
+				}
+			}
+		}
+	case []any:
+		for _, e := range t {
+			changed = r.walk(e) || changed
+		}
+	}
+	return changed
+}
+
+// JSON 响应脱敏；非 JSON 或无需改写时原样返回
+func (r *redactor) JSON(b []byte) []byte {
+	if len(b) == 0 || !r.mayContain(b) {
+		return b
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return b
+	}
+	if !r.walk(v) {
+		return b
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+// 任意值（如 Config）脱敏后输出为通用 JSON 结构
+func (r *redactor) Value(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(r.JSON(b), &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+/************** mapping 迁移：reindex 旧 backing index **************/
+
+// POST /admin/es/reindex：模板改了 mapping 之后，让历史数据也按新 mapping 索引。
+// 请求内同步完成（持有下发锁）：下发新模板（es.files.template 或请求中的 template，记入资产版本历史），
+// 然后 rollover 数据流，新写索引即用新 mapping。之后以 job 方式逐个处理选中的旧 backing index：
+//  1. 按 _simulate_index 得到的新模板 settings/mappings 创建 reindex-<index>（hidden，
+//     lifecycle.origination_date 沿用原索引创建时间，ILM 的年龄不会被重置）
+//  2. 原索引加写保护，_reindex 经 ingest pipeline 写入新索引（requests_per_second 限流，进度见 GET /admin/jobs/{id}）
+//  3. 核对文档数后用 _data_stream/_modify 把新索引换入数据流、原索引换出，默认删除原索引
+// 任何一步失败都在换入之前停止并删除未完成的新索引，数据流保持原状。仅支持 ES。
+
+const reindexPrefix = "reindex-"
+
+type reindexRequest struct {
+	Indices           []string        `json:"indices,omitempty"`             // 为空则处理 rollover 前的全部 backing index
+	Template          json.RawMessage `json:"template,omitempty"`            // 内联模板；为空则下发 es.files.template
+	Pipeline          string          `json:"pipeline,omitempty"`            // 默认 es.names.pipeline；"_none" 不经过 pipeline
+	RequestsPerSecond float64         `json:"requests_per_second,omitempty"` // 限流，0 不限
+	KeepSource        bool            `json:"keep_source,omitempty"`         // 换出后保留原索引（带写保护）
+	DryRun            bool            `json:"dry_run,omitempty"`
+}
+
+func (req *reindexRequest) pipeline(s *Server) string {
+	switch req.Pipeline {
+	case "":
+		return s.cfg.ES.Names.Pipeline
+	case "_none":
+		return ""
+	}
+	return req.Pipeline
+}
+
+// 模板作用到新 backing index 上的 settings / mappings
+func (s *Server) simulateDataStreamIndex(ctx context.Context) (map[string]any, map[string]any, error) {
+	u := fmt.Sprintf("%s/_index_template/_simulate_index/%s", s.cfg.ES.Host, url.PathEscape(s.cfg.ES.Names.DataStream))
+	resp, body, err := s.doPOST(ctx, u, nil, "es")
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("simulate index template: %s %s", resp.Status, body)
+	}
+	var doc struct {
+		Template struct {
+			Settings map[string]any `json:"settings"`
+			Mappings map[string]any `json:"mappings"`
+		} `json:"template"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("decode simulated template: %w", err)
+	}
+	if doc.Template.Settings == nil {
+		doc.Template.Settings = map[string]any{}
+	}
+	return doc.Template.Settings, doc.Template.Mappings, nil
+}
+
+// 按点分路径写入嵌套 settings（_simulate_index 返回的是 {"index":{...}} 形式）
+func setNested(m map[string]any, path string, v any) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = v
+}
+
+func (s *Server) countDocs(ctx context.Context, index string) (int64, error) {
+	resp, body, err := s.doGET(ctx, fmt.Sprintf("%s/%s/_count", s.cfg.ES.Host, url.PathEscape(index)), "es")
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count %s: %s %s", index, resp.Status, body)
+	}
+	var doc struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return 0, fmt.Errorf("decode count of %s: %w", index, err)
+	}
+	return doc.Count, nil
+}
+
+type reindexResult struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Docs    int64  `json:"docs"`
+	Deleted bool   `json:"source_deleted"`
+}
+
+// reindex 单个 backing index 并在数据流中替换它
+func (s *Server) reindexBackingIndex(ctx context.Context, j *Job, req reindexRequest, src backingIndexStats, settings, mappings map[string]any) (res reindexResult, err error) {
+	target := reindexPrefix + src.Index
+	res = reindexResult{Source: src.Index, Target: target}
+	targetURL := fmt.Sprintf("%s/%s", s.cfg.ES.Host, url.PathEscape(target))
+
+	// 上次失败留下的新索引（尚未换入数据流）直接删掉重来
+	if resp, _, err := s.doGET(ctx, targetURL, "es"); err == nil && resp.StatusCode == http.StatusOK {
+		if _, _, err := s.doDELETE(ctx, targetURL, "es"); err != nil {
+			return res, fmt.Errorf("delete leftover %s: %w", target, err)
+		}
+		j.Step("reindex-cleanup", "ok", "deleted leftover "+target)
+	}
+
+	b, _ := json.Marshal(map[string]any{"settings": settings, "mappings": mappings})
+	resp, body, err := s.doPUT(ctx, targetURL, b, "es")
+	if err != nil {
+		return res, err
+	}
+	if resp.StatusCode >= 400 {
+		return res, fmt.Errorf("create %s: %s %s", target, resp.Status, body)
+	}
+	defer func() {
+		if err != nil {
+			_, _, _ = s.doDELETE(context.Background(), targetURL, "es")
+		}
+	}()
+
+	// 写保护只加不撤：非写索引本就不再写入，ILM 的 readonly 也用同一设置
+	if err := s.putIndexSettings(ctx, src.Index, map[string]any{"index.blocks.write": true}); err != nil {
+		return res, err
+	}
+
+	dest := map[string]any{"index": target, "op_type": "create"}
+	if p := req.pipeline(s); p != "" {
+		dest["pipeline"] = p
+	}
+	b, _ = json.Marshal(map[string]any{"source": map[string]any{"index": src.Index}, "dest": dest})
+	u := s.cfg.ES.Host + "/_reindex?wait_for_completion=false"
+	if req.RequestsPerSecond > 0 {
+		u += fmt.Sprintf("&requests_per_second=%g", req.RequestsPerSecond)
+	}
+	s.logger.Printf("step=reindex source=%s target=%s pipeline=%q requests_per_second=%g", src.Index, target, req.pipeline(s), req.RequestsPerSecond)
+	resp, body, err = s.doPOST(ctx, u, b, "es")
+	if err != nil {
+		return res, err
+	}
+	if resp.StatusCode >= 400 {
+		return res, fmt.Errorf("reindex %s: %s %s", src.Index, resp.Status, body)
+	}
+	var task struct {
+		Task string `json:"task"`
+	}
+	_ = json.Unmarshal(body, &task)
+	out, err := s.waitESTask(ctx, j, task.Task)
+	if err != nil {
+		if ctx.Err() != nil && task.Task != "" {
+			// job 被取消或关机：同时取消 ES 端的 reindex 任务
+			_, _, _ = s.doPOST(context.Background(), fmt.Sprintf("%s/_tasks/%s/_cancel", s.cfg.ES.Host, url.PathEscape(task.Task)), nil, "es")
+		}
+		return res, err
+	}
+	if m, ok := out.(map[string]any); ok {
+		if failures, ok := m["failures"].([]any); ok && len(failures) > 0 {
+			return res, fmt.Errorf("reindex %s: %d failure(s), first: %v", src.Index, len(failures), failures[0])
+		}
+	}
+
+	if _, _, err := s.doPOST(ctx, targetURL+"/_refresh", nil, "es"); err != nil {
+		return res, err
+	}
+	want, err := s.countDocs(ctx, src.Index)
+	if err != nil {
+		return res, err
+	}
+	got, err := s.countDocs(ctx, target)
+	if err != nil {
+		return res, err
+	}
+	if got != want {
+		return res, fmt.Errorf("doc count mismatch: %s has %d, %s has %d (a drop processor in the pipeline?)", src.Index, want, target, got)
+	}
+	res.Docs = got
+	j.Step("reindex", "ok", fmt.Sprintf("%s -> %s (%d docs)", src.Index, target, got))
+
+	b, _ = json.Marshal(map[string]any{"actions": []any{
+		map[string]any{"remove_backing_index": map[string]string{"data_stream": s.cfg.ES.Names.DataStream, "index": src.Index}},
+		map[string]any{"add_backing_index": map[string]string{"data_stream": s.cfg.ES.Names.DataStream, "index": target}},
+	}})
+	resp, body, err = s.doPOST(ctx, s.cfg.ES.Host+"/_data_stream/_modify", b, "es")
+	if err != nil {
+		return res, err
+	}
+	if resp.StatusCode >= 400 {
+		return res, fmt.Errorf("swap %s -> %s in data stream: %s %s", src.Index, target, resp.Status, body)
+	}
+	j.Step("swap", "ok", fmt.Sprintf("%s -> %s", src.Index, target))
+
+	if req.KeepSource {
+		return res, nil
+	}
+	resp, body, err = s.doDELETE(ctx, fmt.Sprintf("%s/%s", s.cfg.ES.Host, url.PathEscape(src.Index)), "es")
+	if err != nil || resp.StatusCode >= 400 {
+		// 新索引已换入，删除失败只记录
+		j.Step("reindex-cleanup", "failed", fmt.Sprintf("delete %s: %v %s", src.Index, err, body))
+		return res, nil
+	}
+	res.Deleted = true
+	return res, nil
+}
+
+func (s *Server) runReindex(ctx context.Context, j *Job, req reindexRequest, todo []backingIndexStats) (any, error) {
+	settings, mappings, err := s.simulateDataStreamIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := []reindexResult{}
+	partial := func() map[string]any { return map[string]any{"reindexed": done} }
+	for i, src := range todo {
+		j.SetProgress("index", src.Index)
+		j.SetProgress("done", i)
+		j.SetProgress("total", len(todo))
+		// 每个索引一份 settings：origination_date 各不相同
+		b, _ := json.Marshal(settings)
+		var st map[string]any
+		_ = json.Unmarshal(b, &st)
+		setNested(st, "index.hidden", true)
+		setNested(st, "index.lifecycle.origination_date", src.Created.UnixMilli())
+		setNested(st, "index.lifecycle.indexing_complete", true) // 非写索引，ILM 跳过 rollover
+		res, err := s.reindexBackingIndex(ctx, j, req, src, st, mappings)
+		if err != nil {
+			return partial(), fmt.Errorf("%s: %w", src.Index, err)
+		}
+		done = append(done, res)
+	}
+	j.SetProgress("done", len(todo))
+	return partial(), nil
+}
+
+func (s *Server) reindexRunning() bool {
+	for _, j := range s.jobs.list() {
+		j.mu.Lock()
+		running := j.Kind == "reindex" && j.Status == jobRunning
+		j.mu.Unlock()
+		if running {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleReindex(w http.ResponseWriter, r *http.Request) {
+	var req reindexRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	if s.isOpenSearch() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "replacing data stream backing indices is not supported on OpenSearch"})
+		return
+	}
+	if req.RequestsPerSecond < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "requests_per_second must not be negative"})
+		return
+	}
+	if s.reindexRunning() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "a reindex job is already running"})
+		return
+	}
+	ctx := r.Context()
+	indices, err := s.backingIndexStats(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("reindex", err))
+		return
+	}
+	names := make([]string, 0, len(indices))
+	for _, idx := range indices {
+		names = append(names, idx.Index)
+	}
+	for _, name := range req.Indices {
+		if !slices.Contains(names, name) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s is not a backing index of %s", name, s.cfg.ES.Names.DataStream)})
+			return
+		}
+	}
+	var todo []backingIndexStats
+	for _, idx := range indices {
+		if len(req.Indices) == 0 || slices.Contains(req.Indices, idx.Index) {
+			todo = append(todo, idx)
+		}
+	}
+	if req.DryRun {
+		plan := make([]map[string]any, 0, len(todo))
+		for _, idx := range todo {
+			plan = append(plan, map[string]any{"source": idx.Index, "target": reindexPrefix + idx.Index, "docs": idx.Docs, "pri_store_bytes": idx.PriBytes})
+		}
+		tpl := "es.files.template"
+		if len(req.Template) > 0 {
+			tpl = "request"
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dry_run": true, "template": tpl, "pipeline": req.pipeline(s), "requests_per_second": req.RequestsPerSecond, "indices": plan})
+		return
+	}
+
+	out := map[string]any{"step": "reindex"}
+	// 1) 新模板版本
+	cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+	if len(req.Template) > 0 {
+		if s.applyTemplate(cw, r, "reindex", req.Template) {
+			s.recordAsset(r, assetTemplate, "", "reindex", 0, req.Template)
+		}
+	} else {
+		s.handlePutTemplate(cw, r)
+	}
+	out["template"] = jsonRaw([]byte(cw.body))
+	if cw.status >= 400 {
+		writeJSON(w, cw.status, out)
+		return
+	}
+
+	// 2) rollover：之后的写入使用新 mapping，选中的索引都不再是写索引
+	resp, body, err := s.doPOST(ctx, fmt.Sprintf("%s/%s/_rollover", s.cfg.ES.Host, s.cfg.ES.Names.DataStream), nil, "es")
+	if err != nil {
+		out["rollover"] = map[string]string{"error": err.Error()}
+		writeJSON(w, http.StatusBadGateway, out)
+		return
+	}
+	out["rollover"] = jsonRaw(body)
+	if resp.StatusCode >= 400 {
+		writeJSON(w, resp.StatusCode, out)
+		return
+	}
+
+	// 3) 后台逐个 reindex
+	params := map[string]any{"indices": names, "pipeline": req.pipeline(s), "requests_per_second": req.RequestsPerSecond, "keep_source": req.KeepSource}
+	if len(req.Indices) > 0 {
+		params["indices"] = req.Indices
+	}
+	j := s.startJob("reindex", params, func(ctx context.Context, j *Job) (any, error) {
+		j.Step("rollover", "ok", fmt.Sprintf("%d backing index(es) to reindex", len(todo)))
+		return s.runReindex(ctx, j, req, todo)
+	})
+	out["job"] = j.snapshot()
+	s.logger.Printf("step=reindex job=%s indices=%d operator=%s", j.ID, len(todo), operatorIdentity(r))
+	writeJSON(w, http.StatusAccepted, out)
+}
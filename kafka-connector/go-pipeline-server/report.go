@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/************** 部署状态报告（GET /admin/report） **************/
+
+// 执行 verify-all 与健康检查，汇总成一份可附到变更单上的报告：
+//   - 已下发的资产版本、内容 hash、下发人 / 计划与批准人
+//   - 资产文件是否有未下发的改动（文件 hash 与最近一次下发不同）
+//   - 漂移：data stream 引用的模板 / ILM 与配置不一致，Connect 上的 connector 配置与最近一次下发不同，
+//     connector 实际状态与期望状态不一致
+//   - 各 verify 检查、兼容性探测、sink / 消费延迟 / ES 健康、资产 lint
+// ?format=json（默认）| md | html；?download=true 时以附件形式返回。
+
+const (
+	driftInSync       = "in_sync"
+	driftPending      = "pending"       // 资产文件改动后尚未下发
+	driftNeverApplied = "never_applied" // 本服务没有下发记录
+	driftChanged      = "drifted"       // 集群上的实际配置与最近一次下发不同
+	driftUnknown      = "unknown"
+
+	// 整份报告的时限；verify 检查另有更短的时限，留出时间给漂移检查
+	reportTimeout       = 25 * time.Second
+	reportVerifyTimeout = 20 * time.Second
+)
+
+type reportAsset struct {
+	Kind       string       `json:"kind"`
+	Name       string       `json:"name"`
+	Version    int          `json:"version,omitempty"`
+	Hash       string       `json:"hash,omitempty"`
+	AppliedAt  time.Time    `json:"applied_at,omitzero"`
+	AppliedBy  string       `json:"applied_by,omitempty"`
+	Source     string       `json:"source,omitempty"`
+	Plan       string       `json:"plan,omitempty"`
+	ApprovedBy []string     `json:"approved_by,omitempty"`
+	FileHash   string       `json:"file_hash,omitempty"`
+	Drift      string       `json:"drift"`
+	Diffs      []ensureDiff `json:"diffs,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+type pipelineReport struct {
+	GeneratedAt  time.Time               `json:"generated_at"`
+	GeneratedBy  string                  `json:"generated_by"`
+	Environment  string                  `json:"environment,omitempty"`
+	DataStream   string                  `json:"data_stream"`
+	GitHead      string                  `json:"git_head,omitempty"`
+	OK           bool                    `json:"ok"`
+	Problems     []string                `json:"problems"`
+	Assets       []reportAsset           `json:"assets"`
+	DataStreamOK bool                    `json:"data_stream_in_sync"`
+	DataDiffs    []ensureDiff            `json:"data_stream_diffs,omitempty"`
+	Connectors   []connectStateItem      `json:"connectors"`
+	Verify       map[string]verifyResult `json:"verify"`
+	VerifyOrder  []string                `json:"-"`
+	Compat       *compatReport           `json:"compat,omitempty"`
+	Live         *liveStatus             `json:"live"`
+	Lint         []configIssue           `json:"lint"`
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ES 资产：比较资产文件与最近一次下发的原始文档
+func (s *Server) reportESAsset(ctx context.Context, kind, name, file string) reportAsset {
+	a := reportAsset{Kind: kind, Name: name, Drift: driftNeverApplied}
+	if vs := s.assets.versions(assetKey(kind, "")); len(vs) > 0 {
+		v := vs[0]
+		a.Version, a.Hash, a.AppliedAt, a.AppliedBy, a.Source, a.Plan, a.ApprovedBy = v.Version, v.Hash, v.AppliedAt, v.AppliedBy, v.Source, v.Plan, v.ApprovedBy
+	}
+	b, _, err := s.readAssetOrDefault(ctx, kind, file)
+	if err != nil {
+		a.Error, a.Drift = err.Error(), driftUnknown
+		return a
+	}
+	a.FileHash = hashHex(b)
+	if a.Hash != "" {
+		a.Drift = driftInSync
+		if a.FileHash != a.Hash {
+			a.Drift = driftPending
+		}
+	}
+	return a
+}
+
+// connect sink：比较 Connect 上的 connector 配置与最近一次下发的文档
+func (s *Server) reportSinkAsset(ctx context.Context, sc SinkConfig) (reportAsset, bool) {
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		return reportAsset{}, false
+	}
+	c, ok := p.(*connectSink)
+	if !ok {
+		return reportAsset{}, false
+	}
+	a := reportAsset{Kind: assetSink, Name: sc.Name, Drift: driftNeverApplied}
+	vs := s.assets.versions(assetKey(assetSink, sc.Name))
+	if len(vs) == 0 {
+		return a, true
+	}
+	v := vs[0]
+	a.Version, a.Hash, a.AppliedAt, a.AppliedBy, a.Source, a.Plan, a.ApprovedBy = v.Version, v.Hash, v.AppliedAt, v.AppliedBy, v.Source, v.Plan, v.ApprovedBy
+	_, applied, err := s.assets.get(assetKey(assetSink, sc.Name), v.Version)
+	var doc struct {
+		Config map[string]string `json:"config"`
+	}
+	if err == nil {
+		err = json.Unmarshal(applied, &doc)
+	}
+	if err != nil {
+		a.Error, a.Drift = err.Error(), driftUnknown
+		return a, true
+	}
+	resp, body, err := s.doGET(ctx, c.connectorURL("/config"), "connect")
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("get connector config returned %s", resp.Status)
+	}
+	var have map[string]string
+	if err == nil {
+		err = json.Unmarshal(body, &have)
+	}
+	if err != nil {
+		a.Error, a.Drift = err.Error(), driftUnknown
+		return a, true
+	}
+	a.Diffs = s.connectorConfigDiff(doc.Config, have)
+	a.Drift = driftInSync
+	if len(a.Diffs) > 0 {
+		a.Drift = driftChanged
+	}
+	return a, true
+}
+
+func (s *Server) buildReport(ctx context.Context, r *http.Request) *pipelineReport {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	// 运行状态（含 Kafka 消费延迟）与 verify 检查并发采集
+	liveDone := make(chan *liveStatus, 1)
+	go func() { liveDone <- s.collectLiveStatus(ctx) }()
+
+	rep := &pipelineReport{
+		GeneratedAt: time.Now().UTC(), GeneratedBy: operatorIdentity(r),
+		Environment: s.cfg.Approvals.Environment, DataStream: s.cfg.ES.Names.DataStream,
+		Problems: []string{}, Connectors: []connectStateItem{},
+	}
+	if s.git != nil {
+		if head, err := s.git.run(ctx, "rev-parse", "HEAD"); err == nil {
+			rep.GitHead = strings.TrimSpace(head)
+		}
+	}
+
+	vctx, vcancel := context.WithTimeout(ctx, reportVerifyTimeout)
+	ok, failed, results := s.verifyAll(vctx, r)
+	vcancel()
+	rep.Verify = results
+	for _, c := range s.verifyChecks() {
+		rep.VerifyOrder = append(rep.VerifyOrder, c.name)
+	}
+	if !ok {
+		rep.Problems = append(rep.Problems, "verify checks failed: "+strings.Join(failed, ", "))
+	}
+
+	names, files := s.cfg.ES.Names, s.cfg.ES.Files
+	rep.Assets = []reportAsset{
+		s.reportESAsset(ctx, assetILM, names.ILMPolicy, files.ILM),
+		s.reportESAsset(ctx, assetTemplate, names.IndexTemplate, files.Template),
+		s.reportESAsset(ctx, assetPipeline, names.Pipeline, files.Pipeline),
+	}
+	for _, sc := range s.sinkConfigs() {
+		if a, ok := s.reportSinkAsset(ctx, sc); ok {
+			rep.Assets = append(rep.Assets, a)
+		}
+		if it, ok := s.connectStateItem(ctx, sc); ok {
+			rep.Connectors = append(rep.Connectors, it)
+			if !it.InSync {
+				rep.Problems = append(rep.Problems, fmt.Sprintf("connector %s is %s, desired state differs (pending %s)", it.Name, it.Actual.State, it.Pending))
+			}
+		}
+	}
+	for _, a := range rep.Assets {
+		switch a.Drift {
+		case driftPending:
+			rep.Problems = append(rep.Problems, fmt.Sprintf("%s %s: asset file changed since version %d was applied", a.Kind, a.Name, a.Version))
+		case driftChanged:
+			rep.Problems = append(rep.Problems, fmt.Sprintf("%s %s: %d field(s) differ from version %d on the cluster", a.Kind, a.Name, len(a.Diffs), a.Version))
+		case driftUnknown:
+			rep.Problems = append(rep.Problems, fmt.Sprintf("%s %s: drift check failed: %s", a.Kind, a.Name, a.Error))
+		}
+	}
+
+	diffs, err := s.verifyExistingDataStream(ctx)
+	rep.DataDiffs = diffs
+	rep.DataStreamOK = err == nil && len(diffs) == 0
+	if err != nil {
+		rep.Problems = append(rep.Problems, "data stream: "+err.Error())
+	} else if len(diffs) > 0 {
+		rep.Problems = append(rep.Problems, fmt.Sprintf("data stream %s: %d reference(s) differ from the configuration", rep.DataStream, len(diffs)))
+	}
+
+	rep.Compat = s.lastCompat()
+	if rep.Compat != nil {
+		for _, is := range rep.Compat.Issues {
+			rep.Problems = append(rep.Problems, "compatibility: "+is)
+		}
+	}
+	rep.Live = <-liveDone
+	rep.Lint = s.lintAssets(ctx)
+	for _, is := range rep.Lint {
+		if is.Level == issueError {
+			rep.Problems = append(rep.Problems, fmt.Sprintf("lint %s: %s", is.Path, is.Message))
+		}
+	}
+	rep.OK = len(rep.Problems) == 0
+	return rep
+}
+
+var reportFuncs = map[string]any{
+	"short": func(h string) string {
+		if h == "" {
+			return "-"
+		}
+		if len(h) > 12 {
+			return h[:12]
+		}
+		return h
+	},
+	"ts": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+	"json": func(v any) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	},
+	"join": strings.Join,
+	// Markdown 表格单元格：转义竖线，换行改为空格
+	"cell": func(v any) string {
+		s := fmt.Sprint(v)
+		if b, ok := v.([]byte); ok {
+			s = string(b)
+		}
+		return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+	},
+}
+
+const reportMarkdown = `# Log pipeline report: {{.DataStream}}
+
+- Generated: {{ts .GeneratedAt}} by {{cell .GeneratedBy}}
+{{- if .Environment}}
+- Environment: {{.Environment}}{{end}}
+{{- if .GitHead}}
+- Asset repository HEAD: ` + "`{{.GitHead}}`" + `{{end}}
+- Result: **{{if .OK}}OK{{else}}PROBLEMS FOUND{{end}}**
+{{if .Problems}}
+## Problems
+{{range .Problems}}
+- {{cell .}}{{end}}
+{{end}}
+## Deployed assets
+
+| Kind | Name | Version | Hash | File hash | Drift | Applied at | Applied by | Plan / approved by |
+|---|---|---|---|---|---|---|---|---|
+{{range .Assets}}| {{.Kind}} | {{cell .Name}} | {{if .Version}}v{{.Version}}{{else}}-{{end}} | ` + "`{{short .Hash}}`" + ` | ` + "`{{short .FileHash}}`" + ` | {{.Drift}} | {{ts .AppliedAt}} | {{cell .AppliedBy}} | {{.Plan}}{{if .ApprovedBy}} ({{join .ApprovedBy ", "}}){{end}} |
+{{end}}
+{{- range .Assets}}{{if .Diffs}}
+Drift in {{.Kind}} {{cell .Name}}:
+{{range .Diffs}}
+- ` + "`{{cell .Field}}`" + `: deployed {{cell (json .Expected)}}, cluster {{cell (json .Actual)}}{{end}}
+{{end}}{{end}}
+## Data stream
+
+{{if .DataStreamOK}}In sync with the configured index template and ILM policy.
+{{else}}{{range .DataDiffs}}- {{.Field}}: expected {{cell (json .Expected)}}, actual {{cell (json .Actual)}}
+{{else}}Could not be checked.
+{{end}}{{end}}
+## Connectors
+
+| Name | State | Desired | In sync |
+|---|---|---|---|
+{{range .Connectors}}| {{cell .Name}} | {{.Actual.State}} | {{if .Desired}}{{.Desired.State}}{{else}}-{{end}} | {{if .InSync}}yes{{else}}no{{end}} |
+{{end}}
+## Verification
+
+| Check | Result | HTTP | Duration | Error |
+|---|---|---|---|---|
+{{range $name := .VerifyOrder}}{{with index $.Verify $name}}| {{$name}} | {{if .Skipped}}skipped{{else if .OK}}ok{{else}}FAILED{{end}} | {{.Status}} | {{.DurationMS}} ms | {{cell .Error}} |
+{{end}}{{end}}
+## Health
+{{with .Live}}
+- Sink {{cell .Sink.Name}}: {{.Sink.State}}{{if .Sink.Error}} ({{cell .Sink.Error}}){{end}}
+{{- if .Lag}}
+- Consumer lag ({{.Lag.Group}}): {{.Lag.Total}}{{if .Lag.Error}} ({{cell .Lag.Error}}){{end}}{{end}}
+- Elasticsearch: {{.ES.Status}}{{if .ES.Error}} ({{cell .ES.Error}}){{end}}{{end}}
+{{- with .Compat}}
+- ES version: {{if .ES.Version}}{{.ES.Version}}{{else}}unreachable{{end}}, Connect version: {{if .Connect.Version}}{{.Connect.Version}}{{else}}unreachable{{end}} (checked {{ts .CheckedAt}}){{end}}
+{{if .Lint}}
+## Asset lint
+{{range .Lint}}
+- {{.Level}} ` + "`{{.Path}}`" + `: {{cell .Message}}{{end}}
+{{end}}`
+
+const reportHTML = `<!doctype html>
+<html><head><meta charset="utf-8"><title>Log pipeline report: {{.DataStream}}</title>
+<style>
+body{font:14px/1.5 -apple-system,Segoe UI,sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;margin:.5em 0 1.5em}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}
+th{background:#f4f4f4}code{font-size:12px}.ok{color:#1a7f37}.bad{color:#cf222e;font-weight:bold}.muted{color:#888}
+</style></head><body>
+<h1>Log pipeline report: {{.DataStream}}</h1>
+<p>Generated {{ts .GeneratedAt}} by {{.GeneratedBy}}{{if .Environment}} &middot; environment <b>{{.Environment}}</b>{{end}}{{if .GitHead}} &middot; asset repository HEAD <code>{{.GitHead}}</code>{{end}}</p>
+<p>Result: {{if .OK}}<span class="ok">OK</span>{{else}}<span class="bad">PROBLEMS FOUND</span>{{end}}</p>
+{{if .Problems}}<h2>Problems</h2><ul>{{range .Problems}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<h2>Deployed assets</h2>
+<table><tr><th>Kind</th><th>Name</th><th>Version</th><th>Hash</th><th>File hash</th><th>Drift</th><th>Applied at</th><th>Applied by</th><th>Plan / approved by</th></tr>
+{{range .Assets}}<tr><td>{{.Kind}}</td><td>{{.Name}}</td><td>{{if .Version}}v{{.Version}}{{else}}-{{end}}</td><td><code title="{{.Hash}}">{{short .Hash}}</code></td><td><code title="{{.FileHash}}">{{short .FileHash}}</code></td>
+<td class="{{if eq .Drift "in_sync"}}ok{{else}}bad{{end}}">{{.Drift}}{{if .Error}}<br><span class="muted">{{.Error}}</span>{{end}}{{range .Diffs}}<br><code>{{.Field}}</code>: {{json .Expected}} &rarr; {{json .Actual}}{{end}}</td>
+<td>{{ts .AppliedAt}}</td><td>{{.AppliedBy}}</td><td>{{.Plan}}{{if .ApprovedBy}} ({{join .ApprovedBy ", "}}){{end}}</td></tr>
+{{end}}</table>
+<h2>Data stream</h2>
+{{if .DataStreamOK}}<p class="ok">In sync with the configured index template and ILM policy.</p>{{else}}<ul>{{range .DataDiffs}}<li>{{.Field}}: expected {{json .Expected}}, actual {{json .Actual}}</li>{{else}}<li>Could not be checked.</li>{{end}}</ul>{{end}}
+<h2>Connectors</h2>
+<table><tr><th>Name</th><th>State</th><th>Desired</th><th>In sync</th></tr>
+{{range .Connectors}}<tr><td>{{.Name}}</td><td>{{.Actual.State}}</td><td>{{if .Desired}}{{.Desired.State}}{{else}}-{{end}}</td><td class="{{if .InSync}}ok{{else}}bad{{end}}">{{if .InSync}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+<h2>Verification</h2>
+<table><tr><th>Check</th><th>Result</th><th>HTTP</th><th>Duration</th><th>Error</th></tr>
+{{range $name := .VerifyOrder}}{{with index $.Verify $name}}<tr><td>{{$name}}</td><td class="{{if .OK}}ok{{else}}bad{{end}}">{{if .Skipped}}skipped{{else if .OK}}ok{{else}}FAILED{{end}}</td><td>{{.Status}}</td><td>{{.DurationMS}} ms</td><td>{{.Error}}</td></tr>
+{{end}}{{end}}</table>
+<h2>Health</h2><ul>
+{{with .Live}}<li>Sink {{.Sink.Name}}: {{.Sink.State}}{{if .Sink.Error}} ({{.Sink.Error}}){{end}}</li>
+{{if .Lag}}<li>Consumer lag ({{.Lag.Group}}): {{.Lag.Total}}{{if .Lag.Error}} ({{.Lag.Error}}){{end}}</li>{{end}}
+<li>Elasticsearch: {{.ES.Status}}{{if .ES.Error}} ({{.ES.Error}}){{end}}</li>{{end}}
+{{with .Compat}}<li>ES version: {{if .ES.Version}}{{.ES.Version}}{{else}}unreachable{{end}}, Connect version: {{if .Connect.Version}}{{.Connect.Version}}{{else}}unreachable{{end}} (checked {{ts .CheckedAt}})</li>{{end}}
+</ul>
+{{if .Lint}}<h2>Asset lint</h2><ul>{{range .Lint}}<li>{{.Level}} <code>{{.Path}}</code>: {{.Message}}</li>{{end}}</ul>{{end}}
+</body></html>
+`
+
+var (
+	reportMarkdownTmpl = template.Must(template.New("report.md").Funcs(reportFuncs).Parse(reportMarkdown))
+	reportHTMLTmpl     = htmltemplate.Must(htmltemplate.New("report.html").Funcs(reportFuncs).Parse(reportHTML))
+)
+
+var reportContentTypes = map[string]string{
+	"json": "application/json",
+	"md":   "text/markdown; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+}
+
+func (s *Server) renderReport(rep *pipelineReport, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "json":
+		var b []byte
+		b, err = json.MarshalIndent(rep, "", "  ")
+		buf.Write(s.redact.JSON(b))
+		buf.WriteByte('\n')
+	case "md":
+		err = reportMarkdownTmpl.Execute(&buf, rep)
+	case "html":
+		err = reportHTMLTmpl.Execute(&buf, rep)
+	default:
+		err = fmt.Errorf("format must be json, md or html")
+	}
+	return buf.Bytes(), err
+}
+
+func (rep *pipelineReport) fileName(format string) string {
+	return fmt.Sprintf("pipeline-report-%s-%s.%s", rep.DataStream, rep.GeneratedAt.Format("20060102T150405Z"), format)
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	contentType, ok := reportContentTypes[format]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json, md or html"})
+		return
+	}
+	start := time.Now()
+	// 报告可能比 http.Server 的写超时更久
+	_ = http.NewResponseController(w).SetWriteDeadline(start.Add(reportTimeout + 10*time.Second))
+	rep := s.buildReport(r.Context(), r)
+	b, err := s.renderReport(rep, format)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorBody("report", err))
+		return
+	}
+	s.logger.Printf("step=report format=%s ok=%t problems=%d dur_ms=%d operator=%s", format, rep.OK, len(rep.Problems), time.Since(start).Milliseconds(), operatorIdentity(r))
+	w.Header().Set("Content-Type", contentType)
+	if r.URL.Query().Get("download") == "true" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rep.fileName(format)))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+/************** 定时投递（schedules 任务 report） **************/
+
+// params：
+//   - targets：notifications.targets 中的名字；为空时发往 events 显式包含 report 的目标
+//   - format：附件格式 html（默认）| md | json
+//   - only_on_problems：true 时报告无问题就不发送
+// 正文为结论与问题列表（Slack / 钉钉只收到正文），webhook 与邮件附带完整报告。
+// 任一目标投递失败则任务失败（并触发 job_failed 通知）。
+
+func (s *Server) reportTargets(names []string) ([]NotificationTarget, error) {
+	var out []NotificationTarget
+	for _, t := range s.cfg.Notifications.Targets {
+		if len(names) == 0 && slices.Contains(t.Events, eventReport) || slices.Contains(names, t.Name) {
+			out = append(out, t)
+		}
+	}
+	for _, n := range names {
+		if !slices.ContainsFunc(out, func(t NotificationTarget) bool { return t.Name == n }) {
+			return nil, fmt.Errorf("params.targets: notification target %q not found", n)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no notification target subscribes to %q; set params.targets or add it to a target's events", eventReport)
+	}
+	return out, nil
+}
+
+func (rep *pipelineReport) notification(attachment notificationAttachment) notification {
+	n := notification{Event: eventReport, Severity: "info", At: rep.GeneratedAt,
+		Title:  "Pipeline report for " + rep.DataStream + ": OK",
+		Text:   "All checks passed and deployed assets match the configuration.",
+		Fields: map[string]any{"data_stream": rep.DataStream, "problems": len(rep.Problems)},
+	}
+	if rep.Environment != "" {
+		n.Fields["environment"] = rep.Environment
+	}
+	if !rep.OK {
+		n.Severity = "warning"
+		n.Title = fmt.Sprintf("Pipeline report for %s: %d problem(s)", rep.DataStream, len(rep.Problems))
+		n.Text = "- " + strings.Join(rep.Problems, "\n- ")
+	}
+	if attachment.Content != "" {
+		n.Attachments = []notificationAttachment{attachment}
+	}
+	return n
+}
+
+func (s *Server) taskReport(ctx context.Context, j *Job, params map[string]any) (any, error) {
+	format := paramString(params, "format", "html")
+	contentType, ok := reportContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("params.format must be json, md or html")
+	}
+	targets, err := s.reportTargets(paramStrings(params, "targets"))
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/admin/report", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Cache-Control", "no-cache")
+	rep := s.buildReport(ctx, r)
+	j.Step("report", "ok", fmt.Sprintf("ok=%t problems=%d", rep.OK, len(rep.Problems)))
+	res := map[string]any{"ok": rep.OK, "problems": rep.Problems, "delivered": map[string]string{}}
+	if rep.OK && paramString(params, "only_on_problems", "false") == "true" {
+		j.Step("deliver", "skipped", "no problems found")
+		return res, nil
+	}
+	b, err := s.renderReport(rep, format)
+	if err != nil {
+		return res, err
+	}
+	n := rep.notification(notificationAttachment{Name: rep.fileName(format), ContentType: contentType, Content: string(b)})
+
+	delivered := res["delivered"].(map[string]string)
+	var failed []string
+	for _, t := range targets {
+		sctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := s.sendNotification(sctx, t, n)
+		cancel()
+		if err != nil {
+			s.logger.Printf("step=report deliver target=%s err=%v", t.Name, err)
+			j.Step("deliver", "failed", t.Name+": "+err.Error())
+			delivered[t.Name] = err.Error()
+			failed = append(failed, t.Name)
+			continue
+		}
+		s.logger.Printf("step=report deliver target=%s format=%s sent", t.Name, format)
+		j.Step("deliver", "ok", t.Name)
+		delivered[t.Name] = "ok"
+	}
+	if len(failed) > 0 {
+		return res, fmt.Errorf("report delivery failed: %s", strings.Join(failed, ", "))
+	}
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"strings"
+)
+
+/************** 请求日志的排除与采样 **************/
+
+// 静态资源的 requestLogger 会记录每一次下载（JS chunk、字体、source map），把 /admin 操作淹没在日志里。
+// request_log.exclude 中的路径不记录，request_log.sample 中的路径按 rate 比例记录（记录的行带 sample_rate=）；
+// 状态码 >= 400 的请求总是记录。同一规则也作用于 /admin/logs/http 请求日志流。
+// 路径模式：以 / 结尾为前缀匹配（如 /static/），否则为 path.Match 模式（如 /*.png、/static/js/*.map）。
+// 多条 sample 规则按顺序先命中者生效；/healthz、/readyz 探针始终不记录。
+
+type RequestLogConfig struct {
+	Exclude []string           `yaml:"exclude"` // 不记录的路径
+	Sample  []RequestLogSample `yaml:"sample"`  // 按比例记录的路径
+}
+
+type RequestLogSample struct {
+	Paths []string `yaml:"paths"`
+	Rate  float64  `yaml:"rate"` // 记录比例，(0, 1]
+}
+
+type requestLogRules struct {
+	exclude []string
+	sample  []RequestLogSample
+}
+
+func matchLogPath(pattern, p string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(p, pattern)
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+// 非法配置 panic（同 mustParseDuration），由 parseConfig 统一 recover
+func newRequestLogRules(cfg RequestLogConfig) *requestLogRules {
+	check := func(field, p string) {
+		if !strings.HasPrefix(p, "/") {
+			panic(fmt.Errorf("%s: path %q must start with /", field, p))
+		}
+		if _, err := path.Match(p, "/"); err != nil {
+			panic(fmt.Errorf("%s: path %q: %w", field, p, err))
+		}
+	}
+	for _, p := range cfg.Exclude {
+		check("request_log.exclude", p)
+	}
+	for i, smp := range cfg.Sample {
+		field := fmt.Sprintf("request_log.sample[%d]", i)
+		if len(smp.Paths) == 0 {
+			panic(fmt.Errorf("%s: paths is required", field))
+		}
+		if smp.Rate <= 0 || smp.Rate > 1 {
+			panic(fmt.Errorf("%s: rate must be in (0, 1], got %g", field, smp.Rate))
+		}
+		for _, p := range smp.Paths {
+			check(field+".paths", p)
+		}
+	}
+	return &requestLogRules{exclude: cfg.Exclude, sample: cfg.Sample}
+}
+
+// 是否记录该请求；rate < 1 表示按采样记录
+func (rl *requestLogRules) keep(p string, status int) (keep bool, rate float64) {
+	if rl == nil || status >= 400 {
+		return true, 1
+	}
+	for _, pat := range rl.exclude {
+		if matchLogPath(pat, p) {
+			return false, 0
+		}
+	}
+	for _, smp := range rl.sample {
+		for _, pat := range smp.Paths {
+			if matchLogPath(pat, p) {
+				return rand.Float64() < smp.Rate, smp.Rate
+			}
+		}
+	}
+	return true, 1
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/************** FAILED task 自动重启 **************/
+
+// 开启 task_restarter 后按 interval 轮询各 Connect 类 sink 的状态，connector 或 task 处于 FAILED 时自动重启：
+// 首次发现立即重启，之后的间隔从 initial_backoff 开始按 2 倍递增（不超过 max_backoff）；
+// 同一 task 在 window 内重启达到 max_restarts 次后放弃并发送 restart_gave_up 通知，恢复 RUNNING 后重新计数。
+// ES 短暂超时之类的瞬时故障因此能自愈，持续失败仍会通知到人。期望状态为 paused / absent 的 connector 不处理。
+
+const (
+	defaultRestartInterval   = 30 * time.Second
+	defaultRestartBackoff    = 30 * time.Second
+	defaultRestartMaxBackoff = 10 * time.Minute
+	defaultRestartMax        = 5
+	defaultRestartWindow     = time.Hour
+
+	connectorTaskID = -1 // connector 本身
+)
+
+type TaskRestarterConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	Interval       string `yaml:"interval"`        // 轮询间隔，默认 30s
+	InitialBackoff string `yaml:"initial_backoff"` // 第二次重启前的等待，之后每次翻倍，默认 30s
+	MaxBackoff     string `yaml:"max_backoff"`     // 默认 10m
+	MaxRestarts    int    `yaml:"max_restarts"`    // window 内最多重启次数，默认 5
+	Window         string `yaml:"window"`          // 重启次数的统计窗口，默认 1h
+}
+
+type taskRestartState struct {
+	Connector   string      `json:"connector"`
+	Task        int         `json:"task"` // -1 为 connector 本身
+	Restarts    []time.Time `json:"restarts"`
+	NextAttempt time.Time   `json:"next_attempt,omitzero"`
+	GaveUp      bool        `json:"gave_up"`
+	LastError   string      `json:"last_error,omitempty"` // 最近一次 FAILED 的 trace 首行或重启失败原因
+}
+
+type taskRestarter struct {
+	interval, backoff, maxBackoff, window time.Duration
+	max                                   int
+
+	mu    sync.Mutex
+	tasks map[string]*taskRestartState // key: connector/task
+}
+
+func newTaskRestarter(cfg TaskRestarterConfig) *taskRestarter {
+	or := func(field, v string, def time.Duration) time.Duration {
+		if d := mustParseDuration(field, v); d > 0 {
+			return d
+		}
+		return def
+	}
+	t := &taskRestarter{
+		interval:   or("task_restarter.interval", cfg.Interval, defaultRestartInterval),
+		backoff:    or("task_restarter.initial_backoff", cfg.InitialBackoff, defaultRestartBackoff),
+		maxBackoff: or("task_restarter.max_backoff", cfg.MaxBackoff, defaultRestartMaxBackoff),
+		window:     or("task_restarter.window", cfg.Window, defaultRestartWindow),
+		max:        cfg.MaxRestarts,
+		tasks:      map[string]*taskRestartState{},
+	}
+	if t.max <= 0 {
+		t.max = defaultRestartMax
+	}
+	return t
+}
+
+func restartKey(connector string, task int) string {
+	return connector + "/" + strconv.Itoa(task)
+}
+
+// 第 n 次重启（n 从 0 开始）前应等待的时长
+func (t *taskRestarter) delay(n int) time.Duration {
+	if n == 0 {
+		return 0
+	}
+	d := t.backoff
+	for i := 1; i < n && d < t.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, t.maxBackoff)
+}
+
+// 决定是否现在重启；放弃时 gaveUp=true（只在刚放弃的那一轮返回）
+func (t *taskRestarter) due(key, connector string, task int, trace string, now time.Time) (restart, gaveUp bool, st taskRestartState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.tasks[key]
+	if e == nil {
+		e = &taskRestartState{Connector: connector, Task: task}
+		t.tasks[key] = e
+	}
+	if trace != "" {
+		e.LastError = trace
+	}
+	e.Restarts = pruneBefore(e.Restarts, now.Add(-t.window))
+	switch {
+	case e.GaveUp, now.Before(e.NextAttempt):
+	case len(e.Restarts) >= t.max:
+		// 最后一次重启后又等满一个退避间隔仍 FAILED
+		e.GaveUp = true
+		gaveUp = true
+	default:
+		restart = true
+		e.Restarts = append(e.Restarts, now)
+		e.NextAttempt = now.Add(t.delay(len(e.Restarts)))
+	}
+	return restart, gaveUp, *e
+}
+
+// task 不再 FAILED：窗口内没有重启记录时清掉状态；返回之前是否已放弃
+func (t *taskRestarter) healthy(key string, now time.Time) (recovered bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.tasks[key]
+	if e == nil {
+		return false
+	}
+	recovered = e.GaveUp
+	e.GaveUp = false
+	e.Restarts = pruneBefore(e.Restarts, now.Add(-t.window))
+	if len(e.Restarts) == 0 {
+		delete(t.tasks, key)
+	}
+	return recovered
+}
+
+func (t *taskRestarter) failed(key string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.tasks[key]; e != nil {
+		e.LastError = err.Error()
+	}
+}
+
+func pruneBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	out := ts[:0]
+	for _, x := range ts {
+		if x.After(cutoff) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// Connect REST：POST /connectors/{name}/tasks/{id}/restart
+func (c *connectSink) RestartTask(ctx context.Context, id int) (*sinkResponse, error) {
+	if err := c.checkOwner(ctx, nil); err != nil {
+		return nil, err
+	}
+	url := c.connectorURL(fmt.Sprintf("/tasks/%d/restart", id))
+	c.s.logger.Printf("connect action=restart-task name=%s task=%d url=%s", c.name, id, url)
+	resp, body, err := c.s.doPOST(ctx, url, nil, "connect")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (s *Server) restartFailed(ctx context.Context, c *connectSink, task int, trace string) {
+	key := restartKey(c.name, task)
+	restart, gaveUp, st := s.restarts.due(key, c.name, task, firstLine(trace), time.Now())
+	what := fmt.Sprintf("task %d", task)
+	if task == connectorTaskID {
+		what = "connector"
+	}
+	if gaveUp {
+		s.logger.Printf("step=task-restart name=%s %s gave_up restarts=%d window=%s", c.name, what, len(st.Restarts), s.restarts.window)
+		s.notify(notification{Event: eventRestartGaveUp, Severity: "critical",
+			Title:  fmt.Sprintf("Gave up restarting %s %s", c.name, what),
+			Text:   fmt.Sprintf("Still FAILED after %d automatic restarts within %s: %s", len(st.Restarts), s.restarts.window, st.LastError),
+			Fields: map[string]any{"connector": c.name, "task": task, "restarts": len(st.Restarts)}})
+		return
+	}
+	if !restart {
+		return
+	}
+	release, err := s.acquireLock(ctx, "task-restarter", "task-restart")
+	if err != nil {
+		s.restarts.failed(key, err)
+		s.logger.Printf("step=task-restart name=%s %s lock_err=%v", c.name, what, err)
+		return
+	}
+	defer release()
+	var res *sinkResponse
+	if task == connectorTaskID {
+		res, err = c.Restart(ctx, true)
+	} else {
+		res, err = c.RestartTask(ctx, task)
+	}
+	if err == nil && res.Code >= 300 {
+		err = fmt.Errorf("%s", res.Status)
+	}
+	if err != nil {
+		s.restarts.failed(key, err)
+	}
+	s.logger.Printf("step=task-restart name=%s %s attempt=%d next_after=%s err=%v",
+		c.name, what, len(st.Restarts), st.NextAttempt.Format(time.RFC3339), err)
+}
+
+func (s *Server) checkFailedTasks(ctx context.Context) {
+	now := time.Now()
+	for _, sc := range s.sinkConfigs() {
+		p, err := s.sinkProvider(sc)
+		if err != nil {
+			continue
+		}
+		c, ok := p.(*connectSink)
+		if !ok {
+			continue
+		}
+		if want, ok := s.desired.get(c.name); ok && want.State != desiredRunning {
+			continue
+		}
+		act := c.actual(ctx)
+		if act.State == "UNKNOWN" || act.State == "ABSENT" {
+			continue
+		}
+		check := func(task int, state, trace string) {
+			key := restartKey(c.name, task)
+			if state == "FAILED" {
+				s.restartFailed(ctx, c, task, trace)
+				return
+			}
+			if s.restarts.healthy(key, now) {
+				s.logger.Printf("step=task-restart name=%s task=%d recovered state=%s", c.name, task, state)
+			}
+		}
+		check(connectorTaskID, act.State, "")
+		for _, t := range act.Tasks {
+			check(t.ID, t.State, t.Trace)
+		}
+	}
+}
+
+func (s *Server) runTaskRestarter(ctx context.Context) {
+	if !s.cfg.TaskRestarter.Enabled {
+		return
+	}
+	t := s.restarts
+	s.logger.Printf("task-restarter interval=%s initial_backoff=%s max_backoff=%s max_restarts=%d window=%s",
+		t.interval, t.backoff, t.maxBackoff, t.max, t.window)
+	tick := time.NewTicker(t.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		if s.inMaintenance("task-restarter") {
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, t.interval)
+		s.checkFailedTasks(cctx)
+		cancel()
+	}
+}
+
+func (s *Server) handleTaskRestarts(w http.ResponseWriter, r *http.Request) {
+	t := s.restarts
+	t.mu.Lock()
+	items := make([]taskRestartState, 0, len(t.tasks))
+	for _, e := range t.tasks {
+		cp := *e
+		cp.Restarts = append([]time.Time(nil), e.Restarts...)
+		items = append(items, cp)
+	}
+	t.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Connector != items[j].Connector {
+			return items[i].Connector < items[j].Connector
+		}
+		return items[i].Task < items[j].Task
+	})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":         s.cfg.TaskRestarter.Enabled,
+		"interval":        t.interval.String(),
+		"initial_backoff": t.backoff.String(),
+		"max_backoff":     t.maxBackoff.String(),
+		"max_restarts":    t.max,
+		"window":          t.window.String(),
+		"tasks":           items,
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 手动重启限速（防止重启风暴） **************/
+
+// 事故中反复点重启只会让抖动的 sink 更糟：每个 connector 的手动重启（POST /admin/connect/bulk action=restart）
+// 按 restart_guard 限速，第二次起需与上一次间隔 min_interval，之后每次翻倍；window 内满 max_restarts 次后拒绝。
+// 被拒绝时不调用 Connect，返回 429、可重试的时间与最近的失败原因（task trace 首行、自动重启记录的错误），
+// 先看原因再决定是否真的需要重启。?force=true 跳过限速。自动重启（restarter.go）有自己的退避，不计入。
+
+const (
+	defaultRestartGuardMax      = 3
+	defaultRestartGuardWindow   = 10 * time.Minute
+	defaultRestartGuardInterval = 30 * time.Second
+)
+
+type RestartGuardConfig struct {
+	MaxRestarts int    `yaml:"max_restarts"` // window 内每个 connector 最多手动重启次数，默认 3；-1 关闭限速
+	Window      string `yaml:"window"`       // 默认 10m
+	MinInterval string `yaml:"min_interval"` // 第二次重启前的最小间隔，之后每次翻倍，默认 30s
+}
+
+type restartGuard struct {
+	max              int
+	window, interval time.Duration
+
+	mu       sync.Mutex
+	restarts map[string][]time.Time // key: connector
+}
+
+func newRestartGuard(cfg RestartGuardConfig) *restartGuard {
+	g := &restartGuard{
+		max:      cfg.MaxRestarts,
+		window:   mustParseDuration("restart_guard.window", cfg.Window),
+		interval: mustParseDuration("restart_guard.min_interval", cfg.MinInterval),
+		restarts: map[string][]time.Time{},
+	}
+	if g.max == 0 {
+		g.max = defaultRestartGuardMax
+	}
+	if g.window <= 0 {
+		g.window = defaultRestartGuardWindow
+	}
+	if g.interval <= 0 {
+		g.interval = defaultRestartGuardInterval
+	}
+	return g
+}
+
+// 允许时记下这次重启并返回 0；否则返回还需等待的时长与窗口内已有的重启次数
+func (g *restartGuard) reserve(name string, now time.Time) (wait time.Duration, recent int) {
+	if g == nil || g.max < 0 {
+		return 0, 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ts := pruneBefore(g.restarts[name], now.Add(-g.window))
+	g.restarts[name] = ts
+	if n := len(ts); n > 0 {
+		next := ts[n-1].Add(g.interval * time.Duration(math.Pow(2, float64(n-1))))
+		if n >= g.max {
+			// 最早的一次滑出窗口后才能再重启
+			next = ts[n-g.max].Add(g.window)
+		}
+		if now.Before(next) {
+			return next.Sub(now), n
+		}
+	}
+	g.restarts[name] = append(ts, now)
+	return 0, len(ts)
+}
+
+// 最近的失败原因：当前 FAILED 的 connector / task 的 trace 首行，以及自动重启记录的错误
+func (s *Server) recentFailures(ctx context.Context, c *connectSink) []string {
+	var out []string
+	act := c.actual(ctx)
+	switch {
+	case act.Error != "":
+		out = append(out, "status: "+act.Error)
+	case act.State == "FAILED":
+		out = append(out, "connector: FAILED")
+	}
+	for _, t := range act.Tasks {
+		if t.State == "FAILED" {
+			out = append(out, fmt.Sprintf("task %d: %s", t.ID, firstLine(t.Trace)))
+		}
+	}
+	if s.restarts != nil {
+		s.restarts.mu.Lock()
+		for _, e := range s.restarts.tasks {
+			if e.Connector == c.name && e.LastError != "" && !strings.Contains(strings.Join(out, "\n"), e.LastError) {
+				out = append(out, fmt.Sprintf("auto-restart (%d in %s): %s", len(e.Restarts), s.restarts.window, e.LastError))
+			}
+		}
+		s.restarts.mu.Unlock()
+	}
+	return out
+}
+
+// 手动重启前调用；被限速时填好 bulkItem 并返回 false
+func (s *Server) guardRestart(ctx context.Context, c *connectSink, it *bulkItem) bool {
+	if forced(ctx) {
+		return true
+	}
+	wait, recent := s.restartGuard.reserve(c.name, time.Now())
+	if wait == 0 {
+		return true
+	}
+	wait = wait.Round(time.Second)
+	failures := s.recentFailures(ctx, c)
+	s.logger.Printf("connect action=restart name=%s refused recent=%d retry_after=%s", c.name, recent, wait)
+	it.Code = 429
+	it.Error = fmt.Sprintf("restart refused: %d restart(s) of %s within %s; retry after %s or pass force=true", recent, c.name, s.restartGuard.window, wait)
+	it.Body = map[string]any{"recent_restarts": recent, "retry_after_seconds": int(wait.Seconds()), "failures": failures}
+	return false
+}
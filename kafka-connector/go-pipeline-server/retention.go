@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** 保留期与稳态磁盘估算 **************/
+
+// GET /admin/es/retention/estimate[?lookback=7d]：用数据流 backing index 的创建时间与主分片大小算出写入速率
+// （已 rollover 的索引覆盖 [本索引创建, 下一个索引创建)，只取 lookback 内的；都没有时用写索引至今的增长），
+// 结合 ILM 的 rollover 条件与 delete min_age 估算：
+//   - rollover 间隔：max_age 与按速率填满 max_primary_shard_size / max_size / max_docs 的时间取最小值
+//   - 保留窗口：ILM 的 min_age 从 rollover 起算，所以数据保留 delete_age ~ delete_age + rollover 间隔
+//   - 稳态磁盘：速率 × (delete_age + rollover 间隔) × (1 + replicas)，即删除前的峰值
+// 并给出当前最老 backing index 的实际年龄，和集群磁盘总量对比。仅支持 ES 的 ILM。
+
+const defaultRetentionLookback = 7 * 24 * time.Hour
+
+type backingIndexStats struct {
+	Index    string    `json:"index"`
+	Created  time.Time `json:"created"`
+	Docs     int64     `json:"docs"`
+	PriBytes int64     `json:"pri_store_bytes"`
+	Bytes    int64     `json:"store_bytes"`
+	Write    bool      `json:"write_index,omitempty"`
+}
+
+type ingestRate struct {
+	Source      string  `json:"source"` // rolled_over | write_index
+	Indices     int     `json:"indices"`
+	SpanHours   float64 `json:"span_hours"`
+	BytesPerDay int64   `json:"pri_bytes_per_day"`
+	DocsPerDay  int64   `json:"docs_per_day"`
+}
+
+type rolloverPolicy struct {
+	MaxAge              string `json:"max_age,omitempty"`
+	MaxPrimaryShardSize string `json:"max_primary_shard_size,omitempty"`
+	MaxSize             string `json:"max_size,omitempty"`
+	MaxDocs             int64  `json:"max_docs,omitempty"`
+}
+
+type retentionEstimate struct {
+	Policy    string              `json:"policy"`
+	Rollover  rolloverPolicy      `json:"rollover"`
+	DeleteAge string              `json:"delete_min_age,omitempty"`
+	Shards    int                 `json:"primary_shards"`
+	Replicas  int                 `json:"replicas"`
+	Rate      *ingestRate         `json:"rate,omitempty"`
+	Indices   []backingIndexStats `json:"indices"`
+	Warnings  []string            `json:"warnings"`
+	Estimate  map[string]any      `json:"estimate"`
+	Actual    map[string]any      `json:"actual"`
+}
+
+// 线上 ILM 策略中的 rollover 条件与 delete min_age
+func (s *Server) ilmRetention(ctx context.Context) (rolloverPolicy, string, bool, error) {
+	var ro rolloverPolicy
+	resp, body, err := s.doGET(ctx, s.lifecyclePolicyURL(), "es")
+	if err != nil {
+		return ro, "", false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ro, "", false, fmt.Errorf("get ilm policy %s returned %s", s.cfg.ES.Names.ILMPolicy, resp.Status)
+	}
+	var policies map[string]struct {
+		Policy struct {
+			Phases map[string]struct {
+				MinAge  string                     `json:"min_age"`
+				Actions map[string]json.RawMessage `json:"actions"`
+			} `json:"phases"`
+		} `json:"policy"`
+	}
+	if err := json.Unmarshal(body, &policies); err != nil {
+		return ro, "", false, fmt.Errorf("decode ilm policy: %w", err)
+	}
+	p, ok := policies[s.cfg.ES.Names.ILMPolicy]
+	if !ok {
+		return ro, "", false, fmt.Errorf("ilm policy %s not found", s.cfg.ES.Names.ILMPolicy)
+	}
+	if raw, ok := p.Policy.Phases["hot"].Actions["rollover"]; ok {
+		_ = json.Unmarshal(raw, &ro)
+	}
+	del, hasDelete := p.Policy.Phases["delete"]
+	if hasDelete {
+		_, hasDelete = del.Actions["delete"]
+	}
+	minAge := del.MinAge
+	if hasDelete && minAge == "" {
+		minAge = "0ms"
+	}
+	return ro, minAge, hasDelete, nil
+}
+
+func (s *Server) backingIndexStats(ctx context.Context) ([]backingIndexStats, error) {
+	indices, err := s.dataStreamIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/_cat/indices/%s?format=json&bytes=b&h=index,creation.date,docs.count,pri.store.size,store.size", s.cfg.ES.Host, strings.Join(indices, ","))
+	resp, body, err := s.doGET(ctx, u, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cat indices returned %s", resp.Status)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("decode cat indices: %w", err)
+	}
+	byName := map[string]map[string]string{}
+	for _, row := range rows {
+		byName[row["index"]] = row
+	}
+	out := make([]backingIndexStats, 0, len(indices))
+	for i, name := range indices {
+		row, ok := byName[name]
+		if !ok {
+			continue
+		}
+		ms, _ := strconv.ParseInt(row["creation.date"], 10, 64)
+		docs, _ := strconv.ParseInt(row["docs.count"], 10, 64)
+		pri, _ := strconv.ParseInt(row["pri.store.size"], 10, 64)
+		total, _ := strconv.ParseInt(row["store.size"], 10, 64)
+		out = append(out, backingIndexStats{Index: name, Created: time.UnixMilli(ms).UTC(), Docs: docs, PriBytes: pri, Bytes: total, Write: i == len(indices)-1})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+// 写入速率：优先用 lookback 内已 rollover 的索引（时间跨度完整），没有时用写索引
+func estimateIngestRate(indices []backingIndexStats, lookback time.Duration, now time.Time) *ingestRate {
+	var span time.Duration
+	var bytes, docs int64
+	n := 0
+	for i := 0; i+1 < len(indices); i++ {
+		cur, next := indices[i], indices[i+1]
+		if next.Created.Before(now.Add(-lookback)) {
+			continue
+		}
+		if d := next.Created.Sub(cur.Created); d > 0 {
+			span += d
+			bytes += cur.PriBytes
+			docs += cur.Docs
+			n++
+		}
+	}
+	src := "rolled_over"
+	if n == 0 && len(indices) > 0 {
+		w := indices[len(indices)-1]
+		span, bytes, docs, n, src = now.Sub(w.Created), w.PriBytes, w.Docs, 1, "write_index"
+	}
+	if n == 0 || span <= 0 {
+		return nil
+	}
+	days := span.Hours() / 24
+	return &ingestRate{
+		Source:      src,
+		Indices:     n,
+		SpanHours:   math.Round(span.Hours()*10) / 10,
+		BytesPerDay: int64(float64(bytes) / days),
+		DocsPerDay:  int64(float64(docs) / days),
+	}
+}
+
+// 按 rollover 条件估算两次 rollover 的间隔；没有任何可估算的条件时返回 0
+func rolloverInterval(ro rolloverPolicy, rate *ingestRate, shards int) (time.Duration, string) {
+	var best time.Duration
+	var by string
+	consider := func(d time.Duration, name string) {
+		if d > 0 && (best == 0 || d < best) {
+			best, by = d, name
+		}
+	}
+	if d, ok := parseESDuration(ro.MaxAge); ok {
+		consider(d, "max_age")
+	}
+	if rate == nil || rate.BytesPerDay <= 0 {
+		return best, by
+	}
+	perDay := float64(rate.BytesPerDay)
+	days := func(bytes float64) time.Duration { return time.Duration(bytes / perDay * float64(24*time.Hour)) }
+	if ro.MaxPrimaryShardSize != "" && shards > 0 {
+		if b, err := parseESBytes(strings.ToLower(ro.MaxPrimaryShardSize)); err == nil {
+			consider(days(float64(b)*float64(shards)), "max_primary_shard_size")
+		}
+	}
+	if ro.MaxSize != "" {
+		if b, err := parseESBytes(strings.ToLower(ro.MaxSize)); err == nil {
+			consider(days(float64(b)), "max_size")
+		}
+	}
+	if ro.MaxDocs > 0 && rate.DocsPerDay > 0 {
+		consider(time.Duration(float64(ro.MaxDocs)/float64(rate.DocsPerDay)*float64(24*time.Hour)), "max_docs")
+	}
+	return best, by
+}
+
+func roundDays(d time.Duration) float64 {
+	return math.Round(d.Hours()/24*100) / 100
+}
+
+func (s *Server) handleRetentionEstimate(w http.ResponseWriter, r *http.Request) {
+	if s.isOpenSearch() {
+		writeJSON(w, 400, map[string]string{"error": "retention estimate reads ILM policies and is only supported on elasticsearch"})
+		return
+	}
+	lookback, lookbackRaw := defaultRetentionLookback, "7d"
+	if v := r.URL.Query().Get("lookback"); v != "" {
+		d, ok := parseESDuration(v)
+		if !ok || d <= 0 {
+			writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("invalid lookback %q (e.g. 7d, 12h)", v)})
+			return
+		}
+		lookback, lookbackRaw = d, v
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	now := time.Now()
+
+	ro, deleteAge, hasDelete, err := s.ilmRetention(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("retention-estimate", err))
+		return
+	}
+	indices, err := s.backingIndexStats(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("retention-estimate", err))
+		return
+	}
+	est := &retentionEstimate{
+		Policy:    s.cfg.ES.Names.ILMPolicy,
+		Rollover:  ro,
+		DeleteAge: deleteAge,
+		Indices:   indices,
+		Warnings:  []string{},
+		Estimate:  map[string]any{},
+		Actual:    map[string]any{},
+	}
+	if len(indices) > 0 {
+		idx, err := s.indexSettings(ctx, indices[len(indices)-1].Index)
+		if err != nil {
+			est.Warnings = append(est.Warnings, err.Error())
+		} else {
+			est.Shards, est.Replicas = atoiLoose(idx["index.number_of_shards"]), atoiLoose(idx["index.number_of_replicas"])
+		}
+	}
+
+	// 实际情况：最老 backing index 的年龄与当前占用
+	var priBytes, bytes int64
+	for _, idx := range indices {
+		priBytes += idx.PriBytes
+		bytes += idx.Bytes
+	}
+	est.Actual["pri_store_bytes"] = priBytes
+	est.Actual["store_bytes"] = bytes
+	if len(indices) > 0 {
+		oldest := indices[0]
+		est.Actual["oldest_index"] = oldest.Index
+		est.Actual["retention_days"] = roundDays(now.Sub(oldest.Created))
+	}
+
+	est.Rate = estimateIngestRate(indices, lookback, now)
+	if est.Rate == nil {
+		est.Warnings = append(est.Warnings, "not enough data to compute an ingest rate")
+	} else if est.Rate.Source == "write_index" {
+		est.Warnings = append(est.Warnings, fmt.Sprintf("no backing index rolled over within %s; rate is based on the write index only", lookbackRaw))
+	}
+
+	interval, by := rolloverInterval(ro, est.Rate, est.Shards)
+	if interval > 0 {
+		est.Estimate["rollover_interval_days"] = roundDays(interval)
+		est.Estimate["rollover_by"] = by
+	} else {
+		est.Warnings = append(est.Warnings, "cannot estimate the rollover interval from the policy's rollover conditions")
+	}
+	del, ok := parseESDuration(deleteAge)
+	switch {
+	case !hasDelete:
+		est.Warnings = append(est.Warnings, "policy has no delete phase; data is kept indefinitely and disk usage grows without bound")
+	case !ok:
+		est.Warnings = append(est.Warnings, fmt.Sprintf("cannot parse delete min_age %q", deleteAge))
+	case interval > 0:
+		est.Estimate["retention_min_days"] = roundDays(del)
+		est.Estimate["retention_max_days"] = roundDays(del + interval)
+		if est.Rate != nil {
+			priPeak := int64(float64(est.Rate.BytesPerDay) * (del + interval).Hours() / 24)
+			est.Estimate["steady_state_pri_bytes"] = priPeak
+			est.Estimate["steady_state_bytes"] = priPeak * int64(1+est.Replicas)
+		}
+		if age, ok := est.Actual["retention_days"].(float64); ok && age > roundDays(del+interval)*1.5 {
+			est.Warnings = append(est.Warnings, fmt.Sprintf("oldest backing index is %.1f days old, beyond the expected %.1f; check ILM for stuck deletes (GET /admin/verify/ilm-explain)", age, roundDays(del+interval)))
+		}
+	}
+
+	// 与集群磁盘总量对比（只看数据节点，未考虑其他索引的占用）
+	if ss, ok := est.Estimate["steady_state_bytes"].(int64); ok {
+		if rep, err := s.capacityReport(ctx); err == nil {
+			var total int64
+			for _, n := range rep.Nodes {
+				total += n.Total
+			}
+			if total > 0 {
+				pct := math.Round(float64(ss)/float64(total)*1000) / 10
+				est.Estimate["cluster_disk_bytes"] = total
+				est.Estimate["cluster_disk_percent"] = pct
+				if w, ok := rep.Watermarks[diskHigh]; ok && w.Percent > 0 && pct >= w.Percent {
+					est.Warnings = append(est.Warnings, fmt.Sprintf("steady state needs %.1f%% of cluster disk, over the high watermark (%s)", pct, w.Raw))
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, est)
+}
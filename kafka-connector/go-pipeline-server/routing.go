@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/************** 按服务路由到多个 data stream **************/
+
+// 一个 Kafka topic 里混着多个团队的日志时，按 routing.rules 分流到各自的 data stream，各自设置保留期：
+//   - values：文档 routing.field（默认 service.name）的值等于其一时路由。下发 pipeline 时在处理器末尾
+//     追加 tag 为 route:<name> 的 reroute 处理器（需 Elasticsearch 8.8+），前面的解析处理器照常执行
+//   - topics：这些 topic 由单独生成的 sink connector（<主 sink>-<name>，essink.go）直接写入该 data stream，
+//     同样经过主 pipeline；sink 参数写在 sink 段，与 sinks[].elasticsearch 相同
+// 每条规则的 data stream 默认 <es.names.data_stream>-<name>，索引模板 <es.names.index_template>-<name> 由主模板生成：
+// index_patterns 指向该 data stream、优先级比主模板高 1、index.default_pipeline 为 _none（reroute 之后不再重复执行主 pipeline）。
+// 设置 retention 时生成 ILM 策略 <es.names.ilm_policy>-<name>（主策略的 delete phase min_age 改为 retention），否则沿用主策略。
+// POST /admin/es/routes 下发各规则的 ILM 与模板（应在下发带 reroute 的 pipeline 之前执行，否则 reroute 会建出普通索引）；
+// GET /admin/es/routes 查看规则展开后的资源名与 reroute 处理器。topics 规则的 connector 与其他 sink 一样用 /admin/sinks/{name} 注册。
+
+const (
+	defaultRouteField = "service.name"
+	routeTagPrefix    = "route:"
+)
+
+type RoutingConfig struct {
+	Field string      `yaml:"field"` // values 规则比较的文档字段，默认 service.name
+	Rules []RouteRule `yaml:"rules"`
+}
+
+type RouteRule struct {
+	Name       string         `yaml:"name"`
+	Values     []string       `yaml:"values"`      // routing.field 的取值，生成 reroute 处理器
+	Topics     []string       `yaml:"topics"`      // 由单独的 sink connector 写入；与 values 二选一
+	DataStream string         `yaml:"data_stream"` // 默认 <es.names.data_stream>-<name>
+	Retention  string         `yaml:"retention"`   // 如 30d；为空时沿用主 ILM 策略
+	Sink       ESSinkSettings `yaml:"sink"`        // topics 规则的 connector 参数，data_stream 不用填
+}
+
+var routeNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func (c RoutingConfig) field() string {
+	if c.Field == "" {
+		return defaultRouteField
+	}
+	return c.Field
+}
+
+// 规则展开后的资源名
+type routeResources struct {
+	Name          string   `json:"name"`
+	DataStream    string   `json:"data_stream"`
+	IndexTemplate string   `json:"index_template"`
+	ILMPolicy     string   `json:"ilm_policy"`
+	Retention     string   `json:"retention,omitempty"`
+	Values        []string `json:"values,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	Sink          string   `json:"sink,omitempty"` // topics 规则生成的 connector
+}
+
+func (cfg *Config) routeResources(r RouteRule) routeResources {
+	names := cfg.ES.Names
+	res := routeResources{
+		Name: r.Name, DataStream: r.DataStream, IndexTemplate: names.IndexTemplate + "-" + r.Name,
+		ILMPolicy: names.ILMPolicy, Retention: r.Retention, Values: r.Values, Topics: r.Topics,
+	}
+	if res.DataStream == "" {
+		res.DataStream = names.DataStream + "-" + r.Name
+	}
+	if r.Retention != "" {
+		res.ILMPolicy = names.ILMPolicy + "-" + r.Name
+	}
+	if len(r.Topics) > 0 {
+		sink := cfg.Sink.Name
+		if sink == "" {
+			sink = cfg.Connect.Names.Sink
+		}
+		res.Sink = sink + "-" + r.Name
+	}
+	return res
+}
+
+// 解析配置时调用
+func (c RoutingConfig) validate(cfg *Config) error {
+	if len(c.Rules) == 0 {
+		return nil
+	}
+	if cfg.ES.Names.templated {
+		return fmt.Errorf("routing: not supported when es.names.data_stream is a name template")
+	}
+	seen := map[string]bool{}
+	streams := map[string]string{cfg.ES.Names.DataStream: "es.names.data_stream"}
+	for _, r := range c.Rules {
+		at := "routing.rules[" + r.Name + "]"
+		if !routeNameRe.MatchString(r.Name) {
+			return fmt.Errorf("routing.rules: invalid name %q (lowercase letters, digits and -)", r.Name)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("routing.rules: duplicate name %q", r.Name)
+		}
+		seen[r.Name] = true
+		if (len(r.Values) == 0) == (len(r.Topics) == 0) {
+			return fmt.Errorf("%s: exactly one of values or topics is required", at)
+		}
+		if len(r.Values) > 0 && r.Sink.configured() {
+			return fmt.Errorf("%s: sink only applies to topics rules", at)
+		}
+		if r.Retention != "" {
+			if _, ok := parseESDuration(r.Retention); !ok {
+				return fmt.Errorf("%s: retention %q is not a valid duration such as 30d", at, r.Retention)
+			}
+		}
+		ds := cfg.routeResources(r).DataStream
+		if prev, ok := streams[ds]; ok {
+			return fmt.Errorf("%s: data stream %s is already used by %s", at, ds, prev)
+		}
+		streams[ds] = at
+	}
+	return nil
+}
+
+// topics 规则生成的 sink，追加在 sinkConfigs() 之后
+func (s *Server) routeSinkConfigs() []SinkConfig {
+	var out []SinkConfig
+	for _, r := range s.cfg.Routing.Rules {
+		if len(r.Topics) == 0 {
+			continue
+		}
+		res := s.cfg.routeResources(r)
+		es := r.Sink
+		es.DataStream = res.DataStream
+		out = append(out, SinkConfig{Name: res.Sink, Type: sinkTypeConnect, Topics: r.Topics, Elasticsearch: es})
+	}
+	return out
+}
+
+func (s *Server) rerouteProcessors() []builderProcessor {
+	var out []builderProcessor
+	field := s.cfg.Routing.field()
+	v := painlessFieldValue(field)
+	for _, r := range s.cfg.Routing.Rules {
+		if len(r.Values) == 0 {
+			continue
+		}
+		lits := make([]string, 0, len(r.Values))
+		for _, val := range r.Values {
+			lits = append(lits, painlessString(val))
+		}
+		out = append(out, builderProcessor{Type: "reroute", Config: map[string]any{
+			"tag":         routeTagPrefix + r.Name,
+			"description": fmt.Sprintf("managed by routing.rules: %s in %v", field, r.Values),
+			"if":          fmt.Sprintf("%s != null && [%s].contains(%s.toString())", v, strings.Join(lits, ", "), v),
+			"destination": s.cfg.routeResources(r).DataStream,
+		}})
+	}
+	return out
+}
+
+// 下发 pipeline 前调用：去掉文档中已有的 route:* 处理器，在末尾追加当前规则的 reroute 处理器
+func (s *Server) applyRouting(b []byte) ([]byte, error) {
+	procs := s.rerouteProcessors()
+	if len(procs) == 0 {
+		return b, nil
+	}
+	if s.isOpenSearch() {
+		return nil, fmt.Errorf("routing.rules with values need the reroute processor (Elasticsearch 8.8+); use topics rules on OpenSearch")
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	bp, err := pipelineFromRaw(doc)
+	if err != nil {
+		return nil, err
+	}
+	bp.Processors = slices.DeleteFunc(bp.Processors, func(p builderProcessor) bool {
+		tag, _ := p.Config["tag"].(string)
+		return strings.HasPrefix(tag, routeTagPrefix)
+	})
+	bp.Processors = append(bp.Processors, procs...)
+	out := bp.raw()
+	// builderPipeline 之外的顶层字段原样保留
+	for k, v := range doc {
+		if _, ok := out[k]; !ok && k != "processors" {
+			out[k] = v
+		}
+	}
+	return json.Marshal(out)
+}
+
+// 路由规则的配置：名字换成规则的资源，其余同主配置
+func (s *Server) routeConfig(res routeResources) Config {
+	cfg := s.cfg
+	n := &cfg.ES.Names
+	n.DataStream, n.IndexTemplate, n.ILMPolicy = res.DataStream, res.IndexTemplate, res.ILMPolicy
+	n.templated, n.indexPattern = false, ""
+	cfg.Routing = RoutingConfig{}
+	return cfg
+}
+
+// 由主模板生成：指向规则的 data stream 与策略，优先级 +1，不再执行默认 pipeline
+func (s *Server) renderRouteTemplate(ctx context.Context, res routeResources) ([]byte, error) {
+	b, _, err := s.readAssetOrDefault(ctx, assetTemplate, s.cfg.ES.Files.Template)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := retargetTemplate(b, s.cfg.ES.Files.Template, res.DataStream, res.ILMPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := doc["priority"].(float64); ok {
+		doc["priority"] = int(p) + 1
+	}
+	settings := doc["template"].(map[string]any)["settings"].(map[string]any)
+	delete(settings, "default_pipeline")
+	if idx, ok := settings["index"].(map[string]any); ok {
+		delete(idx, "default_pipeline")
+	}
+	settings["index.default_pipeline"] = "_none"
+	return json.Marshal(doc)
+}
+
+func (s *Server) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := []routeResources{}
+	for _, rule := range s.cfg.Routing.Rules {
+		routes = append(routes, s.cfg.routeResources(rule))
+	}
+	procs := builderToRaw(s.rerouteProcessors())
+	writeJSON(w, http.StatusOK, map[string]any{"field": s.cfg.Routing.field(), "routes": routes, "processors": procs})
+}
+
+type routeApplyResult struct {
+	Route    string `json:"route"`
+	Step     string `json:"step"`
+	Code     int    `json:"code"`
+	Response any    `json:"response"`
+}
+
+// POST /admin/es/routes：依次下发各规则的 ILM（设置了 retention 时）与模板，遇到失败即停止
+func (s *Server) handleApplyRoutes(w http.ResponseWriter, r *http.Request) {
+	if len(s.cfg.Routing.Rules) == 0 {
+		writeJSON(w, 400, map[string]string{"error": "routing.rules is empty"})
+		return
+	}
+	ctx := optionsContext(r)
+	results := []routeApplyResult{}
+	run := func(route, step string, apply func(http.ResponseWriter) bool) bool {
+		cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+		ok := apply(cw)
+		results = append(results, routeApplyResult{Route: route, Step: step, Code: cw.status, Response: jsonRaw([]byte(cw.body))})
+		// 跳过（内容未变化）也算成功
+		return ok || cw.status < 300
+	}
+	for _, rule := range s.cfg.Routing.Rules {
+		res := s.cfg.routeResources(rule)
+		rs := s.pipelineServer(s.routeConfig(res))
+		source := "routing:" + rule.Name
+		if rule.Retention != "" {
+			b, _, err := s.readAssetOrDefault(ctx, assetILM, s.cfg.ES.Files.ILM)
+			if err == nil {
+				b, err = ilmWithRetention(b, s.cfg.ES.Files.ILM, rule.Retention)
+			}
+			if err != nil {
+				writeJSON(w, 400, map[string]any{"error": err.Error(), "route": rule.Name, "results": results})
+				return
+			}
+			if !run(rule.Name, assetILM, func(cw http.ResponseWriter) bool { return rs.applyILM(cw, r.WithContext(ctx), source, b) }) {
+				break
+			}
+		}
+		b, err := s.renderRouteTemplate(ctx, res)
+		if err != nil {
+			writeJSON(w, 400, map[string]any{"error": err.Error(), "route": rule.Name, "results": results})
+			return
+		}
+		if !run(rule.Name, assetTemplate, func(cw http.ResponseWriter) bool { return rs.applyTemplate(cw, r.WithContext(ctx), source, b) }) {
+			break
+		}
+	}
+	code := http.StatusOK
+	if last := results[len(results)-1]; last.Code >= 300 {
+		code = last.Code
+	}
+	s.logger.Printf("step=routes applied=%d code=%d", len(results), code)
+	writeJSON(w, code, map[string]any{"step": "routes", "results": results})
+}
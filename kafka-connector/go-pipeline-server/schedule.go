@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+/************** 定时维护任务（cron 风格，配置驱动） **************/
+
+const (
+	taskVerifyAll  = "verify_all" // 全量 verify，有失败项即失败
+	taskForcemerge = "forcemerge" // 对指定 ILM 阶段的只读 backing index 做 force-merge（可选 shrink）
+	taskDLQCheck   = "dlq_check"  // 统计各 connect sink 的 DLQ topic 消息数
+	taskReport     = "report"     // 生成部署状态报告并通过通知目标投递（report.go）
+
+	defaultScheduleStateFile = "schedules-state.json"
+)
+
+type ScheduleConfig struct {
+	Name     string         `yaml:"name"`
+	Cron     string         `yaml:"cron"`   // "分 时 日 月 周"，或 @hourly / @daily / @weekly / @monthly / @every 30m
+	Task     string         `yaml:"task"`   // verify_all | forcemerge | dlq_check | report
+	Params   map[string]any `yaml:"params"` // 任务参数，见各任务实现
+	Disabled bool           `yaml:"disabled"`
+}
+
+type SchedulesConfig struct {
+	StateFile string           `yaml:"state_file"` // 上次运行状态持久化文件，默认 schedules-state.json
+	Jobs      []ScheduleConfig `yaml:"jobs"`
+}
+
+type scheduledTask func(s *Server, ctx context.Context, j *Job, params map[string]any) (any, error)
+
+var scheduledTasks = map[string]scheduledTask{
+	taskVerifyAll:  (*Server).taskVerifyAll,
+	taskForcemerge: (*Server).taskForcemerge,
+	taskDLQCheck:   (*Server).taskDLQCheck,
+	taskReport:     (*Server).taskReport,
+}
+
+/************** cron 表达式 **************/
+
+// 各字段为位图；@every 时只用 every
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	every                         time.Duration
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+func parseCron(spec string) (*cronSpec, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("@every needs a duration >= 1m")
+		}
+		return &cronSpec{every: d}, nil
+	}
+	if m, ok := cronMacros[spec]; ok {
+		spec = m
+	}
+	f := strings.Fields(spec)
+	if len(f) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(f))
+	}
+	c := &cronSpec{domStar: f[2] == "*", dowStar: f[4] == "*"}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	dst := [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range f {
+		bits, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("field %d %q: %w", i+1, field, err)
+		}
+		*dst[i] = bits
+	}
+	if c.dow&(1<<7) != 0 { // 7 与 0 都表示周日
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// 支持 *、a、a-b、逗号列表与 /step
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", b)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%d-%d out of range [%d, %d]", start, end, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// 日与周同时限定时满足其一即可（与 crontab 一致）
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// 严格晚于 t 的下一次触发时间（本地时区，分钟精度）；5 年内无匹配返回零值
+func (c *cronSpec) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+/************** 调度器 **************/
+
+type scheduleState struct {
+	Name           string     `json:"name"`
+	Cron           string     `json:"cron"`
+	Task           string     `json:"task"`
+	Disabled       bool       `json:"disabled,omitempty"`
+	Running        bool       `json:"running"`
+	NextRun        *time.Time `json:"next_run,omitempty"`
+	LastRun        *time.Time `json:"last_run,omitempty"`
+	LastStatus     string     `json:"last_status,omitempty"` // succeeded | failed
+	LastError      string     `json:"last_error,omitempty"`
+	LastJobID      string     `json:"last_job_id,omitempty"`
+	LastTrigger    string     `json:"last_trigger,omitempty"` // cron | manual
+	LastDurationMS int64      `json:"last_duration_ms,omitempty"`
+	LastResult     any        `json:"last_result,omitempty"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	// 因冻结窗口跳过的最近一次（freeze.go）
+	LastSkipped    *time.Time `json:"last_skipped,omitempty"`
+	LastSkipReason string     `json:"last_skip_reason,omitempty"`
+}
+
+type scheduleEntry struct {
+	cfg   ScheduleConfig
+	spec  *cronSpec
+	state scheduleState
+}
+
+type scheduler struct {
+	mu      sync.Mutex
+	file    string
+	entries []*scheduleEntry
+}
+
+// 配置错误（cron 非法、未知任务、重名）直接 panic，与 mustParseDuration 一致
+func newScheduler(cfg SchedulesConfig) *scheduler {
+	sc := &scheduler{file: cfg.StateFile}
+	if sc.file == "" {
+		sc.file = defaultScheduleStateFile
+	}
+	seen := map[string]bool{}
+	for i, c := range cfg.Jobs {
+		field := fmt.Sprintf("schedules.jobs[%d]", i)
+		if c.Name == "" {
+			panic(fmt.Errorf("%s: name is required", field))
+		}
+		if seen[c.Name] {
+			panic(fmt.Errorf("%s: duplicate name %q", field, c.Name))
+		}
+		seen[c.Name] = true
+		if _, ok := scheduledTasks[c.Task]; !ok {
+			panic(fmt.Errorf("%s: unknown task %q", field, c.Task))
+		}
+		spec, err := parseCron(c.Cron)
+		if err != nil {
+			panic(fmt.Errorf("%s.cron: %w", field, err))
+		}
+		sc.entries = append(sc.entries, &scheduleEntry{cfg: c, spec: spec,
+			state: scheduleState{Name: c.Name, Cron: c.Cron, Task: c.Task, Disabled: c.Disabled}})
+	}
+	return sc
+}
+
+// 读取上次运行状态；文件不存在不算错误
+func (sc *scheduler) load() error {
+	b, err := os.ReadFile(sc.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []scheduleState
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("decode %s: %w", sc.file, err)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, st := range saved {
+		for _, e := range sc.entries {
+			if e.cfg.Name != st.Name {
+				continue
+			}
+			e.state.LastRun, e.state.LastStatus, e.state.LastError = st.LastRun, st.LastStatus, st.LastError
+			e.state.LastJobID, e.state.LastTrigger, e.state.LastDurationMS = st.LastJobID, st.LastTrigger, st.LastDurationMS
+			e.state.LastResult, e.state.Runs, e.state.Failures = st.LastResult, st.Runs, st.Failures
+		}
+	}
+	return nil
+}
+
+// 先写临时文件再 rename，避免进程中断留下半个文件
+func (sc *scheduler) save() error {
+	sc.mu.Lock()
+	out := make([]scheduleState, 0, len(sc.entries))
+	for _, e := range sc.entries {
+		st := e.state
+		st.Running, st.NextRun = false, nil
+		out = append(out, st)
+	}
+	sc.mu.Unlock()
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(sc.file); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := sc.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sc.file)
+}
+
+func (sc *scheduler) find(name string) (*scheduleEntry, bool) {
+	for _, e := range sc.entries {
+		if e.cfg.Name == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (sc *scheduler) snapshot() []scheduleState {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	out := make([]scheduleState, 0, len(sc.entries))
+	for _, e := range sc.entries {
+		out = append(out, e.state)
+	}
+	return out
+}
+
+var errScheduleRunning = errors.New("previous run is still in progress")
+
+// 以 job 形式运行一次；上一次尚未结束时跳过，避免重叠
+func (s *Server) triggerSchedule(e *scheduleEntry, trigger string) (*Job, error) {
+	s.sched.mu.Lock()
+	if e.state.Running {
+		s.sched.mu.Unlock()
+		return nil, errScheduleRunning
+	}
+	e.state.Running = true
+	s.sched.mu.Unlock()
+
+	task := scheduledTasks[e.cfg.Task]
+	params := map[string]any{"schedule": e.cfg.Name, "task": e.cfg.Task, "trigger": trigger}
+	if len(e.cfg.Params) > 0 {
+		params["params"] = e.cfg.Params
+	}
+	return s.startJob("schedule", params, func(ctx context.Context, j *Job) (any, error) {
+		start := time.Now()
+		res, err := task(s, ctx, j, e.cfg.Params)
+
+		s.sched.mu.Lock()
+		st := &e.state
+		st.Running = false
+		st.LastRun, st.LastJobID, st.LastTrigger = &start, j.ID, trigger
+		st.LastDurationMS = time.Since(start).Milliseconds()
+		st.LastResult = res
+		st.Runs++
+		if err != nil {
+			st.LastStatus, st.LastError = jobFailed, err.Error()
+			st.Failures++
+		} else {
+			st.LastStatus, st.LastError = jobSucceeded, ""
+		}
+		s.sched.mu.Unlock()
+		if serr := s.sched.save(); serr != nil {
+			s.logger.Printf("schedule name=%s save_state_err=%v", e.cfg.Name, serr)
+		}
+		return res, err
+	}), nil
+}
+
+func (s *Server) runScheduler(ctx context.Context) {
+	if len(s.sched.entries) == 0 {
+		return
+	}
+	now := time.Now()
+	s.sched.mu.Lock()
+	for _, e := range s.sched.entries {
+		if !e.cfg.Disabled {
+			next := e.spec.next(now)
+			e.state.NextRun = &next
+		}
+	}
+	s.sched.mu.Unlock()
+	s.logger.Printf("scheduler jobs=%d state_file=%s", len(s.sched.entries), s.sched.file)
+
+	for {
+		// 最长睡 1 分钟，系统时间跳变后也能及时纠正
+		now := time.Now()
+		wake := now.Add(time.Minute)
+		for _, e := range s.sched.entries {
+			s.sched.mu.Lock()
+			next := e.state.NextRun
+			s.sched.mu.Unlock()
+			if next == nil || next.IsZero() {
+				continue
+			}
+			if !next.After(now) {
+				if m, on := s.maintenance.active(now); on {
+					s.logger.Printf("schedule name=%s skipped: %s", e.cfg.Name, m.message())
+					s.sched.mu.Lock()
+					e.state.LastSkipped, e.state.LastSkipReason = &now, m.message()
+					s.sched.mu.Unlock()
+				} else if p, frozen := s.freezeSkips(e.cfg.Task, now); frozen {
+					s.logger.Printf("schedule name=%s skipped: %s", e.cfg.Name, p.message())
+					s.sched.mu.Lock()
+					e.state.LastSkipped, e.state.LastSkipReason = &now, p.message()
+					s.sched.mu.Unlock()
+				} else if _, err := s.triggerSchedule(e, "cron"); err != nil {
+					s.logger.Printf("schedule name=%s skipped: %v", e.cfg.Name, err)
+				}
+				n := e.spec.next(now)
+				s.sched.mu.Lock()
+				e.state.NextRun = &n
+				s.sched.mu.Unlock()
+				next = &n
+			}
+			if next.Before(wake) {
+				wake = *next
+			}
+		}
+		t := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.sched.snapshot())
+}
+
+// 手动触发一次（不影响下一次 cron 时间）
+func (s *Server) handleRunSchedule(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.sched.find(r.PathValue("name"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "schedule not found"})
+		return
+	}
+	// 会改动集群的任务在冻结期间同样拒绝（管理员可覆盖）
+	if freezeMutatingTasks[e.cfg.Task] && !s.checkFreeze(w, r) {
+		return
+	}
+	j, err := s.triggerSchedule(e, "manual")
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+/************** 任务实现 **************/
+
+func paramString(params map[string]any, key, def string) string {
+	if v, ok := params[key]; ok {
+		if s := fmt.Sprint(v); s != "" {
+			return s
+		}
+	}
+	return def
+}
+
+// 支持 YAML 列表或逗号分隔字符串
+func paramStrings(params map[string]any, key string) []string {
+	var out []string
+	switch v := params[key].(type) {
+	case []any:
+		for _, x := range v {
+			out = append(out, fmt.Sprint(x))
+		}
+	case string:
+		for _, x := range strings.Split(v, ",") {
+			if x = strings.TrimSpace(x); x != "" {
+				out = append(out, x)
+			}
+		}
+	}
+	return out
+}
+
+func paramInt(params map[string]any, key string, def int) (int, error) {
+	v, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case float64:
+		return int(n), nil
+	case string:
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("params.%s: %w", key, err)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("params.%s must be an integer", key)
+}
+
+func (s *Server) taskVerifyAll(ctx context.Context, j *Job, _ map[string]any) (any, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/admin/verify/all", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Cache-Control", "no-cache")
+	ok, failed, results := s.verifyAll(ctx, r)
+	res := map[string]any{"ok": ok, "failed": failed, "checks": results}
+	if !ok {
+		j.Step("verify", "failed", strings.Join(failed, ","))
+		return res, fmt.Errorf("verify failed: %s", strings.Join(failed, ", "))
+	}
+	j.Step("verify", "ok", "")
+	return res, nil
+}
+
+type dlqSize struct {
+	Sink     string `json:"sink"`
+	Topic    string `json:"topic"`
+	Messages int64  `json:"messages"`
+	Error    string `json:"error,omitempty"`
+}
+
+// connect sink 配置文件中的 errors.deadletterqueue.topic.name
+func (s *Server) sinkDLQTopics() map[string]string {
+	out := map[string]string{}
+	for _, sc := range s.sinkConfigs() {
+		if sc.Type != sinkTypeConnect {
+			continue
+		}
+		b, err := s.readSinkAsset(context.Background(), sc)
+		if err != nil {
+			continue
+		}
+		var sink struct {
+			Config map[string]any `json:"config"`
+		}
+		if json.Unmarshal(b, &sink) != nil {
+			continue
+		}
+		if t, _ := sink.Config["errors.deadletterqueue.topic.name"].(string); t != "" {
+			out[sc.Name] = t
+		}
+	}
+	return out
+}
+
+// params.max_messages：任一 DLQ 超过该值即失败（触发 job_failed 通知）；0 只统计
+func (s *Server) taskDLQCheck(ctx context.Context, j *Job, params map[string]any) (any, error) {
+	limit, err := paramInt(params, "max_messages", 0)
+	if err != nil {
+		return nil, err
+	}
+	topics := s.sinkDLQTopics()
+	if len(topics) == 0 {
+		j.Step("dlq", "skipped", "no connect sink has a dead letter queue topic")
+		return map[string]any{"dlq": []dlqSize{}}, nil
+	}
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	sizes := []dlqSize{}
+	var over, failed []string
+	for sink, topic := range topics {
+		d := dlqSize{Sink: sink, Topic: topic}
+		starts, err := adm.ListStartOffsets(ctx, topic)
+		if err == nil {
+			err = starts.Error()
+		}
+		var ends kadm.ListedOffsets
+		if err == nil {
+			if ends, err = adm.ListEndOffsets(ctx, topic); err == nil {
+				err = ends.Error()
+			}
+		}
+		switch {
+		case errors.Is(err, kerr.UnknownTopicOrPartition):
+			// DLQ topic 在第一条错误消息写入时才创建
+		case err != nil:
+			d.Error = err.Error()
+			failed = append(failed, topic)
+		default:
+			for p, eo := range ends[topic] {
+				d.Messages += eo.Offset - starts[topic][p].Offset
+			}
+		}
+		if limit > 0 && d.Messages > int64(limit) {
+			over = append(over, fmt.Sprintf("%s=%d", topic, d.Messages))
+		}
+		sizes = append(sizes, d)
+		j.Step("dlq", "ok", fmt.Sprintf("%s messages=%d", topic, d.Messages))
+	}
+	res := map[string]any{"dlq": sizes, "max_messages": limit}
+	if len(failed) > 0 {
+		return res, fmt.Errorf("read dead letter queue offsets failed: %s", strings.Join(failed, ", "))
+	}
+	if len(over) > 0 {
+		return res, fmt.Errorf("dead letter queue over %d messages: %s", limit, strings.Join(over, ", "))
+	}
+	return res, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+/************** 部署向导状态 **************/
+
+// 前端向导的进度保存在服务端（<assets.dir>/setup-state.json），刷新页面或换人操作看到的是同一份进度。
+// 每条 pipeline（默认 es.names.data_stream，?pipeline= 指定）按 setupSteps 的顺序推进，
+// 标记 done / skipped 前要求前置步骤已完成，如模板之前不能标记 data stream 已创建；
+// verify 标记 done 时会实际执行一次 verify/all，全部通过才接受。
+// 置回 pending 时依赖它的步骤一并置回。对应的下发接口（ILM / pipeline / 模板 / data stream / sink）
+// 成功后自动把默认 pipeline 的该步骤记为 done。
+// 并发：PUT 可带 version（GET 返回的值），不一致返回 409，避免两个人同时推进时互相覆盖。
+
+const (
+	setupStateFile = "setup-state.json"
+
+	setupPending = "pending"
+	setupDone    = "done"
+	setupSkipped = "skipped"
+)
+
+type setupStep struct {
+	Name     string
+	Requires []string
+}
+
+var setupSteps = []setupStep{
+	{Name: "ilm"},
+	{Name: "pipeline"},
+	{Name: "template", Requires: []string{"ilm"}},
+	{Name: "data_stream", Requires: []string{"template"}},
+	{Name: "sink", Requires: []string{"data_stream", "pipeline"}},
+	{Name: "verify", Requires: []string{"sink"}},
+}
+
+func findSetupStep(name string) (setupStep, bool) {
+	for _, st := range setupSteps {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return setupStep{}, false
+}
+
+type setupStepState struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+	By     string    `json:"by,omitempty"`
+	Note   string    `json:"note,omitempty"`
+}
+
+type setupPipelineState struct {
+	Version int                        `json:"version"`
+	Steps   map[string]*setupStepState `json:"steps"`
+}
+
+type setupStore struct {
+	mu        sync.Mutex
+	file      string
+	pipelines map[string]*setupPipelineState
+}
+
+func newSetupStore(cfg AssetsConfig) *setupStore {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultAssetsDir
+	}
+	return &setupStore{file: filepath.Join(dir, setupStateFile), pipelines: map[string]*setupPipelineState{}}
+}
+
+func (st *setupStore) load() error {
+	b, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := json.Unmarshal(b, &st.pipelines); err != nil {
+		return fmt.Errorf("decode %s: %w", st.file, err)
+	}
+	return nil
+}
+
+func (st *setupStore) saveLocked() error {
+	b, err := json.MarshalIndent(st.pipelines, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), 0o755); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+func (st *setupStore) pipelineLocked(name string) *setupPipelineState {
+	p, ok := st.pipelines[name]
+	if !ok {
+		p = &setupPipelineState{Steps: map[string]*setupStepState{}}
+		st.pipelines[name] = p
+	}
+	if p.Steps == nil {
+		p.Steps = map[string]*setupStepState{}
+	}
+	return p
+}
+
+func (p *setupPipelineState) status(step string) string {
+	if e, ok := p.Steps[step]; ok {
+		return e.Status
+	}
+	return setupPending
+}
+
+func (p *setupPipelineState) finished(step string) bool {
+	s := p.status(step)
+	return s == setupDone || s == setupSkipped
+}
+
+// 未完成的前置步骤
+func (p *setupPipelineState) missing(st setupStep) []string {
+	var out []string
+	for _, req := range st.Requires {
+		if !p.finished(req) {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// 直接或间接依赖 step 的步骤
+func setupDependents(step string) []string {
+	out := []string{}
+	for _, st := range setupSteps {
+		for _, req := range st.Requires {
+			if req == step || slices.Contains(out, req) {
+				out = append(out, st.Name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+type setupStepView struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Requires []string `json:"requires"`
+	Ready    bool     `json:"ready"` // 前置步骤均已完成
+	At       string   `json:"at,omitempty"`
+	By       string   `json:"by,omitempty"`
+	Note     string   `json:"note,omitempty"`
+}
+
+func (st *setupStore) viewLocked(name string) map[string]any {
+	p := st.pipelineLocked(name)
+	steps := make([]setupStepView, 0, len(setupSteps))
+	next := ""
+	for _, step := range setupSteps {
+		v := setupStepView{Name: step.Name, Status: p.status(step.Name), Requires: step.Requires, Ready: len(p.missing(step)) == 0}
+		if v.Requires == nil {
+			v.Requires = []string{}
+		}
+		if e, ok := p.Steps[step.Name]; ok {
+			v.At, v.By, v.Note = e.At.Format(time.RFC3339), e.By, e.Note
+		}
+		if next == "" && v.Ready && v.Status == setupPending {
+			next = step.Name
+		}
+		steps = append(steps, v)
+	}
+	return map[string]any{"pipeline": name, "version": p.Version, "steps": steps, "next": next, "complete": next == "" && p.finished(setupSteps[len(setupSteps)-1].Name)}
+}
+
+func (s *Server) setupPipelineName(r *http.Request, fromBody string) string {
+	if fromBody != "" {
+		return fromBody
+	}
+	if p := r.URL.Query().Get("pipeline"); p != "" {
+		return p
+	}
+	return s.cfg.ES.Names.DataStream
+}
+
+// GET /admin/setup/state[?pipeline=]
+func (s *Server) handleGetSetupState(w http.ResponseWriter, r *http.Request) {
+	name := s.setupPipelineName(r, "")
+	s.setup.mu.Lock()
+	defer s.setup.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.setup.viewLocked(name))
+}
+
+type setupStateRequest struct {
+	Pipeline string `json:"pipeline,omitempty"`
+	Step     string `json:"step"`
+	Status   string `json:"status"` // done | skipped | pending
+	Note     string `json:"note,omitempty"`
+	Version  *int   `json:"version,omitempty"` // 期望的当前版本，不一致返回 409
+}
+
+// PUT /admin/setup/state {"step":"template","status":"done","version":3}
+func (s *Server) handlePutSetupState(w http.ResponseWriter, r *http.Request) {
+	var req setupStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w, err)
+		return
+	}
+	step, ok := findSetupStep(req.Step)
+	if !ok {
+		names := make([]string, 0, len(setupSteps))
+		for _, st := range setupSteps {
+			names = append(names, st.Name)
+		}
+		writeJSON(w, 400, map[string]any{"error": fmt.Sprintf("unknown step %q", req.Step), "steps": names})
+		return
+	}
+	if !slices.Contains([]string{setupDone, setupSkipped, setupPending}, req.Status) {
+		writeJSON(w, 400, map[string]string{"error": fmt.Sprintf("unknown status %q (done | skipped | pending)", req.Status)})
+		return
+	}
+	name := s.setupPipelineName(r, req.Pipeline)
+
+	// verify 需要实际通过校验；在持锁前执行，避免长时间阻塞其他人读取进度
+	if step.Name == "verify" && req.Status == setupDone {
+		if name != s.cfg.ES.Names.DataStream {
+			writeJSON(w, 400, map[string]string{"error": "verify can only be run for the configured pipeline " + s.cfg.ES.Names.DataStream})
+			return
+		}
+		if ok, failed, _ := s.verifyAll(r.Context(), r); !ok {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": "verify/all did not pass", "failed": failed})
+			return
+		}
+	}
+
+	s.setup.mu.Lock()
+	defer s.setup.mu.Unlock()
+	p := s.setup.pipelineLocked(name)
+	if req.Version != nil && *req.Version != p.Version {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error": fmt.Sprintf("setup state of %s changed (version %d, expected %d); reload and retry", name, p.Version, *req.Version),
+			"state": s.setup.viewLocked(name),
+		})
+		return
+	}
+	var reset []string
+	now, by := time.Now().UTC(), operatorIdentity(r)
+	switch req.Status {
+	case setupDone, setupSkipped:
+		if missing := p.missing(step); len(missing) > 0 {
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"error":   fmt.Sprintf("step %s requires %v to be done or skipped first", step.Name, missing),
+				"missing": missing,
+			})
+			return
+		}
+	case setupPending:
+		for _, dep := range setupDependents(step.Name) {
+			if p.finished(dep) {
+				p.Steps[dep] = &setupStepState{Status: setupPending, At: now, By: by, Note: "reset: " + step.Name + " set back to pending"}
+				reset = append(reset, dep)
+			}
+		}
+	}
+	p.Steps[step.Name] = &setupStepState{Status: req.Status, At: now, By: by, Note: req.Note}
+	p.Version++
+	if err := s.setup.saveLocked(); err != nil {
+		writeJSON(w, 500, errorBody("setup-state", err))
+		return
+	}
+	s.logger.Printf("step=setup-state pipeline=%s set=%s status=%s reset=%v operator=%s", name, step.Name, req.Status, reset, by)
+	out := s.setup.viewLocked(name)
+	if len(reset) > 0 {
+		out["reset"] = reset
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// 路由包装：下发接口成功（2xx）后把默认 pipeline 的对应步骤记为 done；不检查前置步骤，以实际结果为准
+func (s *Server) trackSetupStep(step string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if rec.status == 0 || rec.status >= 300 {
+			return
+		}
+		s.setup.mu.Lock()
+		defer s.setup.mu.Unlock()
+		p := s.setup.pipelineLocked(s.cfg.ES.Names.DataStream)
+		if p.status(step) == setupDone {
+			return
+		}
+		p.Steps[step] = &setupStepState{Status: setupDone, At: time.Now().UTC(), By: operatorIdentity(r), Note: "recorded from " + r.Method + " " + r.URL.Path}
+		p.Version++
+		if err := s.setup.saveLocked(); err != nil {
+			s.logger.Printf("step=setup-state name=%s save_err=%v", step, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/************** 关机与平滑重启 **************/
+
+// SIGINT / SIGTERM：readyz 先转 503，等待进行中的 job 与下发锁（shutdown.job_timeout）；
+// 然后停止后台子系统（reconciler、调度器等，等其完成当前一轮）、中断仍未结束的 job（已完成步骤保留），
+// 最后关闭 HTTP（shutdown.drain_timeout 内等待在途请求）、gRPC 与 Kafka 客户端，并打一条汇总日志。
+// SIGHUP 与配置变更：同样等待后 re-exec，监听 socket 以 fd 形式交给新进程——
+// 切换期间新连接在内核 backlog 中排队，不会被拒绝；二进制已替换时即完成升级。
+
+const (
+	defaultDrainTimeout = 10 * time.Second
+	defaultJobTimeout   = 5 * time.Minute
+
+	// 交接给新进程的监听 fd，格式 addr=fd,addr=fd
+	inheritFDsEnv = "PIPELINE_LISTEN_FDS"
+)
+
+type ShutdownConfig struct {
+	DrainTimeout string `yaml:"drain_timeout"` // 等待在途 HTTP 请求结束，默认 10s
+	JobTimeout   string `yaml:"job_timeout"`   // 等待进行中的 job / 下发锁，默认 5m；超时仍继续关机
+}
+
+func (c ShutdownConfig) timeouts() (drain, jobs time.Duration) {
+	drain, jobs = mustParseDuration("shutdown.drain_timeout", c.DrainTimeout), mustParseDuration("shutdown.job_timeout", c.JobTimeout)
+	if drain <= 0 {
+		drain = defaultDrainTimeout
+	}
+	if jobs <= 0 {
+		jobs = defaultJobTimeout
+	}
+	return drain, jobs
+}
+
+// 上一个进程交接过来的监听 fd（读取后清掉环境变量，避免再传给子进程）
+func inheritedFDs() map[string]int {
+	raw := os.Getenv(inheritFDsEnv)
+	_ = os.Unsetenv(inheritFDsEnv)
+	out := map[string]int{}
+	for _, kv := range strings.Split(raw, ",") {
+		addr, fd, ok := strings.Cut(kv, "=")
+		if n, err := strconv.Atoi(fd); ok && err == nil {
+			out[addr] = n
+		}
+	}
+	return out
+}
+
+// 优先复用交接过来的 socket，否则新建监听
+func listenInherited(addr string, inherited map[string]int) (net.Listener, bool, error) {
+	if fd, ok := inherited[addr]; ok {
+		f := os.NewFile(uintptr(fd), addr)
+		defer f.Close()
+		ln, err := net.FileListener(f)
+		return ln, true, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	return ln, false, err
+}
+
+// 复制监听 fd（dup 出来的 fd 不带 CLOEXEC，exec 后仍然有效），返回交给新进程的环境变量值；
+// 之后 http.Server.Shutdown 关闭原 fd 也不影响这些副本
+func handoffListeners(lns map[string]net.Listener) (string, error) {
+	var parts []string
+	for addr, ln := range lns {
+		tl, ok := ln.(*net.TCPListener)
+		if !ok {
+			return "", fmt.Errorf("listener %s is not TCP", addr)
+		}
+		rc, err := tl.SyscallConn()
+		if err != nil {
+			return "", err
+		}
+		var dupFD int
+		var dupErr error
+		if err := rc.Control(func(fd uintptr) { dupFD, dupErr = syscall.Dup(int(fd)) }); err != nil {
+			return "", err
+		}
+		if dupErr != nil {
+			return "", fmt.Errorf("dup listener %s: %w", addr, dupErr)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%d", addr, dupFD))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// 等待进行中的 job 与下发锁释放，超时或 ctx 取消时返回剩余数量
+func (s *Server) waitIdle(ctx context.Context, timeout time.Duration) (jobs, locks int) {
+	deadline := time.Now().Add(timeout)
+	for {
+		jobs, locks = s.jobs.running(), s.locks.heldCount()
+		if (jobs == 0 && locks == 0) || time.Now().After(deadline) {
+			return jobs, locks
+		}
+		select {
+		case <-ctx.Done():
+			return jobs, locks
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// 后台子系统（operator reconciler、调度器、状态推送、告警、配置监听）：关机时先取消再等待退出
+type backgroundSet struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	running map[string]bool
+}
+
+func newBackgroundSet() *backgroundSet { return &backgroundSet{running: map[string]bool{}} }
+
+func (b *backgroundSet) start(name string, fn func()) {
+	b.mu.Lock()
+	b.running[name] = true
+	b.mu.Unlock()
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+		b.mu.Lock()
+		delete(b.running, name)
+		b.mu.Unlock()
+	}()
+}
+
+// 等待全部退出，超时返回仍在运行的子系统
+func (b *backgroundSet) wait(timeout time.Duration) []string {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]string, 0, len(b.running))
+	for name := range b.running {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var errShutdown = errors.New("server shutting down")
+
+// 取消仍在运行的 job（已完成的步骤保留在 job 记录中），等待其返回；返回被中断的 job
+func (s *Server) interruptJobs(timeout time.Duration) []string {
+	var ids []string
+	for _, j := range s.jobs.list() {
+		j.mu.Lock()
+		if j.Status == jobRunning {
+			ids = append(ids, fmt.Sprintf("%s(%s)", j.ID, j.Kind))
+			j.Steps = append(j.Steps, JobStep{Name: "shutdown", Status: "interrupted", Detail: errShutdown.Error(), At: time.Now()})
+			j.cancel(errShutdown)
+		}
+		j.mu.Unlock()
+	}
+	if len(ids) > 0 {
+		deadline := time.Now().Add(timeout)
+		for s.jobs.running() > 0 && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return ids
+}
+
+// 关机汇总日志
+type shutdownSummary struct {
+	start         time.Time
+	reason        string
+	waitedJobs    int
+	waitedLocks   int
+	bgTimedOut    []string
+	jobsCancelled []string
+	locksHeld     int
+	httpErrors    []string
+}
+
+func (s *Server) logShutdownSummary(sum *shutdownSummary) {
+	s.logger.Printf("shutdown summary reason=%s dur_ms=%d jobs_after_wait=%d locks_after_wait=%d background_not_stopped=%v jobs_interrupted=%v locks_still_held=%d http_errors=%v",
+		sum.reason, time.Since(sum.start).Milliseconds(), sum.waitedJobs, sum.waitedLocks, sum.bgTimedOut, sum.jobsCancelled, sum.locksHeld, sum.httpErrors)
+}
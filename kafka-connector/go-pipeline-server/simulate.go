@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/************** 索引模板模拟（POST /admin/es/template/simulate） **************/
+
+// 下发模板之前查看新 backing index 实际会得到的 settings / mappings / aliases（合并 composed_of 组件模板之后）。
+// 请求体为模板文档时模拟该文档，空请求体时模拟配置的模板文件（同 POST /admin/es/template，支持 ?ref=）；
+// 两者都先经过与下发相同的处理（index_patterns 对齐、降采样叠加、归属标记）。
+// 返回 ES _index_template/_simulate 的结果、settings / mappings 与当前数据流新 backing index（_simulate_index）相比的变化，
+// 以及 overlapping（同样匹配、优先级更低而被覆盖的模板）。不改动集群，冻结窗口内也可调用。
+
+// 嵌套对象展开为点分路径；数组与标量为叶子
+func flattenJSON(prefix string, v any, out map[string]any) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		if prefix != "" {
+			out[prefix] = v
+		}
+		return
+	}
+	for k, child := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flattenJSON(key, child, out)
+	}
+}
+
+// expected 为模拟结果，actual 为当前值
+func flatDiff(prefix string, want, have any) []ensureDiff {
+	w, h := map[string]any{}, map[string]any{}
+	flattenJSON(prefix, want, w)
+	flattenJSON(prefix, have, h)
+	keys := map[string]bool{}
+	for k := range w {
+		keys[k] = true
+	}
+	for k := range h {
+		keys[k] = true
+	}
+	diffs := []ensureDiff{}
+	for k := range keys {
+		wv, wok := w[k]
+		hv, hok := h[k]
+		wb, _ := json.Marshal(wv)
+		hb, _ := json.Marshal(hv)
+		if wok == hok && string(wb) == string(hb) {
+			continue
+		}
+		d := ensureDiff{Field: k}
+		if wok {
+			d.Expected = wv
+		}
+		if hok {
+			d.Actual = hv
+		}
+		diffs = append(diffs, d)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs
+}
+
+// 模拟结果里每次都会变的字段（创建时间、uuid 等）不参与比较
+func dropVolatileSettings(settings map[string]any) {
+	idx, _ := settings["index"].(map[string]any)
+	for _, k := range []string{"creation_date", "uuid", "provided_name", "version"} {
+		delete(idx, k)
+	}
+}
+
+func (s *Server) handleSimulateTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := optionsContext(r)
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+	source := "request"
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		if raw, source, err = s.readAssetOrDefault(ctx, assetTemplate, s.cfg.ES.Files.Template); err != nil {
+			writeJSON(w, 400, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	b, err := s.alignTemplatePatterns(raw)
+	if err == nil {
+		b, err = s.prepareIndexTemplate(b)
+	}
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	u := s.cfg.ES.Host + "/_index_template/_simulate"
+	s.logger.Printf("step=template-simulate url=%s source=%s size=%d", u, source, len(b))
+	resp, body, err := s.doPOST(ctx, u, b, "es")
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("template-simulate", err))
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		writeJSON(w, resp.StatusCode, map[string]any{"step": "template-simulate", "error": "elasticsearch rejected the template", "body": jsonRaw(body)})
+		return
+	}
+	var sim struct {
+		Template struct {
+			Settings map[string]any `json:"settings"`
+			Mappings map[string]any `json:"mappings"`
+			Aliases  map[string]any `json:"aliases"`
+		} `json:"template"`
+		Overlapping []struct {
+			Name          string   `json:"name"`
+			IndexPatterns []string `json:"index_patterns"`
+		} `json:"overlapping"`
+	}
+	if err := json.Unmarshal(body, &sim); err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("template-simulate", fmt.Errorf("decode simulate response: %w", err)))
+		return
+	}
+	dropVolatileSettings(sim.Template.Settings)
+	out := map[string]any{
+		"step":        "template-simulate",
+		"source":      source,
+		"template":    s.cfg.ES.Names.IndexTemplate,
+		"settings":    sim.Template.Settings,
+		"mappings":    sim.Template.Mappings,
+		"aliases":     sim.Template.Aliases,
+		"overlapping": sim.Overlapping,
+	}
+
+	// 当前新 backing index 会得到的配置（reindex.go）；数据流尚未匹配到任何模板时只返回模拟结果
+	if settings, mappings, err := s.simulateDataStreamIndex(ctx); err != nil {
+		out["changes_error"] = err.Error()
+	} else {
+		dropVolatileSettings(settings)
+		changes := flatDiff("settings", sim.Template.Settings, settings)
+		out["changes"] = append(changes, flatDiff("mappings", sim.Template.Mappings, mappings)...)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/************** Sink 抽象：Connect / Logstash / Loki / ClickHouse / S3 / MirrorMaker 2 **************/
+
+const (
+	sinkTypeConnect    = "connect"
+	sinkTypeLogstash   = "logstash"
+	sinkTypeLoki       = "loki"
+	sinkTypeClickHouse = "clickhouse"
+	sinkTypeS3         = "s3"
+)
+
+// 每条日志流的 sink 配置；主 sink 见 Config.Sink，额外的见 Config.Sinks
+type SinkConfig struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"` // connect | logstash | loki | clickhouse | s3 | mirrormaker2
+	Topics []string `yaml:"topics"`
+	File   string   `yaml:"file"` // connect：connector JSON 文件
+	// connect：由这些字段生成 ES sink connector，设置后不读 file（essink.go）
+	Elasticsearch ESSinkSettings   `yaml:"elasticsearch"`
+	Logstash      LogstashConfig   `yaml:"logstash"`
+	Loki          LokiConfig       `yaml:"loki"`
+	ClickHouse    ClickHouseConfig `yaml:"clickhouse"`
+	S3            S3SinkConfig     `yaml:"s3"`
+
+	MirrorMaker MirrorMakerConfig `yaml:"mirrormaker"`
+}
+
+type sinkResponse struct {
+	Code   int
+	Status string
+	Body   []byte
+
+	Result string       // Register：created | updated | unchanged，空表示未知
+	Diffs  []ensureDiff // Register 更新时与现有配置的差异
+
+	Applied []byte // Register 实际提交的 connector 文档（用于版本历史）
+}
+
+func toSinkResponse(resp *http.Response, body []byte) *sinkResponse {
+	return &sinkResponse{Code: resp.StatusCode, Status: resp.Status, Body: body}
+}
+
+// 不支持的操作（如 Logstash 暂停）
+var errSinkUnsupported = errors.New("operation not supported by this sink type")
+
+// 配置或资源文件问题，对应 400
+type sinkInputError struct{ err error }
+
+func (e *sinkInputError) Error() string { return e.err.Error() }
+func (e *sinkInputError) Unwrap() error { return e.err }
+
+type SinkProvider interface {
+	Type() string
+	Name() string
+	Register(ctx context.Context) (*sinkResponse, error)
+	Status(ctx context.Context) (*sinkResponse, error)
+	Config(ctx context.Context) (*sinkResponse, error)
+	Pause(ctx context.Context) (*sinkResponse, error)
+	Resume(ctx context.Context) (*sinkResponse, error)
+	Delete(ctx context.Context) (*sinkResponse, error)
+}
+
+func normalizeSinkType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if t == "" {
+		return sinkTypeConnect
+	}
+	return t
+}
+
+// 主 sink：未填写的字段沿用 connect.* / logstash.* 的旧配置
+func (s *Server) primarySinkConfig() SinkConfig {
+	sc := s.cfg.Sink
+	sc.Type = normalizeSinkType(sc.Type)
+	if sc.Name == "" {
+		sc.Name = s.cfg.Connect.Names.Sink
+	}
+	if sc.File == "" {
+		sc.File = s.cfg.Connect.Files.Sink
+	}
+	if sc.Logstash.BootstrapServers == "" {
+		sc.Logstash = s.cfg.Logstash
+	}
+	return sc
+}
+
+func (s *Server) sinkConfigs() []SinkConfig {
+	out := []SinkConfig{s.primarySinkConfig()}
+	for _, sc := range s.cfg.Sinks {
+		sc.Type = normalizeSinkType(sc.Type)
+		out = append(out, sc)
+	}
+	return append(out, s.routeSinkConfigs()...)
+}
+
+func (s *Server) findSinkConfig(name string) (SinkConfig, bool) {
+	for _, sc := range s.sinkConfigs() {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return SinkConfig{}, false
+}
+
+func (s *Server) sinkProvider(sc SinkConfig) (SinkProvider, error) {
+	switch sc.Type {
+	case sinkTypeConnect:
+		return &connectSink{s: s, typ: sinkTypeConnect, name: sc.Name, file: sc.File,
+			load: func(ctx context.Context) ([]byte, error) { return s.readSinkAsset(ctx, sc) }}, nil
+	case sinkTypeS3:
+		return &connectSink{s: s, typ: sinkTypeS3, name: sc.Name,
+			load: func(context.Context) ([]byte, error) { return s.renderS3Connector(sc) }}, nil
+	case sinkTypeMirrorMaker:
+		return &connectSink{s: s, typ: sinkTypeMirrorMaker, name: sc.Name,
+			load: func(context.Context) ([]byte, error) { return s.renderMirrorMakerConnector(sc) }}, nil
+	case sinkTypeLogstash:
+		return &logstashSink{s: s, sc: sc}, nil
+	case sinkTypeLoki:
+		return &lokiSink{s: s, sc: sc}, nil
+	case sinkTypeClickHouse:
+		return &clickhouseSink{s: s, sc: sc}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+}
+
+func (s *Server) sinkType() string { return s.primarySinkConfig().Type }
+
+func (s *Server) primarySink() (SinkProvider, error) {
+	return s.sinkProvider(s.primarySinkConfig())
+}
+
+/************** Kafka Connect sink（ES sink / S3 sink 等） **************/
+
+type connectSink struct {
+	s    *Server
+	typ  string
+	name string
+	file string
+	load func(ctx context.Context) ([]byte, error)
+}
+
+func (c *connectSink) Type() string { return c.typ }
+func (c *connectSink) Name() string { return c.name }
+
+func (c *connectSink) connectorURL(suffix string) string {
+	return fmt.Sprintf("%s/connectors/%s%s", c.s.cfg.Connect.Host, c.name, suffix)
+}
+
+func (c *connectSink) Register(ctx context.Context) (*sinkResponse, error) {
+	if err := c.s.requireApproval(ctx); err != nil {
+		return nil, err
+	}
+	b, err := c.load(ctx)
+	if err == nil {
+		b, err = c.s.stampConnector(b)
+	}
+	if err != nil {
+		c.s.logger.Printf("step=sink read_file_err file=%s err=%v", c.file, err)
+		return nil, &sinkInputError{err}
+	}
+	if err := c.s.checkGuardrails(assetSink, b); err != nil {
+		return nil, err
+	}
+	// 引用了 ${provider:...} 时先确认 worker 能解析，避免注册后任务才失败
+	if configPlaceholderRe.Match(b) {
+		chk, err := c.s.checkConfigProviders(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		if !chk.ok() {
+			c.s.logger.Printf("step=sink config_providers missing=%v validation=%q", chk.Missing, chk.Validation)
+			return nil, &sinkInputError{fmt.Errorf("config providers not usable on the Connect worker: missing=%v errors=%v", chk.Missing, chk.Validation)}
+		}
+	}
+	url := fmt.Sprintf("%s/connectors", c.s.cfg.Connect.Host)
+	c.s.logger.Printf("step=sink post url=%s file=%s size=%d", url, c.file, len(b))
+	resp, respBody, err := c.s.doPOST(ctx, url, b, "connect")
+	if err != nil {
+		return nil, err
+	}
+	if connectAlreadyExists(resp.StatusCode, respBody) {
+		res, err := c.ensureExisting(ctx, b)
+		if res != nil {
+			res.Applied = b
+		}
+		return res, err
+	}
+	res := toSinkResponse(resp, respBody)
+	if resp.StatusCode < 300 {
+		res.Result = ensureCreated
+	}
+	res.Applied = b
+	return res, nil
+}
+
+func (c *connectSink) get(ctx context.Context, action, suffix string) (*sinkResponse, error) {
+	url := c.connectorURL(suffix)
+	c.s.logger.Printf("connect action=%s name=%s url=%s", action, c.name, url)
+	resp, body, err := c.s.doGET(ctx, url, "connect")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (c *connectSink) put(ctx context.Context, action, suffix string) (*sinkResponse, error) {
+	if err := c.checkOwner(ctx, nil); err != nil {
+		return nil, err
+	}
+	url := c.connectorURL(suffix)
+	c.s.logger.Printf("connect action=%s name=%s url=%s", action, c.name, url)
+	resp, body, err := c.s.doPUTNoBody(ctx, url, "connect")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+func (c *connectSink) Status(ctx context.Context) (*sinkResponse, error) {
+	return c.get(ctx, "status", "/status")
+}
+func (c *connectSink) Config(ctx context.Context) (*sinkResponse, error) {
+	return c.get(ctx, "get-config", "/config")
+}
+func (c *connectSink) Pause(ctx context.Context) (*sinkResponse, error) {
+	return c.put(ctx, "pause", "/pause")
+}
+func (c *connectSink) Resume(ctx context.Context) (*sinkResponse, error) {
+	return c.put(ctx, "resume", "/resume")
+}
+
+func (c *connectSink) Delete(ctx context.Context) (*sinkResponse, error) {
+	if err := c.checkOwner(ctx, nil); err != nil {
+		return nil, err
+	}
+	url := c.connectorURL("")
+	c.s.logger.Printf("connect action=delete name=%s url=%s", c.name, url)
+	resp, body, err := c.s.doDELETE(ctx, url, "connect")
+	if err != nil {
+		return nil, err
+	}
+	return toSinkResponse(resp, body), nil
+}
+
+/************** S3 归档 sink（Confluent S3 Sink Connector） **************/
+
+const s3ConnectorClass = "io.confluent.connect.s3.S3SinkConnector"
+
+type S3SinkConfig struct {
+	Bucket              string            `yaml:"bucket"`
+	Region              string            `yaml:"region"`
+	StoreURL            string            `yaml:"store_url"` // MinIO 等兼容存储
+	TopicsDir           string            `yaml:"topics_dir"`
+	FlushSize           int               `yaml:"flush_size"`
+	RotateIntervalMs    int64             `yaml:"rotate_interval_ms"`
+	PartitionDurationMs int64             `yaml:"partition_duration_ms"`
+	PathFormat          string            `yaml:"path_format"`
+	TasksMax            int               `yaml:"tasks_max"`
+	Extra               map[string]string `yaml:"extra"` // 原样透传的额外属性
+}
+
+func (s *Server) renderS3Connector(sc SinkConfig) ([]byte, error) {
+	c := sc.S3
+	if c.Bucket == "" || len(sc.Topics) == 0 {
+		return nil, fmt.Errorf("sink %s: s3.bucket and topics are required", sc.Name)
+	}
+	orInt := func(v, def int64) string {
+		if v <= 0 {
+			v = def
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	orStr := func(v, def string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	}
+	cfg := map[string]string{
+		"connector.class":                s3ConnectorClass,
+		"tasks.max":                      orInt(int64(c.TasksMax), 1),
+		"topics":                         strings.Join(sc.Topics, ","),
+		"s3.bucket.name":                 c.Bucket,
+		"s3.region":                      orStr(c.Region, "us-east-1"),
+		"topics.dir":                     orStr(c.TopicsDir, "topics"),
+		"storage.class":                  "io.confluent.connect.s3.storage.S3Storage",
+		"format.class":                   "io.confluent.connect.s3.format.json.JsonFormat",
+		"partitioner.class":              "io.confluent.connect.storage.partitioner.TimeBasedPartitioner",
+		"path.format":                    orStr(c.PathFormat, "'dt'=YYYY-MM-dd/'hour'=HH"),
+		"partition.duration.ms":          orInt(c.PartitionDurationMs, 3600000),
+		"rotate.interval.ms":             orInt(c.RotateIntervalMs, 600000),
+		"flush.size":                     orInt(int64(c.FlushSize), 10000),
+		"locale":                         "en-US",
+		"timezone":                       "UTC",
+		"timestamp.extractor":            "Record",
+		"key.converter":                  "org.apache.kafka.connect.storage.StringConverter",
+		"value.converter":                "org.apache.kafka.connect.json.JsonConverter",
+		"value.converter.schemas.enable": "false",
+	}
+	if c.StoreURL != "" {
+		cfg["store.url"] = c.StoreURL
+	}
+	for k, v := range c.Extra {
+		cfg[k] = v
+	}
+	return json.Marshal(map[string]any{"name": sc.Name, "config": cfg})
+}
+
+/************** HTTP 处理 **************/
+
+func writeSinkError(w http.ResponseWriter, step string, err error) {
+	var inErr *sinkInputError
+	var ge *guardrailError
+	if e, ok := isNotManaged(err); ok {
+		writeNotManaged(w, step, e)
+		return
+	}
+	var ar *approvalRequiredError
+	if errors.As(err, &ge) {
+		writeGuardrailError(w, step, ge)
+		return
+	}
+	if errors.As(err, &ar) {
+		writeApprovalRequired(w, step, ar)
+		return
+	}
+	switch {
+	case errors.Is(err, errSinkUnsupported), errors.As(err, &inErr):
+		writeJSON(w, 400, map[string]any{"step": step, "error": err.Error()})
+	default:
+		writeJSON(w, 500, map[string]any{"step": step, "error": err.Error()})
+	}
+}
+
+func (s *Server) writeSinkResult(w http.ResponseWriter, step string, res *sinkResponse, err error) {
+	if err != nil {
+		writeSinkError(w, step, err)
+		return
+	}
+	writeJSON(w, res.Code, jsonRaw(res.Body))
+}
+
+func (s *Server) writeSinkRegister(w http.ResponseWriter, p SinkProvider, res *sinkResponse, err error) {
+	if err != nil {
+		writeSinkError(w, "sink", err)
+		return
+	}
+	out := map[string]any{"step": "sink", "type": p.Type(), "name": p.Name(), "status": res.Status, "body": string(res.Body)}
+	if res.Result != "" {
+		out["result"] = res.Result
+	}
+	if len(res.Diffs) > 0 {
+		out["diffs"] = res.Diffs
+	}
+	writeJSON(w, res.Code, out)
+}
+
+// 按 {name} 查找 sink；找不到时直接写 404
+func (s *Server) namedSink(w http.ResponseWriter, r *http.Request) (SinkProvider, bool) {
+	name := r.PathValue("name")
+	sc, ok := s.findSinkConfig(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("sink %q not configured", name)})
+		return nil, false
+	}
+	p, err := s.sinkProvider(sc)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	return p, true
+}
+
+func (s *Server) handleListSinks(w http.ResponseWriter, r *http.Request) {
+	type item struct {
+		Name    string   `json:"name"`
+		Type    string   `json:"type"`
+		Topics  []string `json:"topics,omitempty"`
+		Primary bool     `json:"primary"`
+	}
+	var out []item
+	for i, sc := range s.sinkConfigs() {
+		out = append(out, item{Name: sc.Name, Type: sc.Type, Topics: sc.Topics, Primary: i == 0})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleNamedSinkRegister(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Register(optionsContext(r))
+		s.recordSinkRegister(r, p, res, err, 0)
+		s.trackDesired(r, p, desiredRunning, res, err)
+		s.writeSinkRegister(w, p, res, err)
+	}
+}
+
+func (s *Server) handleNamedSinkStatus(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Status(r.Context())
+		s.writeSinkResult(w, "sink-status", res, err)
+	}
+}
+
+func (s *Server) handleNamedSinkConfig(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Config(r.Context())
+		s.writeSinkResult(w, "sink-config", res, err)
+	}
+}
+
+func (s *Server) handleNamedSinkPause(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Pause(optionsContext(r))
+		s.trackDesired(r, p, desiredPaused, res, err)
+		s.writeSinkResult(w, "sink-pause", res, err)
+	}
+}
+
+func (s *Server) handleNamedSinkResume(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Resume(optionsContext(r))
+		s.trackDesired(r, p, desiredRunning, res, err)
+		s.writeSinkResult(w, "sink-resume", res, err)
+	}
+}
+
+func (s *Server) handleNamedSinkDelete(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.namedSink(w, r); ok {
+		res, err := p.Delete(optionsContext(r))
+		s.trackDesired(r, p, desiredAbsent, res, err)
+		s.writeSinkResult(w, "sink-delete", res, err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+/************** 请求 / 响应体大小限制 **************/
+
+// 下游响应体与 /admin 请求体都按上限读取：超出时不把整块读进内存，
+// 而是返回带 truncated 标记的结构化错误；日志片段按 UTF-8 边界截断并注明原始大小。
+
+const (
+	defaultMaxResponseBytes = 32 << 20
+	defaultMaxRequestBytes  = 8 << 20
+	defaultLogSnippetBytes  = 2048
+)
+
+type bodyLimitError struct {
+	What  string // 如 "GET http://es:9200/_cat/indices response"
+	Limit int64
+}
+
+func (e *bodyLimitError) Error() string {
+	return fmt.Sprintf("%s body exceeds %d bytes (truncated)", e.What, e.Limit)
+}
+
+// 结构化字段，合入错误响应
+func (e *bodyLimitError) fields() map[string]any {
+	return map[string]any{"truncated": true, "limit_bytes": e.Limit}
+}
+
+// 出错时的响应体：大小超限、熔断等错误附带各自的结构化字段（truncated / circuit_open ...）
+func errorBody(step string, err error) map[string]any {
+	out := map[string]any{"step": step, "error": err.Error()}
+	var fe interface{ fields() map[string]any }
+	if errors.As(err, &fe) {
+		for k, v := range fe.fields() {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (s *Server) maxResponseBytes() int64 {
+	if n := s.cfg.Limits.MaxResponseBytes; n > 0 {
+		return n
+	}
+	return defaultMaxResponseBytes
+}
+
+func (s *Server) maxRequestBytes() int64 {
+	if n := s.cfg.Limits.MaxRequestBytes; n > 0 {
+		return n
+	}
+	return defaultMaxRequestBytes
+}
+
+func (s *Server) logSnippetBytes() int {
+	if n := s.cfg.Limits.LogSnippetBytes; n > 0 {
+		return n
+	}
+	return defaultLogSnippetBytes
+}
+
+// 最多读 limit 字节；超出时返回已读部分与 *bodyLimitError
+func readLimited(r io.Reader, limit int64, what string) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(b)) > limit {
+		return b[:limit], &bodyLimitError{What: what, Limit: limit}
+	}
+	return b, err
+}
+
+// 读取下游响应体（超限时剩余部分直接丢弃，连接不复用）
+func (s *Server) readResponse(resp *http.Response) ([]byte, error) {
+	what := fmt.Sprintf("%s %s response", resp.Request.Method, resp.Request.URL.Redacted())
+	return readLimited(resp.Body, s.maxResponseBytes(), what)
+}
+
+// 日志用片段：JSON 先压缩再截断，截断点落在 UTF-8 字符边界，返回原始长度供日志注明
+func (s *Server) logSnippet(body []byte) (snippet []byte, total int, truncated bool) {
+	total = len(body)
+	max := s.logSnippetBytes()
+	if len(body) <= max {
+		return body, total, false
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, body) == nil {
+		body = buf.Bytes()
+		if len(body) <= max {
+			return body, total, false
+		}
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut], total, true
+}
+
+// 请求体解析失败：超出 max_request_bytes 时 413 并带 truncated 标记，其余 400
+func writeInvalidBody(w http.ResponseWriter, err error) {
+	var me *http.MaxBytesError
+	if errors.As(err, &me) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{
+			"error":       fmt.Sprintf("request body exceeds limits.max_request_bytes (%d bytes)", me.Limit),
+			"truncated":   true,
+			"limit_bytes": me.Limit,
+		})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body: " + err.Error()})
+}
+
+// /admin 请求体上限：声明的 Content-Length 超限直接 413，其余用 MaxBytesReader 兜底
+func (s *Server) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.maxRequestBytes()
+		if r.ContentLength > limit {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{
+				"error":          fmt.Sprintf("request body exceeds limits.max_request_bytes (%d bytes)", limit),
+				"truncated":      true,
+				"limit_bytes":    limit,
+				"content_length": r.ContentLength,
+			})
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
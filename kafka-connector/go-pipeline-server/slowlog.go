@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/************** ES 慢日志（search / indexing slowlog） **************/
+
+// ES 的慢日志写在节点本地文件里，没有 REST 接口可读；通常由 Elastic Agent / Filebeat 的 elasticsearch 集成
+// 采集到 logs-elasticsearch.slowlog-* 数据流（或自建的索引，见 slowlog.index）。
+// GET /admin/es/slowlog 从中查询与本数据流 backing index 相关的记录，按查询 / 文档的形状（字面量替换为 ?）归并，
+// 按累计耗时给出最慢的几类，并附上可能的原因（前缀通配、regexp、script、深分页等）；
+// 同时检查写索引是否设置了 index.search.slowlog / index.indexing.slowlog 阈值，未设置时 ES 不会记录任何慢日志。
+// 参数：since（默认 24h）、type=search|indexing、top（默认 20）、size（扫描的记录数，默认 500，最多 5000）。
+// 字段兼容 Filebeat 模块（event.duration 纳秒）与 ES 原生 JSON 慢日志（elasticsearch.slowlog.took_millis）。
+
+const (
+	defaultSlowlogIndex = "logs-elasticsearch.slowlog-*"
+	maxSlowlogScan      = 5000
+	slowlogSampleBytes  = 2048
+)
+
+type SlowlogConfig struct {
+	Index string `yaml:"index"` // 慢日志所在的数据流 / 索引模式，默认 logs-elasticsearch.slowlog-*
+}
+
+func (c SlowlogConfig) index() string {
+	if c.Index != "" {
+		return c.Index
+	}
+	return defaultSlowlogIndex
+}
+
+// 写索引上与慢日志相关的阈值设置
+var slowlogThresholdKeys = []string{
+	"index.search.slowlog.threshold.query.warn",
+	"index.search.slowlog.threshold.query.info",
+	"index.search.slowlog.threshold.fetch.warn",
+	"index.indexing.slowlog.threshold.index.warn",
+	"index.indexing.slowlog.threshold.index.info",
+}
+
+type slowlogEntry struct {
+	At     time.Time
+	Type   string // search | indexing
+	Index  string
+	Took   time.Duration
+	Source string
+}
+
+type slowlogGroup struct {
+	Type        string    `json:"type"`
+	Fingerprint string    `json:"fingerprint"`
+	Count       int       `json:"count"`
+	TotalMS     int64     `json:"total_ms"`
+	MaxMS       int64     `json:"max_ms"`
+	AvgMS       int64     `json:"avg_ms"`
+	Indices     []string  `json:"indices"`
+	LastSeen    time.Time `json:"last_seen"`
+	Sample      string    `json:"sample"`
+	Hints       []string  `json:"hints,omitempty"`
+}
+
+type slowlogReport struct {
+	Source     string            `json:"source"`
+	Since      string            `json:"since"`
+	Scanned    int               `json:"scanned"`
+	Total      int64             `json:"total"` // 时间范围内匹配的记录数
+	WriteIndex string            `json:"write_index,omitempty"`
+	Thresholds map[string]string `json:"thresholds"`
+	Warnings   []string          `json:"warnings"`
+	Groups     []slowlogGroup    `json:"groups"`
+}
+
+// 本数据流的 backing index（含 shrink / reindex 产生的索引）与数据流名本身
+func (s *Server) slowlogIndexQuery() map[string]any {
+	var should []any
+	for _, p := range append(s.backingIndexPatterns(), s.cfg.ES.Names.DataStream) {
+		should = append(should, map[string]any{"wildcard": map[string]any{"elasticsearch.index.name": map[string]any{"value": p}}})
+	}
+	return map[string]any{"bool": map[string]any{"should": should, "minimum_should_match": 1}}
+}
+
+func (s *Server) fetchSlowlog(ctx context.Context, since, typ string, size int) ([]slowlogEntry, int64, error) {
+	filter := []any{
+		map[string]any{"range": map[string]any{"@timestamp": map[string]any{"gte": "now-" + since}}},
+		s.slowlogIndexQuery(),
+	}
+	switch typ {
+	case "search":
+		filter = append(filter, map[string]any{"prefix": map[string]any{"log.logger": "index.search.slowlog"}})
+	case "indexing":
+		filter = append(filter, map[string]any{"prefix": map[string]any{"log.logger": "index.indexing.slowlog"}})
+	}
+	q := map[string]any{
+		"size":             size,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": map[string]any{"filter": filter}},
+		"sort": []any{
+			map[string]any{"event.duration": map[string]any{"order": "desc", "unmapped_type": "long"}},
+			map[string]any{"elasticsearch.slowlog.took_millis": map[string]any{"order": "desc", "unmapped_type": "long"}},
+		},
+	}
+	b, _ := json.Marshal(q)
+	u := fmt.Sprintf("%s/%s/_search?ignore_unavailable=true&allow_no_indices=true", s.cfg.ES.Host, url.PathEscape(s.cfg.Slowlog.index()))
+	resp, body, err := s.doPOST(ctx, u, b, "es")
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("search %s returned %s", s.cfg.Slowlog.index(), resp.Status)
+	}
+	var doc struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source map[string]any `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, 0, fmt.Errorf("decode slowlog search: %w", err)
+	}
+	out := make([]slowlogEntry, 0, len(doc.Hits.Hits))
+	for _, h := range doc.Hits.Hits {
+		if e, ok := parseSlowlogDoc(h.Source); ok {
+			out = append(out, e)
+		}
+	}
+	return out, doc.Hits.Total.Value, nil
+}
+
+// 字段兼容展开（{"a":{"b":1}}）与扁平（{"a.b":1}）两种写法
+func lookupString(doc map[string]any, path string) string {
+	v, ok := lookupSetting(doc, path)
+	if !ok || v == nil {
+		return ""
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+var slowlogMessageIndex = regexp.MustCompile(`^\[([^\]/]+)`)
+
+func parseSlowlogDoc(doc map[string]any) (slowlogEntry, bool) {
+	e := slowlogEntry{Index: lookupString(doc, "elasticsearch.index.name"), Source: lookupString(doc, "elasticsearch.slowlog.source")}
+	if e.Source == "" {
+		e.Source = lookupString(doc, "elasticsearch.slowlog.source_query")
+	}
+	if e.Index == "" {
+		if m := slowlogMessageIndex.FindStringSubmatch(lookupString(doc, "elasticsearch.slowlog.message")); m != nil {
+			e.Index = m[1]
+		}
+	}
+	e.At, _ = time.Parse(time.RFC3339Nano, lookupString(doc, "@timestamp"))
+
+	logger := lookupString(doc, "log.logger")
+	switch {
+	case strings.Contains(logger, "indexing"):
+		e.Type = "indexing"
+	case strings.Contains(logger, "search"):
+		e.Type = "search"
+	case lookupString(doc, "elasticsearch.slowlog.search_type") != "":
+		e.Type = "search"
+	default:
+		e.Type = "indexing"
+	}
+
+	if ns, err := strconv.ParseInt(lookupString(doc, "event.duration"), 10, 64); err == nil && ns > 0 {
+		e.Took = time.Duration(ns)
+	} else if ms, err := strconv.ParseInt(lookupString(doc, "elasticsearch.slowlog.took_millis"), 10, 64); err == nil {
+		e.Took = time.Duration(ms) * time.Millisecond
+	} else if d, ok := parseESDuration(lookupString(doc, "elasticsearch.slowlog.took")); ok {
+		e.Took = d
+	} else {
+		return e, false
+	}
+	return e, true
+}
+
+var (
+	slowlogQuoted  = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	slowlogNumbers = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+)
+
+// 查询 / 文档的形状：JSON 叶子值替换为 ?，键保持有序；非 JSON 时替换引号内字符串与数字
+func slowlogFingerprint(source string) string {
+	var v any
+	if json.Unmarshal([]byte(source), &v) == nil {
+		b, _ := json.Marshal(stripLeaves(v))
+		return string(b)
+	}
+	fp := slowlogQuoted.ReplaceAllString(source, `"?"`)
+	return slowlogNumbers.ReplaceAllString(fp, "?")
+}
+
+func stripLeaves(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, x := range t {
+			out[k] = stripLeaves(x)
+		}
+		return out
+	case []any:
+		// terms 之类的数组只保留一个元素的形状，避免元素个数不同被拆成多组
+		if len(t) == 0 {
+			return t
+		}
+		return []any{stripLeaves(t[0])}
+	default:
+		return "?"
+	}
+}
+
+var slowlogLeadingWildcard = regexp.MustCompile(`"(wildcard|query_string|value|query)"\s*:\s*"[*?]`)
+
+// 常见的慢查询 / 慢写入原因
+func slowlogHints(typ, sample string) []string {
+	var out []string
+	if typ == "indexing" {
+		// ES 默认只记录 source 的前 1000 个字符，记满说明文档较大
+		if len(sample) >= 1000 {
+			out = append(out, "large documents: consider trimming fields in the ingest pipeline or disabling indexing of unused fields (index: false / enabled: false)")
+		}
+		return out
+	}
+	if slowlogLeadingWildcard.MatchString(sample) {
+		out = append(out, "leading wildcard scans every term; map the field as wildcard type or use an n-gram subfield")
+	}
+	if strings.Contains(sample, `"regexp"`) {
+		out = append(out, "regexp query; consider a keyword subfield with a normalizer or the wildcard field type")
+	}
+	if strings.Contains(sample, `"script"`) {
+		out = append(out, "script evaluated per document; precompute the value in the ingest pipeline or use a runtime field sparingly")
+	}
+	var q struct {
+		From int `json:"from"`
+		Size int `json:"size"`
+	}
+	if json.Unmarshal([]byte(sample), &q) == nil && q.From+q.Size > 10000 {
+		out = append(out, fmt.Sprintf("deep pagination (from+size=%d); use search_after with a point in time", q.From+q.Size))
+	}
+	if strings.Contains(sample, `"aggregations"`) || strings.Contains(sample, `"aggs"`) {
+		if strings.Contains(sample, `"terms"`) && !strings.Contains(sample, ".keyword") {
+			out = append(out, "terms aggregation on a field without .keyword; make sure it is mapped as keyword (fielddata on text fields is slow)")
+		}
+	}
+	return out
+}
+
+func groupSlowlog(entries []slowlogEntry) []slowlogGroup {
+	groups := map[string]*slowlogGroup{}
+	indices := map[string]map[string]bool{}
+	for _, e := range entries {
+		fp := slowlogFingerprint(e.Source)
+		key := e.Type + "|" + fp
+		g, ok := groups[key]
+		if !ok {
+			g = &slowlogGroup{Type: e.Type, Fingerprint: fp}
+			groups[key] = g
+			indices[key] = map[string]bool{}
+		}
+		ms := e.Took.Milliseconds()
+		g.Count++
+		g.TotalMS += ms
+		if ms >= g.MaxMS {
+			g.MaxMS = ms
+			g.Sample = e.Source
+		}
+		if e.At.After(g.LastSeen) {
+			g.LastSeen = e.At
+		}
+		if e.Index != "" {
+			indices[key][e.Index] = true
+		}
+	}
+	out := make([]slowlogGroup, 0, len(groups))
+	for key, g := range groups {
+		g.AvgMS = g.TotalMS / int64(g.Count)
+		for idx := range indices[key] {
+			g.Indices = append(g.Indices, idx)
+		}
+		sort.Strings(g.Indices)
+		if len(g.Sample) > slowlogSampleBytes {
+			g.Sample = g.Sample[:slowlogSampleBytes]
+		}
+		if len(g.Fingerprint) > slowlogSampleBytes {
+			g.Fingerprint = g.Fingerprint[:slowlogSampleBytes]
+		}
+		g.Hints = slowlogHints(g.Type, g.Sample)
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].TotalMS != out[k].TotalMS {
+			return out[i].TotalMS > out[k].TotalMS
+		}
+		return out[i].MaxMS > out[k].MaxMS
+	})
+	return out
+}
+
+func (s *Server) handleSlowlog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since := q.Get("since")
+	if since == "" {
+		since = "24h"
+	}
+	if _, ok := parseESDuration(since); !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an ES duration such as 30m, 24h or 7d"})
+		return
+	}
+	typ := q.Get("type")
+	if typ != "" && typ != "search" && typ != "indexing" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "type must be search or indexing"})
+		return
+	}
+	top, size := 20, 500
+	for name, dst := range map[string]*int{"top": &top, "size": &size} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": name + " must be a positive integer"})
+				return
+			}
+			*dst = n
+		}
+	}
+	size = min(size, maxSlowlogScan)
+
+	ctx := r.Context()
+	rep := slowlogReport{Source: s.cfg.Slowlog.index(), Since: since, Thresholds: map[string]string{}, Warnings: []string{}}
+	if idx, err := s.dataStreamIndices(ctx); err != nil {
+		rep.Warnings = append(rep.Warnings, err.Error())
+	} else if len(idx) > 0 {
+		rep.WriteIndex = idx[len(idx)-1]
+		if settings, err := s.indexSettings(ctx, rep.WriteIndex); err != nil {
+			rep.Warnings = append(rep.Warnings, err.Error())
+		} else {
+			for _, k := range slowlogThresholdKeys {
+				if v, ok := settings[k]; ok && v != "-1" {
+					rep.Thresholds[k] = v
+				}
+			}
+			if len(rep.Thresholds) == 0 {
+				rep.Warnings = append(rep.Warnings, fmt.Sprintf("no slowlog thresholds are set on write index %s; ES logs nothing until e.g. index.search.slowlog.threshold.query.warn is set in the index template", rep.WriteIndex))
+			}
+		}
+	}
+
+	entries, total, err := s.fetchSlowlog(ctx, since, typ, size)
+	if err != nil {
+		s.logger.Printf("step=slowlog source=%s err=%v", rep.Source, err)
+		writeJSON(w, http.StatusBadGateway, errorBody("slowlog", err))
+		return
+	}
+	rep.Scanned, rep.Total = len(entries), total
+	if total > int64(len(entries)) {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("scanned the slowest %d of %d entries; raise size to include more", len(entries), total))
+	}
+	if total == 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("no slowlog entries for %s in %s within %s; check that slow logs are shipped there (slowlog.index)", s.cfg.ES.Names.DataStream, rep.Source, since))
+	}
+	rep.Groups = groupSlowlog(entries)
+	if len(rep.Groups) > top {
+		rep.Groups = rep.Groups[:top]
+	}
+	for i := range rep.Groups {
+		rep.Groups[i].Sample = string(s.redact.JSON([]byte(rep.Groups[i].Sample)))
+	}
+	s.logger.Printf("step=slowlog source=%s since=%s scanned=%d total=%d groups=%d", rep.Source, since, rep.Scanned, rep.Total, len(rep.Groups))
+	writeJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/************** 运行状态汇总（sink / task / 消费延迟 / ES 健康） **************/
+
+type connectorTask struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id,omitempty"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+type sinkState struct {
+	Name  string          `json:"name"`
+	Type  string          `json:"type"`
+	State string          `json:"state"` // RUNNING / PAUSED / FAILED / UNASSIGNED / UNKNOWN
+	Tasks []connectorTask `json:"tasks,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+type consumerLag struct {
+	Group string `json:"group"`
+	State string `json:"state,omitempty"`
+	Total int64  `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+type esHealth struct {
+	Status              string `json:"status"` // green / yellow / red / unreachable
+	NumberOfNodes       int    `json:"number_of_nodes,omitempty"`
+	UnassignedShards    int    `json:"unassigned_shards,omitempty"`
+	ActiveShardsPercent any    `json:"active_shards_percent_as_number,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+type liveStatus struct {
+	At   time.Time    `json:"at"`
+	Sink sinkState    `json:"sink"`
+	Lag  *consumerLag `json:"lag,omitempty"`
+	ES   esHealth     `json:"es"`
+	// 维护模式（maintenance.go），前端显示横幅
+	Maintenance *maintenanceState `json:"maintenance,omitempty"`
+}
+
+// Connect sink 默认的 consumer group 为 connect-<connector 名>
+func (s *Server) sinkConsumerGroup(name string) string {
+	if g := s.cfg.Kafka.ConsumerGroup; g != "" {
+		return g
+	}
+	return "connect-" + name
+}
+
+func parseConnectStatus(body []byte) (string, []connectorTask, error) {
+	var st struct {
+		Connector struct {
+			State string `json:"state"`
+			Trace string `json:"trace"`
+		} `json:"connector"`
+		Tasks []connectorTask `json:"tasks"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		return "", nil, err
+	}
+	return st.Connector.State, st.Tasks, nil
+}
+
+func (s *Server) collectSinkState(ctx context.Context) sinkState {
+	p, err := s.primarySink()
+	if err != nil {
+		return sinkState{State: "UNKNOWN", Error: err.Error()}
+	}
+	st := sinkState{Name: p.Name(), Type: p.Type(), State: "UNKNOWN"}
+	res, err := p.Status(ctx)
+	switch {
+	case err != nil:
+		st.Error = err.Error()
+	case res.Code == http.StatusNotFound:
+		st.State = "NOT_FOUND"
+	case res.Code >= 300:
+		st.Error = res.Status
+	case p.Type() == sinkTypeConnect || p.Type() == sinkTypeS3 || p.Type() == sinkTypeMirrorMaker:
+		state, tasks, err := parseConnectStatus(res.Body)
+		if err != nil {
+			st.Error = err.Error()
+			break
+		}
+		st.State, st.Tasks = state, tasks
+	default:
+		st.State = "RUNNING"
+	}
+	return st
+}
+
+func (s *Server) collectConsumerLag(ctx context.Context, sinkName string) *consumerLag {
+	if len(s.cfg.Kafka.Brokers) == 0 || sinkName == "" {
+		return nil
+	}
+	out := &consumerLag{Group: s.sinkConsumerGroup(sinkName)}
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	defer release()
+	lags, err := adm.Lag(ctx, out.Group)
+	if err == nil {
+		err = lags.Error()
+	}
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	l := lags[out.Group]
+	out.State, out.Total = l.State, l.Lag.Total()
+	return out
+}
+
+func (s *Server) collectESHealth(ctx context.Context) esHealth {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+"/_cluster/health", "es")
+	if err != nil {
+		return esHealth{Status: "unreachable", Error: err.Error()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return esHealth{Status: "unreachable", Error: resp.Status}
+	}
+	var h esHealth
+	if err := json.Unmarshal(body, &h); err != nil {
+		return esHealth{Status: "unknown", Error: fmt.Sprintf("decode cluster health: %v", err)}
+	}
+	return h
+}
+
+func (s *Server) collectLiveStatus(ctx context.Context) *liveStatus {
+	st := &liveStatus{At: time.Now()}
+	st.Sink = s.collectSinkState(ctx)
+	st.Lag = s.collectConsumerLag(ctx, st.Sink.Name)
+	st.ES = s.collectESHealth(ctx)
+	st.Maintenance = s.maintenanceStatus()
+	return st
+}
+
+func (s *Server) handleLiveStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, s.collectLiveStatus(ctx))
+}
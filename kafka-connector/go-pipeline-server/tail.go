@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/************** Topic 尾部消息（只读消费，不提交位点） **************/
+
+const maxTailMessages = 500
+
+type SchemaRegistryConfig struct {
+	URL      string `yaml:"url"` // e.g. http://schema-registry:8081
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// schema id -> codec，schema 不可变，进程内缓存即可
+type avroCodecs struct {
+	mu     sync.Mutex
+	codecs map[uint32]*goavro.Codec
+}
+
+func (s *Server) avroCodec(ctx context.Context, id uint32) (*goavro.Codec, error) {
+	s.avro.mu.Lock()
+	c, ok := s.avro.codecs[id]
+	s.avro.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	sr := s.cfg.Kafka.SchemaRegistry
+	if sr.URL == "" {
+		return nil, fmt.Errorf("kafka.schema_registry.url is not configured")
+	}
+	url := fmt.Sprintf("%s/schemas/ids/%d", strings.TrimRight(sr.URL, "/"), id)
+	resp, body, err := s.doRequest(ctx, http.MethodGet, url, nil, "schema-registry", "", func(req *http.Request) {
+		if sr.Username != "" {
+			req.SetBasicAuth(sr.Username, sr.Password)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("schema registry: schema id %d: %s", id, resp.Status)
+	}
+	var doc struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if doc.SchemaType != "" && doc.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("schema id %d is %s, only AVRO is supported", id, doc.SchemaType)
+	}
+	c, err = goavro.NewCodec(doc.Schema)
+	if err != nil {
+		return nil, err
+	}
+	s.avro.mu.Lock()
+	if s.avro.codecs == nil {
+		s.avro.codecs = map[uint32]*goavro.Codec{}
+	}
+	s.avro.codecs[id] = c
+	s.avro.mu.Unlock()
+	return c, nil
+}
+
+// 按 Confluent wire format（0x00 + 4 字节 schema id）识别 Avro，其余按 JSON / 文本
+func (s *Server) decodeKafkaValue(ctx context.Context, v []byte) (format string, value any, schemaID uint32, err error) {
+	if len(v) >= 5 && v[0] == 0 {
+		id := binary.BigEndian.Uint32(v[1:5])
+		codec, err := s.avroCodec(ctx, id)
+		if err != nil {
+			return "avro", nil, id, err
+		}
+		native, _, err := codec.NativeFromBinary(v[5:])
+		if err != nil {
+			return "avro", nil, id, err
+		}
+		return "avro", native, id, nil
+	}
+	if json.Valid(v) {
+		return "json", json.RawMessage(v), 0, nil
+	}
+	return "text", string(v), 0, nil
+}
+
+type tailMessage struct {
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Format    string            `json:"format"`
+	SchemaID  uint32            `json:"schema_id,omitempty"`
+	Value     any               `json:"value,omitempty"`
+	Error     string            `json:"decode_error,omitempty"`
+}
+
+func (s *Server) handleKafkaTail(w http.ResponseWriter, r *http.Request) {
+	n := 50
+	if v := r.URL.Query().Get("n"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "n must be a positive integer"})
+			return
+		}
+		n = min(i, maxTailMessages)
+	}
+	topic := r.URL.Query().Get("topic")
+	if topic == "" {
+		topic = s.cfg.Kafka.Topic
+	}
+	if topic == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "kafka.topic is not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		writeJSON(w, kafkaErrStatus(err), errorBody("kafka-tail", err))
+		return
+	}
+	// 整个读取过程占用一个 kafka 名额
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorBody("kafka-tail", err))
+		return
+	}
+	defer release()
+	starts, err := adm.ListStartOffsets(ctx, topic)
+	if err == nil {
+		err = starts.Error()
+	}
+	if err != nil {
+		s.logger.Printf("kafka action=tail topic=%s err=%v", topic, err)
+		writeJSON(w, http.StatusBadGateway, errorBody("kafka-tail", err))
+		return
+	}
+	ends, err := adm.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		s.logger.Printf("kafka action=tail topic=%s err=%v", topic, err)
+		writeJSON(w, http.StatusBadGateway, errorBody("kafka-tail", err))
+		return
+	}
+
+	// 每个分区最多读末尾 n 条，合并后按时间取最后 n 条
+	offsets := map[int32]kgo.Offset{}
+	remaining := map[int32]int64{}
+	for p, eo := range ends[topic] {
+		from := max(eo.Offset-int64(n), starts[topic][p].Offset)
+		if from >= eo.Offset {
+			continue
+		}
+		offsets[p] = kgo.NewOffset().At(from)
+		remaining[p] = eo.Offset
+	}
+	msgs := []tailMessage{}
+	if len(offsets) > 0 {
+		// 独立 consumer，不加入 group，不会提交位点
+		opts, err := s.kafkaOpts(kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: offsets}))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody("kafka-tail", err))
+			return
+		}
+		cl, err := kgo.NewClient(opts...)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("kafka-tail", err))
+			return
+		}
+		defer cl.Close()
+
+		var recs []*kgo.Record
+		for len(remaining) > 0 && ctx.Err() == nil {
+			fs := cl.PollFetches(ctx)
+			fs.EachError(func(t string, p int32, err error) {
+				if ctx.Err() == nil {
+					s.logger.Printf("kafka action=tail topic=%s partition=%d err=%v", t, p, err)
+				}
+			})
+			fs.EachRecord(func(rec *kgo.Record) {
+				recs = append(recs, rec)
+				if rec.Offset+1 >= remaining[rec.Partition] {
+					delete(remaining, rec.Partition)
+				}
+			})
+		}
+		sort.Slice(recs, func(i, k int) bool { return recs[i].Timestamp.Before(recs[k].Timestamp) })
+		if len(recs) > n {
+			recs = recs[len(recs)-n:]
+		}
+		for _, rec := range recs {
+			m := tailMessage{Partition: rec.Partition, Offset: rec.Offset, Timestamp: rec.Timestamp, Key: string(rec.Key)}
+			for _, h := range rec.Headers {
+				if m.Headers == nil {
+					m.Headers = map[string]string{}
+				}
+				m.Headers[h.Key] = string(h.Value)
+			}
+			var derr error
+			m.Format, m.Value, m.SchemaID, derr = s.decodeKafkaValue(ctx, rec.Value)
+			if derr != nil {
+				m.Error = derr.Error()
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	s.logger.Printf("kafka action=tail topic=%s n=%d got=%d", topic, n, len(msgs))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"topic":    topic,
+		"count":    len(msgs),
+		"messages": msgs,
+		"partial":  len(remaining) > 0,
+	})
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 多租户 **************/
+
+// 平台团队把本服务作为共享服务提供给多个产品团队：tenancy.tenants 中每个租户有自己的一条 pipeline
+// （data stream / ILM / 模板 / ingest pipeline / sink 的名字与资产文件）、资产版本与向导进度目录
+// （<assets.dir>/tenants/<name>）、访问 token 与配额。租户接口在 /admin/t/{tenant}/ 下，路径与全局接口相同，
+// 如 POST /admin/t/team-a/es/template；只开放与本租户 pipeline 相关的接口（见 tenantRoutes），集群级接口不开放。
+// 鉴权：Authorization: Bearer <token>。租户 token 只能访问本租户；配置了 tenants 时必须配置 tenancy.admin_tokens，
+// 全局 /admin 接口要求 admin token，admin token 也可访问任意租户。未配置 tenants 时行为不变。
+// 租户资源的归属标记为 <ownership.managed_by>/<tenant>，并带 tenant 标签；主实例的 GC 不会处理它们。
+
+type TenancyConfig struct {
+	AdminTokens []string       `yaml:"admin_tokens"` // 平台管理员 token；配置了 tenants 时必填，未配置 tenants 时全局接口不鉴权
+	Tenants     []TenantConfig `yaml:"tenants"`
+}
+
+type TenantConfig struct {
+	Name   string   `yaml:"name"`
+	Tokens []string `yaml:"tokens"`
+	Topic  string   `yaml:"topic"` // 租户日志所在的 Kafka topic
+	// 资源名，默认按租户名生成：logs-<name>、<name>-ilm、<name>-template、<name>-pipeline、sink-es-<name>
+	Names struct {
+		DataStream    string `yaml:"data_stream"`
+		ILMPolicy     string `yaml:"ilm_policy"`
+		IndexTemplate string `yaml:"index_template"`
+		Pipeline      string `yaml:"pipeline"`
+		Sink          string `yaml:"sink"`
+	} `yaml:"names"`
+	// 资产文件；留空使用内嵌默认文档
+	Files struct {
+		ILM      string `yaml:"ilm"`
+		Template string `yaml:"template"`
+		Pipeline string `yaml:"pipeline"`
+		Sink     string `yaml:"sink"`
+	} `yaml:"files"`
+	Sink      ESSinkSettings    `yaml:"sink"` // 由配置生成 sink connector（essink.go），设置后忽略 files.sink
+	Labels    map[string]string `yaml:"labels"`
+	Protected bool              `yaml:"protected"` // 下发须经审批（plans.go）；全局 approvals.protected 时总是受保护
+	// 覆盖全局 guardrails 中的项（guardrails.go）
+	Guardrails GuardrailsConfig `yaml:"guardrails"`
+	Quota      struct {
+		RequestsPerMinute int `yaml:"requests_per_minute"` // 0 不限
+		MaxConcurrent     int `yaml:"max_concurrent"`      // 同时处理的请求数，0 不限
+	} `yaml:"quota"`
+}
+
+// 填充默认名字
+func (t TenantConfig) withDefaults() TenantConfig {
+	or := func(v *string, def string) {
+		if *v == "" {
+			*v = def
+		}
+	}
+	or(&t.Names.DataStream, "logs-"+t.Name)
+	or(&t.Names.ILMPolicy, t.Name+"-ilm")
+	or(&t.Names.IndexTemplate, t.Name+"-template")
+	or(&t.Names.Pipeline, t.Name+"-pipeline")
+	or(&t.Names.Sink, "sink-es-"+t.Name)
+	return t
+}
+
+// 解析配置时调用：名字、token 不得与其他租户或主配置重复
+func (c TenancyConfig) validate(cfg *Config) error {
+	owner := map[string]string{}
+	claim := func(kind, name, who string) error {
+		if name == "" {
+			return nil
+		}
+		key := kind + "/" + name
+		if prev, ok := owner[key]; ok && prev != who {
+			return fmt.Errorf("tenancy: %s %q is used by both %s and %s", kind, name, prev, who)
+		}
+		owner[key] = who
+		return nil
+	}
+	primary := "the main pipeline"
+	for _, kv := range [][2]string{
+		{"data stream", cfg.ES.Names.DataStream}, {"ilm policy", cfg.ES.Names.ILMPolicy},
+		{"index template", cfg.ES.Names.IndexTemplate}, {"pipeline", cfg.ES.Names.Pipeline}, {"connector", cfg.Connect.Names.Sink},
+	} {
+		_ = claim(kv[0], kv[1], primary)
+	}
+	// 没有 admin token 时不带 token 的请求可访问全部全局接口，租户去掉 token 即可操作其他租户的资源
+	if len(c.Tenants) > 0 && len(c.AdminTokens) == 0 {
+		return fmt.Errorf("tenancy.admin_tokens is required when tenancy.tenants is set")
+	}
+	for _, tok := range c.AdminTokens {
+		_ = claim("token", tok, "admin_tokens")
+	}
+	for _, t := range c.Tenants {
+		if t.Name == "" || strings.ContainsAny(t.Name, "/ ") {
+			return fmt.Errorf("tenancy.tenants: invalid name %q", t.Name)
+		}
+		who := "tenant " + t.Name
+		if err := claim("tenant", t.Name, who); err != nil {
+			return err
+		}
+		if len(t.Tokens) == 0 {
+			return fmt.Errorf("tenancy.tenants[%s]: at least one token is required", t.Name)
+		}
+		if t.Topic == "" {
+			return fmt.Errorf("tenancy.tenants[%s]: topic is required", t.Name)
+		}
+		t = t.withDefaults()
+		for _, kv := range [][2]string{
+			{"data stream", t.Names.DataStream}, {"ilm policy", t.Names.ILMPolicy}, {"index template", t.Names.IndexTemplate},
+			{"pipeline", t.Names.Pipeline}, {"connector", t.Names.Sink},
+		} {
+			if err := claim(kv[0], kv[1], who); err != nil {
+				return err
+			}
+		}
+		for _, tok := range t.Tokens {
+			if err := claim("token", tok, who); err != nil {
+				return fmt.Errorf("tenancy: a token of %s is reused", who)
+			}
+		}
+	}
+	return nil
+}
+
+type tenant struct {
+	cfg TenantConfig
+	srv *Server
+	mux *http.ServeMux
+
+	sem     chan struct{} // max_concurrent
+	mu      sync.Mutex
+	window  time.Time // 当前分钟
+	counter int
+}
+
+// 租户的配置：共用全局的连接与行为设置，替换 pipeline 相关部分
+func (s *Server) tenantConfig(t TenantConfig) Config {
+	cfg := s.cfg
+	n := &cfg.ES.Names
+	n.DataStream, n.ILMPolicy, n.IndexTemplate, n.Pipeline = t.Names.DataStream, t.Names.ILMPolicy, t.Names.IndexTemplate, t.Names.Pipeline
+	n.templated, n.indexPattern = false, ""
+	cfg.ES.Files.ILM, cfg.ES.Files.Template, cfg.ES.Files.Pipeline = t.Files.ILM, t.Files.Template, t.Files.Pipeline
+	cfg.Kafka.Topic = t.Topic
+	cfg.Connect.Names.Sink, cfg.Connect.Files.Sink = t.Names.Sink, t.Files.Sink
+	cfg.Sink = SinkConfig{Type: sinkTypeConnect, Name: t.Names.Sink, Topics: []string{t.Topic}, File: t.Files.Sink, Elasticsearch: t.Sink}
+	cfg.Sinks, cfg.Transforms, cfg.Watches = nil, nil, nil
+	cfg.Routing = RoutingConfig{}
+	cfg.Ownership.ManagedBy = s.managedBy() + "/" + t.Name
+	labels := map[string]string{}
+	for k, v := range s.cfg.Ownership.Labels {
+		labels[k] = v
+	}
+	for k, v := range t.Labels {
+		labels[k] = v
+	}
+	labels["tenant"] = t.Name
+	cfg.Ownership.Labels = labels
+	cfg.Guardrails = s.cfg.Guardrails.merge(t.Guardrails)
+	cfg.Approvals.Protected = s.cfg.Approvals.Protected || t.Protected
+	if cfg.Approvals.Environment != "" {
+		cfg.Approvals.Environment += "/" + t.Name
+	}
+	cfg.Assets.Dir = filepath.Join(s.assetsDir(), "tenants", t.Name)
+	return cfg
+}
+
+func (s *Server) assetsDir() string {
+	if s.cfg.Assets.Dir != "" {
+		return s.cfg.Assets.Dir
+	}
+	return defaultAssetsDir
+}
+
+// main 中调用：构建各租户的 Server 与路由
+func (s *Server) initTenants() error {
+	s.tenants = map[string]*tenant{}
+	for _, tc := range s.cfg.Tenancy.Tenants {
+		tc = tc.withDefaults()
+		cfg := s.tenantConfig(tc)
+		ts := s.pipelineServer(cfg)
+		ts.git = nil // 资产 git 仓库是全局的，租户不能用 ?ref= 读取
+		ts.cache = newResponseCache(mustParseDuration("cache.ttl", cfg.Cache.TTL))
+		ts.assets = newAssetStore(cfg.Assets)
+		ts.setup = newSetupStore(cfg.Assets)
+		ts.desired = newDesiredStore(cfg.Assets)
+		ts.plans = newPlanStore(cfg)
+		if err := ts.assets.load(); err != nil {
+			return fmt.Errorf("tenant %s: load asset versions: %w", tc.Name, err)
+		}
+		if err := ts.setup.load(); err != nil {
+			return fmt.Errorf("tenant %s: load setup state: %w", tc.Name, err)
+		}
+		if err := ts.desired.load(); err != nil {
+			return fmt.Errorf("tenant %s: load connector desired state: %w", tc.Name, err)
+		}
+		if err := ts.plans.load(); err != nil {
+			return fmt.Errorf("tenant %s: load plans: %w", tc.Name, err)
+		}
+		t := &tenant{cfg: tc, srv: ts, mux: ts.tenantRoutes()}
+		if n := tc.Quota.MaxConcurrent; n > 0 {
+			t.sem = make(chan struct{}, n)
+		}
+		s.tenants[tc.Name] = t
+	}
+	if len(s.tenants) > 0 {
+		s.logger.Printf("tenancy: %d tenant(s), admin tokens=%d", len(s.tenants), len(s.cfg.Tenancy.AdminTokens))
+	}
+	return nil
+}
+
+// 租户可用的接口：只涉及本租户 pipeline 的资源
+func (s *Server) tenantRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/setup/state", s.handleGetSetupState)
+	mux.HandleFunc("PUT /admin/setup/state", s.withSchema("setup-state", s.handlePutSetupState))
+
+	mux.HandleFunc("POST /admin/es/data-stream", s.trackSetupStep("data_stream", s.withLock(s.handleCreateDataStream)))
+	mux.HandleFunc("POST /admin/es/ilm", s.trackSetupStep("ilm", s.withLock(s.handlePutILM)))
+	mux.HandleFunc("POST /admin/es/template", s.trackSetupStep("template", s.withLock(s.handlePutTemplate)))
+	mux.HandleFunc("POST /admin/es/template/simulate", s.handleSimulateTemplate)
+	mux.HandleFunc("POST /admin/es/pipeline", s.trackSetupStep("pipeline", s.withLock(s.handlePutPipeline)))
+	mux.HandleFunc("PATCH /admin/es/{kind}", s.withLock(s.handlePatchAsset))
+	mux.HandleFunc("POST /admin/connect/sink", s.trackSetupStep("sink", s.withLock(s.handleRegisterSink)))
+
+	mux.HandleFunc("GET /admin/verify/ilm-explain", s.cacheGET("ilm-explain", s.handleVerifyILMExplain))
+	mux.HandleFunc("GET /admin/verify/template", s.cacheGET("template", s.handleVerifyTemplate))
+	mux.HandleFunc("GET /admin/verify/pipeline", s.cacheGET("pipeline", s.handleVerifyPipeline))
+	mux.HandleFunc("GET /admin/verify/sink-status", s.cacheGET("sink-status", s.handleVerifySinkStatus))
+	mux.HandleFunc("GET /admin/verify/data-stream", s.cacheGET("data-stream", s.handleVerifyDataStream))
+	mux.HandleFunc("GET /admin/verify/kafka-topic", s.handleVerifyKafkaTopic)
+	mux.HandleFunc("GET /admin/verify/all", s.handleVerifyAll)
+	mux.HandleFunc("GET /admin/report", s.handleReport)
+	mux.HandleFunc("GET /admin/es/slowlog", s.handleSlowlog)
+
+	mux.HandleFunc("GET /admin/connect/config", s.handleGetSinkConfig)
+	mux.HandleFunc("PUT /admin/connect/pause", s.withLock(s.handlePauseSink))
+	mux.HandleFunc("PUT /admin/connect/resume", s.withLock(s.handleResumeSink))
+	mux.HandleFunc("DELETE /admin/connect/delete", s.withLock(s.handleDeleteSink))
+
+	mux.HandleFunc("GET /admin/plans", s.handleListPlans)
+	mux.HandleFunc("POST /admin/plans", s.withSchema("plan", s.handleCreatePlan))
+	mux.HandleFunc("GET /admin/plans/{id}", s.handleGetPlan)
+	mux.HandleFunc("POST /admin/plans/{id}/approve", s.withSchema("plan-approve", s.handleApprovePlan))
+	mux.HandleFunc("POST /admin/plans/{id}/apply", s.withLock(s.handleApplyPlan))
+
+	mux.HandleFunc("GET /admin/assets/lint", s.handleLintAssets)
+	mux.HandleFunc("GET /admin/assets/ecs", s.handleECSReport)
+	mux.HandleFunc("GET /admin/assets/{kind}/versions", s.handleListAssetVersions)
+	mux.HandleFunc("GET /admin/assets/{kind}/versions/{version}", s.handleGetAssetVersion)
+	mux.HandleFunc("POST /admin/assets/{kind}/rollback/{version}", s.withLock(s.handleRollbackAsset))
+	return mux
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if tok, ok := strings.CutPrefix(h, "Bearer "); ok {
+		return strings.TrimSpace(tok)
+	}
+	return ""
+}
+
+func tokenIn(tok string, list []string) bool {
+	if tok == "" {
+		return false
+	}
+	for _, t := range list {
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// token 所属租户；不是租户 token 返回空
+func (s *Server) tenantOfToken(tok string) string {
+	for name, t := range s.tenants {
+		if tokenIn(tok, t.cfg.Tokens) {
+			return name
+		}
+	}
+	return ""
+}
+
+// 全局接口（REST 与 gRPC）的 token 校验：未配置 tenants 时不鉴权；租户 token 返回 403，非 admin token 返回 401
+func (s *Server) checkGlobalToken(tok string) (int, error) {
+	if len(s.tenants) == 0 {
+		return http.StatusOK, nil
+	}
+	if name := s.tenantOfToken(tok); name != "" {
+		return http.StatusForbidden, fmt.Errorf("tenant %s may only use /admin/t/%s/", name, name)
+	}
+	if !tokenIn(tok, s.cfg.Tenancy.AdminTokens) {
+		return http.StatusUnauthorized, fmt.Errorf("admin token required")
+	}
+	return http.StatusOK, nil
+}
+
+// 全局 /admin 接口的鉴权：租户 token 一律拒绝，其余要求 admin token（配置了 tenants 时 admin_tokens 必填）
+func (s *Server) tenantGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.tenants) == 0 || strings.HasPrefix(r.URL.Path, "/admin/t/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tok := bearerToken(r)
+		if name := s.tenantOfToken(tok); name != "" {
+			logField(r, "tenant", name)
+		}
+		if code, err := s.checkGlobalToken(tok); err != nil {
+			if code == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-pipeline-server"`)
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// /admin/t/{tenant}/...：鉴权、配额，然后交给租户的路由（路径去掉 /t/{tenant}）
+func (s *Server) handleTenant(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("tenant")
+	t, ok := s.tenants[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("tenant %q not configured", name)})
+		return
+	}
+	logField(r, "tenant", name)
+	tok := bearerToken(r)
+	switch {
+	case tokenIn(tok, t.cfg.Tokens), tokenIn(tok, s.cfg.Tenancy.AdminTokens):
+	case tok == "":
+		w.Header().Set("WWW-Authenticate", `Bearer realm="go-pipeline-server"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "bearer token required"})
+		return
+	default:
+		s.logger.Printf("step=tenant name=%s denied path=%s from=%s", name, r.URL.Path, clientIP(r))
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("token is not valid for tenant %s", name)})
+		return
+	}
+	release, retryAfter, ok := t.admit()
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": fmt.Sprintf("tenant %s is over its request quota", name), "retry_after": retryAfter})
+		return
+	}
+	defer release()
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/admin" + strings.TrimPrefix(r.URL.Path, "/admin/t/"+name)
+	r2.URL.RawPath = ""
+	r2.Header.Set("X-Operator", name+"/"+operatorIdentity(r))
+	t.srv.bustCacheOnWrite(t.mux).ServeHTTP(w, r2)
+}
+
+// 配额：每分钟请求数与并发数；返回 release 与被拒绝时建议的重试秒数
+func (t *tenant) admit() (func(), int, bool) {
+	if n := t.cfg.Quota.RequestsPerMinute; n > 0 {
+		t.mu.Lock()
+		now := time.Now()
+		if now.Sub(t.window) >= time.Minute {
+			t.window, t.counter = now, 0
+		}
+		if t.counter >= n {
+			wait := int(time.Minute-now.Sub(t.window))/int(time.Second) + 1
+			t.mu.Unlock()
+			return nil, wait, false
+		}
+		t.counter++
+		t.mu.Unlock()
+	}
+	if t.sem == nil {
+		return func() {}, 0, true
+	}
+	select {
+	case t.sem <- struct{}{}:
+		return func() { <-t.sem }, 0, true
+	default:
+		return nil, 1, false
+	}
+}
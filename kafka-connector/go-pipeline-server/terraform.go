@@ -0,0 +1,679 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+/************** 导出 Terraform（HCL） **************/
+
+// GET /admin/export/terraform 读取集群中当前的 ILM 策略、索引模板、ingest pipeline、data stream、
+// connector 及其 topic，输出 elastic/elasticstack、Mongey/kafka、Mongey/kafka-connect provider 的 HCL。
+// 默认附带 Terraform 1.5+ 的 import 块，首次 apply 即接管现有资源而不是重建；?imports=false 关闭。
+// 密码类配置（redact.keys 匹配的 key）改为 sensitive 变量引用，不写入导出内容。
+// 只支持 elasticsearch flavor（OpenSearch 的 ISM 不在 elasticstack provider 范围内）。
+
+var (
+	hclIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	tfNameRe   = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// ILM action 中以 JSON 字符串传给 provider 的字段
+var tfILMJSONAttrs = map[string]bool{"include": true, "exclude": true, "require": true}
+
+var tfILMPhases = []string{"hot", "warm", "cold", "frozen", "delete"}
+
+// kafka provider 的 sasl_mechanism 取值
+var tfKafkaSASL = map[string]string{
+	"PLAIN": "plain", "SCRAM-SHA-256": "scram-sha256", "SCRAM-SHA-512": "scram-sha512", "AWS_MSK_IAM": "aws-iam",
+}
+
+type hclWriter struct {
+	b      bytes.Buffer
+	indent int
+}
+
+func (h *hclWriter) line(format string, args ...any) {
+	if format == "" {
+		h.b.WriteString("\n")
+		return
+	}
+	h.b.WriteString(strings.Repeat("  ", h.indent))
+	fmt.Fprintf(&h.b, format, args...)
+	h.b.WriteString("\n")
+}
+
+func (h *hclWriter) open(header string) {
+	h.line("%s {", header)
+	h.indent++
+}
+
+func (h *hclWriter) close() {
+	h.indent--
+	h.line("}")
+}
+
+func (h *hclWriter) attr(name string, v any) {
+	h.line("%s = %s", hclKey(name), hclValue(v, h.indent))
+}
+
+// 原样输出表达式（变量引用、jsonencode 等）
+func (h *hclWriter) expr(name, expr string) {
+	h.line("%s = %s", hclKey(name), expr)
+}
+
+func (h *hclWriter) jsonAttr(name string, v any) {
+	h.expr(name, "jsonencode("+hclValue(v, h.indent)+")")
+}
+
+func hclKey(k string) string {
+	if hclIdentRe.MatchString(k) {
+		return k
+	}
+	return hclString(k)
+}
+
+// JSON 转义与 HCL 兼容；另需转义模板插值 ${ 与 %{
+func hclString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	out := strings.TrimSuffix(b.String(), "\n")
+	out = strings.ReplaceAll(out, "${", "$${")
+	return strings.ReplaceAll(out, "%{", "%%{")
+}
+
+func hclValue(v any, indent int) string {
+	pad := strings.Repeat("  ", indent)
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return hclString(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case int:
+		return strconv.Itoa(t)
+	case []string:
+		items := make([]any, len(t))
+		for i, s := range t {
+			items[i] = s
+		}
+		return hclValue(items, indent)
+	case []any:
+		if len(t) == 0 {
+			return "[]"
+		}
+		scalar := true
+		for _, e := range t {
+			switch e.(type) {
+			case map[string]any, []any:
+				scalar = false
+			}
+		}
+		parts := make([]string, len(t))
+		for i, e := range t {
+			parts[i] = hclValue(e, indent+1)
+		}
+		if scalar {
+			return "[" + strings.Join(parts, ", ") + "]"
+		}
+		return "[\n" + pad + "  " + strings.Join(parts, ",\n"+pad+"  ") + ",\n" + pad + "]"
+	case map[string]string:
+		m := make(map[string]any, len(t))
+		for k, s := range t {
+			m[k] = s
+		}
+		return hclValue(m, indent)
+	case map[string]any:
+		if len(t) == 0 {
+			return "{}"
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s  %s = %s\n", pad, hclKey(k), hclValue(t[k], indent+1))
+		}
+		b.WriteString(pad + "}")
+		return b.String()
+	}
+	return hclString(fmt.Sprint(v))
+}
+
+func tfName(prefix, s string) string {
+	n := strings.Trim(tfNameRe.ReplaceAllString(strings.ToLower(s), "_"), "_")
+	if prefix != "" {
+		n = prefix + "_" + n
+	}
+	return n
+}
+
+func decodeJSONNumber(b []byte, out any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+type tfExport struct {
+	h         hclWriter
+	imports   bool
+	clusterID string
+	vars      []string // sensitive 变量名
+	skipped   []string
+}
+
+func (t *tfExport) importBlock(to, id string) {
+	if !t.imports {
+		return
+	}
+	t.h.open("import")
+	t.h.expr("to", to)
+	t.h.attr("id", id)
+	t.h.close()
+	t.h.line("")
+}
+
+func (t *tfExport) sensitiveVar(name string) string {
+	if !slices.Contains(t.vars, name) {
+		t.vars = append(t.vars, name)
+	}
+	return "var." + name
+}
+
+func (s *Server) handleExportTerraform(w http.ResponseWriter, r *http.Request) {
+	if s.isOpenSearch() {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"step": "export-terraform", "error": "terraform export supports the elasticsearch flavor only"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	t := &tfExport{imports: r.URL.Query().Get("imports") != "false"}
+	body, err := s.renderTerraform(ctx, t)
+	if err != nil {
+		s.logger.Printf("step=export-terraform err=%v", err)
+		writeJSON(w, http.StatusBadGateway, errorBody("export-terraform", err))
+		return
+	}
+	s.logger.Printf("step=export-terraform bytes=%d skipped=%v", len(body), t.skipped)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="log-pipeline.tf"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// 读取 ES 资源；404 返回 nil, nil
+func (s *Server) tfGetES(ctx context.Context, path string) ([]byte, error) {
+	resp, body, err := s.doGET(ctx, s.cfg.ES.Host+path, "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, string(body))
+	}
+	return body, nil
+}
+
+func (s *Server) renderTerraform(ctx context.Context, t *tfExport) ([]byte, error) {
+	root, err := s.tfGetES(ctx, "/")
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		ClusterUUID string `json:"cluster_uuid"`
+	}
+	_ = json.Unmarshal(root, &info)
+	t.clusterID = info.ClusterUUID
+	if t.imports && t.clusterID == "" {
+		t.skipped = append(t.skipped, "import blocks for elasticsearch resources (cluster_uuid unavailable)")
+	}
+
+	if err := s.tfILM(ctx, t); err != nil {
+		return nil, err
+	}
+	if err := s.tfTemplate(ctx, t); err != nil {
+		return nil, err
+	}
+	if err := s.tfPipeline(ctx, t); err != nil {
+		return nil, err
+	}
+	if err := s.tfDataStream(ctx, t); err != nil {
+		return nil, err
+	}
+	topics, err := s.tfConnectors(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	s.tfTopics(ctx, t, topics)
+
+	// 头部：provider 与变量（在资源之后生成，才知道用到了哪些 sensitive 变量）
+	head := &hclWriter{}
+	head.line("# generated by go-pipeline-server GET /admin/export/terraform at %s", time.Now().UTC().Format(time.RFC3339))
+	for _, sk := range t.skipped {
+		head.line("# skipped: %s", sk)
+	}
+	head.line("")
+	head.open("terraform")
+	head.open("required_providers")
+	head.attr("elasticstack", map[string]any{"source": "elastic/elasticstack", "version": "~> 0.11"})
+	head.attr("kafka", map[string]any{"source": "Mongey/kafka", "version": "~> 0.7"})
+	head.attr("kafka-connect", map[string]any{"source": "Mongey/kafka-connect", "version": "~> 0.4"})
+	head.close()
+	head.close()
+	head.line("")
+
+	head.open(`provider "elasticstack"`)
+	head.open("elasticsearch")
+	head.attr("endpoints", []string{s.cfg.ES.Host})
+	if s.cfg.ES.Username != "" {
+		head.attr("username", s.cfg.ES.Username)
+		head.expr("password", t.sensitiveVar("es_password"))
+	}
+	if !s.cfg.ES.VerifyTLS && strings.HasPrefix(s.cfg.ES.Host, "https://") {
+		head.attr("insecure", true)
+	}
+	head.close()
+	head.close()
+	head.line("")
+
+	head.open(`provider "kafka"`)
+	head.attr("bootstrap_servers", s.cfg.Kafka.Brokers)
+	head.attr("tls_enabled", s.cfg.Kafka.TLS.Enabled)
+	if m := strings.ToUpper(s.cfg.Kafka.SASL.Mechanism); m != "" {
+		head.attr("sasl_mechanism", tfKafkaSASL[m])
+		if m != "AWS_MSK_IAM" {
+			head.attr("sasl_username", s.cfg.Kafka.SASL.Username)
+			head.expr("sasl_password", t.sensitiveVar("kafka_sasl_password"))
+		}
+	}
+	head.close()
+	head.line("")
+
+	head.open(`provider "kafka-connect"`)
+	head.attr("url", s.cfg.Connect.Host)
+	if s.cfg.Connect.Username != "" {
+		head.attr("basic_auth_username", s.cfg.Connect.Username)
+		head.expr("basic_auth_password", t.sensitiveVar("connect_password"))
+	}
+	head.close()
+	head.line("")
+
+	for _, v := range t.vars {
+		head.open(fmt.Sprintf("variable %q", v))
+		head.expr("type", "string")
+		head.attr("sensitive", true)
+		head.close()
+		head.line("")
+	}
+	return append(head.b.Bytes(), t.h.b.Bytes()...), nil
+}
+
+// elasticstack 的 import ID 为 <cluster_uuid>/<name>；取不到 uuid 时不生成 import 块
+func (t *tfExport) esImport(addr, name string) {
+	if t.clusterID == "" {
+		return
+	}
+	t.importBlock(addr, t.clusterID+"/"+name)
+}
+
+func (s *Server) tfILM(ctx context.Context, t *tfExport) error {
+	name := s.cfg.ES.Names.ILMPolicy
+	b, err := s.tfGetES(ctx, "/_ilm/policy/"+url.PathEscape(name))
+	if err != nil || b == nil {
+		if b == nil && err == nil {
+			t.skipped = append(t.skipped, "ilm policy "+name+" (not found)")
+		}
+		return err
+	}
+	var resp map[string]struct {
+		Policy struct {
+			Phases map[string]map[string]any `json:"phases"`
+			Meta   map[string]any            `json:"_meta"`
+		} `json:"policy"`
+	}
+	if err := decodeJSONNumber(b, &resp); err != nil {
+		return fmt.Errorf("decode ilm policy: %w", err)
+	}
+	p := resp[name].Policy
+	addr := "elasticstack_elasticsearch_index_lifecycle.ilm"
+	t.esImport(addr, name)
+	h := &t.h
+	h.open(`resource "elasticstack_elasticsearch_index_lifecycle" "ilm"`)
+	h.attr("name", name)
+	if len(p.Meta) > 0 {
+		h.jsonAttr("metadata", p.Meta)
+	}
+	for _, phase := range tfILMPhases {
+		ph, ok := p.Phases[phase]
+		if !ok {
+			continue
+		}
+		h.line("")
+		h.open(phase)
+		if v, ok := ph["min_age"]; ok {
+			h.attr("min_age", v)
+		}
+		actions, _ := ph["actions"].(map[string]any)
+		names := make([]string, 0, len(actions))
+		for a := range actions {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		for _, a := range names {
+			params, _ := actions[a].(map[string]any)
+			h.open(a)
+			keys := make([]string, 0, len(params))
+			for k := range params {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if tfILMJSONAttrs[k] {
+					h.jsonAttr(k, params[k])
+				} else {
+					h.attr(k, params[k])
+				}
+			}
+			h.close()
+		}
+		h.close()
+	}
+	h.close()
+	h.line("")
+	return nil
+}
+
+func (s *Server) tfTemplate(ctx context.Context, t *tfExport) error {
+	name := s.cfg.ES.Names.IndexTemplate
+	b, err := s.tfGetES(ctx, "/_index_template/"+url.PathEscape(name))
+	if err != nil || b == nil {
+		if b == nil && err == nil {
+			t.skipped = append(t.skipped, "index template "+name+" (not found)")
+		}
+		return err
+	}
+	var resp struct {
+		IndexTemplates []struct {
+			IndexTemplate map[string]any `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := decodeJSONNumber(b, &resp); err != nil || len(resp.IndexTemplates) == 0 {
+		return fmt.Errorf("decode index template: %v", err)
+	}
+	it := resp.IndexTemplates[0].IndexTemplate
+	addr := "elasticstack_elasticsearch_index_template.template"
+	t.esImport(addr, name)
+	h := &t.h
+	h.open(`resource "elasticstack_elasticsearch_index_template" "template"`)
+	h.attr("name", name)
+	for _, k := range []string{"index_patterns", "composed_of", "priority", "version", "allow_auto_create"} {
+		if v, ok := it[k]; ok {
+			h.attr(k, v)
+		}
+	}
+	if meta, ok := it["_meta"]; ok {
+		h.jsonAttr("metadata", meta)
+	}
+	if ds, ok := it["data_stream"].(map[string]any); ok {
+		h.open("data_stream")
+		for _, k := range []string{"hidden", "allow_custom_routing"} {
+			if v, ok := ds[k]; ok {
+				h.attr(k, v)
+			}
+		}
+		h.close()
+	}
+	if tpl, ok := it["template"].(map[string]any); ok {
+		h.open("template")
+		for _, k := range []string{"settings", "mappings"} {
+			if v, ok := tpl[k]; ok {
+				h.jsonAttr(k, v)
+			}
+		}
+		aliases, _ := tpl["aliases"].(map[string]any)
+		names := make([]string, 0, len(aliases))
+		for a := range aliases {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		for _, a := range names {
+			h.open("alias")
+			h.attr("name", a)
+			opts, _ := aliases[a].(map[string]any)
+			keys := make([]string, 0, len(opts))
+			for k := range opts {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if k == "filter" {
+					h.jsonAttr(k, opts[k])
+				} else {
+					h.attr(k, opts[k])
+				}
+			}
+			h.close()
+		}
+		h.close()
+	}
+	h.close()
+	h.line("")
+	return nil
+}
+
+func (s *Server) tfPipeline(ctx context.Context, t *tfExport) error {
+	name := s.cfg.ES.Names.Pipeline
+	if name == "" {
+		return nil
+	}
+	b, err := s.tfGetES(ctx, "/_ingest/pipeline/"+url.PathEscape(name))
+	if err != nil || b == nil {
+		if b == nil && err == nil {
+			t.skipped = append(t.skipped, "ingest pipeline "+name+" (not found)")
+		}
+		return err
+	}
+	var resp map[string]map[string]any
+	if err := decodeJSONNumber(b, &resp); err != nil {
+		return fmt.Errorf("decode ingest pipeline: %w", err)
+	}
+	p := resp[name]
+	addr := "elasticstack_elasticsearch_ingest_pipeline.pipeline"
+	t.esImport(addr, name)
+	h := &t.h
+	h.open(`resource "elasticstack_elasticsearch_ingest_pipeline" "pipeline"`)
+	h.attr("name", name)
+	if v, ok := p["description"]; ok {
+		h.attr("description", v)
+	}
+	// provider 要求每个 processor 是一段 JSON 字符串
+	for _, k := range []string{"processors", "on_failure"} {
+		list, _ := p[k].([]any)
+		if len(list) == 0 {
+			continue
+		}
+		h.line("%s = [", k)
+		h.indent++
+		for _, proc := range list {
+			h.line("jsonencode(%s),", hclValue(proc, h.indent))
+		}
+		h.indent--
+		h.line("]")
+	}
+	if meta, ok := p["_meta"]; ok {
+		h.jsonAttr("metadata", meta)
+	}
+	h.close()
+	h.line("")
+	return nil
+}
+
+func (s *Server) tfDataStream(ctx context.Context, t *tfExport) error {
+	name := s.cfg.ES.Names.DataStream
+	b, err := s.tfGetES(ctx, "/_data_stream/"+url.PathEscape(name))
+	if err != nil || b == nil {
+		if b == nil && err == nil {
+			t.skipped = append(t.skipped, "data stream "+name+" (not found)")
+		}
+		return err
+	}
+	t.esImport("elasticstack_elasticsearch_data_stream.data_stream", name)
+	h := &t.h
+	h.open(`resource "elasticstack_elasticsearch_data_stream" "data_stream"`)
+	h.attr("name", name)
+	h.line("")
+	h.expr("depends_on", "[elasticstack_elasticsearch_index_template.template]")
+	h.close()
+	h.line("")
+	return nil
+}
+
+// 返回 connector 用到的 topic（含 DLQ），供 kafka_topic 资源使用
+func (s *Server) tfConnectors(ctx context.Context, t *tfExport) ([]string, error) {
+	topics := map[string]bool{}
+	if s.cfg.Kafka.Topic != "" {
+		topics[s.cfg.Kafka.Topic] = true
+	}
+	for _, sc := range s.sinkConfigs() {
+		if sc.Type != sinkTypeConnect && sc.Type != sinkTypeS3 && sc.Type != sinkTypeMirrorMaker {
+			t.skipped = append(t.skipped, fmt.Sprintf("sink %s (type %s, not a Kafka Connect connector)", sc.Name, sc.Type))
+			continue
+		}
+		resp, body, err := s.doGET(ctx, fmt.Sprintf("%s/connectors/%s/config", s.cfg.Connect.Host, url.PathEscape(sc.Name)), "connect")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			t.skipped = append(t.skipped, "connector "+sc.Name+" (not found)")
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("GET connector %s config: %s: %s", sc.Name, resp.Status, string(body))
+		}
+		var cfg map[string]string
+		if err := json.Unmarshal(body, &cfg); err != nil {
+			return nil, fmt.Errorf("decode connector %s config: %w", sc.Name, err)
+		}
+		for _, tp := range strings.Split(cfg["topics"], ",") {
+			if tp = strings.TrimSpace(tp); tp != "" {
+				topics[tp] = true
+			}
+		}
+		if dlq := cfg["errors.deadletterqueue.topic.name"]; dlq != "" {
+			topics[dlq] = true
+		}
+		plain, sensitive := map[string]any{}, map[string]string{}
+		for k, v := range cfg {
+			if s.redact.matchKey(k) {
+				sensitive[k] = t.sensitiveVar(tfName("connector_"+sc.Name, k))
+			} else {
+				plain[k] = v
+			}
+		}
+		h := &t.h
+		h.open(fmt.Sprintf(`resource "kafka-connect_connector" %q`, tfName("sink", sc.Name)))
+		h.attr("name", sc.Name)
+		h.attr("config", plain)
+		if len(sensitive) > 0 {
+			keys := make([]string, 0, len(sensitive))
+			for k := range sensitive {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			h.open("config_sensitive =") // map 属性，不是 block
+			for _, k := range keys {
+				h.expr(k, sensitive[k])
+			}
+			h.close()
+		}
+		h.close()
+		h.line("")
+	}
+	out := make([]string, 0, len(topics))
+	for tp := range topics {
+		out = append(out, tp)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// topic 的分区数、副本数与显式设置过的配置；Kafka 不可达时只在头部注明跳过
+func (s *Server) tfTopics(ctx context.Context, t *tfExport, topics []string) {
+	if len(topics) == 0 {
+		return
+	}
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		t.skipped = append(t.skipped, fmt.Sprintf("kafka topics %v (%v)", topics, err))
+		return
+	}
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		t.skipped = append(t.skipped, fmt.Sprintf("kafka topics %v (%v)", topics, err))
+		return
+	}
+	defer release()
+	md, err := adm.Metadata(ctx, topics...)
+	if err != nil {
+		t.skipped = append(t.skipped, fmt.Sprintf("kafka topics %v (%v)", topics, err))
+		return
+	}
+	configs := map[string]map[string]any{}
+	if rcs, err := adm.DescribeTopicConfigs(ctx, topics...); err == nil {
+		for _, rc := range rcs {
+			m := map[string]any{}
+			for _, c := range rc.Configs {
+				if c.Source == kmsg.ConfigSourceDynamicTopicConfig && c.Value != nil {
+					m[c.Key] = *c.Value
+				}
+			}
+			configs[rc.Name] = m
+		}
+	}
+	for _, name := range topics {
+		td, ok := md.Topics[name]
+		if !ok || td.Err != nil {
+			t.skipped = append(t.skipped, "kafka topic "+name+" (not found)")
+			continue
+		}
+		rf := 0
+		for _, p := range td.Partitions {
+			rf = len(p.Replicas)
+			break
+		}
+		addr := "kafka_topic." + tfName("topic", name)
+		t.importBlock(addr, name)
+		h := &t.h
+		h.open(fmt.Sprintf(`resource "kafka_topic" %q`, tfName("topic", name)))
+		h.attr("name", name)
+		h.attr("partitions", len(td.Partitions))
+		h.attr("replication_factor", rf)
+		if len(configs[name]) > 0 {
+			h.attr("config", configs[name])
+		}
+		h.close()
+		h.line("")
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/************** 端到端拓扑（GET /admin/topology） **************/
+
+// 从 Connect 与 ES 的元数据拼出 topic → connector → ingest pipeline → data stream → ILM 策略的有向图，前端据此画拓扑图：
+//   - connector 的 topics / topics.regex 连到 topic；ES sink 按 topic.to.external.resource.mapping 连到写入的 data stream
+//     （未配置映射时写入与 topic 同名的索引，不画）
+//   - pipeline 取 connector 的 ingest.pipeline.name，未指定时取 data stream 模板的 index.default_pipeline；
+//     pipeline 中的 reroute 处理器（routing.go）再连到目标 data stream
+//   - data stream 连到其 ilm_policy
+// 节点 ID 为 <kind>:<name>；managed 表示在本服务配置中（主 pipeline、sinks、routing），missing 表示被引用但不存在。
+// Connect 或 ES 不可达时返回已拼出的部分，errors 中给出原因。
+// ?status=true 时在节点与边上附带运行状态，GET /admin/topology/stream 按间隔推送（topologylive.go）。
+
+const (
+	topoTopic      = "topic"
+	topoConnector  = "connector"
+	topoPipeline   = "pipeline"
+	topoDataStream = "data_stream"
+	topoILM        = "ilm_policy"
+)
+
+type topologyNode struct {
+	ID      string         `json:"id"`
+	Kind    string         `json:"kind"`
+	Name    string         `json:"name"`
+	Managed bool           `json:"managed,omitempty"`
+	Missing bool           `json:"missing,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Status  map[string]any `json:"status,omitempty"` // ?status=true 时的运行状态（topologylive.go）
+}
+
+type topologyEdge struct {
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+	Via    string         `json:"via,omitempty"` // 连接依据，如 topics、ingest.pipeline.name、default_pipeline、reroute
+	Status map[string]any `json:"status,omitempty"`
+}
+
+type topology struct {
+	Nodes  []*topologyNode `json:"nodes"`
+	Edges  []*topologyEdge `json:"edges"`
+	Errors []string        `json:"errors,omitempty"`
+
+	byID    map[string]*topologyNode
+	edges   map[[3]string]bool // from, to, via
+	backing map[string]string  // data stream 的 backing index -> data stream
+}
+
+func newTopology() *topology {
+	return &topology{Nodes: []*topologyNode{}, Edges: []*topologyEdge{}, byID: map[string]*topologyNode{}, edges: map[[3]string]bool{}, backing: map[string]string{}}
+}
+
+func (t *topology) node(kind, name string) *topologyNode {
+	id := kind + ":" + name
+	if n, ok := t.byID[id]; ok {
+		return n
+	}
+	n := &topologyNode{ID: id, Kind: kind, Name: name}
+	t.byID[id] = n
+	t.Nodes = append(t.Nodes, n)
+	return n
+}
+
+func (t *topology) link(from, to *topologyNode, via string) {
+	k := [3]string{from.ID, to.ID, via}
+	if !t.edges[k] {
+		t.edges[k] = true
+		t.Edges = append(t.Edges, &topologyEdge{From: from.ID, To: to.ID, Via: via})
+	}
+}
+
+func (t *topology) nodesOf(kind string) []*topologyNode {
+	var out []*topologyNode
+	for _, n := range t.Nodes {
+		if n.Kind == kind {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (t *topology) errorf(format string, args ...any) {
+	t.Errors = append(t.Errors, fmt.Sprintf(format, args...))
+}
+
+func (s *Server) getJSON(ctx context.Context, u, kind string, v any) (int, error) {
+	resp, body, err := s.doGET(ctx, u, kind)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return resp.StatusCode, json.Unmarshal(body, v)
+}
+
+// 本服务配置中的资源
+func (s *Server) markManaged(t *topology) {
+	names := s.cfg.ES.Names
+	mark := func(kind, name string) {
+		if name != "" {
+			t.node(kind, name).Managed = true
+		}
+	}
+	mark(topoPipeline, names.Pipeline)
+	mark(topoDataStream, names.DataStream)
+	mark(topoILM, names.ILMPolicy)
+	for _, sc := range s.sinkConfigs() {
+		if slices.Contains([]string{sinkTypeConnect, sinkTypeS3, sinkTypeMirrorMaker}, sc.Type) {
+			mark(topoConnector, sc.Name)
+		}
+	}
+	for _, r := range s.cfg.Routing.Rules {
+		res := s.cfg.routeResources(r)
+		mark(topoDataStream, res.DataStream)
+		mark(topoILM, res.ILMPolicy)
+	}
+}
+
+// connector 写入的 data stream 与显式指定的 pipeline；返回未指定 pipeline 的 data stream -> connector，
+// 稍后按模板的 default_pipeline 连接，没有默认 pipeline 时 connector 直连 data stream
+func (s *Server) topologyConnectors(ctx context.Context, t *topology) (defaultPipelineFrom map[string][]*topologyNode) {
+	defaultPipelineFrom = map[string][]*topologyNode{}
+	var conns map[string]struct {
+		Info struct {
+			Config map[string]string `json:"config"`
+			Type   string            `json:"type"`
+		} `json:"info"`
+	}
+	if _, err := s.getJSON(ctx, s.cfg.Connect.Host+"/connectors?expand=info", "connect", &conns); err != nil {
+		t.errorf("connect: %v", err)
+		return
+	}
+	for name, c := range conns {
+		cfg := c.Info.Config
+		cn := t.node(topoConnector, name)
+		cn.Attrs = map[string]any{}
+		if class := cfg["connector.class"]; class != "" {
+			cn.Attrs["class"] = class
+		}
+		if c.Info.Type != "" {
+			cn.Attrs["type"] = c.Info.Type
+		}
+		for _, topic := range strings.Split(cfg["topics"], ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				t.link(t.node(topoTopic, topic), cn, "topics")
+			}
+		}
+		if re := cfg["topics.regex"]; re != "" {
+			tn := t.node(topoTopic, re)
+			tn.Attrs = map[string]any{"regex": true}
+			t.link(tn, cn, "topics.regex")
+		}
+		var pipeline *topologyNode
+		if p := cfg["ingest.pipeline.name"]; p != "" && cfg["use.ingest.pipeline"] != "false" {
+			pipeline = t.node(topoPipeline, p)
+			t.link(cn, pipeline, "ingest.pipeline.name")
+		}
+		for _, pair := range strings.Split(cfg["topic.to.external.resource.mapping"], ",") {
+			_, target, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || target == "" {
+				continue
+			}
+			ds := t.node(topoDataStream, target)
+			if pipeline != nil {
+				t.link(pipeline, ds, "ingest")
+			} else {
+				defaultPipelineFrom[target] = append(defaultPipelineFrom[target], cn)
+			}
+		}
+	}
+	for _, n := range t.nodesOf(topoConnector) {
+		_, ok := conns[n.Name]
+		n.Missing = !ok
+	}
+	return defaultPipelineFrom
+}
+
+type topologyDataStream struct {
+	Name      string `json:"name"`
+	Template  string `json:"template"`
+	ILMPolicy string `json:"ilm_policy"`
+	Status    string `json:"status"`
+	Indices   []struct {
+		IndexName string `json:"index_name"`
+	} `json:"indices"`
+}
+
+func (s *Server) buildTopology(ctx context.Context) *topology {
+	t := newTopology()
+	s.markManaged(t)
+	defaultPipelineFrom := s.topologyConnectors(ctx, t)
+
+	var streams struct {
+		DataStreams []topologyDataStream `json:"data_streams"`
+	}
+	_, dsErr := s.getJSON(ctx, s.cfg.ES.Host+"/_data_stream", "es", &streams)
+	if dsErr != nil {
+		t.errorf("es data streams: %v", dsErr)
+	}
+	dataStreams := map[string]topologyDataStream{}
+	for _, ds := range streams.DataStreams {
+		dataStreams[ds.Name] = ds
+	}
+
+	// connector 未指定 pipeline 时由模板的 default_pipeline 执行
+	defaultPipeline := map[string]string{}
+	if len(defaultPipelineFrom) > 0 {
+		var tpls struct {
+			IndexTemplates []struct {
+				Name          string `json:"name"`
+				IndexTemplate struct {
+					Template struct {
+						Settings map[string]any `json:"settings"`
+					} `json:"template"`
+				} `json:"index_template"`
+			} `json:"index_templates"`
+		}
+		if _, err := s.getJSON(ctx, s.cfg.ES.Host+"/_index_template", "es", &tpls); err != nil {
+			t.errorf("es index templates: %v", err)
+		}
+		for _, tpl := range tpls.IndexTemplates {
+			if v, ok := lookupSetting(tpl.IndexTemplate.Template.Settings, "index.default_pipeline"); ok {
+				defaultPipeline[tpl.Name] = fmt.Sprint(v)
+			}
+		}
+	}
+	for ds, conns := range defaultPipelineFrom {
+		dn := t.node(topoDataStream, ds)
+		p := defaultPipeline[dataStreams[ds].Template]
+		if p == "" || p == "_none" {
+			for _, cn := range conns {
+				t.link(cn, dn, "topic.to.external.resource.mapping")
+			}
+			continue
+		}
+		pn := t.node(topoPipeline, p)
+		for _, cn := range conns {
+			t.link(cn, pn, "default_pipeline")
+		}
+		t.link(pn, dn, "ingest")
+	}
+
+	// 被引用 pipeline 中的 reroute 目标
+	var pipelineNames []string
+	for _, n := range t.nodesOf(topoPipeline) {
+		pipelineNames = append(pipelineNames, n.Name)
+	}
+	if len(pipelineNames) > 0 {
+		var pipelines map[string]struct {
+			Processors []map[string]map[string]any `json:"processors"`
+		}
+		u := s.cfg.ES.Host + "/_ingest/pipeline/" + url.PathEscape(strings.Join(pipelineNames, ","))
+		code, err := s.getJSON(ctx, u, "es", &pipelines)
+		listed := err == nil || code == http.StatusNotFound // 404：一个都不存在
+		if !listed {
+			t.errorf("es pipelines: %v", err)
+		}
+		for _, name := range pipelineNames {
+			p, ok := pipelines[name]
+			pn := t.node(topoPipeline, name)
+			if !ok {
+				pn.Missing = listed
+				continue
+			}
+			pn.Attrs = map[string]any{"processors": len(p.Processors)}
+			for _, proc := range p.Processors {
+				if rr, ok := proc["reroute"]; ok {
+					if dest, _ := rr["destination"].(string); dest != "" {
+						t.link(pn, t.node(topoDataStream, dest), "reroute")
+					}
+				}
+			}
+		}
+	}
+
+	for _, n := range t.nodesOf(topoDataStream) {
+		ds, ok := dataStreams[n.Name]
+		if !ok {
+			n.Missing = dsErr == nil
+			continue
+		}
+		n.Attrs = map[string]any{"template": ds.Template, "health": ds.Status, "backing_indices": len(ds.Indices)}
+		for _, idx := range ds.Indices {
+			t.backing[idx.IndexName] = ds.Name
+		}
+		if ds.ILMPolicy != "" {
+			t.link(n, t.node(topoILM, ds.ILMPolicy), "ilm_policy")
+		}
+	}
+	return t.sorted()
+}
+
+func (t *topology) sorted() *topology {
+	order := map[string]int{topoTopic: 0, topoConnector: 1, topoPipeline: 2, topoDataStream: 3, topoILM: 4}
+	sort.Slice(t.Nodes, func(i, j int) bool {
+		a, b := t.Nodes[i], t.Nodes[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(t.Edges, func(i, j int) bool {
+		a, b := t.Edges[i], t.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return t
+}
+
+// GET /admin/topology 的路由：拓扑结构走查询缓存；?status=true 是实时状态，不缓存
+func (s *Server) topologyRoute() http.HandlerFunc {
+	cached := s.cacheGET("topology", s.handleTopology)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") == "true" {
+			s.handleTopology(w, r)
+			return
+		}
+		cached(w, r)
+	}
+}
+
+// GET /admin/topology[?status=true]
+func (s *Server) handleTopology(w http.ResponseWriter, r *http.Request) {
+	t := s.buildTopology(r.Context())
+	if r.URL.Query().Get("status") == "true" {
+		s.topologyStatus(r.Context(), t)
+	}
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** 拓扑运行状态（GET /admin/topology?status=true 与 /admin/topology/stream） **************/
+
+// 在 topology.go 的图上附带运行状态，前端据此画实时的管道图：
+//   - connector 节点：connector 与 task 状态（tasks 按状态计数，failed_tasks 为 FAILED 的 task id）、consumer lag 合计
+//   - topic → connector 边：该 topic 上的 consumer lag（topics.regex 节点为合计）
+//   - pipeline 节点：ingest 累计 count / failed / current，docs_per_sec
+//   - data stream 节点：主分片文档数与 index_total / index_failed，docs_per_sec
+//   - data stream → ILM 策略边与 ILM 节点：backing index 的 phase 分布（phases），ILM 出错的索引数（ilm_errors）
+// 速率由累计计数相对上一次采样求出（所有请求共享采样，第一次请求没有速率），同 /admin/es/pressure。
+// consumer lag 需要 kafka.brokers，group 为 connect-<connector>（主 sink 使用 kafka.consumer_group）。
+// /admin/topology/stream 为 SSE，每个 interval（默认 live.interval，?interval= 可覆盖，最小 1s）推送一次 topology 事件。
+
+type counterSample struct {
+	at time.Time
+	v  int64
+}
+
+type topologySampler struct {
+	mu   sync.Mutex
+	last map[string]counterSample // 节点 ID -> 上一次的累计计数
+}
+
+// 记录本次的累计计数，返回距上次采样的每秒增量；较早的采样在一小时未更新后丢弃
+func (ts *topologySampler) rates(at time.Time, cur map[string]int64) map[string]float64 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.last == nil {
+		ts.last = map[string]counterSample{}
+	}
+	out := map[string]float64{}
+	for id, v := range cur {
+		if prev, ok := ts.last[id]; ok {
+			if secs := at.Sub(prev.at).Seconds(); secs > 0 {
+				out[id] = math.Round(float64(counterDelta(v, prev.v))/secs*100) / 100
+			}
+		}
+		ts.last[id] = counterSample{at: at, v: v}
+	}
+	for id, c := range ts.last {
+		if at.Sub(c.at) > time.Hour {
+			delete(ts.last, id)
+		}
+	}
+	return out
+}
+
+func (n *topologyNode) setStatus(k string, v any) {
+	if n.Status == nil {
+		n.Status = map[string]any{}
+	}
+	n.Status[k] = v
+}
+
+func (e *topologyEdge) setStatus(k string, v any) {
+	if e.Status == nil {
+		e.Status = map[string]any{}
+	}
+	e.Status[k] = v
+}
+
+// 存在（未标记 missing）的某类节点名
+func (t *topology) present(kind string) []string {
+	var out []string
+	for _, n := range t.nodesOf(kind) {
+		if !n.Missing {
+			out = append(out, n.Name)
+		}
+	}
+	return out
+}
+
+func (s *Server) topologyStatus(ctx context.Context, t *topology) {
+	counters := map[string]int64{}
+	s.topologyConnectorStatus(ctx, t)
+	s.topologyLag(ctx, t)
+	s.topologyPipelineStatus(ctx, t, counters)
+	s.topologyDataStreamStatus(ctx, t, counters)
+	s.topologyILMStatus(ctx, t)
+	for id, rate := range s.topoSampler.rates(time.Now(), counters) {
+		if n, ok := t.byID[id]; ok {
+			n.setStatus("docs_per_sec", rate)
+		}
+	}
+}
+
+func (s *Server) topologyConnectorStatus(ctx context.Context, t *topology) {
+	if len(t.present(topoConnector)) == 0 {
+		return
+	}
+	var conns map[string]struct {
+		Status json.RawMessage `json:"status"`
+	}
+	if _, err := s.getJSON(ctx, s.cfg.Connect.Host+"/connectors?expand=status", "connect", &conns); err != nil {
+		t.errorf("connect status: %v", err)
+		return
+	}
+	for name, c := range conns {
+		n, ok := t.byID[topoConnector+":"+name]
+		if !ok || len(c.Status) == 0 {
+			continue
+		}
+		state, tasks, err := parseConnectStatus(c.Status)
+		if err != nil {
+			t.errorf("connect status %s: %v", name, err)
+			continue
+		}
+		counts := map[string]int{}
+		failed := []int{}
+		for _, task := range tasks {
+			counts[task.State]++
+			if task.State == "FAILED" {
+				failed = append(failed, task.ID)
+			}
+		}
+		n.setStatus("state", state)
+		n.setStatus("tasks", counts)
+		if len(failed) > 0 {
+			n.setStatus("failed_tasks", failed)
+		}
+	}
+}
+
+// 有 topic 输入边的 connector（sink）的 consumer lag，一次查询所有 group
+func (s *Server) topologyLag(ctx context.Context, t *topology) {
+	if len(s.cfg.Kafka.Brokers) == 0 {
+		return
+	}
+	primary := ""
+	if p, err := s.primarySink(); err == nil {
+		primary = p.Name()
+	}
+	inputs := map[string][]*topologyEdge{} // connector 节点 ID -> topic 输入边
+	for _, e := range t.Edges {
+		if strings.HasPrefix(e.From, topoTopic+":") && !t.byID[e.To].Missing {
+			inputs[e.To] = append(inputs[e.To], e)
+		}
+	}
+	if len(inputs) == 0 {
+		return
+	}
+	groupOf := map[string]string{}
+	var groups []string
+	for id := range inputs {
+		name := t.byID[id].Name
+		g := "connect-" + name
+		if name == primary {
+			g = s.sinkConsumerGroup(name)
+		}
+		groupOf[id] = g
+		groups = append(groups, g)
+	}
+	adm, err := s.kafkaAdmin()
+	if err != nil {
+		t.errorf("kafka: %v", err)
+		return
+	}
+	release, err := s.limits.acquire(ctx, "kafka")
+	if err != nil {
+		t.errorf("kafka: %v", err)
+		return
+	}
+	defer release()
+	lags, err := adm.Lag(ctx, groups...)
+	if err != nil {
+		t.errorf("kafka lag: %v", err)
+		return
+	}
+	for id, edges := range inputs {
+		n := t.byID[id]
+		l, ok := lags[groupOf[id]]
+		if !ok {
+			continue
+		}
+		if err := l.Error(); err != nil {
+			n.setStatus("lag_error", err.Error())
+			continue
+		}
+		n.setStatus("lag", l.Lag.Total())
+		n.setStatus("group_state", l.State)
+		byTopic := l.Lag.TotalByTopic()
+		for _, e := range edges {
+			tn := t.byID[e.From]
+			if tn.Attrs["regex"] == true {
+				e.setStatus("lag", l.Lag.Total())
+			} else if tl, ok := byTopic[tn.Name]; ok {
+				e.setStatus("lag", tl.Lag)
+			}
+		}
+	}
+}
+
+func (s *Server) topologyPipelineStatus(ctx context.Context, t *topology, counters map[string]int64) {
+	names := t.present(topoPipeline)
+	if len(names) == 0 {
+		return
+	}
+	nodes, err := s.nodesIngestStats(ctx)
+	if err != nil {
+		t.errorf("es ingest stats: %v", err)
+		return
+	}
+	for _, name := range names {
+		var sum ingestProcessorStats
+		found := false
+		for _, node := range nodes {
+			if p, ok := node.Ingest.Pipelines[name]; ok {
+				found = true
+				sum.Count += p.Count
+				sum.Failed += p.Failed
+				sum.Current += p.Current
+				sum.TimeInMilli += p.TimeInMilli
+			}
+		}
+		if !found {
+			continue
+		}
+		n := t.node(topoPipeline, name)
+		n.setStatus("count", sum.Count)
+		n.setStatus("failed", sum.Failed)
+		n.setStatus("current", sum.Current)
+		counters[n.ID] = sum.Count
+	}
+}
+
+// 按 backing index 汇总到 data stream；只统计主分片，避免副本重复计数
+func (s *Server) topologyDataStreamStatus(ctx context.Context, t *topology, counters map[string]int64) {
+	names := t.present(topoDataStream)
+	if len(names) == 0 || len(t.backing) == 0 {
+		return
+	}
+	var stats struct {
+		Indices map[string]struct {
+			Primaries struct {
+				Docs struct {
+					Count int64 `json:"count"`
+				} `json:"docs"`
+				Indexing struct {
+					IndexTotal  int64 `json:"index_total"`
+					IndexFailed int64 `json:"index_failed"`
+				} `json:"indexing"`
+			} `json:"primaries"`
+		} `json:"indices"`
+	}
+	u := s.cfg.ES.Host + "/" + url.PathEscape(strings.Join(names, ",")) + "/_stats/docs,indexing?level=indices"
+	if _, err := s.getJSON(ctx, u, "es", &stats); err != nil {
+		t.errorf("es data stream stats: %v", err)
+		return
+	}
+	type dsStats struct{ docs, total, failed int64 }
+	sums := map[string]*dsStats{}
+	for idx, st := range stats.Indices {
+		ds, ok := t.backing[idx]
+		if !ok {
+			continue
+		}
+		if sums[ds] == nil {
+			sums[ds] = &dsStats{}
+		}
+		sums[ds].docs += st.Primaries.Docs.Count
+		sums[ds].total += st.Primaries.Indexing.IndexTotal
+		sums[ds].failed += st.Primaries.Indexing.IndexFailed
+	}
+	for ds, sum := range sums {
+		n := t.node(topoDataStream, ds)
+		n.setStatus("docs", sum.docs)
+		n.setStatus("index_total", sum.total)
+		n.setStatus("index_failed", sum.failed)
+		counters[n.ID] = sum.total
+	}
+}
+
+// backing index 的 ILM phase 分布，放在 data stream → ILM 策略的边上，并按策略汇总到 ILM 节点
+func (s *Server) topologyILMStatus(ctx context.Context, t *topology) {
+	names := t.present(topoDataStream)
+	if len(names) == 0 || len(t.nodesOf(topoILM)) == 0 {
+		return
+	}
+	var explain struct {
+		Indices map[string]struct {
+			Managed bool   `json:"managed"`
+			Policy  string `json:"policy"`
+			Phase   string `json:"phase"`
+			Step    string `json:"step"`
+		} `json:"indices"`
+	}
+	u := s.cfg.ES.Host + "/" + url.PathEscape(strings.Join(names, ",")) + "/_ilm/explain?only_managed=true"
+	if _, err := s.getJSON(ctx, u, "es", &explain); err != nil {
+		t.errorf("es ilm explain: %v", err)
+		return
+	}
+	type phaseCount struct {
+		phases map[string]int
+		errors int
+	}
+	add := func(m map[string]*phaseCount, key, phase string, failed bool) {
+		pc := m[key]
+		if pc == nil {
+			pc = &phaseCount{phases: map[string]int{}}
+			m[key] = pc
+		}
+		pc.phases[phase]++
+		if failed {
+			pc.errors++
+		}
+	}
+	byEdge, byPolicy := map[string]*phaseCount{}, map[string]*phaseCount{}
+	for idx, ex := range explain.Indices {
+		ds, ok := t.backing[idx]
+		if !ok || !ex.Managed {
+			continue
+		}
+		phase := ex.Phase
+		if phase == "" {
+			phase = "new"
+		}
+		failed := ex.Step == "ERROR"
+		add(byEdge, topoDataStream+":"+ds+"|"+topoILM+":"+ex.Policy, phase, failed)
+		add(byPolicy, topoILM+":"+ex.Policy, phase, failed)
+	}
+	for _, e := range t.Edges {
+		if pc, ok := byEdge[e.From+"|"+e.To]; ok {
+			e.setStatus("phases", pc.phases)
+			e.setStatus("ilm_errors", pc.errors)
+		}
+	}
+	for id, pc := range byPolicy {
+		if n, ok := t.byID[id]; ok {
+			n.setStatus("phases", pc.phases)
+			n.setStatus("ilm_errors", pc.errors)
+		}
+	}
+}
+
+// GET /admin/topology/stream[?interval=10s]：SSE，连接后立即推送一次，之后每个 interval 推送带运行状态的拓扑
+func (s *Server) handleTopologyStream(w http.ResponseWriter, r *http.Request) {
+	interval := mustParseDuration("live.interval", s.cfg.Live.Interval)
+	if v := r.URL.Query().Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "interval must be a duration of at least 1s"})
+			return
+		}
+		interval = d
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	rc := http.NewResponseController(w)
+	// 长连接：清除 http.Server 的写超时，由推送与心跳维持
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s.logger.Printf("topology-stream connected ip=%s interval=%s", clientIP(r), interval)
+	defer s.logger.Printf("topology-stream disconnected ip=%s", clientIP(r))
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	var seq uint64
+	push := func() error {
+		ctx, cancel := context.WithTimeout(r.Context(), max(interval, 15*time.Second))
+		defer cancel()
+		t := s.buildTopology(ctx)
+		s.topologyStatus(ctx, t)
+		seq++
+		return writeSSE(w, "topology", seq, t)
+	}
+	if push() != nil || rc.Flush() != nil {
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+			if push() != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
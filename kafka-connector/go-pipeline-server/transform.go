@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/************** 预聚合（ES transform） **************/
+
+// transforms 中每一项对应一个持续运行的 pivot transform：按 interval 的 @timestamp 桶 + group_by 字段分组，
+// 把数据流聚合到以实体为中心的汇总索引（如每小时按服务统计错误数 / 错误率），仪表盘直接查汇总索引，
+// 不再对原始日志做重聚合。也可用 file 指定完整的 transform JSON（pivot / latest），此时忽略生成字段。
+// PUT /admin/es/transforms 创建或更新（pivot 变化需 ?recreate=true 删除重建）；
+// PUT /admin/es/transforms/{name}/start|stop 启停；GET /admin/es/transforms 汇总 _stats。仅支持 ES。
+
+type TransformConfig struct {
+	Name      string            `yaml:"name"`      // transform id
+	Dest      string            `yaml:"dest"`      // 汇总索引，默认 <name>
+	Query     string            `yaml:"query"`     // 源数据过滤（query_string），空为全部
+	GroupBy   []string          `yaml:"group_by"`  // terms 分组字段（keyword）
+	Interval  string            `yaml:"interval"`  // @timestamp 分桶粒度（fixed_interval），默认 1h
+	Metrics   map[string]string `yaml:"metrics"`   // 输出字段 -> "<agg>:<参数>"，见 transformAgg
+	Frequency string            `yaml:"frequency"` // 检查新数据的间隔，默认 5m
+	Delay     string            `yaml:"delay"`     // sync.time.delay，等待迟到数据，默认 60s
+	Retention string            `yaml:"retention"` // 汇总文档保留时长（retention_policy），空为不删除
+	File      string            `yaml:"file"`      // 完整 transform JSON，设置后忽略以上生成字段（dest 除外）
+}
+
+func (t TransformConfig) dest() string {
+	if t.Dest != "" {
+		return t.Dest
+	}
+	return t.Name
+}
+
+func (t TransformConfig) interval() string {
+	if t.Interval == "" {
+		return "1h"
+	}
+	return t.Interval
+}
+
+func (s *Server) findTransform(name string) (TransformConfig, bool) {
+	for _, t := range s.cfg.Transforms {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return TransformConfig{}, false
+}
+
+// 指标写法：
+//
+//	value_count:<field> / sum: / avg: / min: / max: / cardinality:<field>
+//	filter:<query_string>      满足条件的文档数，如 filter:log.level:ERROR
+//	ratio:<a>/<b>              两个已定义指标之比（bucket_script），如 ratio:errors/total
+func transformAgg(out, spec string) (map[string]any, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("metric %q: expected <agg>:<arg>, got %q", out, spec)
+	}
+	switch kind {
+	case "value_count", "sum", "avg", "min", "max", "cardinality":
+		return map[string]any{kind: map[string]any{"field": arg}}, nil
+	case "filter":
+		return map[string]any{"filter": map[string]any{"query_string": map[string]any{"query": arg}}}, nil
+	case "ratio":
+		a, b, ok := strings.Cut(arg, "/")
+		if !ok || a == "" || b == "" {
+			return nil, fmt.Errorf("metric %q: ratio expects <a>/<b>, got %q", out, arg)
+		}
+		return map[string]any{"bucket_script": map[string]any{
+			"buckets_path": map[string]string{"a": a, "b": b},
+			"script":       "params.b == 0 ? 0 : params.a / params.b",
+		}}, nil
+	}
+	return nil, fmt.Errorf("metric %q: unsupported aggregation %q", out, kind)
+}
+
+// 生成（或读取）transform 文档，并写入 dest / _meta.managed_by
+func (s *Server) renderTransform(t TransformConfig) (map[string]any, error) {
+	if t.Name == "" {
+		return nil, fmt.Errorf("transforms: name is required")
+	}
+	var doc map[string]any
+	if t.File != "" {
+		b, err := os.ReadFile(t.File)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", t.Name, err)
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("transform %s: parse %s: %w", t.Name, t.File, err)
+		}
+	} else {
+		if len(t.Metrics) == 0 {
+			return nil, fmt.Errorf("transform %s: metrics is required", t.Name)
+		}
+		groupBy := map[string]any{
+			"@timestamp": map[string]any{"date_histogram": map[string]any{"field": "@timestamp", "fixed_interval": t.interval()}},
+		}
+		for _, f := range t.GroupBy {
+			groupBy[f] = map[string]any{"terms": map[string]any{"field": f}}
+		}
+		aggs := map[string]any{}
+		for out, spec := range t.Metrics {
+			a, err := transformAgg(out, spec)
+			if err != nil {
+				return nil, fmt.Errorf("transform %s: %w", t.Name, err)
+			}
+			aggs[out] = a
+		}
+		source := map[string]any{"index": []string{s.cfg.ES.Names.DataStream}}
+		if t.Query != "" {
+			source["query"] = map[string]any{"query_string": map[string]any{"query": t.Query}}
+		}
+		frequency, delay := t.Frequency, t.Delay
+		if frequency == "" {
+			frequency = "5m"
+		}
+		if delay == "" {
+			delay = "60s"
+		}
+		doc = map[string]any{
+			"description": fmt.Sprintf("%s summary of %s every %s", t.Name, s.cfg.ES.Names.DataStream, t.interval()),
+			"source":      source,
+			"pivot":       map[string]any{"group_by": groupBy, "aggregations": aggs},
+			"frequency":   frequency,
+			"sync":        map[string]any{"time": map[string]any{"field": "@timestamp", "delay": delay}},
+		}
+		if t.Retention != "" {
+			doc["retention_policy"] = map[string]any{"time": map[string]any{"field": "@timestamp", "max_age": t.Retention}}
+		}
+	}
+	dest, _ := doc["dest"].(map[string]any)
+	if dest == nil {
+		dest = map[string]any{}
+		doc["dest"] = dest
+	}
+	if t.Dest != "" || dest["index"] == nil {
+		dest["index"] = t.dest()
+	}
+	meta, _ := doc["_meta"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		doc["_meta"] = meta
+	}
+	meta["managed_by"] = s.managedBy()
+	s.stampLabels(meta)
+	return doc, nil
+}
+
+func (s *Server) transformURL(id, suffix string) string {
+	return fmt.Sprintf("%s/_transform/%s%s", s.cfg.ES.Host, url.PathEscape(id), suffix)
+}
+
+func (s *Server) transformsUnavailable(w http.ResponseWriter) bool {
+	switch {
+	case s.isOpenSearch():
+		writeJSON(w, 400, map[string]string{"error": "transform management is only supported on elasticsearch"})
+		return true
+	case len(s.cfg.Transforms) == 0:
+		writeJSON(w, 400, map[string]string{"error": "no transforms configured"})
+		return true
+	}
+	return false
+}
+
+type transformResult struct {
+	Name   string `json:"name"`
+	Result string `json:"result"` // created | updated | recreated | unchanged | failed
+	Error  string `json:"error,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+// 线上 transform 文档；不存在时返回 nil
+func (s *Server) getTransform(ctx context.Context, id string) (map[string]any, error) {
+	resp, body, err := s.doGET(ctx, s.transformURL(id, ""), "es")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get transform %s: %s %s", id, resp.Status, firstLine(string(body)))
+	}
+	var doc struct {
+		Transforms []map[string]any `json:"transforms"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || len(doc.Transforms) == 0 {
+		return nil, fmt.Errorf("decode transform %s: unexpected response", id)
+	}
+	return doc.Transforms[0], nil
+}
+
+// 期望文档与线上文档在 key 上是否一致（线上文档带有 id / version / create_time 等额外字段）
+func transformSame(want, have map[string]any, key string) bool {
+	a, _ := json.Marshal(want[key])
+	b, _ := json.Marshal(have[key])
+	var x, y any
+	_ = json.Unmarshal(a, &x)
+	_ = json.Unmarshal(b, &y)
+	return reflect.DeepEqual(x, y)
+}
+
+func (s *Server) esCall(ctx context.Context, method, u string, body []byte) error {
+	var (
+		resp *http.Response
+		b    []byte
+		err  error
+	)
+	switch method {
+	case http.MethodPut:
+		resp, b, err = s.doPUT(ctx, u, body, "es")
+	case http.MethodPost:
+		resp, b, err = s.doPOST(ctx, u, body, "es")
+	case http.MethodDelete:
+		resp, b, err = s.doDELETE(ctx, u, "es")
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s %s", method, strings.TrimPrefix(u, s.cfg.ES.Host), resp.Status, firstLine(string(b)))
+	}
+	return nil
+}
+
+func (s *Server) putTransform(r *http.Request, t TransformConfig) transformResult {
+	ctx := r.Context()
+	res := transformResult{Name: t.Name}
+	fail := func(err error) transformResult {
+		res.Result, res.Error = "failed", err.Error()
+		return res
+	}
+	want, err := s.renderTransform(t)
+	if err != nil {
+		return fail(err)
+	}
+	have, err := s.getTransform(ctx, t.Name)
+	if err != nil {
+		return fail(err)
+	}
+	if have == nil {
+		b, _ := json.Marshal(want)
+		s.logger.Printf("step=transform create=%s dest=%s operator=%s", t.Name, t.dest(), operatorIdentity(r))
+		if err := s.esCall(ctx, http.MethodPut, s.transformURL(t.Name, ""), b); err != nil {
+			return fail(err)
+		}
+		res.Result = "created"
+		return res
+	}
+	meta, _ := have["_meta"].(map[string]any)
+	owner, _ := meta["managed_by"].(string)
+	if owner != s.managedBy() && r.URL.Query().Get("force") != "true" && !forced(ctx) {
+		e := &notManagedError{Kind: "transform", Name: t.Name, Owner: owner}
+		res.Result, res.Error, res.Owner = "failed", e.Error(), owner
+		return res
+	}
+
+	// pivot / latest / dest.index 不能原地更新，只能删除重建
+	if !transformSame(want, have, "pivot") || !transformSame(want, have, "latest") {
+		if r.URL.Query().Get("recreate") != "true" {
+			return fail(fmt.Errorf("transform %s: pivot changed and cannot be updated in place; pass recreate=true to delete and recreate it (the destination index is kept)", t.Name))
+		}
+		s.logger.Printf("step=transform recreate=%s operator=%s", t.Name, operatorIdentity(r))
+		if err := s.esCall(ctx, http.MethodDelete, s.transformURL(t.Name, "?force=true"), nil); err != nil {
+			return fail(err)
+		}
+		b, _ := json.Marshal(want)
+		if err := s.esCall(ctx, http.MethodPut, s.transformURL(t.Name, ""), b); err != nil {
+			return fail(err)
+		}
+		res.Result = "recreated"
+		return res
+	}
+	upd := map[string]any{}
+	for _, k := range []string{"description", "source", "dest", "frequency", "sync", "retention_policy", "settings", "_meta"} {
+		if v, ok := want[k]; ok && !transformSame(want, have, k) {
+			upd[k] = v
+		}
+	}
+	if len(upd) == 0 {
+		res.Result = "unchanged"
+		return res
+	}
+	b, _ := json.Marshal(upd)
+	s.logger.Printf("step=transform update=%s operator=%s", t.Name, operatorIdentity(r))
+	if err := s.esCall(ctx, http.MethodPost, s.transformURL(t.Name, "/_update"), b); err != nil {
+		return fail(err)
+	}
+	res.Result = "updated"
+	return res
+}
+
+// PUT /admin/es/transforms[?name=][&recreate=true][&start=true]
+func (s *Server) handlePutTransforms(w http.ResponseWriter, r *http.Request) {
+	if s.transformsUnavailable(w) {
+		return
+	}
+	targets := s.cfg.Transforms
+	if name := r.URL.Query().Get("name"); name != "" {
+		t, ok := s.findTransform(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("transform %q not configured", name)})
+			return
+		}
+		targets = []TransformConfig{t}
+	}
+	results := make([]transformResult, 0, len(targets))
+	failed := 0
+	for _, t := range targets {
+		res := s.putTransform(r, t)
+		if res.Result != "failed" && r.URL.Query().Get("start") == "true" {
+			if err := s.startTransform(r.Context(), t.Name); err != nil {
+				res.Result, res.Error = "failed", err.Error()
+			}
+		}
+		if res.Result == "failed" {
+			failed++
+		}
+		results = append(results, res)
+	}
+	code := http.StatusOK
+	switch {
+	case failed == len(results):
+		code = http.StatusBadGateway
+	case failed > 0:
+		code = http.StatusMultiStatus
+	}
+	writeJSON(w, code, map[string]any{"step": "transform", "transforms": results})
+}
+
+// 已在运行时 ES 返回 409，视为成功
+func (s *Server) startTransform(ctx context.Context, id string) error {
+	resp, body, err := s.doPOST(ctx, s.transformURL(id, "/_start"), nil, "es")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 && !(resp.StatusCode == http.StatusConflict && strings.Contains(string(body), "already started")) {
+		return fmt.Errorf("start transform %s: %s %s", id, resp.Status, firstLine(string(body)))
+	}
+	return nil
+}
+
+// PUT /admin/es/transforms/{name}/start
+func (s *Server) handleStartTransform(w http.ResponseWriter, r *http.Request) {
+	s.transformAction(w, r, "start")
+}
+
+// PUT /admin/es/transforms/{name}/stop[?wait=true]
+func (s *Server) handleStopTransform(w http.ResponseWriter, r *http.Request) {
+	s.transformAction(w, r, "stop")
+}
+
+func (s *Server) transformAction(w http.ResponseWriter, r *http.Request, action string) {
+	if s.isOpenSearch() {
+		writeJSON(w, 400, map[string]string{"error": "transform management is only supported on elasticsearch"})
+		return
+	}
+	name := r.PathValue("name")
+	if _, ok := s.findTransform(name); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("transform %q not configured", name)})
+		return
+	}
+	s.logger.Printf("step=transform %s=%s operator=%s", action, name, operatorIdentity(r))
+	var err error
+	if action == "start" {
+		err = s.startTransform(r.Context(), name)
+	} else {
+		// 默认等当前 checkpoint 完成再停，避免汇总索引停在半个 checkpoint
+		q := "/_stop?wait_for_checkpoint=true"
+		if r.URL.Query().Get("wait") == "true" {
+			q += "&wait_for_completion=true"
+		}
+		err = s.esCall(r.Context(), http.MethodPost, s.transformURL(name, q), nil)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("transform-"+action, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"step": "transform-" + action, "name": name, "result": "ok"})
+}
+
+type transformStatus struct {
+	Name               string `json:"name"`
+	Dest               string `json:"dest"`
+	State              string `json:"state"` // started | indexing | stopped | failed | absent ...
+	Health             string `json:"health,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Checkpoint         int64  `json:"checkpoint"`
+	LastCheckpointTime int64  `json:"last_checkpoint_ms,omitempty"`
+	DocsProcessed      int64  `json:"documents_processed"`
+	DocsIndexed        int64  `json:"documents_indexed"`
+	SearchTimeMs       int64  `json:"search_time_ms"`
+	IndexFailures      int64  `json:"index_failures"`
+	SearchFailures     int64  `json:"search_failures"`
+}
+
+// GET /admin/es/transforms
+func (s *Server) handleListTransforms(w http.ResponseWriter, r *http.Request) {
+	if s.transformsUnavailable(w) {
+		return
+	}
+	ids := make([]string, 0, len(s.cfg.Transforms))
+	for _, t := range s.cfg.Transforms {
+		ids = append(ids, url.PathEscape(t.Name))
+	}
+	resp, body, err := s.doGET(r.Context(), fmt.Sprintf("%s/_transform/%s/_stats?allow_no_match=true", s.cfg.ES.Host, strings.Join(ids, ",")), "es")
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("transform-stats", err))
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		writeJSON(w, resp.StatusCode, map[string]any{"step": "transform-stats", "response": jsonRaw(body)})
+		return
+	}
+	var stats struct {
+		Transforms []struct {
+			ID     string `json:"id"`
+			State  string `json:"state"`
+			Reason string `json:"reason"`
+			Health struct {
+				Status string `json:"status"`
+			} `json:"health"`
+			Stats struct {
+				DocsProcessed  int64 `json:"documents_processed"`
+				DocsIndexed    int64 `json:"documents_indexed"`
+				SearchTimeMs   int64 `json:"search_time_in_ms"`
+				IndexFailures  int64 `json:"index_failures"`
+				SearchFailures int64 `json:"search_failures"`
+			} `json:"stats"`
+			Checkpointing struct {
+				Last struct {
+					Checkpoint int64 `json:"checkpoint"`
+					Timestamp  int64 `json:"timestamp_millis"`
+				} `json:"last"`
+			} `json:"checkpointing"`
+		} `json:"transforms"`
+	}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("transform-stats", fmt.Errorf("decode transform stats: %w", err)))
+		return
+	}
+	byID := map[string]transformStatus{}
+	for _, t := range stats.Transforms {
+		byID[t.ID] = transformStatus{
+			State: t.State, Health: t.Health.Status, Reason: t.Reason,
+			Checkpoint: t.Checkpointing.Last.Checkpoint, LastCheckpointTime: t.Checkpointing.Last.Timestamp,
+			DocsProcessed: t.Stats.DocsProcessed, DocsIndexed: t.Stats.DocsIndexed, SearchTimeMs: t.Stats.SearchTimeMs,
+			IndexFailures: t.Stats.IndexFailures, SearchFailures: t.Stats.SearchFailures,
+		}
+	}
+	out := make([]transformStatus, 0, len(s.cfg.Transforms))
+	var problems []string
+	for _, t := range s.cfg.Transforms {
+		st, ok := byID[t.Name]
+		if !ok {
+			st.State = "absent"
+		}
+		st.Name, st.Dest = t.Name, t.dest()
+		switch {
+		case st.State == "absent":
+			problems = append(problems, fmt.Sprintf("%s is not created", t.Name))
+		case st.State == "failed":
+			problems = append(problems, fmt.Sprintf("%s failed: %s", t.Name, st.Reason))
+		case st.State == "stopped":
+			problems = append(problems, fmt.Sprintf("%s is stopped", t.Name))
+		case st.Health != "" && st.Health != "green":
+			problems = append(problems, fmt.Sprintf("%s health is %s", t.Name, st.Health))
+		}
+		out = append(out, st)
+	}
+	sort.Strings(problems)
+	if problems == nil {
+		problems = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": len(problems) == 0, "problems": problems, "transforms": out})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/************** 批量验证：并发执行全部 verify 检查 **************/
+
+const verifyAllWorkers = 4
+
+type verifyCheck struct {
+	name string
+	run  func(w http.ResponseWriter, r *http.Request)
+}
+
+type verifyResult struct {
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Status     int    `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Body       any    `json:"body,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (s *Server) verifyChecks() []verifyCheck {
+	return []verifyCheck{
+		{"ilm", s.cacheGET("ilm-explain", s.handleVerifyILMExplain)},
+		{"template", s.cacheGET("template", s.handleVerifyTemplate)},
+		{"pipeline", s.cacheGET("pipeline", s.handleVerifyPipeline)},
+		{"data-stream", s.cacheGET("data-stream", s.handleVerifyDataStream)},
+		{"sink-status", s.cacheGET("sink-status", s.handleVerifySinkStatus)},
+		{"kafka-topic", s.handleVerifyKafkaTopic},
+		{"downsample", s.cacheGET("downsample", s.handleVerifyDownsample)},
+		{"geoip", s.cacheGET("geoip", s.handleVerifyGeoIP)},
+		{"capacity", s.cacheGET("capacity", s.handleVerifyCapacity)},
+		{"ingest-nodes", s.cacheGET("ingest-nodes", s.handleVerifyIngestNodes)},
+	}
+}
+
+func (s *Server) handleVerifyDataStream(w http.ResponseWriter, r *http.Request) {
+	url := s.cfg.ES.Host + "/_data_stream/" + s.cfg.ES.Names.DataStream
+	s.logger.Printf("verify=data-stream url=%s", url)
+	resp, body, err := s.doGET(r.Context(), url, "es")
+	if err != nil {
+		writeJSON(w, 500, errorBody("verify-data-stream", err))
+		return
+	}
+	writeJSON(w, resp.StatusCode, jsonRaw(body))
+}
+
+// 日志 topic 存在且各分区有 leader
+func (s *Server) handleVerifyKafkaTopic(w http.ResponseWriter, r *http.Request) {
+	if len(s.cfg.Kafka.Brokers) == 0 || s.cfg.Kafka.Topic == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": true, "reason": "kafka.brokers or kafka.topic is not configured"})
+		return
+	}
+	info, err := s.kafkaClusterInfo(r.Context())
+	if err != nil {
+		writeJSON(w, kafkaErrStatus(err), errorBody("verify-kafka-topic", err))
+		return
+	}
+	code := http.StatusOK
+	if exists, _ := info.LogTopic["exists"].(bool); !exists {
+		code = http.StatusNotFound
+	}
+	for _, p := range info.Offline {
+		if p.Topic == s.cfg.Kafka.Topic {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, map[string]any{"topic": info.LogTopic, "offline": info.Offline, "under_replicated": info.UnderReplicated})
+}
+
+func (s *Server) runVerifyCheck(ctx context.Context, r *http.Request, c verifyCheck) verifyResult {
+	start := time.Now()
+	cw := &captureWriter{hdr: http.Header{}, status: http.StatusOK}
+	c.run(cw, r.Clone(ctx))
+	res := verifyResult{Status: cw.status, DurationMS: time.Since(start).Milliseconds(), OK: cw.status < 400}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(cw.body), &body); err != nil {
+		res.Body = cw.body
+	} else if skipped, _ := body["skipped"].(bool); skipped {
+		res.Skipped = true
+		res.Body = body
+	} else if e, ok := body["error"].(string); ok && !res.OK {
+		res.Error = e
+	} else if d, ok := body["data"]; ok {
+		res.Body = d
+	} else {
+		res.Body = body
+	}
+	return res
+}
+
+// 并发执行全部检查（总超时 30s），返回整体结果、失败项与各项明细
+func (s *Server) verifyAll(ctx context.Context, r *http.Request) (bool, []string, map[string]verifyResult) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	checks := s.verifyChecks()
+	results := make(map[string]verifyResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan verifyCheck)
+	for i := 0; i < min(verifyAllWorkers, len(checks)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range queue {
+				res := s.runVerifyCheck(ctx, r, c)
+				mu.Lock()
+				results[c.name] = res
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, c := range checks {
+		queue <- c
+	}
+	close(queue)
+	wg.Wait()
+
+	ok := true
+	failed := []string{}
+	for _, c := range checks {
+		if !results[c.name].OK {
+			ok = false
+			failed = append(failed, c.name)
+		}
+	}
+	return ok, failed, results
+}
+
+func (s *Server) handleVerifyAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ok, failed, results := s.verifyAll(r.Context(), r)
+	s.logger.Printf("verify=all ok=%v failed=%v dur_ms=%d", ok, failed, time.Since(start).Milliseconds())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":          ok,
+		"failed":      failed,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"checks":      results,
+	})
+}
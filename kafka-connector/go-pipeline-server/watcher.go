@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+/************** Watcher 告警规则下发（ES 内置告警，替代内部告警引擎） **************/
+
+// watches 中每一项对应一个资产文件（PUT _watcher/watch/<name> 的请求体），由 ES 自己调度执行，
+// 适合有 Watcher 许可（platinum / enterprise）、希望告警不依赖本服务存活的部署。
+// 下发时 input.search.request.indices 为空则指向本数据流，并在 metadata.managed_by 写入归属标记。
+// PUT /admin/es/watches 下发（?name= 只下发一个），GET /admin/es/watches 汇总各 watch 的执行状态，
+// POST /admin/es/watches/{name}/execute 立即模拟执行一次（不记录历史、不触发动作）。仅支持 ES。
+
+type WatchConfig struct {
+	Name string `yaml:"name"` // watch id
+	File string `yaml:"file"` // watch JSON（trigger / input / condition / actions）
+}
+
+func (s *Server) findWatch(name string) (WatchConfig, bool) {
+	for _, wc := range s.cfg.Watches {
+		if wc.Name == name {
+			return wc, true
+		}
+	}
+	return WatchConfig{}, false
+}
+
+func (s *Server) watchURL(id, suffix string) string {
+	return fmt.Sprintf("%s/_watcher/watch/%s%s", s.cfg.ES.Host, url.PathEscape(id), suffix)
+}
+
+func (s *Server) watchesUnavailable(w http.ResponseWriter) bool {
+	switch {
+	case s.isOpenSearch():
+		writeJSON(w, 400, map[string]string{"error": "watcher is only supported on elasticsearch (use the alerting plugin or the internal alerts engine)"})
+		return true
+	case len(s.cfg.Watches) == 0:
+		writeJSON(w, 400, map[string]string{"error": "no watches configured"})
+		return true
+	}
+	return false
+}
+
+// 读取 watch 文件，补全 search input 的索引并写入 metadata.managed_by
+func (s *Server) renderWatch(wc WatchConfig) ([]byte, error) {
+	if wc.Name == "" || wc.File == "" {
+		return nil, fmt.Errorf("watches: name and file are required")
+	}
+	b, err := os.ReadFile(wc.File)
+	if err != nil {
+		return nil, fmt.Errorf("watch %s: %w", wc.Name, err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("watch %s: parse %s: %w", wc.Name, wc.File, err)
+	}
+	if _, ok := doc["trigger"].(map[string]any); !ok {
+		return nil, fmt.Errorf("watch %s: %s has no \"trigger\" object", wc.Name, wc.File)
+	}
+	input, _ := doc["input"].(map[string]any)
+	search, _ := input["search"].(map[string]any)
+	if req, _ := search["request"].(map[string]any); req != nil {
+		if idx, _ := req["indices"].([]any); len(idx) == 0 {
+			req["indices"] = []string{s.cfg.ES.Names.DataStream}
+		}
+	}
+	meta, _ := doc["metadata"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		doc["metadata"] = meta
+	}
+	meta["managed_by"] = s.managedBy()
+	s.stampLabels(meta)
+	return json.Marshal(doc)
+}
+
+// GET _watcher/watch/<id>；不存在时 found=false
+type watchDoc struct {
+	Found  bool `json:"found"`
+	Status struct {
+		State struct {
+			Active bool `json:"active"`
+		} `json:"state"`
+		LastChecked      string `json:"last_checked"`
+		LastMetCondition string `json:"last_met_condition"`
+		ExecutionState   string `json:"execution_state"`
+		Actions          map[string]struct {
+			Ack struct {
+				State string `json:"state"`
+			} `json:"ack"`
+			LastExecution struct {
+				Timestamp  string `json:"timestamp"`
+				Successful bool   `json:"successful"`
+				Reason     string `json:"reason"`
+			} `json:"last_execution"`
+		} `json:"actions"`
+	} `json:"status"`
+	Watch struct {
+		Metadata struct {
+			ManagedBy string `json:"managed_by"`
+		} `json:"metadata"`
+	} `json:"watch"`
+}
+
+func (s *Server) getWatch(ctx context.Context, id string) (watchDoc, error) {
+	var doc watchDoc
+	resp, body, err := s.doGET(ctx, s.watchURL(id, ""), "es")
+	if err != nil {
+		return doc, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return doc, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return doc, fmt.Errorf("get watch %s: %s %s", id, resp.Status, firstLine(string(body)))
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return doc, fmt.Errorf("decode watch %s: %w", id, err)
+	}
+	return doc, nil
+}
+
+type watchResult struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"` // created | updated | failed
+	Version int64  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+}
+
+func (s *Server) putWatch(r *http.Request, wc WatchConfig) watchResult {
+	ctx := r.Context()
+	res := watchResult{Name: wc.Name}
+	b, err := s.renderWatch(wc)
+	if err != nil {
+		res.Result, res.Error = "failed", err.Error()
+		return res
+	}
+	have, err := s.getWatch(ctx, wc.Name)
+	if err != nil {
+		res.Result, res.Error = "failed", err.Error()
+		return res
+	}
+	if owner := have.Watch.Metadata.ManagedBy; have.Found && owner != s.managedBy() && r.URL.Query().Get("force") != "true" && !forced(ctx) {
+		e := &notManagedError{Kind: "watch", Name: wc.Name, Owner: owner}
+		s.logger.Printf("step=watch ownership_refused name=%s owner=%q", wc.Name, owner)
+		res.Result, res.Error, res.Owner = "failed", e.Error(), owner
+		return res
+	}
+	// 重新下发不改变激活状态：被手工停用的 watch 保持停用
+	active := !have.Found || have.Status.State.Active
+	s.logger.Printf("step=watch put=%s file=%s active=%t operator=%s", wc.Name, wc.File, active, operatorIdentity(r))
+	resp, body, err := s.doPUT(ctx, s.watchURL(wc.Name, fmt.Sprintf("?active=%t", active)), b, "es")
+	if err != nil {
+		res.Result, res.Error = "failed", err.Error()
+		return res
+	}
+	if resp.StatusCode >= 300 {
+		res.Result, res.Error = "failed", fmt.Sprintf("%s %s", resp.Status, firstLine(string(body)))
+		return res
+	}
+	var ack struct {
+		Version int64 `json:"_version"`
+		Created bool  `json:"created"`
+	}
+	_ = json.Unmarshal(body, &ack)
+	res.Result, res.Version = "updated", ack.Version
+	if ack.Created {
+		res.Result = "created"
+	}
+	return res
+}
+
+// PUT /admin/es/watches[?name=][&force=true]
+func (s *Server) handlePutWatches(w http.ResponseWriter, r *http.Request) {
+	if s.watchesUnavailable(w) {
+		return
+	}
+	targets := s.cfg.Watches
+	if name := r.URL.Query().Get("name"); name != "" {
+		wc, ok := s.findWatch(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("watch %q not configured", name)})
+			return
+		}
+		targets = []WatchConfig{wc}
+	}
+	results := make([]watchResult, 0, len(targets))
+	failed := 0
+	for _, wc := range targets {
+		res := s.putWatch(r, wc)
+		if res.Result == "failed" {
+			failed++
+		}
+		results = append(results, res)
+	}
+	code := http.StatusOK
+	switch {
+	case failed == len(results):
+		code = http.StatusBadGateway
+	case failed > 0:
+		code = http.StatusMultiStatus
+	}
+	writeJSON(w, code, map[string]any{"step": "watch", "watches": results})
+}
+
+type watchStatus struct {
+	Name             string            `json:"name"`
+	State            string            `json:"state"` // active | inactive | absent
+	ManagedBy        string            `json:"managed_by,omitempty"`
+	ExecutionState   string            `json:"execution_state,omitempty"`
+	LastChecked      string            `json:"last_checked,omitempty"`
+	LastMetCondition string            `json:"last_met_condition,omitempty"`
+	FailedActions    map[string]string `json:"failed_actions,omitempty"` // action -> 最近一次失败原因
+}
+
+// GET /admin/es/watches
+func (s *Server) handleListWatches(w http.ResponseWriter, r *http.Request) {
+	if s.watchesUnavailable(w) {
+		return
+	}
+	ctx := r.Context()
+	out := make([]watchStatus, 0, len(s.cfg.Watches))
+	var problems []string
+	for _, wc := range s.cfg.Watches {
+		doc, err := s.getWatch(ctx, wc.Name)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody("watch-status", err))
+			return
+		}
+		st := watchStatus{Name: wc.Name, State: "absent"}
+		if !doc.Found {
+			problems = append(problems, fmt.Sprintf("%s is not provisioned", wc.Name))
+			out = append(out, st)
+			continue
+		}
+		st.State = "inactive"
+		if doc.Status.State.Active {
+			st.State = "active"
+		}
+		st.ManagedBy = doc.Watch.Metadata.ManagedBy
+		st.ExecutionState = doc.Status.ExecutionState
+		st.LastChecked, st.LastMetCondition = doc.Status.LastChecked, doc.Status.LastMetCondition
+		for name, a := range doc.Status.Actions {
+			if a.LastExecution.Timestamp != "" && !a.LastExecution.Successful {
+				if st.FailedActions == nil {
+					st.FailedActions = map[string]string{}
+				}
+				st.FailedActions[name] = a.LastExecution.Reason
+				problems = append(problems, fmt.Sprintf("%s action %s failed: %s", wc.Name, name, a.LastExecution.Reason))
+			}
+		}
+		switch {
+		case st.State == "inactive":
+			problems = append(problems, fmt.Sprintf("%s is inactive", wc.Name))
+		case st.LastChecked == "":
+			problems = append(problems, fmt.Sprintf("%s has not run yet", wc.Name))
+		case st.ExecutionState != "" && !watchExecutionOK(st.ExecutionState):
+			problems = append(problems, fmt.Sprintf("%s last execution %s", wc.Name, st.ExecutionState))
+		}
+		out = append(out, st)
+	}
+	sort.Strings(problems)
+	if problems == nil {
+		problems = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": len(problems) == 0, "problems": problems, "watches": out})
+}
+
+// 正常的执行结果：条件满足并执行、条件不满足、被节流或已确认
+func watchExecutionOK(state string) bool {
+	switch state {
+	case "executed", "execution_not_needed", "throttled", "acknowledged":
+		return true
+	}
+	return false
+}
+
+// POST /admin/es/watches/{name}/execute：模拟执行（record_execution=false，动作全部 simulate）
+func (s *Server) handleExecuteWatch(w http.ResponseWriter, r *http.Request) {
+	if s.isOpenSearch() {
+		writeJSON(w, 400, map[string]string{"error": "watcher is only supported on elasticsearch"})
+		return
+	}
+	name := r.PathValue("name")
+	if _, ok := s.findWatch(name); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("watch %q not configured", name)})
+		return
+	}
+	b, _ := json.Marshal(map[string]any{"record_execution": false, "action_modes": map[string]string{"_all": "simulate"}})
+	resp, body, err := s.doPOST(r.Context(), s.watchURL(name, "/_execute"), b, "es")
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("watch-execute", err))
+		return
+	}
+	if resp.StatusCode >= 300 {
+		writeJSON(w, resp.StatusCode, map[string]any{"step": "watch-execute", "response": jsonRaw(body)})
+		return
+	}
+	var res struct {
+		WatchRecord struct {
+			State  string `json:"state"`
+			Result struct {
+				Input struct {
+					Status  string         `json:"status"`
+					Payload map[string]any `json:"payload"`
+				} `json:"input"`
+				Condition struct {
+					Met bool `json:"met"`
+				} `json:"condition"`
+				Actions []struct {
+					ID     string `json:"id"`
+					Status string `json:"status"`
+					Reason string `json:"reason"`
+				} `json:"actions"`
+			} `json:"result"`
+			Messages []string `json:"messages"`
+		} `json:"watch_record"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		writeJSON(w, http.StatusBadGateway, errorBody("watch-execute", fmt.Errorf("decode execute response: %w", err)))
+		return
+	}
+	rec := res.WatchRecord
+	out := map[string]any{
+		"name":          name,
+		"state":         rec.State,
+		"input_status":  rec.Result.Input.Status,
+		"condition_met": rec.Result.Condition.Met,
+		"actions":       rec.Result.Actions,
+	}
+	if hits, ok := rec.Result.Input.Payload["hits"].(map[string]any); ok {
+		out["hits_total"] = hits["total"]
+	}
+	if len(rec.Messages) > 0 {
+		out["messages"] = rec.Messages
+	}
+	code := http.StatusOK
+	if !watchExecutionOK(rec.State) {
+		code = http.StatusConflict
+		out["error"] = strings.Join(append([]string{"execution " + rec.State}, rec.Messages...), "; ")
+	}
+	writeJSON(w, code, out)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/************** WebSocket 实时状态推送（/admin/ws） **************/
+
+// RFC 6455 仅实现服务端推送所需的子集：握手、文本帧、ping/pong、close
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+
+	wsMaxClientFrame = 1 << 16
+)
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	wmu  sync.Mutex
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func wsAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") || key == "" {
+		return nil, fmt.Errorf("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("unsupported websocket version")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 清除 http.Server 设置的读写超时，长连接由 ping 维持
+	_ = conn.SetDeadline(time.Time{})
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw, send: make(chan []byte, 8), done: make(chan struct{})}, nil
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	hdr := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.rw.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// 读取客户端帧（必须带掩码），返回 opcode 与 payload
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.rw, h[:]); err != nil {
+		return 0, nil, err
+	}
+	op := h[0] & 0x0F
+	masked := h[1]&0x80 != 0
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if !masked || n > wsMaxClientFrame {
+		return 0, nil, fmt.Errorf("invalid client frame")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // 1000 normal closure
+		c.conn.Close()
+	})
+}
+
+// 客户端消息只处理控制帧；读到 close 或出错即断开
+func (c *wsConn) readLoop() {
+	defer c.close()
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpClose:
+			return
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	defer c.close()
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			if err := c.writeFrame(wsOpText, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/************** 广播 **************/
+
+type wsHub struct {
+	mu      sync.Mutex
+	clients map[*wsConn]struct{}
+	last    []byte // 最近一次状态，新连接立即下发
+}
+
+func newWSHub() *wsHub { return &wsHub{clients: map[*wsConn]struct{}{}} }
+
+func (h *wsHub) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	if h.last != nil {
+		c.send <- h.last
+	}
+}
+
+func (h *wsHub) remove(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+func (h *wsHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// 慢客户端（发送队列已满）直接断开，避免拖慢广播
+func (h *wsHub) broadcast(msg []byte, remember bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if remember {
+		h.last = msg
+	}
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			delete(h.clients, c)
+			go c.close()
+		}
+	}
+}
+
+func (h *wsHub) closeAll() {
+	h.mu.Lock()
+	clients := h.clients
+	h.clients = map[*wsConn]struct{}{}
+	h.mu.Unlock()
+	for c := range clients {
+		c.close()
+	}
+}
+
+type wsMessage struct {
+	Type string `json:"type"` // status | event | maintenance
+	Data any    `json:"data"`
+}
+
+type statusEvent struct {
+	At     time.Time `json:"at"`
+	Kind   string    `json:"kind"` // sink_state | task_state | es_health
+	Target string    `json:"target"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// 比较前后两次状态，得到状态迁移事件（用于即时告警）
+func diffLiveStatus(prev, cur *liveStatus) []statusEvent {
+	if prev == nil {
+		return nil
+	}
+	var evs []statusEvent
+	if prev.Sink.State != cur.Sink.State {
+		evs = append(evs, statusEvent{At: cur.At, Kind: "sink_state", Target: cur.Sink.Name, From: prev.Sink.State, To: cur.Sink.State, Detail: cur.Sink.Error})
+	}
+	before := map[int]string{}
+	for _, t := range prev.Sink.Tasks {
+		before[t.ID] = t.State
+	}
+	for _, t := range cur.Sink.Tasks {
+		if from, ok := before[t.ID]; ok && from != t.State {
+			evs = append(evs, statusEvent{At: cur.At, Kind: "task_state", Target: fmt.Sprintf("%s/%d", cur.Sink.Name, t.ID), From: from, To: t.State, Detail: firstLine(t.Trace)})
+		}
+	}
+	if prev.ES.Status != cur.ES.Status {
+		evs = append(evs, statusEvent{At: cur.At, Kind: "es_health", Target: "elasticsearch", From: prev.ES.Status, To: cur.ES.Status, Detail: cur.ES.Error})
+	}
+	return evs
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// 定时采集状态：推送给 WebSocket 客户端，状态迁移触发通知；两者都不需要时不采集
+func (s *Server) runStatusMonitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var prev *liveStatus
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if s.ws.count() == 0 && !s.notificationsEnabled() {
+			prev = nil
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, interval+10*time.Second)
+		cur := s.collectLiveStatus(cctx)
+		cancel()
+		for _, ev := range diffLiveStatus(prev, cur) {
+			b, _ := json.Marshal(wsMessage{Type: "event", Data: ev})
+			s.ws.broadcast(b, false)
+			if n, ok := notificationForEvent(ev); ok {
+				s.notify(n)
+			}
+		}
+		b, _ := json.Marshal(wsMessage{Type: "status", Data: cur})
+		s.ws.broadcast(b, true)
+		prev = cur
+	}
+}
+
+// 浏览器对 WebSocket 不做 CORS 限制：Origin 须与请求的 Host 相同或在 frontend.allowed_origins 中；
+// 不带 Origin 的非浏览器客户端放行
+func (s *Server) wsOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(s.cfg.Frontend.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if !s.wsOriginAllowed(r) {
+		s.logger.Printf("ws rejected origin=%q host=%s ip=%s", r.Header.Get("Origin"), r.Host, clientIP(r))
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "origin not allowed"})
+		return
+	}
+	c, err := wsUpgrade(w, r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	s.logger.Printf("ws connected ip=%s clients=%d", clientIP(r), s.ws.count()+1)
+	s.ws.add(c)
+	go c.writeLoop()
+	c.readLoop()
+	s.ws.remove(c)
+	s.logger.Printf("ws disconnected ip=%s", clientIP(r))
+}